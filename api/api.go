@@ -1,5 +1,10 @@
 package api
 
+import (
+	"encoding/json"
+	"time"
+)
+
 const (
 	// MsgDaemonOK is the OK response upon successfully reaching daemon
 	MsgDaemonOK = "I'm a little Webhook, short and stout!"
@@ -12,6 +17,38 @@ const (
 
 	// Entries is a constant used in HTTP GET query strings
 	Entries = "entries"
+
+	// DeployID is a constant used in HTTP GET query strings
+	DeployID = "id"
+
+	// DiffFrom and DiffTo are constants used in HTTP GET query strings to
+	// identify the deployments being compared
+	DiffFrom = "from"
+	DiffTo   = "to"
+
+	// ListLimit and ListOffset are constants used in HTTP GET query strings to
+	// page through lists
+	ListLimit  = "limit"
+	ListOffset = "offset"
+
+	// UserAdmin is a constant used in HTTP GET query strings to filter users
+	// by whether they are administrators
+	UserAdmin = "admin"
+
+	// DryRun is a constant used in HTTP query strings to request a dry run
+	DryRun = "dry_run"
+
+	// ComposeRef is a constant used in HTTP GET query strings to identify the
+	// git revision to resolve compose configuration for
+	ComposeRef = "ref"
+
+	// PromoteRequestPart is the name of the multipart form part containing the
+	// PromoteRequest in a promotion
+	PromoteRequestPart = "promotion"
+
+	// PromoteImagesPart is the name of the multipart form part containing the
+	// image tarball in a promotion
+	PromoteImagesPart = "images"
 )
 
 // UpRequest is the configurable body of a UP request to the daemon.
@@ -22,6 +59,106 @@ type UpRequest struct {
 	BuildFilePath string     `json:"build_file_path"`
 	GitOptions    GitOptions `json:"git_options"`
 	WebHookSecret string     `json:"webhook_secret"`
+
+	// Message is an optional description of the deployment
+	Message string `json:"message,omitempty"`
+
+	// FeatureFlags are passed to project containers for this deployment only
+	FeatureFlags map[string]string `json:"feature_flags,omitempty"`
+
+	HealthChecks  map[string]HealthCheck `json:"health_checks,omitempty"`
+	HistoryLimit  int                    `json:"history_limit,omitempty"`
+	Notifications []NotificationChannel  `json:"notifications,omitempty"`
+	Networks      []string               `json:"networks,omitempty"`
+	BuildSecrets  []string               `json:"build_secrets,omitempty"`
+
+	// BuildOrder and BuildParallelism configure the order in which
+	// docker-compose services are built
+	BuildOrder       [][]string `json:"build_order,omitempty"`
+	BuildParallelism int        `json:"build_parallelism,omitempty"`
+
+	// AllowedRefs restricts which branches and refs may be deployed - see
+	// the documentation for supported patterns
+	AllowedRefs []string `json:"allowed_refs,omitempty"`
+
+	// WarmStandby makes webhook pushes pre-warm the project's image instead
+	// of deploying it
+	WarmStandby bool `json:"warm_standby,omitempty"`
+}
+
+// NotificationChannel configures a channel that receives notifications about
+// deployments and security events. Aggregate and Backoff are formatted as Go
+// duration strings, such as "5m".
+type NotificationChannel struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Aggregate string `json:"aggregate,omitempty"`
+
+	// Retries is the number of delivery attempts made before a notification
+	// is recorded as failed, retried after Backoff, which doubles after each
+	// failed attempt
+	Retries int    `json:"retries,omitempty"`
+	Backoff string `json:"backoff,omitempty"`
+
+	// IncludeStatus adds a snapshot of the deployment's status to deploy
+	// events sent to webhook channels
+	IncludeStatus bool `json:"include_status,omitempty"`
+}
+
+// HealthCheck configures health monitoring for a service. Durations are
+// formatted as Go duration strings, such as "30s".
+type HealthCheck struct {
+	StartupGrace       string   `json:"startup_grace,omitempty"`
+	Interval           string   `json:"interval,omitempty"`
+	UnhealthyThreshold int      `json:"unhealthy_threshold,omitempty"`
+	Command            []string `json:"command,omitempty"`
+	Timeout            string   `json:"timeout,omitempty"`
+	Port               int      `json:"port,omitempty"`
+	Protocol           string   `json:"protocol,omitempty"`
+
+	// FlapThreshold is the number of restarts allowed within FlapWindow before
+	// a service is considered to be crash-looping, and StopOnFlap stops
+	// automatic restarts of crash-looping services
+	FlapThreshold int    `json:"flap_threshold,omitempty"`
+	FlapWindow    string `json:"flap_window,omitempty"`
+	StopOnFlap    bool   `json:"stop_on_flap,omitempty"`
+}
+
+// SignedUpRequest is the body of a signed deploy request, which can be used to
+// trigger deployments from CI without an API token. See
+// common.SignDeployRequest for the signing scheme.
+type SignedUpRequest struct {
+	Project   string `json:"project"`
+	Ref       string `json:"ref"`
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message,omitempty"`
+	Signature string `json:"signature"`
+}
+
+// PromoteRequest describes a deployment being promoted from another remote. It
+// is sent alongside the images used by the source deployment.
+type PromoteRequest struct {
+	SourceRemote string       `json:"source_remote"`
+	Record       DeployRecord `json:"record"`
+
+	// Message optionally replaces the source deployment's message
+	Message string `json:"message,omitempty"`
+}
+
+// RestartRequest configures a restart of project containers
+type RestartRequest struct {
+	Stream bool `json:"stream"`
+
+	// Rolling restarts the replicas of each service in batches of BatchSize,
+	// waiting for each batch to become healthy before moving on
+	Rolling   bool `json:"rolling"`
+	BatchSize int  `json:"batch_size,omitempty"`
+}
+
+// ResumeRequest resumes automatic restarts of crash-looping containers. If no
+// services are given, all crash-looping containers are resumed.
+type ResumeRequest struct {
+	Services []string `json:"services,omitempty"`
 }
 
 // GitOptions represents GitHub-related deployment options
@@ -30,6 +167,17 @@ type GitOptions struct {
 	Branch    string `json:"branch"`
 }
 
+// User roles, from most to least privileged
+const (
+	// RoleAdmin users can do anything, including deploying and managing users
+	RoleAdmin = "admin"
+	// RoleUser users can access user-restricted endpoints
+	RoleUser = "user"
+	// RoleViewer users can only access viewer-restricted and read-only
+	// endpoints, such as status and logs
+	RoleViewer = "viewer"
+)
+
 // UserRequest is used for logging in or modifying users
 type UserRequest struct {
 	Username string `json:"username"`
@@ -37,6 +185,169 @@ type UserRequest struct {
 	Email    string `json:"email"`
 	Admin    bool   `json:"admin"`
 	Totp     string `json:"totp"`
+
+	// Role is the role of a new user - if unset, Admin determines whether the
+	// user is an admin or a regular user
+	Role string `json:"role,omitempty"`
+
+	// NewPassword is used when changing a user's password, in which case
+	// Password must be the user's current password
+	NewPassword string `json:"new_password,omitempty"`
+
+	// NewUsername is used when renaming a user
+	NewUsername string `json:"new_username,omitempty"`
+
+	// Remember requests a longer-lived session on login
+	Remember bool `json:"remember,omitempty"`
+
+	// Revision is the revision of the user that an update is based on, as
+	// returned when the user was read. It is required when renaming a user,
+	// setting a user's role, or updating a password, and the update is
+	// rejected if the user has been modified since, so that concurrent updates
+	// do not overwrite each other.
+	Revision *int `json:"revision,omitempty"`
+}
+
+// GetRole returns the role requested for a new user
+func (u UserRequest) GetRole() string {
+	if u.Role != "" {
+		return u.Role
+	}
+	if u.Admin {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+// Token scopes restrict what a scoped API token can be used for
+const (
+	// ScopeDeploy allows deploying and managing the deployed project
+	ScopeDeploy = "deploy"
+	// ScopeLogs allows viewing logs, stats, and deployment history
+	ScopeLogs = "logs"
+	// ScopeUsers allows managing users and sessions
+	ScopeUsers = "users"
+)
+
+// UserListRequest configures which users to list
+type UserListRequest struct {
+	// Limit is the maximum number of users to list - zero lists all users
+	Limit int
+
+	// Offset is the number of users to skip
+	Offset int
+
+	// Admin filters users by whether they are administrators, if set
+	Admin *bool
+}
+
+// UserInfo describes a registered user
+type UserInfo struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+
+	// LastLogin is when the user last logged in - users that have not logged
+	// in since last logins were first recorded do not have one
+	LastLogin *time.Time `json:"last_login,omitempty"`
+
+	// Email is the user's email address, if they have one
+	Email string `json:"email,omitempty"`
+
+	// Revision changes whenever the user's role or password changes, and can
+	// be provided with updates to reject them if the user has changed since
+	Revision int `json:"revision"`
+}
+
+// UserResetRequest configures a reset of all users
+type UserResetRequest struct {
+	// DryRun lists the users that would be removed, along with a confirmation
+	// for them, without removing anyone
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Confirm must be the confirmation from a dry run for users to be removed
+	// - if users have changed since the dry run, nobody is removed
+	Confirm string `json:"confirm,omitempty"`
+}
+
+// UserResetPlan lists the users that a reset would remove
+type UserResetPlan struct {
+	Users   []string `json:"users"`
+	Confirm string   `json:"confirm"`
+}
+
+// PasswordResetRequest requests a token that allows a user to set a new
+// password without knowing their current one
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+// PasswordResetToken is a single-use token that allows a user to set a new
+// password until it expires
+type PasswordResetToken struct {
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Expiry   time.Time `json:"expiry"`
+}
+
+// PasswordResetConfirmRequest sets a new password for a user using a password
+// reset token
+type PasswordResetConfirmRequest struct {
+	Username    string `json:"username"`
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// Identity describes the holder of the token used to make a request
+type Identity struct {
+	User     string `json:"user"`
+	Role     string `json:"role"`
+	Admin    bool   `json:"admin"`
+	ReadOnly bool   `json:"read_only"`
+
+	// Scopes restricts what the token can be used for - tokens without scopes
+	// are unrestricted
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Expiry is when the token expires - master and read-only tokens do not
+	// expire, and do not have one
+	Expiry *time.Time `json:"expiry,omitempty"`
+
+	// Revision is the current revision of the user the token was issued to -
+	// master and read-only tokens do not have one
+	Revision *int `json:"revision,omitempty"`
+}
+
+// UserImportResult summarizes a bulk user import
+type UserImportResult struct {
+	// Created lists the usernames that were created - either all of the
+	// requested users, or none of them
+	Created []string `json:"created"`
+
+	// FailedIndex is the index of the first requested user that could not be
+	// created, if any
+	FailedIndex *int `json:"failed_index,omitempty"`
+}
+
+// UserDatabase is a snapshot of all users on a remote, including their password
+// hashes, which can be used to back up users or move them to another remote
+type UserDatabase struct {
+	// Users maps usernames to their stored records, which should be treated as
+	// opaque
+	Users map[string]json.RawMessage `json:"users"`
+}
+
+// UserRestoreRequest restores users from a UserDatabase. Restored users replace
+// existing users with the same name - if Replace is set, all other existing
+// users are removed as well.
+type UserRestoreRequest struct {
+	UserDatabase
+	Replace bool `json:"replace,omitempty"`
+}
+
+// TokenRequest is used for creating or revoking read-only API tokens
+type TokenRequest struct {
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
 }
 
 // EnvRequest represents a request to manage environment variables
@@ -46,4 +357,8 @@ type EnvRequest struct {
 	Encrypt bool   `json:"encrypt,omitempty"`
 
 	Remove bool `json:"remove,omitempty"`
+
+	// Restart redeploys the current commit once the variable is updated, so
+	// that the change takes effect immediately
+	Restart bool `json:"restart,omitempty"`
 }
@@ -0,0 +1,113 @@
+package api
+
+// Error codes are included in the "error_code" field of error responses from
+// the daemon, and offer a stable, machine-readable alternative to response
+// messages, which may change between releases. Codes are namespaced by the
+// area of the daemon they originate from. New codes may be added, but existing
+// codes are never renamed or repurposed.
+const (
+	// ErrCodeInvalidRequest indicates a malformed or otherwise invalid request
+	ErrCodeInvalidRequest = "request.invalid"
+	// ErrCodeNotFound indicates that a requested resource does not exist
+	ErrCodeNotFound = "request.not_found"
+	// ErrCodeConflict indicates that the request conflicts with current state
+	ErrCodeConflict = "request.conflict"
+	// ErrCodePreconditionFailed indicates that the daemon is not in a state
+	// that allows the request to be fulfilled
+	ErrCodePreconditionFailed = "request.precondition_failed"
+	// ErrCodeRateLimited indicates that the client has made too many requests
+	// and should wait before trying again
+	ErrCodeRateLimited = "request.rate_limited"
+	// ErrCodeShuttingDown indicates that the daemon is shutting down, and is
+	// no longer accepting requests
+	ErrCodeShuttingDown = "request.shutting_down"
+	// ErrCodeTimeout indicates that the daemon took too long to handle the
+	// request, for example because its database was busy
+	ErrCodeTimeout = "request.timeout"
+	// ErrCodeNotSupported indicates a request that the daemon's configuration
+	// does not support
+	ErrCodeNotSupported = "request.not_supported"
+
+	// ErrCodeInvalidToken indicates a missing, malformed, or expired token
+	ErrCodeInvalidToken = "auth.invalid_token"
+	// ErrCodeRevokedToken indicates a token that has been explicitly revoked
+	ErrCodeRevokedToken = "auth.revoked_token"
+	// ErrCodeExpiredToken indicates a session token that has expired, and
+	// requires logging in again
+	ErrCodeExpiredToken = "auth.expired_token"
+	// ErrCodeMalformedToken indicates a token that could not be read, and
+	// requires logging in again
+	ErrCodeMalformedToken = "auth.malformed_token"
+	// ErrCodeInvalidSignature indicates a token that was not signed by the
+	// daemon's current key, which usually means the daemon is misconfigured or
+	// its keys have changed
+	ErrCodeInvalidSignature = "auth.invalid_signature"
+	// ErrCodeUserNotFound indicates a token issued to a user that no longer
+	// exists
+	ErrCodeUserNotFound = "auth.user_not_found"
+	// ErrCodeInvalidCredentials indicates an incorrect username, password, or
+	// TOTP
+	ErrCodeInvalidCredentials = "auth.invalid_credentials"
+	// ErrCodeForbidden indicates a valid token that lacks the permissions
+	// required for the request
+	ErrCodeForbidden = "auth.forbidden"
+	// ErrCodeLockedOut indicates that a username has been temporarily locked
+	// out after too many failed logins
+	ErrCodeLockedOut = "auth.locked_out"
+	// ErrCodeTotpRequired indicates a login for a user with TOTP enabled that
+	// did not provide a TOTP
+	ErrCodeTotpRequired = "auth.totp_required"
+	// ErrCodeInvalidCSRF indicates a request authenticated by the session
+	// cookie that did not provide the matching CSRF token
+	ErrCodeInvalidCSRF = "auth.invalid_csrf"
+	// ErrCodeAddressDenied indicates a request from a client address that is
+	// not allowed to access the remote
+	ErrCodeAddressDenied = "auth.address_denied"
+	// ErrCodePasswordResetRequired indicates a login for a user that must
+	// change their password, which did not provide a new password
+	ErrCodePasswordResetRequired = "auth.password_reset_required"
+	// ErrCodeInvalidResetToken indicates a password reset token that is
+	// incorrect, has already been used, or has expired
+	ErrCodeInvalidResetToken = "auth.invalid_reset_token"
+
+	// ErrCodeUserExists indicates an attempt to add a user that already exists
+	ErrCodeUserExists = "user.exists"
+	// ErrCodeInvalidRole indicates a user role that is not one of the RoleX
+	// constants
+	ErrCodeInvalidRole = "user.invalid_role"
+	// ErrCodePasswordPolicy indicates a new password that does not satisfy
+	// the remote's password policy
+	ErrCodePasswordPolicy = "user.password_policy"
+	// ErrCodeUsernamePolicy indicates a new username that does not satisfy
+	// the remote's username policy
+	ErrCodeUsernamePolicy = "user.username_policy"
+	// ErrCodeLastAdmin indicates an attempt to demote the last remaining
+	// admin, which would leave nobody able to administer the remote
+	ErrCodeLastAdmin = "user.last_admin"
+	// ErrCodeRevisionRequired indicates an update to a user that does not say
+	// which revision of the user it is based on
+	ErrCodeRevisionRequired = "user.revision_required"
+
+	// ErrCodeNoDeployment indicates that no project has been deployed yet
+	ErrCodeNoDeployment = "deploy.not_found"
+	// ErrCodeDeployInProgress indicates that another deployment is running
+	ErrCodeDeployInProgress = "deploy.in_progress"
+	// ErrCodeBuildFailed indicates that the project failed to build
+	ErrCodeBuildFailed = "deploy.build_failed"
+	// ErrCodeRefNotAllowed indicates that the requested branch or ref is not
+	// in the remote's list of deployable refs
+	ErrCodeRefNotAllowed = "deploy.ref_not_allowed"
+	// ErrCodeStartFailed indicates that the project was built, but its
+	// containers failed to start
+	ErrCodeStartFailed = "deploy.start_failed"
+	// ErrCodeNoRollbackTarget indicates a rollback with no earlier successful
+	// deployment of a different commit to roll back to
+	ErrCodeNoRollbackTarget = "deploy.no_rollback_target"
+
+	// ErrCodeInvalidConfig indicates invalid project configuration, such as
+	// health checks or notification channels
+	ErrCodeInvalidConfig = "config.invalid"
+
+	// ErrCodeInternal indicates an unexpected error within the daemon
+	ErrCodeInternal = "internal"
+)
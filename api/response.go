@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"time"
 )
 
 // BaseResponse is the underlying response structure to all responses.
@@ -19,6 +20,10 @@ type BaseResponse struct {
 	// Err contains additional context in the event of an error
 	Err string `json:"error,omitempty"`
 
+	// ErrCode is a stable, machine-readable code identifying the kind of error
+	// encountered - see the ErrCode* constants
+	ErrCode string `json:"error_code,omitempty"`
+
 	// Data contains information the server wants to return
 	Data interface{} `json:"data,omitempty"`
 }
@@ -80,6 +85,10 @@ func (b *BaseResponse) Error() error {
 type TotpResponse struct {
 	TotpSecret  string   `json:"secret"`
 	BackupCodes []string `json:"backup_codes"`
+
+	// URI is an otpauth URI for the secret that can be added to authenticator
+	// apps, usually as a QR code
+	URI string `json:"uri,omitempty"`
 }
 
 // DeploymentStatus lists details about the deployed project
@@ -89,6 +98,242 @@ type DeploymentStatus struct {
 	CommitHash           string   `json:"commit_hash"`
 	CommitMessage        string   `json:"commit_message"`
 	BuildType            string   `json:"build_type"`
+	DeployMessage        string   `json:"deploy_message,omitempty"`
 	Containers           []string `json:"containers"`
 	BuildContainerActive bool     `json:"build_active"`
+
+	// Health maps active containers to their health state
+	Health map[string]string `json:"health,omitempty"`
+
+	// CachedAt is when this status was retrieved by the daemon
+	CachedAt time.Time `json:"cached_at"`
+
+	// Networks maps each shared network the project is attached to to the
+	// containers attached to it
+	Networks map[string][]string `json:"networks,omitempty"`
+
+	// WebhookPath is the path at which the deployed project receives webhooks
+	WebhookPath string `json:"webhook_path,omitempty"`
+}
+
+// DeployRecord describes a past deployment of the project
+type DeployRecord struct {
+	ID            string        `json:"id"`
+	Timestamp     time.Time     `json:"timestamp"`
+	Project       string        `json:"project"`
+	Branch        string        `json:"branch"`
+	CommitHash    string        `json:"commit_hash"`
+	CommitMessage string        `json:"commit_message"`
+	BuildType     string        `json:"build_type"`
+	Message       string        `json:"message,omitempty"`
+	Images        []DeployImage `json:"images"`
+
+	// FeatureFlags are the feature flags passed to containers for this
+	// deployment
+	FeatureFlags map[string]string `json:"feature_flags,omitempty"`
+
+	// Environment contains digests of the values of the environment variables
+	// configured for this deployment, keyed by name
+	Environment map[string]string `json:"environment,omitempty"`
+
+	// PromotedFrom is set if this deployment was promoted from another remote
+	PromotedFrom *Provenance `json:"promoted_from,omitempty"`
+
+	// Prewarmed is set if this deployment used an image that was pre-warmed
+	// for its commit, rather than building one
+	Prewarmed bool `json:"prewarmed,omitempty"`
+
+	// RolledBackFrom is set if this deployment rolled back another, and is
+	// the commit that was rolled back
+	RolledBackFrom string `json:"rolled_back_from,omitempty"`
+}
+
+// DeployImage is an image used in a deployment. ID is the image's content
+// digest, such as "sha256:..."
+type DeployImage struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// Provenance describes where a promoted deployment originated
+type Provenance struct {
+	Remote   string `json:"remote"`
+	DeployID string `json:"deploy_id"`
+}
+
+// DaemonStats is a snapshot of daemon metrics
+type DaemonStats struct {
+	InertiaVersion string `json:"version"`
+	Uptime         string `json:"uptime"`
+	UptimeSeconds  int64  `json:"uptime_seconds"`
+
+	// Counters are keyed by metric name, and match the counters exposed in
+	// Prometheus format
+	Counters map[string]uint64 `json:"counters"`
+}
+
+// DeployedVersion describes the currently active deployment
+type DeployedVersion struct {
+	DeployID   string    `json:"deploy_id"`
+	CommitHash string    `json:"commit_hash"`
+	Ref        string    `json:"ref"`
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+// DeployDiff describes the differences between two recorded deployments
+type DeployDiff struct {
+	From DeployRecord `json:"from"`
+	To   DeployRecord `json:"to"`
+
+	// GitDiff is a patch of the changes between the deployments' commits, if
+	// both commits are available - otherwise, GitDiffError explains why not
+	GitDiff      string `json:"git_diff,omitempty"`
+	GitDiffError string `json:"git_diff_error,omitempty"`
+
+	// Environment changes are reported using digests of the variables' values
+	Environment  []Change `json:"environment"`
+	Images       []Change `json:"images"`
+	FeatureFlags []Change `json:"feature_flags"`
+}
+
+// Change describes a difference in a named value between two deployments.
+// From is empty if the value was added, and To is empty if it was removed.
+type Change struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// FailedNotification describes a notification that could not be delivered
+// after exhausting retries
+type FailedNotification struct {
+	// Channel identifies the channel by type and host - channel URLs are not
+	// included since they often contain credentials
+	Channel  string    `json:"channel"`
+	FailedAt time.Time `json:"failed_at"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Events   []string  `json:"events"`
+}
+
+// ReadOnlyToken describes a read-only API token
+type ReadOnlyToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Hash is a SHA-256 hash of the token - the token itself is only provided
+	// once, when it is created
+	Hash string `json:"hash"`
+}
+
+// Session describes an active login session
+type Session struct {
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	IssuedAt time.Time `json:"issued_at"`
+	Expiry   time.Time `json:"expiry"`
+
+	// Address is the client address the session was started from
+	Address string `json:"address,omitempty"`
+}
+
+// Statuses of deployments in the webhook deploy queue
+const (
+	QueuedDeployPending    = "queued"
+	QueuedDeployRunning    = "running"
+	QueuedDeploySuperseded = "skipped (superseded)"
+)
+
+// QueuedDeploy describes a deployment triggered by a webhook push event
+type QueuedDeploy struct {
+	Branch     string    `json:"branch"`
+	Ref        string    `json:"ref"`
+	Source     string    `json:"source"`
+	ReceivedAt time.Time `json:"received_at"`
+	Status     string    `json:"status"`
+}
+
+// DeployQueue describes the state of webhook-triggered deployments. Skipped
+// deployments are listed most recent first.
+type DeployQueue struct {
+	Running *QueuedDeploy  `json:"running,omitempty"`
+	Pending []QueuedDeploy `json:"pending"`
+	Skipped []QueuedDeploy `json:"skipped"`
+}
+
+// States of a deployment's progress through the deploy pipeline
+const (
+	DeployStateIdle     = "idle"
+	DeployStateQueued   = "queued"
+	DeployStateBuilding = "building"
+	DeployStateStarting = "starting"
+	DeployStateRunning  = "running"
+	DeployStateFailed   = "failed"
+)
+
+// DeployProgress describes the progress of the most recent deployment. State
+// is "idle" if there has not been a deployment since the daemon started.
+type DeployProgress struct {
+	State  string `json:"state"`
+	Source string `json:"source,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// PruneReport describes Docker assets removed by a prune. SpaceReclaimed is
+// in bytes.
+type PruneReport struct {
+	ContainersDeleted []string `json:"containers_deleted"`
+	ImagesDeleted     []string `json:"images_deleted"`
+	SpaceReclaimed    uint64   `json:"space_reclaimed"`
+}
+
+// WebhookProvider describes how a git host must deliver webhooks to the daemon
+type WebhookProvider struct {
+	Name            string   `json:"name"`
+	EventHeader     string   `json:"event_header"`
+	PushEvent       string   `json:"push_event"`
+	SignatureHeader string   `json:"signature_header"`
+	Signature       string   `json:"signature"`
+	ContentTypes    []string `json:"content_types"`
+}
+
+// WebhookConfig describes where and how the daemon receives webhooks
+type WebhookConfig struct {
+	URLs      []string `json:"urls"`
+	Project   string   `json:"project"`
+	Branch    string   `json:"branch"`
+	SecretSet bool     `json:"secret_set"`
+
+	// AllowedRefs lists the patterns of refs that may be deployed - if empty,
+	// all refs are allowed
+	AllowedRefs []string          `json:"allowed_refs,omitempty"`
+	Providers   []WebhookProvider `json:"providers"`
+
+	// WarmStandby is set if pushes pre-warm the project's image instead of
+	// deploying it
+	WarmStandby bool `json:"warm_standby,omitempty"`
+}
+
+// WebhookTestResult reports how the daemon handled a test webhook payload.
+// Error is set if the payload failed verification or parsing, and Reason is
+// set if a valid payload would not have triggered a deployment.
+type WebhookTestResult struct {
+	Provider    string `json:"provider"`
+	Event       string `json:"event"`
+	Verified    bool   `json:"verified"`
+	Parsed      bool   `json:"parsed"`
+	Error       string `json:"error,omitempty"`
+	Repository  string `json:"repository,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+	Branch      string `json:"branch,omitempty"`
+	SSHURL      string `json:"ssh_url,omitempty"`
+	WouldDeploy bool   `json:"would_deploy"`
+	Reason      string `json:"reason,omitempty"`
 }
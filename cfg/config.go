@@ -21,9 +21,67 @@ type Config struct {
 	BuildType     string `toml:"build-type"`
 	BuildFilePath string `toml:"build-file-path"`
 
+	// HistoryLimit is the number of deployments retained in each remote's
+	// deployment history - if unset, the daemon's default is used
+	HistoryLimit int `toml:"history-limit,omitempty"`
+
+	// HealthChecks configures health monitoring for each service, keyed by
+	// service or container name
+	HealthChecks map[string]*HealthCheck `toml:"health-checks"`
+
+	// Notifications configures channels that receive notifications about
+	// deployments and security events
+	Notifications []*Notification `toml:"notifications"`
+
+	// Networks are shared Docker networks that project containers are
+	// attached to, created on the remote if they do not exist yet
+	Networks []string `toml:"networks,omitempty"`
+
+	// BuildSecrets are the names of encrypted environment variables that are
+	// made available to Dockerfile builds as BuildKit secrets
+	BuildSecrets []string `toml:"build-secrets,omitempty"`
+
+	// BuildOrder lists groups of docker-compose services that are built one
+	// group after another, and BuildParallelism limits how many services in
+	// each group are built at once - by default, all services are built at
+	// once
+	BuildOrder       [][]string `toml:"build-order,omitempty"`
+	BuildParallelism int        `toml:"build-parallelism,omitempty"`
+
 	Remotes map[string]*RemoteVPS `toml:"remotes"`
 }
 
+// HealthCheck configures health monitoring for a service
+type HealthCheck struct {
+	StartupGrace       string   `toml:"startup-grace"`
+	Interval           string   `toml:"interval"`
+	UnhealthyThreshold int      `toml:"unhealthy-threshold"`
+	Command            []string `toml:"command"`
+	Timeout            string   `toml:"timeout"`
+	Port               int      `toml:"port"`
+	Protocol           string   `toml:"protocol"`
+
+	// FlapThreshold and FlapWindow configure when a frequently restarted
+	// service is considered to be crash-looping, and StopOnFlap stops
+	// automatic restarts of crash-looping services
+	FlapThreshold int    `toml:"flap-threshold,omitempty"`
+	FlapWindow    string `toml:"flap-window,omitempty"`
+	StopOnFlap    bool   `toml:"stop-on-flap,omitempty"`
+}
+
+// Notification configures a notification channel
+type Notification struct {
+	Type      string `toml:"type"`
+	URL       string `toml:"url"`
+	Aggregate string `toml:"aggregate"`
+	Retries   int    `toml:"retries,omitempty"`
+	Backoff   string `toml:"backoff,omitempty"`
+
+	// IncludeStatus adds a snapshot of the deployment's status to deploy
+	// events sent to webhook channels
+	IncludeStatus bool `toml:"include-status,omitempty"`
+}
+
 // NewConfig sets up Inertia configuration with given properties
 func NewConfig(version, project, buildType, buildFilePath string) *Config {
 	cfg := &Config{
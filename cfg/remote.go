@@ -9,6 +9,15 @@ type RemoteVPS struct {
 	Branch  string        `toml:"branch"`
 	SSHPort string        `toml:"ssh-port"`
 	Daemon  *DaemonConfig `toml:"daemon"`
+
+	// AllowedRefs restricts which branches and refs may be deployed to this
+	// remote, such as "main" or "refs/tags/*" - if empty, all are allowed
+	AllowedRefs []string `toml:"allowed-refs,omitempty"`
+
+	// WarmStandby makes webhook pushes pre-warm the project's image instead
+	// of deploying it, so that the next deployment of the pushed commit can
+	// skip the build
+	WarmStandby bool `toml:"warm-standby,omitempty"`
 }
 
 // DaemonConfig contains parameters for the Daemon
@@ -0,0 +1,32 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// UserConfig represents per-user CLI preferences that apply across projects
+type UserConfig struct {
+	// DefaultRemote is the remote commands target when none is given
+	DefaultRemote string `toml:"default-remote,omitempty"`
+
+	// DefaultProject is the path to the project configuration used when the
+	// current directory does not contain one
+	DefaultProject string `toml:"default-project,omitempty"`
+}
+
+// Write writes user configuration to the given path, creating its parent
+// directory if necessary
+func (u *UserConfig) Write(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return toml.NewEncoder(f).Encode(u)
+}
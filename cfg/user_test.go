@@ -0,0 +1,25 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserConfigWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-user")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, ".inertia", "config.toml")
+	var u = &UserConfig{DefaultRemote: "staging"}
+	assert.Nil(t, u.Write(path))
+
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(contents), `default-remote = "staging"`)
+	assert.NotContains(t, string(contents), "default-project")
+}
@@ -2,15 +2,20 @@ package client
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/ubclaunchpad/inertia/api"
@@ -26,11 +31,19 @@ type Client struct {
 	project       string
 	buildType     string
 	buildFilePath string
+	historyLimit  int
+	healthChecks  map[string]*cfg.HealthCheck
+	notifications []*cfg.Notification
+	networks      []string
+	buildSecrets  []string
+	buildOrder    [][]string
+	parallelism   int
 
 	out io.Writer
 
 	SSH       SSHSession
 	verifySSL bool
+	timeout   time.Duration
 }
 
 // NewClient sets up a client to communicate to the daemon at
@@ -56,6 +69,13 @@ func NewClient(remoteName, keyPassphrase string, config *cfg.Config, out ...io.W
 		project:       config.Project,
 		buildType:     config.BuildType,
 		buildFilePath: config.BuildFilePath,
+		historyLimit:  config.HistoryLimit,
+		healthChecks:  config.HealthChecks,
+		notifications: config.Notifications,
+		networks:      config.Networks,
+		buildSecrets:  config.BuildSecrets,
+		buildOrder:    config.BuildOrder,
+		parallelism:   config.BuildParallelism,
 
 		out: writer,
 	}, true
@@ -67,6 +87,12 @@ func (c *Client) SetSSLVerification(verify bool) {
 	c.verifySSL = verify
 }
 
+// SetTimeout sets a time limit for requests made to the daemon. A zero timeout
+// means no time limit.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
 // BootstrapRemote configures a remote vps for continuous deployment
 // by installing docker, starting the daemon and building a
 // public-private key-pair. It outputs configuration information
@@ -224,8 +250,11 @@ func (c *Client) getDaemonAPIToken(session SSHSession, daemonVersion string) (st
 }
 
 // Up brings the project up on the remote VPS instance specified
-// in the deployment object.
-func (c *Client) Up(gitRemoteURL, buildType string, stream bool) (*http.Response, error) {
+// in the deployment object. The message is an optional description of the
+// deployment, and flags are optional feature flags passed to project
+// containers for this deployment only.
+func (c *Client) Up(gitRemoteURL, buildType, message string, flags map[string]string,
+	stream bool) (*http.Response, error) {
 	if buildType == "" {
 		buildType = c.buildType
 	}
@@ -240,9 +269,94 @@ func (c *Client) Up(gitRemoteURL, buildType string, stream bool) (*http.Response
 			RemoteURL: common.GetSSHRemoteURL(gitRemoteURL),
 			Branch:    c.Branch,
 		},
+		HealthChecks:  c.getHealthChecks(),
+		HistoryLimit:  c.historyLimit,
+		Notifications: c.getNotifications(),
+		Networks:      c.networks,
+		BuildSecrets:  c.buildSecrets,
+		Message:       message,
+		FeatureFlags:  flags,
+
+		BuildOrder:       c.buildOrder,
+		BuildParallelism: c.parallelism,
+
+		AllowedRefs: c.RemoteVPS.AllowedRefs,
+		WarmStandby: c.RemoteVPS.WarmStandby,
 	})
 }
 
+// getHealthChecks converts configured health checks into their API
+// representation
+func (c *Client) getHealthChecks() map[string]api.HealthCheck {
+	if len(c.healthChecks) == 0 {
+		return nil
+	}
+	var checks = make(map[string]api.HealthCheck, len(c.healthChecks))
+	for service, check := range c.healthChecks {
+		if check == nil {
+			continue
+		}
+		checks[service] = api.HealthCheck{
+			StartupGrace:       check.StartupGrace,
+			Interval:           check.Interval,
+			UnhealthyThreshold: check.UnhealthyThreshold,
+			Command:            check.Command,
+			Timeout:            check.Timeout,
+			Port:               check.Port,
+			Protocol:           check.Protocol,
+			FlapThreshold:      check.FlapThreshold,
+			FlapWindow:         check.FlapWindow,
+			StopOnFlap:         check.StopOnFlap,
+		}
+	}
+	return checks
+}
+
+// getNotifications converts configured notification channels into their API
+// representation
+func (c *Client) getNotifications() []api.NotificationChannel {
+	if len(c.notifications) == 0 {
+		return nil
+	}
+	var channels = make([]api.NotificationChannel, 0, len(c.notifications))
+	for _, n := range c.notifications {
+		if n == nil {
+			continue
+		}
+		channels = append(channels, api.NotificationChannel{
+			Type:      n.Type,
+			URL:       n.URL,
+			Aggregate: n.Aggregate,
+			Retries:   n.Retries,
+			Backoff:   n.Backoff,
+
+			IncludeStatus: n.IncludeStatus,
+		})
+	}
+	return channels
+}
+
+// NewSignedUpRequest creates a deploy request for the given ref, signed with
+// this remote's webhook secret. The request can be sent to the daemon by CI
+// in place of an API token, and is only valid for a few minutes after the
+// given timestamp.
+func (c *Client) NewSignedUpRequest(ref, message string, timestamp time.Time) *api.SignedUpRequest {
+	var ts = timestamp.Unix()
+	return &api.SignedUpRequest{
+		Project:   c.project,
+		Ref:       ref,
+		Timestamp: ts,
+		Message:   message,
+		Signature: common.SignDeployRequest(
+			c.RemoteVPS.Daemon.WebHookSecret, c.project, ref, message, ts),
+	}
+}
+
+// SignedUp deploys the given ref using a signed deploy request
+func (c *Client) SignedUp(ref, message string) (*http.Response, error) {
+	return c.post("/deploy", c.NewSignedUpRequest(ref, message, time.Now()))
+}
+
 // LogIn gets an access token for the user with the given credentials. Use ""
 // for totp if none is required.
 func (c *Client) LogIn(user, password, totp string) (*http.Response, error) {
@@ -253,9 +367,25 @@ func (c *Client) LogIn(user, password, totp string) (*http.Response, error) {
 	})
 }
 
-// Token generates token on this remote.
-func (c *Client) Token() (*http.Response, error) {
-	return c.get("/token", nil)
+// LogInWithNewPassword gets an access token for the user with the given
+// credentials, replacing their password with newPassword. This is required if
+// an admin has required the user to reset their password.
+func (c *Client) LogInWithNewPassword(user, password, totp, newPassword string) (*http.Response, error) {
+	return c.post("/user/login", &api.UserRequest{
+		Username:    user,
+		Password:    password,
+		Totp:        totp,
+		NewPassword: newPassword,
+	})
+}
+
+// Token generates token on this remote. If scopes are provided, the token can
+// only be used for endpoints that require one of them.
+func (c *Client) Token(scopes ...string) (*http.Response, error) {
+	if len(scopes) == 0 {
+		return c.get("/token", nil)
+	}
+	return c.get("/token", map[string]string{"scopes": strings.Join(scopes, ",")})
 }
 
 // Prune clears Docker ReadFiles on this remote.
@@ -269,6 +399,29 @@ func (c *Client) Down() (*http.Response, error) {
 	return c.post("/down", nil)
 }
 
+// Rollback rebuilds and deploys the most recent successful deployment of a
+// commit other than the one currently deployed
+func (c *Client) Rollback() (*http.Response, error) {
+	return c.post("/deploy/rollback", nil)
+}
+
+// Restart restarts the project's containers. If rolling is set, replicas of
+// each service are restarted batchSize at a time, waiting for each batch to
+// become healthy before moving on.
+func (c *Client) Restart(rolling bool, batchSize int, stream bool) (*http.Response, error) {
+	return c.post("/restart", &api.RestartRequest{
+		Stream:    stream,
+		Rolling:   rolling,
+		BatchSize: batchSize,
+	})
+}
+
+// Resume resumes automatic restarts of the given crash-looping services, or of
+// all crash-looping services if none are given
+func (c *Client) Resume(services []string) (*http.Response, error) {
+	return c.post("/restart/resume", &api.ResumeRequest{Services: services})
+}
+
 // Status lists the currently active containers on the remote VPS instance
 func (c *Client) Status() (*http.Response, error) {
 	resp, err := c.get("/status", nil)
@@ -279,6 +432,16 @@ func (c *Client) Status() (*http.Response, error) {
 	return resp, err
 }
 
+// Deployed retrieves a summary of the currently active deployment
+func (c *Client) Deployed() (*http.Response, error) {
+	return c.get("/deployed", nil)
+}
+
+// Stats retrieves a snapshot of daemon metrics
+func (c *Client) Stats() (*http.Response, error) {
+	return c.get("/stats", nil)
+}
+
 // Reset shuts down deployment and deletes the contents of the deployment's
 // project directory
 func (c *Client) Reset() (*http.Response, error) {
@@ -329,9 +492,9 @@ func (c *Client) LogsWebSocket(container string, entries int) (SocketReader, err
 }
 
 // UpdateEnv updates environment variable
-func (c *Client) UpdateEnv(name, value string, encrypt, remove bool) (*http.Response, error) {
+func (c *Client) UpdateEnv(name, value string, encrypt, remove, restart bool) (*http.Response, error) {
 	return c.post("/env", api.EnvRequest{
-		Name: name, Value: value, Encrypt: encrypt, Remove: remove,
+		Name: name, Value: value, Encrypt: encrypt, Remove: remove, Restart: restart,
 	})
 }
 
@@ -340,28 +503,277 @@ func (c *Client) ListEnv() (*http.Response, error) {
 	return c.get("/env", nil)
 }
 
-// AddUser adds an authorized user for access to Inertia Web
-func (c *Client) AddUser(username, password string, admin bool) (*http.Response, error) {
+// History lists past deployments, or retrieves a single deployment if an ID
+// is provided. The ID may be a deploy ID or an image digest.
+func (c *Client) History(id string) (*http.Response, error) {
+	var queries map[string]string
+	if id != "" {
+		queries = map[string]string{api.DeployID: id}
+	}
+	return c.get("/history", queries)
+}
+
+// DeployQueue retrieves the state of webhook-triggered deployments
+func (c *Client) DeployQueue() (*http.Response, error) {
+	return c.get("/webhook/queue", nil)
+}
+
+// WebhookConfig retrieves the URLs at which the daemon receives webhooks, and
+// what each supported git host must send
+func (c *Client) WebhookConfig() (*http.Response, error) {
+	return c.get("/webhook/config", nil)
+}
+
+// TestWebhook sends a sample GitHub push event for the given repository and
+// branch to the daemon, signed with this remote's webhook secret. The daemon
+// reports how it verified and parsed the event without deploying anything.
+func (c *Client) TestWebhook(repoURL, branch string) (*http.Response, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"ref": "refs/heads/" + branch,
+		"repository": map[string]string{
+			"name":      path.Base(common.ExtractRepository(repoURL)),
+			"clone_url": repoURL,
+			"ssh_url":   common.GetSSHRemoteURL(repoURL),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.buildRequest("POST", "/webhook/test", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, []byte(c.RemoteVPS.Daemon.WebHookSecret))
+	mac.Write(body)
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+
+	client := buildHTTPSClient(c.verifySSL, c.timeout)
+	return client.Do(req)
+}
+
+// FailedNotifications lists notifications that could not be delivered
+func (c *Client) FailedNotifications() (*http.Response, error) {
+	return c.get("/notifications/failed", nil)
+}
+
+// DiffDeploys compares two past deployments
+func (c *Client) DiffDeploys(from, to string) (*http.Response, error) {
+	return c.get("/history/diff", map[string]string{
+		api.DiffFrom: from,
+		api.DiffTo:   to,
+	})
+}
+
+// ComposeConfig retrieves the resolved docker-compose configuration of the
+// project at the given git revision, or of the current project if empty
+func (c *Client) ComposeConfig(ref string) (*http.Response, error) {
+	var queries map[string]string
+	if ref != "" {
+		queries = map[string]string{api.ComposeRef: ref}
+	}
+	return c.get("/compose/config", queries)
+}
+
+// ExportImages retrieves the images used by the given deployment as a tarball
+func (c *Client) ExportImages(id string) (*http.Response, error) {
+	return c.get("/history/export", map[string]string{api.DeployID: id})
+}
+
+// Promote deploys the given images, which were used by the recorded deployment
+// on the source remote, without building the project. If message is empty,
+// the recorded deployment's message is used.
+func (c *Client) Promote(source, message string, record api.DeployRecord, images io.Reader) (*http.Response, error) {
+	var (
+		reader, writer = io.Pipe()
+		form           = multipart.NewWriter(writer)
+	)
+	go func() {
+		writer.CloseWithError(writePromotion(form, api.PromoteRequest{
+			SourceRemote: source,
+			Record:       record,
+			Message:      message,
+		}, images))
+	}()
+
+	req, err := c.buildRequest("POST", "/promote", reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	client := buildHTTPSClient(c.verifySSL, c.timeout)
+	return client.Do(req)
+}
+
+// writePromotion writes the promotion details followed by the images as
+// multipart form parts
+func writePromotion(form *multipart.Writer, req api.PromoteRequest, images io.Reader) error {
+	part, err := form.CreateFormField(api.PromoteRequestPart)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(part).Encode(req); err != nil {
+		return err
+	}
+	part, err = form.CreateFormFile(api.PromoteImagesPart, "images.tar")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(part, images); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// AddUser adds an authorized user with the given role - see api.RoleAdmin,
+// api.RoleUser, and api.RoleViewer
+func (c *Client) AddUser(username, password, role string) (*http.Response, error) {
+	return c.AddUserWithEmail(username, password, role, "")
+}
+
+// AddUserWithEmail adds a user like AddUser, along with the given email
+// address, which is optional
+func (c *Client) AddUserWithEmail(username, password, role, email string) (*http.Response, error) {
 	return c.post("/user/add", &api.UserRequest{
 		Username: username,
 		Password: password,
-		Admin:    admin,
+		Email:    email,
+		Admin:    role == api.RoleAdmin,
+		Role:     role,
 	})
 }
 
+// ImportUsers adds all of the given users at once - if any of them cannot be
+// added, none of them are
+func (c *Client) ImportUsers(users []api.UserRequest) (*http.Response, error) {
+	return c.post("/user/import", users)
+}
+
+// ExportUsers retrieves a snapshot of all users on the remote, including their
+// password hashes, as an api.UserDatabase.
+func (c *Client) ExportUsers() (*http.Response, error) {
+	return c.get("/user/export", nil)
+}
+
+// RestoreUsers restores users from a snapshot retrieved by ExportUsers. If
+// replace is set, all users not in the snapshot are removed.
+func (c *Client) RestoreUsers(db api.UserDatabase, replace bool) (*http.Response, error) {
+	return c.post("/user/restore", &api.UserRestoreRequest{UserDatabase: db, Replace: replace})
+}
+
 // RemoveUser prevents a user from accessing Inertia Web
 func (c *Client) RemoveUser(username string) (*http.Response, error) {
 	return c.post("/user/remove", &api.UserRequest{Username: username})
 }
 
-// ResetUsers resets all users on the remote.
-func (c *Client) ResetUsers() (*http.Response, error) {
-	return c.post("/user/reset", nil)
+// RevokeSessions invalidates all session tokens issued to the given user.
+func (c *Client) RevokeSessions(username string) (*http.Response, error) {
+	return c.post("/user/revoke", &api.UserRequest{Username: username})
+}
+
+// RenameUser changes the username of the given user, without otherwise
+// changing their account. The revision is the revision of the user that was
+// last read, as listed by ListUsers.
+func (c *Client) RenameUser(username, newUsername string, revision int) (*http.Response, error) {
+	return c.post("/user/rename", &api.UserRequest{
+		Username:    username,
+		NewUsername: newUsername,
+		Revision:    &revision,
+	})
+}
+
+// SetAdmin promotes the given user to an admin, or demotes them to a regular
+// user, without otherwise changing their account. The revision is the revision
+// of the user that was last read, as listed by ListUsers.
+func (c *Client) SetAdmin(username string, admin bool, revision int) (*http.Response, error) {
+	return c.post("/user/setadmin", &api.UserRequest{
+		Username: username,
+		Admin:    admin,
+		Revision: &revision,
+	})
+}
+
+// ForcePasswordReset requires all users on the remote to change their password
+// the next time they log in, and logs them out.
+func (c *Client) ForcePasswordReset() (*http.Response, error) {
+	return c.post("/user/forcereset", nil)
+}
+
+// RequestPasswordReset creates a single-use token that allows the given user to
+// set a new password without knowing their current one, returned as an
+// api.PasswordResetToken. Only admins can request reset tokens.
+func (c *Client) RequestPasswordReset(username string) (*http.Response, error) {
+	return c.post("/user/resetrequest", &api.PasswordResetRequest{Username: username})
 }
 
-// ListUsers lists all users on the remote.
-func (c *Client) ListUsers() (*http.Response, error) {
-	return c.get("/user/list", nil)
+// ConfirmPasswordReset sets a new password for the given user using a token
+// from RequestPasswordReset. This does not require the client to be logged in.
+func (c *Client) ConfirmPasswordReset(username, token, newPassword string) (*http.Response, error) {
+	return c.post("/user/resetconfirm", &api.PasswordResetConfirmRequest{
+		Username:    username,
+		Token:       token,
+		NewPassword: newPassword,
+	})
+}
+
+// ResetUsers resets all users on the remote. Make a dry run first to get the
+// confirmation required to remove users.
+func (c *Client) ResetUsers(req api.UserResetRequest) (*http.Response, error) {
+	return c.post("/user/reset", &req)
+}
+
+// ListUsers lists users on the remote, sorted by username. Use a limit and
+// offset to page through them.
+func (c *Client) ListUsers(req api.UserListRequest) (*http.Response, error) {
+	var queries = map[string]string{}
+	if req.Limit > 0 {
+		queries[api.ListLimit] = strconv.Itoa(req.Limit)
+	}
+	if req.Offset > 0 {
+		queries[api.ListOffset] = strconv.Itoa(req.Offset)
+	}
+	if req.Admin != nil {
+		queries[api.UserAdmin] = strconv.FormatBool(*req.Admin)
+	}
+	return c.get("/user/list", queries)
+}
+
+// RefreshToken exchanges the client's still-valid session token for a new one
+// with a fresh expiry
+func (c *Client) RefreshToken() (*http.Response, error) {
+	return c.post("/user/refresh", nil)
+}
+
+// WhoAmI retrieves the identity of the holder of the configured token
+func (c *Client) WhoAmI() (*http.Response, error) {
+	return c.get("/user/whoami", nil)
+}
+
+// UpdatePassword changes the logged in user's password. The revision is the
+// revision of the user that was last read, as reported by WhoAmI.
+func (c *Client) UpdatePassword(password, newPassword string, revision int) (*http.Response, error) {
+	return c.post("/user/updatepassword", &api.UserRequest{
+		Password:    password,
+		NewPassword: newPassword,
+		Revision:    &revision,
+	})
+}
+
+// AddReadOnlyToken creates a read-only API token with the given name
+func (c *Client) AddReadOnlyToken(name string) (*http.Response, error) {
+	return c.post("/user/tokens/add", &api.TokenRequest{Name: name})
+}
+
+// RevokeReadOnlyToken revokes the read-only API token with the given ID
+func (c *Client) RevokeReadOnlyToken(id string) (*http.Response, error) {
+	return c.post("/user/tokens/revoke", &api.TokenRequest{ID: id})
+}
+
+// ListReadOnlyTokens lists all read-only API tokens on the remote
+func (c *Client) ListReadOnlyTokens() (*http.Response, error) {
+	return c.get("/user/tokens", nil)
 }
 
 // EnableTotp enables Totp for a given user
@@ -372,6 +784,25 @@ func (c *Client) EnableTotp(username, password string) (*http.Response, error) {
 	})
 }
 
+// EnrollTotp starts Totp enrollment for a given user. Totp is enabled once the
+// enrollment is confirmed with ConfirmTotp.
+func (c *Client) EnrollTotp(username, password string) (*http.Response, error) {
+	return c.post("/user/totp/enroll", &api.UserRequest{
+		Username: username,
+		Password: password,
+	})
+}
+
+// ConfirmTotp confirms Totp enrollment for a given user with a Totp from the
+// user's authenticator app
+func (c *Client) ConfirmTotp(username, password, totp string) (*http.Response, error) {
+	return c.post("/user/totp/confirm", &api.UserRequest{
+		Username: username,
+		Password: password,
+		Totp:     totp,
+	})
+}
+
 // DisableTotp disables Totp for a given user
 func (c *Client) DisableTotp() (*http.Response, error) {
 	return c.post("/user/totp/disable", nil)
@@ -390,7 +821,7 @@ func (c *Client) get(endpoint string, queries map[string]string) (*http.Response
 		encodeQuery(req.URL, queries)
 	}
 
-	client := buildHTTPSClient(c.verifySSL)
+	client := buildHTTPSClient(c.verifySSL, c.timeout)
 	return client.Do(req)
 }
 
@@ -413,7 +844,7 @@ func (c *Client) post(endpoint string, requestBody interface{}) (*http.Response,
 		return nil, err
 	}
 
-	client := buildHTTPSClient(c.verifySSL)
+	client := buildHTTPSClient(c.verifySSL, c.timeout)
 	return client.Do(req)
 }
 
@@ -433,20 +864,24 @@ func (c *Client) buildRequest(method string, endpoint string, payload io.Reader)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.Daemon.Token)
 
 	return req, nil
 }
 
-func buildHTTPSClient(verify bool) *http.Client {
-	return &http.Client{Transport: &http.Transport{
-		// Our certificates are self-signed, so will raise
-		// a warning - currently, we ask our client to ignore
-		// this warning.
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: !verify,
+func buildHTTPSClient(verify bool, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// Our certificates are self-signed, so will raise
+			// a warning - currently, we ask our client to ignore
+			// this warning.
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: !verify,
+			},
 		},
-	}}
+	}
 }
 
 func buildWebSocketDialer(verify bool) *websocket.Dialer {
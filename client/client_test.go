@@ -1,6 +1,9 @@
 package client
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -15,6 +18,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/common"
 )
 
 var (
@@ -190,6 +194,11 @@ func TestUp(t *testing.T) {
 		assert.Equal(t, "arjan", upReq.WebHookSecret)
 		assert.Equal(t, "test_project", upReq.Project)
 		assert.Equal(t, "docker-compose", upReq.BuildType)
+		assert.Equal(t, "30s", upReq.HealthChecks["web"].StartupGrace)
+		assert.Equal(t, []string{"pg_isready"}, upReq.HealthChecks["web"].Command)
+		assert.Equal(t, "hotfix", upReq.Message)
+		assert.Equal(t, map[string]string{"beta": "on"}, upReq.FeatureFlags)
+		assert.Equal(t, 10, upReq.HistoryLimit)
 
 		// Check correct endpoint called
 		endpoint := req.URL.Path
@@ -201,12 +210,50 @@ func TestUp(t *testing.T) {
 	defer testServer.Close()
 
 	d := newMockClient(testServer)
+	d.healthChecks = map[string]*cfg.HealthCheck{"web": {
+		StartupGrace: "30s",
+		Command:      []string{"pg_isready"},
+	}}
+	d.historyLimit = 10
 	assert.False(t, d.verifySSL)
-	resp, err := d.Up("myremote.git", "docker-compose", false)
+	resp, err := d.Up("myremote.git", "docker-compose", "hotfix", map[string]string{"beta": "on"}, false)
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestSignedUp(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check request body
+		body, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		defer req.Body.Close()
+		var upReq api.SignedUpRequest
+		err = json.Unmarshal(body, &upReq)
+		assert.Nil(t, err)
+		assert.Equal(t, "test_project", upReq.Project)
+		assert.Equal(t, "refs/heads/master", upReq.Ref)
+		assert.Equal(t, "hotfix", upReq.Message)
+		assert.Equal(t,
+			common.SignDeployRequest("arjan", "test_project", "refs/heads/master", "hotfix", upReq.Timestamp),
+			upReq.Signature)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/deploy", endpoint)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.SignedUp("refs/heads/master", "hotfix")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
 func TestPrune(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -251,6 +298,69 @@ func TestDown(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestRollback(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/deploy/rollback", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Rollback()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestRestart(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/restart", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+
+		defer req.Body.Close()
+		var restartReq api.RestartRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&restartReq))
+		assert.True(t, restartReq.Rolling)
+		assert.Equal(t, 2, restartReq.BatchSize)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Restart(true, 2, false)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestResume(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/restart/resume", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+
+		defer req.Body.Close()
+		var resumeReq api.ResumeRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&resumeReq))
+		assert.Equal(t, []string{"web"}, resumeReq.Services)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Resume([]string{"web"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestStatus(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -273,12 +383,55 @@ func TestStatus(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestDeployed(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/deployed", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Deployed()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestStats(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/stats", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestStatusFail(t *testing.T) {
 	d := newMockClient(nil)
 	_, err := d.Status()
 	assert.Contains(t, err.Error(), "appears offline")
 }
 
+func TestStatusTimeout(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Second)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	d.SetTimeout(50 * time.Millisecond)
+	_, err := d.Status()
+	assert.NotNil(t, err)
+}
+
 func TestReset(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -329,6 +482,182 @@ func TestLogs(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestHistory(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "GET", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/history", endpoint)
+		assert.Equal(t, "3", req.URL.Query().Get(api.DeployID))
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.History("3")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestFailedNotifications(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "GET", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/notifications/failed", endpoint)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.FailedNotifications()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDeployQueue(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/webhook/queue", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.DeployQueue()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWebhookConfig(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/webhook/config", req.URL.Path)
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.WebhookConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTestWebhook(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/webhook/test", req.URL.Path)
+		assert.Equal(t, "push", req.Header.Get("X-GitHub-Event"))
+
+		body, err := ioutil.ReadAll(req.Body)
+		assert.Nil(t, err)
+		mac := hmac.New(sha1.New, []byte("arjan"))
+		mac.Write(body)
+		assert.Equal(t, "sha1="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Hub-Signature"))
+		assert.Contains(t, string(body), `"ref":"refs/heads/dev"`)
+		assert.Contains(t, string(body), `"ssh_url":"git@github.com:ubclaunchpad/inertia.git"`)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.TestWebhook("https://github.com/ubclaunchpad/inertia.git", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestComposeConfig(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/compose/config", req.URL.Path)
+		assert.Equal(t, "dev", req.URL.Query().Get(api.ComposeRef))
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.ComposeConfig("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDiffDeploys(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "GET", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/history/diff", endpoint)
+		assert.Equal(t, "2", req.URL.Query().Get(api.DiffFrom))
+		assert.Equal(t, "3", req.URL.Query().Get(api.DiffTo))
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.DiffDeploys("2", "3")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPromote(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/promote", endpoint)
+
+		// Check body
+		reader, err := req.MultipartReader()
+		assert.Nil(t, err)
+		part, err := reader.NextPart()
+		assert.Nil(t, err)
+		assert.Equal(t, api.PromoteRequestPart, part.FormName())
+		var promoteReq api.PromoteRequest
+		assert.Nil(t, json.NewDecoder(part).Decode(&promoteReq))
+		assert.Equal(t, "staging", promoteReq.SourceRemote)
+		assert.Equal(t, "3", promoteReq.Record.ID)
+		part, err = reader.NextPart()
+		assert.Nil(t, err)
+		assert.Equal(t, api.PromoteImagesPart, part.FormName())
+		images, err := ioutil.ReadAll(part)
+		assert.Nil(t, err)
+		assert.Equal(t, "wow", string(images))
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.Promote("staging", "", api.DeployRecord{ID: "3"}, strings.NewReader("wow"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
 func TestLogsWebsocket(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		// Check request method
@@ -395,7 +724,7 @@ func TestUpdateEnv(t *testing.T) {
 	defer testServer.Close()
 
 	d := newMockClient(testServer)
-	resp, err := d.UpdateEnv("", "", false, false)
+	resp, err := d.UpdateEnv("", "", false, false, false)
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
@@ -439,7 +768,7 @@ func TestAddUser(t *testing.T) {
 	defer testServer.Close()
 
 	d := newMockClient(testServer)
-	resp, err := d.AddUser("", "", false)
+	resp, err := d.AddUser("", "", api.RoleUser)
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
@@ -466,6 +795,182 @@ func TestRemoveUser(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestRevokeSessions(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/revoke", endpoint)
+
+		// Check body
+		var userReq api.UserRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&userReq))
+		assert.Equal(t, "bob", userReq.Username)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.RevokeSessions("bob")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRenameUser(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/rename", endpoint)
+
+		// Check body
+		var userReq api.UserRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&userReq))
+		assert.Equal(t, "bob", userReq.Username)
+		assert.Equal(t, "robert", userReq.NewUsername)
+		if assert.NotNil(t, userReq.Revision) {
+			assert.Equal(t, 2, *userReq.Revision)
+		}
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.RenameUser("bob", "robert", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestSetAdmin(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/setadmin", endpoint)
+
+		// Check body
+		var userReq api.UserRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&userReq))
+		assert.Equal(t, "bob", userReq.Username)
+		assert.True(t, userReq.Admin)
+		if assert.NotNil(t, userReq.Revision) {
+			assert.Equal(t, 3, *userReq.Revision)
+		}
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.SetAdmin("bob", true, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestUpdatePassword(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/updatepassword", endpoint)
+
+		// Check body
+		var userReq api.UserRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&userReq))
+		assert.Equal(t, "old", userReq.Password)
+		assert.Equal(t, "new", userReq.NewPassword)
+		if assert.NotNil(t, userReq.Revision) {
+			assert.Equal(t, 0, *userReq.Revision)
+		}
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.UpdatePassword("old", "new", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestConfirmTotp(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/totp/confirm", endpoint)
+
+		// Check body
+		var userReq api.UserRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&userReq))
+		assert.Equal(t, "bob", userReq.Username)
+		assert.Equal(t, "wowgreat", userReq.Password)
+		assert.Equal(t, "123456", userReq.Totp)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.ConfirmTotp("bob", "wowgreat", "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAddReadOnlyToken(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusCreated)
+
+		// Check request method
+		assert.Equal(t, "POST", req.Method)
+
+		// Check correct endpoint called
+		endpoint := req.URL.Path
+		assert.Equal(t, "/user/tokens/add", endpoint)
+
+		// Check body
+		var tokenReq api.TokenRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&tokenReq))
+		assert.Equal(t, "dashboard", tokenReq.Name)
+
+		// Check auth
+		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
+	}))
+	defer testServer.Close()
+
+	d := newMockClient(testServer)
+	resp, err := d.AddReadOnlyToken("dashboard")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
 func TestResetUser(t *testing.T) {
 	testServer := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -477,13 +982,19 @@ func TestResetUser(t *testing.T) {
 		endpoint := req.URL.Path
 		assert.Equal(t, "/user/reset", endpoint)
 
+		// Check body
+		var reset api.UserResetRequest
+		assert.Nil(t, json.NewDecoder(req.Body).Decode(&reset))
+		assert.Equal(t, "abcde", reset.Confirm)
+		assert.False(t, reset.DryRun)
+
 		// Check auth
 		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
 	}))
 	defer testServer.Close()
 
 	d := newMockClient(testServer)
-	resp, err := d.ResetUsers()
+	resp, err := d.ResetUsers(api.UserResetRequest{Confirm: "abcde"})
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
@@ -499,13 +1010,19 @@ func TestListUsers(t *testing.T) {
 		endpoint := req.URL.Path
 		assert.Equal(t, "/user/list", endpoint)
 
+		// Check queries
+		assert.Equal(t, "10", req.URL.Query().Get(api.ListLimit))
+		assert.Equal(t, "", req.URL.Query().Get(api.ListOffset))
+		assert.Equal(t, "true", req.URL.Query().Get(api.UserAdmin))
+
 		// Check auth
 		assert.Equal(t, "Bearer "+fakeAuth, req.Header.Get("Authorization"))
 	}))
 	defer testServer.Close()
 
 	d := newMockClient(testServer)
-	resp, err := d.ListUsers()
+	var admin = true
+	resp, err := d.ListUsers(api.UserListRequest{Limit: 10, Admin: &admin})
 	assert.Nil(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
@@ -545,6 +1062,9 @@ func TestLogIn(t *testing.T) {
 		endpoint := req.URL.Path
 		assert.Equal(t, "/user/login", endpoint)
 
+		// Check that the token is requested in the response body
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+
 		// Check auth
 		defer req.Body.Close()
 		body, err := ioutil.ReadAll(req.Body)
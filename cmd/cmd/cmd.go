@@ -1,9 +1,34 @@
 package inertiacmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/local"
+)
 
 // Cmd is parent class for all Inertia CLI commands
 type Cmd struct {
 	*cobra.Command
 	ConfigPath string
+
+	// ConfigPathSet indicates that ConfigPath was explicitly provided
+	ConfigPathSet bool
+
+	// FromEnv indicates that configuration should be read from the
+	// environment instead of a configuration file
+	FromEnv bool
+
+	// User contains per-user CLI preferences, such as the default remote
+	User *cfg.UserConfig
+}
+
+// ProjectConfigPath returns the path to the project configuration to read,
+// falling back to the user's default project if ConfigPath was not explicitly
+// provided and does not exist
+func (c *Cmd) ProjectConfigPath() string {
+	if c.User == nil {
+		return c.ConfigPath
+	}
+	return local.ResolveProjectConfigPath(c.ConfigPath, c.ConfigPathSet, c.User.DefaultProject)
 }
@@ -3,6 +3,7 @@ package configcmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/ubclaunchpad/inertia/cfg"
@@ -28,9 +29,10 @@ func AttachConfigCmd(inertia *inertiacmd.Cmd) {
 
 For configuring remote settings, use 'inertia remote'.`,
 		},
-		cfgPath: inertia.ConfigPath,
+		cfgPath: inertia.ProjectConfigPath(),
 	}
 	config.attachSetCmd()
+	config.attachSetDefaultCmd()
 	config.attachUpgradeCmd()
 	config.attachResetCmd()
 	inertia.AddCommand(config.Command)
@@ -87,6 +89,69 @@ func (root *ConfigCmd) attachSetCmd() {
 	root.AddCommand(set)
 }
 
+func (root *ConfigCmd) attachSetDefaultCmd() {
+	const (
+		flagProject = "project"
+		flagClear   = "clear"
+	)
+	var setDefault = &cobra.Command{
+		Use:   "set-default [remote]",
+		Short: "Set the remote and project used when commands omit them",
+		Long: `Sets your default remote, which is targeted when a command is run without a
+remote, for example 'inertia up'. The default is stored in your user
+configuration in ~/.inertia/config.toml and applies across projects.
+
+Use the --project flag to also set the project configuration that is used when
+the current directory does not contain one. For a single invocation, defaults
+can be overridden with the --remote and --config flags, or with the
+INERTIA_DEFAULT_REMOTE and INERTIA_DEFAULT_PROJECT environment variables.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path, err := local.GetUserConfigPath()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			user, err := local.GetUserConfig(path)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			if clear, _ := cmd.Flags().GetBool(flagClear); clear {
+				user = &cfg.UserConfig{}
+			}
+			if len(args) > 0 {
+				if config, _, err := local.GetProjectConfigFromDisk(root.cfgPath); err == nil {
+					if _, found := config.Remotes[args[0]]; !found {
+						fmt.Printf("[WARNING] Remote '%s' is not configured in this project\n", args[0])
+					}
+				}
+				user.DefaultRemote = args[0]
+			}
+			if project, _ := cmd.Flags().GetString(flagProject); project != "" {
+				if user.DefaultProject, err = filepath.Abs(project); err != nil {
+					printutil.Fatal(err)
+				}
+			}
+
+			if err := user.Write(path); err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Printf("Default remote: %s\n", orNone(user.DefaultRemote))
+			fmt.Printf("Default project: %s\n", orNone(user.DefaultProject))
+		},
+	}
+	setDefault.Flags().String(flagProject, "", "path to the default project configuration")
+	setDefault.Flags().Bool(flagClear, false, "remove existing defaults")
+	root.AddCommand(setDefault)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
 func (root *ConfigCmd) attachUpgradeCmd() {
 	const flagVersion = "version"
 	var upgrade = &cobra.Command{
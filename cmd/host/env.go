@@ -10,6 +10,10 @@ import (
 	"github.com/ubclaunchpad/inertia/cmd/printutil"
 )
 
+// flagRestart is used by commands that can redeploy the project once an
+// environment variable is updated
+const flagRestart = "restart"
+
 // EnvCmd is the parent class for the 'env' subcommands
 type EnvCmd struct {
 	*cobra.Command
@@ -49,11 +53,13 @@ func (root *EnvCmd) attachSetCmd() {
 		Use:   "set [name] [value]",
 		Short: "Set an environment variable on your remote",
 		Long: `Sets a persistent environment variable on your remote. Set environment
-variables are applied to all deployed containers.`,
+variables are applied to all deployed containers the next time they are
+deployed - use --restart to redeploy your project immediately.`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			var encrypt, _ = cmd.Flags().GetBool(flagEncrypt)
-			resp, err := root.host.client.UpdateEnv(args[0], args[1], encrypt, false)
+			var restart, _ = cmd.Flags().GetBool(flagRestart)
+			resp, err := root.host.client.UpdateEnv(args[0], args[1], encrypt, false, restart)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -66,6 +72,7 @@ variables are applied to all deployed containers.`,
 		},
 	}
 	set.Flags().BoolP(flagEncrypt, "e", false, "encrypt variable when stored")
+	set.Flags().Bool(flagRestart, false, "redeploy your project so the change takes effect")
 	root.AddCommand(set)
 }
 
@@ -74,10 +81,13 @@ func (root *EnvCmd) attachRemoveCmd() {
 		Use:   "rm [name]",
 		Short: "Remove an environment variable from your remote",
 		Long: `Removes the specified environment variable from deployed containers
-and persistent environment storage.`,
+and persistent environment storage. The variable is removed from containers the
+next time they are deployed - use --restart to redeploy your project
+immediately.`,
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			resp, err := root.host.client.UpdateEnv(args[0], "", false, true)
+			var restart, _ = cmd.Flags().GetBool(flagRestart)
+			resp, err := root.host.client.UpdateEnv(args[0], "", false, true, restart)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -90,6 +100,7 @@ and persistent environment storage.`,
 			fmt.Printf("(Status code %d) %s\n", resp.StatusCode, body)
 		},
 	}
+	remove.Flags().Bool(flagRestart, false, "redeploy your project so the change takes effect")
 	root.AddCommand(remove)
 }
 
@@ -2,12 +2,15 @@ package hostcmd
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/client"
 	inertiacmd "github.com/ubclaunchpad/inertia/cmd/cmd"
@@ -29,7 +32,17 @@ const (
 // AttachHostCmds reads configuration to attach a child command for each
 // configured remote in the configuration
 func AttachHostCmds(inertia *inertiacmd.Cmd) {
-	config, path, err := local.GetProjectConfigFromDisk(inertia.ConfigPath)
+	if inertia.FromEnv {
+		config, err := local.GetProjectConfigFromEnv(inertia.Version)
+		if err != nil {
+			fmt.Printf("[WARNING] Failed to read configuration from environment: %s\n", err.Error())
+			return
+		}
+		AttachHostCmd(inertia, local.EnvRemoteName, config, "", false)
+		return
+	}
+
+	config, path, err := local.GetProjectConfigFromDisk(inertia.ProjectConfigPath())
 	if err != nil {
 		return
 	}
@@ -37,7 +50,9 @@ func AttachHostCmds(inertia *inertiacmd.Cmd) {
 		fmt.Printf("[WARNING] Configuration version '%s' does not match your Inertia CLI version '%s'\n",
 			config.Version, inertia.Version)
 	}
-	for remote := range config.Remotes {
+	for remote, r := range config.Remotes {
+		// environment variables take precedence over the configuration file
+		local.ApplyEnvOverrides(r)
 		AttachHostCmd(inertia, remote, config, path)
 	}
 }
@@ -54,6 +69,9 @@ type HostCmd struct {
 const (
 	flagShort     = "short"
 	flagVerifySSL = "verify-ssl"
+
+	// flagMessage is used by commands that trigger deployments
+	flagMessage = "message"
 )
 
 // AttachHostCmd attaches a subcommand for a configured remote host to the
@@ -113,14 +131,25 @@ Run 'inertia [remote] init' to gather this information.`,
 	host.attachInitCmd()
 	host.attachUpCmd()
 	host.attachDownCmd()
+	host.attachRollbackCmd()
+	host.attachRestartCmd()
+	host.attachResumeCmd()
 	host.attachStatusCmd()
+	host.attachStatsCmd()
+	host.attachNotificationsCmd()
+	host.attachQueueCmd()
+	host.attachWebhookCmd()
 	host.attachLogsCmd()
+	host.attachHistoryCmd()
+	host.attachComposeConfigCmd()
+	host.attachPromoteCmd()
 	AttachUserCmd(host)
 	AttachEnvCmd(host)
 	host.attachSendFileCmd()
 	host.attachSSHCmd()
 	host.attachPruneCmd()
 	host.attachTokenCmd()
+	host.attachSignCmd()
 	host.attachUpgradeCmd()
 	host.attachUninstallCmd()
 
@@ -129,7 +158,10 @@ Run 'inertia [remote] init' to gather this information.`,
 }
 
 func (root *HostCmd) attachUpCmd() {
-	const flagBuildType = "type"
+	const (
+		flagBuildType   = "type"
+		flagFeatureFlag = "flag"
+	)
 	var up = &cobra.Command{
 		Use:   "up",
 		Short: "Bring project online on remote",
@@ -140,6 +172,16 @@ This requires an Inertia daemon to be active on your remote - do this by running
 			// Get flags
 			var short, _ = cmd.Flags().GetBool(flagShort)
 			var buildType, _ = cmd.Flags().GetString(flagBuildType)
+			var message, _ = cmd.Flags().GetString(flagMessage)
+			var flagValues, _ = cmd.Flags().GetStringArray(flagFeatureFlag)
+			var featureFlags = make(map[string]string, len(flagValues))
+			for _, f := range flagValues {
+				var kv = strings.SplitN(f, "=", 2)
+				if len(kv) != 2 {
+					printutil.Fatalf("invalid feature flag '%s' - flags must be in the form 'name=value'", f)
+				}
+				featureFlags[kv[0]] = kv[1]
+			}
 
 			// TODO: support other remotes
 			url, err := local.GetRepoRemote("origin")
@@ -147,41 +189,50 @@ This requires an Inertia daemon to be active on your remote - do this by running
 				printutil.Fatal(err)
 			}
 
-			resp, err := root.client.Up(url, buildType, !short)
+			resp, err := root.client.Up(url, buildType, message, featureFlags, !short)
 			if err != nil {
 				printutil.Fatal(err)
 			}
 			defer resp.Body.Close()
 
 			if short {
-				body, err := ioutil.ReadAll(resp.Body)
+				b, err := api.Unmarshal(resp.Body)
 				if err != nil {
 					printutil.Fatal(err)
 				}
-				switch resp.StatusCode {
-				case http.StatusCreated:
+				if resp.StatusCode == http.StatusCreated {
 					fmt.Printf("(Status code %d) Project build started!\n", resp.StatusCode)
-				case http.StatusUnauthorized:
-					fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
-				case http.StatusPreconditionFailed:
-					fmt.Printf("(Status code %d) Problem with deployment setup:\n%s\n", resp.StatusCode, body)
-				default:
-					fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
-						resp.StatusCode, body)
+					return
 				}
+				printutil.FatalResponse(b)
 			} else {
+				// errors encountered mid-stream are rendered as a final JSON
+				// response, which we check for an error code
+				var last []byte
 				reader := bufio.NewReader(resp.Body)
 				for {
 					line, err := reader.ReadBytes('\n')
+					if len(line) > 0 {
+						fmt.Print(string(line))
+						last = line
+					}
 					if err != nil {
 						break
 					}
-					fmt.Print(string(line))
+				}
+				if b, err := api.Unmarshal(bytes.NewReader(last)); err == nil && b.Error() != nil {
+					if hint := printutil.ErrorHint(b.ErrCode); hint != "" {
+						fmt.Println(hint)
+					}
+					os.Exit(printutil.ExitCode(b.ErrCode))
 				}
 			}
 		},
 	}
 	up.Flags().String(flagBuildType, "", "override configured build method for your project")
+	up.Flags().StringP(flagMessage, "m", "", "describe this deployment (default is the commit message)")
+	up.Flags().StringArray(flagFeatureFlag, []string{},
+		"set a feature flag for this deployment, in the form 'name=value'")
 	root.AddCommand(up)
 }
 
@@ -220,6 +271,135 @@ Requires project to be online - do this by running 'inertia [remote] up`,
 	root.AddCommand(down)
 }
 
+func (root *HostCmd) attachRollbackCmd() {
+	var rollback = &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back to the previous successful deployment",
+		Long: `Rebuilds and deploys the most recent successful deployment of a commit other
+than the one currently deployed, for example to revert a deployment that broke
+your project. The rollback is recorded in 'inertia [remote] history'.
+
+Requires at least one earlier successful deployment of a different commit.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Rollback()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var commit string
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "commit", Value: &commit})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusCreated:
+				fmt.Printf("(Status code %d) Rolled back to commit %s\n", b.HTTPStatusCode, commit)
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Print(printutil.FormatErrorResponse(b))
+				os.Exit(printutil.ExitCode(b.ErrCode))
+			}
+		},
+	}
+	root.AddCommand(rollback)
+}
+
+func (root *HostCmd) attachRestartCmd() {
+	const (
+		flagRolling = "rolling"
+		flagBatch   = "batch"
+	)
+	var restart = &cobra.Command{
+		Use:   "restart",
+		Short: "Restart project containers on remote",
+		Long: `Restarts your project's containers on your remote.
+
+By default, all containers are restarted at once. With --rolling, replicas of
+each service are restarted in batches, waiting for each batch to pass its health
+checks before moving on, so that scaled services remain available.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var short, _ = cmd.Flags().GetBool(flagShort)
+			var rolling, _ = cmd.Flags().GetBool(flagRolling)
+			var batch, _ = cmd.Flags().GetInt(flagBatch)
+			resp, err := root.client.Restart(rolling, batch, !short)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if short {
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				switch resp.StatusCode {
+				case http.StatusOK:
+					fmt.Printf("(Status code %d) Project restarted\n", resp.StatusCode)
+				case http.StatusPreconditionFailed:
+					fmt.Printf("(Status code %d) No containers are currently active\n", resp.StatusCode)
+				case http.StatusUnauthorized:
+					fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, body)
+				default:
+					fmt.Printf("(Status code %d) Unknown response from daemon: %s\n",
+						resp.StatusCode, body)
+				}
+			} else {
+				reader := bufio.NewReader(resp.Body)
+				for {
+					line, err := reader.ReadBytes('\n')
+					if err != nil {
+						break
+					}
+					fmt.Print(string(line))
+				}
+			}
+		},
+	}
+	restart.Flags().Bool(flagRolling, false, "restart replicas of each service in batches")
+	restart.Flags().Int(flagBatch, 1, "number of replicas to restart at a time in a rolling restart")
+	root.AddCommand(restart)
+}
+
+func (root *HostCmd) attachResumeCmd() {
+	var resume = &cobra.Command{
+		Use:   "resume [containers]",
+		Short: "Resume automatic restarts of crash-looping containers",
+		Long: `Resumes automatic restarts of containers that the daemon has marked as
+crash-looping after restarting them too often. If no containers are given, all
+crash-looping containers are resumed.
+
+Resumed containers are probed again as if they had just started, and are
+restarted as usual if they are still unhealthy.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Resume(args)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var resumed []string
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "resumed", Value: &resumed})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				fmt.Printf("(Status code %d) Resumed restarts of %s\n",
+					b.HTTPStatusCode, strings.Join(resumed, ", "))
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Printf("(Status code %d) %s\n", b.HTTPStatusCode, b.Message)
+			}
+		},
+	}
+	root.AddCommand(resume)
+}
+
 func (root *HostCmd) attachStatusCmd() {
 	var stat = &cobra.Command{
 		Use:   "status",
@@ -246,6 +426,9 @@ Requires the Inertia daemon to be active on your remote - do this by running 'in
 					printutil.Fatal(err)
 				}
 				println(printutil.FormatStatus(status))
+				if status.WebhookPath != "" {
+					fmt.Printf("Project webhook URL: %s%s\n", host, status.WebhookPath)
+				}
 			case http.StatusUnauthorized:
 				body, err := ioutil.ReadAll(resp.Body)
 				if err != nil {
@@ -265,6 +448,199 @@ Requires the Inertia daemon to be active on your remote - do this by running 'in
 	root.AddCommand(stat)
 }
 
+func (root *HostCmd) attachStatsCmd() {
+	var stats = &cobra.Command{
+		Use:   "stats",
+		Short: "Print daemon metrics for this remote",
+		Long: `Prints a snapshot of metrics collected by the Inertia daemon on this remote,
+such as request and deployment counts, along with its version and uptime.
+
+The same metrics are available in Prometheus format from the daemon's '/metrics' endpoint.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.Stats()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				var stats = &api.DaemonStats{}
+				if _, err := api.Unmarshal(resp.Body, api.KV{
+					Key: "stats", Value: stats,
+				}); err != nil {
+					printutil.Fatal(err)
+				}
+				println(printutil.FormatStats(stats))
+			case http.StatusUnauthorized:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, body)
+			default:
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				fmt.Printf("(Status code %d) %s\n",
+					resp.StatusCode, body)
+			}
+		},
+	}
+	root.AddCommand(stats)
+}
+
+func (root *HostCmd) attachNotificationsCmd() {
+	var notifications = &cobra.Command{
+		Use:   "notifications",
+		Short: "List notifications that could not be delivered",
+		Long: `Lists notifications that the daemon on this remote could not deliver to
+configured notification channels after exhausting retries, most recent first.
+
+Only the most recent failures are retained, and they are cleared when the
+daemon restarts.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.FailedNotifications()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var failed []api.FailedNotification
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "notifications", Value: &failed})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				if len(failed) == 0 {
+					fmt.Printf("(Status code %d) No failed notifications\n", b.HTTPStatusCode)
+				}
+				for _, f := range failed {
+					fmt.Println(printutil.FormatFailedNotification(&f))
+				}
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Printf("(Status code %d) %s\n", b.HTTPStatusCode, b.Message)
+			}
+		},
+	}
+	root.AddCommand(notifications)
+}
+
+func (root *HostCmd) attachQueueCmd() {
+	var queue = &cobra.Command{
+		Use:   "queue",
+		Short: "Show deployments triggered by webhooks on this remote",
+		Long: `Shows the running and queued deployments triggered by webhook push events on
+this remote, as well as queued deployments that were skipped because a newer
+push to the same branch arrived before they started.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.DeployQueue()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var queue api.DeployQueue
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "queue", Value: &queue})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				fmt.Print(printutil.FormatDeployQueue(&queue))
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Printf("(Status code %d) %s\n", b.HTTPStatusCode, b.Message)
+			}
+		},
+	}
+	root.AddCommand(queue)
+}
+
+func (root *HostCmd) attachWebhookCmd() {
+	var webhook = &cobra.Command{
+		Use:   "webhook [command]",
+		Short: "Inspect and test webhook configuration on this remote",
+		Long: `Inspects and tests the webhook configuration of this remote, to help you set up
+continuous deployment from your repository host.`,
+	}
+
+	var config = &cobra.Command{
+		Use:   "config",
+		Short: "Show the webhook URLs and signature requirements of this remote",
+		Long: `Shows the URLs at which this remote receives webhooks, and the event and
+signature headers each supported git host must send.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.client.WebhookConfig()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var config api.WebhookConfig
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "webhook", Value: &config})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if b.HTTPStatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Print(printutil.FormatWebhookConfig(&config))
+		},
+	}
+	webhook.AddCommand(config)
+
+	const flagBranch = "branch"
+	var test = &cobra.Command{
+		Use:   "test",
+		Short: "Send a test push event to this remote",
+		Long: `Sends a sample GitHub push event for your repository, signed with this remote's
+webhook secret, to this remote. The remote verifies and parses the event as it
+would a real webhook, and reports whether it would have triggered a deployment.
+No deployment is triggered.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var branch, _ = cmd.Flags().GetString(flagBranch)
+			if branch == "" {
+				branch = root.client.Branch
+			}
+			url, err := local.GetRepoRemote("origin")
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			resp, err := root.client.TestWebhook(url, branch)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var result api.WebhookTestResult
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "result", Value: &result})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if b.HTTPStatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Print(printutil.FormatWebhookTestResult(&result))
+			if !result.WouldDeploy {
+				os.Exit(1)
+			}
+		},
+	}
+	test.Flags().String(flagBranch, "", "branch to send a push event for (default is the remote's branch)")
+	webhook.AddCommand(test)
+
+	root.AddCommand(webhook)
+}
+
 func (root *HostCmd) attachLogsCmd() {
 	const flagEntries = "entries"
 	var log = &cobra.Command{
@@ -332,6 +708,243 @@ Use 'inertia [remote] status' to see which containers are active.`,
 	root.AddCommand(log)
 }
 
+func (root *HostCmd) attachHistoryCmd() {
+	var history = &cobra.Command{
+		Use:   "history [deploy]",
+		Short: "List past deployments on this remote",
+		Long: `Lists past deployments on this remote, most recent first.
+
+Provide a deploy ID or image digest to only show a single deployment.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var id string
+			if len(args) > 0 {
+				id = args[0]
+			}
+			records, b, err := root.getHistory(id)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				if len(records) == 0 {
+					fmt.Printf("(Status code %d) No deployments recorded yet\n", b.HTTPStatusCode)
+				}
+				for _, r := range records {
+					fmt.Println(printutil.FormatDeployRecord(&r))
+				}
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Printf("(Status code %d) %s\n", b.HTTPStatusCode, b.Message)
+			}
+		},
+	}
+	history.AddCommand(root.newHistoryDiffCmd())
+	root.AddCommand(history)
+}
+
+func (root *HostCmd) newHistoryDiffCmd() *cobra.Command {
+	const flagOutput = "output"
+	var diff = &cobra.Command{
+		Use:   "diff [from deploy] [to deploy]",
+		Short: "Compare two past deployments on this remote",
+		Long: `Compares two past deployments on this remote, showing changes to the
+project's code, environment variables, images, and feature flags between them.
+
+Environment variable values are never shown - only digests of their values
+are compared. Use --output json to retrieve the comparison as JSON.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var output, _ = cmd.Flags().GetString(flagOutput)
+			if output != "text" && output != "json" {
+				printutil.Fatalf("invalid output format '%s'", output)
+			}
+
+			resp, err := root.client.DiffDeploys(args[0], args[1])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var diff api.DeployDiff
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "diff", Value: &diff})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				if output == "json" {
+					bytes, err := json.MarshalIndent(diff, "", "  ")
+					if err != nil {
+						printutil.Fatal(err)
+					}
+					fmt.Println(string(bytes))
+				} else {
+					fmt.Print(printutil.FormatDeployDiff(&diff))
+				}
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Printf("(Status code %d) %s\n", b.HTTPStatusCode, b.Message)
+			}
+		},
+	}
+	diff.Flags().StringP(flagOutput, "o", "text", "output format (text or json)")
+	return diff
+}
+
+func (root *HostCmd) attachComposeConfigCmd() {
+	const flagOutput = "output"
+	var config = &cobra.Command{
+		Use:   "compose-config [ref]",
+		Short: "Print the resolved docker-compose configuration on this remote",
+		Long: `Prints the fully resolved docker-compose configuration the daemon on this
+remote uses for your project, as output by 'docker-compose config', after
+environment variable interpolation. If a git ref such as a branch, tag, or
+commit is given, the configuration at that ref is resolved instead of the
+currently checked out project.
+
+Values of encrypted environment variables are masked. Use --output to write
+the configuration to a file.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var ref string
+			if len(args) > 0 {
+				ref = args[0]
+			}
+			resp, err := root.client.ComposeConfig(ref)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var config string
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "config", Value: &config})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch b.HTTPStatusCode {
+			case http.StatusOK:
+				if output, _ := cmd.Flags().GetString(flagOutput); output != "" {
+					if err := ioutil.WriteFile(output, []byte(config), 0644); err != nil {
+						printutil.Fatal(err)
+					}
+					fmt.Printf("Configuration written to %s\n", output)
+					return
+				}
+				fmt.Print(config)
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", b.HTTPStatusCode, b.Message)
+			default:
+				fmt.Print(printutil.FormatErrorResponse(b))
+			}
+		},
+	}
+	config.Flags().StringP(flagOutput, "o", "", "file to write configuration to")
+	root.AddCommand(config)
+}
+
+func (root *HostCmd) attachPromoteCmd() {
+	const flagDeploy = "deploy"
+	var promote = &cobra.Command{
+		Use:   "promote [target remote]",
+		Short: "Promote a deployment on this remote to another remote",
+		Long: `Promotes a deployment on this remote to another remote, for example from a
+staging remote to a production remote.
+
+The images used by the deployment are sent directly to the target remote and
+deployed without being rebuilt, and the target remote's deployment history
+records where they came from. By default, the most recent deployment is
+promoted - use the --deploy flag to provide a deploy ID or image digest from
+'inertia [remote] history'.
+
+The target remote must already be set up with 'inertia [target] up'.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var id, _ = cmd.Flags().GetString(flagDeploy)
+			var message, _ = cmd.Flags().GetString(flagMessage)
+			var verify, _ = cmd.Flags().GetBool(flagVerifySSL)
+			if args[0] == root.remote {
+				printutil.Fatal("cannot promote a deployment to the same remote")
+			}
+			target, found := client.NewClient(args[0], os.Getenv(EnvSSHPassphrase), root.config, os.Stdout)
+			if !found {
+				printutil.Fatal("Remote not found")
+			}
+			target.SetSSLVerification(verify)
+
+			// Find deployment to promote
+			records, b, err := root.getHistory(id)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if b.HTTPStatusCode != http.StatusOK {
+				printutil.Fatalf("(Status code %d) %s", b.HTTPStatusCode, b.Message)
+			}
+			if len(records) == 0 {
+				printutil.Fatal("no deployments to promote")
+			}
+			var record = records[0]
+			fmt.Printf("Promoting deploy %s (%s) from '%s' to '%s'\n",
+				record.ID, record.CommitHash, root.remote, args[0])
+
+			// Stream images from this remote to the target
+			images, err := root.client.ExportImages(record.ID)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer images.Body.Close()
+			if images.StatusCode != http.StatusOK {
+				body, _ := ioutil.ReadAll(images.Body)
+				printutil.Fatalf("(Status code %d) failed to export images: %s", images.StatusCode, body)
+			}
+			resp, err := target.Promote(root.remote, message, record, images.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			reader := bufio.NewReader(resp.Body)
+			for {
+				line, err := reader.ReadBytes('\n')
+				if err != nil {
+					break
+				}
+				fmt.Print(string(line))
+			}
+		},
+	}
+	promote.Flags().String(flagDeploy, "", "deploy ID or image digest to promote (default is the most recent deployment)")
+	promote.Flags().StringP(flagMessage, "m", "", "describe this deployment (default is the promoted deployment's message)")
+	root.AddCommand(promote)
+}
+
+// getHistory retrieves past deployments from the remote, or a single
+// deployment if an ID is provided
+func (root *HostCmd) getHistory(id string) ([]api.DeployRecord, *api.BaseResponse, error) {
+	resp, err := root.client.History(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if id == "" {
+		var records []api.DeployRecord
+		b, err := api.Unmarshal(resp.Body, api.KV{Key: "history", Value: &records})
+		return records, b, err
+	}
+	var record api.DeployRecord
+	b, err := api.Unmarshal(resp.Body, api.KV{Key: "deploy", Value: &record})
+	if err != nil || b.HTTPStatusCode != http.StatusOK {
+		return nil, b, err
+	}
+	return []api.DeployRecord{record}, b, nil
+}
+
 func (root *HostCmd) attachPruneCmd() {
 	var prune = &cobra.Command{
 		Use:   "prune",
@@ -515,9 +1128,14 @@ func (root *HostCmd) attachTokenCmd() {
 	var token = &cobra.Command{
 		Use:   "token",
 		Short: "Generate tokens associated with permission levels for admin to share.",
-		Long:  `Generate tokens associated with permission levels for team leads to share`,
+		Long: `Generate tokens associated with permission levels for team leads to share.
+
+Use --scope to restrict the token to certain endpoints - for example, a token
+with the 'deploy' scope can be used by a CI system to deploy your project, but
+not to manage users. Available scopes are 'deploy', 'logs', and 'users'.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			resp, err := root.client.Token()
+			var scopes, _ = cmd.Flags().GetStringSlice("scope")
+			resp, err := root.client.Token(scopes...)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -540,9 +1158,43 @@ func (root *HostCmd) attachTokenCmd() {
 			}
 		},
 	}
+	token.Flags().StringSlice("scope", nil,
+		"restrict the token to the given scopes")
 	root.AddCommand(token)
 }
 
+func (root *HostCmd) attachSignCmd() {
+	const flagRef = "ref"
+	var sign = &cobra.Command{
+		Use:   "sign",
+		Short: "Generate a signed deploy request for use in CI",
+		Long: `Generates a deploy request signed with this remote's webhook secret.
+
+This allows CI to trigger deployments without an API token - the request is
+only valid for a few minutes after it is generated, and can be sent to the
+daemon's /deploy endpoint:
+
+	curl -k -X POST -d "$(inertia ${remote_name} sign)" \
+		https://${remote_ip}:${daemon_port}/deploy`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var ref, _ = cmd.Flags().GetString(flagRef)
+			if ref == "" {
+				ref = "refs/heads/" + root.client.Branch
+			}
+
+			var message, _ = cmd.Flags().GetString(flagMessage)
+			b, err := json.Marshal(root.client.NewSignedUpRequest(ref, message, time.Now()))
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Println(string(b))
+		},
+	}
+	sign.Flags().String(flagRef, "", "git ref to deploy (default is the remote's configured branch)")
+	sign.Flags().StringP(flagMessage, "m", "", "describe this deployment (default is the commit message)")
+	root.AddCommand(sign)
+}
+
 func (root *HostCmd) attachUpgradeCmd() {
 	const flagVersion = "version"
 	var upgrade = &cobra.Command{
@@ -1,15 +1,19 @@
 package hostcmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/cmd/printutil"
+	"github.com/ubclaunchpad/inertia/local"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -32,9 +36,22 @@ func AttachUserCmd(host *HostCmd) {
 
 	// attach children
 	user.attachLoginCmd()
+	user.attachRefreshCmd()
+	user.attachWhoamiCmd()
+	user.attachPasswdCmd()
 	AttachTotpCmd(user)
+	AttachTokenCmd(user)
 	user.attachAddCmd()
+	user.attachImportCmd()
+	user.attachExportCmd()
+	user.attachRestoreCmd()
 	user.attachRemoveCmd()
+	user.attachRenameCmd()
+	user.attachRevokeCmd()
+	user.attachForceResetCmd()
+	user.attachResetRequestCmd()
+	user.attachResetConfirmCmd()
+	user.attachSetAdminCmd()
 	user.attachListCmd()
 	user.attachResetCmd()
 
@@ -43,7 +60,11 @@ func AttachUserCmd(host *HostCmd) {
 }
 
 func (root *UserCmd) attachAddCmd() {
-	const flagAdmin = "admin"
+	const (
+		flagAdmin = "admin"
+		flagRole  = "role"
+		flagEmail = "email"
+	)
 	var add = &cobra.Command{
 		Use:   "add [user]",
 		Short: "Create a user with access to this remote's Inertia daemon",
@@ -52,7 +73,13 @@ func (root *UserCmd) attachAddCmd() {
 This user will be able to log in and view or configure the deployment
 from the Inertia CLI (using 'inertia [remote] user login').
 
-Use the --admin flag to create an admin user.`,
+Use the --role flag to set the user's role:
+
+- admin: can do anything, including deploying and managing users
+- user: can access everything except administrative endpoints
+- viewer: can only view status, logs, and other read-only information
+
+The --admin flag is shorthand for '--role admin'.`,
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Print("Enter a password for user: ")
@@ -63,8 +90,12 @@ Use the --admin flag to create an admin user.`,
 			var password = strings.TrimSpace(string(bytePassword))
 			fmt.Print("\n")
 
-			var admin, _ = cmd.Flags().GetBool(flagAdmin)
-			resp, err := root.host.client.AddUser(args[0], password, admin)
+			var role, _ = cmd.Flags().GetString(flagRole)
+			if admin, _ := cmd.Flags().GetBool(flagAdmin); admin {
+				role = api.RoleAdmin
+			}
+			var email, _ = cmd.Flags().GetString(flagEmail)
+			resp, err := root.host.client.AddUserWithEmail(args[0], password, role, email)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -86,9 +117,132 @@ Use the --admin flag to create an admin user.`,
 		},
 	}
 	add.Flags().Bool(flagAdmin, false, "create a user with administrator permissions")
+	add.Flags().String(flagRole, api.RoleUser, "role of the user - one of 'admin', 'user', or 'viewer'")
+	add.Flags().String(flagEmail, "", "email address of the user")
 	root.AddCommand(add)
 }
 
+func (root *UserCmd) attachImportCmd() {
+	var importCmd = &cobra.Command{
+		Use:   "import [file]",
+		Short: "Create several users at once from a file",
+		Long: `Creates all of the users listed in the given JSON file, which should contain
+an array of users, for example:
+
+	[
+		{ "username": "alice", "password": "...", "admin": true },
+		{ "username": "bob", "password": "..." }
+	]
+
+If any user is invalid or already exists, none of the users are created.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			bytes, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			var users []api.UserRequest
+			if err = json.Unmarshal(bytes, &users); err != nil {
+				printutil.Fatalf("invalid user file: %s", err.Error())
+			}
+
+			resp, err := root.host.client.ImportUsers(users)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var result api.UserImportResult
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "import", Value: &result})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusCreated {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("(Status code %d) %d users added:\n%s\n", resp.StatusCode,
+				len(result.Created), strings.Join(result.Created, "\n"))
+		},
+	}
+	root.AddCommand(importCmd)
+}
+
+func (root *UserCmd) attachExportCmd() {
+	var export = &cobra.Command{
+		Use:   "export [file]",
+		Short: "Back up all users to a file",
+		Long: `Saves all users on your remote, including their password hashes, to the
+given file. The file can be used to restore users with 'inertia [remote] user
+restore', and should be kept somewhere safe.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.ExportUsers()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				b, err := api.Unmarshal(resp.Body)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				printutil.FatalResponse(b)
+			}
+			var db api.UserDatabase
+			if err = json.NewDecoder(resp.Body).Decode(&db); err != nil {
+				printutil.Fatal(err)
+			}
+			bytes, err := json.MarshalIndent(db, "", "  ")
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if err = ioutil.WriteFile(args[0], bytes, 0600); err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Printf("%d users exported to %s.\n", len(db.Users), args[0])
+		},
+	}
+	root.AddCommand(export)
+}
+
+func (root *UserCmd) attachRestoreCmd() {
+	var restore = &cobra.Command{
+		Use:   "restore [file]",
+		Short: "Restore users from a backup",
+		Long: `Restores all users in the given file, which should have been created with
+'inertia [remote] user export'. Restored users replace existing users with the
+same name, and everyone is logged out. If any user is invalid, no users are
+restored.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			bytes, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			var db api.UserDatabase
+			if err = json.Unmarshal(bytes, &db); err != nil {
+				printutil.Fatalf("invalid user file: %s", err.Error())
+			}
+
+			var replace, _ = cmd.Flags().GetBool("replace")
+			resp, err := root.host.client.RestoreUsers(db, replace)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("%d users restored.\n", len(db.Users))
+		},
+	}
+	restore.Flags().Bool("replace", false, "remove all users that are not in the file")
+	root.AddCommand(restore)
+}
+
 func (root *UserCmd) attachRemoveCmd() {
 	var remove = &cobra.Command{
 		Use:   "rm [user]",
@@ -122,6 +276,188 @@ remotely.`,
 	root.AddCommand(remove)
 }
 
+func (root *UserCmd) attachRenameCmd() {
+	var rename = &cobra.Command{
+		Use:   "rename [user] [new username]",
+		Short: "Change a user's username",
+		Long: `Changes the username of the given user, without changing their password or
+role. The user will need to log in again with their new username.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var revision = root.userRevision(args[0])
+			resp, err := root.host.client.RenameUser(args[0], args[1], revision)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("User '%s' renamed to '%s'.\n", args[0], args[1])
+		},
+	}
+	root.AddCommand(rename)
+}
+
+func (root *UserCmd) attachRevokeCmd() {
+	var revoke = &cobra.Command{
+		Use:   "revoke [user]",
+		Short: "Log a user out everywhere",
+		Long: `Revokes all session tokens issued to the given user - for example, if
+one of their devices has been lost. The user can log in again as usual.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.RevokeSessions(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("Sessions for user '%s' revoked.\n", args[0])
+		},
+	}
+	root.AddCommand(revoke)
+}
+
+func (root *UserCmd) attachForceResetCmd() {
+	var forceReset = &cobra.Command{
+		Use:   "forcereset",
+		Short: "Require all users to change their password",
+		Long: `Requires every user to change their password the next time they log
+in, and logs everyone out - for example, after a suspected breach.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.ForcePasswordReset()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var marked int
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "users", Value: &marked})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("%d users must change their password on next login.\n", marked)
+		},
+	}
+	root.AddCommand(forceReset)
+}
+
+func (root *UserCmd) attachResetRequestCmd() {
+	var resetRequest = &cobra.Command{
+		Use:   "resetrequest [user]",
+		Short: "Create a password reset token for a user",
+		Long: `Creates a single-use token that allows the given user to set a new password
+without knowing their current one, using 'inertia [remote] user resetconfirm'.
+The token expires after a while, and replaces any token created before. It is
+up to you to pass the token on to the user.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.RequestPasswordReset(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var reset api.PasswordResetToken
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "reset", Value: &reset})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("Reset token for user '%s' (expires %s):\n%s\n",
+				reset.Username, reset.Expiry.Local().Format(time.RFC1123), reset.Token)
+		},
+	}
+	root.AddCommand(resetRequest)
+}
+
+func (root *UserCmd) attachResetConfirmCmd() {
+	var resetConfirm = &cobra.Command{
+		Use:   "resetconfirm [user] [token]",
+		Short: "Set a new password using a password reset token",
+		Long: `Sets a new password for the given user using a token from
+'inertia [remote] user resetrequest'. You will be prompted for the new
+password. You do not need to be logged in, and each token can only be used
+once.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print("New password: ")
+			password, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Println()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			resp, err := root.host.client.ConfirmPasswordReset(
+				args[0], args[1], strings.TrimSpace(string(password)))
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Println("Password reset - log in with your new password.")
+		},
+	}
+	root.AddCommand(resetConfirm)
+}
+
+func (root *UserCmd) attachSetAdminCmd() {
+	var setAdmin = &cobra.Command{
+		Use:   "setadmin [user] [true|false]",
+		Short: "Promote a user to admin, or demote an admin",
+		Long: `Promotes the given user to an admin, or demotes an admin to a regular user,
+without changing their password. The last remaining admin cannot be demoted.
+The user will need to log in again to pick up their new role.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			admin, err := strconv.ParseBool(args[1])
+			if err != nil {
+				printutil.Fatal("second argument must be 'true' or 'false'")
+			}
+			var revision = root.userRevision(args[0])
+			resp, err := root.host.client.SetAdmin(args[0], admin, revision)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			if admin {
+				fmt.Printf("User '%s' is now an admin.\n", args[0])
+			} else {
+				fmt.Printf("User '%s' is no longer an admin.\n", args[0])
+			}
+		},
+	}
+	root.AddCommand(setAdmin)
+}
+
 func (root *UserCmd) attachLoginCmd() {
 	var login = &cobra.Command{
 		Use:   "login [user]",
@@ -138,20 +474,37 @@ func (root *UserCmd) attachLoginCmd() {
 			}
 
 			var totp, _ = cmd.Flags().GetString("totp")
+			var newPassword string
 			resp, err := root.host.client.LogIn(username, string(pwBytes), totp)
 			if err != nil {
 				printutil.Fatal(err)
 			}
 
-			if resp.StatusCode == http.StatusExpectationFailed {
-				// a TOTP is required
+			if requiresPasswordReset(resp) {
+				fmt.Println("You must change your password before logging in.")
+				fmt.Print("New password: ")
+				newBytes, err := terminal.ReadPassword(int(syscall.Stdin))
+				fmt.Println()
+				if err != nil {
+					printutil.Fatal(err)
+				}
+				newPassword = strings.TrimSpace(string(newBytes))
+				resp, err = root.host.client.LogInWithNewPassword(
+					username, string(pwBytes), totp, newPassword)
+				if err != nil {
+					printutil.Fatal(err)
+				}
+			}
+
+			if requiresTotp(resp) {
 				fmt.Print("Authentication code (or backup code): ")
 				totpBytes, err := terminal.ReadPassword(int(syscall.Stdin))
 				fmt.Println()
 				if err != nil {
 					printutil.Fatal(err)
 				}
-				resp, err = root.host.client.LogIn(username, string(pwBytes), string(totpBytes))
+				resp, err = root.host.client.LogInWithNewPassword(
+					username, string(pwBytes), string(totpBytes), newPassword)
 				if err != nil {
 					printutil.Fatal(err)
 				}
@@ -167,36 +520,247 @@ func (root *UserCmd) attachLoginCmd() {
 			if api.Unmarshal(resp.Body, api.KV{Key: "token", Value: &token}); err != nil {
 				printutil.Fatal(err)
 			}
+			root.saveToken(token, "You have been logged in successfully")
+		},
+	}
+	login.Flags().String("totp", "", "auth code or backup code for 2FA")
+	root.AddCommand(login)
+}
 
-			var config = root.host.config
-			var remote = root.host.remote
-			config.Remotes[remote].Daemon.Token = string(token)
-			if err = config.Write(root.host.cfgPath); err != nil {
+func (root *UserCmd) attachRefreshCmd() {
+	var refresh = &cobra.Command{
+		Use:   "refresh",
+		Short: "Extend your session",
+		Long: `Exchanges your current session token for a new one with a fresh expiry.
+The current token must not have expired yet - otherwise, log in again with
+'inertia [remote] user login'.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.RefreshToken()
+			if err != nil {
 				printutil.Fatal(err)
 			}
+			defer resp.Body.Close()
+			var token string
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "token", Value: &token})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			root.saveToken(token, "Your session has been refreshed")
+		},
+	}
+	root.AddCommand(refresh)
+}
 
-			fmt.Println("You have been logged in successfully.")
+func (root *UserCmd) attachWhoamiCmd() {
+	var whoami = &cobra.Command{
+		Use:   "whoami",
+		Short: "Show who you are logged in as",
+		Long: `Shows the user, role, and token expiry of the token configured for
+your remote.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.WhoAmI()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var identity api.Identity
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "identity", Value: &identity})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+
+			fmt.Printf("user:    %s\n", identity.User)
+			fmt.Printf("role:    %s\n", identity.Role)
+			switch {
+			case identity.ReadOnly:
+				fmt.Println("expiry:  never (read-only token)")
+			case identity.Expiry == nil:
+				fmt.Println("expiry:  never")
+			default:
+				fmt.Printf("expiry:  %s\n", identity.Expiry.Local().Format(time.RFC1123))
+			}
 		},
 	}
-	login.Flags().String("totp", "", "auth code or backup code for 2FA")
-	root.AddCommand(login)
+	root.AddCommand(whoami)
+}
+
+// userRevision reads the current revision of the given user, which must be
+// provided with updates to the user
+func (root *UserCmd) userRevision(username string) int {
+	resp, err := root.host.client.ListUsers(api.UserListRequest{})
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var details = make([]api.UserInfo, 0)
+	b, err := api.Unmarshal(resp.Body, api.KV{Key: "details", Value: &details})
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		printutil.FatalResponse(b)
+	}
+	for _, u := range details {
+		if u.Username == username {
+			return u.Revision
+		}
+	}
+	printutil.Fatalf("user '%s' not found", username)
+	return 0
+}
+
+// ownRevision reads the current revision of the logged in user, which must be
+// provided with updates to the user
+func (root *UserCmd) ownRevision() int {
+	resp, err := root.host.client.WhoAmI()
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var identity api.Identity
+	b, err := api.Unmarshal(resp.Body, api.KV{Key: "identity", Value: &identity})
+	if err != nil {
+		printutil.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		printutil.FatalResponse(b)
+	}
+	if identity.Revision == nil {
+		printutil.Fatal("passwords can only be changed by users logged in with a session")
+	}
+	return *identity.Revision
+}
+
+// saveToken saves the given token to the remote's configuration, and reports
+// success with the given message
+func (root *UserCmd) saveToken(token, message string) {
+	var config = root.host.config
+	var remote = root.host.remote
+	config.Remotes[remote].Daemon.Token = token
+	if root.host.cfgPath == "" {
+		// configuration was read from the environment, so there is
+		// nowhere to save the token
+		fmt.Printf("%s - set %s=%s to use this session.\n",
+			message, local.EnvToken, token)
+		return
+	}
+	if err := config.Write(root.host.cfgPath); err != nil {
+		printutil.Fatal(err)
+	}
+	fmt.Println(message + ".")
+}
+
+// requiresTotp checks if the given login response rejected the login because
+// the user has TOTP enabled, and no TOTP was provided
+func requiresTotp(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	defer resp.Body.Close()
+	b, err := api.Unmarshal(resp.Body)
+	return err == nil && b.ErrCode == api.ErrCodeTotpRequired
+}
+
+func requiresPasswordReset(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		return false
+	}
+	defer resp.Body.Close()
+	b, err := api.Unmarshal(resp.Body)
+	return err == nil && b.ErrCode == api.ErrCodePasswordResetRequired
+}
+
+func (root *UserCmd) attachPasswdCmd() {
+	var passwd = &cobra.Command{
+		Use:   "passwd",
+		Short: "Change your password",
+		Long: `Changes the password of the user you are logged in as. You will be
+prompted for your current password and a new one.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print("Current password: ")
+			current, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Println()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			fmt.Print("New password: ")
+			updated, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Println()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			resp, err := root.host.client.UpdatePassword(
+				string(current), strings.TrimSpace(string(updated)), root.ownRevision())
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Println("Password updated.")
+		},
+	}
+	root.AddCommand(passwd)
 }
 
 func (root *UserCmd) attachResetCmd() {
+	const flagDryRun = "dry-run"
 	var reset = &cobra.Command{
 		Use:   "reset",
 		Short: "Reset user database on your remote",
 		Long: `Removes all users credentials on your remote. All configured user
 will no longer be able to log in and view or configure the deployment
-remotely.`,
+remotely.
+
+The users that would be removed are listed first, and you will be asked to
+confirm before anyone is removed. Use --dry-run to only list them.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			resp, err := root.host.client.ResetUsers()
+			resp, err := root.host.client.ResetUsers(api.UserResetRequest{DryRun: true})
 			if err != nil {
 				printutil.Fatal(err)
 			}
 			defer resp.Body.Close()
+			var plan api.UserResetPlan
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "reset", Value: &plan})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+			fmt.Printf("The following %d users will be removed:\n%s\n",
+				len(plan.Users), strings.Join(plan.Users, "\n"))
+			if dryRun, _ := cmd.Flags().GetBool(flagDryRun); dryRun {
+				return
+			}
 
-			body, err := ioutil.ReadAll(resp.Body)
+			println("This is irreversible. Continue? (y/n)")
+			var response string
+			if _, err := fmt.Scanln(&response); err != nil || response != "y" {
+				printutil.Fatal("aborting")
+			}
+
+			resp, err = root.host.client.ResetUsers(api.UserResetRequest{Confirm: plan.Confirm})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err = api.Unmarshal(resp.Body)
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -205,13 +769,13 @@ remotely.`,
 			case http.StatusOK:
 				fmt.Printf("(Status code %d) All users removed.\n", resp.StatusCode)
 			case http.StatusUnauthorized:
-				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+				fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, b.Error())
 			default:
-				fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
-					resp.StatusCode, body)
+				printutil.FatalResponse(b)
 			}
 		},
 	}
+	reset.Flags().Bool(flagDryRun, false, "only list the users that would be removed")
 	root.AddCommand(reset)
 }
 
@@ -219,24 +783,51 @@ func (root *UserCmd) attachListCmd() {
 	var list = &cobra.Command{
 		Use:   "ls",
 		Short: "List all users registered on your remote.",
-		Long:  `Lists all users registered in Inertia's user database.`,
+		Long: `Lists users registered in Inertia's user database, sorted by username.
+
+Use --limit and --offset to page through users, and --admin to only list
+administrators (or, with --admin=false, only non-administrators).`,
 		Run: func(cmd *cobra.Command, args []string) {
-			resp, err := root.host.client.ListUsers()
+			var req api.UserListRequest
+			req.Limit, _ = cmd.Flags().GetInt("limit")
+			req.Offset, _ = cmd.Flags().GetInt("offset")
+			if cmd.Flags().Changed("admin") {
+				var admin, _ = cmd.Flags().GetBool("admin")
+				req.Admin = &admin
+			}
+			resp, err := root.host.client.ListUsers(req)
 			if err != nil {
 				printutil.Fatal(err)
 			}
 			defer resp.Body.Close()
 
 			var users = make([]string, 0)
-			b, err := api.Unmarshal(resp.Body, api.KV{Key: "users", Value: &users})
+			var details = make([]api.UserInfo, 0)
+			var total int
+			b, err := api.Unmarshal(resp.Body,
+				api.KV{Key: "users", Value: &users},
+				api.KV{Key: "details", Value: &details},
+				api.KV{Key: "total", Value: &total})
 			if err != nil {
 				printutil.Fatal(err)
 			}
 
 			switch resp.StatusCode {
 			case http.StatusOK:
-				fmt.Printf("(Status code %d) %s:\n%s", resp.StatusCode, b.Message,
-					strings.Join(users, "\n"))
+				// Older daemons do not provide details
+				var lines = users
+				if len(details) == len(users) {
+					lines = make([]string, len(details))
+					for i, u := range details {
+						var lastLogin = "never"
+						if u.LastLogin != nil {
+							lastLogin = u.LastLogin.Local().Format(time.RFC1123)
+						}
+						lines[i] = fmt.Sprintf("%s (%s, last login: %s)", u.Username, u.Role, lastLogin)
+					}
+				}
+				fmt.Printf("(Status code %d) %s (%d of %d):\n%s", resp.StatusCode, b.Message,
+					len(users), total, strings.Join(lines, "\n"))
 			case http.StatusUnauthorized:
 				fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, b.Error())
 			default:
@@ -245,5 +836,8 @@ func (root *UserCmd) attachListCmd() {
 			}
 		},
 	}
+	list.Flags().Int("limit", 0, "maximum number of users to list")
+	list.Flags().Int("offset", 0, "number of users to skip")
+	list.Flags().Bool("admin", false, "only list administrators")
 	root.AddCommand(list)
 }
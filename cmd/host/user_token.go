@@ -0,0 +1,150 @@
+package hostcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/cmd/printutil"
+)
+
+// UserTokenCmd is the parent class for the 'user token' subcommands
+type UserTokenCmd struct {
+	*cobra.Command
+	host *HostCmd
+}
+
+// AttachTokenCmd attaches the 'token' subcommands to given parent
+func AttachTokenCmd(root *UserCmd) {
+	var token = &UserTokenCmd{
+		Command: &cobra.Command{
+			Use:   "token",
+			Short: "Manage read-only API tokens",
+			Long: `Manage read-only API tokens on your Inertia daemon.
+
+Read-only tokens can be used to check on your deployment - for example from a
+status dashboard - but cannot deploy or administer your remote.`,
+		},
+		host: root.host,
+	}
+
+	// attach children
+	token.attachAddCmd()
+	token.attachListCmd()
+	token.attachRevokeCmd()
+
+	// attach to parent
+	root.AddCommand(token.Command)
+}
+
+func (root *UserTokenCmd) attachAddCmd() {
+	var add = &cobra.Command{
+		Use:   "add [name]",
+		Short: "Create a read-only API token",
+		Long: `Creates a read-only API token with the given name.
+
+The token can be used to view the deployment's status, logs, and history, but
+not to deploy or manage users. It does not expire - use 'revoke' to disable it.
+
+The daemon only keeps a hash of the token, so it is only shown once.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.AddReadOnlyToken(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var id, token string
+			b, err := api.Unmarshal(resp.Body,
+				api.KV{Key: "id", Value: &id},
+				api.KV{Key: "token", Value: &token})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch resp.StatusCode {
+			case http.StatusCreated:
+				fmt.Printf("(Status code %d) %s (ID %s):\n%s\n", resp.StatusCode, b.Message, id, token)
+				fmt.Println("Store this token somewhere safe - it will not be shown again.")
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, b.Error())
+			default:
+				fmt.Printf("(Status code %d) Unknown response from daemon: %s\n",
+					resp.StatusCode, b.Error())
+			}
+		},
+	}
+	root.AddCommand(add)
+}
+
+func (root *UserTokenCmd) attachListCmd() {
+	var list = &cobra.Command{
+		Use:   "ls",
+		Short: "List read-only API tokens",
+		Long:  `Lists all read-only API tokens that have not been revoked.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.ListReadOnlyTokens()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var tokens = make([]api.ReadOnlyToken, 0)
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "tokens", Value: &tokens})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				fmt.Printf("(Status code %d) %s:\n", resp.StatusCode, b.Message)
+				for _, t := range tokens {
+					fmt.Printf(" - %s (ID %s, created %s, hash %s)\n",
+						t.Name, t.ID, t.CreatedAt.Format(time.RFC822), t.Hash)
+				}
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth: %s\n", resp.StatusCode, b.Error())
+			default:
+				fmt.Printf("(Status code %d) Unknown response from daemon: %s\n",
+					resp.StatusCode, b.Error())
+			}
+		},
+	}
+	root.AddCommand(list)
+}
+
+func (root *UserTokenCmd) attachRevokeCmd() {
+	var revoke = &cobra.Command{
+		Use:   "revoke [id]",
+		Short: "Revoke a read-only API token",
+		Long:  `Revokes the read-only API token with the given ID, as listed by 'ls'.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := root.host.client.RevokeReadOnlyToken(args[0])
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				printutil.Fatal(err)
+			}
+
+			switch resp.StatusCode {
+			case http.StatusOK:
+				fmt.Printf("(Status code %d) Token revoked.\n", resp.StatusCode)
+			case http.StatusUnauthorized:
+				fmt.Printf("(Status code %d) Bad auth:\n%s\n", resp.StatusCode, body)
+			default:
+				fmt.Printf("(Status code %d) Unknown response from daemon:\n%s\n",
+					resp.StatusCode, body)
+			}
+		},
+	}
+	root.AddCommand(revoke)
+}
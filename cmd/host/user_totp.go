@@ -53,8 +53,8 @@ func (root *UserTotpCmd) attachEnableCmd() {
 				printutil.Fatal(err)
 			}
 
-			// Endpoint handles user authentication before enabling Totp
-			resp, err := root.host.client.EnableTotp(username, string(pwBytes))
+			// Endpoint handles user authentication before enrolling in Totp
+			resp, err := root.host.client.EnrollTotp(username, string(pwBytes))
 			if err != nil {
 				printutil.Fatal(err)
 			}
@@ -65,21 +65,39 @@ func (root *UserTotpCmd) attachEnableCmd() {
 			defer resp.Body.Close()
 
 			var totpInfo api.TotpResponse
-			b, err := api.Unmarshal(resp.Body, api.KV{Key: "totp", Value: &totpInfo})
-			if err != nil {
+			if _, err := api.Unmarshal(resp.Body, api.KV{Key: "totp", Value: &totpInfo}); err != nil {
 				printutil.Fatal(err)
 			}
 
 			// Display QR code so users can easily add their keys to their
 			// authenticator apps
-			qr.New().Get(fmt.Sprintf("otpauth://totp/%s?secret=%s&issuer=Inertia",
-				username, totpInfo.TotpSecret)).Print()
-
-			fmt.Printf("\n\n(Status code %d) %s\n",
-				resp.StatusCode, b.Message)
-			fmt.Print("Scan the QR code above to " +
+			qr.New().Get(totpInfo.URI).Print()
+			fmt.Print("\n\nScan the QR code above to " +
 				"add your Inertia account to your authenticator app.\n\n")
-			fmt.Printf("Your secret key is: %s\n", totpInfo.TotpSecret)
+			fmt.Printf("Your secret key is: %s\n\n", totpInfo.TotpSecret)
+
+			// Totp is only enabled once the user proves they have set up
+			// their authenticator app
+			fmt.Print("Authentication code: ")
+			totpBytes, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Println()
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			resp, err = root.host.client.ConfirmTotp(username, string(pwBytes), string(totpBytes))
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, err := api.Unmarshal(resp.Body, api.KV{Key: "totp", Value: &totpInfo})
+			if err != nil {
+				printutil.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				printutil.FatalResponse(b)
+			}
+
+			fmt.Printf("(Status code %d) %s\n", resp.StatusCode, b.Message)
 			fmt.Print("Your backup codes are:\n\n")
 
 			for _, backupCode := range totpInfo.BackupCodes {
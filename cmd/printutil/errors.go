@@ -0,0 +1,129 @@
+package printutil
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+// Exit codes used by the CLI when the daemon responds with an error
+const (
+	ExitGeneric          = 1
+	ExitInvalidRequest   = 2
+	ExitAuth             = 3
+	ExitNoDeployment     = 4
+	ExitDeployInProgress = 5
+	ExitDeployFailed     = 6
+)
+
+// ErrorHint returns a human-friendly suggestion for the given daemon error
+// code, or an empty string if there is nothing to add
+func ErrorHint(code string) string {
+	switch code {
+	case api.ErrCodeRateLimited:
+		return "Too many requests have been made to this remote - wait a moment and try again."
+	case api.ErrCodeShuttingDown:
+		return "The daemon is shutting down - wait for it to restart and try again."
+	case api.ErrCodeTimeout:
+		return "The daemon took too long to respond - it may be busy, so try again in a moment."
+	case api.ErrCodeNotSupported:
+		return "This remote's daemon is not configured to support this request."
+	case api.ErrCodeInvalidToken:
+		return "Your token is missing, invalid, or expired - try 'inertia [remote] user login'."
+	case api.ErrCodeRevokedToken:
+		return "This token has been revoked - request a new one from an admin."
+	case api.ErrCodeExpiredToken:
+		return "Your session has expired - log in again with 'inertia [remote] user login'."
+	case api.ErrCodeMalformedToken:
+		return "Your token could not be read - log in again with 'inertia [remote] user login'."
+	case api.ErrCodeInvalidSignature:
+		return "Your token was not issued by this remote's daemon - check that the remote's address is correct, or that its keys have not changed."
+	case api.ErrCodeUserNotFound:
+		return "The user this token was issued to no longer exists - ask an admin to add you again."
+	case api.ErrCodeInvalidCredentials:
+		return "The username, password, or TOTP provided is incorrect."
+	case api.ErrCodeLockedOut:
+		return "Too many failed logins for this user - wait a while and try again."
+	case api.ErrCodeTotpRequired:
+		return "This user has 2FA enabled - provide a TOTP or backup code."
+	case api.ErrCodeAddressDenied:
+		return "Your address is not allowed to access this remote - ask an admin to allow it."
+	case api.ErrCodePasswordResetRequired:
+		return "An admin requires you to change your password - log in again to set a new one."
+	case api.ErrCodeInvalidResetToken:
+		return "This reset token is incorrect, used, or expired - ask an admin for a new one."
+	case api.ErrCodeUserExists:
+		return "A user with this name already exists - pick another name or remove the existing user first."
+	case api.ErrCodeInvalidRole:
+		return "Roles must be one of 'admin', 'user', or 'viewer'."
+	case api.ErrCodePasswordPolicy:
+		return "The password does not meet this remote's password policy."
+	case api.ErrCodeUsernamePolicy:
+		return "Usernames must be 3 to 64 letters, digits, '_', or '-', and not a reserved name like 'admin'."
+	case api.ErrCodeLastAdmin:
+		return "Promote another user to admin before demoting this one."
+	case api.ErrCodeRevisionRequired:
+		return "Your CLI may be out of date - upgrade it and try again."
+	case api.ErrCodeForbidden:
+		return "You do not have permission to do this - ask an admin for access."
+	case api.ErrCodeNoDeployment:
+		return "No project is deployed yet - try 'inertia [remote] up'."
+	case api.ErrCodeDeployInProgress:
+		return "Another deployment is in progress - wait for it to finish and try again."
+	case api.ErrCodeRefNotAllowed:
+		return "This branch is not allowed to be deployed to this remote - check 'allowed-refs' in your inertia.toml."
+	case api.ErrCodeBuildFailed:
+		return "Your project failed to build - check the output above or 'inertia [remote] logs'."
+	case api.ErrCodeStartFailed:
+		return "Your project built, but failed to start - check 'inertia [remote] logs'."
+	case api.ErrCodeNoRollbackTarget:
+		return "There is no earlier deployment to roll back to - check 'inertia [remote] history'."
+	case api.ErrCodeInvalidConfig:
+		return "Your project configuration is invalid - check your inertia.toml."
+	default:
+		return ""
+	}
+}
+
+// ExitCode returns the exit status the CLI should use for the given daemon
+// error code
+func ExitCode(code string) int {
+	switch code {
+	case api.ErrCodeInvalidRequest, api.ErrCodeInvalidConfig, api.ErrCodeRefNotAllowed,
+		api.ErrCodeUserExists, api.ErrCodeInvalidRole, api.ErrCodePasswordPolicy,
+		api.ErrCodeUsernamePolicy, api.ErrCodeRevisionRequired:
+		return ExitInvalidRequest
+	case api.ErrCodeInvalidToken, api.ErrCodeRevokedToken, api.ErrCodeExpiredToken,
+		api.ErrCodeMalformedToken, api.ErrCodeInvalidSignature, api.ErrCodeUserNotFound,
+		api.ErrCodeInvalidCredentials, api.ErrCodeLockedOut, api.ErrCodeTotpRequired,
+		api.ErrCodeInvalidCSRF, api.ErrCodePasswordResetRequired, api.ErrCodeAddressDenied,
+		api.ErrCodeInvalidResetToken, api.ErrCodeForbidden:
+		return ExitAuth
+	case api.ErrCodeNoDeployment:
+		return ExitNoDeployment
+	case api.ErrCodeDeployInProgress:
+		return ExitDeployInProgress
+	case api.ErrCodeBuildFailed, api.ErrCodeStartFailed:
+		return ExitDeployFailed
+	default:
+		return ExitGeneric
+	}
+}
+
+// FormatErrorResponse renders an error response from the daemon, including
+// a hint based on the response's error code when one is available
+func FormatErrorResponse(b *api.BaseResponse) string {
+	var out = fmt.Sprintf("(Status code %d) %s\n", b.HTTPStatusCode, b.Error())
+	if hint := ErrorHint(b.ErrCode); hint != "" {
+		out += hint + "\n"
+	}
+	return out
+}
+
+// FatalResponse prints an error response from the daemon and exits with a
+// status corresponding to the response's error code
+func FatalResponse(b *api.BaseResponse) {
+	fmt.Print(FormatErrorResponse(b))
+	os.Exit(ExitCode(b.ErrCode))
+}
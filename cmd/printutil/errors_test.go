@@ -0,0 +1,37 @@
+package printutil
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitAuth, ExitCode(api.ErrCodeInvalidToken))
+	assert.Equal(t, ExitAuth, ExitCode(api.ErrCodeTotpRequired))
+	assert.Equal(t, ExitInvalidRequest, ExitCode(api.ErrCodeInvalidConfig))
+	assert.Equal(t, ExitInvalidRequest, ExitCode(api.ErrCodeUserExists))
+	assert.Equal(t, ExitDeployInProgress, ExitCode(api.ErrCodeDeployInProgress))
+	assert.Equal(t, ExitDeployFailed, ExitCode(api.ErrCodeBuildFailed))
+	assert.Equal(t, ExitGeneric, ExitCode("some.future_code"))
+	assert.Equal(t, ExitGeneric, ExitCode(""))
+}
+
+func TestFormatErrorResponse(t *testing.T) {
+	out := FormatErrorResponse(&api.BaseResponse{
+		HTTPStatusCode: http.StatusConflict,
+		Message:        "a deployment is already in progress",
+		ErrCode:        api.ErrCodeDeployInProgress,
+	})
+	assert.Contains(t, out, "(Status code 409)")
+	assert.Contains(t, out, "a deployment is already in progress")
+	assert.Contains(t, out, "wait for it to finish")
+
+	out = FormatErrorResponse(&api.BaseResponse{
+		HTTPStatusCode: http.StatusTeapot,
+		Message:        "short and stout",
+	})
+	assert.Equal(t, "(Status code 418) [error 418] short and stout\n", out)
+}
@@ -2,6 +2,9 @@ package printutil
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/cfg"
@@ -11,6 +14,11 @@ const (
 	msgBuildInProgress    = "It appears that your build is still in progress."
 	msgNoContainersActive = "No containers are active."
 	msgNoDeployment       = "No deployment found - try running 'inertia [remote] up'"
+	msgCrashLooping       = "Crash-looping containers are not restarted - fix them, then run 'inertia [remote] resume'\n"
+
+	// stateCrashLooping is the health state of containers that are no longer
+	// restarted automatically
+	stateCrashLooping = "crash-looping"
 )
 
 // FormatStatus prints the given deployment status
@@ -26,6 +34,9 @@ func FormatStatus(s *api.DeploymentStatus) string {
 	// If no branch/commit, then it's likely the deployment has not
 	// been instantiated on the remote yet
 	var statusString = inertiaStatus + branchStatus + commitStatus + commitMessage + buildTypeStatus
+	if s.DeployMessage != "" && s.DeployMessage != s.CommitMessage {
+		statusString += " - Deploy:     " + s.DeployMessage + "\n"
+	}
 	if s.Branch == "" && s.CommitHash == "" && s.CommitMessage == "" {
 		return statusString + msgNoDeployment
 	}
@@ -42,10 +53,31 @@ func FormatStatus(s *api.DeploymentStatus) string {
 	}
 
 	activeContainers := "Active containers:\n"
+	crashLooping := false
 	for _, container := range s.Containers {
-		activeContainers += " - " + container + "\n"
+		if state, found := s.Health[container]; found {
+			activeContainers += " - " + container + " (" + state + ")\n"
+			crashLooping = crashLooping || state == stateCrashLooping
+		} else {
+			activeContainers += " - " + container + "\n"
+		}
 	}
 	statusString += activeContainers
+	if crashLooping {
+		statusString += msgCrashLooping
+	}
+
+	if len(s.Networks) > 0 {
+		var networks = make([]string, 0, len(s.Networks))
+		for name := range s.Networks {
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		statusString += "Shared networks:\n"
+		for _, name := range networks {
+			statusString += " - " + name + ": " + strings.Join(s.Networks[name], ", ") + "\n"
+		}
+	}
 	return statusString
 }
 
@@ -59,3 +91,167 @@ func FormatRemoteDetails(remote *cfg.RemoteVPS) string {
 	remoteString += fmt.Sprintf("Run 'inertia %s status' for more details.\n", remote.Name)
 	return remoteString
 }
+
+// FormatDeployRecord prints the given deployment record
+func FormatDeployRecord(r *api.DeployRecord) string {
+	recordString := fmt.Sprintf("Deploy %s (%s):\n", r.ID, r.Timestamp.Format(time.RFC822))
+	recordString += fmt.Sprintf(" - Branch:     %s\n", r.Branch)
+	recordString += fmt.Sprintf(" - Commit:     %s\n", r.CommitHash)
+	recordString += fmt.Sprintf(" - Message:    %s\n", r.CommitMessage)
+	recordString += fmt.Sprintf(" - Build Type: %s\n", r.BuildType)
+	if r.Message != "" && r.Message != r.CommitMessage {
+		recordString += fmt.Sprintf(" - Deploy:     %s\n", r.Message)
+	}
+	if r.PromotedFrom != nil {
+		recordString += fmt.Sprintf(" - Promoted:   from deploy %s on %s\n",
+			r.PromotedFrom.DeployID, r.PromotedFrom.Remote)
+	}
+	if r.Prewarmed {
+		recordString += " - Build:      pre-warmed\n"
+	}
+	var flags = make([]string, 0, len(r.FeatureFlags))
+	for name, value := range r.FeatureFlags {
+		flags = append(flags, name+"="+value)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		recordString += fmt.Sprintf(" - Flag:       %s\n", flag)
+	}
+	for _, image := range r.Images {
+		recordString += fmt.Sprintf(" - Image:      %s (%s)\n", image.Name, image.ID)
+	}
+	return recordString
+}
+
+// FormatDeployDiff prints the given comparison of two deployments
+func FormatDeployDiff(d *api.DeployDiff) string {
+	diffString := fmt.Sprintf("Deploy %s (%s) -> Deploy %s (%s)\n",
+		d.From.ID, d.From.CommitHash, d.To.ID, d.To.CommitHash)
+	diffString += formatChanges("Environment", d.Environment)
+	diffString += formatChanges("Images", d.Images)
+	diffString += formatChanges("Feature Flags", d.FeatureFlags)
+	if d.GitDiffError != "" {
+		diffString += fmt.Sprintf("Commits: unavailable (%s)\n", d.GitDiffError)
+	} else if d.GitDiff == "" {
+		diffString += "Commits: no changes\n"
+	} else {
+		diffString += "Commits:\n" + d.GitDiff
+	}
+	return diffString
+}
+
+func formatChanges(title string, changes []api.Change) string {
+	if len(changes) == 0 {
+		return fmt.Sprintf("%s: no changes\n", title)
+	}
+	changeString := fmt.Sprintf("%s:\n", title)
+	for _, c := range changes {
+		switch {
+		case c.From == "":
+			changeString += fmt.Sprintf(" + %s (%s)\n", c.Name, c.To)
+		case c.To == "":
+			changeString += fmt.Sprintf(" - %s (%s)\n", c.Name, c.From)
+		default:
+			changeString += fmt.Sprintf(" ~ %s (%s -> %s)\n", c.Name, c.From, c.To)
+		}
+	}
+	return changeString
+}
+
+// FormatFailedNotification prints the given failed notification
+func FormatFailedNotification(f *api.FailedNotification) string {
+	failedString := fmt.Sprintf("Failed to notify %s (%s):\n",
+		f.Channel, f.FailedAt.Format(time.RFC822))
+	failedString += fmt.Sprintf(" - Attempts: %d\n", f.Attempts)
+	failedString += fmt.Sprintf(" - Error:    %s\n", f.Error)
+	for _, e := range f.Events {
+		failedString += fmt.Sprintf(" - Event:    %s\n", e)
+	}
+	return failedString
+}
+
+// FormatDeployQueue prints the given webhook deploy queue
+func FormatDeployQueue(q *api.DeployQueue) string {
+	var queueString string
+	if q.Running == nil && len(q.Pending) == 0 {
+		queueString = "No webhook deployments in progress\n"
+	}
+	if q.Running != nil {
+		queueString += "Running:\n" + formatQueuedDeploy(q.Running)
+	}
+	if len(q.Pending) > 0 {
+		queueString += "Queued:\n"
+		for _, d := range q.Pending {
+			queueString += formatQueuedDeploy(&d)
+		}
+	}
+	if len(q.Skipped) > 0 {
+		queueString += "Skipped:\n"
+		for _, d := range q.Skipped {
+			queueString += formatQueuedDeploy(&d)
+		}
+	}
+	return queueString
+}
+
+func formatQueuedDeploy(d *api.QueuedDeploy) string {
+	return fmt.Sprintf(" - %s (%s push at %s): %s\n",
+		d.Branch, d.Source, d.ReceivedAt.Format(time.RFC822), d.Status)
+}
+
+// FormatWebhookConfig prints the given webhook configuration
+func FormatWebhookConfig(c *api.WebhookConfig) string {
+	var configString = "Webhook URLs:\n"
+	for _, u := range c.URLs {
+		configString += " - " + u + "\n"
+	}
+	if c.Branch != "" && c.WarmStandby {
+		configString += fmt.Sprintf("Pre-warms branch %s of project %s on push (warm standby)\n", c.Branch, c.Project)
+	} else if c.Branch != "" {
+		configString += fmt.Sprintf("Deploys branch %s of project %s on push\n", c.Branch, c.Project)
+	}
+	if len(c.AllowedRefs) > 0 {
+		configString += "Deployable refs: " + strings.Join(c.AllowedRefs, ", ") + "\n"
+	}
+	if !c.SecretSet {
+		configString += "[WARNING] No webhook secret is set - run 'inertia [remote] up' to set one\n"
+	}
+	configString += "Supported providers:\n"
+	for _, p := range c.Providers {
+		configString += fmt.Sprintf(" - %s: send '%s' events as %s\n",
+			p.Name, p.PushEvent, strings.Join(p.ContentTypes, " or "))
+		configString += fmt.Sprintf("     %s header: %s\n", p.SignatureHeader, p.Signature)
+	}
+	return configString
+}
+
+// FormatWebhookTestResult prints the given webhook test result
+func FormatWebhookTestResult(r *api.WebhookTestResult) string {
+	var resultString = fmt.Sprintf("Received %s '%s' event\n", r.Provider, r.Event)
+	if r.Error != "" {
+		return resultString + "[FAILED] " + r.Error + "\n"
+	}
+	resultString += "[OK] signature verified\n[OK] payload parsed\n"
+	if r.Repository != "" {
+		resultString += fmt.Sprintf("Repository: %s (%s)\nBranch:     %s\n",
+			r.Repository, r.SSHURL, r.Branch)
+	}
+	if r.WouldDeploy {
+		return resultString + "This event would trigger a deployment\n"
+	}
+	return resultString + "This event would not trigger a deployment: " + r.Reason + "\n"
+}
+
+// FormatStats prints the given daemon stats
+func FormatStats(s *api.DaemonStats) string {
+	statsString := fmt.Sprintf("inertia daemon %s (up %s)\n", s.InertiaVersion, s.Uptime)
+	var names = make([]string, 0, len(s.Counters))
+	for name := range s.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		statsString += fmt.Sprintf(" - %s: %d\n", name, s.Counters[name])
+	}
+	return statsString
+}
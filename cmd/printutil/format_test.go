@@ -1,6 +1,7 @@
 package printutil
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,6 +22,66 @@ func TestFormatStatus(t *testing.T) {
 	assert.Contains(t, output, "Active containers")
 }
 
+func TestFormatStatusHealth(t *testing.T) {
+	output := FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		Containers:     []string{"/web", "/db"},
+		Health:         map[string]string{"/web": "starting"},
+	})
+	assert.Contains(t, output, "/web (starting)")
+	assert.Contains(t, output, " - /db\n")
+	assert.NotContains(t, output, "resume")
+
+	output = FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		Containers:     []string{"/web"},
+		Health:         map[string]string{"/web": "crash-looping"},
+	})
+	assert.Contains(t, output, "/web (crash-looping)")
+	assert.Contains(t, output, "inertia [remote] resume")
+}
+
+func TestFormatStatusNetworks(t *testing.T) {
+	output := FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		Containers:     []string{"/web", "/db"},
+		Networks:       map[string][]string{"shared": {"/db", "/other-stack"}},
+	})
+	assert.Contains(t, output, "Shared networks:\n - shared: /db, /other-stack\n")
+}
+
+func TestFormatStatusDeployMessage(t *testing.T) {
+	output := FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		DeployMessage:  "hotfix for login bug",
+		Containers:     []string{"/web"},
+	})
+	assert.Contains(t, output, " - Deploy:     hotfix for login bug\n")
+
+	// deploy message should not be repeated if it is the commit message
+	output = FormatStatus(&api.DeploymentStatus{
+		InertiaVersion: "9000",
+		Branch:         "call",
+		CommitHash:     "me",
+		CommitMessage:  "maybe",
+		DeployMessage:  "maybe",
+		Containers:     []string{"/web"},
+	})
+	assert.NotContains(t, output, "Deploy:")
+}
+
 func TestFormatStatusBuildActive(t *testing.T) {
 	output := FormatStatus(&api.DeploymentStatus{
 		InertiaVersion:       "9000",
@@ -60,3 +121,140 @@ func TestFormatRemoteDetails(t *testing.T) {
 	assert.Contains(t, output, "tree")
 	assert.Contains(t, output, "/wow/amaze")
 }
+
+func TestFormatDeployRecord(t *testing.T) {
+	output := FormatDeployRecord(&api.DeployRecord{
+		ID:           "3",
+		Branch:       "master",
+		CommitHash:   "abcde",
+		Message:      "hotfix for login bug",
+		Images:       []api.DeployImage{{Name: "inertia-build/project", ID: "sha256:1234"}},
+		FeatureFlags: map[string]string{"new_login": "true"},
+	})
+	assert.Contains(t, output, "hotfix for login bug")
+	assert.Contains(t, output, "Flag:       new_login=true")
+	assert.Contains(t, output, "Deploy 3")
+	assert.Contains(t, output, "abcde")
+	assert.Contains(t, output, "inertia-build/project (sha256:1234)")
+	assert.NotContains(t, output, "Promoted")
+
+	output = FormatDeployRecord(&api.DeployRecord{
+		ID:           "1",
+		PromotedFrom: &api.Provenance{Remote: "staging", DeployID: "3"},
+	})
+	assert.Contains(t, output, "from deploy 3 on staging")
+	assert.NotContains(t, output, "pre-warmed")
+
+	output = FormatDeployRecord(&api.DeployRecord{ID: "4", Prewarmed: true})
+	assert.Contains(t, output, "Build:      pre-warmed")
+}
+
+func TestFormatDeployDiff(t *testing.T) {
+	output := FormatDeployDiff(&api.DeployDiff{
+		From:        api.DeployRecord{ID: "2", CommitHash: "abcde"},
+		To:          api.DeployRecord{ID: "3", CommitHash: "fghij"},
+		Environment: []api.Change{{Name: "DEBUG", To: "1234"}, {Name: "PORT", From: "5678"}},
+		Images:      []api.Change{{Name: "web", From: "sha256:1234", To: "sha256:5678"}},
+		GitDiff:     "+ wow\n",
+	})
+	assert.Contains(t, output, "Deploy 2 (abcde) -> Deploy 3 (fghij)")
+	assert.Contains(t, output, " + DEBUG (1234)")
+	assert.Contains(t, output, " - PORT (5678)")
+	assert.Contains(t, output, " ~ web (sha256:1234 -> sha256:5678)")
+	assert.Contains(t, output, "Feature Flags: no changes")
+	assert.Contains(t, output, "+ wow")
+
+	output = FormatDeployDiff(&api.DeployDiff{GitDiffError: "commit abcde is not available"})
+	assert.Contains(t, output, "Commits: unavailable (commit abcde is not available)")
+}
+
+func TestFormatFailedNotification(t *testing.T) {
+	output := FormatFailedNotification(&api.FailedNotification{
+		Channel:  "slack https://hooks.slack.com",
+		Attempts: 3,
+		Error:    "notification rejected with status 500",
+		Events:   []string{"deployed branch master"},
+	})
+	assert.Contains(t, output, "Failed to notify slack https://hooks.slack.com")
+	assert.Contains(t, output, "Attempts: 3")
+	assert.Contains(t, output, "status 500")
+	assert.Contains(t, output, "Event:    deployed branch master")
+}
+
+func TestFormatDeployQueue(t *testing.T) {
+	assert.Contains(t, FormatDeployQueue(&api.DeployQueue{}), "No webhook deployments")
+
+	output := FormatDeployQueue(&api.DeployQueue{
+		Running: &api.QueuedDeploy{Branch: "master", Source: "github", Status: api.QueuedDeployRunning},
+		Pending: []api.QueuedDeploy{{Branch: "master", Source: "github", Status: api.QueuedDeployPending}},
+		Skipped: []api.QueuedDeploy{{Branch: "master", Source: "github", Status: api.QueuedDeploySuperseded}},
+	})
+	assert.Contains(t, output, "Running:\n - master (github push")
+	assert.Contains(t, output, "Queued:")
+	assert.Contains(t, output, "skipped (superseded)")
+	assert.NotContains(t, output, "No webhook deployments")
+}
+
+func TestFormatWebhookConfig(t *testing.T) {
+	output := FormatWebhookConfig(&api.WebhookConfig{
+		URLs:        []string{"https://0.0.0.0:4303/webhook"},
+		Project:     "inertia",
+		Branch:      "master",
+		AllowedRefs: []string{"master", "refs/tags/*"},
+		Providers: []api.WebhookProvider{{
+			Name:            "github",
+			PushEvent:       "push",
+			SignatureHeader: "X-Hub-Signature",
+			ContentTypes:    []string{"application/json"},
+		}},
+	})
+	assert.Contains(t, output, " - https://0.0.0.0:4303/webhook")
+	assert.Contains(t, output, "branch master of project inertia")
+	assert.Contains(t, output, "No webhook secret")
+	assert.Contains(t, output, "Deployable refs: master, refs/tags/*")
+	assert.Contains(t, output, "github: send 'push' events as application/json")
+
+	output = FormatWebhookConfig(&api.WebhookConfig{
+		Project:     "inertia",
+		Branch:      "master",
+		WarmStandby: true,
+	})
+	assert.Contains(t, output, "Pre-warms branch master of project inertia")
+}
+
+func TestFormatWebhookTestResult(t *testing.T) {
+	output := FormatWebhookTestResult(&api.WebhookTestResult{
+		Provider: "github",
+		Event:    "push",
+		Error:    "unable to verify payload: payload signature check failed",
+	})
+	assert.Contains(t, output, "[FAILED] unable to verify payload")
+	assert.NotContains(t, output, "[OK]")
+
+	output = FormatWebhookTestResult(&api.WebhookTestResult{
+		Provider: "github", Event: "push", Verified: true, Parsed: true,
+		Repository: "inertia", Branch: "dev",
+		Reason: "event branch dev does not match deployed branch master",
+	})
+	assert.Contains(t, output, "[OK] signature verified")
+	assert.Contains(t, output, "would not trigger a deployment: event branch dev")
+
+	output = FormatWebhookTestResult(&api.WebhookTestResult{
+		Provider: "github", Event: "push", Verified: true, Parsed: true, WouldDeploy: true,
+	})
+	assert.Contains(t, output, "would trigger a deployment")
+}
+
+func TestFormatStats(t *testing.T) {
+	output := FormatStats(&api.DaemonStats{
+		InertiaVersion: "v0.1.0",
+		Uptime:         "1h0m0s",
+		Counters: map[string]uint64{
+			"inertia_requests_total":    12,
+			"inertia_deployments_total": 3,
+		},
+	})
+	assert.Contains(t, output, "inertia daemon v0.1.0 (up 1h0m0s)")
+	assert.Contains(t, output, "inertia_requests_total: 12")
+	assert.True(t, strings.Index(output, "deployments") < strings.Index(output, "requests"))
+}
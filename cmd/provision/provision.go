@@ -25,6 +25,10 @@ type ProvisionCmd struct {
 const (
 	flagDaemonPort = "daemon.port"
 	flagPorts      = "ports"
+
+	flagTimeoutRequest = "timeout.request"
+	flagTimeoutStartup = "timeout.startup"
+	flagTimeoutSSH     = "timeout.ssh"
 )
 
 // AttachProvisionCmd attaches the 'provision' subcommands to the given parent
@@ -37,7 +41,7 @@ func AttachProvisionCmd(inertia *inertiacmd.Cmd) {
 		PersistentPreRun: func(*cobra.Command, []string) {
 			// Ensure project initialized, load config
 			var err error
-			prov.config, prov.cfgPath, err = local.GetProjectConfigFromDisk(inertia.ConfigPath)
+			prov.config, prov.cfgPath, err = local.GetProjectConfigFromDisk(inertia.ProjectConfigPath())
 			if err != nil {
 				printutil.Fatalf("failed to read config at '%s': %s", prov.cfgPath, err.Error())
 			}
@@ -48,6 +52,12 @@ func AttachProvisionCmd(inertia *inertiacmd.Cmd) {
 	}
 	prov.PersistentFlags().StringP(flagDaemonPort, "d", "4303", "daemon port")
 	prov.PersistentFlags().StringArrayP(flagPorts, "p", []string{}, "ports your project uses")
+	prov.PersistentFlags().Duration(flagTimeoutRequest, provision.DefaultRequestTimeout,
+		"time allowed for each request to the provider")
+	prov.PersistentFlags().Duration(flagTimeoutStartup, provision.DefaultStartupTimeout,
+		"time allowed for the new host to start running")
+	prov.PersistentFlags().Duration(flagTimeoutSSH, provision.DefaultSSHTimeout,
+		"time allowed for the new host to accept SSH connections")
 
 	// add children
 	prov.attachEcsCmd()
@@ -163,6 +173,9 @@ This ensures that your project ports are properly exposed and externally accessi
 			// Create remote instance
 			var port, _ = cmd.Flags().GetString(flagDaemonPort)
 			var portDaemon, _ = common.ParseInt64(port)
+			var timeoutRequest, _ = cmd.Flags().GetDuration(flagTimeoutRequest)
+			var timeoutStartup, _ = cmd.Flags().GetDuration(flagTimeoutStartup)
+			var timeoutSSH, _ = cmd.Flags().GetDuration(flagTimeoutSSH)
 			remote, err := prov.CreateInstance(provision.EC2CreateInstanceOptions{
 				Name:        args[0],
 				ProjectName: config.Project,
@@ -172,6 +185,12 @@ This ensures that your project ports are properly exposed and externally accessi
 				ImageID:      image,
 				InstanceType: instanceType,
 				Region:       region,
+
+				Timeouts: provision.Timeouts{
+					Request: timeoutRequest,
+					Startup: timeoutStartup,
+					SSH:     timeoutSSH,
+				},
 			})
 			if err != nil {
 				printutil.Fatal(err)
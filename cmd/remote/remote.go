@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/cmd/inpututil"
 	"github.com/ubclaunchpad/inertia/cmd/printutil"
@@ -42,7 +43,7 @@ inertia gcloud status      # check on status of Inertia daemon
 		PersistentPreRun: func(*cobra.Command, []string) {
 			// Ensure project initialized, load config
 			var err error
-			remote.config, remote.cfgPath, err = local.GetProjectConfigFromDisk(inertia.ConfigPath)
+			remote.config, remote.cfgPath, err = local.GetProjectConfigFromDisk(inertia.ProjectConfigPath())
 			if err != nil {
 				printutil.Fatalf("failed to read config at '%s': %s", remote.cfgPath, err.Error())
 			}
@@ -108,12 +109,26 @@ Inertia commands.`,
 }
 
 func (root *RemoteCmd) attachListCmd() {
-	const flagVerbose = "verbose"
+	const (
+		flagVerbose = "verbose"
+		flagStatus  = "status"
+		flagTimeout = "timeout"
+	)
 	var list = &cobra.Command{
 		Use:   "ls",
 		Short: "List currently configured remotes",
-		Long:  `Lists all currently configured remotes.`,
+		Long: `Lists all currently configured remotes.
+
+Use the --status flag to check on each remote's daemon and currently deployed
+commit. Remotes are checked concurrently, and remotes that do not respond within
+the given timeout are reported as unreachable.`,
 		Run: func(cmd *cobra.Command, args []string) {
+			if status, _ := cmd.Flags().GetBool(flagStatus); status {
+				var timeout, _ = cmd.Flags().GetDuration(flagTimeout)
+				fmt.Print(formatRemoteHealth(checkRemotes(root.config, timeout)))
+				return
+			}
+
 			var verbose, _ = cmd.Flags().GetBool(flagVerbose)
 			for name, remote := range root.config.Remotes {
 				if remote != nil && verbose {
@@ -125,6 +140,8 @@ func (root *RemoteCmd) attachListCmd() {
 		},
 	}
 	list.Flags().BoolP(flagVerbose, "v", false, "enable verbose output")
+	list.Flags().Bool(flagStatus, false, "check the status of each remote's daemon")
+	list.Flags().Duration(flagTimeout, 5*time.Second, "time limit for checking each remote")
 	root.AddCommand(list)
 }
 
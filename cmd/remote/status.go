@@ -0,0 +1,116 @@
+package remotecmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/cfg"
+	"github.com/ubclaunchpad/inertia/client"
+)
+
+// remoteHealth summarizes the state of a configured remote's daemon
+type remoteHealth struct {
+	Name       string
+	Reachable  bool
+	Version    string
+	CommitHash string
+	Err        error
+}
+
+// checkRemotes concurrently retrieves the status of each configured remote,
+// giving up on each remote after the given timeout. Results are sorted by
+// remote name.
+func checkRemotes(config *cfg.Config, timeout time.Duration) []remoteHealth {
+	var (
+		wg      sync.WaitGroup
+		results = make([]remoteHealth, 0, len(config.Remotes))
+		mux     sync.Mutex
+	)
+	for name := range config.Remotes {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			var health = checkRemote(config, name, timeout)
+			mux.Lock()
+			results = append(results, health)
+			mux.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results
+}
+
+func checkRemote(config *cfg.Config, name string, timeout time.Duration) remoteHealth {
+	var health = remoteHealth{Name: name}
+	cli, found := client.NewClient(name, "", config)
+	if !found || cli.Daemon == nil {
+		health.Err = fmt.Errorf("remote is not configured")
+		return health
+	}
+	cli.SetTimeout(timeout)
+
+	resp, err := cli.Status()
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	defer resp.Body.Close()
+	health.Reachable = true
+
+	var status = &api.DeploymentStatus{}
+	base, err := api.Unmarshal(resp.Body, api.KV{Key: "status", Value: status})
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	if err := base.Error(); err != nil {
+		health.Err = err
+		return health
+	}
+	health.Version = status.InertiaVersion
+	health.CommitHash = status.CommitHash
+	return health
+}
+
+// formatRemoteHealth prints the given remote states as a table
+func formatRemoteHealth(results []remoteHealth) string {
+	var (
+		buf bytes.Buffer
+		w   = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	)
+	fmt.Fprintln(w, "REMOTE\tSTATUS\tVERSION\tCOMMIT\tERROR")
+	for _, r := range results {
+		var (
+			state  = "unreachable"
+			commit = r.CommitHash
+			errMsg = ""
+		)
+		if r.Reachable {
+			state = "online"
+		}
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			r.Name, state, orDash(r.Version), orDash(commit), errMsg)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package remotecmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+func newTestRemote(name string, ts *httptest.Server) *cfg.RemoteVPS {
+	var parts = strings.Split(ts.URL, ":")
+	return &cfg.RemoteVPS{
+		Name:   name,
+		IP:     strings.Trim(parts[1], "/"),
+		Daemon: &cfg.DaemonConfig{Port: parts[2]},
+	}
+}
+
+func TestCheckRemotes(t *testing.T) {
+	online := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/status", req.URL.Path)
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte(`{"code":200,"message":"ok","data":{"status":{"version":"v0.5.0","commit_hash":"abcdef123456"}}}`))
+	}))
+	defer online.Close()
+	slow := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(time.Second)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	var config = &cfg.Config{Remotes: map[string]*cfg.RemoteVPS{
+		"prod":    newTestRemote("prod", online),
+		"staging": newTestRemote("staging", slow),
+	}}
+	var start = time.Now()
+	results := checkRemotes(config, 100*time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, "prod", results[0].Name)
+	assert.True(t, results[0].Reachable)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "v0.5.0", results[0].Version)
+	assert.Equal(t, "abcdef123456", results[0].CommitHash)
+	assert.Equal(t, "staging", results[1].Name)
+	assert.False(t, results[1].Reachable)
+	assert.NotNil(t, results[1].Err)
+}
+
+func TestFormatRemoteHealth(t *testing.T) {
+	var table = formatRemoteHealth([]remoteHealth{
+		{Name: "prod", Reachable: true, Version: "v0.5.0", CommitHash: "abcdef123456"},
+		{Name: "staging", Err: errors.New("timed out")},
+	})
+	var lines = strings.Split(strings.TrimSpace(table), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "online")
+	assert.Contains(t, lines[1], "abcdef1")
+	assert.NotContains(t, lines[1], "abcdef12")
+	assert.Contains(t, lines[2], "unreachable")
+	assert.Contains(t, lines[2], "timed out")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -10,6 +11,7 @@ import (
 	hostcmd "github.com/ubclaunchpad/inertia/cmd/host"
 	provisioncmd "github.com/ubclaunchpad/inertia/cmd/provision"
 	remotecmd "github.com/ubclaunchpad/inertia/cmd/remote"
+	"github.com/ubclaunchpad/inertia/local"
 )
 
 func getVersion(version string) string {
@@ -45,17 +47,42 @@ Issue tracker: https://github.com/ubclaunchpad/inertia/issues`,
 	}
 
 	// persistent flags across all children
+	var remote string
 	root.PersistentFlags().StringVar(&root.ConfigPath, "config", "inertia.toml", "specify relative path to Inertia configuration")
+	root.PersistentFlags().BoolVar(&root.FromEnv, "from-env", false,
+		"read configuration from environment variables instead of a configuration file")
+	root.PersistentFlags().StringVar(&remote, "remote", "",
+		"remote to target when none is given, overriding your default remote")
 	// hack in flag parsing - this must be done because we need to initialize the
 	// host commands properly when Cobra first constructs the command tree, which
 	// occurs before the built-in flag parser
 	for i, arg := range os.Args {
-		if arg == "--config" {
+		if arg == "--config" && i+1 < len(os.Args) {
 			root.ConfigPath = os.Args[i+1]
-			break
+			root.ConfigPathSet = true
+		}
+		if arg == "--from-env" {
+			root.FromEnv = true
+		}
+		if arg == "--remote" && i+1 < len(os.Args) {
+			remote = os.Args[i+1]
 		}
 	}
 
+	// load per-user preferences
+	if path, err := local.GetUserConfigPath(); err == nil {
+		if user, err := local.GetUserConfig(path); err != nil {
+			fmt.Printf("[WARNING] Failed to read user configuration: %s\n", err.Error())
+		} else {
+			// environment variables take precedence over the configuration file
+			local.ApplyUserEnvOverrides(user)
+			root.User = user
+		}
+	}
+	if remote == "" && root.User != nil {
+		remote = root.User.DefaultRemote
+	}
+
 	// attach children to root 'inertia' command
 	attachInitCmd(root)
 	configcmd.AttachConfigCmd(root)
@@ -64,5 +91,27 @@ Issue tracker: https://github.com/ubclaunchpad/inertia/issues`,
 	hostcmd.AttachHostCmds(root)
 	attachContribPlugins(root)
 
+	// target the default remote if no remote was given
+	if remote != "" {
+		applyDefaultRemote(root, remote, os.Args[1:])
+	}
+
 	return root
 }
+
+// applyDefaultRemote directs the given arguments to the given remote if they
+// do not already refer to a command
+func applyDefaultRemote(root *inertiacmd.Cmd, remote string, args []string) {
+	if len(args) > 0 && args[0] == "help" {
+		return
+	}
+	if _, _, err := root.Find(args); err == nil {
+		return
+	}
+	if cmd, _, err := root.Find([]string{remote}); err != nil || cmd == root.Command {
+		fmt.Fprintf(os.Stderr, "[WARNING] Default remote '%s' not found\n", remote)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Targeting remote '%s'\n", remote)
+	root.SetArgs(append([]string{remote}, args...))
+}
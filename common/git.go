@@ -27,8 +27,12 @@ func GetSSHRemoteURL(url string) string {
 	return sshURL
 }
 
-// GetBranchFromRef gets the branch name from a git ref of form refs/...
+// GetBranchFromRef gets the branch name from a git ref of form refs/... - refs
+// that do not start with "refs/" are treated as branch names
 func GetBranchFromRef(ref string) string {
+	if !strings.HasPrefix(ref, "refs/") {
+		return ref
+	}
 	parts := strings.Split(ref, "/")
 	return strings.Join(parts[2:], "/")
 }
@@ -43,6 +43,7 @@ func TestGetBranchFromRef(t *testing.T) {
 		{"single-part branch", args{"refs/heads/master"}, "master"},
 		{"two-part branch", args{"refs/heads/web/navbar-hide"}, "web/navbar-hide"},
 		{"three-part branch", args{"refs/heads/web/fix/navbar-hide"}, "web/fix/navbar-hide"},
+		{"branch name", args{"web/navbar-hide"}, "web/navbar-hide"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
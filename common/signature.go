@@ -0,0 +1,26 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// DeployRequestPayload returns the canonical payload that is signed for a
+// signed deploy request, in the form "[timestamp]:[project]:[ref]", followed by
+// ":[message]" if a deploy message is provided
+func DeployRequestPayload(project, ref, message string, timestamp int64) []byte {
+	if message == "" {
+		return []byte(fmt.Sprintf("%d:%s:%s", timestamp, project, ref))
+	}
+	return []byte(fmt.Sprintf("%d:%s:%s:%s", timestamp, project, ref, message))
+}
+
+// SignDeployRequest signs the given deploy parameters with key, and returns a
+// signature in the form "sha256=[hexdigest]"
+func SignDeployRequest(key, project, ref, message string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(DeployRequestPayload(project, ref, message, timestamp))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeployRequestPayload(t *testing.T) {
+	assert.Equal(t, "1234:inertia:refs/heads/master",
+		string(DeployRequestPayload("inertia", "refs/heads/master", "", 1234)))
+	assert.Equal(t, "1234:inertia:refs/heads/master:hotfix",
+		string(DeployRequestPayload("inertia", "refs/heads/master", "hotfix", 1234)))
+}
+
+func TestSignDeployRequest(t *testing.T) {
+	var sig = SignDeployRequest("sekrit", "inertia", "refs/heads/master", "", 1234)
+	assert.Contains(t, sig, "sha256=")
+	assert.Len(t, sig, len("sha256=")+64)
+
+	// signatures should be deterministic
+	assert.Equal(t, sig, SignDeployRequest("sekrit", "inertia", "refs/heads/master", "", 1234))
+
+	// any change in parameters should change the signature
+	assert.NotEqual(t, sig, SignDeployRequest("sekrit", "inertia", "refs/heads/master", "", 1235))
+	assert.NotEqual(t, sig, SignDeployRequest("sekrit", "inertia", "refs/heads/dev", "", 1234))
+	assert.NotEqual(t, sig, SignDeployRequest("sekrit", "other", "refs/heads/master", "", 1234))
+	assert.NotEqual(t, sig, SignDeployRequest("wow", "inertia", "refs/heads/master", "", 1234))
+	assert.NotEqual(t, sig, SignDeployRequest("sekrit", "inertia", "refs/heads/master", "hotfix", 1234))
+}
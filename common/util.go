@@ -17,8 +17,12 @@ func (dn DevNull) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-// GetFullPath returns the absolute path of the config file.
+// GetFullPath returns the absolute path of the config file. Paths that are
+// already absolute are returned as is.
 func GetFullPath(relPath string) (string, error) {
+	if filepath.IsAbs(relPath) {
+		return relPath, nil
+	}
 	path, err := os.Getwd()
 	if err != nil {
 		return "", err
@@ -3,6 +3,7 @@ package common
 import (
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -64,3 +65,16 @@ func TestParseInt64(t *testing.T) {
 	_, err = ParseInt64("")
 	assert.NotNil(t, err)
 }
+
+func TestGetFullPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+
+	path, err := GetFullPath("inertia.toml")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(cwd, "inertia.toml"), path)
+
+	path, err = GetFullPath("/projects/wow/inertia.toml")
+	assert.Nil(t, err)
+	assert.Equal(t, "/projects/wow/inertia.toml", path)
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Audited actions
+const (
+	AuditLogin          = "login"
+	AuditLogout         = "logout"
+	AuditLogoutAll      = "logout.all"
+	AuditAccess         = "access"
+	AuditRefresh        = "session.refresh"
+	AuditUserAdd        = "user.add"
+	AuditUserImport     = "user.import"
+	AuditUserExport     = "user.export"
+	AuditUserRestore    = "user.restore"
+	AuditUserRemove     = "user.remove"
+	AuditUserRename     = "user.rename"
+	AuditUserRevoke     = "user.revoke"
+	AuditUserSetAdmin   = "user.setadmin"
+	AuditUserReset      = "user.reset"
+	AuditUserForceReset = "user.forcereset"
+	AuditResetRequest   = "user.resetrequest"
+	AuditResetConfirm   = "user.resetconfirm"
+	AuditPasswordUpdate = "user.updatepassword"
+	AuditTotpEnable     = "totp.enable"
+	AuditTotpEnroll     = "totp.enroll"
+	AuditTotpDisable    = "totp.disable"
+	AuditTokenAdd       = "token.add"
+	AuditTokenRevoke    = "token.revoke"
+)
+
+// Results of audited actions
+const (
+	AuditResultGranted = "granted"
+	AuditResultDenied  = "denied"
+	AuditResultSuccess = "success"
+	AuditResultFailed  = "failed"
+)
+
+// auditBufferSize is the number of audit events that can be waiting to be
+// written before new events are dropped
+const auditBufferSize = 256
+
+// AuditEvent is a structured record of an authentication or authorization
+// decision, or a user management action
+type AuditEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user,omitempty"`
+	Action    string    `json:"action"`
+	Method    string    `json:"method"`
+	Endpoint  string    `json:"endpoint"`
+	Result    string    `json:"result"`
+
+	// Target is the subject of user management actions, such as the user
+	// being added or removed
+	Target string `json:"target,omitempty"`
+
+	// Reason describes why access was denied
+	Reason string `json:"reason,omitempty"`
+
+	// RequestID identifies the request the event was recorded for, and is
+	// echoed to the client in the X-Request-ID header
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// auditor writes audit events as JSON lines in the background, so that a slow
+// writer never holds up request handling. Events are dropped if too many are
+// waiting to be written, or once the auditor is closed. A nil auditor discards
+// all events.
+type auditor struct {
+	now     func() time.Time
+	events  chan AuditEvent
+	done    chan struct{}
+	dropped int64
+
+	mux    sync.RWMutex
+	closed bool
+}
+
+// newAuditor starts writing audit events to w, or returns nil if w is nil
+func newAuditor(w io.Writer) *auditor {
+	if w == nil {
+		return nil
+	}
+	var a = &auditor{
+		now:    time.Now,
+		events: make(chan AuditEvent, auditBufferSize),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		var enc = json.NewEncoder(w)
+		for e := range a.events {
+			enc.Encode(e)
+		}
+	}()
+	return a
+}
+
+// Record queues the given event to be written
+func (a *auditor) Record(e AuditEvent) {
+	if a == nil {
+		return
+	}
+	e.Timestamp = a.now()
+
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	if a.closed {
+		atomic.AddInt64(&a.dropped, 1)
+		return
+	}
+	select {
+	case a.events <- e:
+	default:
+		atomic.AddInt64(&a.dropped, 1)
+	}
+}
+
+// Dropped returns the number of events that were dropped because too many
+// were waiting to be written
+func (a *auditor) Dropped() int64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&a.dropped)
+}
+
+// Close stops accepting events, and waits for queued events to be written
+func (a *auditor) Close() {
+	if a == nil {
+		return
+	}
+	a.mux.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mux.Unlock()
+	<-a.done
+}
+
+// audit records an event for the given request
+func (h *PermissionsHandler) audit(r *http.Request, e AuditEvent) {
+	e.Method = r.Method
+	e.Endpoint = r.URL.Path
+	e.RequestID = middleware.GetReqID(r.Context())
+	h.auditor.Record(e)
+}
+
+// audited wraps the given handler to record an event for the given action
+// once it is done, based on the status of its response. Handlers can provide
+// details about the event using auditUser and auditTarget.
+func (h *PermissionsHandler) audited(action string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var e = &AuditEvent{Action: action}
+		if username, ok := r.Context().Value(ctxUsername).(string); ok {
+			e.User = username
+		}
+		var ww = middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		handler(ww, r.WithContext(context.WithValue(r.Context(), ctxAuditEvent, e)))
+
+		switch status := ww.Status(); {
+		case status < http.StatusBadRequest:
+			e.Result = AuditResultSuccess
+		case status == http.StatusUnauthorized, status == http.StatusForbidden,
+			status == http.StatusTooManyRequests:
+			e.Result = AuditResultDenied
+		default:
+			e.Result = AuditResultFailed
+		}
+		h.audit(r, *e)
+	}
+}
+
+// auditUser sets the user of the audit event for the given request, for
+// handlers that are not user-restricted
+func auditUser(r *http.Request, username string) {
+	if e, ok := r.Context().Value(ctxAuditEvent).(*AuditEvent); ok {
+		e.User = username
+	}
+}
+
+// auditTarget sets the subject of the audit event for the given request
+func auditTarget(r *http.Request, target string) {
+	if e, ok := r.Context().Value(ctxAuditEvent).(*AuditEvent); ok {
+		e.Target = target
+	}
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// blockingWriter blocks writes until it is released
+type blockingWriter struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return b.buf.Write(p)
+}
+
+func readAuditEvents(t *testing.T, out *bytes.Buffer) map[string]AuditEvent {
+	var events = map[string]AuditEvent{}
+	var dec = json.NewDecoder(out)
+	for dec.More() {
+		var e AuditEvent
+		assert.Nil(t, dec.Decode(&e))
+		events[e.Action+"/"+e.Result] = e
+	}
+	return events
+}
+
+func TestAuditor_nil(t *testing.T) {
+	var a = newAuditor(nil)
+	assert.Nil(t, a)
+	a.Record(AuditEvent{Action: AuditLogin})
+	assert.Equal(t, int64(0), a.Dropped())
+	a.Close()
+}
+
+func TestAuditor_slowWriter(t *testing.T) {
+	var (
+		w     = &blockingWriter{release: make(chan struct{})}
+		a     = newAuditor(w)
+		total = auditBufferSize + 10
+		done  = make(chan struct{})
+	)
+
+	// recording should never wait on the writer
+	go func() {
+		for i := 0; i < total; i++ {
+			a.Record(AuditEvent{Action: AuditAccess})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("recording blocked on slow writer")
+	}
+	var dropped = a.Dropped()
+	assert.True(t, dropped >= 9)
+
+	// queued events are written on close
+	close(w.release)
+	a.Close()
+	assert.Equal(t, total-int(dropped), strings.Count(w.buf.String(), "\n"))
+}
+
+func TestAuditor_recordAfterClose(t *testing.T) {
+	var a = newAuditor(ioutil.Discard)
+	a.Close()
+	a.Close()
+	assert.NotPanics(t, func() { a.Record(AuditEvent{Action: AuditLogin}) })
+	assert.Equal(t, int64(1), a.Dropped())
+}
+
+func TestPermissionsHandler_audit(t *testing.T) {
+	var (
+		out = &bytes.Buffer{}
+		now = time.Date(2018, time.October, 1, 12, 0, 0, 0, time.UTC)
+	)
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Audit: out,
+		Keys:  KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	ph.auditor.now = func() time.Time { return now }
+	ph.SetLoginTokenMode(LoginTokenBoth)
+	var requests int
+	ph.SetRequestIDGenerator(func() string {
+		requests++
+		return fmt.Sprintf("req-%d", requests)
+	})
+	ph.AttachAdminRestrictedHandlerFunc("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), http.MethodPost)
+	ts := httptest.NewServer(ph)
+	defer ts.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	// log in as a non-admin
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	loginResp, err := http.Post(ts.URL+"/user/login", "application/json", bytes.NewReader(body))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, loginResp.StatusCode)
+	token := getTokenFromResponse(loginResp.Body)
+
+	// attempt to access admin endpoint
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/test", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// wait for events to be written
+	assert.Nil(t, ph.Close())
+	var events = readAuditEvents(t, out)
+	assert.Len(t, events, 2)
+	assert.Equal(t, AuditEvent{
+		Timestamp: now,
+		User:      "bobheadxi",
+		Action:    AuditLogin,
+		Method:    http.MethodPost,
+		Endpoint:  "/user/login",
+		Result:    AuditResultSuccess,
+		RequestID: "req-1",
+	}, events[AuditLogin+"/"+AuditResultSuccess])
+	assert.Equal(t, AuditEvent{
+		Timestamp: now,
+		User:      "bobheadxi",
+		Action:    AuditAccess,
+		Method:    http.MethodPost,
+		Endpoint:  "/test",
+		Result:    AuditResultDenied,
+		Reason:    "admin privileges required",
+		RequestID: "req-2",
+	}, events[AuditAccess+"/"+AuditResultDenied])
+}
+
+func TestPermissionsHandler_auditRequestID(t *testing.T) {
+	var out = &bytes.Buffer{}
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Audit: out,
+		Keys:  KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	ph.SetRequestIDGenerator(func() string { return "generated" })
+	ts := httptest.NewServer(ph)
+	defer ts.Close()
+
+	// supplied IDs are echoed, and included in audit events and responses
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/user/validate", nil)
+	assert.Nil(t, err)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	var body api.BaseResponse
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "abc-123", resp.Header.Get(RequestIDHeader))
+	assert.Equal(t, "abc-123", body.RequestID)
+
+	// IDs are generated for requests without one, or with an invalid one
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/user/validate", nil)
+	assert.Nil(t, err)
+	req.Header.Set(RequestIDHeader, strings.Repeat("a", maxRequestIDLength+1))
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "generated", resp.Header.Get(RequestIDHeader))
+
+	// wait for events to be written
+	assert.Nil(t, ph.Close())
+	var ids []string
+	var dec = json.NewDecoder(out)
+	for dec.More() {
+		var e AuditEvent
+		assert.Nil(t, dec.Decode(&e))
+		ids = append(ids, e.RequestID)
+	}
+	assert.Equal(t, []string{"abc-123", "generated"}, ids)
+}
+
+func TestIsValidRequestID(t *testing.T) {
+	assert.True(t, isValidRequestID("abc-123"))
+	assert.True(t, isValidRequestID(newRequestID()))
+	assert.False(t, isValidRequestID(""))
+	assert.False(t, isValidRequestID("abc\n123"))
+	assert.False(t, isValidRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// Authorizer implements custom authorization policies, such as only allowing
+// users with certain claims to access certain paths. It is consulted for
+// requests to restricted paths after the token holder has been authenticated
+// and has passed the handler's own role and scope checks.
+type Authorizer interface {
+	// Authorize returns true if the holder of the given claims may make the
+	// given request, or false and a reason to give the client otherwise
+	Authorize(claims *crypto.TokenClaims, r *http.Request) (bool, string)
+}
+
+// AuthorizerFunc is an Authorizer backed by a function
+type AuthorizerFunc func(claims *crypto.TokenClaims, r *http.Request) (bool, string)
+
+// Authorize implements Authorizer
+func (f AuthorizerFunc) Authorize(claims *crypto.TokenClaims, r *http.Request) (bool, string) {
+	return f(claims, r)
+}
+
+// SetAuthorizer configures a custom authorization policy that requests to
+// restricted paths must also satisfy. By default, only the handler's own role
+// and scope checks apply. It must be set before the handler starts serving
+// requests.
+func (h *PermissionsHandler) SetAuthorizer(authorizer Authorizer) {
+	h.authorizer = authorizer
+}
+
+// authorize checks the given request against the custom authorization policy,
+// if there is one, and returns the reason for denying it
+func (h *PermissionsHandler) authorize(claims *crypto.TokenClaims, r *http.Request) (bool, string) {
+	if h.authorizer == nil {
+		return true, ""
+	}
+	allowed, reason := h.authorizer.Authorize(claims, r)
+	if !allowed && reason == "" {
+		reason = "denied by authorization policy"
+	}
+	return allowed, reason
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestPermissionsHandler_authorizer(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.AttachUserRestrictedHandlerFunc("/production/deploy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ph.AttachUserRestrictedHandlerFunc("/staging/deploy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ph.AttachPublicHandlerFunc("/public", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// only members of the "production" group may touch production routes
+	var calls int
+	ph.SetAuthorizer(AuthorizerFunc(func(claims *crypto.TokenClaims, r *http.Request) (bool, string) {
+		calls++
+		if !strings.HasPrefix(r.URL.Path, "/production/") {
+			return true, ""
+		}
+		if group, _ := claims.Claims["group"].(string); group != "production" {
+			return false, "only the production group may access production routes"
+		}
+		return true, ""
+	}))
+
+	var tokenWithGroup = func(group string) string {
+		var claims = &crypto.TokenClaims{User: "master", Admin: true}
+		if group != "" {
+			claims.Claims = map[string]interface{}{"group": group}
+		}
+		token, err := claims.GenerateToken(crypto.TestPrivateKey)
+		assert.Nil(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		group    string
+		wantCode int
+	}{
+		{"group allowed", "/production/deploy", "production", http.StatusOK},
+		{"group denied", "/production/deploy", "interns", http.StatusForbidden},
+		{"no group denied", "/production/deploy", "", http.StatusForbidden},
+		{"other path allowed", "/staging/deploy", "interns", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tokenWithGroup(tt.group))
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusForbidden {
+				b, err := api.Unmarshal(rec.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, "only the production group may access production routes", b.Message)
+				assert.Equal(t, api.ErrCodeForbidden, b.ErrCode)
+			}
+		})
+	}
+
+	// public paths are not subject to the authorizer
+	calls = 0
+	req := httptest.NewRequest(http.MethodGet, "/public", nil)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestPermissionsHandler_authorizerDefaultReason(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.AttachUserRestrictedHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ph.SetAuthorizer(AuthorizerFunc(func(*crypto.TokenClaims, *http.Request) (bool, string) {
+		return false, ""
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	b, err := api.Unmarshal(rec.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "denied by authorization policy", b.Message)
+}
@@ -0,0 +1,36 @@
+package auth
+
+import "time"
+
+// Clock tells the current time. The handler reads the time from its Clock when
+// issuing tokens, checking whether tokens have expired, and tracking lockout
+// and rate limit windows, so that tests can control the passage of time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a Clock that tells the real time
+type systemClock struct{}
+
+// Now implements Clock
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SetClock configures the clock the handler reads the current time from. By
+// default, the real time is used - this is mostly useful for tests. If the
+// session store is replaced with SetSessionStore, it must be set afterwards.
+// It must be set before the handler starts serving requests.
+func (h *PermissionsHandler) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	h.clock = clock
+	h.sessions.clock = clock
+	h.users.clock = clock
+	h.logins.now = clock.Now
+	if h.limiter != nil {
+		h.limiter.now = clock.Now
+	}
+	if store, ok := h.sessions.store.(*memorySessionStore); ok {
+		store.clock = clock
+	}
+}
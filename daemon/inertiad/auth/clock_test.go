@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	c.now = c.now.Add(d)
+	c.mux.Unlock()
+}
+
+func TestPermissionsHandler_SetClock_tokenExpiry(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var clock = newFakeClock()
+	ph.SetClock(clock)
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	// Mint a session token
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var token = getTokenFromResponse(rec.Result().Body)
+
+	var validate = func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/user/validate", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Valid until just before expiry
+	clock.Advance(DefaultSessionTimeout - time.Second)
+	assert.Equal(t, http.StatusOK, validate().Code)
+
+	// Rejected once the clock passes expiry
+	clock.Advance(2 * time.Second)
+	rec = validate()
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeExpiredToken)
+}
+
+func TestPermissionsHandler_SetClock_lockout(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var clock = newFakeClock()
+	ph.SetClock(clock)
+	ph.SetLoginLockout(1, time.Minute)
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func(password string) int {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: password})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	// Locked out after a failed login, until the cooldown passes
+	login("wrong")
+	assert.Equal(t, http.StatusTooManyRequests, login("wowgreat"))
+	clock.Advance(time.Minute + time.Second)
+	assert.Equal(t, http.StatusOK, login("wowgreat"))
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// CookieName is the name of the cookie that holds session tokens for
+	// browser clients such as Inertia Web
+	CookieName = "ubclaunchpad-inertia"
+
+	// CSRFCookieName is the name of the cookie that holds the CSRF token issued
+	// alongside the session cookie. Unlike the session cookie, it is readable
+	// by scripts, which must echo it in the CSRFHeader of requests that
+	// change state.
+	CSRFCookieName = "ubclaunchpad-inertia-csrf"
+
+	// CSRFHeader is the header in which cookie-authenticated requests must
+	// provide their CSRF token
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// errSameSiteNone is returned for cookie configurations that send the session
+// cookie with cross-site requests
+var errSameSiteNone = errors.New("session cookies cannot use SameSite=None, since cross-origin requests are allowed from any origin")
+
+// CookieConfig configures the attributes of the session cookie
+type CookieConfig struct {
+	// Secure restricts the cookie to HTTPS requests
+	Secure bool
+
+	// HttpOnly hides the cookie from scripts
+	HttpOnly bool
+
+	// SameSite restricts the cookie from being sent with cross-site requests.
+	// http.SameSiteNoneMode is not allowed, since the daemon allows
+	// credentialed cross-origin requests from any origin.
+	SameSite http.SameSite
+
+	// Domain and Path restrict the requests the cookie is sent with
+	Domain string
+	Path   string
+}
+
+// DefaultCookieConfig returns the cookie attributes used when none are
+// provided - the cookie is secure, hidden from scripts, and sent with
+// top-level navigations from other sites only
+func DefaultCookieConfig() CookieConfig {
+	return CookieConfig{
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	}
+}
+
+// ParseSameSite parses "lax" or "strict" into a SameSite mode, and returns
+// false if the given value is not one of these. "none" is not accepted, since
+// it would let any site make credentialed requests with the session cookie.
+func ParseSameSite(v string) (http.SameSite, bool) {
+	switch strings.ToLower(v) {
+	case "lax":
+		return http.SameSiteLaxMode, true
+	case "strict":
+		return http.SameSiteStrictMode, true
+	default:
+		return http.SameSiteDefaultMode, false
+	}
+}
+
+// LoginTokenMode determines how session tokens issued on login or refresh are
+// returned to clients
+type LoginTokenMode int
+
+const (
+	// LoginTokenCookie sets the token in the session cookie, and only returns
+	// it in the response body to clients that accept JSON
+	LoginTokenCookie LoginTokenMode = iota
+
+	// LoginTokenBody only returns the token in the response body, and does not
+	// set any cookies
+	LoginTokenBody
+
+	// LoginTokenBoth sets the token in the session cookie, and returns it in
+	// the response body
+	LoginTokenBoth
+)
+
+// ParseLoginTokenMode parses "cookie", "body", or "both" into a LoginTokenMode,
+// and returns false if the given value is not one of these
+func ParseLoginTokenMode(v string) (LoginTokenMode, bool) {
+	switch strings.ToLower(v) {
+	case "cookie":
+		return LoginTokenCookie, true
+	case "body":
+		return LoginTokenBody, true
+	case "both":
+		return LoginTokenBoth, true
+	default:
+		return LoginTokenCookie, false
+	}
+}
+
+// SetLoginTokenMode configures how session tokens issued on login or refresh
+// are returned to clients. By default, LoginTokenCookie is used.
+func (h *PermissionsHandler) SetLoginTokenMode(mode LoginTokenMode) {
+	h.loginToken = mode
+}
+
+// acceptsJSON returns true if the given request accepts JSON responses
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		var mediaType = strings.TrimSpace(strings.Split(accept, ";")[0])
+		if strings.EqualFold(mediaType, "application/json") {
+			return true
+		}
+	}
+	return false
+}
+
+// issueSession returns the given session token to the client according to the
+// login token mode, and returns the key-value pairs to include in the response
+func (h *PermissionsHandler) issueSession(w http.ResponseWriter, r *http.Request,
+	token string, expiry time.Time) ([]interface{}, error) {
+	var kvs []interface{}
+	if h.loginToken != LoginTokenBody {
+		csrf, err := h.setSessionCookie(w, token, expiry)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, "csrf_token", csrf)
+	}
+	if h.loginToken != LoginTokenCookie || acceptsJSON(r) {
+		kvs = append(kvs, "token", token)
+	}
+	return kvs, nil
+}
+
+// setSessionCookie sets a cookie holding the given session token, and a cookie
+// holding a new CSRF token, which both expire alongside the session token. The
+// CSRF token is returned.
+func (h *PermissionsHandler) setSessionCookie(w http.ResponseWriter, token string,
+	expiry time.Time) (string, error) {
+	var csrf = make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return "", err
+	}
+	var csrfToken = base64.RawURLEncoding.EncodeToString(csrf)
+	var maxAge = int(expiry.Sub(h.clock.Now()).Seconds())
+	http.SetCookie(w, h.newCookie(CookieName, token, expiry, maxAge, h.cookies.HttpOnly))
+	http.SetCookie(w, h.newCookie(CSRFCookieName, csrfToken, expiry, maxAge, false))
+	return csrfToken, nil
+}
+
+// clearSessionCookie tells the client to discard the session and CSRF cookies
+func (h *PermissionsHandler) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, h.newCookie(CookieName, "", time.Unix(0, 0), -1, h.cookies.HttpOnly))
+	http.SetCookie(w, h.newCookie(CSRFCookieName, "", time.Unix(0, 0), -1, false))
+}
+
+func (h *PermissionsHandler) newCookie(name, value string, expiry time.Time, maxAge int,
+	httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Expires:  expiry,
+		MaxAge:   maxAge,
+		Secure:   h.cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: h.cookies.SameSite,
+		Domain:   h.cookies.Domain,
+		Path:     h.cookies.Path,
+	}
+}
+
+// hasValidCSRFToken returns false if the given request was authenticated by
+// the session cookie and may change state, but does not echo the CSRF cookie
+// in the CSRFHeader. Other sites can make browsers send cookies, but cannot
+// read them, so they cannot provide the header. Requests authenticated with
+// a bearer token are not affected, since browsers never send them on their
+// own.
+func hasValidCSRFToken(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	var header = r.Header.Get(CSRFHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestParseSameSite(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   http.SameSite
+		wantOK bool
+	}{
+		{"lax", http.SameSiteLaxMode, true},
+		{"Strict", http.SameSiteStrictMode, true},
+		{"none", http.SameSiteDefaultMode, false},
+		{"sometimes", http.SameSiteDefaultMode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseSameSite(tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestParseLoginTokenMode(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   LoginTokenMode
+		wantOK bool
+	}{
+		{"cookie", LoginTokenCookie, true},
+		{"Body", LoginTokenBody, true},
+		{"both", LoginTokenBoth, true},
+		{"carrier pigeon", LoginTokenCookie, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseLoginTokenMode(tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestPermissionsHandler_loginTokenMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       *LoginTokenMode
+		accept     string
+		wantCookie bool
+		wantToken  bool
+	}{
+		{"cookie-only by default", nil, "", true, false},
+		{"cookie-only for browsers", nil, "text/html,*/*;q=0.8", true, false},
+		{"cookie with body for JSON clients", nil, "text/plain, application/json; q=0.9", true, true},
+		{"body-only", &[]LoginTokenMode{LoginTokenBody}[0], "", false, true},
+		{"combined", &[]LoginTokenMode{LoginTokenBoth}[0], "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+				Keys: KeyLookupFunc(crypto.GetFakeAPIKey),
+			})
+			assert.Nil(t, err)
+			defer ph.Close()
+			if tt.mode != nil {
+				ph.SetLoginTokenMode(*tt.mode)
+			}
+			assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+			body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+			assert.Nil(t, err)
+			var req = httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			var rec = httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var token, csrfToken string
+			_, err = api.Unmarshal(rec.Result().Body,
+				api.KV{Key: "token", Value: &token},
+				api.KV{Key: "csrf_token", Value: &csrfToken})
+			assert.Nil(t, err)
+			var cookies = rec.Result().Cookies()
+			if tt.wantCookie {
+				assert.Len(t, cookies, 2)
+				assert.NotEmpty(t, csrfToken)
+			} else {
+				assert.Empty(t, cookies)
+				assert.Empty(t, csrfToken)
+			}
+			if tt.wantToken {
+				assert.NotEmpty(t, token)
+				if tt.wantCookie {
+					assert.Equal(t, token, cookies[0].Value)
+				}
+			} else {
+				assert.Empty(t, token)
+			}
+		})
+	}
+}
+
+func TestPermissionsHandler_sameSiteNone(t *testing.T) {
+	// cross-origin requests are allowed from any origin, so cookies must not be
+	// sent with them
+	_, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Cookies: &CookieConfig{Secure: true, HttpOnly: true, SameSite: http.SameSiteNoneMode},
+		Keys:    KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Equal(t, errSameSiteNone, err)
+}
+
+func TestPermissionsHandler_sessionCookie(t *testing.T) {
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Cookies: &CookieConfig{
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+			Domain:   "inertia.example.com",
+			Path:     "/inertia",
+		},
+		Keys: KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	// login sets cookie with configured attributes
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	var req = httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	var rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var cookies = rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	var cookie = cookies[0]
+	assert.Equal(t, CookieName, cookie.Name)
+	assert.Equal(t, getTokenFromResponse(rec.Result().Body), cookie.Value)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.Equal(t, "inertia.example.com", cookie.Domain)
+	assert.Equal(t, "/inertia", cookie.Path)
+
+	// CSRF cookie has the same attributes, but is readable by scripts
+	var csrf = cookies[1]
+	assert.Equal(t, CSRFCookieName, csrf.Name)
+	assert.NotEmpty(t, csrf.Value)
+	assert.True(t, csrf.Secure)
+	assert.False(t, csrf.HttpOnly)
+	assert.Equal(t, "/inertia", csrf.Path)
+
+	// cookie can be used in place of a bearer token
+	req = httptest.NewRequest(http.MethodGet, "/user/validate", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: cookie.Value})
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// logout clears cookie with the same domain and path
+	req = httptest.NewRequest(http.MethodPost, "/user/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+cookie.Value)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cookies = rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	cookie = cookies[0]
+	assert.Equal(t, CookieName, cookie.Name)
+	assert.Equal(t, "", cookie.Value)
+	assert.True(t, cookie.MaxAge < 0)
+	assert.Equal(t, "inertia.example.com", cookie.Domain)
+	assert.Equal(t, "/inertia", cookie.Path)
+	assert.Equal(t, CSRFCookieName, cookies[1].Name)
+	assert.True(t, cookies[1].MaxAge < 0)
+}
+
+func TestPermissionsHandler_csrf(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	ph.AttachUserRestrictedHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, http.MethodGet, http.MethodPost)
+
+	// log in to get session and CSRF cookies
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	var rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var cookies = rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	var session, csrf = cookies[0], cookies[1]
+	var csrfToken string
+	_, err = api.Unmarshal(rec.Result().Body, api.KV{Key: "csrf_token", Value: &csrfToken})
+	assert.Nil(t, err)
+	assert.Equal(t, csrf.Value, csrfToken)
+
+	tests := []struct {
+		name     string
+		method   string
+		cookies  []*http.Cookie
+		header   string
+		bearer   bool
+		wantCode int
+	}{
+		{"valid token", http.MethodPost, []*http.Cookie{session, csrf}, csrf.Value, false, http.StatusOK},
+		{"missing token", http.MethodPost, []*http.Cookie{session, csrf}, "", false, http.StatusForbidden},
+		{"mismatched token", http.MethodPost, []*http.Cookie{session, csrf}, "forged", false, http.StatusForbidden},
+		{"missing cookie", http.MethodPost, []*http.Cookie{session}, csrf.Value, false, http.StatusForbidden},
+		{"safe method", http.MethodGet, []*http.Cookie{session}, "", false, http.StatusOK},
+		{"bearer token", http.MethodPost, nil, "", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req = httptest.NewRequest(tt.method, "/test", nil)
+			for _, c := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+session.Value)
+			}
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusForbidden {
+				assert.Contains(t, rec.Body.String(), api.ErrCodeInvalidCSRF)
+			}
+		})
+	}
+}
+
+func TestPermissionsHandler_rememberSession(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetRememberTimeout(72 * time.Hour)
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	tests := []struct {
+		name     string
+		remember bool
+		want     time.Duration
+	}{
+		{"default", false, DefaultSessionTimeout},
+		{"remember", true, 72 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(&api.UserRequest{
+				Username: "bobheadxi", Password: "wowgreat", Remember: tt.remember})
+			assert.Nil(t, err)
+			var rec = httptest.NewRecorder()
+			var before = time.Now()
+			ph.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body)))
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			// cookies expire alongside the session
+			var cookies = rec.Result().Cookies()
+			assert.Len(t, cookies, 2)
+			for _, c := range cookies {
+				assert.InDelta(t, tt.want.Seconds(), float64(c.MaxAge), 5)
+			}
+
+			// token carries the expiry it was issued with, and is accepted
+			var req = httptest.NewRequest(http.MethodGet, "/user/validate", nil)
+			req.Header.Set("Authorization", "Bearer "+cookies[0].Value)
+			claims, err := ph.sessions.GetSession(req)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.remember, claims.Remember)
+			assert.WithinDuration(t, before.Add(tt.want), claims.Expiry, 5*time.Second)
+
+			// refreshed sessions keep the same duration
+			refreshed, _, err := ph.sessions.RefreshSession(claims)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.remember, refreshed.Remember)
+			assert.WithinDuration(t, time.Now().Add(tt.want), refreshed.Expiry, 5*time.Second)
+		})
+	}
+}
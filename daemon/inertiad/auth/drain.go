@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is the default duration for which Close waits for
+// in-flight requests to finish
+const DefaultDrainTimeout = 10 * time.Second
+
+// drainer tracks in-flight requests, so that resources they use are only
+// released once they have finished
+type drainer struct {
+	timeout time.Duration
+
+	mux      sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+}
+
+func newDrainer(timeout time.Duration) *drainer {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	return &drainer{timeout: timeout}
+}
+
+// Begin records a new in-flight request, which must call Done once finished.
+// It returns false if the drainer is draining, in which case the request
+// should be refused.
+func (d *drainer) Begin() bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inflight.Add(1)
+	return true
+}
+
+// Done records that an in-flight request has finished
+func (d *drainer) Done() { d.inflight.Done() }
+
+// Wait blocks until all in-flight requests have finished
+func (d *drainer) Wait() { d.inflight.Wait() }
+
+// Drain refuses new requests, and waits up to the timeout for in-flight
+// requests to finish. It returns false if requests were still in flight when
+// the timeout elapsed.
+func (d *drainer) Drain() bool {
+	d.mux.Lock()
+	d.draining = true
+	d.mux.Unlock()
+
+	var done = make(chan struct{})
+	go func() {
+		d.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(d.timeout):
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestNewDrainer(t *testing.T) {
+	assert.Equal(t, DefaultDrainTimeout, newDrainer(0).timeout)
+	assert.Equal(t, time.Second, newDrainer(time.Second).timeout)
+}
+
+func TestPermissionsHandler_drain(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	ph.AttachPublicHandlerFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// start a slow request, then close the handler while it is in flight
+	var slow = httptest.NewRecorder()
+	var served = make(chan struct{})
+	go func() {
+		ph.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(served)
+	}()
+	<-started
+	var closed = make(chan error)
+	go func() { closed <- ph.Close() }()
+
+	// new requests are refused while draining
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 100; i++ {
+		rec = httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/user/validate", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeShuttingDown)
+
+	// close waits for the slow request to finish
+	select {
+	case <-closed:
+		t.Fatal("closed before in-flight request finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-served
+	assert.Equal(t, http.StatusOK, slow.Code)
+	assert.Nil(t, <-closed)
+}
+
+// eventWriter sends each audit event written to it on a channel
+type eventWriter chan AuditEvent
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	var e AuditEvent
+	if err := json.Unmarshal(p, &e); err != nil {
+		return 0, err
+	}
+	w <- e
+	return len(p), nil
+}
+
+func TestPermissionsHandler_drainTimeout(t *testing.T) {
+	var events = make(eventWriter, 1)
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Audit: events,
+		Keys:  KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	ph.SetDrainTimeout(10 * time.Millisecond)
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	ph.AttachPublicHandlerFunc("/stuck", ph.audited(AuditAccess, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+
+		// the user database should still be usable
+		if err := ph.users.Ping(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var (
+		stuck  = httptest.NewRecorder()
+		served = make(chan interface{})
+	)
+	go func() {
+		defer func() { served <- recover() }()
+		ph.ServeHTTP(stuck, httptest.NewRequest(http.MethodGet, "/stuck", nil))
+	}()
+	<-started
+
+	// requests that do not finish in time do not block closing forever
+	assert.NotNil(t, ph.Close())
+
+	// the stuck request finishes after close returns, and is still audited
+	close(release)
+	assert.Nil(t, <-served)
+	assert.Equal(t, http.StatusOK, stuck.Code)
+	var e = <-events
+	assert.Equal(t, AuditAccess, e.Action)
+	assert.Equal(t, AuditResultSuccess, e.Result)
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// PlaintextMode determines how requests that were not made over HTTPS are
+// handled
+type PlaintextMode int
+
+const (
+	// PlaintextAllow serves requests that were not made over HTTPS
+	PlaintextAllow PlaintextMode = iota
+
+	// PlaintextRedirect redirects requests that were not made over HTTPS to
+	// the same URL over HTTPS
+	PlaintextRedirect
+
+	// PlaintextReject refuses requests that were not made over HTTPS
+	PlaintextReject
+)
+
+// ParsePlaintextMode parses "allow", "redirect", or "reject" into a
+// PlaintextMode, and returns false if the given value is not one of these
+func ParsePlaintextMode(v string) (PlaintextMode, bool) {
+	switch strings.ToLower(v) {
+	case "allow":
+		return PlaintextAllow, true
+	case "redirect":
+		return PlaintextRedirect, true
+	case "reject":
+		return PlaintextReject, true
+	default:
+		return PlaintextAllow, false
+	}
+}
+
+// HTTPSPolicy configures how the handler enforces HTTPS
+type HTTPSPolicy struct {
+	// HSTSMaxAge is how long browsers should only access the daemon over
+	// HTTPS, which is sent in the Strict-Transport-Security header of all
+	// responses - if zero, no header is sent
+	HSTSMaxAge time.Duration
+
+	// HSTSIncludeSubdomains extends the Strict-Transport-Security header to
+	// subdomains of the daemon's domain
+	HSTSIncludeSubdomains bool
+
+	// Plaintext determines how requests that were not made over HTTPS are
+	// handled. Requests are considered to have been made over HTTPS if they
+	// were served over TLS, or if the X-Forwarded-Proto header says so. Health
+	// and readiness probes are always served.
+	Plaintext PlaintextMode
+}
+
+// SetHTTPSPolicy configures how the handler enforces HTTPS. By default, no
+// Strict-Transport-Security header is sent, and plaintext requests are
+// allowed.
+func (h *PermissionsHandler) SetHTTPSPolicy(policy HTTPSPolicy) {
+	h.https = policy
+}
+
+// setHSTS sets the Strict-Transport-Security header, if configured
+func (h *PermissionsHandler) setHSTS(w http.ResponseWriter) {
+	if h.https.HSTSMaxAge <= 0 {
+		return
+	}
+	var value = fmt.Sprintf("max-age=%d", int64(h.https.HSTSMaxAge/time.Second))
+	if h.https.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	w.Header().Set("Strict-Transport-Security", value)
+}
+
+// enforceHTTPS handles requests that were not made over HTTPS according to
+// the plaintext mode, and returns false if the request has been handled
+func (h *PermissionsHandler) enforceHTTPS(w http.ResponseWriter, r *http.Request) bool {
+	if h.https.Plaintext == PlaintextAllow || isHTTPS(r) {
+		return true
+	}
+	switch h.https.Plaintext {
+	case PlaintextRedirect:
+		var target = "https://" + r.Host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	default:
+		render.Render(w, r, res.ErrForbidden("HTTPS is required"))
+	}
+	return false
+}
+
+// isHTTPS returns true if the given request was made over HTTPS
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	var proto = strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0])
+	return strings.EqualFold(proto, "https")
+}
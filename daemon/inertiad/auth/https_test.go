@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlaintextMode(t *testing.T) {
+	for v, want := range map[string]PlaintextMode{
+		"allow":    PlaintextAllow,
+		"Redirect": PlaintextRedirect,
+		"reject":   PlaintextReject,
+	} {
+		mode, ok := ParsePlaintextMode(v)
+		assert.True(t, ok)
+		assert.Equal(t, want, mode)
+	}
+	_, ok := ParsePlaintextMode("upgrade")
+	assert.False(t, ok)
+}
+
+func TestPermissionsHandler_httpsPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       HTTPSPolicy
+		path         string
+		tls          bool
+		proto        string
+		wantCode     int
+		wantHSTS     string
+		wantLocation string
+	}{
+		{"no policy", HTTPSPolicy{},
+			"/test?a=b", false, "", http.StatusOK, "", ""},
+		{"hsts", HTTPSPolicy{HSTSMaxAge: 365 * 24 * time.Hour},
+			"/test?a=b", true, "", http.StatusOK, "max-age=31536000", ""},
+		{"hsts with subdomains", HTTPSPolicy{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true},
+			"/test?a=b", true, "", http.StatusOK, "max-age=3600; includeSubDomains", ""},
+		{"redirect plaintext", HTTPSPolicy{Plaintext: PlaintextRedirect},
+			"/test?a=b", false, "", http.StatusPermanentRedirect, "", "https://example.com/test?a=b"},
+		{"redirect forwarded https", HTTPSPolicy{Plaintext: PlaintextRedirect},
+			"/test?a=b", false, "https", http.StatusOK, "", ""},
+		{"redirect forwarded http", HTTPSPolicy{Plaintext: PlaintextRedirect},
+			"/test?a=b", false, "http", http.StatusPermanentRedirect, "", "https://example.com/test?a=b"},
+		{"reject plaintext", HTTPSPolicy{HSTSMaxAge: time.Hour, Plaintext: PlaintextReject},
+			"/test?a=b", false, "", http.StatusForbidden, "max-age=3600", ""},
+		{"reject allows tls", HTTPSPolicy{Plaintext: PlaintextReject},
+			"/test?a=b", true, "", http.StatusOK, "", ""},
+		{"reject allows probes", HTTPSPolicy{Plaintext: PlaintextReject},
+			"/health", false, "", http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph, err := getTestPermissionsHandler()
+			assert.Nil(t, err)
+			defer ph.Close()
+			ph.SetHTTPSPolicy(tt.policy)
+			ph.AttachPublicHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			var req = httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			var rec = httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantHSTS, rec.Header().Get("Strict-Transport-Security"))
+			assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+		})
+	}
+}
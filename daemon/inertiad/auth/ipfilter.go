@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPFilter configures which client addresses may make requests
+type IPFilter struct {
+	// Allow lists the CIDR ranges, or single addresses, that clients must be
+	// in - if empty, all clients not denied are allowed
+	Allow []string
+
+	// Deny lists the CIDR ranges, or single addresses, that clients must not
+	// be in. Denied ranges take precedence over allowed ones.
+	Deny []string
+
+	// TrustedProxies is the number of proxies in front of the daemon whose
+	// X-Forwarded-For entries are trusted to identify the client. If zero, the
+	// header is ignored, since clients can set it to anything.
+	TrustedProxies int
+
+	// ExemptPublic exempts public endpoints, such as health checks, from the
+	// filter. Endpoints served by the PermissionsHandler itself, such as
+	// logins, are always filtered.
+	ExemptPublic bool
+}
+
+// ipFilter allows or denies requests based on the address of the client
+type ipFilter struct {
+	allow        []*net.IPNet
+	deny         []*net.IPNet
+	exemptPublic bool
+}
+
+// newIPFilter creates a filter from the given configuration, or returns nil if
+// the configuration does not filter requests
+func newIPFilter(filter *IPFilter) (*ipFilter, error) {
+	if filter == nil || (len(filter.Allow) == 0 && len(filter.Deny) == 0) {
+		return nil, nil
+	}
+	allow, err := parseCIDRs(filter.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseCIDRs(filter.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &ipFilter{
+		allow:        allow,
+		deny:         deny,
+		exemptPublic: filter.ExemptPublic,
+	}, nil
+}
+
+// parseCIDRs parses the given CIDR ranges. Single addresses are treated as
+// ranges containing only that address.
+func parseCIDRs(ranges []string) ([]*net.IPNet, error) {
+	var nets = make([]*net.IPNet, 0, len(ranges))
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			var ip = net.ParseIP(r)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address '%s'", r)
+			}
+			var bits = 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range '%s': %s", r, err.Error())
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Allowed returns true if the given client address is not denied, and is
+// allowed if any ranges are allowed
+func (f *ipFilter) Allowed(address string) bool {
+	var ip = net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddress returns the address of the client that made the given request.
+// Each trusted proxy is assumed to have appended the address it received the
+// request from to the X-Forwarded-For header, so the client is the entry just
+// before those appended by trusted proxies. If there are no trusted proxies,
+// the header is ignored, since clients can set it to anything.
+func clientAddress(r *http.Request, trustedProxies int) string {
+	var address = remoteAddress(r)
+	if trustedProxies <= 0 {
+		return address
+	}
+	var hops []string
+	for _, header := range r.Header["X-Forwarded-For"] {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	hops = append(hops, address)
+	var i = len(hops) - 1 - trustedProxies
+	if i < 0 {
+		i = 0
+	}
+	return hops[i]
+}
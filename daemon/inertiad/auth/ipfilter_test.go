@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestNewIPFilter(t *testing.T) {
+	f, err := newIPFilter(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, f)
+	f, err = newIPFilter(&IPFilter{TrustedProxies: 1})
+	assert.Nil(t, err)
+	assert.Nil(t, f)
+
+	f, err = newIPFilter(&IPFilter{Allow: []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"}})
+	assert.Nil(t, err)
+	assert.True(t, f.Allowed("10.1.2.3"))
+	assert.True(t, f.Allowed("192.168.1.1"))
+	assert.False(t, f.Allowed("192.168.1.2"))
+	assert.True(t, f.Allowed("2001:db8::1"))
+	assert.False(t, f.Allowed("not-an-address"))
+
+	_, err = newIPFilter(&IPFilter{Allow: []string{"10.0.0.0/33"}})
+	assert.NotNil(t, err)
+	_, err = newIPFilter(&IPFilter{Deny: []string{"office"}})
+	assert.NotNil(t, err)
+}
+
+func TestPermissionsHandler_ipFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    IPFilter
+		path      string
+		remote    string
+		forwarded string
+		wantCode  int
+	}{
+		{"allowed", IPFilter{Allow: []string{"10.0.0.0/8"}},
+			"/test", "10.1.2.3:1234", "", http.StatusOK},
+		{"not allowed", IPFilter{Allow: []string{"10.0.0.0/8"}},
+			"/test", "192.0.2.1:1234", "", http.StatusForbidden},
+		{"denied", IPFilter{Deny: []string{"192.0.2.0/24"}},
+			"/test", "192.0.2.1:1234", "", http.StatusForbidden},
+		{"denied within allowed", IPFilter{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}},
+			"/test", "10.1.2.3:1234", "", http.StatusForbidden},
+		{"spoofed forwarded header ignored", IPFilter{Allow: []string{"10.0.0.0/8"}},
+			"/test", "192.0.2.1:1234", "10.1.2.3", http.StatusForbidden},
+		{"forwarded by trusted proxy", IPFilter{Allow: []string{"10.0.0.0/8"}, TrustedProxies: 1},
+			"/test", "192.0.2.1:1234", "192.0.2.2, 10.1.2.3", http.StatusOK},
+		{"spoofed entry before trusted proxy ignored", IPFilter{Allow: []string{"10.0.0.0/8"}, TrustedProxies: 1},
+			"/test", "192.0.2.1:1234", "10.1.2.3, 192.0.2.2", http.StatusForbidden},
+		{"public exempt", IPFilter{Allow: []string{"10.0.0.0/8"}, ExemptPublic: true},
+			"/test", "192.0.2.1:1234", "", http.StatusOK},
+		{"login never exempt", IPFilter{Allow: []string{"10.0.0.0/8"}, ExemptPublic: true},
+			"/user/login", "192.0.2.1:1234", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter = tt.filter
+			ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+				IPFilter: &filter,
+				Keys:     KeyLookupFunc(crypto.GetFakeAPIKey),
+			})
+			assert.Nil(t, err)
+			defer ph.Close()
+			ph.AttachPublicHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			var req = httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			var rec = httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusForbidden {
+				b, err := api.Unmarshal(rec.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, api.ErrCodeAddressDenied, b.ErrCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// JWKSPath is where the public keys used to validate RS256 tokens are served
+const JWKSPath = "/.well-known/jwks.json"
+
+// KeyLookup retrieves the keys used to sign and validate tokens. Implementations
+// can fetch keys from secret stores, or rotate them - tokens signed with a key
+// that is no longer returned are rejected.
+type KeyLookup interface {
+	// Key returns the key used to validate the given token, or the key used to
+	// sign new tokens if the given token is nil
+	Key(token *jwt.Token) ([]byte, error)
+}
+
+// KeyLookupFunc adapts a jwt.Keyfunc that returns keys as byte slices, such as
+// crypto.GetAPIPrivateKey, into a KeyLookup
+type KeyLookupFunc func(*jwt.Token) (interface{}, error)
+
+// Key implements KeyLookup
+func (f KeyLookupFunc) Key(token *jwt.Token) ([]byte, error) {
+	key, err := f(token)
+	if err != nil {
+		return nil, err
+	}
+	bytes, ok := key.([]byte)
+	if !ok {
+		return nil, errors.New("key lookup did not return a byte slice")
+	}
+	return bytes, nil
+}
+
+// RSAKeyLookup is a KeyLookup that signs session and read-only tokens with
+// RS256 using an RSA private key, so that other services can validate them
+// with only the public key, which is served at JWKSPath. HMAC tokens, such as
+// the master tokens used by the Inertia CLI, are still accepted, and are
+// validated with keys from the given secret KeyLookup.
+type RSAKeyLookup struct {
+	mux      sync.RWMutex
+	private  *rsa.PrivateKey
+	keyID    string
+	previous []previousKey
+	secret   KeyLookup
+}
+
+// previousKey is a public key that has been rotated out, but is still
+// published and accepted until the end of its overlap window
+type previousKey struct {
+	public *rsa.PublicKey
+	keyID  string
+	until  time.Time
+}
+
+// NewRSAKeyLookup creates a KeyLookup that signs tokens with the given RSA
+// private key. HMAC tokens are validated with keys from the given secret
+// KeyLookup, or from crypto.GetAPIPrivateKey if it is nil.
+func NewRSAKeyLookup(key *rsa.PrivateKey, secret KeyLookup) *RSAKeyLookup {
+	if secret == nil {
+		secret = KeyLookupFunc(crypto.GetAPIPrivateKey)
+	}
+	return &RSAKeyLookup{
+		private: key,
+		keyID:   crypto.KeyID(&key.PublicKey),
+		secret:  secret,
+	}
+}
+
+// Key implements KeyLookup, and returns the key used to validate HMAC tokens
+func (k *RSAKeyLookup) Key(token *jwt.Token) ([]byte, error) {
+	return k.secret.Key(token)
+}
+
+// Rotate starts signing tokens with the given key. The key that was in use
+// is still published and accepted for the given overlap, so that tokens it
+// signed remain valid, and so that other services have time to pick up the
+// new key - if the overlap is zero, DefaultSessionTimeout is used.
+func (k *RSAKeyLookup) Rotate(key *rsa.PrivateKey, overlap time.Duration) {
+	if overlap <= 0 {
+		overlap = DefaultSessionTimeout
+	}
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	var keyID = crypto.KeyID(&key.PublicKey)
+	if keyID == k.keyID {
+		return
+	}
+	k.previous = append(k.activePrevious(), previousKey{
+		public: &k.private.PublicKey,
+		keyID:  k.keyID,
+		until:  time.Now().Add(overlap),
+	})
+	k.private = key
+	k.keyID = keyID
+}
+
+// JWKS returns the public keys that RS256 tokens are currently accepted for,
+// starting with the key tokens are signed with
+func (k *RSAKeyLookup) JWKS() crypto.JWKS {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	var jwks = crypto.JWKS{Keys: []crypto.JWK{crypto.NewRS256JWK(&k.private.PublicKey)}}
+	for _, p := range k.activePrevious() {
+		jwks.Keys = append(jwks.Keys, crypto.NewRS256JWK(p.public))
+	}
+	return jwks
+}
+
+// publicKey returns the public key with the given key ID, which is the current
+// key if no key ID is given
+func (k *RSAKeyLookup) publicKey(keyID string) (*rsa.PublicKey, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	if keyID == "" || keyID == k.keyID {
+		return &k.private.PublicKey, nil
+	}
+	for _, p := range k.activePrevious() {
+		if p.keyID == keyID {
+			return p.public, nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// sign signs the given claims with the current key
+func (k *RSAKeyLookup) sign(claims *crypto.TokenClaims) (string, error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return claims.GenerateRS256Token(k.private, k.keyID)
+}
+
+// activePrevious returns the previous keys whose overlap has not yet ended.
+// Callers must hold the lock.
+func (k *RSAKeyLookup) activePrevious() []previousKey {
+	var now = time.Now()
+	var active = make([]previousKey, 0, len(k.previous))
+	for _, p := range k.previous {
+		if now.Before(p.until) {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// keyfunc adapts the given KeyLookup into a jwt.Keyfunc for validating tokens.
+// The key is picked based on the token's algorithm, and crypto.ValidateToken
+// rejects tokens whose algorithm does not match the key.
+func keyfunc(keys KeyLookup) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if rsaKeys, ok := keys.(*RSAKeyLookup); ok && token.Method == jwt.SigningMethodRS256 {
+			keyID, _ := token.Header["kid"].(string)
+			return rsaKeys.publicKey(keyID)
+		}
+		return keys.Key(token)
+	}
+}
+
+// signToken signs the given claims with RS256 if the given KeyLookup is an
+// RSAKeyLookup, or with HMAC using its signing key otherwise
+func signToken(keys KeyLookup, claims *crypto.TokenClaims) (string, error) {
+	if rsaKeys, ok := keys.(*RSAKeyLookup); ok {
+		return rsaKeys.sign(claims)
+	}
+	key, err := keys.Key(nil)
+	if err != nil {
+		return "", err
+	}
+	return claims.GenerateToken(key)
+}
+
+// jwksHandler serves the public keys used to validate RS256 tokens, which is
+// an empty set if tokens are signed with HMAC
+func (h *PermissionsHandler) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	var jwks = crypto.JWKS{Keys: []crypto.JWK{}}
+	if rsaKeys, ok := h.sessions.keys.(*RSAKeyLookup); ok {
+		jwks = rsaKeys.JWKS()
+	}
+	render.JSON(w, r, jwks)
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// rotatingKeys is a KeyLookup that serves one key at a time, and can switch
+// to the next key at any time
+type rotatingKeys struct {
+	keys    [][]byte
+	current int
+	calls   int
+}
+
+func (r *rotatingKeys) Key(*jwt.Token) ([]byte, error) {
+	r.calls++
+	return r.keys[r.current], nil
+}
+
+func (r *rotatingKeys) rotate() { r.current = (r.current + 1) % len(r.keys) }
+
+func TestKeyLookupFunc(t *testing.T) {
+	key, err := KeyLookupFunc(crypto.GetFakeAPIKey).Key(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, crypto.TestPrivateKey, key)
+
+	_, err = KeyLookupFunc(func(*jwt.Token) (interface{}, error) {
+		return nil, errors.New("no key")
+	}).Key(nil)
+	assert.NotNil(t, err)
+
+	_, err = KeyLookupFunc(func(*jwt.Token) (interface{}, error) {
+		return "not bytes", nil
+	}).Key(nil)
+	assert.NotNil(t, err)
+}
+
+func TestPermissionsHandler_rotatingKeys(t *testing.T) {
+	var keys = &rotatingKeys{keys: [][]byte{[]byte("first_key"), []byte("second_key")}}
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{Keys: keys})
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func() string {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return getTokenFromResponse(rec.Result().Body)
+	}
+	var validate = func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/user/validate", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// keys are looked up for signing and validation
+	var token = login()
+	assert.Equal(t, http.StatusOK, validate(token))
+	assert.True(t, keys.calls >= 2)
+
+	// tokens signed with a rotated-out key are rejected
+	keys.rotate()
+	assert.Equal(t, http.StatusUnauthorized, validate(token))
+
+	// tokens signed with the new key are accepted
+	assert.Equal(t, http.StatusOK, validate(login()))
+}
+
+func TestPermissionsHandler_RSAKeyLookup(t *testing.T) {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Keys: NewRSAKeyLookup(signingKey, KeyLookupFunc(crypto.GetFakeAPIKey)),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var validate = func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/user/validate", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// session tokens are signed with RS256
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var token = getTokenFromResponse(rec.Result().Body)
+	assert.Equal(t, http.StatusOK, validate(token))
+
+	// the token can be validated with only the published public key
+	req = httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var jwks crypto.JWKS
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&jwks))
+	assert.Len(t, jwks.Keys, 1)
+	claims, err := crypto.ValidateToken(token, func(tok *jwt.Token) (interface{}, error) {
+		assert.Equal(t, jwks.Keys[0].KeyID, tok.Header["kid"])
+		return &signingKey.PublicKey, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "bobheadxi", claims.User)
+
+	// HMAC master tokens are still accepted
+	assert.Equal(t, http.StatusOK, validate(crypto.TestMasterToken))
+
+	// tokens that swap in HMAC, keyed with the public key, are rejected
+	publicKey, err := x509.MarshalPKIXPublicKey(&signingKey.PublicKey)
+	assert.Nil(t, err)
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &crypto.TokenClaims{
+		SessionID: claims.SessionID, User: "bobheadxi", Admin: true,
+		Expiry: claims.Expiry, Version: claims.Version, Role: api.RoleAdmin,
+	}).SignedString(publicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, validate(forged))
+}
+
+func TestPermissionsHandler_jwksHMAC(t *testing.T) {
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Keys: KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+
+	// no keys are published for HMAC tokens
+	req := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var jwks crypto.JWKS
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&jwks))
+	assert.Empty(t, jwks.Keys)
+}
+
+func TestPermissionsHandler_jwksRotation(t *testing.T) {
+	previousKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	currentKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	var keys = NewRSAKeyLookup(previousKey, KeyLookupFunc(crypto.GetFakeAPIKey))
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{Keys: keys})
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func() string {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+		assert.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return getTokenFromResponse(rec.Result().Body)
+	}
+	var validate = func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/user/validate", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	var keyIDs = func() []string {
+		req := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var jwks crypto.JWKS
+		assert.Nil(t, json.NewDecoder(rec.Body).Decode(&jwks))
+		var ids = make([]string, 0, len(jwks.Keys))
+		for _, k := range jwks.Keys {
+			ids = append(ids, k.KeyID)
+		}
+		return ids
+	}
+	var keyIDOf = func(token string) string {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, &crypto.TokenClaims{})
+		assert.Nil(t, err)
+		kid, _ := parsed.Header["kid"].(string)
+		return kid
+	}
+
+	var oldToken = login()
+	assert.Equal(t, []string{keyIDOf(oldToken)}, keyIDs())
+
+	// during the overlap, both keys are published and accepted, and freshly
+	// minted tokens are signed with the new key
+	keys.Rotate(currentKey, time.Hour)
+	var newToken = login()
+	assert.Equal(t, crypto.KeyID(&currentKey.PublicKey), keyIDOf(newToken))
+	assert.Equal(t, []string{keyIDOf(newToken), keyIDOf(oldToken)}, keyIDs())
+	assert.Equal(t, http.StatusOK, validate(oldToken))
+	assert.Equal(t, http.StatusOK, validate(newToken))
+
+	// once the overlap ends, only the new key is published and accepted
+	keys.previous[0].until = time.Now().Add(-time.Second)
+	assert.Equal(t, []string{keyIDOf(newToken)}, keyIDs())
+	assert.Equal(t, http.StatusUnauthorized, validate(oldToken))
+	assert.Equal(t, http.StatusOK, validate(newToken))
+}
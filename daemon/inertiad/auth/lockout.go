@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLockoutThreshold is the default number of consecutive failed
+	// logins after which a username is locked out
+	DefaultLockoutThreshold = 5
+
+	// DefaultLockoutCooldown is the default duration for which a username
+	// stays locked out
+	DefaultLockoutCooldown = 15 * time.Minute
+
+	// DefaultLoginBackoffCap is the default longest delay enforced between
+	// failed logins for a username, when login backoff is enabled
+	DefaultLoginBackoffCap = 5 * time.Minute
+)
+
+// loginFailures tracks consecutive failed logins for a username
+type loginFailures struct {
+	count       int
+	lastFailed  time.Time
+	lockedUntil time.Time
+
+	// retryAt is when the next login attempt is accepted, if login backoff
+	// is enabled
+	retryAt time.Time
+}
+
+// loginLimiter locks out usernames after too many consecutive failed logins,
+// and optionally enforces a delay after each failed login that doubles with
+// every consecutive failure. Failures are tracked by username rather than by
+// client address, so that attackers cannot get around lockouts by spreading
+// attempts across many addresses.
+type loginLimiter struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	// backoff is the delay enforced after the first failed login, and
+	// backoffCap is the longest delay enforced - if backoff is zero, no
+	// delays are enforced
+	backoff    time.Duration
+	backoffCap time.Duration
+
+	mux      sync.Mutex
+	failures map[string]*loginFailures
+}
+
+// newLoginLimiter creates a limiter that locks out usernames for the given
+// cooldown once threshold consecutive logins have failed. Invalid values fall
+// back to defaults.
+func newLoginLimiter(threshold int, cooldown time.Duration) *loginLimiter {
+	if threshold <= 0 {
+		threshold = DefaultLockoutThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultLockoutCooldown
+	}
+	return &loginLimiter{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		failures:  make(map[string]*loginFailures),
+	}
+}
+
+// SetBackoff enforces the given delay after a failed login for a username, and
+// doubles it with every consecutive failure up to the given cap, before the
+// next attempt is accepted. If the delay is zero, no delays are enforced, and
+// if the cap is invalid, DefaultLoginBackoffCap is used.
+func (l *loginLimiter) SetBackoff(delay, maxDelay time.Duration) {
+	if maxDelay <= 0 {
+		maxDelay = DefaultLoginBackoffCap
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	l.mux.Lock()
+	l.backoff, l.backoffCap = delay, maxDelay
+	l.mux.Unlock()
+}
+
+// Locked returns how much longer the given username is locked out, or must
+// wait before its next login attempt, for, and false if it can log in now
+func (l *loginLimiter) Locked(username string) (time.Duration, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	var now = l.now()
+	l.prune(now)
+	f, found := l.failures[username]
+	if !found {
+		return 0, false
+	}
+	var until = f.lockedUntil
+	if f.retryAt.After(until) {
+		until = f.retryAt
+	}
+	if !now.Before(until) {
+		return 0, false
+	}
+	return until.Sub(now), true
+}
+
+// Failed records a failed login for the given username, and returns true if
+// the username is now locked out
+func (l *loginLimiter) Failed(username string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	f, found := l.failures[username]
+	if !found {
+		f = &loginFailures{}
+		l.failures[username] = f
+	}
+	f.count++
+	f.lastFailed = l.now()
+	if l.backoff > 0 {
+		f.retryAt = f.lastFailed.Add(l.delay(f.count))
+	}
+	if f.count < l.threshold {
+		return false
+	}
+	f.lockedUntil = f.lastFailed.Add(l.cooldown)
+	return true
+}
+
+// delay returns the delay enforced after the given number of consecutive
+// failed logins. Must be called while holding the lock.
+func (l *loginLimiter) delay(failures int) time.Duration {
+	var delay = l.backoff
+	for i := 1; i < failures && delay < l.backoffCap; i++ {
+		delay *= 2
+	}
+	if delay > l.backoffCap {
+		delay = l.backoffCap
+	}
+	return delay
+}
+
+// Succeeded resets the failed login count, and any delay, for the given
+// username
+func (l *loginLimiter) Succeeded(username string) {
+	l.mux.Lock()
+	delete(l.failures, username)
+	l.mux.Unlock()
+}
+
+// prune discards expired lockouts, and failures that have not been followed
+// by another within the cooldown, after which usernames start over with a
+// clean count. Must be called while holding the lock.
+func (l *loginLimiter) prune(now time.Time) {
+	for username, f := range l.failures {
+		var expiry = f.lockedUntil
+		if expiry.IsZero() {
+			expiry = f.lastFailed.Add(l.cooldown)
+		}
+		if f.retryAt.After(expiry) {
+			expiry = f.retryAt
+		}
+		if !now.Before(expiry) {
+			delete(l.failures, username)
+		}
+	}
+}
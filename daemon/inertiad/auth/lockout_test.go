@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	var (
+		now = time.Now()
+		l   = newLoginLimiter(3, time.Minute)
+	)
+	l.now = func() time.Time { return now }
+
+	// lockout triggers on the third consecutive failure
+	assert.False(t, l.Failed("bob"))
+	assert.False(t, l.Failed("bob"))
+	_, locked := l.Locked("bob")
+	assert.False(t, locked)
+	assert.True(t, l.Failed("bob"))
+	remaining, locked := l.Locked("bob")
+	assert.True(t, locked)
+	assert.Equal(t, time.Minute, remaining)
+
+	// other usernames are unaffected
+	_, locked = l.Locked("alice")
+	assert.False(t, locked)
+
+	// lockout expires after the cooldown, with a clean count
+	now = now.Add(time.Minute)
+	_, locked = l.Locked("bob")
+	assert.False(t, locked)
+	assert.False(t, l.Failed("bob"))
+}
+
+func TestLoginLimiter_Succeeded(t *testing.T) {
+	var l = newLoginLimiter(2, time.Minute)
+	assert.False(t, l.Failed("bob"))
+	l.Succeeded("bob")
+	assert.False(t, l.Failed("bob"))
+	assert.True(t, l.Failed("bob"))
+}
+
+func TestLoginLimiter_backoff(t *testing.T) {
+	var (
+		now = time.Now()
+		l   = newLoginLimiter(10, time.Minute)
+	)
+	l.now = func() time.Time { return now }
+	l.SetBackoff(time.Second, 10*time.Second)
+
+	// the delay grows with each consecutive failure, up to the cap
+	for _, delay := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		assert.False(t, l.Failed("bob"))
+		remaining, locked := l.Locked("bob")
+		assert.True(t, locked)
+		assert.Equal(t, delay*time.Second, remaining)
+		now = now.Add(remaining)
+		_, locked = l.Locked("bob")
+		assert.False(t, locked)
+	}
+
+	// success resets the delay
+	l.Succeeded("bob")
+	l.Failed("bob")
+	remaining, _ := l.Locked("bob")
+	assert.Equal(t, time.Second, remaining)
+
+	// other usernames are unaffected
+	_, locked := l.Locked("alice")
+	assert.False(t, locked)
+}
+
+func TestLoginLimiter_prune(t *testing.T) {
+	var (
+		now = time.Now()
+		l   = newLoginLimiter(0, 0)
+	)
+	l.now = func() time.Time { return now }
+	assert.Equal(t, DefaultLockoutThreshold, l.threshold)
+	assert.Equal(t, DefaultLockoutCooldown, l.cooldown)
+
+	// stale failures are forgotten
+	l.Failed("bob")
+	now = now.Add(DefaultLockoutCooldown)
+	l.Locked("bob")
+	assert.Empty(t, l.failures)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/metrics"
+)
+
+// roleReadOnly labels denials of read-only tokens, which do not have a role
+const roleReadOnly = "read_only"
+
+// metricsNamespace prefixes the names of all auth metrics in registries
+// created by the handler
+const metricsNamespace = "inertia"
+
+var errMetricsRegistered = errors.New("auth metrics are already registered with this registry")
+
+// authMetrics tracks authentication and authorization activity. Like other
+// observations, its methods are no-ops on nil metrics.
+type authMetrics struct {
+	registry *metrics.Registry
+
+	logins       *metrics.Counter
+	loginsFailed *metrics.Counter
+	denied       *metrics.CounterVec
+	duration     *metrics.Histogram
+}
+
+// newAuthMetrics registers auth metrics with the given registry. Each handler
+// must have its own metrics, since the active sessions gauge reads from the
+// handler's sessions, so registering auth metrics with a registry that
+// already has them fails.
+func newAuthMetrics(r *metrics.Registry, sessions *sessionManager) (*authMetrics, error) {
+	if r.Registered("auth_logins_total") {
+		return nil, errMetricsRegistered
+	}
+	r.NewGaugeFunc("auth_sessions_active", "Number of active login sessions.",
+		func() float64 { return float64(sessions.Active()) })
+	return &authMetrics{
+		registry: r,
+		logins: r.NewCounter("auth_logins_total",
+			"Number of successful logins."),
+		loginsFailed: r.NewCounter("auth_login_failures_total",
+			"Number of failed logins."),
+		denied: r.NewCounterVec("auth_denied_total",
+			"Number of requests denied because of the role of the requester.", "role", 0),
+		duration: r.NewHistogram("auth_duration_seconds",
+			"Time taken to authorize requests."),
+	}, nil
+}
+
+// observeLogin records the outcome of a login
+func (m *authMetrics) observeLogin(success bool) {
+	if m == nil {
+		return
+	}
+	if success {
+		m.logins.Inc()
+	} else {
+		m.loginsFailed.Inc()
+	}
+}
+
+// observeDenied records a request denied because of the given role
+func (m *authMetrics) observeDenied(role string) {
+	if m == nil {
+		return
+	}
+	m.denied.With(role).Inc()
+}
+
+// observeDuration records the time taken to authorize a request
+func (m *authMetrics) observeDuration(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.duration.Observe(d.Seconds())
+}
+
+// MetricsHandler serves auth metrics, along with any other metrics in the
+// registry they are registered with, in the Prometheus text format, reporting
+// the given daemon version. It can be attached as a public or restricted
+// handler.
+func (h *PermissionsHandler) MetricsHandler(version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := h.metrics.registry.WritePrometheus(w, version); err != nil {
+			println("failed to write auth metrics: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/metrics"
+)
+
+func TestPermissionsHandler_metrics(t *testing.T) {
+	var registry = metrics.NewRegistry("test")
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Metrics: registry,
+		Keys:    KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetLoginTokenMode(LoginTokenBoth)
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleViewer))
+
+	var login = func(password string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: password})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/user/login", bytes.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// failed login
+	assert.Equal(t, http.StatusUnauthorized, login("notright").Code)
+
+	// successful login
+	rec := login("wowgreat")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token := getTokenFromResponse(rec.Result().Body)
+
+	// viewers cannot manage users
+	req := httptest.NewRequest("POST", "/user/add", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	var snapshot = registry.Snapshot()
+	assert.Equal(t, uint64(1), snapshot["test_auth_login_failures_total"])
+	assert.Equal(t, uint64(1), snapshot["test_auth_logins_total"])
+	assert.Equal(t, uint64(1), snapshot["test_auth_denied_total"])
+	assert.Equal(t, 1, ph.sessions.Active())
+
+	// metrics can be served in the Prometheus text format
+	rec = httptest.NewRecorder()
+	ph.MetricsHandler("test").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `test_auth_denied_total{role="viewer"} 1`)
+	assert.Contains(t, rec.Body.String(), "test_auth_sessions_active 1")
+	assert.Contains(t, rec.Body.String(), "test_auth_duration_seconds_count 3")
+}
+
+func TestPermissionsHandler_metricsFailedLogin(t *testing.T) {
+	var registry = metrics.NewRegistry("test")
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Metrics: registry,
+		Keys:    KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "notright"})
+	assert.Nil(t, err)
+	req := httptest.NewRequest("POST", "/user/login", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	var snapshot = registry.Snapshot()
+	assert.Equal(t, uint64(1), snapshot["test_auth_login_failures_total"])
+	assert.Equal(t, uint64(0), snapshot["test_auth_logins_total"])
+
+	// handlers cannot share a registry, since the active sessions gauge would
+	// only ever report the first handler's sessions
+	_, err = NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Metrics: registry,
+		Keys:    KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.NotNil(t, err)
+
+	// handlers without a registry have their own
+	other, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer other.Close()
+	other.metrics.observeLogin(false)
+	assert.Equal(t, uint64(1), registry.Snapshot()["test_auth_login_failures_total"])
+}
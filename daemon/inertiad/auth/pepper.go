@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// Peppers are server-side secrets that passwords are combined with before they
+// are hashed, so that password hashes taken from the user database cannot be
+// cracked without the secrets. Each user records the version of the pepper
+// their password was hashed with, so that peppers can be rotated.
+type Peppers struct {
+	// Current is the version of the pepper that new passwords are hashed with
+	// - if zero, new passwords are not peppered
+	Current int
+
+	// Keys maps pepper versions to secrets. Passwords hashed with a version
+	// that is no longer present can no longer be verified.
+	Keys map[int][]byte
+}
+
+// apply combines the given password with the pepper of the given version,
+// and returns false if there is no such pepper
+func (p Peppers) apply(password string, version int) (string, bool) {
+	if version == 0 {
+		return password, true
+	}
+	pepper, ok := p.Keys[version]
+	if !ok {
+		return "", false
+	}
+	return crypto.PepperPassword(password, pepper), true
+}
+
+// SetPasswordPeppers configures the secrets that passwords are combined with
+// before they are hashed. Existing passwords are verified with the pepper they
+// were hashed with, and are rehashed with the current pepper the next time
+// their user logs in.
+func (h *PermissionsHandler) SetPasswordPeppers(peppers Peppers) {
+	h.users.peppers = peppers
+}
+
+// hashPassword hashes the given password with the current pepper, and returns
+// the hash along with the version of the pepper used
+func (m *userManager) hashPassword(password string) (string, int, error) {
+	var version = m.peppers.Current
+	peppered, ok := m.peppers.apply(password, version)
+	if !ok {
+		return "", 0, errPepperNotFound
+	}
+	hash, err := crypto.HashPasswordWithCost(peppered, m.hashCost)
+	return hash, version, err
+}
+
+// correctPassword checks the given password against the given user's password
+// hash, using the pepper it was hashed with
+func (m *userManager) correctPassword(props *userProps, password string) bool {
+	peppered, ok := m.peppers.apply(password, props.PepperVersion)
+	if !ok {
+		m.decoy.Compare(password)
+		return false
+	}
+	return crypto.CorrectPassword(props.HashedPassword, peppered)
+}
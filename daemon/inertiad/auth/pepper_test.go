@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestPeppers(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	manager.peppers = Peppers{Current: 1, Keys: map[int][]byte{1: []byte("first_pepper")}}
+
+	var stored = func(username string) *userProps {
+		props, correct, err := manager.IsCorrectCredentials(username, "wrong_password")
+		assert.Nil(t, err)
+		assert.False(t, correct)
+		return props
+	}
+
+	// peppered passwords verify, but their hashes are useless without the pepper
+	assert.Nil(t, manager.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	_, correct, err := manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	var props = stored("bobheadxi")
+	assert.Equal(t, 1, props.PepperVersion)
+	assert.False(t, crypto.CorrectPassword(props.HashedPassword, "wowgreat"))
+
+	// passwords hashed under an old pepper validate during a rotation, and are
+	// rehashed with the current pepper on login
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleUser))
+	manager.peppers = Peppers{Current: 2, Keys: map[int][]byte{
+		1: []byte("first_pepper"),
+		2: []byte("second_pepper"),
+	}}
+	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	assert.Equal(t, 2, stored("bobheadxi").PepperVersion)
+	assert.Equal(t, 1, stored("chadlagore").PepperVersion)
+
+	// new passwords use the current pepper
+	assert.Nil(t, manager.UpdatePassword("chadlagore", "chadlad2", anyRevision))
+	assert.Equal(t, 2, stored("chadlagore").PepperVersion)
+	assert.Nil(t, manager.AddUser("yaoharry", "harryharry", api.RoleUser))
+	assert.Equal(t, 2, stored("yaoharry").PepperVersion)
+
+	// once the old pepper is retired, only passwords hashed under it fail
+	assert.Nil(t, manager.AddUser("oldtimer", "oldpassword", api.RoleUser))
+	manager.peppers = Peppers{Current: 3, Keys: map[int][]byte{
+		2: []byte("second_pepper"),
+		3: []byte("third_pepper"),
+	}}
+	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	manager.peppers.Keys = map[int][]byte{3: []byte("third_pepper")}
+	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	_, correct, err = manager.IsCorrectCredentials("oldtimer", "oldpassword")
+	assert.Nil(t, err)
+	assert.False(t, correct)
+
+	// passwords cannot be hashed without the current pepper
+	manager.peppers = Peppers{Current: 4}
+	assert.Equal(t, errPepperNotFound, manager.AddUser("nopepper", "wowgreat", api.RoleUser))
+}
+
+func TestPeppersUnpeppered(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	// passwords hashed before a pepper was set still validate, and are
+	// peppered on login
+	assert.Nil(t, manager.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	manager.peppers = Peppers{Current: 1, Keys: map[int][]byte{1: []byte("first_pepper")}}
+	props, correct, err := manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	assert.Equal(t, 1, props.PepperVersion)
+	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+}
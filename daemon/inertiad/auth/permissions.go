@@ -3,13 +3,19 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
@@ -17,6 +23,7 @@ import (
 
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/metrics"
 )
 
 // ctxKey represents keys used in request contexts
@@ -24,58 +31,236 @@ type ctxKey int
 
 const (
 	ctxUsername ctxKey = iota
+	ctxAuditEvent
 )
 
+// routeMethods are the methods checked for when listing the methods allowed
+// for a path, in the order they are listed
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// DefaultRealm is the default realm that clients are asked to authenticate
+// for in unauthorized responses
+const DefaultRealm = "inertia"
+
 // PermissionsHandler handles users, permissions, and sessions on top
 // of an http.ServeMux. It is used for Inertia Web.
 type PermissionsHandler struct {
 	domain     string
+	realm      string
 	users      *userManager
 	sessions   *sessionManager
+	logins     *loginLimiter
+	limiter    *rateLimiter
+	ips        *ipFilter
+	auditor    *auditor
+	drain      *drainer
+	metrics    *authMetrics
+	cookies    CookieConfig
 	mux        *chi.Mux
 	userPaths  []string
 	adminPaths []string
+
+	// readOnlyPaths are user-restricted paths that read-only tokens and
+	// viewers may access with safe methods
+	readOnlyPaths []string
+
+	// viewerPaths are user-restricted paths that viewers may access
+	viewerPaths []string
+
+	// scopes maps paths to the scope that scoped tokens need to access them
+	scopes map[string]string
+
+	// trustedProxies is the number of proxies whose X-Forwarded-For entries
+	// identify clients, for filtering, rate limiting, and sessions
+	trustedProxies int
+
+	// requestTimeout limits how long requests to the handler's own endpoints
+	// may take, including authorization - if zero, there is no limit
+	requestTimeout time.Duration
+
+	// requestID generates IDs for requests that do not supply one
+	requestID func() string
+
+	// resetTimeout is how long password reset tokens are valid for
+	resetTimeout time.Duration
+
+	// upgradePaths are user-restricted paths that websocket upgrade requests
+	// may authenticate to with a token in the tokenParam query parameter
+	upgradePaths []string
+	tokenParam   string
+
+	// loginToken determines how session tokens are returned to clients
+	loginToken LoginTokenMode
+
+	// authorizer applies custom authorization policies, if set
+	authorizer Authorizer
+
+	// https configures HSTS and how plaintext requests are handled
+	https HTTPSPolicy
+
+	// clock tells the time when issuing and validating tokens
+	clock Clock
+}
+
+// HandlerOptions configures a PermissionsHandler. The zero value uses the
+// defaults for everything.
+type HandlerOptions struct {
+	// SessionTimeout is how long session tokens last - if zero,
+	// DefaultSessionTimeout is used
+	SessionTimeout time.Duration
+
+	// HashCost is the bcrypt cost passwords are hashed with - if it is zero or
+	// invalid, bcrypt's default cost is used
+	HashCost int
+
+	// Cookies are the attributes session cookies are set with - if nil, those
+	// from DefaultCookieConfig are used
+	Cookies *CookieConfig
+
+	// Audit receives audit events for logins, access decisions, and user
+	// management as JSON lines - if nil, no events are kept
+	Audit io.Writer
+
+	// RateLimit throttles requests from each client address - if nil,
+	// requests are not throttled
+	RateLimit *RateLimit
+
+	// IPFilter refuses requests from client addresses that it does not allow -
+	// if nil, requests are not filtered
+	IPFilter *IPFilter
+
+	// Metrics is the registry that metrics for logins, denied requests, active
+	// sessions, and the time taken to authorize requests are registered with -
+	// if nil, the handler uses a registry of its own. Either way, they are
+	// served by MetricsHandler.
+	Metrics *metrics.Registry
+
+	// Realm is the realm unauthorized responses ask clients to authenticate
+	// for - if empty, DefaultRealm is used
+	Realm string
+
+	// Keys signs and validates tokens - if nil, crypto.GetAPIPrivateKey is
+	// used. Use an RSAKeyLookup to sign tokens with RS256 instead of HMAC.
+	Keys KeyLookup
 }
 
 // NewPermissionsHandler returns a new handler for authenticating users and
 // handling user administration. It also serves as the primary server for the
-// Inertia daemon.
+// Inertia daemon. Users are stored in the database at dbPath, or in memory if
+// dbPath is MemoryStore.
 func NewPermissionsHandler(
-	dbPath, hostDomain string, timeout int,
-	keyLookup ...func(*jwt.Token) (interface{}, error),
+	dbPath, hostDomain string, opts HandlerOptions,
 ) (*PermissionsHandler, error) {
+	if opts.Realm == "" {
+		opts.Realm = DefaultRealm
+	}
+
+	// Set up IP filter
+	ips, err := newIPFilter(opts.IPFilter)
+	if err != nil {
+		return nil, err
+	}
+	var trustedProxies int
+	if opts.IPFilter != nil {
+		trustedProxies = opts.IPFilter.TrustedProxies
+	}
+
 	// Set up user manager
-	userManager, err := newUserManager(dbPath)
+	userManager, err := newUserManager(dbPath, opts.HashCost)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set up session manager
-	lookup := crypto.GetAPIPrivateKey
-	if len(keyLookup) > 0 {
-		lookup = keyLookup[0]
+	if opts.Keys == nil {
+		opts.Keys = KeyLookupFunc(crypto.GetAPIPrivateKey)
+	}
+	sessionManager := newSessionManager(hostDomain, opts.SessionTimeout, opts.Keys)
+	if opts.Cookies == nil {
+		var defaults = DefaultCookieConfig()
+		opts.Cookies = &defaults
+	}
+	if opts.Cookies.SameSite == http.SameSiteNoneMode {
+		sessionManager.Close()
+		userManager.Close()
+		return nil, errSameSiteNone
+	}
+
+	// Set up metrics
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.NewRegistry(metricsNamespace)
+	}
+	observer, err := newAuthMetrics(opts.Metrics, sessionManager)
+	if err != nil {
+		sessionManager.Close()
+		userManager.Close()
+		return nil, fmt.Errorf("failed to register metrics: %s", err.Error())
 	}
-	sessionManager := newSessionManager(hostDomain, timeout, lookup)
 
 	// Set up handler
+	var userPaths = []string{
+		"/user/validate",
+		"/user/whoami",
+		"/user/refresh",
+		"/user/logoutall",
+		"/user/updatepassword",
+		"/user/totp/enable",
+		"/user/totp/enroll",
+		"/user/totp/confirm",
+		"/user/totp/disable"}
 	var h = &PermissionsHandler{
-		domain:   hostDomain,
-		users:    userManager,
-		sessions: sessionManager,
-		mux:      chi.NewMux(),
+		domain:         hostDomain,
+		realm:          opts.Realm,
+		users:          userManager,
+		sessions:       sessionManager,
+		logins:         newLoginLimiter(DefaultLockoutThreshold, DefaultLockoutCooldown),
+		limiter:        newRateLimiter(opts.RateLimit),
+		ips:            ips,
+		trustedProxies: trustedProxies,
+		auditor:        newAuditor(opts.Audit),
+		drain:          newDrainer(DefaultDrainTimeout),
+		metrics:        observer,
+		cookies:        *opts.Cookies,
+		requestID:      newRequestID,
+		resetTimeout:   DefaultResetTokenTimeout,
+		tokenParam:     DefaultTokenQueryParam,
+		clock:          systemClock{},
+		mux:            chi.NewMux(),
 
 		// paths restricted to users
-		userPaths: []string{
-			"/user/validate",
-			"/user/totp/enable",
-			"/user/totp/disable"},
+		userPaths: userPaths,
+
+		// user-restricted paths that viewers may also access - viewers can
+		// manage their own accounts, so this starts as a copy of userPaths
+		viewerPaths: append([]string{}, userPaths...),
+
+		// user-restricted paths that read-only tokens may also access
+		readOnlyPaths: []string{
+			"/user/whoami"},
+
+		// user management requires the users scope
+		scopes: map[string]string{
+			"/user": api.ScopeUsers},
 
 		// paths restricted to administrators
 		adminPaths: []string{
 			"/user/add",
+			"/user/import",
+			"/user/export",
+			"/user/restore",
 			"/user/remove",
+			"/user/rename",
+			"/user/revoke",
+			"/user/setadmin",
 			"/user/reset",
-			"/user/list"},
+			"/user/forcereset",
+			"/user/resetrequest",
+			"/user/list",
+			"/user/sessions",
+			"/user/tokens"},
 	}
 
 	// Register useful middleware
@@ -84,43 +269,183 @@ func NewPermissionsHandler(
 			AllowedOrigins:   []string{"*"},
 			AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
 			AllowedHeaders:   []string{"*"},
+			ExposedHeaders:   []string{RequestIDHeader},
 			AllowCredentials: true,
 		}).Handler,
-		middleware.RequestID,
 		middleware.RealIP,
 		// TODO: logging middleware
 		middleware.Recoverer)
+	h.mux.MethodNotAllowed(h.methodNotAllowedHandler)
+
+	// Register probes for load balancers
+	h.mux.Get(healthPath, h.healthHandler)
+	h.mux.Head(healthPath, h.healthHandler)
+	h.mux.Get(readyPath, h.readyHandler)
+	h.mux.Head(readyPath, h.readyHandler)
+
+	// Publish the keys that other services can validate tokens with
+	h.mux.Get(JWKSPath, h.jwksHandler)
 
 	// Register all user-related routes that managed by the permissions handler
 	h.mux.Route("/user", func(r chi.Router) {
-		r.Post("/login", h.loginHandler)
-		r.Post("/logout", h.logoutHandler)
+		r.Post("/login", h.audited(AuditLogin, h.loginHandler))
+		r.Post("/logout", h.audited(AuditLogout, h.logoutHandler))
+		r.Post("/resetconfirm", h.audited(AuditResetConfirm, h.resetConfirmHandler))
 
 		// user-only paths
 		r.Get("/validate", h.validateHandler)
+		r.Get("/whoami", h.whoamiHandler)
+		r.Post("/refresh", h.audited(AuditRefresh, h.refreshHandler))
+		r.Post("/logoutall", h.audited(AuditLogoutAll, h.logoutAllHandler))
+		r.Post("/updatepassword", h.audited(AuditPasswordUpdate, h.updatePasswordHandler))
 		r.Route("/totp", func(r chi.Router) {
-			r.Post("/enable", h.enableTotpHandler)
-			r.Post("/disable", h.disableTotpHandler)
+			r.Post("/enable", h.audited(AuditTotpEnable, h.enableTotpHandler))
+			r.Post("/enroll", h.audited(AuditTotpEnroll, h.enrollTotpHandler))
+			r.Post("/confirm", h.audited(AuditTotpEnable, h.confirmTotpHandler))
+			r.Post("/disable", h.audited(AuditTotpDisable, h.disableTotpHandler))
 		})
 
 		// admin-only paths
 		r.Get("/list", h.listUsersHandler)
-		r.Post("/add", h.addUserHandler)
-		r.Post("/remove", h.removeUserHandler)
-		r.Post("/reset", h.resetUsersHandler)
+		r.Get("/sessions", h.listSessionsHandler)
+		r.Post("/add", h.audited(AuditUserAdd, h.addUserHandler))
+		r.Post("/import", h.audited(AuditUserImport, h.importUsersHandler))
+		r.Get("/export", h.audited(AuditUserExport, h.exportUsersHandler))
+		r.Post("/restore", h.audited(AuditUserRestore, h.restoreUsersHandler))
+		r.Post("/remove", h.audited(AuditUserRemove, h.removeUserHandler))
+		r.Post("/rename", h.audited(AuditUserRename, h.renameUserHandler))
+		r.Post("/revoke", h.audited(AuditUserRevoke, h.revokeSessionsHandler))
+		r.Post("/setadmin", h.audited(AuditUserSetAdmin, h.setAdminHandler))
+		r.Post("/reset", h.audited(AuditUserReset, h.resetUsersHandler))
+		r.Post("/forcereset", h.audited(AuditUserForceReset, h.forceResetHandler))
+		r.Post("/resetrequest", h.audited(AuditResetRequest, h.resetRequestHandler))
+		r.Route("/tokens", func(r chi.Router) {
+			r.Get("/", h.listTokensHandler)
+			r.Post("/add", h.audited(AuditTokenAdd, h.addTokenHandler))
+			r.Post("/revoke", h.audited(AuditTokenRevoke, h.revokeTokenHandler))
+		})
 	})
 
 	return h, nil
 }
 
-// Close releases resources held by the PermissionsHandler
+// SetLoginLockout configures the handler to lock out a username for the given
+// cooldown once threshold consecutive logins have failed. Invalid values fall
+// back to DefaultLockoutThreshold and DefaultLockoutCooldown.
+func (h *PermissionsHandler) SetLoginLockout(threshold int, cooldown time.Duration) {
+	var backoff, backoffCap, now = h.logins.backoff, h.logins.backoffCap, h.logins.now
+	h.logins = newLoginLimiter(threshold, cooldown)
+	h.logins.SetBackoff(backoff, backoffCap)
+	h.logins.now = now
+}
+
+// SetLoginBackoff configures the handler to refuse logins for a username for
+// the given delay after a failed login, doubling the delay with every
+// consecutive failure up to the given cap. Successful logins reset the delay.
+// If the delay is zero, which is the default, no delays are enforced. An
+// invalid cap falls back to DefaultLoginBackoffCap.
+func (h *PermissionsHandler) SetLoginBackoff(delay, maxDelay time.Duration) {
+	h.logins.SetBackoff(delay, maxDelay)
+}
+
+// SetPasswordPolicy configures requirements that new passwords must satisfy.
+// Existing passwords are not affected.
+func (h *PermissionsHandler) SetPasswordPolicy(policy PasswordPolicy) {
+	h.users.policy = policy
+}
+
+// SetUsernamePolicy configures requirements that the usernames of new users
+// must satisfy. Existing users are not affected.
+func (h *PermissionsHandler) SetUsernamePolicy(policy UsernamePolicy) {
+	h.users.usernames = policy
+}
+
+// SetTotpKey configures the symmetric key used to encrypt TOTP secrets. TOTP
+// secrets stored before a key was set are encrypted the next time they are
+// used.
+func (h *PermissionsHandler) SetTotpKey(key []byte) {
+	h.users.totpKey = key
+}
+
+// SetRememberTimeout configures how long session tokens issued to users that
+// asked to be remembered on login are valid for. Invalid values fall back to
+// DefaultRememberTimeout.
+func (h *PermissionsHandler) SetRememberTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultRememberTimeout
+	}
+	h.sessions.rememberTimeout = timeout
+}
+
+// SetSessionStore configures where login sessions are kept. By default,
+// sessions are kept in memory - a store shared between daemons allows any
+// daemon to validate sessions created by another. It must be set before the
+// handler starts serving requests.
+func (h *PermissionsHandler) SetSessionStore(store SessionStore) {
+	h.sessions.store = store
+}
+
+// SetRequestTimeout configures how long authorizing a request, and serving the
+// handler's own endpoints, may take before the request is aborted with
+// http.StatusGatewayTimeout. Attached handlers are not limited, since they may
+// stream responses. Zero disables the timeout.
+func (h *PermissionsHandler) SetRequestTimeout(timeout time.Duration) {
+	h.requestTimeout = timeout
+}
+
+// SetResetTokenTimeout configures how long password reset tokens are valid for.
+// Invalid values fall back to DefaultResetTokenTimeout.
+func (h *PermissionsHandler) SetResetTokenTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultResetTokenTimeout
+	}
+	h.resetTimeout = timeout
+}
+
+// SetRequestIDGenerator configures how IDs are generated for requests that do
+// not supply one in the X-Request-ID header. By default, random IDs are used.
+func (h *PermissionsHandler) SetRequestIDGenerator(generate func() string) {
+	h.requestID = generate
+}
+
+// SetDrainTimeout configures how long Close waits for in-flight requests to
+// finish. Invalid values fall back to DefaultDrainTimeout.
+func (h *PermissionsHandler) SetDrainTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	h.drain.timeout = timeout
+}
+
+// Close releases resources held by the PermissionsHandler, and waits for
+// pending audit events to be written. New requests are refused, and in-flight
+// requests are given until the drain timeout to finish before the user
+// database is closed. If they do not finish in time, an error is returned and
+// the audit log and user database are only released once they do.
 func (h *PermissionsHandler) Close() error {
+	if !h.drain.Drain() {
+		go func() {
+			h.drain.Wait()
+			h.release()
+		}()
+		return errors.New("timed out waiting for in-flight requests to finish")
+	}
+	return h.release()
+}
+
+// release stops background jobs and closes the audit log and user database
+func (h *PermissionsHandler) release() error {
+	h.auditor.Close()
 	h.sessions.Close()
 	return h.users.Close()
 }
 
 // nolint: gocyclo
 func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Identify the request, so that its response and log lines can be
+	// correlated
+	r = h.withRequestID(w, r)
+
 	// http.StripPrefix removes the leading slash, but in the interest of
 	// maintaining similar behaviour to stdlib handler functions, we manually
 	// add a leading "/" here instead of having users not add a leading "/" on
@@ -131,23 +456,99 @@ func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = path
 	}
 
+	// Tokens in query strings are never passed on, so that they do not end up
+	// in logs - they are only accepted on upgrades to upgrade-capable paths
+	r = h.withQueryToken(r)
+	h.setHSTS(w)
+
+	// Liveness probes are answered for as long as the process is up, even
+	// while shutting down, and are never filtered or throttled
+	if path == healthPath {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// Refuse new requests once the handler is closing
+	if !h.drain.Begin() {
+		render.Render(w, r, res.Err("daemon is shutting down - try again later",
+			http.StatusServiceUnavailable).
+			WithCode(api.ErrCodeShuttingDown))
+		return
+	}
+	defer h.drain.Done()
+
+	// Track the time taken to authorize the request, up until it is handed off
+	// to the requested endpoint
+	var start, handoff = time.Now(), time.Time{}
+	defer func() {
+		if handoff.IsZero() {
+			handoff = time.Now()
+		}
+		h.metrics.observeDuration(handoff.Sub(start))
+	}()
+
+	// Readiness probes are never filtered or throttled either
+	if path == readyPath {
+		handoff = time.Now()
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+
+	// Redirect or refuse plaintext requests if HTTPS is required
+	if !h.enforceHTTPS(w, r) {
+		return
+	}
+
+	// Stop waiting on the user database once the client goes away, or once the
+	// request has taken too long. The timeout does not apply to attached
+	// handlers, which are served with the original request.
+	var next = r
+	if h.requestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	if strings.HasPrefix(path, "/user/") {
+		next = r
+	}
+
 	// Check if this path is restricted
 	adminRestricted := false
 	for _, prefix := range h.adminPaths {
-		if strings.HasPrefix(path, prefix) {
+		if hasPathPrefix(path, prefix) {
 			adminRestricted = true
 		}
 	}
 	userRestricted := false
 	for _, prefix := range h.userPaths {
-		if strings.HasPrefix(path, prefix) {
+		if hasPathPrefix(path, prefix) {
 			userRestricted = true
 		}
 	}
 
+	// Refuse clients from addresses that are not allowed, before anything else
+	var public = !userRestricted && !adminRestricted
+	if h.ips != nil && !(public && h.ips.exemptPublic && !hasPathPrefix(path, "/user")) {
+		if address := clientAddress(r, h.trustedProxies); !h.ips.Allowed(address) {
+			h.deny(w, r, "", res.ErrForbidden("requests from this address are not allowed",
+				"address", address).
+				WithCode(api.ErrCodeAddressDenied))
+			return
+		}
+	}
+
+	// Throttle clients that are making too many requests
+	if h.limiter != nil && !(public && h.limiter.exemptPublic && !hasPathPrefix(path, "/user")) {
+		if wait, ok := h.limiter.Allow(clientAddress(r, h.trustedProxies)); !ok {
+			h.renderRateLimited(w, r, wait)
+			return
+		}
+	}
+
 	// Serve directly if path is public
-	if !userRestricted && !adminRestricted {
-		h.mux.ServeHTTP(w, r)
+	if public {
+		handoff = time.Now()
+		h.mux.ServeHTTP(w, next)
 		return
 	}
 
@@ -156,39 +557,193 @@ func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err {
 		case errSessionNotFound:
-			render.Render(w, r, res.ErrUnauthorized(err.Error()))
+			h.deny(w, r, "", res.ErrUnauthorized(err.Error()))
+		case errSessionExpired:
+			h.deny(w, r, "", res.ErrUnauthorized(err.Error()).
+				WithCode(api.ErrCodeExpiredToken))
+		case errMalformedHeader, errMalformedToken:
+			h.deny(w, r, "", res.ErrUnauthorized(err.Error()).
+				WithCode(api.ErrCodeMalformedToken))
+		case errInvalidSignature:
+			h.deny(w, r, "", res.ErrUnauthorized(err.Error()).
+				WithCode(api.ErrCodeInvalidSignature))
 		default:
-			render.Render(w, r, res.ErrUnauthorized("failed to read token", "error", err))
+			h.deny(w, r, "", res.ErrUnauthorized("failed to read token", "error", err))
 		}
 		return
 	}
 
+	// Requests authenticated by the session cookie must prove they were not
+	// made on behalf of another site
+	if !hasValidCSRFToken(r) {
+		h.deny(w, r, claims.User, res.ErrForbidden("missing or invalid CSRF token").
+			WithCode(api.ErrCodeInvalidCSRF))
+		return
+	}
+
+	// Read-only tokens may only be used on read-only paths, and are checked
+	// for revocation since they are not session-tracked
+	if claims.ReadOnly {
+		token, _ := tokenFromRequest(r)
+		if !h.usersFor(r).IsValidReadOnlyToken(claims.SessionID, token) {
+			if err := r.Context().Err(); err != nil {
+				render.Render(w, r, errInternal(r, "failed to check token", err))
+				return
+			}
+			h.deny(w, r, claims.User, res.ErrUnauthorized("token has been revoked").
+				WithCode(api.ErrCodeRevokedToken))
+			return
+		}
+		if adminRestricted || !h.isReadOnly(path, r.Method) {
+			h.metrics.observeDenied(roleReadOnly)
+			h.deny(w, r, claims.User, res.ErrForbidden("read-only tokens cannot access this endpoint"))
+			return
+		}
+	}
+
+	// Session tokens are revoked once the user's token version changes
+	if !claims.IsMaster() && !claims.ReadOnly {
+		version, err := h.usersFor(r).TokenVersion(claims.User)
+		switch {
+		case err == errUserNotFound:
+			// Tokens outlive their sessions in session stores that fail to
+			// revoke them, so make sure the user has not been removed
+			h.deny(w, r, claims.User, res.ErrUnauthorized("user no longer exists").
+				WithCode(api.ErrCodeUserNotFound))
+			return
+		case err != nil:
+			render.Render(w, r, errInternal(r, "failed to check token version", err))
+			return
+		case claims.Version != version:
+			h.deny(w, r, claims.User, res.ErrUnauthorized("token has been revoked").
+				WithCode(api.ErrCodeRevokedToken))
+			return
+		}
+	}
+
+	// Scoped tokens may only be used on paths that require one of their scopes
+	if len(claims.Scopes) > 0 {
+		if scope := h.scopeOf(path); scope == "" || !claims.HasScope(scope) {
+			h.deny(w, r, claims.User, res.ErrForbidden(
+				"token does not have the scope required for this endpoint",
+				"scope", scope))
+			return
+		}
+	}
+
+	// Viewers may only use viewer paths and read-only paths
+	if roleOf(claims) == api.RoleViewer {
+		if adminRestricted || !(h.isViewable(path) || h.isReadOnly(path, r.Method)) {
+			h.metrics.observeDenied(api.RoleViewer)
+			h.deny(w, r, claims.User, res.ErrForbidden("viewers cannot access this endpoint"))
+			return
+		}
+	}
+
 	// Check if user has sufficient permissions for path
 	if adminRestricted {
-		admin, err := h.users.IsAdmin(claims.User)
+		admin, err := h.usersFor(r).IsAdmin(claims.User)
 		switch {
 		case err != nil:
-			render.Render(w, r, res.ErrInternalServer("failed to check admin status", err))
+			render.Render(w, r, errInternal(r, "failed to check admin status", err))
 			return
 		case !admin:
-			render.Render(w, r, res.ErrForbidden("admin privileges required"))
+			h.metrics.observeDenied(roleOf(claims))
+			h.deny(w, r, claims.User, res.ErrForbidden("admin privileges required"))
+			return
+		}
+	}
+
+	// Apply custom authorization policies
+	if allowed, reason := h.authorize(claims, r); !allowed {
+		if err := r.Context().Err(); err != nil {
+			render.Render(w, r, errInternal(r, "failed to authorize request", err))
 			return
 		}
+		h.metrics.observeDenied(roleOf(claims))
+		h.deny(w, r, claims.User, res.ErrForbidden(reason))
+		return
 	}
 
 	// Attach username to request context so handlers can use it
-	var ctx = context.WithValue(r.Context(), ctxUsername, claims.User)
+	var ctx = context.WithValue(next.Context(), ctxUsername, claims.User)
 
 	// Serve the requested endpoint to token holders
-	h.mux.ServeHTTP(w, r.WithContext(ctx))
+	h.audit(r, AuditEvent{User: claims.User, Action: AuditAccess, Result: AuditResultGranted})
+	handoff = time.Now()
+	h.mux.ServeHTTP(w, next.WithContext(ctx))
+}
+
+// usersFor returns the user manager to use for the given request, which stops
+// waiting on the database once the request is cancelled or times out
+func (h *PermissionsHandler) usersFor(r *http.Request) *userManager {
+	return h.users.withContext(r.Context())
+}
+
+// errInternal describes an internal error that occurred while handling the
+// given request, or a timeout if the request ran out of time
+func errInternal(r *http.Request, message string, err error, kvs ...interface{}) *res.ErrResponse {
+	if r.Context().Err() == context.DeadlineExceeded {
+		return res.Err("request timed out", http.StatusGatewayTimeout).
+			WithCode(api.ErrCodeTimeout)
+	}
+	return res.ErrInternalServer(message, err, kvs...)
+}
+
+// deny records an access denial for the given user, and responds with the
+// given error
+func (h *PermissionsHandler) deny(w http.ResponseWriter, r *http.Request,
+	username string, e *res.ErrResponse) {
+	h.audit(r, AuditEvent{
+		User:   username,
+		Action: AuditAccess,
+		Result: AuditResultDenied,
+		Reason: e.Message,
+	})
+	if e.HTTPStatusCode == http.StatusUnauthorized {
+		h.renderUnauthorized(w, r, e)
+		return
+	}
+	render.Render(w, r, e)
+}
+
+// renderUnauthorized responds with the given unauthorized error, along with a
+// WWW-Authenticate challenge telling the client how to authenticate
+func (h *PermissionsHandler) renderUnauthorized(w http.ResponseWriter, r *http.Request,
+	e *res.ErrResponse) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", h.realm))
+	render.Render(w, r, e)
 }
 
 // AttachPublicHandler attaches given path and handler and makes it publicly available
-func (h *PermissionsHandler) AttachPublicHandler(path string, handler http.Handler) {
-	h.mux.Handle(path, handler)
+func (h *PermissionsHandler) AttachPublicHandler(
+	path string,
+	handler http.Handler,
+	methods ...string,
+) {
+	h.register(path, handler, methods)
+}
+
+// AttachPublicPrefix attaches given handler to the given path prefix, and all
+// paths under it, and makes them publicly available. The prefix is stripped
+// from request paths before they are passed to the handler, so that handlers
+// such as http.FileServer only see the remainder of the path. Trailing slashes
+// in the prefix are ignored - a prefix of "/web/" matches "/web" and
+// "/web/index.html", but not "/webhook".
+func (h *PermissionsHandler) AttachPublicPrefix(
+	prefix string,
+	handler http.Handler,
+	methods ...string,
+) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	var stripped = stripPathPrefix(prefix, handler)
+	h.register(prefix, stripped, methods)
+	h.register(prefix+"/*", stripped, methods)
 }
 
-// AttachPublicHandlerFunc attaches given path and handler and makes it publicly available
+// AttachPublicHandlerFunc attaches given path and handler and makes it publicly
+// available. If methods are given, the handler only serves requests with one
+// of them - this applies to all the Attach* functions.
 func (h *PermissionsHandler) AttachPublicHandlerFunc(
 	path string,
 	handler http.HandlerFunc,
@@ -207,6 +762,29 @@ func (h *PermissionsHandler) AttachUserRestrictedHandlerFunc(
 	h.register(path, handler, methods)
 }
 
+// AttachReadOnlyHandlerFunc attaches and restricts given path and handler to
+// logged in users, and additionally allows read-only tokens to access it with
+// GET requests.
+func (h *PermissionsHandler) AttachReadOnlyHandlerFunc(
+	path string,
+	handler http.HandlerFunc,
+	methods ...string,
+) {
+	h.readOnlyPaths = append(h.readOnlyPaths, path)
+	h.AttachUserRestrictedHandlerFunc(path, handler, methods...)
+}
+
+// AttachViewerRestrictedHandlerFunc attaches and restricts given path and
+// handler to logged in users, including viewers.
+func (h *PermissionsHandler) AttachViewerRestrictedHandlerFunc(
+	path string,
+	handler http.HandlerFunc,
+	methods ...string,
+) {
+	h.viewerPaths = append(h.viewerPaths, path)
+	h.AttachUserRestrictedHandlerFunc(path, handler, methods...)
+}
+
 // AttachAdminRestrictedHandlerFunc attaches and restricts given path and handler to logged in admins.
 func (h *PermissionsHandler) AttachAdminRestrictedHandlerFunc(
 	path string,
@@ -217,19 +795,101 @@ func (h *PermissionsHandler) AttachAdminRestrictedHandlerFunc(
 	h.register(path, handler, methods)
 }
 
-func (h *PermissionsHandler) register(path string, handler http.HandlerFunc, methods []string) {
+// isReadOnly checks if given request path and method may be accessed with a
+// read-only token
+func (h *PermissionsHandler) isReadOnly(path, method string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	for _, prefix := range h.readOnlyPaths {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isViewable checks if given request path may be accessed by viewers
+func (h *PermissionsHandler) isViewable(path string) bool {
+	for _, prefix := range h.viewerPaths {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope restricts scoped tokens from accessing the given paths, and
+// their subpaths, unless they have the given scope. Scoped tokens cannot access
+// paths that do not require a scope at all.
+func (h *PermissionsHandler) RequireScope(scope string, paths ...string) {
+	for _, path := range paths {
+		h.scopes[path] = scope
+	}
+}
+
+// scopeOf returns the scope required to access the given path, or "" if none
+// is. The most specific matching path takes precedence.
+func (h *PermissionsHandler) scopeOf(path string) string {
+	var match, scope string
+	for prefix, s := range h.scopes {
+		if hasPathPrefix(path, prefix) && len(prefix) > len(match) {
+			match, scope = prefix, s
+		}
+	}
+	return scope
+}
+
+// hasPathPrefix returns true if the given path is the given prefix, or a path
+// under it - "/web" is a prefix of "/web/index.html", but not of "/webhook"
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// stripPathPrefix serves requests with the given prefix removed from their path
+func stripPathPrefix(prefix string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var stripped = new(http.Request)
+		*stripped = *r
+		stripped.URL = new(url.URL)
+		*stripped.URL = *r.URL
+		stripped.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		stripped.URL.RawPath = ""
+		handler.ServeHTTP(w, stripped)
+	})
+}
+
+// register attaches the given handler to the given path for the given methods,
+// or for all methods if none are given. Requests to the path with any other
+// method are rejected with http.StatusMethodNotAllowed.
+func (h *PermissionsHandler) register(path string, handler http.Handler, methods []string) {
 	if len(methods) == 0 {
-		h.mux.HandleFunc(path, handler)
-	} else {
-		for _, m := range methods {
-			switch m {
-			case http.MethodGet:
-				h.mux.Get(path, handler)
-			case http.MethodPost:
-				h.mux.Post(path, handler)
-			}
+		h.mux.Handle(path, handler)
+		return
+	}
+	for _, m := range methods {
+		h.mux.Method(m, path, handler)
+	}
+}
+
+// methodNotAllowedHandler rejects requests with methods that are not
+// registered for the requested path, listing the methods that are in the Allow
+// header
+func (h *PermissionsHandler) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	var path = r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	var allowed = make([]string, 0, len(routeMethods))
+	for _, m := range routeMethods {
+		if h.mux.Match(chi.NewRouteContext(), m, path) {
+			allowed = append(allowed, m)
 		}
 	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	render.Render(w, r, res.Err("method not allowed", http.StatusMethodNotAllowed,
+		"allowed", allowed))
 }
 
 func (h *PermissionsHandler) addUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -247,11 +907,27 @@ func (h *PermissionsHandler) addUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Add user (as admin if specified)
-	if err = h.users.AddUser(userReq.Username, userReq.Password, userReq.Admin); err != nil {
-		if crypto.IsCredentialFormatError(err) {
+	auditTarget(r, userReq.Username)
+	if err = h.usersFor(r).AddUserWithEmail(userReq.Username, userReq.Password,
+		userReq.GetRole(), userReq.Email); err != nil {
+		var uerr, isUsernameErr = err.(*UsernamePolicyError)
+		switch perr, isPolicyErr := err.(*PasswordPolicyError); {
+		case isPolicyErr:
+			render.Render(w, r, passwordPolicyErr(perr))
+		case isUsernameErr:
+			render.Render(w, r, usernamePolicyErr(uerr))
+		case crypto.IsCredentialFormatError(err):
 			render.Render(w, r, res.ErrBadRequest("invalid credentials format",
 				"error", err))
-		} else {
+		case err == errUserExists:
+			render.Render(w, r, res.Err(err.Error(), http.StatusConflict,
+				"user", userReq.Username).
+				WithCode(api.ErrCodeUserExists))
+		case err == errInvalidRole:
+			render.Render(w, r, res.ErrBadRequest(err.Error(),
+				"role", userReq.Role).
+				WithCode(api.ErrCodeInvalidRole))
+		default:
 			render.Render(w, r, res.ErrBadRequest("failed to add user",
 				"error", err))
 		}
@@ -262,70 +938,454 @@ func (h *PermissionsHandler) addUserHandler(w http.ResponseWriter, r *http.Reque
 		"user", userReq.Username))
 }
 
-func (h *PermissionsHandler) removeUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Retrieve user details from request
+func (h *PermissionsHandler) exportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	db, err := h.usersFor(r).Export()
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to export users", err))
+		return
+	}
+
+	// Export the snapshot as-is, so that it can be restored directly
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="inertia-users.json"`)
+	json.NewEncoder(w).Encode(db)
+}
+
+func (h *PermissionsHandler) restoreUsersHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		render.Render(w, r, res.ErrBadRequest(err.Error()))
 		return
 	}
 	defer r.Body.Close()
-	var userReq api.UserRequest
-	if err = json.Unmarshal(body, &userReq); err != nil {
+	var restoreReq api.UserRestoreRequest
+	if err = json.Unmarshal(body, &restoreReq); err != nil {
 		render.Render(w, r, res.ErrBadRequest(err.Error()))
 		return
 	}
+	if len(restoreReq.Users) == 0 {
+		render.Render(w, r, res.ErrBadRequest("no users provided"))
+		return
+	}
 
-	// Remove user credentials
-	if err = h.users.RemoveUser(userReq.Username); err != nil {
-		if err == errUserNotFound {
-			render.Render(w, r, res.ErrNotFound(err.Error()))
-		} else {
-			render.Render(w, r, res.ErrInternalServer("failed to remove user", err))
-		}
+	// Users are restored all at once, or not at all
+	failed, err := h.usersFor(r).Restore(restoreReq.UserDatabase, restoreReq.Replace)
+	switch {
+	case err == errInvalidUserRecord:
+		render.Render(w, r, res.ErrBadRequest(err.Error(),
+			"user", failed))
+		return
+	case err == errInvalidRole:
+		render.Render(w, r, res.ErrBadRequest(err.Error(),
+			"user", failed).
+			WithCode(api.ErrCodeInvalidRole))
+		return
+	case err != nil:
+		render.Render(w, r, errInternal(r, "failed to restore users", err))
 		return
 	}
 
-	// End user sessions
-	h.sessions.EndAllUserSessions(userReq.Username)
+	// Restored users may have changed, so make sure everyone logs in again
+	if err := h.sessions.EndAllSessions(); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end sessions", err))
+		return
+	}
 
-	render.Render(w, r, res.MsgOK("user succesfully removed",
-		"user", userReq.Username))
+	render.Render(w, r, res.MsgOK("users successfully restored",
+		"restored", len(restoreReq.Users),
+		"replaced", restoreReq.Replace))
 }
 
-func (h *PermissionsHandler) enableTotpHandler(w http.ResponseWriter, r *http.Request) {
-	userReq, err := readCredentials(r)
+func (h *PermissionsHandler) importUsersHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
 	}
-
-	// Check if password is correct (we do this first because we don't want to
-	// reveal information about the user to the requester before they are
-	// authenticated)
-	_, correct, err := h.users.IsCorrectCredentials(
-		userReq.Username, userReq.Password)
-	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to check credentials", err))
+	defer r.Body.Close()
+	var userReqs []api.UserRequest
+	if err = json.Unmarshal(body, &userReqs); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
 		return
-	} else if !correct {
-		render.Render(w, r, res.ErrUnauthorized("invalid credentials provided"))
+	}
+	if len(userReqs) == 0 {
+		render.Render(w, r, res.ErrBadRequest("no users provided"))
 		return
 	}
 
-	// Make sure the user does not already have TOTP enabled
-	totpEnabled, err := h.users.IsTotpEnabled(userReq.Username)
+	// Users are added all at once, or not at all
+	var result = api.UserImportResult{Created: []string{}}
+	failed, err := h.usersFor(r).ImportUsers(userReqs)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to check 2FA status", err))
-		return
-	} else if totpEnabled {
-		render.Render(w, r, res.Err("TOTP is already enabled on this user", http.StatusConflict,
-			"user", userReq.Username))
+		result.FailedIndex = &failed
+		var msg = fmt.Sprintf("failed to import user %d (%s) - no users were added",
+			failed, userReqs[failed].Username)
+		var uerr, isUsernameErr = err.(*UsernamePolicyError)
+		switch perr, isPolicyErr := err.(*PasswordPolicyError); {
+		case isPolicyErr:
+			render.Render(w, r, res.ErrBadRequest(msg,
+				"error", err,
+				"rule", perr.Rule,
+				"import", result).
+				WithCode(api.ErrCodePasswordPolicy))
+		case isUsernameErr:
+			render.Render(w, r, res.ErrBadRequest(msg,
+				"error", err,
+				"rule", uerr.Rule,
+				"import", result).
+				WithCode(api.ErrCodeUsernamePolicy))
+		case crypto.IsCredentialFormatError(err):
+			render.Render(w, r, res.ErrBadRequest(msg,
+				"error", err,
+				"import", result))
+		case err == errUserExists:
+			render.Render(w, r, res.Err(msg, http.StatusConflict,
+				"error", err,
+				"import", result).
+				WithCode(api.ErrCodeUserExists))
+		case err == errInvalidRole:
+			render.Render(w, r, res.ErrBadRequest(msg,
+				"error", err,
+				"import", result).
+				WithCode(api.ErrCodeInvalidRole))
+		default:
+			render.Render(w, r, errInternal(r, msg, err,
+				"import", result))
+		}
 		return
 	}
 
-	totpSecret, backupCodes, err := h.users.EnableTotp(userReq.Username)
+	for _, u := range userReqs {
+		result.Created = append(result.Created, u.Username)
+	}
+	render.Render(w, r, res.Msg("users successfully imported", http.StatusCreated,
+		"import", result))
+}
+
+func (h *PermissionsHandler) removeUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Retrieve user details from request
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to create TOTP keys", err))
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+	var userReq api.UserRequest
+	if err = json.Unmarshal(body, &userReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Remove user credentials
+	auditTarget(r, userReq.Username)
+	if err = h.usersFor(r).RemoveUser(userReq.Username); err != nil {
+		if err == errUserNotFound {
+			render.Render(w, r, res.ErrNotFound(err.Error()))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to remove user", err))
+		}
+		return
+	}
+
+	// End user sessions
+	if err := h.sessions.EndAllUserSessions(userReq.Username); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end user sessions", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("user succesfully removed",
+		"user", userReq.Username))
+}
+
+func (h *PermissionsHandler) revokeSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	var userReq api.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+	if userReq.Username == "" {
+		render.Render(w, r, res.ErrBadRequest("a username is required"))
+		return
+	}
+
+	// Invalidate all tokens issued to the user, and drop their sessions
+	auditTarget(r, userReq.Username)
+	if _, err := h.usersFor(r).RevokeTokens(userReq.Username); err != nil {
+		if err == errUserNotFound {
+			render.Render(w, r, res.ErrNotFound(err.Error()))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to revoke sessions", err))
+		}
+		return
+	}
+	if err := h.sessions.EndAllUserSessions(userReq.Username); err != nil {
+		render.Render(w, r, errInternal(r, "failed to revoke sessions", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("user sessions revoked",
+		"user", userReq.Username))
+}
+
+func (h *PermissionsHandler) renameUserHandler(w http.ResponseWriter, r *http.Request) {
+	var userReq api.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+	if userReq.Username == "" || userReq.NewUsername == "" {
+		render.Render(w, r, res.ErrBadRequest("a username and new username are required"))
+		return
+	}
+
+	revision, ok := requireRevision(w, r, userReq.Username, userReq.Revision)
+	if !ok {
+		return
+	}
+
+	auditTarget(r, userReq.Username)
+	err := h.usersFor(r).RenameUser(userReq.Username, userReq.NewUsername, revision)
+	if uerr, ok := err.(*UsernamePolicyError); ok {
+		render.Render(w, r, usernamePolicyErr(uerr))
+		return
+	}
+	switch err {
+	case nil:
+	case errUserNotFound:
+		render.Render(w, r, res.ErrNotFound(err.Error()))
+		return
+	case errUserExists:
+		render.Render(w, r, res.Err(err.Error(), http.StatusConflict,
+			"user", userReq.NewUsername).
+			WithCode(api.ErrCodeUserExists))
+		return
+	case errStaleRevision:
+		render.Render(w, r, staleRevisionErr(userReq.Username))
+		return
+	default:
+		render.Render(w, r, errInternal(r, "failed to rename user", err))
+		return
+	}
+
+	// Sessions are tracked by username, so drop those under the old name
+	if err := h.sessions.EndAllUserSessions(userReq.Username); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end user sessions", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("user renamed",
+		"user", userReq.NewUsername))
+}
+
+func (h *PermissionsHandler) setAdminHandler(w http.ResponseWriter, r *http.Request) {
+	var userReq api.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+	if userReq.Username == "" {
+		render.Render(w, r, res.ErrBadRequest("a username is required"))
+		return
+	}
+
+	revision, ok := requireRevision(w, r, userReq.Username, userReq.Revision)
+	if !ok {
+		return
+	}
+
+	auditTarget(r, userReq.Username)
+	changed, err := h.usersFor(r).SetAdmin(userReq.Username, userReq.Admin, revision)
+	switch err {
+	case nil:
+	case errUserNotFound:
+		render.Render(w, r, res.ErrNotFound(err.Error()))
+		return
+	case errStaleRevision:
+		render.Render(w, r, staleRevisionErr(userReq.Username))
+		return
+	case errLastAdmin:
+		render.Render(w, r, res.Err(err.Error(), http.StatusConflict,
+			"user", userReq.Username).
+			WithCode(api.ErrCodeLastAdmin))
+		return
+	default:
+		render.Render(w, r, errInternal(r, "failed to update user", err))
+		return
+	}
+
+	// Sessions carry the user's role, so drop them if it has changed
+	if changed {
+		if err := h.sessions.EndAllUserSessions(userReq.Username); err != nil {
+			render.Render(w, r, errInternal(r, "failed to end user sessions", err))
+			return
+		}
+	}
+
+	render.Render(w, r, res.MsgOK("user updated",
+		"user", userReq.Username,
+		"admin", userReq.Admin))
+}
+
+func (h *PermissionsHandler) addTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var tokenReq api.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+
+	auditTarget(r, tokenReq.Name)
+	id, token, err := h.usersFor(r).AddReadOnlyToken(tokenReq.Name, h.sessions.keys)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest("failed to create token",
+			"error", err))
+		return
+	}
+
+	// Only a hash of the token is kept, so this is the only time it is shown
+	render.Render(w, r, res.Msg("read-only token created", http.StatusCreated,
+		"id", id,
+		"token", token))
+}
+
+func (h *PermissionsHandler) revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var tokenReq api.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+
+	auditTarget(r, tokenReq.ID)
+	if err := h.usersFor(r).RemoveReadOnlyToken(tokenReq.ID); err != nil {
+		if err == errTokenNotFound {
+			render.Render(w, r, res.ErrNotFound(err.Error(), "id", tokenReq.ID))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to revoke token", err))
+		}
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("read-only token revoked",
+		"id", tokenReq.ID))
+}
+
+func (h *PermissionsHandler) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.usersFor(r).ReadOnlyTokenList()
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to retrieve tokens", err))
+		return
+	}
+	render.Render(w, r, res.MsgOK("read-only tokens retrieved",
+		"tokens", tokens))
+}
+
+func (h *PermissionsHandler) updatePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value(ctxUsername).(string)
+	userReq, err := readCredentials(r)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	if userReq.Password == "" || userReq.NewPassword == "" {
+		render.Render(w, r, res.ErrBadRequest("current and new passwords are required"))
+		return
+	}
+	revision, ok := requireRevision(w, r, username, userReq.Revision)
+	if !ok {
+		return
+	}
+	if err := h.usersFor(r).validateCredentials(username, userReq.NewPassword); err != nil {
+		if perr, ok := err.(*PasswordPolicyError); ok {
+			render.Render(w, r, passwordPolicyErr(perr))
+		} else {
+			render.Render(w, r, res.ErrBadRequest(err.Error()))
+		}
+		return
+	}
+
+	// Make sure the user knows their current password, so that a stolen
+	// session cannot be used to take over the account. Wrong passwords count
+	// towards the same lockout as failed logins, so that this cannot be used
+	// to guess passwords either.
+	if remaining, locked := h.logins.Locked(username); locked {
+		h.renderLockedOut(w, r, remaining)
+		return
+	}
+	_, correct, err := h.usersFor(r).IsCorrectCredentials(username, userReq.Password)
+	switch {
+	case err == errUserNotFound:
+		render.Render(w, r, res.ErrNotFound(err.Error()))
+		return
+	case err != nil:
+		render.Render(w, r, errInternal(r, "failed to check credentials", err))
+		return
+	case !correct:
+		if h.logins.Failed(username) {
+			h.renderLockedOut(w, r, h.logins.cooldown)
+			return
+		}
+		if wait, ok := h.logins.Locked(username); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+		render.Render(w, r, res.ErrForbidden("current password is incorrect").
+			WithCode(api.ErrCodeInvalidCredentials))
+		return
+	}
+	h.logins.Succeeded(username)
+
+	if err := h.usersFor(r).UpdatePassword(username, userReq.NewPassword,
+		revision); err != nil {
+		if err == errStaleRevision {
+			render.Render(w, r, staleRevisionErr(username))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to update password", err))
+		}
+		return
+	}
+	render.Render(w, r, res.MsgOK("password updated",
+		"user", username))
+}
+
+func (h *PermissionsHandler) enableTotpHandler(w http.ResponseWriter, r *http.Request) {
+	userReq, err := readCredentials(r)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Check if password is correct (we do this first because we don't want to
+	// reveal information about the user to the requester before they are
+	// authenticated)
+	auditTarget(r, userReq.Username)
+	_, correct, err := h.usersFor(r).IsCorrectCredentials(
+		userReq.Username, userReq.Password)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to check credentials", err))
+		return
+	} else if !correct {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("invalid credentials provided").
+			WithCode(api.ErrCodeInvalidCredentials))
+		return
+	}
+
+	// Make sure the user does not already have TOTP enabled
+	totpEnabled, err := h.usersFor(r).IsTotpEnabled(userReq.Username)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to check 2FA status", err))
+		return
+	} else if totpEnabled {
+		render.Render(w, r, res.Err("TOTP is already enabled on this user", http.StatusConflict,
+			"user", userReq.Username))
+		return
+	}
+
+	totpSecret, backupCodes, err := h.usersFor(r).EnableTotp(userReq.Username)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to create TOTP keys", err))
 		return
 	}
 
@@ -336,12 +1396,93 @@ func (h *PermissionsHandler) enableTotpHandler(w http.ResponseWriter, r *http.Re
 		}))
 }
 
+func (h *PermissionsHandler) enrollTotpHandler(w http.ResponseWriter, r *http.Request) {
+	userReq, err := readCredentials(r)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Check if password is correct before revealing anything about the user
+	auditTarget(r, userReq.Username)
+	_, correct, err := h.usersFor(r).IsCorrectCredentials(
+		userReq.Username, userReq.Password)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to check credentials", err))
+		return
+	} else if !correct {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("invalid credentials provided").
+			WithCode(api.ErrCodeInvalidCredentials))
+		return
+	}
+
+	totpSecret, uri, err := h.usersFor(r).EnrollTotp(userReq.Username)
+	switch err {
+	case nil:
+	case errTotpEnabled:
+		render.Render(w, r, res.Err(err.Error(), http.StatusConflict,
+			"user", userReq.Username))
+		return
+	default:
+		render.Render(w, r, errInternal(r, "failed to create TOTP keys", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK(
+		"TOTP enrollment started - confirm it with a code from your authenticator app",
+		"totp", &api.TotpResponse{
+			TotpSecret: totpSecret,
+			URI:        uri,
+		}))
+}
+
+func (h *PermissionsHandler) confirmTotpHandler(w http.ResponseWriter, r *http.Request) {
+	userReq, err := readCredentials(r)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Check if password is correct before revealing anything about the user
+	auditTarget(r, userReq.Username)
+	_, correct, err := h.usersFor(r).IsCorrectCredentials(
+		userReq.Username, userReq.Password)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to check credentials", err))
+		return
+	} else if !correct {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("invalid credentials provided").
+			WithCode(api.ErrCodeInvalidCredentials))
+		return
+	}
+
+	backupCodes, err := h.usersFor(r).ConfirmTotp(userReq.Username, userReq.Totp)
+	switch err {
+	case nil:
+	case errTotpNotEnrolled:
+		render.Render(w, r, res.Err(err.Error(), http.StatusConflict,
+			"user", userReq.Username))
+		return
+	case errInvalidTotp:
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	default:
+		render.Render(w, r, errInternal(r, "failed to enable TOTP", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("TOTP successfully enabled",
+		"totp", &api.TotpResponse{
+			BackupCodes: backupCodes,
+		}))
+}
+
 func (h *PermissionsHandler) disableTotpHandler(w http.ResponseWriter, r *http.Request) {
 	username := r.Context().Value(ctxUsername).(string)
 	// Make sure that TOTP is actually enabled
-	totpEnabled, err := h.users.IsTotpEnabled(username)
+	totpEnabled, err := h.usersFor(r).IsTotpEnabled(username)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to check 2FA status", err))
+		render.Render(w, r, errInternal(r, "failed to check 2FA status", err))
 		return
 	} else if !totpEnabled {
 		render.Render(w, r, res.Err("TOTP is not enabled on this user", http.StatusConflict,
@@ -349,8 +1490,8 @@ func (h *PermissionsHandler) disableTotpHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if err = h.users.DisableTotp(username); err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to disable 2FA", err))
+	if err = h.usersFor(r).DisableTotp(username); err != nil {
+		render.Render(w, r, errInternal(r, "failed to disable 2FA", err))
 		return
 	}
 
@@ -359,21 +1500,204 @@ func (h *PermissionsHandler) disableTotpHandler(w http.ResponseWriter, r *http.R
 }
 
 func (h *PermissionsHandler) resetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	// Delete all users
-	if err := h.users.Reset(); err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to reset users and sessions", err))
+	var req api.UserResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get(api.DryRun)); dryRun {
+		req.DryRun = true
+	}
+
+	// List who would be deleted, so that the reset can be confirmed
+	if req.DryRun {
+		usernames, confirm, err := h.usersFor(r).ResetPlan()
+		if err != nil {
+			render.Render(w, r, errInternal(r, "failed to list users", err))
+			return
+		}
+		render.Render(w, r, res.MsgOK("dry run - no users were removed",
+			"reset", &api.UserResetPlan{Users: usernames, Confirm: confirm}))
+		return
+	}
+
+	// Delete all users, if the reset has been confirmed
+	if err := h.usersFor(r).ResetConfirmed(req.Confirm); err != nil {
+		if err == errResetNotConfirmed {
+			render.Render(w, r, res.Err(err.Error()+" - make a dry run to confirm the reset",
+				http.StatusPreconditionFailed).
+				WithCode(api.ErrCodePreconditionFailed))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to reset users and sessions", err))
+		}
 		return
 	}
 
 	// Delete all sessions
-	h.sessions.EndAllSessions()
+	if err := h.sessions.EndAllSessions(); err != nil {
+		render.Render(w, r, errInternal(r, "failed to reset users and sessions", err))
+		return
+	}
 
 	render.Render(w, r, res.MsgOK("user and session databases reset"))
 }
 
+func (h *PermissionsHandler) resetRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var req api.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	if req.Username == "" {
+		render.Render(w, r, res.ErrBadRequest("no username provided"))
+		return
+	}
+
+	// Issue a token - delivering it to the user is up to the admin
+	auditTarget(r, req.Username)
+	token, expiry, err := h.usersFor(r).CreateResetToken(req.Username, h.resetTimeout)
+	if err == errUserNotFound {
+		render.Render(w, r, res.ErrNotFound(err.Error(),
+			"user", req.Username))
+		return
+	} else if err != nil {
+		render.Render(w, r, errInternal(r, "failed to create reset token", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("password reset token created",
+		"reset", &api.PasswordResetToken{
+			Username: req.Username,
+			Token:    token,
+			Expiry:   expiry,
+		}))
+}
+
+func (h *PermissionsHandler) resetConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	var req api.PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	if req.Username == "" || req.Token == "" || req.NewPassword == "" {
+		render.Render(w, r, res.ErrBadRequest("username, token, and new password are required"))
+		return
+	}
+
+	// Consume the token and set the new password
+	auditUser(r, req.Username)
+	if err := h.usersFor(r).ResetPassword(req.Username, req.Token, req.NewPassword); err != nil {
+		switch perr, isPolicyErr := err.(*PasswordPolicyError); {
+		case isPolicyErr:
+			render.Render(w, r, passwordPolicyErr(perr))
+		case err == errInvalidResetToken, err == errResetTokenExpired:
+			render.Render(w, r, res.ErrForbidden(err.Error()).
+				WithCode(api.ErrCodeInvalidResetToken))
+		case crypto.IsCredentialFormatError(err):
+			render.Render(w, r, res.ErrBadRequest("invalid credentials format",
+				"error", err))
+		default:
+			render.Render(w, r, errInternal(r, "failed to reset password", err))
+		}
+		return
+	}
+
+	// Delete the user's sessions, since whoever holds them may not know the
+	// new password
+	if err := h.sessions.EndAllUserSessions(req.Username); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end sessions", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("password reset",
+		"user", req.Username))
+}
+
+func (h *PermissionsHandler) forceResetHandler(w http.ResponseWriter, r *http.Request) {
+	// Require all users to change their password
+	marked, err := h.usersFor(r).RequirePasswordReset()
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to require password reset", err))
+		return
+	}
+
+	// Delete all sessions, so that users must log in again
+	if err := h.sessions.EndAllSessions(); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end sessions", err))
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("password reset required for all users",
+		"users", marked))
+}
+
 func (h *PermissionsHandler) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		query = r.URL.Query()
+		page  = map[string]int{api.ListLimit: 0, api.ListOffset: 0}
+		admin *bool
+	)
+	for _, param := range []string{api.ListLimit, api.ListOffset} {
+		if v := query.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				render.Render(w, r, res.ErrBadRequest(
+					fmt.Sprintf("invalid %s '%s' - must be a non-negative integer", param, v)))
+				return
+			}
+			page[param] = n
+		}
+	}
+	if v := query.Get(api.UserAdmin); v != "" {
+		filter, err := strconv.ParseBool(v)
+		if err != nil {
+			render.Render(w, r, res.ErrBadRequest(
+				fmt.Sprintf("invalid %s '%s' - must be true or false", api.UserAdmin, v)))
+			return
+		}
+		admin = &filter
+	}
+
+	users, err := h.usersFor(r).FilterUsers(admin)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to retrieve users", err))
+		return
+	}
+
+	// Users are sorted by username, so pages are stable
+	var total = len(users)
+	var start, end = page[api.ListOffset], total
+	if start > total {
+		start = total
+	}
+	if limit := page[api.ListLimit]; limit > 0 && start+limit < total {
+		end = start + limit
+	}
+	details, err := h.usersFor(r).UserInfo(users[start:end])
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to retrieve users", err))
+		return
+	}
 	render.Render(w, r, res.MsgOK("users retrieved",
-		"users", h.users.UserList()))
+		"users", users[start:end],
+		"details", details,
+		"total", total))
+}
+
+func (h *PermissionsHandler) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.sessions.List()
+	if err != nil {
+		if err == errListNotSupported {
+			render.Render(w, r, res.Err(err.Error(), http.StatusNotImplemented).
+				WithCode(api.ErrCodeNotSupported))
+		} else {
+			render.Render(w, r, errInternal(r, "failed to retrieve sessions", err))
+		}
+		return
+	}
+	render.Render(w, r, res.MsgOK("sessions retrieved",
+		"sessions", sessions,
+		"total", len(sessions)))
 }
 
 func (h *PermissionsHandler) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -383,73 +1707,295 @@ func (h *PermissionsHandler) loginHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Reject attempts for usernames that have failed to log in too many times
+	auditUser(r, userReq.Username)
+	if remaining, locked := h.logins.Locked(userReq.Username); locked {
+		h.renderLockedOut(w, r, remaining)
+		return
+	}
+
 	// Check the password is correct
-	props, correct, err := h.users.IsCorrectCredentials(
+	props, correct, err := h.usersFor(r).IsCorrectCredentials(
 		userReq.Username, userReq.Password)
 	switch {
 	case err == errMissingCredentials:
 		render.Render(w, r, res.ErrBadRequest(err.Error()))
 		return
+	case r.Context().Err() != nil:
+		// Logins that were abandoned or timed out are not failed logins
+		render.Render(w, r, errInternal(r, "failed to log in", r.Context().Err()))
+		return
 	case !correct || err == errUserNotFound:
-		render.Render(w, r, res.ErrUnauthorized("invalid credentials provided"))
+		h.renderLoginFailed(w, r, userReq.Username)
 		return
 	case err != nil:
-		render.Render(w, r, res.ErrInternalServer("failed to log in", err))
+		render.Render(w, r, errInternal(r, "failed to log in", err))
 		return
 	}
 
+	// Users required to reset their password must provide a new one, which
+	// replaces their current password once they are fully authenticated
+	if props.PasswordResetRequired {
+		if userReq.NewPassword == "" {
+			render.Render(w, r, res.Err("password reset required - log in with a new password",
+				http.StatusUpgradeRequired).
+				WithCode(api.ErrCodePasswordResetRequired))
+			return
+		}
+		if userReq.NewPassword == userReq.Password {
+			render.Render(w, r, res.ErrBadRequest("new password must differ from current password"))
+			return
+		}
+		if err := h.usersFor(r).validateCredentials(userReq.Username, userReq.NewPassword); err != nil {
+			if perr, ok := err.(*PasswordPolicyError); ok {
+				render.Render(w, r, passwordPolicyErr(perr))
+			} else {
+				render.Render(w, r, res.ErrBadRequest(err.Error()))
+			}
+			return
+		}
+	}
+
 	// Make sure TOTP is valid if the user has TOTP enabled
-	totpEnabled, err := h.users.IsTotpEnabled(userReq.Username)
+	totpEnabled, err := h.usersFor(r).IsTotpEnabled(userReq.Username)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to check TOTP status", err))
+		render.Render(w, r, errInternal(r, "failed to check TOTP status", err))
 		return
 	}
 	if totpEnabled {
 		if userReq.Totp == "" {
-			render.Render(w, r, res.ErrBadRequest("no TOTP provided"))
+			render.Render(w, r, res.ErrBadRequest("no TOTP provided").
+				WithCode(api.ErrCodeTotpRequired))
 			return
 		}
-		validTotp, err := h.users.IsValidTotp(userReq.Username, userReq.Totp)
+		validTotp, err := h.usersFor(r).IsValidTotp(userReq.Username, userReq.Totp)
 		if err != nil {
-			render.Render(w, r, res.ErrInternalServer("unable to verify TOTP", err))
+			render.Render(w, r, errInternal(r, "unable to verify TOTP", err))
 			return
 		} else if !validTotp {
 			// Check if the user entered a backup code
-			validBackup, err := h.users.IsValidBackupCode(userReq.Username, userReq.Totp)
+			validBackup, err := h.usersFor(r).IsValidBackupCode(userReq.Username, userReq.Totp)
 			if err != nil {
-				render.Render(w, r, res.ErrInternalServer("unable to verify TOTP", err))
+				render.Render(w, r, errInternal(r, "unable to verify TOTP", err))
 				return
 			} else if !validBackup {
-				render.Render(w, r, res.ErrUnauthorized("invalid credentials provided"))
+				h.renderLoginFailed(w, r, userReq.Username)
 				return
 			}
+
+			// Backup codes can only be used once
+			if err := h.usersFor(r).RemoveBackupCode(userReq.Username, userReq.Totp); err != nil {
+				render.Render(w, r, errInternal(r, "unable to verify TOTP", err))
+				return
+			}
+		}
+	}
+	if props.PasswordResetRequired {
+		if err := h.usersFor(r).UpdatePassword(userReq.Username, userReq.NewPassword,
+			anyRevision); err != nil {
+			render.Render(w, r, errInternal(r, "failed to update password", err))
+			return
 		}
 	}
+	h.logins.Succeeded(userReq.Username)
+	if err := h.usersFor(r).RecordLogin(userReq.Username, h.clock.Now()); err != nil {
+		render.Render(w, r, errInternal(r, "failed to log in", err))
+		return
+	}
 
-	_, token, err := h.sessions.BeginSession(userReq.Username, props.Admin)
+	claims, token, err := h.sessions.BeginSession(userReq.Username, props.Role, props.TokenVersion,
+		userReq.Remember, clientAddress(r, h.trustedProxies))
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to create session", err))
+		return
+	}
+	kvs, err := h.issueSession(w, r, token, claims.Expiry)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to create session", err))
+		render.Render(w, r, errInternal(r, "failed to create session", err))
 		return
 	}
 
-	render.Render(w, r, res.MsgOK("session created",
-		"token", token))
+	h.metrics.observeLogin(true)
+	render.Render(w, r, res.MsgOK("session created", kvs...))
+}
+
+// passwordPolicyErr describes a password that does not satisfy the password
+// policy, including the rule it does not satisfy
+func passwordPolicyErr(err *PasswordPolicyError) *res.ErrResponse {
+	return res.ErrBadRequest("password does not satisfy password policy",
+		"error", err,
+		"rule", err.Rule).
+		WithCode(api.ErrCodePasswordPolicy)
+}
+
+// usernamePolicyErr describes a username that does not satisfy the username
+// policy, including the rule it does not satisfy
+func usernamePolicyErr(err *UsernamePolicyError) *res.ErrResponse {
+	return res.ErrBadRequest("username does not satisfy username policy",
+		"error", err,
+		"rule", err.Rule).
+		WithCode(api.ErrCodeUsernamePolicy)
+}
+
+// renderLoginFailed records a failed login for the given username, and
+// responds with a lockout if the username has failed too many times
+func (h *PermissionsHandler) renderLoginFailed(w http.ResponseWriter, r *http.Request,
+	username string) {
+	h.metrics.observeLogin(false)
+	if h.logins.Failed(username) {
+		h.renderLockedOut(w, r, h.logins.cooldown)
+		return
+	}
+	if wait, ok := h.logins.Locked(username); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+	h.renderUnauthorized(w, r, res.ErrUnauthorized("invalid credentials provided").
+		WithCode(api.ErrCodeInvalidCredentials))
+}
+
+// renderLockedOut responds that a username is locked out for the given duration
+func (h *PermissionsHandler) renderLockedOut(w http.ResponseWriter, r *http.Request,
+	remaining time.Duration) {
+	var retry = int(math.Ceil(remaining.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	render.Render(w, r, res.Err("too many failed login attempts - try again later",
+		http.StatusTooManyRequests,
+		"retry_after", retry).
+		WithCode(api.ErrCodeLockedOut))
+}
+
+// renderRateLimited responds that the client must wait for the given duration
+// before making another request
+func (h *PermissionsHandler) renderRateLimited(w http.ResponseWriter, r *http.Request,
+	wait time.Duration) {
+	var retry = int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	render.Render(w, r, res.Err("too many requests - try again later",
+		http.StatusTooManyRequests,
+		"retry_after", retry).
+		WithCode(api.ErrCodeRateLimited))
 }
 
 func (h *PermissionsHandler) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if claims, err := h.sessions.GetSession(r); err == nil {
+		auditUser(r, claims.User)
+	}
+	h.clearSessionCookie(w)
 	err := h.sessions.EndSession(r)
 	if err != nil {
-		render.Render(w, r, res.ErrInternalServer("failed to end session", err))
+		render.Render(w, r, errInternal(r, "failed to end session", err))
 		return
 	}
 
 	render.Render(w, r, res.MsgOK("session ended"))
 }
 
+func (h *PermissionsHandler) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("failed to read token", "error", err))
+		return
+	}
+	if claims.IsMaster() || claims.ReadOnly {
+		render.Render(w, r, res.ErrBadRequest("only session tokens can be logged out"))
+		return
+	}
+
+	// Invalidate all tokens issued to the user, including this one, and drop
+	// their sessions
+	if _, err := h.usersFor(r).RevokeTokens(claims.User); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end sessions", err))
+		return
+	}
+	if err := h.sessions.EndAllUserSessions(claims.User); err != nil {
+		render.Render(w, r, errInternal(r, "failed to end sessions", err))
+		return
+	}
+	h.clearSessionCookie(w)
+
+	render.Render(w, r, res.MsgOK("all sessions ended"))
+}
+
+func (h *PermissionsHandler) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("failed to read token", "error", err))
+		return
+	}
+	if claims.IsMaster() || claims.ReadOnly {
+		render.Render(w, r, res.ErrBadRequest("only session tokens expire and can be refreshed"))
+		return
+	}
+
+	refreshed, token, err := h.sessions.RefreshSession(claims)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to refresh session", err))
+		return
+	}
+	kvs, err := h.issueSession(w, r, token, refreshed.Expiry)
+	if err != nil {
+		render.Render(w, r, errInternal(r, "failed to refresh session", err))
+		return
+	}
+	render.Render(w, r, res.MsgOK("session refreshed",
+		append(kvs, "expiry", refreshed.Expiry)...))
+}
+
 func (h *PermissionsHandler) validateHandler(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, res.MsgOK("hi there!"))
 }
 
+func (h *PermissionsHandler) whoamiHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.sessions.GetSession(r)
+	if err != nil {
+		h.renderUnauthorized(w, r, res.ErrUnauthorized("failed to read token", "error", err))
+		return
+	}
+
+	var role = roleOf(claims)
+	var identity = api.Identity{
+		User:     claims.User,
+		Role:     role,
+		Admin:    role == api.RoleAdmin,
+		ReadOnly: claims.ReadOnly,
+		Scopes:   claims.Scopes,
+	}
+	if !claims.IsMaster() && !claims.ReadOnly {
+		identity.Expiry = &claims.Expiry
+		revision, err := h.usersFor(r).Revision(claims.User)
+		if err != nil {
+			render.Render(w, r, errInternal(r, "failed to retrieve user", err))
+			return
+		}
+		identity.Revision = &revision
+	}
+	render.Render(w, r, res.MsgOK("identity retrieved",
+		"identity", identity))
+}
+
+// requireRevision returns the revision of the given user that an update is
+// based on, or rejects the request if it does not provide one, so that updates
+// cannot silently overwrite concurrent changes
+func requireRevision(w http.ResponseWriter, r *http.Request, username string, revision *int) (int, bool) {
+	if revision == nil {
+		render.Render(w, r, res.Err("a revision is required - read the user and send the revision that was read",
+			http.StatusPreconditionRequired,
+			"user", username).
+			WithCode(api.ErrCodeRevisionRequired))
+		return 0, false
+	}
+	return *revision, true
+}
+
+// staleRevisionErr reports an update based on an outdated read of the given
+// user
+func staleRevisionErr(username string) *res.ErrResponse {
+	return res.Err(errStaleRevision.Error()+" - read the user again and retry",
+		http.StatusConflict,
+		"user", username).
+		WithCode(api.ErrCodeConflict)
+}
+
 func readCredentials(r *http.Request) (api.UserRequest, error) {
 	userReq := api.UserRequest{}
 	body, err := ioutil.ReadAll(r.Body)
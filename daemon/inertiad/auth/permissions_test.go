@@ -2,14 +2,15 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,26 +26,25 @@ func getTokenFromResponse(body io.ReadCloser) (token string) {
 	return token
 }
 
-func getTestPermissionsHandler(dir string) (*PermissionsHandler, error) {
-	err := os.Mkdir(dir, os.ModePerm)
+func getTestPermissionsHandler() (*PermissionsHandler, error) {
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Keys: KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
 	if err != nil {
 		return nil, err
 	}
-	return NewPermissionsHandler(
-		path.Join(dir, "users.db"),
-		"127.0.0.1", 3000,
-		crypto.GetFakeAPIKey,
-	)
+
+	// Most tests read session tokens from login responses
+	ph.SetLoginTokenMode(LoginTokenBoth)
+	return ph, nil
 }
 
 func TestServeHTTPPublicPath(t *testing.T) {
-	dir := "./test_perm_publicpath"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -66,14 +66,132 @@ func TestServeHTTPPublicPath(t *testing.T) {
 	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
 }
 
+func TestServeHTTPMethods(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var ok = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	ph.AttachPublicHandlerFunc("/ping", ok, http.MethodGet, http.MethodHead)
+	ph.AttachPublicHandler("/static", http.HandlerFunc(ok), http.MethodGet)
+	ph.AttachUserRestrictedHandlerFunc("/settings", ok, http.MethodPut)
+	ph.AttachAdminRestrictedHandlerFunc("/things", ok, http.MethodDelete)
+
+	// the handler may be nested under a prefix, as it is behind proxies
+	ts := httptest.NewServer(http.StripPrefix("/inertia", ph))
+	defer ts.Close()
+
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{http.MethodGet, "/ping", http.StatusOK},
+		{http.MethodHead, "/ping", http.StatusOK},
+		{http.MethodPost, "/ping", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/ping", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/static", http.StatusOK},
+		{http.MethodPost, "/static", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/settings", http.StatusOK},
+		{http.MethodGet, "/settings", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/things", http.StatusOK},
+		{http.MethodPost, "/things", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+"/inertia"+tt.path, nil)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestServeHTTPAllowHeader(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var ok = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	ph.AttachPublicHandlerFunc("/things", ok, http.MethodGet, http.MethodPost)
+
+	tests := []struct {
+		method    string
+		path      string
+		wantCode  int
+		wantAllow string
+	}{
+		{http.MethodGet, "/things", http.StatusOK, ""},
+		{http.MethodDelete, "/things", http.StatusMethodNotAllowed, "GET, POST"},
+		{http.MethodPut, "/things", http.StatusMethodNotAllowed, "GET, POST"},
+		{http.MethodPut, "/health", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodGet, "/user/login", http.StatusMethodNotAllowed, "POST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
+		})
+	}
+}
+
+func TestServeHTTPPublicPrefixes(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var echo = func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s", name, r.URL.Path)
+		})
+	}
+	ph.AttachPublicPrefix("/web/", echo("web"), http.MethodGet)
+	ph.AttachPublicPrefix("/static", echo("static"), http.MethodGet)
+	ph.AttachPublicHandler("/webhook", echo("webhook"))
+
+	// the handler may be nested under a prefix, as it is behind proxies
+	ts := httptest.NewServer(http.StripPrefix("/inertia", ph))
+	defer ts.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/web", http.StatusOK, "web /"},
+		{"/web/", http.StatusOK, "web /"},
+		{"/web/bundle.js", http.StatusOK, "web /bundle.js"},
+		{"/web/assets/logo.png", http.StatusOK, "web /assets/logo.png"},
+		{"/static/", http.StatusOK, "static /"},
+		{"/static/css/main.css", http.StatusOK, "static /css/main.css"},
+		{"/webhook", http.StatusOK, "webhook /webhook"},
+		{"/webhooks", http.StatusNotFound, ""},
+		{"/statics/main.css", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/inertia" + tt.path)
+			assert.Nil(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			if tt.wantBody != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, tt.wantBody, string(body))
+			}
+		})
+	}
+}
+
 func TestServeHTTPWithUserReject(t *testing.T) {
-	dir := "./test_perm_reject"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -100,19 +218,17 @@ func TestServeHTTPWithUserReject(t *testing.T) {
 }
 
 func TestServeHTTPWithUserLoginAndLogout(t *testing.T) {
-	dir := "./test_perm_loginlogout"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
 
 	// Register user
-	err = ph.users.AddUser("bobheadxi", "wowgreat", false)
+	err = ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser)
 	assert.Nil(t, err)
 
 	// Login in as user
@@ -158,13 +274,11 @@ func TestServeHTTPWithUserLoginAndLogout(t *testing.T) {
 }
 
 func TestServeHTTPWithUserLoginAndAccept(t *testing.T) {
-	dir := "./test_perm_loginaccept"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -173,7 +287,7 @@ func TestServeHTTPWithUserLoginAndAccept(t *testing.T) {
 	}), http.MethodPost)
 
 	// Register user
-	err = ph.users.AddUser("bobheadxi", "wowgreat", false)
+	err = ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser)
 	assert.Nil(t, err)
 
 	// log in as non user
@@ -224,13 +338,11 @@ func TestServeHTTPWithUserLoginAndAccept(t *testing.T) {
 }
 
 func TestServeHTTPDenyNonAdmin(t *testing.T) {
-	dir := "./test_perm_denynonadmin"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -239,7 +351,7 @@ func TestServeHTTPDenyNonAdmin(t *testing.T) {
 	}), http.MethodPost)
 
 	// Register user
-	err = ph.users.AddUser("bobheadxi", "wowgreat", false)
+	err = ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser)
 	assert.Nil(t, err)
 
 	// Login in as user
@@ -268,13 +380,11 @@ func TestServeHTTPDenyNonAdmin(t *testing.T) {
 }
 
 func TestServeHTTPAllowAdmin(t *testing.T) {
-	dir := "./test_perm_disallowadmin"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -283,7 +393,7 @@ func TestServeHTTPAllowAdmin(t *testing.T) {
 	}), http.MethodPost)
 
 	// Register user
-	err = ph.users.AddUser("bobheadxi", "wowgreat", true)
+	err = ph.users.AddUser("bobheadxi", "wowgreat", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	// Login in as user
@@ -311,14 +421,103 @@ func TestServeHTTPAllowAdmin(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestServeHTTPReadOnlyToken(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+
+	// Set up permission handler
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ts.Config.Handler = ph
+	var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ph.AttachReadOnlyHandlerFunc("/status", ok, http.MethodGet)
+	ph.AttachUserRestrictedHandlerFunc("/validate", ok, http.MethodGet)
+	ph.AttachAdminRestrictedHandlerFunc("/up", ok, http.MethodPost)
+
+	// Create a read-only token
+	var bearerTokenString = fmt.Sprintf("Bearer %s", crypto.TestMasterToken)
+	body, err := json.Marshal(&api.TokenRequest{Name: "dashboard"})
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", ts.URL+"/user/tokens/add", bytes.NewReader(body))
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", bearerTokenString)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var id, token string
+	_, err = api.Unmarshal(resp.Body, api.KV{Key: "id", Value: &id}, api.KV{Key: "token", Value: &token})
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	// Read-only token should only be accepted on read-only paths
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"GET", "/status", http.StatusOK},
+		{"GET", "/validate", http.StatusForbidden},
+		{"POST", "/up", http.StatusForbidden},
+		{"GET", "/user/tokens", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, err = http.NewRequest(tt.method, ts.URL+tt.path, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, tt.wantCode, resp.StatusCode, tt.path)
+	}
+
+	// Token should be listed by its hash, without the token itself
+	req, err = http.NewRequest("GET", ts.URL+"/user/tokens", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", bearerTokenString)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	listBody, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.NotContains(t, string(listBody), token)
+	var tokens []api.ReadOnlyToken
+	_, err = api.Unmarshal(bytes.NewReader(listBody), api.KV{Key: "tokens", Value: &tokens})
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "dashboard", tokens[0].Name)
+	assert.Equal(t, id, tokens[0].ID)
+	assert.Equal(t, crypto.HashToken(token), tokens[0].Hash)
+
+	// Revoke token
+	body, err = json.Marshal(&api.TokenRequest{ID: id})
+	assert.Nil(t, err)
+	req, err = http.NewRequest("POST", ts.URL+"/user/tokens/revoke", bytes.NewReader(body))
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", bearerTokenString)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Revoked token should no longer be accepted
+	req, err = http.NewRequest("GET", ts.URL+"/status", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
 func TestUserControlHandlers(t *testing.T) {
-	dir := "./test_perm_usercontrol"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -368,7 +567,11 @@ func TestUserControlHandlers(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
 	// Reset all users
-	req, err = http.NewRequest("POST", ts.URL+"/user/reset", nil)
+	_, confirm, err := ph.users.ResetPlan()
+	assert.Nil(t, err)
+	body, err = json.Marshal(&api.UserResetRequest{Confirm: confirm})
+	assert.Nil(t, err)
+	req, err = http.NewRequest("POST", ts.URL+"/user/reset", bytes.NewReader(body))
 	assert.Nil(t, err)
 	req.Header.Set("Authorization", bearerTokenString)
 	resp, err = http.DefaultClient.Do(req)
@@ -378,13 +581,11 @@ func TestUserControlHandlers(t *testing.T) {
 }
 
 func TestEnableDisableTotpEndpoints(t *testing.T) {
-	dir := "./test_enabledisable_totp"
 	ts := httptest.NewServer(nil)
 	defer ts.Close()
 
 	// Set up permission handler
-	ph, err := getTestPermissionsHandler(dir)
-	defer os.RemoveAll(dir)
+	ph, err := getTestPermissionsHandler()
 	assert.Nil(t, err)
 	defer ph.Close()
 	ts.Config.Handler = ph
@@ -458,6 +659,78 @@ func TestEnableDisableTotpEndpoints(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestServeHTTPTotpEnrollment(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetTotpKey(make([]byte, crypto.SymmetricKeyLength))
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleAdmin))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	var post = func(path, token string, body interface{}) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var login = func(totp string) *httptest.ResponseRecorder {
+		return post("/user/login", "", api.UserRequest{
+			Username: "bobheadxi", Password: "wowgreat", Totp: totp})
+	}
+
+	// non-enrolled users log in normally
+	rec := post("/user/login", "", api.UserRequest{Username: "chadlagore", Password: "chadlad"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = login("")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token := getTokenFromResponse(rec.Result().Body)
+
+	// enroll
+	var creds = api.UserRequest{Username: "bobheadxi", Password: "wowgreat"}
+	rec = post("/user/totp/enroll", token, creds)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var enrollment api.TotpResponse
+	_, err = api.Unmarshal(rec.Body, api.KV{Key: "totp", Value: &enrollment})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, enrollment.TotpSecret)
+	assert.Contains(t, enrollment.URI, enrollment.TotpSecret)
+
+	// TOTP is not required until enrollment is confirmed
+	assert.Equal(t, http.StatusOK, login("").Code)
+
+	// confirm enrollment
+	code, err := totp.GenerateCode(enrollment.TotpSecret, time.Now())
+	assert.Nil(t, err)
+	creds.Totp = "000000"
+	assert.Equal(t, http.StatusBadRequest, post("/user/totp/confirm", token, creds).Code)
+	creds.Totp = code
+	rec = post("/user/totp/confirm", token, creds)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var confirmed api.TotpResponse
+	_, err = api.Unmarshal(rec.Body, api.KV{Key: "totp", Value: &confirmed})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, confirmed.BackupCodes)
+
+	// TOTP is now required
+	rec = login("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeTotpRequired)
+
+	// correct code is accepted, but only once
+	code, err = totp.GenerateCode(enrollment.TotpSecret, time.Now().Add(30*time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, login(code).Code)
+	assert.Equal(t, http.StatusUnauthorized, login(code).Code)
+
+	// backup codes are also accepted only once
+	assert.Equal(t, http.StatusOK, login(confirmed.BackupCodes[0]).Code)
+	assert.Equal(t, http.StatusUnauthorized, login(confirmed.BackupCodes[0]).Code)
+}
+
 func TestPermissionsHandler_addUserHandler(t *testing.T) {
 	type args struct {
 		method string
@@ -483,9 +756,7 @@ func TestPermissionsHandler_addUserHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Set up permission handler
-			var dir = "./test_addUserHandler"
-			ph, err := getTestPermissionsHandler(dir)
-			defer os.RemoveAll(dir)
+			ph, err := getTestPermissionsHandler()
 			assert.Nil(t, err)
 			defer ph.Close()
 
@@ -536,15 +807,13 @@ func TestPermissionsHandler_loginHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Set up permission handler
-			var dir = "./test_loginHandler"
-			ph, err := getTestPermissionsHandler(dir)
-			defer os.RemoveAll(dir)
+			ph, err := getTestPermissionsHandler()
 			assert.Nil(t, err)
 			defer ph.Close()
 
 			// test situation
 			var testUser = tt.fields.user
-			ph.users.AddUser(testUser.Username, testUser.Password, testUser.Admin)
+			ph.users.AddUser(testUser.Username, testUser.Password, testUser.GetRole())
 			// todo: test totp situations?
 
 			// test handler
@@ -563,3 +832,1602 @@ func TestPermissionsHandler_loginHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPermissionsHandler_loginHandlerUnknownUser(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+
+	var login = func(username string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(api.UserRequest{Username: username, Password: "lunchpad"})
+		rec := httptest.NewRecorder()
+		ph.loginHandler(rec, httptest.NewRequest("POST", "/", bytes.NewReader(b)))
+		return rec
+	}
+
+	// unknown users and wrong passwords must be indistinguishable
+	var (
+		unknown = login("nobodyhead")
+		wrong   = login("bobhead")
+	)
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, wrong.Code, unknown.Code)
+	assert.Equal(t, wrong.Body.String(), unknown.Body.String())
+
+	// unknown users are checked against a decoy password
+	assert.NotEmpty(t, ph.users.decoy.hash)
+}
+
+func TestPermissionsHandler_cancelledRequest(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	// hold up the database, and cancel a login mid-request
+	var db = ph.users.db.(*memStore)
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	var (
+		rec   = httptest.NewRecorder()
+		req   = httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)).WithContext(ctx)
+		start = time.Now()
+	)
+	ph.ServeHTTP(rec, req)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// abandoned logins are not failed logins
+	_, locked := ph.logins.Locked("bobheadxi")
+	assert.False(t, locked)
+	assert.Empty(t, ph.logins.failures)
+}
+
+func TestPermissionsHandler_requestTimeout(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetRequestTimeout(20 * time.Millisecond)
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	ph.AttachUserRestrictedHandlerFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		// attached handlers may take as long as they need
+		if _, ok := r.Context().Deadline(); ok {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var req = httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	var rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// requests that wait on the database for too long time out
+	var db = ph.users.db.(*memStore)
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeTimeout)
+}
+
+func TestPermissionsHandler_loginHandlerLockout(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetLoginLockout(2, time.Minute)
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+
+	var login = func(password string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(api.UserRequest{Username: "bobhead", Password: password})
+		rec := httptest.NewRecorder()
+		ph.loginHandler(rec, httptest.NewRequest("POST", "/", bytes.NewReader(b)))
+		return rec
+	}
+
+	// a successful login resets the count
+	assert.Equal(t, http.StatusUnauthorized, login("lunchpad").Code)
+	assert.Equal(t, http.StatusOK, login("breakfastpad").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("lunchpad").Code)
+
+	// the threshold locks the username out, even with correct credentials
+	assert.Equal(t, http.StatusTooManyRequests, login("lunchpad").Code)
+	rec := login("breakfastpad")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), api.ErrCodeLockedOut)
+
+	// lockout expires after the cooldown
+	ph.logins.now = func() time.Time { return time.Now().Add(time.Minute) }
+	assert.Equal(t, http.StatusOK, login("breakfastpad").Code)
+}
+
+func TestPermissionsHandler_loginHandlerBackoff(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetLoginLockout(10, time.Hour)
+	ph.SetLoginBackoff(time.Second, 4*time.Second)
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+	var now = time.Now()
+	ph.logins.now = func() time.Time { return now }
+
+	var login = func(password string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(api.UserRequest{Username: "bobhead", Password: password})
+		rec := httptest.NewRecorder()
+		ph.loginHandler(rec, httptest.NewRequest("POST", "/", bytes.NewReader(b)))
+		return rec
+	}
+
+	// the delay doubles with each consecutive failure, up to the cap, and
+	// attempts are refused until it has passed
+	for _, wait := range []string{"1", "2", "4", "4"} {
+		rec := login("lunchpad")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, wait, rec.Header().Get("Retry-After"))
+
+		rec = login("breakfastpad")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, wait, rec.Header().Get("Retry-After"))
+		assert.Contains(t, rec.Body.String(), api.ErrCodeLockedOut)
+
+		seconds, _ := strconv.Atoi(wait)
+		now = now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	// a successful login resets the delay
+	assert.Equal(t, http.StatusOK, login("breakfastpad").Code)
+	rec := login("lunchpad")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func TestPermissionsHandler_updatePasswordHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         api.UserRequest
+		omitRevision bool
+		want         int
+		wantLogin    string
+	}{
+		{"success", api.UserRequest{Password: "breakfastpad", NewPassword: "lunchpad"}, false,
+			http.StatusOK, "lunchpad"},
+		{"wrong current password", api.UserRequest{Password: "dinnerpad", NewPassword: "lunchpad"}, false,
+			http.StatusForbidden, "breakfastpad"},
+		{"new password too short", api.UserRequest{Password: "breakfastpad", NewPassword: "pad"}, false,
+			http.StatusBadRequest, "breakfastpad"},
+		{"missing passwords", api.UserRequest{}, false, http.StatusBadRequest, "breakfastpad"},
+		{"missing revision", api.UserRequest{Password: "breakfastpad", NewPassword: "lunchpad"}, true,
+			http.StatusPreconditionRequired, "breakfastpad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph, err := getTestPermissionsHandler()
+			assert.Nil(t, err)
+			defer ph.Close()
+			assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleAdmin))
+			if !tt.omitRevision {
+				revision, err := ph.users.Revision("bobhead")
+				assert.Nil(t, err)
+				tt.body.Revision = &revision
+			}
+
+			var (
+				b, _ = json.Marshal(tt.body)
+				req  = httptest.NewRequest("POST", "/user/updatepassword", bytes.NewReader(b))
+				rec  = httptest.NewRecorder()
+			)
+			req = req.WithContext(context.WithValue(req.Context(), ctxUsername, "bobhead"))
+			ph.updatePasswordHandler(rec, req)
+			assert.Equal(t, tt.want, rec.Code, rec.Body.String())
+
+			// other properties are preserved
+			props, correct, err := ph.users.IsCorrectCredentials("bobhead", tt.wantLogin)
+			assert.Nil(t, err)
+			assert.True(t, correct)
+			assert.True(t, props.Admin)
+		})
+	}
+}
+
+func TestPermissionsHandler_updatePasswordHandlerLockout(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetLoginLockout(2, time.Minute)
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+
+	var update = func(password string) *httptest.ResponseRecorder {
+		revision, err := ph.users.Revision("bobhead")
+		assert.Nil(t, err)
+		b, _ := json.Marshal(api.UserRequest{Password: password, NewPassword: "lunchpad",
+			Revision: &revision})
+		var (
+			req = httptest.NewRequest("POST", "/user/updatepassword", bytes.NewReader(b))
+			rec = httptest.NewRecorder()
+		)
+		req = req.WithContext(context.WithValue(req.Context(), ctxUsername, "bobhead"))
+		ph.updatePasswordHandler(rec, req)
+		return rec
+	}
+
+	// wrong current passwords count towards the login lockout
+	assert.Equal(t, http.StatusForbidden, update("dinnerpad").Code)
+	assert.Equal(t, http.StatusTooManyRequests, update("dinnerpad").Code)
+	rec := update("breakfastpad")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), api.ErrCodeLockedOut)
+	_, correct, err := ph.users.IsCorrectCredentials("bobhead", "breakfastpad")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+
+	// lockout expires after the cooldown
+	ph.logins.now = func() time.Time { return time.Now().Add(time.Minute) }
+	assert.Equal(t, http.StatusOK, update("breakfastpad").Code)
+}
+
+func TestPermissionsHandler_updatePasswordHandlerError(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+	revision, err := ph.users.Revision("bobhead")
+	assert.Nil(t, err)
+
+	// corrupt the user, so that checking the password fails
+	assert.Nil(t, ph.users.db.Update(func(tx storeTx) error {
+		return tx.Bucket(ph.users.usersBucket).Put([]byte("bobhead"), []byte("{"))
+	}))
+
+	b, _ := json.Marshal(api.UserRequest{Password: "breakfastpad", NewPassword: "lunchpad",
+		Revision: &revision})
+	var (
+		req = httptest.NewRequest("POST", "/user/updatepassword", bytes.NewReader(b))
+		rec = httptest.NewRecorder()
+	)
+	req = req.WithContext(context.WithValue(req.Context(), ctxUsername, "bobhead"))
+	ph.updatePasswordHandler(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+	assert.Empty(t, ph.logins.failures)
+}
+
+func TestUpdatePasswordRequiresLogin(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ts.Config.Handler = ph
+
+	body, _ := json.Marshal(api.UserRequest{Password: "breakfastpad", NewPassword: "lunchpad"})
+	resp, err := http.Post(ts.URL+"/user/updatepassword", "application/json", bytes.NewReader(body))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestServeHTTPSessionExpiryAndRefresh(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ts.Config.Handler = ph
+	assert.Equal(t, DefaultSessionTimeout, ph.sessions.sessionTimeout)
+
+	var do = func(method, path, token string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+
+	// valid token
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	claims, token, err := ph.sessions.BeginSession("bobheadxi", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+	resp := do("GET", "/user/validate", token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// refresh extends the session and replaces the old token
+	time.Sleep(10 * time.Millisecond)
+	resp = do("POST", "/user/refresh", token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var refreshed = getTokenFromResponse(resp.Body)
+	assert.NotEqual(t, token, refreshed)
+	refreshedClaims, err := crypto.ValidateToken(refreshed, crypto.GetFakeAPIKey)
+	assert.Nil(t, err)
+	assert.True(t, refreshedClaims.Expiry.After(claims.Expiry))
+	resp = do("GET", "/user/validate", refreshed)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do("GET", "/user/validate", token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// expired token
+	ph.sessions.sessionTimeout = -time.Minute
+	_, token, err = ph.sessions.BeginSession("bobheadxi", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+	resp = do("GET", "/user/validate", token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	b, err := api.Unmarshal(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeExpiredToken, b.ErrCode)
+	resp = do("POST", "/user/refresh", token)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// master tokens do not expire, so cannot be refreshed
+	resp = do("POST", "/user/refresh", crypto.TestMasterToken)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPermissionsHandler_importUsersHandler(t *testing.T) {
+	var failedAt = func(i int) *int { return &i }
+	tests := []struct {
+		name        string
+		body        []api.UserRequest
+		wantStatus  int
+		wantCreated []string
+		wantFailed  *int
+	}{
+		{"success", []api.UserRequest{
+			{Username: "alice", Password: "alicepass", Admin: true},
+			{Username: "carol", Password: "carolpass"},
+		}, http.StatusCreated, []string{"alice", "carol"}, nil},
+		{"existing user", []api.UserRequest{
+			{Username: "alice", Password: "alicepass"},
+			{Username: "bobhead", Password: "bobpass"},
+			{Username: "carol", Password: "carolpass"},
+		}, http.StatusConflict, []string{}, failedAt(1)},
+		{"invalid password", []api.UserRequest{
+			{Username: "alice", Password: "alicepass"},
+			{Username: "carol", Password: "pw"},
+		}, http.StatusBadRequest, []string{}, failedAt(1)},
+		{"duplicate in batch", []api.UserRequest{
+			{Username: "alice", Password: "alicepass"},
+			{Username: "alice", Password: "alicepass"},
+		}, http.StatusConflict, []string{}, failedAt(1)},
+		{"empty", []api.UserRequest{}, http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph, err := getTestPermissionsHandler()
+			assert.Nil(t, err)
+			defer ph.Close()
+			assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+
+			var (
+				b, _ = json.Marshal(tt.body)
+				req  = httptest.NewRequest("POST", "/user/import", bytes.NewReader(b))
+				rec  = httptest.NewRecorder()
+			)
+			ph.importUsersHandler(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+
+			var result api.UserImportResult
+			_, err = api.Unmarshal(rec.Body, api.KV{Key: "import", Value: &result})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCreated, result.Created)
+			assert.Equal(t, tt.wantFailed, result.FailedIndex)
+
+			// either all users are created, or none are
+			for _, u := range tt.body {
+				if u.Username == "bobhead" {
+					continue
+				}
+				var exists = ph.users.HasUser(u.Username) == nil
+				assert.Equal(t, tt.wantStatus == http.StatusCreated, exists, u.Username)
+			}
+		})
+	}
+}
+
+func TestPermissionsHandler_addUserHandlerPasswordPolicy(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetPasswordPolicy(PasswordPolicy{MinLength: 8, RequireDigit: true})
+
+	var add = func(password string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(api.UserRequest{Username: "bobhead", Password: password})
+		rec := httptest.NewRecorder()
+		ph.addUserHandler(rec, httptest.NewRequest("POST", "/user/add", bytes.NewReader(b)))
+		return rec
+	}
+
+	rec := add("password")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var rule string
+	_, err = api.Unmarshal(rec.Body, api.KV{Key: "rule", Value: &rule})
+	assert.Nil(t, err)
+	assert.Equal(t, RuleDigit, rule)
+	assert.NotNil(t, ph.users.HasUser("bobhead"))
+
+	assert.Equal(t, http.StatusCreated, add("passw0rd").Code)
+	assert.Nil(t, ph.users.HasUser("bobhead"))
+}
+
+func TestPermissionsHandler_addUserHandlerUsernamePolicy(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetUsernamePolicy(UsernamePolicy{Reserved: []string{"deploybot"}})
+
+	tests := []struct {
+		name     string
+		username string
+		wantCode int
+		wantRule string
+	}{
+		{"valid", "bobhead", http.StatusCreated, ""},
+		{"illegal characters", "bob/head", http.StatusBadRequest, RuleUsernameCharset},
+		{"configured reserved name", "DeployBot", http.StatusBadRequest, RuleUsernameReserved},
+		{"default reserved name replaced", "admin", http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := json.Marshal(api.UserRequest{Username: tt.username, Password: "wowgreat"})
+			rec := httptest.NewRecorder()
+			ph.addUserHandler(rec, httptest.NewRequest("POST", "/user/add", bytes.NewReader(b)))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantRule == "" {
+				assert.Nil(t, ph.users.HasUser(tt.username))
+				return
+			}
+			var rule string
+			base, err := api.Unmarshal(rec.Body, api.KV{Key: "rule", Value: &rule})
+			assert.Nil(t, err)
+			assert.Equal(t, api.ErrCodeUsernamePolicy, base.ErrCode)
+			assert.Equal(t, tt.wantRule, rule)
+			assert.NotNil(t, ph.users.HasUser(tt.username))
+		})
+	}
+}
+
+func TestPermissionsHandler_errorResponses(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobhead", "breakfastpad", api.RoleUser))
+
+	// failed login
+	b, _ := json.Marshal(api.UserRequest{Username: "bobhead", Password: "lunchpad"})
+	rec := httptest.NewRecorder()
+	ph.loginHandler(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(b)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	resp, err := api.Unmarshal(rec.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.HTTPStatusCode)
+	assert.NotEmpty(t, resp.Message)
+	assert.Equal(t, api.ErrCodeInvalidCredentials, resp.ErrCode)
+
+	// duplicate user
+	b, _ = json.Marshal(api.UserRequest{Username: "bobhead", Password: "dinnerpad"})
+	rec = httptest.NewRecorder()
+	ph.addUserHandler(rec, httptest.NewRequest("POST", "/user/add", bytes.NewReader(b)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	var user string
+	resp, err = api.Unmarshal(rec.Body, api.KV{Key: "user", Value: &user})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusConflict, resp.HTTPStatusCode)
+	assert.Equal(t, api.ErrCodeUserExists, resp.ErrCode)
+	assert.Equal(t, "bobhead", user)
+
+	// existing user is untouched
+	_, correct, err := ph.users.IsCorrectCredentials("bobhead", "breakfastpad")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+}
+
+func TestServeHTTPViewer(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	defer ts.Close()
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ts.Config.Handler = ph
+
+	var ok = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	ph.AttachUserRestrictedHandlerFunc("/deployish", ok, http.MethodPost)
+	ph.AttachViewerRestrictedHandlerFunc("/audit", ok, http.MethodGet)
+	ph.AttachReadOnlyHandlerFunc("/statusish", ok, http.MethodGet, http.MethodPost)
+	ph.AttachAdminRestrictedHandlerFunc("/statusish/admin", ok, http.MethodGet)
+
+	// Log in as a viewer, and make sure the token carries the role
+	assert.Nil(t, ph.users.AddUser("carol", "carolpass", api.RoleViewer))
+	body, _ := json.Marshal(api.UserRequest{Username: "carol", Password: "carolpass"})
+	loginResp, err := http.Post(ts.URL+"/user/login", "application/json", bytes.NewReader(body))
+	assert.Nil(t, err)
+	defer loginResp.Body.Close()
+	assert.Equal(t, http.StatusOK, loginResp.StatusCode)
+	var token = getTokenFromResponse(loginResp.Body)
+	claims, err := crypto.ValidateToken(token, crypto.GetFakeAPIKey)
+	assert.Nil(t, err)
+	assert.Equal(t, api.RoleViewer, claims.Role)
+	assert.False(t, claims.Admin)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/audit", http.StatusOK},
+		{"GET", "/statusish", http.StatusOK},
+		{"GET", "/user/validate", http.StatusOK},
+		{"POST", "/statusish", http.StatusForbidden},
+		{"POST", "/deployish", http.StatusForbidden},
+		{"GET", "/statusish/admin", http.StatusForbidden},
+		{"GET", "/user/list", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			assert.Nil(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			assert.Nil(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+
+	// Regular users can access user-restricted routes as before
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	_, token, err = ph.sessions.BeginSession("bobheadxi", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", ts.URL+"/deployish", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServeHTTPWhoAmI(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleViewer))
+
+	// Log in to get a session cookie
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+
+	masterToken, err := crypto.GenerateMasterToken(crypto.TestPrivateKey)
+	assert.Nil(t, err)
+
+	type args struct {
+		cookie string
+		bearer string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantCode int
+		want     api.Identity
+	}{
+		{"unauthenticated", args{}, http.StatusUnauthorized, api.Identity{}},
+		{"session cookie", args{cookie: cookies[0].Value}, http.StatusOK,
+			api.Identity{User: "bobheadxi", Role: api.RoleViewer}},
+		{"session bearer token", args{bearer: cookies[0].Value}, http.StatusOK,
+			api.Identity{User: "bobheadxi", Role: api.RoleViewer}},
+		{"master bearer token", args{bearer: masterToken}, http.StatusOK,
+			api.Identity{User: "master", Role: api.RoleAdmin, Admin: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/whoami", nil)
+			if tt.args.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.args.cookie})
+			}
+			if tt.args.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.args.bearer)
+			}
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var identity api.Identity
+			_, err := api.Unmarshal(rec.Result().Body, api.KV{Key: "identity", Value: &identity})
+			assert.Nil(t, err)
+			if tt.want.User == "master" {
+				assert.Nil(t, identity.Expiry)
+			} else {
+				assert.NotNil(t, identity.Expiry)
+				identity.Expiry = nil
+			}
+			assert.Equal(t, tt.want, identity)
+		})
+	}
+}
+
+func TestServeHTTPScopedToken(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	ph.AttachAdminRestrictedHandlerFunc("/up", ok, http.MethodPost)
+	ph.AttachAdminRestrictedHandlerFunc("/env", ok, http.MethodPost)
+	ph.RequireScope(api.ScopeDeploy, "/up")
+
+	deployToken, err := crypto.GenerateScopedToken(crypto.TestPrivateKey, api.ScopeDeploy)
+	assert.Nil(t, err)
+	usersToken, err := crypto.GenerateScopedToken(crypto.TestPrivateKey, api.ScopeUsers)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		token    string
+		path     string
+		wantCode int
+	}{
+		{"deploy token on deploy route", deployToken, "/up", http.StatusOK},
+		{"deploy token on user management route", deployToken, "/user/reset?dry_run=true", http.StatusForbidden},
+		{"deploy token on unscoped route", deployToken, "/env", http.StatusForbidden},
+		{"users token on deploy route", usersToken, "/up", http.StatusForbidden},
+		{"users token on user management route", usersToken, "/user/reset?dry_run=true", http.StatusOK},
+		{"unscoped token on unscoped route", crypto.TestMasterToken, "/env", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
+
+func TestScopeOf(t *testing.T) {
+	ph := &PermissionsHandler{scopes: map[string]string{}}
+	ph.RequireScope(api.ScopeLogs, "/history")
+	ph.RequireScope(api.ScopeDeploy, "/history/rollback")
+	assert.Equal(t, api.ScopeLogs, ph.scopeOf("/history"))
+	assert.Equal(t, api.ScopeLogs, ph.scopeOf("/history/diff"))
+	assert.Equal(t, api.ScopeDeploy, ph.scopeOf("/history/rollback"))
+	assert.Equal(t, "", ph.scopeOf("/historyish"))
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	assert.True(t, hasPathPrefix("/user/reset", "/user/reset"))
+	assert.True(t, hasPathPrefix("/user/reset/all", "/user/reset"))
+	assert.True(t, hasPathPrefix("/web/index.html", "/web/"))
+	assert.False(t, hasPathPrefix("/user/resetconfirm", "/user/reset"))
+	assert.False(t, hasPathPrefix("/webhook", "/web"))
+	assert.False(t, hasPathPrefix("/webhook", "/web/"))
+}
+
+// leakySessionStore is a SessionStore that fails to revoke user sessions
+type leakySessionStore struct{ SessionStore }
+
+func (leakySessionStore) RevokeUser(string) error { return nil }
+
+func TestServeHTTPResetUsers(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	var reset = func(path string, req api.UserResetRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(&req)
+		assert.Nil(t, err)
+		r := httptest.NewRequest("POST", path, bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// dry runs list users without removing them
+	for _, path := range []string{"/user/reset?dry_run=true", "/user/reset"} {
+		rec := reset(path, api.UserResetRequest{DryRun: path == "/user/reset"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var plan api.UserResetPlan
+		_, err = api.Unmarshal(rec.Body, api.KV{Key: "reset", Value: &plan})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bobheadxi", "chadlagore"}, plan.Users)
+		assert.NotEmpty(t, plan.Confirm)
+	}
+	assert.Nil(t, ph.users.HasUser("bobheadxi"))
+
+	// missing or wrong confirmations are rejected
+	for _, confirm := range []string{"", "nope"} {
+		rec := reset("/user/reset", api.UserResetRequest{Confirm: confirm})
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		b, err := api.Unmarshal(rec.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, api.ErrCodePreconditionFailed, b.ErrCode)
+	}
+	assert.Nil(t, ph.users.HasUser("bobheadxi"))
+
+	// confirmed resets remove everyone
+	_, confirm, err := ph.users.ResetPlan()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, reset("/user/reset", api.UserResetRequest{Confirm: confirm}).Code)
+	assert.Equal(t, errUserNotFound, ph.users.HasUser("bobheadxi"))
+	assert.Equal(t, errUserNotFound, ph.users.HasUser("chadlagore"))
+}
+
+func TestServeHTTPSetAdminConcurrent(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleAdmin))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	var do = func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			assert.Nil(t, err)
+			payload = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, payload)
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// both admins read the user before updating it
+	var details []api.UserInfo
+	_, err = api.Unmarshal(do("GET", "/user/list", nil).Body,
+		api.KV{Key: "details", Value: &details})
+	assert.Nil(t, err)
+	assert.Len(t, details, 2)
+	var revision = details[1].Revision
+
+	// only one of the updates based on the same read may apply
+	var codes = make(chan int, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- do("POST", "/user/setadmin", api.UserRequest{
+				Username: "chadlagore", Admin: true, Revision: &revision}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	var statuses = map[int]int{}
+	for code := range codes {
+		statuses[code]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusConflict: 1}, statuses)
+
+	// the stale update is rejected with a conflict
+	rec := do("POST", "/user/setadmin", api.UserRequest{
+		Username: "chadlagore", Admin: true, Revision: &revision})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	b, err := api.Unmarshal(rec.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeConflict, b.ErrCode)
+
+	// updates without a revision are rejected
+	rec = do("POST", "/user/setadmin", api.UserRequest{
+		Username: "chadlagore", Admin: false})
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	b, err = api.Unmarshal(rec.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeRevisionRequired, b.ErrCode)
+	admin, err := ph.users.IsAdmin("chadlagore")
+	assert.Nil(t, err)
+	assert.True(t, admin)
+}
+
+func TestServeHTTPUpdatesWithoutRevisionConcurrent(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleAdmin))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+	revision, err := ph.users.Revision("chadlagore")
+	assert.Nil(t, err)
+
+	var do = func(path string, body api.UserRequest) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// neither of two concurrent updates that do not say which revision they
+	// are based on may overwrite the other
+	var recs = make(chan *httptest.ResponseRecorder, 2)
+	var wg sync.WaitGroup
+	for _, body := range []api.UserRequest{
+		{Username: "chadlagore", Admin: true},
+		{Username: "chadlagore", NewUsername: "chad"},
+	} {
+		wg.Add(1)
+		go func(body api.UserRequest) {
+			defer wg.Done()
+			if body.NewUsername != "" {
+				recs <- do("/user/rename", body)
+			} else {
+				recs <- do("/user/setadmin", body)
+			}
+		}(body)
+	}
+	wg.Wait()
+	close(recs)
+	for rec := range recs {
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+		b, err := api.Unmarshal(rec.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, api.ErrCodeRevisionRequired, b.ErrCode)
+	}
+
+	// the user is unchanged
+	admin, err := ph.users.IsAdmin("chadlagore")
+	assert.Nil(t, err)
+	assert.False(t, admin)
+	assert.Equal(t, errUserNotFound, ph.users.HasUser("chad"))
+	current, err := ph.users.Revision("chadlagore")
+	assert.Nil(t, err)
+	assert.Equal(t, revision, current)
+}
+
+func TestServeHTTPValidateErrors(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+
+	// a session whose user was never added
+	_, ghost, err := ph.sessions.BeginSession("ghost", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+
+	// a token signed by another daemon
+	foreign, err := (&crypto.TokenClaims{
+		SessionID: "1234", User: "bobheadxi", Expiry: time.Now().Add(time.Minute),
+	}).GenerateToken([]byte("another_key"))
+	assert.Nil(t, err)
+
+	// an expired token
+	ph.sessions.sessionTimeout = -time.Minute
+	_, expired, err := ph.sessions.BeginSession("ghost", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+
+	var tests = []struct {
+		name          string
+		authorization string
+		wantCode      string
+	}{
+		{"missing token", "", api.ErrCodeInvalidToken},
+		{"malformed header", "Token " + ghost, api.ErrCodeMalformedToken},
+		{"malformed token", "Bearer not_a_token", api.ErrCodeMalformedToken},
+		{"invalid signature", "Bearer " + foreign, api.ErrCodeInvalidSignature},
+		{"expired token", "Bearer " + expired, api.ErrCodeExpiredToken},
+		{"user not found", "Bearer " + ghost, api.ErrCodeUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/validate", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			b, err := api.Unmarshal(rec.Result().Body)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCode, b.ErrCode)
+		})
+	}
+}
+
+func TestServeHTTPRemoveUser(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.SetSessionStore(leakySessionStore{NewMemorySessionStore()})
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func() *http.Cookie {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Result().Cookies()[0]
+	}
+	var validate = func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/user/validate", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var remove = func() {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi"})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/user/remove", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Log in, then remove the user - the session outlives the user, but the
+	// old cookie is rejected anyway
+	cookie := login()
+	assert.Equal(t, http.StatusOK, validate(cookie).Code)
+	remove()
+	rec := validate(cookie)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	b, err := api.Unmarshal(rec.Result().Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeUserNotFound, b.ErrCode)
+
+	// Adding a user of the same name does not bring old cookies back
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	assert.Equal(t, http.StatusUnauthorized, validate(cookie).Code)
+	cookie = login()
+	assert.Equal(t, http.StatusOK, validate(cookie).Code)
+
+	// Neither does resetting all users and importing them again
+	assert.Nil(t, ph.users.Reset())
+	_, err = ph.users.ImportUsers([]api.UserRequest{{Username: "bobheadxi", Password: "wowgreat"}})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, validate(cookie).Code)
+}
+
+func TestServeHTTPRevokeSessions(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func() *http.Cookie {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		cookies := rec.Result().Cookies()
+		assert.Len(t, cookies, 2)
+		return cookies[0]
+	}
+	var validate = func(cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", "/user/validate", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Log in
+	cookie := login()
+	assert.Equal(t, http.StatusOK, validate(cookie))
+
+	// Revoke the user's sessions as an admin
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi"})
+	assert.Nil(t, err)
+	req := httptest.NewRequest("POST", "/user/revoke", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Old token no longer works
+	assert.Equal(t, http.StatusUnauthorized, validate(cookie))
+
+	// Tokens that were issued with the old version are rejected even if their
+	// session is still tracked
+	_, token, err := ph.sessions.BeginSession("bobheadxi", api.RoleUser, 0, false, "")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, validate(&http.Cookie{Name: CookieName, Value: token}))
+
+	// Fresh login succeeds
+	assert.Equal(t, http.StatusOK, validate(login()))
+
+	// Unknown users cannot be revoked
+	body, err = json.Marshal(&api.UserRequest{Username: "nobody"})
+	assert.Nil(t, err)
+	req = httptest.NewRequest("POST", "/user/revoke", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServeHTTPLogoutAll(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func() []*http.Cookie {
+		body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		cookies := rec.Result().Cookies()
+		assert.Len(t, cookies, 2)
+		return cookies
+	}
+	var validate = func(cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", "/user/validate", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Log in on two machines
+	var shared, other = login(), login()
+	assert.Equal(t, http.StatusOK, validate(shared[0]))
+	assert.Equal(t, http.StatusOK, validate(other[0]))
+
+	// Log out everywhere from one of them
+	req := httptest.NewRequest("POST", "/user/logoutall", nil)
+	req.AddCookie(other[0])
+	req.AddCookie(other[1])
+	req.Header.Set(CSRFHeader, other[1].Value)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The current cookie is cleared
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	assert.Equal(t, CookieName, cookies[0].Name)
+	assert.Empty(t, cookies[0].Value)
+	assert.True(t, cookies[0].MaxAge < 0)
+
+	// Both sessions are rejected
+	assert.Equal(t, http.StatusUnauthorized, validate(shared[0]))
+	assert.Equal(t, http.StatusUnauthorized, validate(other[0]))
+
+	// Fresh login succeeds
+	assert.Equal(t, http.StatusOK, validate(login()[0]))
+
+	// Master tokens cannot be logged out
+	req = httptest.NewRequest("POST", "/user/logoutall", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServeHTTPListSessions(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("alice", "wowgreat", api.RoleUser))
+	assert.Nil(t, ph.users.AddUser("bob", "wowgreat", api.RoleUser))
+
+	var login = func(username string) string {
+		body, err := json.Marshal(&api.UserRequest{Username: username, Password: "wowgreat"})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/user/login", bytes.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return getTokenFromResponse(rec.Result().Body)
+	}
+	var list = func() []api.Session {
+		req := httptest.NewRequest("GET", "/user/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var sessions []api.Session
+		var total int
+		_, err := api.Unmarshal(rec.Body,
+			api.KV{Key: "sessions", Value: &sessions},
+			api.KV{Key: "total", Value: &total})
+		assert.Nil(t, err)
+		assert.Equal(t, len(sessions), total)
+		return sessions
+	}
+
+	// Both sessions are listed
+	var aliceToken = login("alice")
+	login("bob")
+	sessions := list()
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "alice", sessions[0].Username)
+	assert.Equal(t, "bob", sessions[1].Username)
+	for _, s := range sessions {
+		assert.NotEmpty(t, s.ID)
+		assert.Equal(t, "10.0.0.1", s.Address)
+		assert.False(t, s.IssuedAt.IsZero())
+		assert.True(t, s.Expiry.After(s.IssuedAt))
+	}
+
+	// Ended sessions are no longer listed
+	req := httptest.NewRequest("POST", "/user/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	sessions = list()
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "bob", sessions[0].Username)
+
+	// Users cannot list sessions
+	req = httptest.NewRequest("GET", "/user/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+login("bob"))
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Stores that cannot list sessions say so
+	ph.SetSessionStore(struct{ SessionStore }{NewMemorySessionStore()})
+	req = httptest.NewRequest("GET", "/user/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeNotSupported)
+}
+
+func TestServeHTTPListUsers(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	for name, role := range map[string]string{
+		"dave":  api.RoleUser,
+		"alice": api.RoleAdmin,
+		"carol": api.RoleViewer,
+		"bob":   api.RoleUser,
+	} {
+		assert.Nil(t, ph.users.AddUser(name, "wowgreat", role))
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantUsers []string
+		wantTotal int
+	}{
+		{"all", "", http.StatusOK, []string{"alice", "bob", "carol", "dave", "master"}, 5},
+		{"limited page", "limit=2", http.StatusOK, []string{"alice", "bob"}, 5},
+		{"last page", "limit=2&offset=4", http.StatusOK, []string{"master"}, 5},
+		{"offset past end", "offset=10", http.StatusOK, []string{}, 5},
+		{"only admins", "admin=true", http.StatusOK, []string{"alice", "master"}, 2},
+		{"only non-admins", "admin=false&limit=1&offset=1", http.StatusOK, []string{"carol"}, 3},
+		{"invalid limit", "limit=-1", http.StatusBadRequest, nil, 0},
+		{"invalid admin filter", "admin=sometimes", http.StatusBadRequest, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/list?"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+			rec := httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var users []string
+			var total int
+			_, err := api.Unmarshal(rec.Result().Body,
+				api.KV{Key: "users", Value: &users},
+				api.KV{Key: "total", Value: &total})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantUsers, users)
+			assert.Equal(t, tt.wantTotal, total)
+		})
+	}
+}
+
+func TestServeHTTPLastLogin(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleViewer))
+
+	var before = time.Now()
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req := httptest.NewRequest("GET", "/user/list?admin=false", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var details []api.UserInfo
+	_, err = api.Unmarshal(rec.Result().Body, api.KV{Key: "details", Value: &details})
+	assert.Nil(t, err)
+	assert.Len(t, details, 2)
+	assert.Equal(t, "bobheadxi", details[0].Username)
+	assert.NotNil(t, details[0].LastLogin)
+	assert.False(t, details[0].LastLogin.Before(before))
+	assert.Equal(t, api.UserInfo{Username: "chadlagore", Role: api.RoleViewer}, details[1])
+}
+
+func TestServeHTTPForceReset(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	var login = func(password, newPassword string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(&api.UserRequest{
+			Username: "bobheadxi", Password: password, NewPassword: newPassword})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		return rec
+	}
+	rec := login("wowgreat", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token := getTokenFromResponse(rec.Result().Body)
+
+	// only admins can require a reset
+	req := httptest.NewRequest("POST", "/user/forcereset", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest("POST", "/user/forcereset", nil)
+	req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// existing sessions are ended
+	req = httptest.NewRequest("GET", "/user/validate", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// login is blocked until a new password is provided
+	rec = login("wowgreat", "")
+	assert.Equal(t, http.StatusUpgradeRequired, rec.Code)
+	b, err := api.Unmarshal(rec.Result().Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodePasswordResetRequired, b.ErrCode)
+	assert.Equal(t, http.StatusBadRequest, login("wowgreat", "wowgreat").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("notright", "wowamazing").Code)
+
+	// logging in with a new password replaces the password and clears the flag
+	assert.Equal(t, http.StatusOK, login("wowgreat", "wowamazing").Code)
+	assert.Equal(t, http.StatusUnauthorized, login("wowgreat", "").Code)
+	assert.Equal(t, http.StatusOK, login("wowamazing", "").Code)
+}
+
+func TestServeHTTPPasswordResetTokens(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUserWithEmail("bobheadxi", "wowgreat", api.RoleUser, "bob@example.com"))
+
+	var post = func(path, token string, body interface{}) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var confirm = func(token, password string) *httptest.ResponseRecorder {
+		return post("/user/resetconfirm", "", &api.PasswordResetConfirmRequest{
+			Username: "bobheadxi", Token: token, NewPassword: password})
+	}
+
+	// only admins can issue reset tokens
+	rec := post("/user/resetrequest", "", &api.PasswordResetRequest{Username: "bobheadxi"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = post("/user/resetrequest", crypto.TestMasterToken, &api.PasswordResetRequest{Username: "nobody"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = post("/user/resetrequest", crypto.TestMasterToken, &api.PasswordResetRequest{Username: "bobheadxi"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var reset api.PasswordResetToken
+	_, err = api.Unmarshal(rec.Result().Body, api.KV{Key: "reset", Value: &reset})
+	assert.Nil(t, err)
+	assert.Equal(t, "bobheadxi", reset.Username)
+	assert.NotEmpty(t, reset.Token)
+
+	// log in, so that the session can be checked after the reset
+	rec = post("/user/login", "", &api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	session := getTokenFromResponse(rec.Result().Body)
+
+	// confirming does not require a session, but does require the token
+	rec = confirm("nottoken", "wowamazing")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	b, err := api.Unmarshal(rec.Result().Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeInvalidResetToken, b.ErrCode)
+	assert.Equal(t, http.StatusOK, confirm(reset.Token, "wowamazing").Code)
+
+	// tokens are single-use
+	rec = confirm(reset.Token, "wowsuperb")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// the new password works, and existing sessions are ended
+	rec = post("/user/login", "", &api.UserRequest{Username: "bobheadxi", Password: "wowamazing"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	req := httptest.NewRequest("GET", "/user/validate", nil)
+	req.Header.Set("Authorization", "Bearer "+session)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// expired tokens are rejected
+	ph.resetTimeout = -time.Second
+	rec = post("/user/resetrequest", crypto.TestMasterToken, &api.PasswordResetRequest{Username: "bobheadxi"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, err = api.Unmarshal(rec.Result().Body, api.KV{Key: "reset", Value: &reset})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, confirm(reset.Token, "wowsuperb").Code)
+
+	// emails are included in user info
+	infos, err := ph.users.UserInfo([]string{"bobheadxi"})
+	assert.Nil(t, err)
+	assert.Equal(t, "bob@example.com", infos[0].Email)
+	assert.Equal(t, errInvalidEmail, ph.users.AddUserWithEmail("alice", "wowgreat", api.RoleUser, "Alice <alice@example.com>"))
+}
+
+func TestServeHTTPExportAndRestore(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	var users = map[string]string{"bobheadxi": "wowgreat", "chadlagore": "chadlad"}
+	for username, password := range users {
+		assert.Nil(t, ph.users.AddUser(username, password, api.RoleUser))
+	}
+
+	var do = func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			assert.Nil(t, err)
+			payload = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, payload)
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var login = func(username, password string) int {
+		body, err := json.Marshal(&api.UserRequest{Username: username, Password: password})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	// export
+	rec := do("GET", "/user/export", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var db api.UserDatabase
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&db))
+	assert.Len(t, db.Users, 2)
+
+	// reset
+	_, confirm, err := ph.users.ResetPlan()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, do("POST", "/user/reset", api.UserResetRequest{Confirm: confirm}).Code)
+	for username, password := range users {
+		assert.Equal(t, http.StatusUnauthorized, login(username, password))
+	}
+
+	// restore
+	rec = do("POST", "/user/restore", api.UserRestoreRequest{UserDatabase: db, Replace: true})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	for username, password := range users {
+		assert.Equal(t, http.StatusOK, login(username, password), username)
+	}
+
+	// invalid snapshots are rejected
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/user/restore", api.UserRestoreRequest{}).Code)
+	db.Users["whoisthat"] = []byte(`{"HashedPassword":"hash","Role":"superuser"}`)
+	rec = do("POST", "/user/restore", api.UserRestoreRequest{UserDatabase: db})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NotNil(t, ph.users.HasUser("whoisthat"))
+}
+
+func TestServeHTTPWWWAuthenticate(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleViewer))
+
+	// unauthenticated requests are challenged
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("GET", "/user/validate", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `Bearer realm="inertia"`, rec.Header().Get("WWW-Authenticate"))
+
+	// failed logins are challenged
+	body, err := json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "notright"})
+	assert.Nil(t, err)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	// forbidden requests are not
+	body, err = json.Marshal(&api.UserRequest{Username: "bobheadxi", Password: "wowgreat"})
+	assert.Nil(t, err)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	req := httptest.NewRequest("GET", "/user/list", nil)
+	req.Header.Set("Authorization", "Bearer "+getTokenFromResponse(rec.Result().Body))
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+
+	// the realm is configurable
+	custom, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Realm: "staging",
+		Keys:  KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer custom.Close()
+	rec = httptest.NewRecorder()
+	custom.ServeHTTP(rec, httptest.NewRequest("GET", "/user/validate", nil))
+	assert.Equal(t, `Bearer realm="staging"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestServeHTTPRenameUser(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	var rename = func(username, newUsername string) *httptest.ResponseRecorder {
+		// unknown users do not have a revision
+		revision, _ := ph.users.Revision(username)
+		body, err := json.Marshal(&api.UserRequest{
+			Username: username, NewUsername: newUsername, Revision: &revision})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/user/rename", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var login = func(username, password string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(&api.UserRequest{Username: username, Password: password})
+		assert.Nil(t, err)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest("POST", "/user/login", bytes.NewReader(body)))
+		return rec
+	}
+	var validate = func(cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", "/user/validate", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Log in under the old name
+	rec := login("bobheadxi", "wowgreat")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var cookie = rec.Result().Cookies()[0]
+	assert.Equal(t, http.StatusOK, validate(cookie))
+
+	// Rename ends sessions under the old name
+	assert.Equal(t, http.StatusOK, rename("bobheadxi", "robert").Code)
+	assert.Equal(t, http.StatusUnauthorized, validate(cookie))
+
+	// The renamed user can log in with the same password
+	assert.Equal(t, http.StatusUnauthorized, login("bobheadxi", "wowgreat").Code)
+	rec = login("robert", "wowgreat")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, validate(rec.Result().Cookies()[0]))
+
+	// Collisions
+	rec = rename("robert", "chadlagore")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeUserExists)
+	_, correct, err := ph.users.IsCorrectCredentials("chadlagore", "chadlad")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+
+	// Reserved names
+	rec = rename("robert", "admin")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeUsernamePolicy)
+
+	// Missing and unknown users
+	assert.Equal(t, http.StatusBadRequest, rename("robert", "").Code)
+	assert.Equal(t, http.StatusNotFound, rename("nobody", "somebody").Code)
+}
+
+func TestServeHTTPSetAdmin(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+	assert.Nil(t, ph.users.AddUser("bobheadxi", "wowgreat", api.RoleAdmin))
+	assert.Nil(t, ph.users.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	var setAdmin = func(username string, admin bool) *httptest.ResponseRecorder {
+		// unknown users do not have a revision
+		revision, _ := ph.users.Revision(username)
+		body, err := json.Marshal(&api.UserRequest{
+			Username: username, Admin: admin, Revision: &revision})
+		assert.Nil(t, err)
+		req := httptest.NewRequest("POST", "/user/setadmin", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+crypto.TestMasterToken)
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec
+	}
+	var isAdmin = func(username string) bool {
+		admin, err := ph.users.IsAdmin(username)
+		assert.Nil(t, err)
+		return admin
+	}
+
+	// promotion
+	assert.Equal(t, http.StatusOK, setAdmin("chadlagore", true).Code)
+	assert.True(t, isAdmin("chadlagore"))
+
+	// demotion keeps the user's password
+	assert.Equal(t, http.StatusOK, setAdmin("bobheadxi", false).Code)
+	assert.False(t, isAdmin("bobheadxi"))
+	_, correct, err := ph.users.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+
+	// the last admin cannot be demoted
+	rec := setAdmin("chadlagore", false)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), api.ErrCodeLastAdmin)
+	assert.True(t, isAdmin("chadlagore"))
+
+	// unknown users
+	assert.Equal(t, http.StatusNotFound, setAdmin("nobody", true).Code)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Password policy rules, reported in PasswordPolicyError
+const (
+	RuleMinLength = "min-length"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleMixedCase = "mixed-case"
+)
+
+// Username policy rules, reported in UsernamePolicyError
+const (
+	RuleUsernameLength   = "length"
+	RuleUsernameCharset  = "charset"
+	RuleUsernameReserved = "reserved"
+)
+
+// Default username policy requirements
+const (
+	DefaultUsernameMinLength = 3
+	DefaultUsernameMaxLength = 64
+)
+
+// DefaultReservedUsernames are names that cannot be used by new users, since
+// they are easily confused with the remote's own routes or accounts
+var DefaultReservedUsernames = []string{
+	"admin", "root", "master", "system", "inertia",
+	"login", "logout", "validate", "whoami", "refresh",
+}
+
+// PasswordPolicy configures requirements that user passwords must satisfy, in
+// addition to the basic credential format checks. The zero value imposes no
+// additional requirements.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters in a password
+	MinLength int
+
+	// RequireDigit requires at least one digit
+	RequireDigit bool
+
+	// RequireSymbol requires at least one character that is not a letter or
+	// a digit
+	RequireSymbol bool
+
+	// RequireMixedCase requires at least one uppercase and one lowercase letter
+	RequireMixedCase bool
+}
+
+// PasswordPolicyError indicates a password that does not satisfy a rule of a
+// PasswordPolicy
+type PasswordPolicyError struct {
+	// Rule is the rule that the password does not satisfy
+	Rule string
+
+	message string
+}
+
+func (e *PasswordPolicyError) Error() string { return e.message }
+
+// UsernamePolicy configures requirements that the usernames of new users must
+// satisfy. Usernames may only contain letters, digits, '_', and '-'. Zero
+// values fall back to the defaults.
+type UsernamePolicy struct {
+	// MinLength and MaxLength bound the number of characters in a username
+	MinLength int
+	MaxLength int
+
+	// Reserved usernames cannot be used, regardless of case - if nil,
+	// DefaultReservedUsernames is used
+	Reserved []string
+}
+
+// UsernamePolicyError indicates a username that does not satisfy a rule of a
+// UsernamePolicy
+type UsernamePolicyError struct {
+	// Rule is the rule that the username does not satisfy
+	Rule string
+
+	message string
+}
+
+func (e *UsernamePolicyError) Error() string { return e.message }
+
+// Check returns a *UsernamePolicyError if the given username does not satisfy
+// the policy
+func (p UsernamePolicy) Check(username string) error {
+	var min, max, reserved = p.MinLength, p.MaxLength, p.Reserved
+	if min <= 0 {
+		min = DefaultUsernameMinLength
+	}
+	if max <= 0 {
+		max = DefaultUsernameMaxLength
+	}
+	if reserved == nil {
+		reserved = DefaultReservedUsernames
+	}
+
+	var length = 0
+	for _, c := range username {
+		length++
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '_', c == '-':
+		default:
+			return &UsernamePolicyError{RuleUsernameCharset,
+				fmt.Sprintf("username must only contain letters, digits, '_', or '-', not %q", c)}
+		}
+	}
+	if length < min || length > max {
+		return &UsernamePolicyError{RuleUsernameLength,
+			fmt.Sprintf("username must be between %d and %d characters", min, max)}
+	}
+	for _, name := range reserved {
+		if strings.EqualFold(username, name) {
+			return &UsernamePolicyError{RuleUsernameReserved,
+				fmt.Sprintf("username %q is reserved", username)}
+		}
+	}
+	return nil
+}
+
+// Check returns a *PasswordPolicyError if the given password does not satisfy
+// the policy
+func (p PasswordPolicy) Check(password string) error {
+	var length, digit, symbol, upper, lower = 0, false, false, false, false
+	for _, c := range password {
+		length++
+		switch {
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case !unicode.IsLetter(c):
+			symbol = true
+		}
+	}
+
+	switch {
+	case length < p.MinLength:
+		return &PasswordPolicyError{RuleMinLength,
+			fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	case p.RequireDigit && !digit:
+		return &PasswordPolicyError{RuleDigit,
+			"password must contain at least one digit"}
+	case p.RequireSymbol && !symbol:
+		return &PasswordPolicyError{RuleSymbol,
+			"password must contain at least one symbol"}
+	case p.RequireMixedCase && !(upper && lower):
+		return &PasswordPolicyError{RuleMixedCase,
+			"password must contain both uppercase and lowercase letters"}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	var strict = PasswordPolicy{
+		MinLength: 8, RequireDigit: true, RequireSymbol: true, RequireMixedCase: true}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		wantRule string
+	}{
+		{"no policy", PasswordPolicy{}, "pw", ""},
+		{"min length ok", PasswordPolicy{MinLength: 8}, "password", ""},
+		{"min length too short", PasswordPolicy{MinLength: 8}, "passwor", RuleMinLength},
+		{"digit ok", PasswordPolicy{RequireDigit: true}, "passw0rd", ""},
+		{"digit missing", PasswordPolicy{RequireDigit: true}, "password", RuleDigit},
+		{"symbol ok", PasswordPolicy{RequireSymbol: true}, "pass_word", ""},
+		{"symbol missing", PasswordPolicy{RequireSymbol: true}, "passw0rd", RuleSymbol},
+		{"mixed case ok", PasswordPolicy{RequireMixedCase: true}, "passWord", ""},
+		{"mixed case lower only", PasswordPolicy{RequireMixedCase: true}, "password", RuleMixedCase},
+		{"mixed case upper only", PasswordPolicy{RequireMixedCase: true}, "PASSWORD", RuleMixedCase},
+		{"combined ok", strict, "Pass_w0rd", ""},
+		{"combined too short", strict, "Pa_w0rd", RuleMinLength},
+		{"combined missing digit", strict, "Pass_word", RuleDigit},
+		{"combined missing symbol", strict, "Passw0rdd", RuleSymbol},
+		{"combined missing mixed case", strict, "pass_w0rd", RuleMixedCase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password)
+			if tt.wantRule == "" {
+				assert.Nil(t, err)
+				return
+			}
+			perr, ok := err.(*PasswordPolicyError)
+			assert.True(t, ok)
+			if ok {
+				assert.Equal(t, tt.wantRule, perr.Rule)
+				assert.NotEmpty(t, perr.Error())
+			}
+		})
+	}
+}
+
+func TestUsernamePolicy_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   UsernamePolicy
+		username string
+		wantRule string
+	}{
+		{"valid", UsernamePolicy{}, "bobheadxi", ""},
+		{"valid with symbols", UsernamePolicy{}, "bob_head-xi2", ""},
+		{"space", UsernamePolicy{}, "bob head", RuleUsernameCharset},
+		{"slash", UsernamePolicy{}, "bob/head", RuleUsernameCharset},
+		{"dot", UsernamePolicy{}, "bob.head", RuleUsernameCharset},
+		{"non-ascii", UsernamePolicy{}, "böbhead", RuleUsernameCharset},
+		{"too short", UsernamePolicy{}, "bo", RuleUsernameLength},
+		{"too long", UsernamePolicy{}, strings.Repeat("b", DefaultUsernameMaxLength+1), RuleUsernameLength},
+		{"custom length", UsernamePolicy{MinLength: 5, MaxLength: 8}, "bobhe", ""},
+		{"custom length too long", UsernamePolicy{MinLength: 5, MaxLength: 8}, "bobheadxi", RuleUsernameLength},
+		{"reserved", UsernamePolicy{}, "admin", RuleUsernameReserved},
+		{"reserved any case", UsernamePolicy{}, "Validate", RuleUsernameReserved},
+		{"custom reserved", UsernamePolicy{Reserved: []string{"bobheadxi"}}, "bobheadxi", RuleUsernameReserved},
+		{"custom reserved replaces defaults", UsernamePolicy{Reserved: []string{"bobheadxi"}}, "admin", ""},
+		{"nothing reserved", UsernamePolicy{Reserved: []string{}}, "root", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.username)
+			if tt.wantRule == "" {
+				assert.Nil(t, err)
+				return
+			}
+			uerr, ok := err.(*UsernamePolicyError)
+			assert.True(t, ok)
+			if ok {
+				assert.Equal(t, tt.wantRule, uerr.Rule)
+				assert.NotEmpty(t, uerr.Error())
+			}
+		})
+	}
+}
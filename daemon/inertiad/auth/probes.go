@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// Paths of the probes served by the PermissionsHandler, which are public and
+// exempt from IP filtering and rate limiting
+const (
+	healthPath = "/health"
+	readyPath  = "/ready"
+)
+
+// readyTimeout is how long the readiness probe waits on the user database
+const readyTimeout = 2 * time.Second
+
+// healthHandler reports that the daemon is up
+func (h *PermissionsHandler) healthHandler(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, res.MsgOK("daemon is up"))
+}
+
+// readyHandler reports whether the daemon is ready to serve requests, which
+// requires the user database to respond
+func (h *PermissionsHandler) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := h.users.withContext(ctx).Ping(); err != nil {
+		render.Render(w, r, res.Err("user database is not responding",
+			http.StatusServiceUnavailable,
+			"error", err))
+		return
+	}
+	render.Render(w, r, res.MsgOK("daemon is ready"))
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestServeHTTPProbes(t *testing.T) {
+	// probes are exempt from filtering and throttling, even though other public
+	// endpoints are not
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		RateLimit: &RateLimit{RequestsPerSecond: 1, Burst: 1},
+		IPFilter:  &IPFilter{Deny: []string{"192.0.2.1"}},
+		Keys:      KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	ph.AttachPublicHandlerFunc("/public", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var get = func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	assert.Equal(t, http.StatusForbidden, get("/public").Code)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, get("/health").Code)
+		assert.Equal(t, http.StatusOK, get("/ready").Code)
+	}
+
+	// probes only answer safe methods
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ready", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServeHTTPProbes_notReady(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	defer ph.Close()
+
+	// the daemon is still up, but not ready, once the user database is closed
+	assert.Nil(t, ph.users.Close())
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	b, err := api.Unmarshal(rec.Result().Body)
+	assert.Nil(t, err)
+	assert.Contains(t, b.Err, errStoreClosed.Error())
+}
+
+func TestServeHTTPProbes_shuttingDown(t *testing.T) {
+	ph, err := getTestPermissionsHandler()
+	assert.Nil(t, err)
+	assert.Nil(t, ph.Close())
+
+	// liveness probes are answered while shutting down, but readiness probes
+	// are not
+	rec := httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit configures per-client request rate limiting
+type RateLimit struct {
+	// RequestsPerSecond is the rate at which each client may make requests
+	// once it has used up its burst
+	RequestsPerSecond float64
+
+	// Burst is the number of requests each client may make at once
+	Burst int
+
+	// ExemptPublic exempts public endpoints, such as webhooks, from the limit.
+	// Endpoints served by the PermissionsHandler itself, such as logins, are
+	// always limited.
+	ExemptPublic bool
+}
+
+// bucket holds the tokens available to a client, as of the last time the
+// client made a request
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits requests from each client with a token bucket. Each
+// client's bucket holds up to burst tokens and refills at rate tokens per
+// second, and each request takes a token.
+type rateLimiter struct {
+	rate         float64
+	burst        float64
+	exemptPublic bool
+	now          func() time.Time
+
+	mux     sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// newRateLimiter creates a limiter from the given configuration, or returns
+// nil if the configuration does not limit requests
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	if limit == nil || limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+	var burst = limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:         limit.RequestsPerSecond,
+		burst:        float64(burst),
+		exemptPublic: limit.ExemptPublic,
+		now:          time.Now,
+		buckets:      make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the given client's bucket. If there are none left,
+// it returns false and how long the client should wait before trying again.
+func (l *rateLimiter) Allow(client string) (time.Duration, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	var now = l.now()
+	l.prune(now)
+	b, found := l.buckets[client]
+	if !found {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		var wait = (1 - b.tokens) / l.rate
+		return time.Duration(wait * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// prune discards buckets that would have refilled completely, which are no
+// different from new ones. Since every request is checked, buckets are pruned
+// at most once a second. Must be called while holding the lock.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Second {
+		return
+	}
+	l.pruned = now
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// remoteAddress returns the address that the given request was received from,
+// without its port
+func remoteAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(nil))
+	assert.Nil(t, newRateLimiter(&RateLimit{Burst: 10}))
+
+	var l = newRateLimiter(&RateLimit{RequestsPerSecond: 2})
+	assert.NotNil(t, l)
+	assert.Equal(t, float64(1), l.burst)
+}
+
+func TestRateLimiter(t *testing.T) {
+	var (
+		now = time.Now()
+		l   = newRateLimiter(&RateLimit{RequestsPerSecond: 2, Burst: 3})
+	)
+	l.now = func() time.Time { return now }
+
+	// burst is allowed, then clients must wait for a token
+	for i := 0; i < 3; i++ {
+		_, ok := l.Allow("1.2.3.4")
+		assert.True(t, ok)
+	}
+	wait, ok := l.Allow("1.2.3.4")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// other clients are unaffected
+	_, ok = l.Allow("5.6.7.8")
+	assert.True(t, ok)
+
+	// tokens refill over time
+	now = now.Add(500 * time.Millisecond)
+	_, ok = l.Allow("1.2.3.4")
+	assert.True(t, ok)
+	_, ok = l.Allow("1.2.3.4")
+	assert.False(t, ok)
+}
+
+func TestRateLimiter_prune(t *testing.T) {
+	var (
+		now = time.Now()
+		l   = newRateLimiter(&RateLimit{RequestsPerSecond: 1, Burst: 2})
+	)
+	l.now = func() time.Time { return now }
+	l.Allow("1.2.3.4")
+	l.Allow("5.6.7.8")
+	l.Allow("5.6.7.8")
+
+	// only full buckets are discarded
+	now = now.Add(time.Second)
+	l.Allow("9.9.9.9")
+	assert.Len(t, l.buckets, 2)
+	assert.NotContains(t, l.buckets, "1.2.3.4")
+}
+
+func TestServeHTTPRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		exemptPublic bool
+		path         string
+		requests     int
+		wantLimited  bool
+	}{
+		{"below limit", false, "/test", 3, false},
+		{"above limit", false, "/test", 4, true},
+		{"public exempt", true, "/test", 4, false},
+		{"login never exempt", true, "/user/login", 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+				RateLimit: &RateLimit{RequestsPerSecond: 1, Burst: 3, ExemptPublic: tt.exemptPublic},
+				Keys:      KeyLookupFunc(crypto.GetFakeAPIKey),
+			})
+			assert.Nil(t, err)
+			defer ph.Close()
+			var now = time.Now()
+			ph.limiter.now = func() time.Time { return now }
+			ph.AttachPublicHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				rec = httptest.NewRecorder()
+				ph.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			}
+			if tt.wantLimited {
+				assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+				assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+				assert.Contains(t, rec.Body.String(), api.ErrCodeRateLimited)
+			} else {
+				assert.NotEqual(t, http.StatusTooManyRequests, rec.Code)
+				assert.Empty(t, rec.Header().Get("Retry-After"))
+			}
+
+			// other clients are unaffected
+			var req = httptest.NewRequest(http.MethodPost, "/test", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			rec = httptest.NewRecorder()
+			ph.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+}
+
+func TestPermissionsHandler_rateLimitTrustedProxies(t *testing.T) {
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		RateLimit: &RateLimit{RequestsPerSecond: 1, Burst: 1},
+		IPFilter:  &IPFilter{TrustedProxies: 1},
+		Keys:      KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	defer ph.Close()
+	var now = time.Now()
+	ph.limiter.now = func() time.Time { return now }
+	ph.AttachPublicHandlerFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var request = func(forwardedFor string) int {
+		var req = httptest.NewRequest(http.MethodPost, "/test", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		var rec = httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// clients behind the same trusted proxy are limited separately
+	assert.Equal(t, http.StatusOK, request("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.1"))
+	assert.Equal(t, http.StatusOK, request("203.0.113.2"))
+
+	// entries before the trusted proxy's cannot be used to evade the limit
+	assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.1, 203.0.113.1"))
+}
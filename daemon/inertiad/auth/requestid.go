@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// RequestIDHeader is the header used to accept and echo request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request ID accepted from clients
+const maxRequestIDLength = 128
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	var id = make([]byte, 12)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// isValidRequestID returns true if the given request ID, supplied by a client,
+// is short and only contains printable ASCII characters, so that it is safe to
+// echo and log
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID identifies the given request with the request ID supplied by
+// the client, or a new one if none or an invalid one was supplied. The ID is
+// echoed in the response, and attached to the request context where it can be
+// retrieved with middleware.GetReqID.
+func (h *PermissionsHandler) withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	var id = r.Header.Get(RequestIDHeader)
+	if !isValidRequestID(id) {
+		id = h.requestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, id))
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// DefaultResetTokenTimeout is how long password reset tokens are valid for
+const DefaultResetTokenTimeout = time.Hour
+
+var (
+	errInvalidResetToken = errors.New("invalid or already used reset token")
+	errResetTokenExpired = errors.New("reset token has expired")
+)
+
+// CreateResetToken issues a token that allows the given user to set a new
+// password until the given timeout passes, and returns the token and its
+// expiry. Issuing a token replaces any token issued to the user before. Only a
+// hash of the token is stored, so the token cannot be retrieved again.
+func (m *userManager) CreateResetToken(username string, timeout time.Duration) (string, time.Time, error) {
+	token, err := common.GenerateRandomString()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate reset token: %s", err.Error())
+	}
+	var (
+		key    = []byte(username)
+		expiry = m.clock.Now().Add(timeout)
+	)
+	err = m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		props.ResetTokenHash = crypto.HashToken(token)
+		props.ResetTokenExpiry = expiry
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put(key, bytes)
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiry, nil
+}
+
+// ResetPassword replaces the given user's password if the given reset token is
+// valid. The token is cleared in the same transaction, so that it can only be
+// used once. Unknown users are reported as invalid tokens, so that they cannot
+// be told apart from real ones.
+func (m *userManager) ResetPassword(username, token, password string) error {
+	if err := m.validateCredentials(username, password); err != nil {
+		return err
+	}
+	hashedPassword, pepperVersion, err := m.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	var (
+		key  = []byte(username)
+		hash = crypto.HashToken(token)
+	)
+	return m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errInvalidResetToken
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if props.ResetTokenHash == "" ||
+			subtle.ConstantTimeCompare([]byte(props.ResetTokenHash), []byte(hash)) != 1 {
+			return errInvalidResetToken
+		}
+		if m.clock.Now().After(props.ResetTokenExpiry) {
+			return errResetTokenExpired
+		}
+		props.HashedPassword = hashedPassword
+		props.PepperVersion = pepperVersion
+		props.LoginAttempts = 0
+		props.PasswordResetRequired = false
+		props.ResetTokenHash = ""
+		props.ResetTokenExpiry = time.Time{}
+		props.Revision++
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put(key, bytes)
+	})
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestResetTokens(t *testing.T) {
+	dir := "./test_reset"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	// tokens can only be issued to existing users
+	_, _, err = manager.CreateResetToken("nobody", time.Hour)
+	assert.Equal(t, errUserNotFound, err)
+
+	// only a hash of the token is stored
+	token, expiry, err := manager.CreateResetToken("bobheadxi", time.Hour)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.True(t, expiry.After(time.Now()))
+	db, err := manager.Export()
+	assert.Nil(t, err)
+	assert.NotContains(t, string(db.Users["bobheadxi"]), token)
+	assert.Contains(t, string(db.Users["bobheadxi"]), crypto.HashToken(token))
+
+	// wrong tokens and unknown users are rejected alike
+	assert.Equal(t, errInvalidResetToken, manager.ResetPassword("bobheadxi", "nottoken", "wowamazing"))
+	assert.Equal(t, errInvalidResetToken, manager.ResetPassword("nobody", token, "wowamazing"))
+
+	// the token sets a new password
+	assert.Nil(t, manager.ResetPassword("bobheadxi", token, "wowamazing"))
+	_, correct, err := manager.IsCorrectCredentials("bobheadxi", "wowamazing")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+
+	// the token cannot be reused
+	assert.Equal(t, errInvalidResetToken, manager.ResetPassword("bobheadxi", token, "wowsuperb"))
+
+	// issuing a token replaces previous ones
+	first, _, err := manager.CreateResetToken("bobheadxi", time.Hour)
+	assert.Nil(t, err)
+	second, _, err := manager.CreateResetToken("bobheadxi", time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, errInvalidResetToken, manager.ResetPassword("bobheadxi", first, "wowsuperb"))
+
+	// updating the password clears the token
+	assert.Nil(t, manager.UpdatePassword("bobheadxi", "wowsuperb", anyRevision))
+	assert.Equal(t, errInvalidResetToken, manager.ResetPassword("bobheadxi", second, "wowstellar"))
+}
+
+func TestResetTokens_expired(t *testing.T) {
+	dir := "./test_reset_expired"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "wowgreat", api.RoleUser))
+
+	token, _, err := manager.CreateResetToken("bobheadxi", -time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, errResetTokenExpired, manager.ResetPassword("bobheadxi", token, "wowamazing"))
+	_, correct, err := manager.IsCorrectCredentials("bobheadxi", "wowgreat")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+}
@@ -5,42 +5,66 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/common"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 )
 
+// DefaultSessionTimeout is the default duration for which session tokens
+// issued on login are valid
+const DefaultSessionTimeout = 8 * time.Hour
+
+// DefaultRememberTimeout is the default duration for which session tokens
+// issued on login are valid if the user asked to be remembered
+const DefaultRememberTimeout = 30 * 24 * time.Hour
+
 var (
-	errSessionNotFound = errors.New("session not found")
-	errMalformedHeader = errors.New("authorization is malformed")
+	errSessionNotFound  = errors.New("session not found")
+	errSessionExpired   = errors.New("session expired - please log in again")
+	errMalformedHeader  = errors.New("authorization is malformed")
+	errMissingToken     = errors.New("no token was provided")
+	errMalformedToken   = errors.New("token is malformed")
+	errInvalidSignature = errors.New("token signature is invalid - it may have been " +
+		"issued by another daemon, or this daemon's keys may have changed")
+	errListNotSupported = errors.New("the session store does not support listing " +
+		"sessions - configure a session store that keeps track of sessions")
 )
 
 type sessionManager struct {
 	// sessionTimeout is the amount of time created Tokens are given to expire
 	sessionTimeout time.Duration
 
-	// internal is sessionManager's session store - it is protected by an RWMutex
-	internal map[string]*crypto.TokenClaims
-	sync.RWMutex
+	// rememberTimeout is the amount of time created Tokens are given to expire
+	// if the user asked to be remembered
+	rememberTimeout time.Duration
+
+	// store keeps track of sessions, so that they can be ended before their
+	// tokens expire
+	store SessionStore
 
-	// keyLookup implements jwt.Keyfunc and retrieves the key used to sign and
-	// validate JWT tokens
-	keyLookup func(*jwt.Token) (interface{}, error)
+	// keys retrieves the keys used to sign and validate JWT tokens
+	keys KeyLookup
+
+	// clock tells the time when issuing and validating tokens
+	clock Clock
 
 	// endSessionCleanup ends the goroutine that continually cleans up expired
 	// essions from memory
 	endSessionCleanup chan bool
 }
 
-func newSessionManager(domain string, timeout int,
-	keyLookup func(*jwt.Token) (interface{}, error)) *sessionManager {
+func newSessionManager(domain string, timeout time.Duration, keys KeyLookup) *sessionManager {
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
 	manager := &sessionManager{
-		sessionTimeout: time.Duration(timeout) * time.Minute,
-		internal:       make(map[string]*crypto.TokenClaims),
-		keyLookup:      keyLookup,
+		sessionTimeout:  timeout,
+		rememberTimeout: DefaultRememberTimeout,
+		store:           NewMemorySessionStore(),
+		keys:            keys,
+		clock:           systemClock{},
 
 		endSessionCleanup: make(chan bool),
 	}
@@ -54,13 +78,7 @@ func newSessionManager(domain string, timeout int,
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				manager.Lock()
-				for id, c := range manager.internal {
-					if c.Valid() != nil {
-						delete(manager.internal, id)
-					}
-				}
-				manager.Unlock()
+				manager.store.Prune()
 			}
 		}
 	}()
@@ -68,41 +86,44 @@ func newSessionManager(domain string, timeout int,
 	return manager
 }
 
+// Close ends the session cleanup job. Sessions are left in the session store,
+// which may be shared with other daemons.
 func (s *sessionManager) Close() {
 	s.endSessionCleanup <- true
-
-	s.Lock()
-	s.internal = make(map[string]*crypto.TokenClaims)
-	s.Unlock()
 }
 
 // SessionBegin starts a new session with user by generating a token and adding
-// session to memory
-func (s *sessionManager) BeginSession(username string, admin bool) (*crypto.TokenClaims, string, error) {
-	expiration := time.Now().Add(s.sessionTimeout)
+// session to the session store. The token carries the given token version of the user,
+// and expires after the remember timeout instead of the session timeout if
+// remember is set. The given client address is recorded with the session.
+func (s *sessionManager) BeginSession(username, role string, version int,
+	remember bool, address string) (*crypto.TokenClaims, string, error) {
+	var now = s.clock.Now()
+	expiration := now.Add(s.sessionTimeout)
+	if remember {
+		expiration = now.Add(s.rememberTimeout)
+	}
 	id, err := common.GenerateRandomString()
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to begin session for %s: %s", username, err.Error())
 	}
 
 	claims := &crypto.TokenClaims{
-		SessionID: id, User: username, Admin: admin, Expiry: expiration,
+		SessionID: id, User: username, Admin: role == api.RoleAdmin, Role: role,
+		Version: version, Expiry: expiration, Remember: remember,
+		IssuedAt: now.Unix(), Address: address,
 	}
 
 	// Sign a token for user
-	keyBytes, err := s.keyLookup(nil)
+	token, err := signToken(s.keys, claims)
 	if err != nil {
 		return nil, "", err
 	}
-	token, err := claims.GenerateToken(keyBytes.([]byte))
-	if err != nil {
+
+	// Add session to store
+	if err := s.store.Create(claims); err != nil {
 		return nil, "", err
 	}
-
-	// Add session to map
-	s.Lock()
-	s.internal[id] = claims
-	s.Unlock()
 	return claims, token, nil
 }
 
@@ -113,60 +134,125 @@ func (s *sessionManager) EndSession(r *http.Request) error {
 		return err
 	}
 
-	// Delete session from map
-	s.deleteSession(claims.SessionID)
-	return nil
+	// Delete session from store
+	return s.store.Revoke(claims.SessionID)
+}
+
+// tokenFromRequest retrieves the token from the given request's Authorization
+// header, or from the session cookie if there is no header
+func tokenFromRequest(r *http.Request) (string, error) {
+	if bearerString := r.Header.Get("Authorization"); bearerString != "" {
+		splitToken := strings.Split(bearerString, "Bearer ")
+		if len(splitToken) != 2 {
+			return "", errMalformedHeader
+		}
+		return splitToken[1], nil
+	} else if cookie, err := r.Cookie(CookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	return "", errMissingToken
 }
 
 // GetSession verifies if given request is from a valid session and returns it
 func (s *sessionManager) GetSession(r *http.Request) (*crypto.TokenClaims, error) {
-	// Check token in header - if no tokens, check cookie
-	bearerString := r.Header.Get("Authorization")
-	splitToken := strings.Split(bearerString, "Bearer ")
-	if len(splitToken) != 2 {
-		return nil, errMalformedHeader
+	token, err := tokenFromRequest(r)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate token and get claims
-	claims, err := crypto.ValidateToken(splitToken[1], s.keyLookup)
+	claims, err := crypto.ValidateTokenAt(token, keyfunc(s.keys), s.clock.Now())
 	if err != nil {
+		switch {
+		case crypto.IsTokenExpired(err):
+			return nil, errSessionExpired
+		case crypto.IsTokenMalformed(err):
+			return nil, errMalformedToken
+		case crypto.IsTokenSignatureInvalid(err):
+			return nil, errInvalidSignature
+		}
 		return nil, err
 	}
 
 	// Master tokens aren't session-tracked. TODO: reassess security of this
-	if claims.IsMaster() {
+	// Read-only tokens are tracked by the user manager, and must be checked
+	// against it for revocation.
+	if claims.IsMaster() || claims.ReadOnly {
 		return claims, nil
 	}
 
-	s.RLock()
-	_, found := s.internal[claims.SessionID]
-	if !found || claims.Valid() != nil {
-		s.RUnlock()
-		s.deleteSession(claims.SessionID)
-		return nil, errSessionNotFound
+	if _, err := s.store.Validate(claims.SessionID); err != nil {
+		return nil, err
 	}
-	s.RUnlock()
 	return claims, nil
 }
 
-// endAllUserSessions removes all active sessions with given user
-func (s *sessionManager) EndAllUserSessions(username string) {
-	for id, claim := range s.internal {
-		if claim.User == username {
-			s.deleteSession(id)
-		}
+// RefreshSession replaces the given session with a new one for the same user,
+// with a fresh expiry - remembered sessions remain remembered
+func (s *sessionManager) RefreshSession(claims *crypto.TokenClaims) (*crypto.TokenClaims, string, error) {
+	refreshed, token, err := s.BeginSession(claims.User, roleOf(claims), claims.Version,
+		claims.Remember, claims.Address)
+	if err != nil {
+		return nil, "", err
 	}
+	if err := s.store.Revoke(claims.SessionID); err != nil {
+		return nil, "", err
+	}
+	return refreshed, token, nil
+}
+
+// EndAllUserSessions removes all active sessions with given user
+func (s *sessionManager) EndAllUserSessions(username string) error {
+	return s.store.RevokeUser(username)
 }
 
 // EndAllSessions removes all active sessions
-func (s *sessionManager) EndAllSessions() {
-	s.Lock()
-	s.internal = make(map[string]*crypto.TokenClaims)
-	s.Unlock()
+func (s *sessionManager) EndAllSessions() error {
+	return s.store.RevokeAll()
 }
 
-func (s *sessionManager) deleteSession(sessionID string) {
-	s.Lock()
-	delete(s.internal, sessionID)
-	s.Unlock()
+// List returns the sessions that have not ended or expired, or
+// errListNotSupported if the session store cannot list sessions
+func (s *sessionManager) List() ([]api.Session, error) {
+	lister, ok := s.store.(SessionLister)
+	if !ok {
+		return nil, errListNotSupported
+	}
+	claims, err := lister.List()
+	if err != nil {
+		return nil, err
+	}
+	var sessions = make([]api.Session, len(claims))
+	for i, c := range claims {
+		sessions[i] = api.Session{
+			ID:       c.SessionID,
+			Username: c.User,
+			IssuedAt: time.Unix(c.IssuedAt, 0).UTC(),
+			Expiry:   c.Expiry,
+			Address:  c.Address,
+		}
+	}
+	return sessions, nil
+}
+
+// roleOf returns the role of the holder of the given token
+func roleOf(claims *crypto.TokenClaims) string {
+	switch {
+	case claims.Role != "":
+		return claims.Role
+	case claims.Admin:
+		return api.RoleAdmin
+	default:
+		return api.RoleUser
+	}
+}
+
+// Active returns the number of sessions that have not ended or expired, or
+// zero if the session store cannot be reached
+func (s *sessionManager) Active() int {
+	active, err := s.store.Count()
+	if err != nil {
+		return 0
+	}
+	return active
 }
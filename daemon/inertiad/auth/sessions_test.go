@@ -2,31 +2,32 @@ package auth
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 )
 
+func getTestSessionManager(t *testing.T) *sessionManager {
+	var store = NewMemorySessionStore()
+	var expiry = time.Now().Add(time.Hour)
+	assert.Nil(t, store.Create(&crypto.TokenClaims{SessionID: "1234", User: "bob", Expiry: expiry}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{SessionID: "5678", User: "chad", Expiry: expiry}))
+	return &sessionManager{store: store}
+}
+
 func Test_sessionManager_EndAllSessions(t *testing.T) {
-	// make two sessions because map is pointer
-	sessions := map[string]*crypto.TokenClaims{
-		"1234": {User: "bob"},
-	}
-	manager := &sessionManager{internal: map[string]*crypto.TokenClaims{
-		"1234": {User: "bob"},
-	}}
-	manager.EndAllSessions()
-	assert.False(t, assert.ObjectsAreEqualValues(sessions, manager.internal))
+	manager := getTestSessionManager(t)
+	assert.Nil(t, manager.EndAllSessions())
+	assert.Equal(t, 0, manager.Active())
 }
 
 func Test_sessionManager_EndAllUserSessions(t *testing.T) {
-	// make two sessions because map is pointer
-	sessions := map[string]*crypto.TokenClaims{
-		"1234": {User: "bob"},
-	}
-	manager := &sessionManager{internal: map[string]*crypto.TokenClaims{
-		"1234": {User: "bob"},
-	}}
-	manager.EndAllUserSessions("bob")
-	assert.False(t, assert.ObjectsAreEqualValues(sessions, manager.internal))
+	manager := getTestSessionManager(t)
+	assert.Nil(t, manager.EndAllUserSessions("bob"))
+	assert.Equal(t, 1, manager.Active())
+	_, err := manager.store.Validate("1234")
+	assert.Equal(t, errSessionNotFound, err)
+	_, err = manager.store.Validate("5678")
+	assert.Nil(t, err)
 }
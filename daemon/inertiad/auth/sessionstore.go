@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+// SessionStore keeps track of login sessions, so that sessions can be revoked
+// before their tokens expire. Implementations must be safe for concurrent use.
+// A store shared between daemons, such as one backed by Redis, allows any
+// daemon to validate sessions created by another.
+type SessionStore interface {
+	// Create records a new session, identified by the session ID of the given
+	// claims, that expires alongside the claims
+	Create(claims *crypto.TokenClaims) error
+
+	// Validate returns the claims of the session with the given ID, or
+	// errSessionNotFound if the session does not exist or has expired
+	Validate(sessionID string) (*crypto.TokenClaims, error)
+
+	// Revoke removes the session with the given ID, if it exists
+	Revoke(sessionID string) error
+
+	// Touch changes the expiry of the session with the given ID, or returns
+	// errSessionNotFound if the session does not exist or has expired
+	Touch(sessionID string, expiry time.Time) error
+
+	// RevokeUser removes all sessions of the given user
+	RevokeUser(username string) error
+
+	// RevokeAll removes all sessions
+	RevokeAll() error
+
+	// Count returns the number of sessions that have not expired
+	Count() (int, error)
+
+	// Prune removes expired sessions. Stores that expire sessions on their own
+	// can do nothing.
+	Prune() error
+}
+
+// SessionLister is implemented by session stores that can list the sessions
+// they keep, so that administrators can see who is logged in. Stores that
+// cannot, such as ones that only keep track of ended sessions, do not need to
+// implement it.
+type SessionLister interface {
+	// List returns the claims of all sessions that have not expired, ordered
+	// by username and then by when they were issued
+	List() ([]*crypto.TokenClaims, error)
+}
+
+// memorySessionStore is a SessionStore that keeps sessions in memory
+type memorySessionStore struct {
+	sessions map[string]*crypto.TokenClaims
+	clock    Clock
+	mux      sync.RWMutex
+}
+
+// NewMemorySessionStore creates a SessionStore that keeps sessions in memory.
+// Sessions are lost when the daemon restarts, and are not shared with other
+// daemons.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{
+		sessions: make(map[string]*crypto.TokenClaims),
+		clock:    systemClock{},
+	}
+}
+
+func (m *memorySessionStore) Create(claims *crypto.TokenClaims) error {
+	// Keep a copy, so that the session cannot be changed by the caller
+	var session = *claims
+	m.mux.Lock()
+	m.sessions[claims.SessionID] = &session
+	m.mux.Unlock()
+	return nil
+}
+
+func (m *memorySessionStore) Validate(sessionID string) (*crypto.TokenClaims, error) {
+	m.mux.RLock()
+	session, found := m.sessions[sessionID]
+	m.mux.RUnlock()
+	if !found || session.ValidAt(m.clock.Now()) != nil {
+		return nil, errSessionNotFound
+	}
+	var claims = *session
+	return &claims, nil
+}
+
+func (m *memorySessionStore) Revoke(sessionID string) error {
+	m.mux.Lock()
+	delete(m.sessions, sessionID)
+	m.mux.Unlock()
+	return nil
+}
+
+func (m *memorySessionStore) Touch(sessionID string, expiry time.Time) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	session, found := m.sessions[sessionID]
+	if !found || session.ValidAt(m.clock.Now()) != nil {
+		return errSessionNotFound
+	}
+	session.Expiry = expiry
+	return nil
+}
+
+func (m *memorySessionStore) RevokeUser(username string) error {
+	m.mux.Lock()
+	for id, session := range m.sessions {
+		if session.User == username {
+			delete(m.sessions, id)
+		}
+	}
+	m.mux.Unlock()
+	return nil
+}
+
+func (m *memorySessionStore) RevokeAll() error {
+	m.mux.Lock()
+	m.sessions = make(map[string]*crypto.TokenClaims)
+	m.mux.Unlock()
+	return nil
+}
+
+func (m *memorySessionStore) Count() (int, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	var count int
+	for _, session := range m.sessions {
+		if session.ValidAt(m.clock.Now()) == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memorySessionStore) List() ([]*crypto.TokenClaims, error) {
+	var now = m.clock.Now()
+	m.mux.RLock()
+	var sessions = make([]*crypto.TokenClaims, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		if session.ValidAt(now) == nil {
+			var claims = *session
+			sessions = append(sessions, &claims)
+		}
+	}
+	m.mux.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].User != sessions[j].User {
+			return sessions[i].User < sessions[j].User
+		}
+		if sessions[i].IssuedAt != sessions[j].IssuedAt {
+			return sessions[i].IssuedAt < sessions[j].IssuedAt
+		}
+		return sessions[i].SessionID < sessions[j].SessionID
+	})
+	return sessions, nil
+}
+
+func (m *memorySessionStore) Prune() error {
+	m.mux.Lock()
+	for id, session := range m.sessions {
+		if session.ValidAt(m.clock.Now()) != nil {
+			delete(m.sessions, id)
+		}
+	}
+	m.mux.Unlock()
+	return nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	var store = NewMemorySessionStore()
+	var claims = &crypto.TokenClaims{
+		SessionID: "1234", User: "bob", Expiry: time.Now().Add(time.Hour)}
+
+	// create and validate
+	assert.Nil(t, store.Create(claims))
+	session, err := store.Validate("1234")
+	assert.Nil(t, err)
+	assert.Equal(t, claims, session)
+	_, err = store.Validate("5678")
+	assert.Equal(t, errSessionNotFound, err)
+
+	// sessions are copied
+	claims.User = "chad"
+	session, err = store.Validate("1234")
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", session.User)
+
+	// revoke
+	assert.Nil(t, store.Revoke("1234"))
+	_, err = store.Validate("1234")
+	assert.Equal(t, errSessionNotFound, err)
+	assert.Nil(t, store.Revoke("1234"))
+
+	// revoke by user, and revoke all
+	var expiry = time.Now().Add(time.Hour)
+	assert.Nil(t, store.Create(&crypto.TokenClaims{SessionID: "1", User: "bob", Expiry: expiry}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{SessionID: "2", User: "bob", Expiry: expiry}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{SessionID: "3", User: "chad", Expiry: expiry}))
+	assert.Nil(t, store.RevokeUser("bob"))
+	count, err := store.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Nil(t, store.RevokeAll())
+	count, err = store.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMemorySessionStore_List(t *testing.T) {
+	var store = NewMemorySessionStore()
+	var now = time.Now()
+	assert.Nil(t, store.Create(&crypto.TokenClaims{
+		SessionID: "1", User: "chad", IssuedAt: now.Unix(), Expiry: now.Add(time.Hour)}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{
+		SessionID: "2", User: "bob", IssuedAt: now.Unix(), Expiry: now.Add(time.Hour)}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{
+		SessionID: "3", User: "bob", IssuedAt: now.Add(-time.Hour).Unix(), Expiry: now.Add(-time.Minute)}))
+
+	// expired sessions are left out, and sessions are ordered by user
+	sessions, err := store.(SessionLister).List()
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "2", sessions[0].SessionID)
+	assert.Equal(t, "1", sessions[1].SessionID)
+
+	assert.Nil(t, store.Revoke("2"))
+	sessions, err = store.(SessionLister).List()
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "chad", sessions[0].User)
+}
+
+func TestMemorySessionStore_expiry(t *testing.T) {
+	var store = NewMemorySessionStore()
+	assert.Nil(t, store.Create(&crypto.TokenClaims{
+		SessionID: "1234", User: "bob", Expiry: time.Now().Add(time.Hour)}))
+	assert.Nil(t, store.Create(&crypto.TokenClaims{
+		SessionID: "5678", User: "bob", Expiry: time.Now().Add(-time.Second)}))
+
+	// expired sessions are not valid, and are not counted
+	_, err := store.Validate("5678")
+	assert.Equal(t, errSessionNotFound, err)
+	assert.Equal(t, errSessionNotFound, store.Touch("5678", time.Now().Add(time.Hour)))
+	count, err := store.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	// touching a session changes its expiry
+	assert.Nil(t, store.Touch("1234", time.Now().Add(-time.Second)))
+	_, err = store.Validate("1234")
+	assert.Equal(t, errSessionNotFound, err)
+
+	// pruning removes expired sessions
+	assert.Nil(t, store.Prune())
+	assert.Len(t, store.(*memorySessionStore).sessions, 0)
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MemoryStore can be used in place of a database path to keep users in memory
+// instead of on disk. Users kept in memory are lost when the handler is
+// closed, so this is only intended for tests.
+const MemoryStore = ":memory:"
+
+var (
+	errBucketExists   = errors.New("bucket already exists")
+	errBucketNotFound = errors.New("bucket not found")
+	errTxNotWritable  = errors.New("transaction not writable")
+	errStoreClosed    = errors.New("database is closed")
+)
+
+// store is a key/value database made up of buckets, following the subset of
+// bolt's API used by userManager
+type store interface {
+	// View runs fn in a read-only transaction
+	View(fn func(storeTx) error) error
+
+	// Update runs fn in a read-write transaction, which is only committed if
+	// fn does not return an error
+	Update(fn func(storeTx) error) error
+
+	Close() error
+}
+
+// storeTx is a transaction on a store
+type storeTx interface {
+	// Bucket returns the bucket with the given name, or nil if there is none
+	Bucket(name []byte) storeBucket
+	CreateBucket(name []byte) (storeBucket, error)
+	CreateBucketIfNotExists(name []byte) (storeBucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// storeBucket is a collection of key/value pairs in a store
+type storeBucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	ForEach(fn func(k, v []byte) error) error
+}
+
+// openStore opens the database at dbPath, or an empty in-memory database if
+// dbPath is MemoryStore
+func openStore(dbPath string) (store, error) {
+	if dbPath == MemoryStore {
+		return newMemStore(), nil
+	}
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &boltStore{db}, nil
+}
+
+// contextStore runs transactions on behalf of a request, and stops waiting on
+// them once the request's context is done. Transactions that have not started
+// by then are not run at all - transactions that have started are left to
+// finish in the background.
+type contextStore struct {
+	store
+	ctx context.Context
+}
+
+func (s *contextStore) View(fn func(storeTx) error) error {
+	return s.run(func() error { return s.store.View(s.guard(fn)) })
+}
+
+func (s *contextStore) Update(fn func(storeTx) error) error {
+	return s.run(func() error { return s.store.Update(s.guard(fn)) })
+}
+
+// guard wraps fn so that it is skipped if the context is done by the time the
+// transaction starts, for example after waiting on other writers
+func (s *contextStore) guard(fn func(storeTx) error) func(storeTx) error {
+	return func(tx storeTx) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+}
+
+func (s *contextStore) run(tx func() error) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	var done = make(chan error, 1)
+	go func() { done <- tx() }()
+	select {
+	case err := <-done:
+		return err
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// boltStore is a store backed by a bolt database on disk
+type boltStore struct{ db *bolt.DB }
+
+func (s *boltStore) View(fn func(storeTx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error { return fn(&boltTx{tx}) })
+}
+
+func (s *boltStore) Update(fn func(storeTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return fn(&boltTx{tx}) })
+}
+
+func (s *boltStore) Close() error { return s.db.Close() }
+
+type boltTx struct{ tx *bolt.Tx }
+
+func (t *boltTx) Bucket(name []byte) storeBucket {
+	if b := t.tx.Bucket(name); b != nil {
+		return b
+	}
+	return nil
+}
+
+func (t *boltTx) CreateBucket(name []byte) (storeBucket, error) {
+	b, err := t.tx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t *boltTx) CreateBucketIfNotExists(name []byte) (storeBucket, error) {
+	b, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t *boltTx) DeleteBucket(name []byte) error { return t.tx.DeleteBucket(name) }
+
+// memStore is a store kept in memory. Updates are made on a copy of the data,
+// which replaces the original once the update succeeds.
+type memStore struct {
+	mux     sync.RWMutex
+	buckets map[string]map[string][]byte
+	closed  bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{buckets: make(map[string]map[string][]byte)}
+}
+
+func (s *memStore) View(fn func(storeTx) error) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if s.closed {
+		return errStoreClosed
+	}
+	return fn(&memTx{buckets: s.buckets})
+}
+
+func (s *memStore) Update(fn func(storeTx) error) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return errStoreClosed
+	}
+	var buckets = make(map[string]map[string][]byte, len(s.buckets))
+	for name, data := range s.buckets {
+		var copied = make(map[string][]byte, len(data))
+		for k, v := range data {
+			copied[k] = v
+		}
+		buckets[name] = copied
+	}
+	if err := fn(&memTx{buckets: buckets, writable: true}); err != nil {
+		return err
+	}
+	s.buckets = buckets
+	return nil
+}
+
+func (s *memStore) Close() error {
+	s.mux.Lock()
+	s.closed = true
+	s.mux.Unlock()
+	return nil
+}
+
+type memTx struct {
+	buckets  map[string]map[string][]byte
+	writable bool
+}
+
+func (t *memTx) Bucket(name []byte) storeBucket {
+	data, found := t.buckets[string(name)]
+	if !found {
+		return nil
+	}
+	return &memBucket{data: data, writable: t.writable}
+}
+
+func (t *memTx) CreateBucket(name []byte) (storeBucket, error) {
+	if !t.writable {
+		return nil, errTxNotWritable
+	}
+	if _, found := t.buckets[string(name)]; found {
+		return nil, errBucketExists
+	}
+	t.buckets[string(name)] = make(map[string][]byte)
+	return t.Bucket(name), nil
+}
+
+func (t *memTx) CreateBucketIfNotExists(name []byte) (storeBucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+	return t.CreateBucket(name)
+}
+
+func (t *memTx) DeleteBucket(name []byte) error {
+	if !t.writable {
+		return errTxNotWritable
+	}
+	if _, found := t.buckets[string(name)]; !found {
+		return errBucketNotFound
+	}
+	delete(t.buckets, string(name))
+	return nil
+}
+
+type memBucket struct {
+	data     map[string][]byte
+	writable bool
+}
+
+func (b *memBucket) Get(key []byte) []byte { return b.data[string(key)] }
+
+func (b *memBucket) Put(key, value []byte) error {
+	if !b.writable {
+		return errTxNotWritable
+	}
+	b.data[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+func (b *memBucket) Delete(key []byte) error {
+	if !b.writable {
+		return errTxNotWritable
+	}
+	delete(b.data, string(key))
+	return nil
+}
+
+// ForEach iterates over pairs in key order, like bolt
+func (b *memBucket) ForEach(fn func(k, v []byte) error) error {
+	var keys = make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn([]byte(k), b.data[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestUserManager_stores(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbPath func(t *testing.T) (string, func())
+	}{
+		{"bolt", func(t *testing.T) (string, func()) {
+			dir := "./test_users_store"
+			assert.Nil(t, os.Mkdir(dir, os.ModePerm))
+			return path.Join(dir, "users.db"), func() { os.RemoveAll(dir) }
+		}},
+		{"memory", func(t *testing.T) (string, func()) {
+			return MemoryStore, func() {}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath, cleanup := tt.dbPath(t)
+			defer cleanup()
+			manager, err := newUserManager(dbPath, 0)
+			assert.Nil(t, err)
+			defer manager.Close()
+
+			assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+			assert.Nil(t, manager.AddUser("whoisthat", "ummmmmmmmmm", api.RoleUser))
+			assert.Equal(t, []string{"bobheadxi", "master", "whoisthat"}, manager.UserList())
+
+			assert.Nil(t, manager.HasUser("bobheadxi"))
+			_, correct, err := manager.IsCorrectCredentials("bobheadxi", "best_person_ever")
+			assert.Nil(t, err)
+			assert.True(t, correct)
+
+			assert.Nil(t, manager.RemoveUser("bobheadxi"))
+			assert.Equal(t, errUserNotFound, manager.HasUser("bobheadxi"))
+			assert.Equal(t, errUserNotFound, manager.RemoveUser("bobheadxi"))
+
+			// failed imports leave no trace
+			_, err = manager.ImportUsers([]api.UserRequest{
+				{Username: "alice", Password: "wowgreat"},
+				{Username: "whoisthat", Password: "wowgreat"},
+			})
+			assert.Equal(t, errUserExists, err)
+			assert.Equal(t, errUserNotFound, manager.HasUser("alice"))
+
+			assert.Nil(t, manager.Reset())
+			assert.Empty(t, manager.UserList())
+		})
+	}
+}
+
+func TestMemStore_Update(t *testing.T) {
+	var (
+		s      = newMemStore()
+		bucket = []byte("bucket")
+	)
+	assert.Nil(t, s.Update(func(tx storeTx) error {
+		b, err := tx.CreateBucket(bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("a"), []byte("1"))
+	}))
+
+	// failed updates are discarded
+	assert.EqualError(t, s.Update(func(tx storeTx) error {
+		tx.Bucket(bucket).Put([]byte("b"), []byte("2"))
+		tx.Bucket(bucket).Delete([]byte("a"))
+		return errors.New("oh no")
+	}), "oh no")
+
+	// views cannot write
+	assert.Nil(t, s.View(func(tx storeTx) error {
+		b := tx.Bucket(bucket)
+		assert.Equal(t, []byte("1"), b.Get([]byte("a")))
+		assert.Nil(t, b.Get([]byte("b")))
+		assert.Equal(t, errTxNotWritable, b.Put([]byte("b"), []byte("2")))
+		assert.Nil(t, tx.Bucket([]byte("nope")))
+		return nil
+	}))
+}
+
+func TestContextStore(t *testing.T) {
+	var mem = newMemStore()
+	assert.Nil(t, mem.Update(func(tx storeTx) error {
+		_, err := tx.CreateBucket([]byte("users"))
+		return err
+	}))
+
+	// transactions are not run once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	var s = &contextStore{store: mem, ctx: ctx}
+	var ran bool
+	assert.Nil(t, s.View(func(storeTx) error { ran = true; return nil }))
+	assert.True(t, ran)
+	cancel()
+	ran = false
+	assert.Equal(t, context.Canceled, s.Update(func(storeTx) error { ran = true; return nil }))
+	assert.False(t, ran)
+
+	// waiting on other transactions stops once the context is done
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s = &contextStore{store: mem, ctx: ctx}
+	mem.mux.Lock()
+	var start = time.Now()
+	assert.Equal(t, context.DeadlineExceeded, s.Update(func(storeTx) error { return nil }))
+	assert.True(t, time.Since(start) < time.Second)
+	mem.mux.Unlock()
+
+	// transactions that start after the context is done are skipped
+	ran = false
+	assert.Equal(t, context.DeadlineExceeded, s.guard(func(storeTx) error { ran = true; return nil })(nil))
+	assert.False(t, ran)
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+var errTokenNotFound = errors.New("token not found")
+
+// AddReadOnlyToken creates a new read-only token with the given name, signed
+// with the signing key from the given KeyLookup, and returns its ID and the
+// token. Only a hash of the token is stored, so the token cannot be retrieved
+// again. The token remains valid until it is revoked.
+func (m *userManager) AddReadOnlyToken(name string, keys KeyLookup) (string, string, error) {
+	if name == "" {
+		return "", "", errors.New("a token name is required")
+	}
+	id, err := common.GenerateRandomString()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate token ID: %s", err.Error())
+	}
+	var claims = &crypto.TokenClaims{SessionID: id, User: name, ReadOnly: true}
+	token, err := signToken(keys, claims)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %s", err.Error())
+	}
+	bytes, err := json.Marshal(&api.ReadOnlyToken{
+		ID:        id,
+		Name:      name,
+		CreatedAt: m.clock.Now(),
+		Hash:      crypto.HashToken(token),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if err = m.db.Update(func(tx storeTx) error {
+		return tx.Bucket(m.tokensBucket).Put([]byte(id), bytes)
+	}); err != nil {
+		return "", "", err
+	}
+	return id, token, nil
+}
+
+// RemoveReadOnlyToken revokes the read-only token with given ID
+func (m *userManager) RemoveReadOnlyToken(id string) error {
+	var key = []byte(id)
+	return m.db.Update(func(tx storeTx) error {
+		tokens := tx.Bucket(m.tokensBucket)
+		if tokens.Get(key) == nil {
+			return errTokenNotFound
+		}
+		return tokens.Delete(key)
+	})
+}
+
+// IsValidReadOnlyToken returns true if the read-only token with given ID has
+// not been revoked, and the given token matches its stored hash. Tokens created
+// before hashes were stored have the hash of the given token stored on first
+// use.
+func (m *userManager) IsValidReadOnlyToken(id, token string) bool {
+	var (
+		key    = []byte(id)
+		hash   = crypto.HashToken(token)
+		stored api.ReadOnlyToken
+		found  bool
+	)
+	m.db.View(func(tx storeTx) error {
+		v := tx.Bucket(m.tokensBucket).Get(key)
+		if v == nil {
+			return nil
+		}
+		found = json.Unmarshal(v, &stored) == nil
+		return nil
+	})
+	if !found {
+		return false
+	}
+
+	if stored.Hash == "" {
+		if err := m.db.Update(func(tx storeTx) error {
+			tokens := tx.Bucket(m.tokensBucket)
+			v := tokens.Get(key)
+			if v == nil {
+				return errTokenNotFound
+			}
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return errors.New("corrupt token properties: " + err.Error())
+			}
+			if stored.Hash != "" {
+				return nil
+			}
+			stored.Hash = hash
+			bytes, err := json.Marshal(&stored)
+			if err != nil {
+				return err
+			}
+			return tokens.Put(key, bytes)
+		}); err != nil {
+			return false
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hash)) == 1
+}
+
+// ReadOnlyTokenList returns all read-only tokens that have not been revoked
+func (m *userManager) ReadOnlyTokenList() ([]api.ReadOnlyToken, error) {
+	var tokens = make([]api.ReadOnlyToken, 0)
+	err := m.db.View(func(tx storeTx) error {
+		return tx.Bucket(m.tokensBucket).ForEach(func(id, v []byte) error {
+			var token api.ReadOnlyToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return errors.New("corrupt token properties: " + err.Error())
+			}
+			tokens = append(tokens, token)
+			return nil
+		})
+	})
+	return tokens, err
+}
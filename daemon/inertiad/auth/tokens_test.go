@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestReadOnlyTokens(t *testing.T) {
+	dir := "./test_tokens"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	_, _, err = manager.AddReadOnlyToken("", KeyLookupFunc(crypto.GetFakeAPIKey))
+	assert.NotNil(t, err)
+
+	id, token, err := manager.AddReadOnlyToken("dashboard", KeyLookupFunc(crypto.GetFakeAPIKey))
+	assert.Nil(t, err)
+	claims, err := crypto.ValidateToken(token, crypto.GetFakeAPIKey)
+	assert.Nil(t, err)
+	assert.Equal(t, id, claims.SessionID)
+	assert.True(t, claims.ReadOnly)
+	assert.False(t, claims.Admin)
+	assert.True(t, manager.IsValidReadOnlyToken(id, token))
+
+	// tokens should survive user resets
+	assert.Nil(t, manager.Reset())
+	tokens, err := manager.ReadOnlyTokenList()
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, id, tokens[0].ID)
+	assert.Equal(t, crypto.HashToken(token), tokens[0].Hash)
+
+	assert.Nil(t, manager.RemoveReadOnlyToken(id))
+	assert.False(t, manager.IsValidReadOnlyToken(id, token))
+	assert.Equal(t, errTokenNotFound, manager.RemoveReadOnlyToken(id))
+}
+
+func TestReadOnlyTokens_hashed(t *testing.T) {
+	dir := "./test_tokens"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	id, token, err := manager.AddReadOnlyToken("dashboard", KeyLookupFunc(crypto.GetFakeAPIKey))
+	assert.Nil(t, err)
+
+	// the token itself is never stored
+	assert.Nil(t, manager.db.View(func(tx storeTx) error {
+		return tx.Bucket(manager.tokensBucket).ForEach(func(k, v []byte) error {
+			assert.False(t, bytes.Contains(v, []byte(token)))
+			return nil
+		})
+	}))
+
+	// tokens for the same ID that do not match the stored hash are rejected,
+	// even if they are signed with the right key
+	forged, err := (&crypto.TokenClaims{SessionID: id, User: "evil", ReadOnly: true}).
+		GenerateToken(crypto.TestPrivateKey)
+	assert.Nil(t, err)
+	assert.False(t, manager.IsValidReadOnlyToken(id, forged))
+	assert.True(t, manager.IsValidReadOnlyToken(id, token))
+}
+
+func TestReadOnlyTokens_legacy(t *testing.T) {
+	dir := "./test_tokens"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	// tokens created before hashes were stored are hashed on first use
+	legacy, err := json.Marshal(&api.ReadOnlyToken{
+		ID: "legacy", Name: "dashboard", CreatedAt: time.Now()})
+	assert.Nil(t, err)
+	assert.Nil(t, manager.db.Update(func(tx storeTx) error {
+		return tx.Bucket(manager.tokensBucket).Put([]byte("legacy"), legacy)
+	}))
+	token, err := (&crypto.TokenClaims{SessionID: "legacy", User: "dashboard", ReadOnly: true}).
+		GenerateToken(crypto.TestPrivateKey)
+	assert.Nil(t, err)
+	assert.True(t, manager.IsValidReadOnlyToken("legacy", token))
+	tokens, err := manager.ReadOnlyTokenList()
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, crypto.HashToken(token), tokens[0].Hash)
+
+	// after which other tokens are rejected
+	assert.False(t, manager.IsValidReadOnlyToken("legacy", token+"x"))
+}
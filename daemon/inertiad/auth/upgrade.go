@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTokenQueryParam is the default query parameter that websocket
+// upgrade requests may provide a bearer token in
+const DefaultTokenQueryParam = "access_token"
+
+// SetTokenQueryParam configures the query parameter that websocket upgrade
+// requests to upgrade-capable paths may provide a bearer token in. Invalid
+// values fall back to DefaultTokenQueryParam.
+func (h *PermissionsHandler) SetTokenQueryParam(param string) {
+	if param == "" {
+		param = DefaultTokenQueryParam
+	}
+	h.tokenParam = param
+}
+
+// AllowUpgrades marks the given paths, and their subpaths, as upgrade-capable.
+// Browsers cannot set headers on websocket upgrade requests, so upgrades to
+// these paths may provide a bearer token in the token query parameter instead
+// of the Authorization header.
+func (h *PermissionsHandler) AllowUpgrades(paths ...string) {
+	h.upgradePaths = append(h.upgradePaths, paths...)
+}
+
+// isUpgradeCapable checks if given request path may be upgraded
+func (h *PermissionsHandler) isUpgradeCapable(path string) bool {
+	for _, prefix := range h.upgradePaths {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// withQueryToken removes the token query parameter from the given request, so
+// that the token is never logged. If the request is a websocket upgrade to an
+// upgrade-capable path and has no Authorization header, the token is provided
+// in the header instead. Otherwise, the token is dropped.
+func (h *PermissionsHandler) withQueryToken(r *http.Request) *http.Request {
+	var query = r.URL.Query()
+	if _, ok := query[h.tokenParam]; !ok {
+		return r
+	}
+	var token = query.Get(h.tokenParam)
+	query.Del(h.tokenParam)
+
+	// Copy the parts of the request that are changed, since they are shared
+	// with the original request
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	var req = r.WithContext(r.Context())
+	req.URL = &u
+	req.RequestURI = u.RequestURI()
+	req.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+
+	if token != "" && req.Header.Get("Authorization") == "" &&
+		websocket.IsWebSocketUpgrade(req) && h.isUpgradeCapable(req.URL.Path) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
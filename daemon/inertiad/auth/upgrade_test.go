@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+)
+
+func TestServeHTTPQueryToken(t *testing.T) {
+	var audit, logs = &bytes.Buffer{}, &bytes.Buffer{}
+	ph, err := NewPermissionsHandler(MemoryStore, "127.0.0.1", HandlerOptions{
+		Audit: audit,
+		Keys:  KeyLookupFunc(crypto.GetFakeAPIKey),
+	})
+	assert.Nil(t, err)
+	var logged = func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(logs, "%s %s %s\n", r.Method, r.RequestURI, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}
+	ph.AttachReadOnlyHandlerFunc("/logs", logged)
+	ph.AttachUserRestrictedHandlerFunc("/status", logged)
+	ph.AllowUpgrades("/logs")
+
+	var get = func(path string, upgrade bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	var token = crypto.TestMasterToken
+
+	// upgrades to upgrade-capable paths may authenticate with the query token
+	assert.Equal(t, http.StatusOK, get("/logs?follow=true&access_token="+token, true))
+	assert.Contains(t, logs.String(), "/logs?follow=true")
+
+	// query tokens are ignored on other requests
+	assert.Equal(t, http.StatusUnauthorized, get("/logs?access_token="+token, false))
+	assert.Equal(t, http.StatusUnauthorized, get("/status?access_token="+token, true))
+
+	// the query parameter can be configured
+	ph.SetTokenQueryParam("token")
+	assert.Equal(t, http.StatusOK, get("/logs?token="+token, true))
+	assert.Equal(t, http.StatusUnauthorized, get("/logs?access_token="+token, true))
+
+	// tokens never make it into log lines
+	assert.Nil(t, ph.Close())
+	assert.NotEmpty(t, logs.String())
+	assert.NotEmpty(t, audit.String())
+	assert.NotContains(t, logs.String(), token)
+	assert.NotContains(t, audit.String(), token)
+}
@@ -1,63 +1,195 @@
 package auth
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
-	bolt "go.etcd.io/bbolt"
 )
 
 var (
 	errUserNotFound       = errors.New("user not found")
 	errBackupCodeNotFound = errors.New("backup code not found")
 	errMissingCredentials = errors.New("no credentials provided")
+	errUserExists         = errors.New("user already exists")
+	errInvalidRole        = errors.New("role must be one of 'admin', 'user', or 'viewer'")
+	errLastAdmin          = errors.New("cannot demote the last remaining admin")
+	errStaleRevision      = errors.New("user has been modified since it was last read")
+	errTotpEnabled        = errors.New("TOTP is already enabled on this user")
+	errTotpNotEnrolled    = errors.New("no TOTP enrollment in progress")
+	errInvalidTotp        = errors.New("invalid TOTP provided")
+	errTotpKeyMissing     = errors.New("no key available to decrypt TOTP secret")
+	errInvalidUserRecord  = errors.New("invalid user record")
+	errInvalidEmail       = errors.New("invalid email address")
+	errResetNotConfirmed  = errors.New("reset has not been confirmed, or users have changed since it was planned")
+	errPepperNotFound     = errors.New("no pepper available for password")
 )
 
 const (
 	loginAttemptsLimit = 10
+
+	// sealedTotpPrefix marks TOTP secrets that are stored encrypted
+	sealedTotpPrefix = "sealed:"
+
+	// masterUser is the built-in user that master tokens are issued to. It
+	// cannot log in, so it is not counted as a real user.
+	masterUser = "master"
 )
 
 // userProps are properties associated with user, used
 // for database entries
 type userProps struct {
 	HashedPassword  string
-	Admin           bool
+	Role            string
+	Admin           bool // only used to migrate records created before roles
 	LoginAttempts   int
 	TotpSecret      string
 	TotpBackupCodes []string
+
+	// TotpPendingSecret is the secret of a TOTP enrollment that has not been
+	// confirmed yet, and TotpLastStep is the time step of the last TOTP used,
+	// so that TOTPs cannot be reused
+	TotpPendingSecret string
+	TotpLastStep      int64
+
+	// TokenVersion is embedded in session tokens issued to the user, and is
+	// incremented to revoke all previously issued tokens
+	TokenVersion int
+
+	// LastLogin is when the user last logged in successfully, and is zero for
+	// users that have not logged in since it was first recorded
+	LastLogin time.Time
+
+	// PasswordResetRequired is set for users that must change their password
+	// the next time they log in, and is cleared when the password is updated
+	PasswordResetRequired bool
+
+	// Email is the user's email address, which is optional
+	Email string
+
+	// ResetTokenHash is the hash of the user's password reset token, which is
+	// valid until ResetTokenExpiry, and is cleared once the token is used
+	ResetTokenHash   string
+	ResetTokenExpiry time.Time
+
+	// Revision is incremented whenever the user's role or password changes,
+	// so that updates based on an outdated read of the user can be rejected
+	Revision int
+
+	// PepperVersion is the version of the pepper the user's password was
+	// hashed with, and is zero if it was not peppered
+	PepperVersion int
+}
+
+// anyRevision can be provided to updates that should apply regardless of the
+// user's current revision
+const anyRevision = -1
+
+// checkRevision returns errStaleRevision if the given revision is not the
+// user's current revision, or anyRevision
+func (p *userProps) checkRevision(revision int) error {
+	if revision != anyRevision && revision != p.Revision {
+		return errStaleRevision
+	}
+	return nil
 }
 
 // userManager administers sessions and user accounts
 type userManager struct {
 	// db is a boltdb database, which is an embedded key/value database where
-	// each "bucket" is a collection
-	db          *bolt.DB
-	usersBucket []byte
+	// each "bucket" is a collection, or an in-memory equivalent
+	db           store
+	usersBucket  []byte
+	tokensBucket []byte
+
+	// removedBucket holds the token version that a new user added under the
+	// name of a removed user starts at, so that tokens issued before the
+	// removal remain revoked
+	removedBucket []byte
+
+	// hashCost is the bcrypt cost used to hash passwords
+	hashCost int
+
+	// decoy is compared against passwords given for users that do not exist,
+	// so that logins take as long whether or not the user exists
+	decoy *decoyHash
+
+	// policy is the password policy new passwords must satisfy
+	policy PasswordPolicy
+
+	// usernames is the username policy the usernames of new users must satisfy
+	usernames UsernamePolicy
+
+	// totpKey is used to encrypt TOTP secrets - if it is nil, secrets are
+	// stored as-is
+	totpKey []byte
+
+	// peppers are combined with passwords before they are hashed
+	peppers Peppers
+
+	// clock tells the time when checking reset tokens and TOTP passcodes
+	clock Clock
 }
 
-func newUserManager(dbPath string) (*userManager, error) {
+// withContext returns a copy of the user manager that stops waiting on the
+// database once the given context is done
+func (m *userManager) withContext(ctx context.Context) *userManager {
+	var c = *m
+	c.db = &contextStore{store: m.db, ctx: ctx}
+	return &c
+}
+
+// newUserManager opens the user database at dbPath. Passwords are hashed with
+// the given bcrypt cost, or bcrypt's default cost if hashCost is invalid, and
+// must satisfy the given password policy, if one is provided. If dbPath is
+// MemoryStore, users are kept in memory instead.
+func newUserManager(dbPath string, hashCost int, policy ...PasswordPolicy) (*userManager, error) {
 	manager := &userManager{
-		usersBucket: []byte("users"),
+		usersBucket:   []byte("users"),
+		tokensBucket:  []byte("readonly_tokens"),
+		hashCost:      hashCost,
+		removedBucket: []byte("removed_users"),
+		decoy:         &decoyHash{cost: hashCost},
+		clock:         systemClock{},
+	}
+	if len(policy) > 0 {
+		manager.policy = policy[0]
 	}
 
 	// Set up database
-	db, err := bolt.Open(dbPath, 0600, nil)
+	db, err := openStore(dbPath)
 	if err != nil {
 		return nil, err
 	}
-	err = db.Update(func(tx *bolt.Tx) error {
+	err = db.Update(func(tx storeTx) error {
+		if _, err := tx.CreateBucketIfNotExists(manager.tokensBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(manager.removedBucket); err != nil {
+			return err
+		}
 		users, err := tx.CreateBucketIfNotExists(manager.usersBucket)
 		if err != nil {
 			return err
 		}
+		if err := migrateRoles(users); err != nil {
+			return err
+		}
 		// Add a master user - the password to this guy/gal will just be the
 		// GitHub key. It's not really meant for use.
-		bytes, err := json.Marshal(&userProps{Admin: true})
+		bytes, err := json.Marshal(newUserProps("", api.RoleAdmin))
 		if err != nil {
 			return err
 		}
-		return users.Put([]byte("master"), bytes)
+		return users.Put([]byte(masterUser), bytes)
 	})
 	if err != nil {
 		return nil, err
@@ -67,36 +199,206 @@ func newUserManager(dbPath string) (*userManager, error) {
 	return manager, nil
 }
 
+// decoyHash is a hash of a random password, created the first time it is
+// needed so that creating a user manager stays cheap
+type decoyHash struct {
+	once sync.Once
+	cost int
+	hash string
+}
+
+// Compare compares the given password against the decoy hash, taking as long as
+// comparing it against a real user's password would, and always fails
+func (d *decoyHash) Compare(password string) {
+	d.once.Do(func() {
+		// If hashing fails, the comparison fails quickly, which at worst
+		// reveals which users exist
+		d.hash, _ = crypto.HashPasswordWithCost(
+			base64.StdEncoding.EncodeToString(crypto.GenerateSalt()), d.cost)
+	})
+	crypto.CorrectPassword(d.hash, password)
+}
+
+// newUserProps creates properties for a new user with the given role
+func newUserProps(hashedPassword, role string) *userProps {
+	return &userProps{
+		HashedPassword: hashedPassword,
+		Role:           role,
+		Admin:          role == api.RoleAdmin,
+	}
+}
+
+// isValidRole returns true if the given role is known
+func isValidRole(role string) bool {
+	switch role {
+	case api.RoleAdmin, api.RoleUser, api.RoleViewer:
+		return true
+	default:
+		return false
+	}
+}
+
+// migrateRoles assigns roles to users created before roles were introduced,
+// based on whether they are an admin
+func migrateRoles(users storeBucket) error {
+	var migrated = make(map[string][]byte)
+	if err := users.ForEach(func(k, v []byte) error {
+		var props userProps
+		if err := json.Unmarshal(v, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if props.Role != "" {
+			return nil
+		}
+		props.Role = api.RoleUser
+		if props.Admin {
+			props.Role = api.RoleAdmin
+		}
+		bytes, err := json.Marshal(&props)
+		if err != nil {
+			return err
+		}
+		migrated[string(k)] = bytes
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Buckets must not be modified while iterating over them
+	for k, v := range migrated {
+		if err := users.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close ends the session cleanup job and releases the DB handler
 func (m *userManager) Close() error {
 	return m.db.Close()
 }
 
+// Ping checks that the user database responds to reads
+func (m *userManager) Ping() error {
+	return m.db.View(func(tx storeTx) error {
+		if tx.Bucket(m.usersBucket) == nil {
+			return errBucketNotFound
+		}
+		return nil
+	})
+}
+
 // Reset deletes all users and drops all active sessions
 func (m *userManager) Reset() error {
-	return m.db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket(m.usersBucket)
-		if err != nil {
+	return m.db.Update(m.reset)
+}
+
+// ResetPlan returns the users that Reset would delete, sorted by username,
+// along with a confirmation that ResetConfirmed accepts for them
+func (m *userManager) ResetPlan() ([]string, string, error) {
+	usernames, err := m.FilterUsers(nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return usernames, resetConfirmation(usernames), nil
+}
+
+// ResetConfirmed deletes all users like Reset, but only if the given
+// confirmation is the one from ResetPlan for the current users, so that users
+// added since the plan was made are never deleted unknowingly
+func (m *userManager) ResetConfirmed(confirm string) error {
+	return m.db.Update(func(tx storeTx) error {
+		var usernames []string
+		if err := tx.Bucket(m.usersBucket).ForEach(func(k, v []byte) error {
+			usernames = append(usernames, string(k))
+			return nil
+		}); err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket(m.usersBucket)
-		return err
+		sort.Strings(usernames)
+		if confirm != resetConfirmation(usernames) {
+			return errResetNotConfirmed
+		}
+		return m.reset(tx)
 	})
 }
 
-// AddUser inserts a new user
-func (m *userManager) AddUser(username, password string, admin bool) error {
-	err := crypto.ValidateCredentialValues(username, password)
+// resetConfirmation identifies the given set of users, sorted by username
+func resetConfirmation(usernames []string) string {
+	return crypto.HashToken(strings.Join(usernames, "\n"))
+}
+
+// reset deletes all users in the given transaction
+func (m *userManager) reset(tx storeTx) error {
+	if err := tx.Bucket(m.usersBucket).ForEach(func(k, v []byte) error {
+		return m.markRemoved(tx, k, v)
+	}); err != nil {
+		return err
+	}
+	err := tx.DeleteBucket(m.usersBucket)
 	if err != nil {
 		return err
 	}
-	hashedPassword, err := crypto.HashPassword(password)
+	_, err = tx.CreateBucket(m.usersBucket)
+	return err
+}
+
+// validateCredentials checks the format of the given credentials, and that the
+// password satisfies the password policy
+func (m *userManager) validateCredentials(username, password string) error {
+	if err := crypto.ValidateCredentialValues(username, password); err != nil {
+		return err
+	}
+	return m.policy.Check(password)
+}
+
+// validateEmail checks that the given email address, if there is one, is a
+// bare address
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// AddUser inserts a new user with the given role. The username must satisfy
+// the username policy.
+func (m *userManager) AddUser(username, password, role string) error {
+	return m.AddUserWithEmail(username, password, role, "")
+}
+
+// AddUserWithEmail inserts a new user like AddUser, along with the given email
+// address, which is optional
+func (m *userManager) AddUserWithEmail(username, password, role, email string) error {
+	if !isValidRole(role) {
+		return errInvalidRole
+	}
+	if err := m.usernames.Check(username); err != nil {
+		return err
+	}
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+	err := m.validateCredentials(username, password)
+	if err != nil {
+		return err
+	}
+	hashedPassword, pepperVersion, err := m.hashPassword(password)
 	if err != nil {
 		return err
 	}
-	props := userProps{HashedPassword: string(hashedPassword), Admin: admin}
-	return m.db.Update(func(tx *bolt.Tx) error {
+	props := newUserProps(hashedPassword, role)
+	props.PepperVersion = pepperVersion
+	props.Email = email
+	return m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
+		if users.Get([]byte(username)) != nil {
+			return errUserExists
+		}
+		props.TokenVersion = m.initialTokenVersion(tx, username)
 		bytes, err := json.Marshal(props)
 		if err != nil {
 			return err
@@ -105,22 +407,455 @@ func (m *userManager) AddUser(username, password string, admin bool) error {
 	})
 }
 
-// RemoveUser removes user with given username and ends related sessions
+// ImportUsers adds all of the given users in a single transaction. If any user
+// has invalid credentials or already exists, no users are added, and the index
+// of the first offending user is returned along with the error.
+func (m *userManager) ImportUsers(reqs []api.UserRequest) (int, error) {
+	// Validate and hash everything up front to keep the transaction short
+	var (
+		props = make([]*userProps, len(reqs))
+		seen  = make(map[string]bool, len(reqs))
+	)
+	for i, req := range reqs {
+		if !isValidRole(req.GetRole()) {
+			return i, errInvalidRole
+		}
+		if err := m.usernames.Check(req.Username); err != nil {
+			return i, err
+		}
+		if err := validateEmail(req.Email); err != nil {
+			return i, err
+		}
+		if err := m.validateCredentials(req.Username, req.Password); err != nil {
+			return i, err
+		}
+		if seen[req.Username] {
+			return i, errUserExists
+		}
+		seen[req.Username] = true
+		hashedPassword, pepperVersion, err := m.hashPassword(req.Password)
+		if err != nil {
+			return i, err
+		}
+		props[i] = newUserProps(hashedPassword, req.GetRole())
+		props[i].PepperVersion = pepperVersion
+		props[i].Email = req.Email
+	}
+
+	var failed = -1
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		for i, req := range reqs {
+			var key = []byte(req.Username)
+			if users.Get(key) != nil {
+				failed = i
+				return errUserExists
+			}
+			props[i].TokenVersion = m.initialTokenVersion(tx, req.Username)
+			bytes, err := json.Marshal(props[i])
+			if err != nil {
+				failed = i
+				return err
+			}
+			if err := users.Put(key, bytes); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	return failed, err
+}
+
+// Export returns a consistent snapshot of all users, including their password
+// hashes. The master user is not included, since it is created by the daemon.
+func (m *userManager) Export() (*api.UserDatabase, error) {
+	var db = &api.UserDatabase{Users: make(map[string]json.RawMessage)}
+	err := m.db.View(func(tx storeTx) error {
+		return tx.Bucket(m.usersBucket).ForEach(func(k, v []byte) error {
+			if string(k) == masterUser {
+				return nil
+			}
+			// Values are only valid for the lifetime of the transaction
+			db.Users[string(k)] = append(json.RawMessage(nil), v...)
+			return nil
+		})
+	})
+	return db, err
+}
+
+// Restore adds all users in the given snapshot in a single transaction,
+// replacing existing users with the same name. If replace is set, all other
+// users are removed. The master user is never restored or removed. If any user
+// record is invalid, no users are restored, and the offending username is
+// returned along with the error.
+func (m *userManager) Restore(db api.UserDatabase, replace bool) (string, error) {
+	// Validate everything up front to keep the transaction short
+	var usernames = make([]string, 0, len(db.Users))
+	for username := range db.Users {
+		if username == masterUser {
+			continue
+		}
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	var records = make(map[string][]byte, len(db.Users))
+	for _, username := range usernames {
+		var props userProps
+		if username == "" {
+			return username, errInvalidUserRecord
+		}
+		if err := json.Unmarshal(db.Users[username], &props); err != nil || props.HashedPassword == "" {
+			return username, errInvalidUserRecord
+		}
+		if props.Role == "" {
+			props.Role = api.RoleUser
+			if props.Admin {
+				props.Role = api.RoleAdmin
+			}
+		}
+		if !isValidRole(props.Role) {
+			return username, errInvalidRole
+		}
+		bytes, err := json.Marshal(&props)
+		if err != nil {
+			return username, err
+		}
+		records[username] = bytes
+	}
+
+	return "", m.db.Update(func(tx storeTx) error {
+		if replace {
+			// Values are only valid for the lifetime of the bucket
+			var master = append([]byte(nil), tx.Bucket(m.usersBucket).Get([]byte(masterUser))...)
+			if err := tx.DeleteBucket(m.usersBucket); err != nil {
+				return err
+			}
+			users, err := tx.CreateBucket(m.usersBucket)
+			if err != nil {
+				return err
+			}
+			if len(master) > 0 {
+				if err := users.Put([]byte(masterUser), master); err != nil {
+					return err
+				}
+			}
+		}
+		users := tx.Bucket(m.usersBucket)
+		for username, bytes := range records {
+			if err := users.Put([]byte(username), bytes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdatePassword replaces the given user's password, and clears any required
+// password reset and outstanding reset token in the same transaction. The
+// password is only replaced if the user is still at the given revision.
+func (m *userManager) UpdatePassword(username, password string, revision int) error {
+	if err := m.validateCredentials(username, password); err != nil {
+		return err
+	}
+	hashedPassword, pepperVersion, err := m.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	var key = []byte(username)
+	return m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if err := props.checkRevision(revision); err != nil {
+			return err
+		}
+		props.HashedPassword = hashedPassword
+		props.PepperVersion = pepperVersion
+		props.LoginAttempts = 0
+		props.PasswordResetRequired = false
+		props.ResetTokenHash = ""
+		props.ResetTokenExpiry = time.Time{}
+		props.Revision++
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put(key, bytes)
+	})
+}
+
+// RequirePasswordReset marks every user except the master user as having to
+// change their password the next time they log in, and returns the number of
+// users marked. All users are marked in a single transaction.
+func (m *userManager) RequirePasswordReset() (int, error) {
+	var marked int
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		var updated = make(map[string][]byte)
+		if err := users.ForEach(func(k, v []byte) error {
+			if string(k) == masterUser {
+				return nil
+			}
+			var props userProps
+			if err := json.Unmarshal(v, &props); err != nil {
+				return errors.New("Corrupt user properties: " + err.Error())
+			}
+			props.PasswordResetRequired = true
+			bytes, err := json.Marshal(props)
+			if err != nil {
+				return err
+			}
+			updated[string(k)] = bytes
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// Buckets must not be modified while iterating over them
+		for k, v := range updated {
+			if err := users.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		marked = len(updated)
+		return nil
+	})
+	return marked, err
+}
+
+// RevokeTokens invalidates all session tokens previously issued to the given
+// user by incrementing its token version, and returns the new version
+func (m *userManager) RevokeTokens(username string) (int, error) {
+	var version int
+	var key = []byte(username)
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		props.TokenVersion++
+		version = props.TokenVersion
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put(key, bytes)
+	})
+	return version, err
+}
+
+// TokenVersion returns the version that session tokens issued to the given
+// user must have to be valid
+func (m *userManager) TokenVersion(username string) (int, error) {
+	var version int
+	err := m.db.View(func(tx storeTx) error {
+		propsBytes := tx.Bucket(m.usersBucket).Get([]byte(username))
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		version = props.TokenVersion
+		return nil
+	})
+	return version, err
+}
+
+// Revision returns the given user's current revision
+func (m *userManager) Revision(username string) (int, error) {
+	var revision int
+	err := m.db.View(func(tx storeTx) error {
+		propsBytes := tx.Bucket(m.usersBucket).Get([]byte(username))
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		revision = props.Revision
+		return nil
+	})
+	return revision, err
+}
+
+// SetAdmin promotes the given user to an admin, or demotes an admin to a
+// regular user, keeping the rest of their account intact. Tokens issued to the
+// user are revoked if their role changes, since they carry the old role, and
+// SetAdmin reports whether it did. The last remaining admin cannot be demoted,
+// and the user is only updated if they are still at the given revision.
+func (m *userManager) SetAdmin(username string, admin bool, revision int) (bool, error) {
+	var key = []byte(username)
+	var changed bool
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+
+		if err := props.checkRevision(revision); err != nil {
+			return err
+		}
+
+		var role = props.Role
+		switch {
+		case admin:
+			role = api.RoleAdmin
+		case props.Role == api.RoleAdmin:
+			role = api.RoleUser
+		}
+		if role == props.Role {
+			return nil
+		}
+
+		// Make sure there will still be an admin left
+		if props.Role == api.RoleAdmin {
+			var admins int
+			if err := users.ForEach(func(k, v []byte) error {
+				if string(k) == masterUser {
+					return nil
+				}
+				var other userProps
+				if err := json.Unmarshal(v, &other); err != nil {
+					return errors.New("Corrupt user properties: " + err.Error())
+				}
+				if other.Role == api.RoleAdmin {
+					admins++
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errLastAdmin
+			}
+		}
+
+		props.Role = role
+		props.TokenVersion++
+		props.Revision++
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		changed = true
+		return users.Put(key, bytes)
+	})
+	return changed && err == nil, err
+}
+
+// RenameUser moves the given user to a new username, keeping the rest of their
+// account intact. The new username must satisfy the username policy, and must
+// not already be taken. Tokens issued to the user under their old username are
+// no longer valid, even if a user of that name is added later. The user is
+// only renamed if they are still at the given revision.
+func (m *userManager) RenameUser(username, newUsername string, revision int) error {
+	if err := m.usernames.Check(newUsername); err != nil {
+		return err
+	}
+	var oldKey, newKey = []byte(username), []byte(newUsername)
+	return m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(oldKey)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		if users.Get(newKey) != nil {
+			return errUserExists
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if err := props.checkRevision(revision); err != nil {
+			return err
+		}
+
+		// Keep tokens issued to a removed user of the new name revoked
+		if version := m.initialTokenVersion(tx, newUsername); version > props.TokenVersion {
+			props.TokenVersion = version
+		}
+		props.Revision++
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		if err := m.markRemoved(tx, oldKey, propsBytes); err != nil {
+			return err
+		}
+		if err := users.Delete(oldKey); err != nil {
+			return err
+		}
+		return users.Put(newKey, bytes)
+	})
+}
+
+// RemoveUser removes user with given username and ends related sessions.
+// Tokens issued to the user remain revoked even if a user of the same name is
+// added later.
 func (m *userManager) RemoveUser(username string) error {
 	var u = []byte(username)
-	return m.db.Update(func(tx *bolt.Tx) error {
+	return m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
-		if users.Get(u) == nil {
+		propsBytes := users.Get(u)
+		if propsBytes == nil {
 			return errUserNotFound
 		}
+		if err := m.markRemoved(tx, u, propsBytes); err != nil {
+			return err
+		}
 		return users.Delete(u)
 	})
 }
 
+// markRemoved records that the user with the given properties is being
+// removed, so that a new user of the same name starts past the removed user's
+// token version
+func (m *userManager) markRemoved(tx storeTx, username, propsBytes []byte) error {
+	var props userProps
+	if err := json.Unmarshal(propsBytes, &props); err != nil {
+		return errors.New("Corrupt user properties: " + err.Error())
+	}
+	bytes, err := json.Marshal(props.TokenVersion + 1)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(m.removedBucket).Put(username, bytes)
+}
+
+// initialTokenVersion returns the token version a new user with the given name
+// starts at, which is past any version issued to a removed user of that name
+func (m *userManager) initialTokenVersion(tx storeTx, username string) int {
+	var version int
+	if removed := tx.Bucket(m.removedBucket).Get([]byte(username)); removed != nil {
+		json.Unmarshal(removed, &version)
+	}
+	return version
+}
+
 // UserList returns a list of all registered users
 func (m *userManager) UserList() []string {
 	userList := make([]string, 0)
-	m.db.View(func(tx *bolt.Tx) error {
+	m.db.View(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		return users.ForEach(func(username, v []byte) error {
 			userList = append(userList, string(username))
@@ -130,10 +865,88 @@ func (m *userManager) UserList() []string {
 	return userList
 }
 
+// FilterUsers returns the usernames of all users, sorted by username. If admin
+// is set, only users whose admin status matches it are returned.
+func (m *userManager) FilterUsers(admin *bool) ([]string, error) {
+	var userList = make([]string, 0)
+	err := m.db.View(func(tx storeTx) error {
+		return tx.Bucket(m.usersBucket).ForEach(func(username, v []byte) error {
+			if admin != nil {
+				var props userProps
+				if err := json.Unmarshal(v, &props); err != nil {
+					return errors.New("Corrupt user properties: " + err.Error())
+				}
+				if (props.Role == api.RoleAdmin) != *admin {
+					return nil
+				}
+			}
+			userList = append(userList, string(username))
+			return nil
+		})
+	})
+	sort.Strings(userList)
+	return userList, err
+}
+
+// UserInfo returns information about each of the given users, in the given
+// order
+func (m *userManager) UserInfo(usernames []string) ([]api.UserInfo, error) {
+	var infos = make([]api.UserInfo, 0, len(usernames))
+	err := m.db.View(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		for _, username := range usernames {
+			propsBytes := users.Get([]byte(username))
+			if propsBytes == nil {
+				return errUserNotFound
+			}
+			var props userProps
+			if err := json.Unmarshal(propsBytes, &props); err != nil {
+				return errors.New("Corrupt user properties: " + err.Error())
+			}
+			var info = api.UserInfo{
+				Username: username,
+				Role:     props.Role,
+				Email:    props.Email,
+				Revision: props.Revision,
+			}
+			if !props.LastLogin.IsZero() {
+				var lastLogin = props.LastLogin
+				info.LastLogin = &lastLogin
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	return infos, err
+}
+
+// RecordLogin records that the given user logged in successfully at the given
+// time
+func (m *userManager) RecordLogin(username string, at time.Time) error {
+	var key = []byte(username)
+	return m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get(key)
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		var props userProps
+		if err := json.Unmarshal(propsBytes, &props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		props.LastLogin = at.UTC()
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put(key, bytes)
+	})
+}
+
 // HasUser returns nil if user exists in database
 func (m *userManager) HasUser(username string) error {
 	found := false
-	err := m.db.View(func(tx *bolt.Tx) error {
+	err := m.db.View(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		user := users.Get([]byte(username))
 		if user != nil {
@@ -163,16 +976,19 @@ func (m *userManager) IsCorrectCredentials(username, password string) (*userProp
 		correct bool
 	)
 
-	transactionErr := m.db.Update(func(tx *bolt.Tx) error {
+	transactionErr := m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		if propsBytes := users.Get(key); propsBytes == nil {
+			// Spend as long as checking a real password would, so that
+			// unknown users cannot be told apart by response times
+			m.decoy.Compare(password)
 			return errUserNotFound
 		} else if err := json.Unmarshal(propsBytes, props); err != nil {
 			return errors.New("Corrupt user properties: " + err.Error())
 		}
 
 		// The 'correct' here is returned by the funtion
-		correct = crypto.CorrectPassword(props.HashedPassword, password)
+		correct = m.correctPassword(props, password)
 		if !correct {
 			// Track number of login attempts
 			props.LoginAttempts++
@@ -197,6 +1013,15 @@ func (m *userManager) IsCorrectCredentials(username, password string) (*userProp
 
 		// Reset attempts to 0 if login successful
 		props.LoginAttempts = 0
+
+		// Rehash passwords hashed with an old pepper, so that the old pepper
+		// can eventually be retired
+		if props.PepperVersion != m.peppers.Current {
+			if hash, version, err := m.hashPassword(password); err == nil {
+				props.HashedPassword = hash
+				props.PepperVersion = version
+			}
+		}
 		bytes, err := json.Marshal(props)
 		if err != nil {
 			return err
@@ -213,33 +1038,52 @@ func (m *userManager) IsCorrectCredentials(username, password string) (*userProp
 }
 
 // IsValidTotp returns true if the given TOTP is valid for the given user, and
-// false otherwise.
+// false otherwise. Each TOTP is only valid once - TOTPs from the same or an
+// earlier time step than the last valid TOTP are rejected.
 func (m *userManager) IsValidTotp(username string, totp string) (bool, error) {
-	var totpSecret string
-	err := m.db.View(func(tx *bolt.Tx) error {
+	var valid bool
+	err := m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
-		if propsBytes != nil {
-			props := &userProps{}
-			if err := json.Unmarshal(propsBytes, props); err != nil {
-				return errors.New("Corrupt user properties: " + err.Error())
-			}
-			totpSecret = props.TotpSecret
+		if propsBytes == nil {
+			return errors.New("No such user")
+		}
+		props := &userProps{}
+		if err := json.Unmarshal(propsBytes, props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if props.TotpSecret == "" {
 			return nil
 		}
-		return errors.New("No such user")
+		secret, err := m.openTotpSecret(props.TotpSecret)
+		if err != nil {
+			return err
+		}
+		step, ok := crypto.ValidatePasscodeStep(totp, secret, m.clock.Now())
+		if !ok || step <= props.TotpLastStep {
+			return nil
+		}
+		valid = true
+
+		// record the step, and encrypt secrets stored before a key was set
+		props.TotpLastStep = step
+		if props.TotpSecret, err = m.sealTotpSecret(secret); err != nil {
+			return err
+		}
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put([]byte(username), bytes)
 	})
-	if err != nil {
-		return false, err
-	}
-	return crypto.ValidatePasscode(totp, totpSecret), nil
+	return valid && err == nil, err
 }
 
 // IsValidBackupCode returns true if the given backup code is valid for the
 // given user, and false otherwise.
 func (m *userManager) IsValidBackupCode(username, backupCode string) (bool, error) {
 	var backupCodes []string
-	err := m.db.View(func(tx *bolt.Tx) error {
+	err := m.db.View(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -268,7 +1112,7 @@ func (m *userManager) IsValidBackupCode(username, backupCode string) (bool, erro
 func (m *userManager) IsAdmin(username string) (bool, error) {
 	// Check if user is admin in database
 	admin := false
-	err := m.db.View(func(tx *bolt.Tx) error {
+	err := m.db.View(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -277,7 +1121,7 @@ func (m *userManager) IsAdmin(username string) (bool, error) {
 			if err != nil {
 				return errors.New("Corrupt user properties: " + err.Error())
 			}
-			admin = props.Admin
+			admin = props.Role == api.RoleAdmin
 		}
 		return nil
 	})
@@ -288,7 +1132,7 @@ func (m *userManager) IsAdmin(username string) (bool, error) {
 func (m *userManager) IsTotpEnabled(username string) (bool, error) {
 	totpEnabled := false
 
-	err := m.db.View(func(tx *bolt.Tx) error {
+	err := m.db.View(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -309,8 +1153,9 @@ func (m *userManager) IsTotpEnabled(username string) (bool, error) {
 // EnableTotp enables TOTP for a user
 func (m *userManager) EnableTotp(username string) (string, []string, error) {
 	props := &userProps{}
+	var secret string
 
-	err := m.db.Update(func(tx *bolt.Tx) error {
+	err := m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -324,7 +1169,12 @@ func (m *userManager) EnableTotp(username string) (string, []string, error) {
 				return errors.New("Error generating secret totp key: " + totpErr.Error())
 			}
 			props.TotpBackupCodes = crypto.GenerateBackupCodes()
-			props.TotpSecret = totpSecret.Secret()
+			props.TotpPendingSecret = ""
+			props.TotpLastStep = 0
+			if props.TotpSecret, err = m.sealTotpSecret(totpSecret.Secret()); err != nil {
+				return err
+			}
+			secret = totpSecret.Secret()
 
 			bytes, err := json.Marshal(props)
 			if err != nil {
@@ -337,12 +1187,122 @@ func (m *userManager) EnableTotp(username string) (string, []string, error) {
 		}
 		return nil
 	})
-	return props.TotpSecret, props.TotpBackupCodes, err
+	return secret, props.TotpBackupCodes, err
+}
+
+// EnrollTotp starts TOTP enrollment for a user, returning a new secret and its
+// otpauth URI for authenticator apps. TOTP is not enabled until the enrollment
+// is confirmed with ConfirmTotp.
+func (m *userManager) EnrollTotp(username string) (string, string, error) {
+	var secret, uri string
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get([]byte(username))
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		props := &userProps{}
+		if err := json.Unmarshal(propsBytes, props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if props.TotpSecret != "" {
+			return errTotpEnabled
+		}
+
+		key, err := crypto.GenerateSecretKey(username)
+		if err != nil {
+			return errors.New("Error generating secret totp key: " + err.Error())
+		}
+		if props.TotpPendingSecret, err = m.sealTotpSecret(key.Secret()); err != nil {
+			return err
+		}
+		secret, uri = key.Secret(), key.URL()
+
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put([]byte(username), bytes)
+	})
+	return secret, uri, err
+}
+
+// ConfirmTotp enables TOTP for a user with an enrollment in progress if the
+// given TOTP is valid for the enrollment's secret, and returns new backup codes
+func (m *userManager) ConfirmTotp(username, totp string) ([]string, error) {
+	var backupCodes []string
+	err := m.db.Update(func(tx storeTx) error {
+		users := tx.Bucket(m.usersBucket)
+		propsBytes := users.Get([]byte(username))
+		if propsBytes == nil {
+			return errUserNotFound
+		}
+		props := &userProps{}
+		if err := json.Unmarshal(propsBytes, props); err != nil {
+			return errors.New("Corrupt user properties: " + err.Error())
+		}
+		if props.TotpPendingSecret == "" {
+			return errTotpNotEnrolled
+		}
+
+		secret, err := m.openTotpSecret(props.TotpPendingSecret)
+		if err != nil {
+			return err
+		}
+		step, ok := crypto.ValidatePasscodeStep(totp, secret, m.clock.Now())
+		if !ok {
+			return errInvalidTotp
+		}
+		props.TotpSecret = props.TotpPendingSecret
+		props.TotpPendingSecret = ""
+		props.TotpLastStep = step
+		props.TotpBackupCodes = crypto.GenerateBackupCodes()
+		backupCodes = props.TotpBackupCodes
+
+		bytes, err := json.Marshal(props)
+		if err != nil {
+			return err
+		}
+		return users.Put([]byte(username), bytes)
+	})
+	return backupCodes, err
+}
+
+// sealTotpSecret encrypts the given TOTP secret for storage if a key is set
+func (m *userManager) sealTotpSecret(secret string) (string, error) {
+	if m.totpKey == nil {
+		return secret, nil
+	}
+	sealed, err := crypto.Encrypt(m.totpKey, []byte(secret))
+	if err != nil {
+		return "", err
+	}
+	return sealedTotpPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openTotpSecret decrypts a stored TOTP secret. Secrets stored before a key was
+// set are returned as-is.
+func (m *userManager) openTotpSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedTotpPrefix) {
+		return stored, nil
+	}
+	if m.totpKey == nil {
+		return "", errTotpKeyMissing
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedTotpPrefix))
+	if err != nil {
+		return "", errors.New("Corrupt TOTP secret: " + err.Error())
+	}
+	secret, err := crypto.Decrypt(m.totpKey, sealed)
+	if err != nil {
+		return "", errors.New("Corrupt TOTP secret: " + err.Error())
+	}
+	return string(secret), nil
 }
 
 // DisableTotp disables TOTP for a user
 func (m *userManager) DisableTotp(username string) error {
-	return m.db.Update(func(tx *bolt.Tx) error {
+	return m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -352,6 +1312,8 @@ func (m *userManager) DisableTotp(username string) error {
 				return errors.New("Corrupt user properties: " + err.Error())
 			}
 			props.TotpSecret = ""
+			props.TotpPendingSecret = ""
+			props.TotpLastStep = 0
 			props.TotpBackupCodes = []string{}
 
 			bytes, err := json.Marshal(props)
@@ -372,7 +1334,7 @@ func (m *userManager) DisableTotp(username string) error {
 // RemoveBackupCode removes the given backup code from the user's list of
 // backup codes
 func (m *userManager) RemoveBackupCode(username, backupCode string) error {
-	return m.db.Update(func(tx *bolt.Tx) error {
+	return m.db.Update(func(tx storeTx) error {
 		users := tx.Bucket(m.usersBucket)
 		propsBytes := users.Get([]byte(username))
 		if propsBytes != nil {
@@ -1,11 +1,20 @@
 package auth
 
 import (
+	"encoding/json"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 )
 
 func getTestUserManager(dir string) (*userManager, error) {
@@ -13,7 +22,7 @@ func getTestUserManager(dir string) (*userManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newUserManager(path.Join(dir, "users.db"))
+	return newUserManager(path.Join(dir, "users.db"), 0)
 }
 
 func TestAddUserAndIsCorrectCredentials(t *testing.T) {
@@ -23,7 +32,7 @@ func TestAddUserAndIsCorrectCredentials(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	_, correct, err := manager.IsCorrectCredentials("bobheadxi", "not_quite_best")
@@ -33,6 +42,44 @@ func TestAddUserAndIsCorrectCredentials(t *testing.T) {
 	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "best_person_ever")
 	assert.Nil(t, err)
 	assert.True(t, correct)
+
+	// existing users are not overwritten
+	err = manager.AddUser("bobheadxi", "not_quite_best", api.RoleUser)
+	assert.Equal(t, errUserExists, err)
+	_, correct, err = manager.IsCorrectCredentials("bobheadxi", "best_person_ever")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+}
+
+func TestAddUserWithHashCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		hashCost int
+		wantCost int
+	}{
+		{"custom", bcrypt.MinCost, bcrypt.MinCost},
+		{"unset", 0, bcrypt.DefaultCost},
+		{"invalid", bcrypt.MaxCost + 1, bcrypt.DefaultCost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := "./test_users_cost"
+			assert.Nil(t, os.Mkdir(dir, os.ModePerm))
+			defer os.RemoveAll(dir)
+			manager, err := newUserManager(path.Join(dir, "users.db"), tt.hashCost)
+			assert.Nil(t, err)
+			defer manager.Close()
+
+			assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleUser))
+			props, correct, err := manager.IsCorrectCredentials("bobheadxi", "best_person_ever")
+			assert.Nil(t, err)
+			assert.True(t, correct)
+
+			cost, err := bcrypt.Cost([]byte(props.HashedPassword))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCost, cost)
+		})
+	}
 }
 
 func TestAllUserManagementOperations(t *testing.T) {
@@ -42,10 +89,10 @@ func TestAllUserManagementOperations(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
-	err = manager.AddUser("whoisthat", "ummmmmmmmmm", false)
+	err = manager.AddUser("whoisthat", "ummmmmmmmmm", api.RoleUser)
 	assert.Nil(t, err)
 
 	users := manager.UserList()
@@ -68,14 +115,14 @@ func TestIsAdmin(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	admin, err := manager.IsAdmin("bobheadxi")
 	assert.Nil(t, err)
 	assert.True(t, admin)
 
-	err = manager.AddUser("chadlagore", "chadlad", false)
+	err = manager.AddUser("chadlagore", "chadlad", api.RoleUser)
 	assert.Nil(t, err)
 
 	admin, err = manager.IsAdmin("chadlagore")
@@ -90,7 +137,7 @@ func TestRemoveUser(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	err = manager.RemoveUser("bobheadxi")
@@ -108,7 +155,7 @@ func TestTooManyLogins(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	for i := 0; i < loginAttemptsLimit; i++ {
@@ -134,7 +181,7 @@ func TestEnableTotp(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	manager.EnableTotp("bobheadxi")
@@ -150,7 +197,7 @@ func TestDisableTotp(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	manager.EnableTotp("bobheadxi")
@@ -171,7 +218,7 @@ func TestRemoveBackupCode(t *testing.T) {
 	assert.Nil(t, err)
 	defer manager.Close()
 
-	err = manager.AddUser("bobheadxi", "best_person_ever", true)
+	err = manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin)
 	assert.Nil(t, err)
 
 	// good code
@@ -198,3 +245,416 @@ func TestRemoveBackupCode(t *testing.T) {
 	err = manager.RemoveBackupCode("bobheadxi", backupCodes[0])
 	assert.NotNil(t, err)
 }
+
+func TestSetAdmin(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleViewer))
+
+	// the only admin cannot be demoted
+	changed, err := manager.SetAdmin("bobheadxi", false, anyRevision)
+	assert.Equal(t, errLastAdmin, err)
+	assert.False(t, changed)
+
+	// promotion revokes tokens issued with the old role
+	changed, err = manager.SetAdmin("chadlagore", true, anyRevision)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	version, err := manager.TokenVersion("chadlagore")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	// no-op changes leave tokens alone
+	changed, err = manager.SetAdmin("chadlagore", true, anyRevision)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	// admins are demoted to regular users once another admin exists
+	changed, err = manager.SetAdmin("bobheadxi", false, anyRevision)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	admins, err := manager.FilterUsers(&[]bool{true}[0])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chadlagore"}, admins)
+
+	_, err = manager.SetAdmin("nobody", true, anyRevision)
+	assert.Equal(t, errUserNotFound, err)
+}
+
+func TestRenameUser(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	// the user keeps their password and role under the new name
+	assert.Nil(t, manager.RenameUser("bobheadxi", "robert", anyRevision))
+	assert.Equal(t, errUserNotFound, manager.HasUser("bobheadxi"))
+	_, correct, err := manager.IsCorrectCredentials("robert", "best_person_ever")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	admin, err := manager.IsAdmin("robert")
+	assert.Nil(t, err)
+	assert.True(t, admin)
+	revision, err := manager.Revision("robert")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, revision)
+
+	// tokens issued under the old name stay revoked if the name is reused
+	assert.Nil(t, manager.AddUser("bobheadxi", "new_person", api.RoleUser))
+	version, err := manager.TokenVersion("bobheadxi")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	// names that are taken or reserved are rejected
+	assert.Equal(t, errUserExists, manager.RenameUser("robert", "chadlagore", anyRevision))
+	_, isPolicyErr := manager.RenameUser("robert", "admin", anyRevision).(*UsernamePolicyError)
+	assert.True(t, isPolicyErr)
+	assert.Equal(t, errStaleRevision, manager.RenameUser("robert", "bob", 0))
+	assert.Equal(t, errUserNotFound, manager.RenameUser("nobody", "somebody", anyRevision))
+}
+
+func TestRevision(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	revision, err := manager.Revision("chadlagore")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, revision)
+
+	// updates at the current revision apply, and move the revision on
+	_, err = manager.SetAdmin("chadlagore", true, revision)
+	assert.Nil(t, err)
+	assert.Nil(t, manager.UpdatePassword("chadlagore", "chadlad2", revision+1))
+	revision, err = manager.Revision("chadlagore")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, revision)
+
+	// updates at an outdated revision do not
+	_, err = manager.SetAdmin("chadlagore", false, 1)
+	assert.Equal(t, errStaleRevision, err)
+	assert.Equal(t, errStaleRevision, manager.UpdatePassword("chadlagore", "chadlad3", 1))
+	admin, err := manager.IsAdmin("chadlagore")
+	assert.Nil(t, err)
+	assert.True(t, admin)
+	_, correct, err := manager.IsCorrectCredentials("chadlagore", "chadlad2")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+
+	_, err = manager.Revision("nobody")
+	assert.Equal(t, errUserNotFound, err)
+}
+
+func TestRecordLogin(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+
+	// records created before last logins were recorded have none
+	assert.Nil(t, manager.db.Update(func(tx storeTx) error {
+		return tx.Bucket(manager.usersBucket).Put([]byte("chadlagore"),
+			[]byte(`{"HashedPassword":"hash","Role":"user"}`))
+	}))
+	infos, err := manager.UserInfo([]string{"bobheadxi", "chadlagore"})
+	assert.Nil(t, err)
+	assert.Equal(t, []api.UserInfo{
+		{Username: "bobheadxi", Role: api.RoleAdmin},
+		{Username: "chadlagore", Role: api.RoleUser},
+	}, infos)
+
+	// logins are backfilled on the next login
+	var now = time.Date(2018, time.October, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, manager.RecordLogin("chadlagore", now))
+	infos, err = manager.UserInfo([]string{"chadlagore"})
+	assert.Nil(t, err)
+	assert.Len(t, infos, 1)
+	assert.NotNil(t, infos[0].LastLogin)
+	assert.True(t, now.Equal(*infos[0].LastLogin))
+
+	assert.Equal(t, errUserNotFound, manager.RecordLogin("nobody", now))
+	_, err = manager.UserInfo([]string{"nobody"})
+	assert.Equal(t, errUserNotFound, err)
+}
+
+func TestRequirePasswordReset(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	// all users but the master user are marked
+	marked, err := manager.RequirePasswordReset()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, marked)
+	for _, user := range []string{"bobheadxi", "chadlagore"} {
+		props, correct, err := manager.IsCorrectCredentials(user, map[string]string{
+			"bobheadxi": "best_person_ever", "chadlagore": "chadlad"}[user])
+		assert.Nil(t, err)
+		assert.True(t, correct)
+		assert.True(t, props.PasswordResetRequired)
+	}
+	var master userProps
+	assert.Nil(t, manager.db.View(func(tx storeTx) error {
+		return json.Unmarshal(tx.Bucket(manager.usersBucket).Get([]byte(masterUser)), &master)
+	}))
+	assert.False(t, master.PasswordResetRequired)
+
+	// updating the password clears the flag
+	assert.Nil(t, manager.UpdatePassword("bobheadxi", "even_better_person", anyRevision))
+	props, correct, err := manager.IsCorrectCredentials("bobheadxi", "even_better_person")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	assert.False(t, props.PasswordResetRequired)
+	props, _, err = manager.IsCorrectCredentials("chadlagore", "chadlad")
+	assert.Nil(t, err)
+	assert.True(t, props.PasswordResetRequired)
+}
+
+func TestResetConfirmed(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleUser))
+
+	usernames, confirm, err := manager.ResetPlan()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bobheadxi", "chadlagore"}, usernames)
+
+	// confirmations are rejected once users change
+	assert.Equal(t, errResetNotConfirmed, manager.ResetConfirmed(""))
+	assert.Nil(t, manager.AddUser("yaoharry", "ohyeah", api.RoleUser))
+	assert.Equal(t, errResetNotConfirmed, manager.ResetConfirmed(confirm))
+	assert.Nil(t, manager.HasUser("bobheadxi"))
+
+	_, confirm, err = manager.ResetPlan()
+	assert.Nil(t, err)
+	assert.Nil(t, manager.ResetConfirmed(confirm))
+	for _, user := range []string{"bobheadxi", "chadlagore", "yaoharry"} {
+		assert.Equal(t, errUserNotFound, manager.HasUser(user))
+	}
+}
+
+func TestExportAndRestore(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+	assert.Nil(t, manager.AddUser("chadlagore", "chadlad", api.RoleViewer))
+
+	// the master user is left out, since it cannot be restored
+	db, err := manager.Export()
+	assert.Nil(t, err)
+	assert.Len(t, db.Users, 2)
+	assert.NotContains(t, db.Users, masterUser)
+
+	// merging keeps users that are not in the snapshot
+	assert.Nil(t, manager.RemoveUser("chadlagore"))
+	assert.Nil(t, manager.AddUser("whoisthat", "whoknows", api.RoleUser))
+	failed, err := manager.Restore(*db, false)
+	assert.Nil(t, err)
+	assert.Empty(t, failed)
+	assert.ElementsMatch(t, []string{masterUser, "bobheadxi", "chadlagore", "whoisthat"}, manager.UserList())
+	props, correct, err := manager.IsCorrectCredentials("chadlagore", "chadlad")
+	assert.Nil(t, err)
+	assert.True(t, correct)
+	assert.Equal(t, api.RoleViewer, props.Role)
+
+	// replacing removes them, but keeps the master user
+	_, err = manager.Restore(*db, true)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{masterUser, "bobheadxi", "chadlagore"}, manager.UserList())
+
+	// master users in snapshots are ignored
+	db.Users[masterUser] = []byte(`{"Role":"admin"}`)
+	failed, err = manager.Restore(*db, false)
+	assert.Nil(t, err)
+	assert.Empty(t, failed)
+	delete(db.Users, masterUser)
+
+	// invalid records are rejected, and nothing is restored
+	assert.Nil(t, manager.RemoveUser("chadlagore"))
+	db.Users["whoisthat"] = []byte(`{"Role":"user"}`)
+	failed, err = manager.Restore(*db, false)
+	assert.Equal(t, errInvalidUserRecord, err)
+	assert.Equal(t, "whoisthat", failed)
+	assert.ElementsMatch(t, []string{masterUser, "bobheadxi"}, manager.UserList())
+}
+
+func TestEnrollAndConfirmTotp(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	manager.totpKey = make([]byte, crypto.SymmetricKeyLength)
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+
+	var readProps = func() *userProps {
+		var props = &userProps{}
+		assert.Nil(t, manager.db.View(func(tx storeTx) error {
+			return json.Unmarshal(tx.Bucket(manager.usersBucket).Get([]byte("bobheadxi")), props)
+		}))
+		return props
+	}
+
+	// enrollment does not enable TOTP until confirmed
+	secret, uri, err := manager.EnrollTotp("bobheadxi")
+	assert.Nil(t, err)
+	assert.Contains(t, uri, "otpauth://totp/")
+	enabled, err := manager.IsTotpEnabled("bobheadxi")
+	assert.Nil(t, err)
+	assert.False(t, enabled)
+
+	// secrets are stored encrypted
+	var props = readProps()
+	assert.True(t, strings.HasPrefix(props.TotpPendingSecret, sealedTotpPrefix))
+	assert.NotContains(t, props.TotpPendingSecret, secret)
+
+	// wrong code is rejected
+	_, err = manager.ConfirmTotp("bobheadxi", "000000")
+	assert.Equal(t, errInvalidTotp, err)
+
+	// correct code enables TOTP
+	code, err := totp.GenerateCode(secret, time.Now())
+	assert.Nil(t, err)
+	backupCodes, err := manager.ConfirmTotp("bobheadxi", code)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, backupCodes)
+	enabled, err = manager.IsTotpEnabled("bobheadxi")
+	assert.Nil(t, err)
+	assert.True(t, enabled)
+	props = readProps()
+	assert.True(t, strings.HasPrefix(props.TotpSecret, sealedTotpPrefix))
+	assert.Empty(t, props.TotpPendingSecret)
+
+	// already enabled
+	_, _, err = manager.EnrollTotp("bobheadxi")
+	assert.Equal(t, errTotpEnabled, err)
+	_, err = manager.ConfirmTotp("bobheadxi", code)
+	assert.Equal(t, errTotpNotEnrolled, err)
+
+	// codes can only be used once
+	valid, err := manager.IsValidTotp("bobheadxi", code)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+	code, err = totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	assert.Nil(t, err)
+	valid, err = manager.IsValidTotp("bobheadxi", code)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+	valid, err = manager.IsValidTotp("bobheadxi", code)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+}
+
+func TestIsValidTotp_sealsLegacySecret(t *testing.T) {
+	dir := "./test_users"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+	assert.Nil(t, manager.AddUser("bobheadxi", "best_person_ever", api.RoleAdmin))
+
+	// secrets are stored as-is without a key
+	secret, _, err := manager.EnableTotp("bobheadxi")
+	assert.Nil(t, err)
+
+	// secret is encrypted once a key is set and the secret is used
+	manager.totpKey = make([]byte, crypto.SymmetricKeyLength)
+	code, err := totp.GenerateCode(secret, time.Now())
+	assert.Nil(t, err)
+	valid, err := manager.IsValidTotp("bobheadxi", code)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+	var props = &userProps{}
+	assert.Nil(t, manager.db.View(func(tx storeTx) error {
+		return json.Unmarshal(tx.Bucket(manager.usersBucket).Get([]byte("bobheadxi")), props)
+	}))
+	assert.True(t, strings.HasPrefix(props.TotpSecret, sealedTotpPrefix))
+	opened, err := manager.openTotpSecret(props.TotpSecret)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, opened)
+}
+
+func TestRoleMigration(t *testing.T) {
+	dir := "./test_users_roles"
+	assert.Nil(t, os.Mkdir(dir, os.ModePerm))
+	defer os.RemoveAll(dir)
+	var dbPath = path.Join(dir, "users.db")
+
+	// Set up records created before roles were introduced
+	db, err := bolt.Open(dbPath, 0600, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		users, err := tx.CreateBucketIfNotExists([]byte("users"))
+		if err != nil {
+			return err
+		}
+		for name, legacy := range map[string]string{
+			"bobheadxi":  `{"HashedPassword":"hash","Admin":true}`,
+			"chadlagore": `{"HashedPassword":"hash","Admin":false}`,
+			"carol":      `{"HashedPassword":"hash","Role":"viewer"}`,
+		} {
+			if err := users.Put([]byte(name), []byte(legacy)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	assert.Nil(t, db.Close())
+
+	manager, err := newUserManager(dbPath, 0)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	for name, want := range map[string]string{
+		"bobheadxi":  api.RoleAdmin,
+		"chadlagore": api.RoleUser,
+		"carol":      api.RoleViewer,
+		"master":     api.RoleAdmin,
+	} {
+		assert.Nil(t, manager.db.View(func(tx storeTx) error {
+			var props userProps
+			assert.Nil(t, json.Unmarshal(tx.Bucket(manager.usersBucket).Get([]byte(name)), &props))
+			assert.Equal(t, want, props.Role, name)
+			assert.Equal(t, "hash", props.HashedPassword)
+			return nil
+		}))
+	}
+	admin, err := manager.IsAdmin("bobheadxi")
+	assert.Nil(t, err)
+	assert.True(t, admin)
+}
+
+func TestAddUserInvalidRole(t *testing.T) {
+	dir := "./test_users_invalid_role"
+	manager, err := getTestUserManager(dir)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	assert.Equal(t, errInvalidRole, manager.AddUser("bobheadxi", "best_person_ever", "superuser"))
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	docker "github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
@@ -22,6 +23,11 @@ import (
 // No relation to Bob the Builder, though a Bob did write this.
 type ContainerBuilder interface {
 	Build(string, Config, *docker.Client, io.Writer) (func() error, error)
+	Run(string, Config, *docker.Client, io.Writer) (func() error, error)
+	Prewarm(string, Config, *docker.Client, io.Writer) error
+	Prewarmed(string, Config, *docker.Client) bool
+	GetImages(string, Config, *docker.Client) ([]types.ImageSummary, error)
+	ComposeConfig(Config, *docker.Client, io.Writer) error
 	GetBuildStageName() string
 	StopContainers(*docker.Client, io.Writer) error
 	Prune(*docker.Client, io.Writer) error
@@ -36,6 +42,7 @@ type ProjectBuilder func(Config, *docker.Client, io.Writer) (func() error, error
 type Builder struct {
 	buildStageName       string
 	dockerComposeVersion string
+	dockerCLIVersion     string
 	stopper              containers.ContainerStopper
 
 	builders map[string]ProjectBuilder
@@ -46,6 +53,7 @@ func NewBuilder(conf cfg.Config, stopper containers.ContainerStopper) *Builder {
 	b := &Builder{
 		buildStageName:       "build",
 		dockerComposeVersion: conf.DockerComposeVersion,
+		dockerCLIVersion:     conf.DockerCLIVersion,
 		stopper:              stopper,
 	}
 	b.builders = map[string]ProjectBuilder{
@@ -64,14 +72,15 @@ func (b *Builder) StopContainers(docker *docker.Client, out io.Writer) error {
 	return b.stopper(docker, out)
 }
 
-// Prune cleans up Dokcer assets
+// Prune cleans up Docker assets created by Inertia
 func (b *Builder) Prune(docker *docker.Client, out io.Writer) error {
-	return containers.Prune(docker)
+	_, err := containers.Prune(docker)
+	return err
 }
 
 // PruneAll forcibly removes Docker assets
 func (b *Builder) PruneAll(docker *docker.Client, out io.Writer) error {
-	return containers.PruneAll(docker, b.dockerComposeVersion)
+	return containers.PruneAll(docker, b.dockerComposeVersion, b.dockerCLIVersion)
 }
 
 // Config contains parameters required for builds to execute
@@ -82,6 +91,17 @@ type Config struct {
 	BuildDirectory string
 
 	EnvValues []string
+
+	// Secrets are made available to builds that support them, keyed by name,
+	// and are not passed to project containers
+	Secrets map[string]string
+
+	// Plan configures the order in which docker-compose services are built
+	Plan Plan
+
+	// Revision is the commit being built, which is used to look up images
+	// pre-warmed for it
+	Revision string
 }
 
 // Build executes build and deploy
@@ -107,6 +127,36 @@ func (b *Builder) Build(buildType string, d Config,
 	return deploy, nil
 }
 
+// Run creates project containers from images that have already been built,
+// loaded, or pre-warmed for d.Revision, and returns a callback that can be used
+// to deploy the project. Nothing is built.
+func (b *Builder) Run(buildType string, d Config,
+	cli *docker.Client, out io.Writer) (func() error, error) {
+	var ctx = context.Background()
+	if strings.ToLower(buildType) == "dockerfile" {
+		if b.Prewarmed(buildType, d, cli) {
+			if err := usePrewarmed(ctx, d, cli, out); err != nil {
+				return nil, err
+			}
+		}
+		return b.dockerCreate(ctx, d, cli, out)
+	}
+	return b.dockerComposeUp(ctx, d, cli, out, "--no-build")
+}
+
+// GetImages returns the images built for the project
+func (b *Builder) GetImages(buildType string, d Config,
+	cli *docker.Client) ([]types.ImageSummary, error) {
+	var reference = composeProjectName(d.Name) + "_*"
+	if strings.ToLower(buildType) == "dockerfile" {
+		reference = dockerImageName(d.Name)
+	}
+	return cli.ImageList(context.Background(), types.ImageListOptions{
+		Filters: filters.NewArgs(
+			filters.KeyValuePair{Key: "reference", Value: reference}),
+	})
+}
+
 // dockerCompose builds and runs project using docker-compose -
 // the following code performs the bash equivalent of:
 //
@@ -123,9 +173,46 @@ func (b *Builder) Build(buildType string, d Config,
 // See https://cloud.google.com/community/tutorials/docker-compose-on-container-optimized-os
 func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 	out io.Writer) (func() error, error) {
+	if len(d.Secrets) > 0 {
+		return nil, errors.New("build secrets are only supported for dockerfile builds")
+	}
 	fmt.Fprintln(out, "Setting up docker-compose...")
 	ctx := context.Background()
 
+	// Work out build order if one is configured, otherwise build everything
+	// in one go
+	var batches = [][]string{nil}
+	if !d.Plan.IsEmpty() {
+		services, err := b.composeServices(d, cli)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services: %s", err.Error())
+		}
+		if batches, err = d.Plan.Batches(services); err != nil {
+			return nil, err
+		}
+		reportBuildOrder(batches, out)
+	}
+
+	// Start containers to build project
+	reportProjectBuildBegin(d.Name, out)
+	for _, batch := range batches {
+		var args = []string{"build"}
+		if len(batch) > 1 {
+			args = append(args, "--parallel")
+		}
+		if err := b.dockerComposeBuild(ctx, d, cli, out, append(args, batch...)...); err != nil {
+			return nil, err
+		}
+	}
+	reportProjectBuildComplete(d.Name, out)
+
+	return b.dockerComposeUp(ctx, d, cli, out)
+}
+
+// dockerComposeBuild runs docker-compose with the given arguments in the build
+// container, and waits for it to complete
+func (b *Builder) dockerComposeBuild(ctx context.Context, d Config, cli *docker.Client,
+	out io.Writer, args ...string) error {
 	dockercomposeFilePath := "docker-compose.yml"
 	if d.BuildFilePath != "" {
 		dockercomposeFilePath = d.BuildFilePath
@@ -135,15 +222,14 @@ func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 		ctx, &container.Config{
 			Image:      b.dockerComposeVersion,
 			WorkingDir: "/build",
-			Cmd: []string{
+			Cmd: append([]string{
 				"-p", d.Name,
 				"-f", dockercomposeFilePath,
-				"build",
-			},
-			Env: d.EnvValues,
+			}, args...),
+			Env:    d.EnvValues,
+			Labels: managedLabels,
 		},
 		&container.HostConfig{
-			AutoRemove: true,
 			Binds: []string{
 				getTrueDirectory(d.BuildDirectory) + ":/build",
 				"/var/run/docker.sock:/var/run/docker.sock",
@@ -151,20 +237,29 @@ func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 		}, nil, b.buildStageName,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	// Removed explicitly rather than automatically, so that the container name
+	// is free for the next batch
+	defer cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
 	if len(resp.Warnings) > 0 {
 		fmt.Fprintln(out, "Warnings encountered on docker-compose build.")
 		warnings := strings.Join(resp.Warnings, "\n")
-		return nil, errors.New(warnings)
+		return errors.New(warnings)
 	}
 
-	// Start container to build project
-	reportProjectBuildBegin(d.Name, out)
-	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
-		return nil, err
+	return containers.StartAndWait(cli, resp.ID, out)
+}
+
+// dockerComposeUp creates a container that runs docker-compose up for the
+// project, and returns a callback function to start it. Additional arguments
+// are passed to docker-compose up.
+func (b *Builder) dockerComposeUp(ctx context.Context, d Config, cli *docker.Client,
+	out io.Writer, args ...string) (func() error, error) {
+	dockercomposeFilePath := "docker-compose.yml"
+	if d.BuildFilePath != "" {
+		dockercomposeFilePath = d.BuildFilePath
 	}
-	reportProjectBuildComplete(d.Name, out)
 
 	// @TODO allow configuration
 	var (
@@ -176,16 +271,17 @@ func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 
 	// Set up docker-compose up
 	reportProjectContainerCreateBegin(d.Name, out)
-	resp, err = cli.ContainerCreate(
+	resp, err := cli.ContainerCreate(
 		ctx, &container.Config{
 			Image:      b.dockerComposeVersion,
 			WorkingDir: "/build",
-			Cmd: []string{
+			Cmd: append([]string{
 				"-p", d.Name,
 				"-f", dockercomposeFilePath,
 				"up",
-			},
-			Env: d.EnvValues,
+			}, args...),
+			Env:    d.EnvValues,
+			Labels: managedLabels,
 		},
 		&container.HostConfig{
 			AutoRemove: true,
@@ -210,14 +306,24 @@ func (b *Builder) dockerCompose(d Config, cli *docker.Client,
 // dockerBuild builds project from Dockerfile, and returns a callback function to deploy it
 func (b *Builder) dockerBuild(d Config, cli *docker.Client,
 	out io.Writer) (func() error, error) {
-	var (
-		ctx      = context.Background()
-		buildCtx = bytes.NewBuffer(nil)
-	)
+	var ctx = context.Background()
+	if err := b.dockerBuildImage(ctx, d, dockerImageName(d.Name), cli, out); err != nil {
+		return nil, err
+	}
+	return b.dockerCreate(ctx, d, cli, out)
+}
+
+// dockerBuildImage builds the project's Dockerfile into the given image
+func (b *Builder) dockerBuildImage(ctx context.Context, d Config, imageName string,
+	cli *docker.Client, out io.Writer) error {
+	if len(d.Secrets) > 0 {
+		return b.dockerBuildKit(ctx, d, imageName, cli, out)
+	}
 
 	// Create build context
+	var buildCtx = bytes.NewBuffer(nil)
 	if err := buildTar(d.BuildDirectory, buildCtx); err != nil {
-		return nil, err
+		return err
 	}
 
 	// @TODO: support configuration
@@ -228,39 +334,51 @@ func (b *Builder) dockerBuild(d Config, cli *docker.Client,
 
 	// Build image
 	reportProjectBuildBegin(d.Name, out)
-	imageName := "inertia-build/" + d.Name
 	buildResp, err := cli.ImageBuild(
 		ctx, buildCtx, types.ImageBuildOptions{
 			Tags:           []string{imageName},
 			Remove:         true,
 			Dockerfile:     dockerFilePath,
 			SuppressOutput: false,
+			Labels:         managedLabels,
 		},
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	stop := make(chan struct{})
 	log.FlushRoutine(out, buildResp.Body, stop)
 	close(stop)
 	buildResp.Body.Close()
-	// Get image details - this will check if image build was successful
+	// Check if image build was successful
+	if _, _, err := cli.ImageInspectWithRaw(ctx, imageName); err != nil {
+		return fmt.Errorf("image build failed: %s", err.Error())
+	}
+	reportProjectBuildComplete(d.Name, out)
+	return nil
+}
+
+// dockerCreate creates a container from the project's image, and returns a
+// callback function to start it
+func (b *Builder) dockerCreate(ctx context.Context, d Config, cli *docker.Client,
+	out io.Writer) (func() error, error) {
+	imageName := dockerImageName(d.Name)
 	image, _, err := cli.ImageInspectWithRaw(ctx, imageName)
 	if err != nil {
-		return nil, fmt.Errorf("image build failed: %s", err.Error())
+		return nil, fmt.Errorf("image not found: %s", err.Error())
 	}
 	portMap := nat.PortMap{}
 	for p := range image.Config.ExposedPorts {
 		portMap[p] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: p.Port()}}
 	}
-	reportProjectBuildComplete(d.Name, out)
 
 	// Create container from image
 	reportProjectContainerCreateBegin(d.Name, out)
 	containerResp, err := cli.ContainerCreate(
 		ctx, &container.Config{
-			Image: imageName,
-			Env:   d.EnvValues,
+			Image:  imageName,
+			Env:    d.EnvValues,
+			Labels: managedLabels,
 		},
 		&container.HostConfig{
 			PortBindings: portMap,
@@ -286,3 +404,31 @@ func (b *Builder) run(ctx context.Context, client *docker.Client, name, id strin
 	reportProjectStartup(name, out)
 	return client.ContainerStart(ctx, id, types.ContainerStartOptions{})
 }
+
+// dockerImageName returns the name of the image built from a project's
+// Dockerfile
+func dockerImageName(project string) string { return "inertia-build/" + project }
+
+// managedLabels are applied to images and containers created by the builder,
+// so that they can be pruned without touching unrelated Docker assets
+var managedLabels = map[string]string{containers.ManagedLabel: "true"}
+
+// ProjectLabels returns label filters that match Docker assets belonging to
+// the given project, including containers created by docker-compose
+func ProjectLabels(project string) []string {
+	return []string{
+		containers.ManagedLabel,
+		"com.docker.compose.project=" + composeProjectName(project),
+	}
+}
+
+// composeProjectName normalizes a project name the same way docker-compose
+// does, which is used as a prefix for the names of images it builds
+func composeProjectName(project string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(project))
+}
@@ -154,3 +154,41 @@ func TestBuilder_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestComposeProjectName(t *testing.T) {
+	assert.Equal(t, "myproject", composeProjectName("MyProject"))
+	assert.Equal(t, "my-project_1", composeProjectName("my-project_1"))
+	assert.Equal(t, "myproject", composeProjectName("my.project!"))
+}
+
+func TestBuildKitScript(t *testing.T) {
+	script, env, err := buildKitScript("inertia-build/project", "Dockerfile",
+		map[string]string{"NPM_TOKEN": "hunter2", "API_KEY": "wow"})
+	assert.Nil(t, err)
+
+	// Secret values should only be passed through the environment
+	assert.NotContains(t, script, "hunter2")
+	assert.Equal(t, []string{
+		"INERTIA_BUILD_SECRET_API_KEY=wow",
+		"INERTIA_BUILD_SECRET_NPM_TOKEN=hunter2",
+	}, env)
+	assert.Contains(t, script, `printf '%s' "$INERTIA_BUILD_SECRET_NPM_TOKEN" > /run/inertia/secrets/NPM_TOKEN`)
+	assert.Contains(t, script, "docker build --secret id=API_KEY,src=/run/inertia/secrets/API_KEY "+
+		"--secret id=NPM_TOKEN,src=/run/inertia/secrets/NPM_TOKEN --label inertia.managed=true "+
+		"-t 'inertia-build/project' -f 'Dockerfile' .")
+
+	// Secret names are used in the script, so must be safe
+	_, _, err = buildKitScript("inertia-build/project", "Dockerfile",
+		map[string]string{"$(rm -rf /)": "wow"})
+	assert.NotNil(t, err)
+}
+
+func TestBuildSecretsUnsupported(t *testing.T) {
+	b := NewBuilder(cfg.Config{}, nil)
+	_, err := b.Build("docker-compose", Config{
+		Name:    "project",
+		Secrets: map[string]string{"NPM_TOKEN": "hunter2"},
+	}, nil, os.Stdout)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "only supported for dockerfile builds")
+}
@@ -0,0 +1,150 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/versions"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+const (
+	// buildKitMinAPIVersion is the earliest Docker API version with BuildKit
+	// support, corresponding to Docker 18.09
+	buildKitMinAPIVersion = "1.39"
+
+	// buildSecretsDir is where secrets are written for the build, in a tmpfs
+	// mount of the build container so that they are never written to disk
+	buildSecretsDir = "/run/inertia/secrets"
+
+	// buildSecretEnvPrefix prefixes the environment variables used to pass
+	// secrets to the build container
+	buildSecretEnvPrefix = "INERTIA_BUILD_SECRET_"
+)
+
+var secretNamePattern = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// checkBuildKit returns an error if the Docker daemon does not support BuildKit
+func checkBuildKit(cli *docker.Client) error {
+	version, err := cli.ServerVersion(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check for BuildKit support: %s", err.Error())
+	}
+	if versions.LessThan(version.APIVersion, buildKitMinAPIVersion) {
+		return fmt.Errorf("build secrets require BuildKit, which is not available "+
+			"with Docker %s (API version %s) - Docker 18.09 or later is required",
+			version.Version, version.APIVersion)
+	}
+	return nil
+}
+
+// dockerBuildKit builds the project's Dockerfile with BuildKit into the given
+// image, providing the configured secrets as BuildKit secret mounts. This
+// performs the equivalent of:
+//
+//	DOCKER_BUILDKIT=1 docker build \
+//	    --secret id=NAME,src=/run/inertia/secrets/NAME \
+//	    -t inertia-build/project -f Dockerfile .
+//
+// in a container running the Docker CLI. Secret mounts are not persisted in
+// the image or its build cache.
+func (b *Builder) dockerBuildKit(ctx context.Context, d Config, imageName string,
+	cli *docker.Client, out io.Writer) error {
+	if err := checkBuildKit(cli); err != nil {
+		return err
+	}
+
+	dockerFilePath := "Dockerfile"
+	if d.BuildFilePath != "" {
+		dockerFilePath = d.BuildFilePath
+	}
+	script, env, err := buildKitScript(imageName, dockerFilePath, d.Secrets)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Setting up BuildKit build with %d secret(s)...\n", len(d.Secrets))
+	resp, err := cli.ContainerCreate(
+		ctx, &container.Config{
+			Image:      b.dockerCLIVersion,
+			WorkingDir: "/build",
+			Entrypoint: []string{"sh", "-c", script},
+			Env:        append(env, "DOCKER_BUILDKIT=1"),
+			Labels:     managedLabels,
+		},
+		&container.HostConfig{
+			AutoRemove: true,
+			Binds: []string{
+				getTrueDirectory(d.BuildDirectory) + ":/build",
+				"/var/run/docker.sock:/var/run/docker.sock",
+			},
+			Tmpfs: map[string]string{buildSecretsDir: "mode=0700"},
+		}, nil, b.buildStageName,
+	)
+	if err != nil {
+		return err
+	}
+	if len(resp.Warnings) > 0 {
+		return fmt.Errorf("warnings encountered on BuildKit build: %s",
+			strings.Join(resp.Warnings, "\n"))
+	}
+
+	// Start container to build project
+	reportProjectBuildBegin(d.Name, out)
+	if err := containers.StartAndWait(cli, resp.ID, out); err != nil {
+		return err
+	}
+	if _, _, err := cli.ImageInspectWithRaw(ctx, imageName); err != nil {
+		return fmt.Errorf("image build failed: %s", err.Error())
+	}
+	reportProjectBuildComplete(d.Name, out)
+	return nil
+}
+
+// buildKitScript generates a script that writes the given secrets to the
+// secrets directory and builds the given Dockerfile with them. Secret values
+// are passed through the returned environment rather than the script.
+func buildKitScript(image, dockerfile string,
+	secrets map[string]string) (string, []string, error) {
+	var names = make([]string, 0, len(secrets))
+	for name := range secrets {
+		if !secretNamePattern.MatchString(name) {
+			return "", nil, fmt.Errorf("invalid build secret name '%s'", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		env    = make([]string, 0, len(names))
+		writes = make([]string, 0, len(names))
+		args   = []string{"docker", "build"}
+	)
+	for _, name := range names {
+		var (
+			envName = buildSecretEnvPrefix + name
+			file    = path.Join(buildSecretsDir, name)
+		)
+		env = append(env, envName+"="+secrets[name])
+		writes = append(writes, fmt.Sprintf(`printf '%%s' "$%s" > %s`, envName, file))
+		args = append(args, "--secret", "id="+name+",src="+file)
+	}
+	args = append(args, "--label", containers.ManagedLabel+"=true",
+		"-t", shellQuote(image), "-f", shellQuote(dockerfile), ".")
+
+	var script = append([]string{"set -e", "umask 077"}, writes...)
+	script = append(script, strings.Join(args, " "))
+	return strings.Join(script, "\n"), env, nil
+}
+
+// shellQuote quotes the given value for use as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
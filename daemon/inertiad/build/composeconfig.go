@@ -0,0 +1,100 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	docker "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+// ComposeConfig writes the project's fully resolved docker-compose
+// configuration, as output by 'docker-compose config', to out. The project's
+// environment values are used for interpolation. Nothing is built or run.
+func (b *Builder) ComposeConfig(d Config, cli *docker.Client, out io.Writer) error {
+	config, err := b.dockerComposeOutput(d, cli, "config")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, config)
+	return err
+}
+
+// composeServices lists the services defined in the project's docker-compose
+// configuration
+func (b *Builder) composeServices(d Config, cli *docker.Client) ([]string, error) {
+	output, err := b.dockerComposeOutput(d, cli, "config", "--services")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// dockerComposeOutput runs docker-compose with the given arguments against a
+// read-only copy of the project, and returns its output
+func (b *Builder) dockerComposeOutput(d Config, cli *docker.Client, args ...string) (string, error) {
+	var ctx = context.Background()
+
+	dockercomposeFilePath := "docker-compose.yml"
+	if d.BuildFilePath != "" {
+		dockercomposeFilePath = d.BuildFilePath
+	}
+
+	resp, err := cli.ContainerCreate(
+		ctx, &container.Config{
+			Image:      b.dockerComposeVersion,
+			WorkingDir: "/build",
+			Cmd: append([]string{
+				"-p", d.Name,
+				"-f", dockercomposeFilePath,
+			}, args...),
+			Env:    d.EnvValues,
+			Labels: managedLabels,
+		},
+		&container.HostConfig{
+			Binds: []string{
+				getTrueDirectory(d.BuildDirectory) + ":/build:ro",
+			},
+		}, nil, "",
+	)
+	if err != nil {
+		return "", err
+	}
+	defer cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", err
+	}
+	exitCode, err := containers.Wait(cli, resp.ID, make(chan struct{}))
+	if err != nil {
+		return "", err
+	}
+
+	// Output is multiplexed, so separate stdout from errors
+	logs, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+	var (
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+	)
+	if _, err := stdcopy.StdCopy(stdout, stderr, logs); err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("docker-compose %s exited with status %d: %s",
+			strings.Join(args, " "), exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,110 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultComposeFile is the docker-compose file used if no build file path is
+// configured
+const DefaultComposeFile = "docker-compose.yml"
+
+// ComposeFileError describes a docker-compose file that the daemon cannot
+// deploy, because its schema version or some of its top-level keys are not
+// supported
+type ComposeFileError struct {
+	Path    string
+	Version string
+
+	// UnsupportedKeys are the top-level keys that are not supported by the
+	// file's schema version
+	UnsupportedKeys []string
+
+	reason string
+}
+
+func (e *ComposeFileError) Error() string {
+	if len(e.UnsupportedKeys) > 0 {
+		return fmt.Sprintf("docker-compose file %s (version %s) uses unsupported top-level keys: %s",
+			e.Path, e.Version, strings.Join(e.UnsupportedKeys, ", "))
+	}
+	return fmt.Sprintf("docker-compose file %s: %s", e.Path, e.reason)
+}
+
+// ValidateComposeFile parses the docker-compose file at the given path and
+// returns its schema version. Files that use a schema version other than 2.x
+// or 3.x, or top-level keys that their version does not support, are rejected
+// with a *ComposeFileError.
+func ValidateComposeFile(path string) (string, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var file map[string]interface{}
+	if err = yaml.Unmarshal(bytes, &file); err != nil {
+		return "", &ComposeFileError{Path: path, reason: "invalid YAML: " + err.Error()}
+	}
+
+	// Files without a version use the legacy version 1 schema, where services
+	// are declared at the top level
+	raw, found := file["version"]
+	if !found {
+		return "1", &ComposeFileError{Path: path, Version: "1",
+			reason: "version 1 files are not supported - declare 'version: \"3\"' and move services under 'services'"}
+	}
+	var version = fmt.Sprint(raw)
+	major, minor, err := parseComposeVersion(version)
+	if err != nil || (major != 2 && major != 3) {
+		return version, &ComposeFileError{Path: path, Version: version,
+			reason: "version " + version + " is not supported - use version 2.x or 3.x"}
+	}
+
+	var unsupported []string
+	for key := range file {
+		if !composeKeySupported(key, major, minor) {
+			unsupported = append(unsupported, key)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return version, &ComposeFileError{Path: path, Version: version, UnsupportedKeys: unsupported}
+	}
+	return version, nil
+}
+
+// parseComposeVersion parses versions such as "3" or "3.4"
+func parseComposeVersion(version string) (major, minor int, err error) {
+	var parts = strings.SplitN(version, ".", 2)
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if len(parts) > 1 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return major, minor, nil
+}
+
+// composeKeySupported returns true if the given top-level key may be used in
+// docker-compose files of the given schema version
+func composeKeySupported(key string, major, minor int) bool {
+	switch key {
+	case "version", "services", "networks", "volumes":
+		return true
+	case "secrets":
+		return major == 3 && minor >= 1
+	case "configs":
+		return major == 3 && minor >= 3
+	}
+	// Extension fields
+	if strings.HasPrefix(key, "x-") {
+		return (major == 2 && minor >= 1) || (major == 3 && minor >= 4)
+	}
+	return false
+}
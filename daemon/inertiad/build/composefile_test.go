@@ -0,0 +1,86 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateComposeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name            string
+		file            string
+		wantVersion     string
+		wantErr         bool
+		wantUnsupported []string
+	}{
+		{"v3", `
+version: "3.4"
+x-defaults: &defaults
+  restart: always
+services:
+  web:
+    <<: *defaults
+    image: nginx
+secrets:
+  key:
+    file: ./key.txt
+`, "3.4", false, nil},
+		{"v2", `
+version: '2'
+services:
+  web:
+    build: .
+networks:
+  front: {}
+volumes:
+  data: {}
+`, "2", false, nil},
+		{"numeric version", "version: 3\nservices: {}\n", "3", false, nil},
+		{"unsupported top-level key", `
+version: "3"
+services:
+  web:
+    image: nginx
+configs:
+  nginx:
+    file: ./nginx.conf
+web2:
+  image: nginx
+`, "3", true, []string{"configs", "web2"}},
+		{"unsupported extension field", "version: '2'\nx-defaults: {}\nservices: {}\n", "2", true, []string{"x-defaults"}},
+		{"v1", "web:\n  image: nginx\n", "1", true, nil},
+		{"unsupported version", "version: '4'\nservices: {}\n", "4", true, nil},
+		{"invalid version", "version: latest\nservices: {}\n", "latest", true, nil},
+		{"invalid yaml", "version: [\n", "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path = filepath.Join(dir, tt.name+".yml")
+			assert.Nil(t, ioutil.WriteFile(path, []byte(tt.file), 0644))
+
+			version, err := ValidateComposeFile(path)
+			assert.Equal(t, tt.wantVersion, version)
+			if !tt.wantErr {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.IsType(t, &ComposeFileError{}, err) {
+				assert.Equal(t, tt.wantUnsupported, err.(*ComposeFileError).UnsupportedKeys)
+				for _, key := range tt.wantUnsupported {
+					assert.Contains(t, err.Error(), key)
+				}
+			}
+		})
+	}
+
+	_, err = ValidateComposeFile(filepath.Join(dir, "nonexistent.yml"))
+	assert.NotNil(t, err)
+}
@@ -5,6 +5,7 @@ import (
 	io "io"
 	sync "sync"
 
+	types "github.com/docker/docker/api/types"
 	client "github.com/docker/docker/client"
 	build "github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 )
@@ -26,6 +27,19 @@ type FakeContainerBuilder struct {
 		result1 func() error
 		result2 error
 	}
+	ComposeConfigStub        func(build.Config, *client.Client, io.Writer) error
+	composeConfigMutex       sync.RWMutex
+	composeConfigArgsForCall []struct {
+		arg1 build.Config
+		arg2 *client.Client
+		arg3 io.Writer
+	}
+	composeConfigReturns struct {
+		result1 error
+	}
+	composeConfigReturnsOnCall map[int]struct {
+		result1 error
+	}
 	GetBuildStageNameStub        func() string
 	getBuildStageNameMutex       sync.RWMutex
 	getBuildStageNameArgsForCall []struct {
@@ -36,6 +50,48 @@ type FakeContainerBuilder struct {
 	getBuildStageNameReturnsOnCall map[int]struct {
 		result1 string
 	}
+	GetImagesStub        func(string, build.Config, *client.Client) ([]types.ImageSummary, error)
+	getImagesMutex       sync.RWMutex
+	getImagesArgsForCall []struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+	}
+	getImagesReturns struct {
+		result1 []types.ImageSummary
+		result2 error
+	}
+	getImagesReturnsOnCall map[int]struct {
+		result1 []types.ImageSummary
+		result2 error
+	}
+	PrewarmStub        func(string, build.Config, *client.Client, io.Writer) error
+	prewarmMutex       sync.RWMutex
+	prewarmArgsForCall []struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+		arg4 io.Writer
+	}
+	prewarmReturns struct {
+		result1 error
+	}
+	prewarmReturnsOnCall map[int]struct {
+		result1 error
+	}
+	PrewarmedStub        func(string, build.Config, *client.Client) bool
+	prewarmedMutex       sync.RWMutex
+	prewarmedArgsForCall []struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+	}
+	prewarmedReturns struct {
+		result1 bool
+	}
+	prewarmedReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	PruneStub        func(*client.Client, io.Writer) error
 	pruneMutex       sync.RWMutex
 	pruneArgsForCall []struct {
@@ -60,6 +116,22 @@ type FakeContainerBuilder struct {
 	pruneAllReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RunStub        func(string, build.Config, *client.Client, io.Writer) (func() error, error)
+	runMutex       sync.RWMutex
+	runArgsForCall []struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+		arg4 io.Writer
+	}
+	runReturns struct {
+		result1 func() error
+		result2 error
+	}
+	runReturnsOnCall map[int]struct {
+		result1 func() error
+		result2 error
+	}
 	StopContainersStub        func(*client.Client, io.Writer) error
 	stopContainersMutex       sync.RWMutex
 	stopContainersArgsForCall []struct {
@@ -142,6 +214,68 @@ func (fake *FakeContainerBuilder) BuildReturnsOnCall(i int, result1 func() error
 	}{result1, result2}
 }
 
+func (fake *FakeContainerBuilder) ComposeConfig(arg1 build.Config, arg2 *client.Client, arg3 io.Writer) error {
+	fake.composeConfigMutex.Lock()
+	ret, specificReturn := fake.composeConfigReturnsOnCall[len(fake.composeConfigArgsForCall)]
+	fake.composeConfigArgsForCall = append(fake.composeConfigArgsForCall, struct {
+		arg1 build.Config
+		arg2 *client.Client
+		arg3 io.Writer
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("ComposeConfig", []interface{}{arg1, arg2, arg3})
+	fake.composeConfigMutex.Unlock()
+	if fake.ComposeConfigStub != nil {
+		return fake.ComposeConfigStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.composeConfigReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerBuilder) ComposeConfigCallCount() int {
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
+	return len(fake.composeConfigArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) ComposeConfigCalls(stub func(build.Config, *client.Client, io.Writer) error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = stub
+}
+
+func (fake *FakeContainerBuilder) ComposeConfigArgsForCall(i int) (build.Config, *client.Client, io.Writer) {
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
+	argsForCall := fake.composeConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeContainerBuilder) ComposeConfigReturns(result1 error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = nil
+	fake.composeConfigReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) ComposeConfigReturnsOnCall(i int, result1 error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = nil
+	if fake.composeConfigReturnsOnCall == nil {
+		fake.composeConfigReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.composeConfigReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainerBuilder) GetBuildStageName() string {
 	fake.getBuildStageNameMutex.Lock()
 	ret, specificReturn := fake.getBuildStageNameReturnsOnCall[len(fake.getBuildStageNameArgsForCall)]
@@ -194,6 +328,196 @@ func (fake *FakeContainerBuilder) GetBuildStageNameReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *FakeContainerBuilder) GetImages(arg1 string, arg2 build.Config, arg3 *client.Client) ([]types.ImageSummary, error) {
+	fake.getImagesMutex.Lock()
+	ret, specificReturn := fake.getImagesReturnsOnCall[len(fake.getImagesArgsForCall)]
+	fake.getImagesArgsForCall = append(fake.getImagesArgsForCall, struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("GetImages", []interface{}{arg1, arg2, arg3})
+	fake.getImagesMutex.Unlock()
+	if fake.GetImagesStub != nil {
+		return fake.GetImagesStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getImagesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeContainerBuilder) GetImagesCallCount() int {
+	fake.getImagesMutex.RLock()
+	defer fake.getImagesMutex.RUnlock()
+	return len(fake.getImagesArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) GetImagesCalls(stub func(string, build.Config, *client.Client) ([]types.ImageSummary, error)) {
+	fake.getImagesMutex.Lock()
+	defer fake.getImagesMutex.Unlock()
+	fake.GetImagesStub = stub
+}
+
+func (fake *FakeContainerBuilder) GetImagesArgsForCall(i int) (string, build.Config, *client.Client) {
+	fake.getImagesMutex.RLock()
+	defer fake.getImagesMutex.RUnlock()
+	argsForCall := fake.getImagesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeContainerBuilder) GetImagesReturns(result1 []types.ImageSummary, result2 error) {
+	fake.getImagesMutex.Lock()
+	defer fake.getImagesMutex.Unlock()
+	fake.GetImagesStub = nil
+	fake.getImagesReturns = struct {
+		result1 []types.ImageSummary
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerBuilder) GetImagesReturnsOnCall(i int, result1 []types.ImageSummary, result2 error) {
+	fake.getImagesMutex.Lock()
+	defer fake.getImagesMutex.Unlock()
+	fake.GetImagesStub = nil
+	if fake.getImagesReturnsOnCall == nil {
+		fake.getImagesReturnsOnCall = make(map[int]struct {
+			result1 []types.ImageSummary
+			result2 error
+		})
+	}
+	fake.getImagesReturnsOnCall[i] = struct {
+		result1 []types.ImageSummary
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerBuilder) Prewarm(arg1 string, arg2 build.Config, arg3 *client.Client, arg4 io.Writer) error {
+	fake.prewarmMutex.Lock()
+	ret, specificReturn := fake.prewarmReturnsOnCall[len(fake.prewarmArgsForCall)]
+	fake.prewarmArgsForCall = append(fake.prewarmArgsForCall, struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+		arg4 io.Writer
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("Prewarm", []interface{}{arg1, arg2, arg3, arg4})
+	fake.prewarmMutex.Unlock()
+	if fake.PrewarmStub != nil {
+		return fake.PrewarmStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.prewarmReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerBuilder) PrewarmCallCount() int {
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	return len(fake.prewarmArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) PrewarmCalls(stub func(string, build.Config, *client.Client, io.Writer) error) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = stub
+}
+
+func (fake *FakeContainerBuilder) PrewarmArgsForCall(i int) (string, build.Config, *client.Client, io.Writer) {
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	argsForCall := fake.prewarmArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeContainerBuilder) PrewarmReturns(result1 error) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = nil
+	fake.prewarmReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) PrewarmReturnsOnCall(i int, result1 error) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = nil
+	if fake.prewarmReturnsOnCall == nil {
+		fake.prewarmReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.prewarmReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) Prewarmed(arg1 string, arg2 build.Config, arg3 *client.Client) bool {
+	fake.prewarmedMutex.Lock()
+	ret, specificReturn := fake.prewarmedReturnsOnCall[len(fake.prewarmedArgsForCall)]
+	fake.prewarmedArgsForCall = append(fake.prewarmedArgsForCall, struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Prewarmed", []interface{}{arg1, arg2, arg3})
+	fake.prewarmedMutex.Unlock()
+	if fake.PrewarmedStub != nil {
+		return fake.PrewarmedStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.prewarmedReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerBuilder) PrewarmedCallCount() int {
+	fake.prewarmedMutex.RLock()
+	defer fake.prewarmedMutex.RUnlock()
+	return len(fake.prewarmedArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) PrewarmedCalls(stub func(string, build.Config, *client.Client) bool) {
+	fake.prewarmedMutex.Lock()
+	defer fake.prewarmedMutex.Unlock()
+	fake.PrewarmedStub = stub
+}
+
+func (fake *FakeContainerBuilder) PrewarmedArgsForCall(i int) (string, build.Config, *client.Client) {
+	fake.prewarmedMutex.RLock()
+	defer fake.prewarmedMutex.RUnlock()
+	argsForCall := fake.prewarmedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeContainerBuilder) PrewarmedReturns(result1 bool) {
+	fake.prewarmedMutex.Lock()
+	defer fake.prewarmedMutex.Unlock()
+	fake.PrewarmedStub = nil
+	fake.prewarmedReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeContainerBuilder) PrewarmedReturnsOnCall(i int, result1 bool) {
+	fake.prewarmedMutex.Lock()
+	defer fake.prewarmedMutex.Unlock()
+	fake.PrewarmedStub = nil
+	if fake.prewarmedReturnsOnCall == nil {
+		fake.prewarmedReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.prewarmedReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *FakeContainerBuilder) Prune(arg1 *client.Client, arg2 io.Writer) error {
 	fake.pruneMutex.Lock()
 	ret, specificReturn := fake.pruneReturnsOnCall[len(fake.pruneArgsForCall)]
@@ -316,6 +640,72 @@ func (fake *FakeContainerBuilder) PruneAllReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainerBuilder) Run(arg1 string, arg2 build.Config, arg3 *client.Client, arg4 io.Writer) (func() error, error) {
+	fake.runMutex.Lock()
+	ret, specificReturn := fake.runReturnsOnCall[len(fake.runArgsForCall)]
+	fake.runArgsForCall = append(fake.runArgsForCall, struct {
+		arg1 string
+		arg2 build.Config
+		arg3 *client.Client
+		arg4 io.Writer
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("Run", []interface{}{arg1, arg2, arg3, arg4})
+	fake.runMutex.Unlock()
+	if fake.RunStub != nil {
+		return fake.RunStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.runReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeContainerBuilder) RunCallCount() int {
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	return len(fake.runArgsForCall)
+}
+
+func (fake *FakeContainerBuilder) RunCalls(stub func(string, build.Config, *client.Client, io.Writer) (func() error, error)) {
+	fake.runMutex.Lock()
+	defer fake.runMutex.Unlock()
+	fake.RunStub = stub
+}
+
+func (fake *FakeContainerBuilder) RunArgsForCall(i int) (string, build.Config, *client.Client, io.Writer) {
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
+	argsForCall := fake.runArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeContainerBuilder) RunReturns(result1 func() error, result2 error) {
+	fake.runMutex.Lock()
+	defer fake.runMutex.Unlock()
+	fake.RunStub = nil
+	fake.runReturns = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerBuilder) RunReturnsOnCall(i int, result1 func() error, result2 error) {
+	fake.runMutex.Lock()
+	defer fake.runMutex.Unlock()
+	fake.RunStub = nil
+	if fake.runReturnsOnCall == nil {
+		fake.runReturnsOnCall = make(map[int]struct {
+			result1 func() error
+			result2 error
+		})
+	}
+	fake.runReturnsOnCall[i] = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeContainerBuilder) StopContainers(arg1 *client.Client, arg2 io.Writer) error {
 	fake.stopContainersMutex.Lock()
 	ret, specificReturn := fake.stopContainersReturnsOnCall[len(fake.stopContainersArgsForCall)]
@@ -382,12 +772,22 @@ func (fake *FakeContainerBuilder) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.buildMutex.RLock()
 	defer fake.buildMutex.RUnlock()
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
 	fake.getBuildStageNameMutex.RLock()
 	defer fake.getBuildStageNameMutex.RUnlock()
+	fake.getImagesMutex.RLock()
+	defer fake.getImagesMutex.RUnlock()
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	fake.prewarmedMutex.RLock()
+	defer fake.prewarmedMutex.RUnlock()
 	fake.pruneMutex.RLock()
 	defer fake.pruneMutex.RUnlock()
 	fake.pruneAllMutex.RLock()
 	defer fake.pruneAllMutex.RUnlock()
+	fake.runMutex.RLock()
+	defer fake.runMutex.RUnlock()
 	fake.stopContainersMutex.RLock()
 	defer fake.stopContainersMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -3,6 +3,7 @@ package build
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
 func reportDeployInit(buildType, name string, out io.Writer) {
@@ -28,3 +29,11 @@ func reportProjectContainerCreateComplete(name string, out io.Writer) {
 func reportProjectStartup(name string, out io.Writer) {
 	fmt.Fprintf(out, "Starting up %s...\n", name)
 }
+
+func reportBuildOrder(batches [][]string, out io.Writer) {
+	var steps = make([]string, len(batches))
+	for i, batch := range batches {
+		steps[i] = "[" + strings.Join(batch, ", ") + "]"
+	}
+	fmt.Fprintf(out, "Building services in order: %s\n", strings.Join(steps, " -> "))
+}
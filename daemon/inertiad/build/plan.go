@@ -0,0 +1,73 @@
+package build
+
+import (
+	"fmt"
+)
+
+// Plan configures the order in which docker-compose services are built. The
+// zero value builds all services at once.
+type Plan struct {
+	// Groups are built one after another. Services not in any group are
+	// built after all groups.
+	Groups [][]string
+
+	// Parallelism is the number of services within a group that are built at
+	// the same time - 0 builds an entire group at once
+	Parallelism int
+}
+
+// IsEmpty returns true if the plan does not constrain builds
+func (p Plan) IsEmpty() bool {
+	return len(p.Groups) == 0 && p.Parallelism <= 0
+}
+
+// Batches splits the given services into batches that should be built one
+// after another, in which all services can be built at once. Services named
+// in the plan that are not in the given services are rejected.
+func (p Plan) Batches(services []string) ([][]string, error) {
+	var (
+		known   = make(map[string]bool, len(services))
+		grouped = make(map[string]bool, len(services))
+		groups  = make([][]string, 0, len(p.Groups)+1)
+	)
+	for _, s := range services {
+		known[s] = true
+	}
+	for _, group := range p.Groups {
+		for _, s := range group {
+			if !known[s] {
+				return nil, fmt.Errorf("build order references unknown service '%s'", s)
+			}
+			if grouped[s] {
+				return nil, fmt.Errorf("service '%s' appears more than once in build order", s)
+			}
+			grouped[s] = true
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	var rest = []string{}
+	for _, s := range services {
+		if !grouped[s] {
+			rest = append(rest, s)
+		}
+	}
+	if len(rest) > 0 {
+		groups = append(groups, rest)
+	}
+
+	var batches = [][]string{}
+	for _, group := range groups {
+		if p.Parallelism <= 0 {
+			batches = append(batches, group)
+			continue
+		}
+		for len(group) > p.Parallelism {
+			batches = append(batches, group[:p.Parallelism])
+			group = group[p.Parallelism:]
+		}
+		batches = append(batches, group)
+	}
+	return batches, nil
+}
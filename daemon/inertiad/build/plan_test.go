@@ -0,0 +1,47 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanBatches(t *testing.T) {
+	var services = []string{"api", "db", "web", "worker", "cache"}
+	tests := []struct {
+		name    string
+		plan    Plan
+		want    [][]string
+		wantErr bool
+	}{
+		{"empty", Plan{},
+			[][]string{{"api", "db", "web", "worker", "cache"}}, false},
+		{"serial", Plan{Parallelism: 1},
+			[][]string{{"api"}, {"db"}, {"web"}, {"worker"}, {"cache"}}, false},
+		{"parallelism", Plan{Parallelism: 2},
+			[][]string{{"api", "db"}, {"web", "worker"}, {"cache"}}, false},
+		{"groups", Plan{Groups: [][]string{{"db"}, {"api", "worker"}}},
+			[][]string{{"db"}, {"api", "worker"}, {"web", "cache"}}, false},
+		{"groups with parallelism", Plan{Groups: [][]string{{"db", "cache", "api"}}, Parallelism: 2},
+			[][]string{{"db", "cache"}, {"api"}, {"web", "worker"}}, false},
+		{"unknown service", Plan{Groups: [][]string{{"nope"}}}, nil, true},
+		{"duplicate service", Plan{Groups: [][]string{{"db"}, {"db"}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.plan.Batches(services)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPlanIsEmpty(t *testing.T) {
+	assert.True(t, Plan{}.IsEmpty())
+	assert.False(t, Plan{Parallelism: 1}.IsEmpty())
+	assert.False(t, Plan{Groups: [][]string{{"db"}}}.IsEmpty())
+}
@@ -0,0 +1,101 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	docker "github.com/docker/docker/client"
+)
+
+// Prewarm builds the project's image for d.Revision ahead of a deployment,
+// without touching active containers. The image is tagged with the revision,
+// and is used by Run when a later deployment is for the same revision. An
+// image that has already been pre-warmed for the revision is reused. Only
+// dockerfile builds can be pre-warmed.
+func (b *Builder) Prewarm(buildType string, d Config,
+	cli *docker.Client, out io.Writer) error {
+	if strings.ToLower(buildType) != "dockerfile" {
+		return errors.New("only dockerfile builds can be pre-warmed")
+	}
+	if d.Revision == "" {
+		return errors.New("a revision is required to pre-warm a build")
+	}
+	if b.Prewarmed(buildType, d, cli) {
+		fmt.Fprintf(out, "Image for %s is already pre-warmed\n", d.Revision)
+		return nil
+	}
+
+	var (
+		ctx       = context.Background()
+		imageName = prewarmedImageName(d.Name, d.Revision)
+	)
+	fmt.Fprintf(out, "Pre-warming image for %s...\n", d.Revision)
+	if err := b.dockerBuildImage(ctx, d, imageName, cli, out); err != nil {
+		return err
+	}
+
+	// Only the most recently pre-warmed revision is kept around
+	if err := removePrewarmed(ctx, d.Name, cli, imageName); err != nil {
+		fmt.Fprintln(out, "unable to remove stale pre-warmed images: "+err.Error())
+	}
+	return nil
+}
+
+// Prewarmed returns true if an image has been pre-warmed for d.Revision
+func (b *Builder) Prewarmed(buildType string, d Config, cli *docker.Client) bool {
+	if strings.ToLower(buildType) != "dockerfile" || d.Revision == "" {
+		return false
+	}
+	_, _, err := cli.ImageInspectWithRaw(context.Background(),
+		prewarmedImageName(d.Name, d.Revision))
+	return err == nil
+}
+
+// usePrewarmed tags the image pre-warmed for d.Revision as the project's image
+func usePrewarmed(ctx context.Context, d Config, cli *docker.Client, out io.Writer) error {
+	fmt.Fprintf(out, "Using image pre-warmed for %s\n", d.Revision)
+	return cli.ImageTag(ctx, prewarmedImageName(d.Name, d.Revision),
+		dockerImageName(d.Name))
+}
+
+// removePrewarmed removes the tags of all of the project's pre-warmed images
+// except the given one
+func removePrewarmed(ctx context.Context, project string, cli *docker.Client,
+	keep string) error {
+	images, err := cli.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.KeyValuePair{
+			Key: "reference", Value: dockerImageName(project) + ":*"}),
+	})
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if tag == keep || !isPrewarmedImageName(project, tag) {
+				continue
+			}
+			if _, err := cli.ImageRemove(ctx, tag, types.ImageRemoveOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prewarmedImageName returns the name of the image pre-warmed for the given
+// revision of a project
+func prewarmedImageName(project, revision string) string {
+	return dockerImageName(project) + ":" + revision
+}
+
+// isPrewarmedImageName returns true if the given image name is a pre-warmed
+// image of the given project
+func isPrewarmedImageName(project, name string) bool {
+	var tag = strings.TrimPrefix(name, dockerImageName(project)+":")
+	return tag != name && tag != "latest"
+}
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+func TestBuilder_PrewarmUnsupported(t *testing.T) {
+	var b = NewBuilder(cfg.Config{}, nil)
+	var out = &bytes.Buffer{}
+
+	err := b.Prewarm("docker-compose", Config{Name: "p", Revision: "abcde"}, nil, out)
+	assert.EqualError(t, err, "only dockerfile builds can be pre-warmed")
+	err = b.Prewarm("dockerfile", Config{Name: "p"}, nil, out)
+	assert.EqualError(t, err, "a revision is required to pre-warm a build")
+
+	assert.False(t, b.Prewarmed("docker-compose", Config{Name: "p", Revision: "abcde"}, nil))
+	assert.False(t, b.Prewarmed("dockerfile", Config{Name: "p"}, nil))
+}
+
+func Test_isPrewarmedImageName(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		want  bool
+	}{
+		{"pre-warmed", prewarmedImageName("p", "abcde"), true},
+		{"latest", "inertia-build/p:latest", false},
+		{"other project", prewarmedImageName("q", "abcde"), false},
+		{"untagged", "inertia-build/p", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPrewarmedImageName("p", tt.image))
+		})
+	}
+}
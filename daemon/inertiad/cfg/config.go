@@ -1,6 +1,23 @@
 package cfg
 
-import "os"
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultStatusCacheInterval is the default maximum age of cached
+	// deployment statuses
+	DefaultStatusCacheInterval = 2 * time.Second
+
+	// DefaultHistoryLimit is the default number of deployments retained in
+	// the deployment history
+	DefaultHistoryLimit = 50
+)
 
 // Config provides basic daemon configuration
 type Config struct {
@@ -11,16 +28,315 @@ type Config struct {
 
 	// Build tools
 	DockerComposeVersion string // "docker/compose:1.21.0"
+	DockerCLIVersion     string // "docker:19.03"
+
+	// ConfigFile is an optional file of environment-style 'KEY=value' lines
+	// that override environment values, and can be reloaded at runtime
+	ConfigFile string // "/app/host/.inertia/daemon.env"
+
+	// StatusCacheInterval is the maximum age of cached deployment statuses
+	StatusCacheInterval time.Duration // "2s"
+
+	// HistoryLimit is the default number of deployments retained in the
+	// deployment history, which projects may override
+	HistoryLimit int // "50"
+
+	// CompressHistory enables compression of older deployment history
+	// records, which trades CPU for disk space
+	CompressHistory bool // "false"
+
+	// MaskLogSecrets enables replacing the values of encrypted environment
+	// variables in container logs, at the cost of scanning all log output
+	MaskLogSecrets bool // "false"
+
+	// CleanupNetworks enables removing shared networks created by Inertia
+	// once no containers are attached to them
+	CleanupNetworks bool // "false"
+
+	// SessionTimeout is how long session tokens issued on login are valid for
+	// - zero uses the default
+	SessionTimeout time.Duration // "8h"
+
+	// RememberSessionTimeout is how long session tokens issued on login are
+	// valid for if the user asked to be remembered - zero uses the default
+	RememberSessionTimeout time.Duration // "720h"
+
+	// ResetTokenTimeout is how long password reset tokens are valid for - zero
+	// uses the default
+	ResetTokenTimeout time.Duration // "1h"
+
+	// PasswordHashCost is the bcrypt cost used to hash user passwords - zero
+	// uses bcrypt's default cost
+	PasswordHashCost int // "10"
+
+	// PasswordPepperVersion is the version of the secret pepper that new
+	// passwords are combined with before they are hashed - zero disables
+	// peppering. Peppers are kept in the secrets directory, and one is
+	// generated for this version if it does not exist yet. Peppers of earlier
+	// versions are kept so that existing passwords can still be verified.
+	PasswordPepperVersion int // "0"
+
+	// Password policy requirements for new passwords
+	PasswordMinLength        int  // "0"
+	PasswordRequireDigit     bool // "false"
+	PasswordRequireSymbol    bool // "false"
+	PasswordRequireMixedCase bool // "false"
+
+	// ReservedUsernames is a comma-separated list of usernames that new users
+	// cannot use - if empty, the default reserved usernames are used
+	ReservedUsernames []string // ""
+
+	// LoginLockoutThreshold is the number of consecutive failed logins after
+	// which a username is locked out, and LoginLockoutCooldown is how long the
+	// lockout lasts - zero values use the defaults
+	LoginLockoutThreshold int           // "5"
+	LoginLockoutCooldown  time.Duration // "15m"
+
+	// LoginBackoff is the delay enforced after a failed login for a username,
+	// which doubles with each consecutive failure up to LoginBackoffCap - zero
+	// disables login backoff, and a zero cap uses the default
+	LoginBackoff    time.Duration // "0"
+	LoginBackoffCap time.Duration // "5m"
+
+	// RateLimit is the number of requests per second each client address may
+	// make once it has used up RateLimitBurst - zero disables rate limiting.
+	// RateLimitExemptPublic exempts public endpoints such as webhooks.
+	RateLimit             float64 // "0"
+	RateLimitBurst        int     // "1"
+	RateLimitExemptPublic bool    // "false"
+
+	// AllowedCIDRs and DeniedCIDRs are comma-separated lists of address ranges
+	// that clients must, or must not, be in - if both are empty, all clients
+	// are allowed. TrustedProxies is the number of proxies in front of the
+	// daemon whose X-Forwarded-For entries identify the client, and
+	// IPFilterExemptPublic exempts public endpoints such as health checks.
+	AllowedCIDRs         []string // ""
+	DeniedCIDRs          []string // ""
+	TrustedProxies       int      // "0"
+	IPFilterExemptPublic bool     // "false"
+
+	// ShutdownTimeout is how long the daemon waits for in-flight requests to
+	// finish when shutting down - zero uses the default
+	ShutdownTimeout time.Duration // "10s"
+
+	// RequestTimeout is how long authorizing a request, and serving user
+	// management endpoints, may take - zero disables the timeout
+	RequestTimeout time.Duration // "0"
+
+	// AuditLog is the file that auth audit events are appended to - if empty,
+	// no audit events are kept
+	AuditLog string // ""
+
+	// AuthRealm is the realm that clients are asked to authenticate for in
+	// unauthorized responses - if empty, the default realm is used
+	AuthRealm string // "inertia"
+
+	// AuthTokenParam is the query parameter that websocket upgrade requests,
+	// such as those for streaming logs, may provide a bearer token in - if
+	// empty, the default parameter is used
+	AuthTokenParam string // "access_token"
+
+	// TokenSigningKey is a PEM-encoded RSA private key that session and
+	// read-only tokens are signed with using RS256, so that other services can
+	// validate them with the public key - if empty, tokens are signed with HMAC
+	TokenSigningKey string // ""
+
+	// PreviousTokenSigningKey is the RSA private key that tokens were signed
+	// with before TokenSigningKey - its public key is still published, and
+	// tokens it signed are still accepted, for TokenKeyOverlap after startup.
+	// A zero overlap uses the default.
+	PreviousTokenSigningKey string        // ""
+	TokenKeyOverlap         time.Duration // "8h"
+
+	// HSTSMaxAge is how long browsers should only access the daemon over
+	// HTTPS, which is sent in the Strict-Transport-Security header - zero
+	// disables the header. HSTSIncludeSubdomains extends it to subdomains.
+	HSTSMaxAge            time.Duration // "0"
+	HSTSIncludeSubdomains bool          // "false"
+
+	// Plaintext determines how requests not made over HTTPS are handled - one
+	// of "allow", "redirect", or "reject"
+	Plaintext string // "allow"
+
+	// Session cookie attributes - cookies are secure unless CookieInsecure is
+	// set, and CookieSameSite is one of "lax" or "strict"
+	CookieDomain   string // ""
+	CookiePath     string // "/"
+	CookieSameSite string // "lax"
+	CookieInsecure bool   // "false"
+
+	// LoginToken determines how session tokens are returned on login - one of
+	// "cookie", "body", or "both". With "cookie", tokens are only returned in
+	// the response body to clients that accept JSON, such as the Inertia CLI.
+	LoginToken string // "cookie"
+
+	// WebhookDedupWindow is how long webhook delivery IDs are remembered, so
+	// that repeated deliveries of the same event are ignored
+	WebhookDedupWindow time.Duration // "10m"
 
 	WebhookSecret string
 }
 
 // New creates a new daemon configuration from environment values
 func New() *Config {
+	return newConfig(os.Getenv)
+}
+
+// Load creates a new daemon configuration from environment values, overridden
+// by values in the given config file if it exists
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return New(), nil
+	}
+	values, err := readConfigFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return New(), nil
+		}
+		return nil, err
+	}
+	return newConfig(func(key string) string {
+		if v, found := values[key]; found {
+			return v
+		}
+		return os.Getenv(key)
+	}), nil
+}
+
+func newConfig(getenv func(string) string) *Config {
 	return &Config{
-		SecretsDirectory:     os.Getenv("INERTIA_SECRETS_DIR"),
-		DataDirectory:        os.Getenv("INERTIA_DATA_DIR"),
-		DockerComposeVersion: os.Getenv("INERTIA_DOCKERCOMPOSE"),
-		ProjectDirectory:     os.Getenv("INERTIA_PROJECT_DIR"),
+		SecretsDirectory:     getenv("INERTIA_SECRETS_DIR"),
+		DataDirectory:        getenv("INERTIA_DATA_DIR"),
+		DockerComposeVersion: getenv("INERTIA_DOCKERCOMPOSE"),
+		DockerCLIVersion:     getenv("INERTIA_DOCKERCLI"),
+		ProjectDirectory:     getenv("INERTIA_PROJECT_DIR"),
+		ConfigFile:           os.Getenv("INERTIA_CONFIG_FILE"),
+		StatusCacheInterval:  getDuration(getenv("INERTIA_STATUS_CACHE_INTERVAL"), DefaultStatusCacheInterval),
+		HistoryLimit:         getInt(getenv("INERTIA_HISTORY_LIMIT"), DefaultHistoryLimit),
+		CompressHistory:      getenv("INERTIA_COMPRESS_HISTORY") == "true",
+		MaskLogSecrets:       getenv("INERTIA_MASK_LOG_SECRETS") == "true",
+		CleanupNetworks:      getenv("INERTIA_CLEANUP_NETWORKS") == "true",
+		SessionTimeout:       getDuration(getenv("INERTIA_SESSION_TIMEOUT"), 0),
+		PasswordHashCost:     getInt(getenv("INERTIA_PASSWORD_HASH_COST"), 0),
+
+		PasswordPepperVersion: getInt(getenv("INERTIA_PASSWORD_PEPPER_VERSION"), 0),
+
+		PasswordMinLength:        getInt(getenv("INERTIA_PASSWORD_MIN_LENGTH"), 0),
+		PasswordRequireDigit:     getenv("INERTIA_PASSWORD_REQUIRE_DIGIT") == "true",
+		PasswordRequireSymbol:    getenv("INERTIA_PASSWORD_REQUIRE_SYMBOL") == "true",
+		PasswordRequireMixedCase: getenv("INERTIA_PASSWORD_REQUIRE_MIXED_CASE") == "true",
+		ReservedUsernames:        getList(getenv("INERTIA_RESERVED_USERNAMES")),
+
+		LoginLockoutThreshold: getInt(getenv("INERTIA_LOGIN_LOCKOUT_THRESHOLD"), 0),
+		LoginLockoutCooldown:  getDuration(getenv("INERTIA_LOGIN_LOCKOUT_COOLDOWN"), 0),
+		LoginBackoff:          getDuration(getenv("INERTIA_LOGIN_BACKOFF"), 0),
+		LoginBackoffCap:       getDuration(getenv("INERTIA_LOGIN_BACKOFF_CAP"), 0),
+
+		RateLimit:             getFloat(getenv("INERTIA_RATE_LIMIT"), 0),
+		RateLimitBurst:        getInt(getenv("INERTIA_RATE_LIMIT_BURST"), 1),
+		RateLimitExemptPublic: getenv("INERTIA_RATE_LIMIT_EXEMPT_PUBLIC") == "true",
+
+		AllowedCIDRs:         getList(getenv("INERTIA_ALLOWED_CIDRS")),
+		DeniedCIDRs:          getList(getenv("INERTIA_DENIED_CIDRS")),
+		TrustedProxies:       getInt(getenv("INERTIA_TRUSTED_PROXIES"), 0),
+		IPFilterExemptPublic: getenv("INERTIA_IP_FILTER_EXEMPT_PUBLIC") == "true",
+
+		ShutdownTimeout: getDuration(getenv("INERTIA_SHUTDOWN_TIMEOUT"), 0),
+		RequestTimeout:  getDuration(getenv("INERTIA_REQUEST_TIMEOUT"), 0),
+
+		RememberSessionTimeout: getDuration(getenv("INERTIA_REMEMBER_SESSION_TIMEOUT"), 0),
+		ResetTokenTimeout:      getDuration(getenv("INERTIA_RESET_TOKEN_TIMEOUT"), 0),
+
+		AuditLog:       getenv("INERTIA_AUDIT_LOG"),
+		AuthRealm:      getenv("INERTIA_AUTH_REALM"),
+		AuthTokenParam: getenv("INERTIA_AUTH_TOKEN_PARAM"),
+
+		TokenSigningKey:         getenv("INERTIA_TOKEN_SIGNING_KEY"),
+		PreviousTokenSigningKey: getenv("INERTIA_PREVIOUS_TOKEN_SIGNING_KEY"),
+		TokenKeyOverlap:         getDuration(getenv("INERTIA_TOKEN_KEY_OVERLAP"), 0),
+
+		HSTSMaxAge:            getDuration(getenv("INERTIA_HSTS_MAX_AGE"), 0),
+		HSTSIncludeSubdomains: getenv("INERTIA_HSTS_INCLUDE_SUBDOMAINS") == "true",
+		Plaintext:             getenv("INERTIA_PLAINTEXT"),
+
+		CookieDomain:   getenv("INERTIA_COOKIE_DOMAIN"),
+		CookiePath:     getenv("INERTIA_COOKIE_PATH"),
+		CookieSameSite: getenv("INERTIA_COOKIE_SAMESITE"),
+		CookieInsecure: getenv("INERTIA_COOKIE_INSECURE") == "true",
+
+		LoginToken: getenv("INERTIA_LOGIN_TOKEN"),
+
+		WebhookDedupWindow: getDuration(getenv("INERTIA_WEBHOOK_DEDUP_WINDOW"), 0),
+	}
+}
+
+// readConfigFile parses 'KEY=value' lines from the given file. Blank lines and
+// lines starting with '#' are ignored.
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		values  = make(map[string]string)
+		scanner = bufio.NewScanner(file)
+		line    = 0
+	)
+	for scanner.Scan() {
+		line++
+		var text = strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var kv = strings.SplitN(text, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("%s:%d: expected 'KEY=value'", path, line)
+		}
+		values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return values, scanner.Err()
+}
+
+// getInt parses the given value as a positive integer, falling back to the
+// given default if it is unset or invalid
+func getInt(value string, fallback int) int {
+	i, err := strconv.Atoi(value)
+	if err != nil || i <= 0 {
+		return fallback
+	}
+	return i
+}
+
+// getFloat parses the given value as a positive number, falling back to the
+// given default if it is unset or invalid
+func getFloat(value string, fallback float64) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		return fallback
+	}
+	return f
+}
+
+// getList parses the given value as a comma-separated list, ignoring empty
+// entries. It returns nil if there are no entries.
+func getList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// getDuration parses the given value as a duration, falling back to the given
+// default if it is unset or invalid
+func getDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
 	}
+	return d
 }
@@ -1,8 +1,11 @@
 package cfg
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,3 +15,91 @@ func TestNew(t *testing.T) {
 	cfg := New()
 	assert.Equal(t, "/user/project", cfg.ProjectDirectory)
 }
+
+func TestNewStatusCacheInterval(t *testing.T) {
+	os.Setenv("INERTIA_STATUS_CACHE_INTERVAL", "10s")
+	assert.Equal(t, 10*time.Second, New().StatusCacheInterval)
+
+	os.Setenv("INERTIA_STATUS_CACHE_INTERVAL", "wow")
+	assert.Equal(t, DefaultStatusCacheInterval, New().StatusCacheInterval)
+	os.Unsetenv("INERTIA_STATUS_CACHE_INTERVAL")
+}
+
+func TestNewHistoryLimit(t *testing.T) {
+	os.Setenv("INERTIA_HISTORY_LIMIT", "10")
+	assert.Equal(t, 10, New().HistoryLimit)
+
+	os.Setenv("INERTIA_HISTORY_LIMIT", "-1")
+	assert.Equal(t, DefaultHistoryLimit, New().HistoryLimit)
+	os.Unsetenv("INERTIA_HISTORY_LIMIT")
+}
+
+func TestNewPasswordHashCost(t *testing.T) {
+	assert.Equal(t, 0, New().PasswordHashCost)
+	os.Setenv("INERTIA_PASSWORD_HASH_COST", "12")
+	assert.Equal(t, 12, New().PasswordHashCost)
+	os.Unsetenv("INERTIA_PASSWORD_HASH_COST")
+}
+
+func TestNewReservedUsernames(t *testing.T) {
+	assert.Nil(t, New().ReservedUsernames)
+	os.Setenv("INERTIA_RESERVED_USERNAMES", "admin, deploybot,,")
+	assert.Equal(t, []string{"admin", "deploybot"}, New().ReservedUsernames)
+	os.Unsetenv("INERTIA_RESERVED_USERNAMES")
+}
+
+func TestNewCleanupNetworks(t *testing.T) {
+	assert.False(t, New().CleanupNetworks)
+	os.Setenv("INERTIA_CLEANUP_NETWORKS", "true")
+	assert.True(t, New().CleanupNetworks)
+	os.Unsetenv("INERTIA_CLEANUP_NETWORKS")
+}
+
+func TestNewCompressHistory(t *testing.T) {
+	assert.False(t, New().CompressHistory)
+	os.Setenv("INERTIA_COMPRESS_HISTORY", "true")
+	assert.True(t, New().CompressHistory)
+	os.Unsetenv("INERTIA_COMPRESS_HISTORY")
+}
+
+func TestNewMaskLogSecrets(t *testing.T) {
+	assert.False(t, New().MaskLogSecrets)
+	os.Setenv("INERTIA_MASK_LOG_SECRETS", "true")
+	assert.True(t, New().MaskLogSecrets)
+	os.Unsetenv("INERTIA_MASK_LOG_SECRETS")
+}
+
+func TestLoad(t *testing.T) {
+	os.Setenv("INERTIA_PROJECT_DIR", "/user/project")
+	os.Setenv("INERTIA_HISTORY_LIMIT", "10")
+	defer os.Unsetenv("INERTIA_HISTORY_LIMIT")
+
+	// Missing or unset config files should fall back to environment
+	cfg, err := Load("")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, cfg.HistoryLimit)
+	cfg, err = Load(filepath.Join(os.TempDir(), "inertia-missing.env"))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, cfg.HistoryLimit)
+
+	// Config file values should override environment
+	dir, err := ioutil.TempDir("", "inertia-cfg")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "daemon.env")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`
+# daemon tuning
+INERTIA_HISTORY_LIMIT=20
+INERTIA_MASK_LOG_SECRETS = true
+`), 0600))
+	cfg, err = Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, cfg.HistoryLimit)
+	assert.True(t, cfg.MaskLogSecrets)
+	assert.Equal(t, "/user/project", cfg.ProjectDirectory)
+
+	// Malformed lines should be rejected
+	assert.Nil(t, ioutil.WriteFile(path, []byte("INERTIA_HISTORY_LIMIT\n"), 0600))
+	_, err = Load(path)
+	assert.NotNil(t, err)
+}
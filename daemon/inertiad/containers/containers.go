@@ -13,6 +13,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 )
 
@@ -109,20 +110,59 @@ func StopActiveContainers(docker *docker.Client, out io.Writer) error {
 	return nil
 }
 
-// Prune clears up unused Docker assets.
-func Prune(docker *docker.Client) error {
-	ctx := context.Background()
+// ManagedLabel marks images and containers that were created by Inertia
+const ManagedLabel = "inertia.managed"
+
+// Pruner is the subset of the Docker client used to prune assets
+type Pruner interface {
+	ContainersPrune(ctx context.Context, pruneFilters filters.Args) (types.ContainersPruneReport, error)
+	ImagesPrune(ctx context.Context, pruneFilters filters.Args) (types.ImagesPruneReport, error)
+}
 
-	_, errImages := docker.ImagesPrune(ctx, filters.Args{})
-	_, errContainers := docker.ContainersPrune(ctx, filters.Args{})
-	_, errVolumes := docker.VolumesPrune(ctx, filters.Args{})
-	if errImages != nil || errContainers != nil || errVolumes != nil {
-		return fmt.Errorf(
-			"Errors encountered: %s ; %s ; %s",
-			errImages, errContainers, errVolumes,
-		)
+// Prune removes stopped containers and dangling images that have any of the
+// given labels, which can be either "key" or "key=value". Running containers
+// and the images they use are never removed, so it is safe to prune while a
+// project is deployed. If no labels are given, ManagedLabel is used.
+func Prune(cli Pruner, labels ...string) (api.PruneReport, error) {
+	if len(labels) == 0 {
+		labels = []string{ManagedLabel}
 	}
-	return nil
+	var (
+		ctx    = context.Background()
+		report = api.PruneReport{
+			ContainersDeleted: []string{},
+			ImagesDeleted:     []string{},
+		}
+	)
+
+	// Docker requires resources to match every label filter, so each label is
+	// pruned separately. Containers go first to release the images they used.
+	for _, label := range labels {
+		resp, err := cli.ContainersPrune(ctx, filters.NewArgs(
+			filters.KeyValuePair{Key: "label", Value: label},
+		))
+		if err != nil {
+			return report, fmt.Errorf("failed to prune containers: %s", err.Error())
+		}
+		report.ContainersDeleted = append(report.ContainersDeleted, resp.ContainersDeleted...)
+		report.SpaceReclaimed += resp.SpaceReclaimed
+	}
+	for _, label := range labels {
+		resp, err := cli.ImagesPrune(ctx, filters.NewArgs(
+			filters.KeyValuePair{Key: "dangling", Value: "true"},
+			filters.KeyValuePair{Key: "label", Value: label},
+		))
+		if err != nil {
+			return report, fmt.Errorf("failed to prune images: %s", err.Error())
+		}
+		for _, image := range resp.ImagesDeleted {
+			if image.Deleted != "" {
+				report.ImagesDeleted = append(report.ImagesDeleted, image.Deleted)
+			}
+		}
+		report.SpaceReclaimed += resp.SpaceReclaimed
+	}
+	return report, nil
 }
 
 // PruneAll forcibly removes all images except given exceptions (repo tag names)
@@ -159,6 +199,18 @@ func PruneAll(docker *docker.Client, exceptions ...string) error {
 	return nil
 }
 
+// LoadImages loads images from a tarball in the format used by 'docker save'
+func LoadImages(cli *docker.Client, in io.Reader, out io.Writer) error {
+	resp, err := cli.ImageLoad(context.Background(), in, true)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	log.FlushRoutine(out, resp.Body, stop)
+	close(stop)
+	return resp.Body.Close()
+}
+
 // Wait blocks until given container ID stops
 func Wait(cli *docker.Client, id string, stop chan struct{}) (int64, error) {
 	var status container.ContainerWaitOKBody
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -85,6 +86,65 @@ func TestPrune(t *testing.T) {
 	Prune(cli)
 }
 
+// fakePruner records the filters it is called with, and reports one asset
+// named after the filtered label as removed
+type fakePruner struct {
+	containerFilters []filters.Args
+	imageFilters     []filters.Args
+}
+
+func (f *fakePruner) ContainersPrune(ctx context.Context, args filters.Args) (types.ContainersPruneReport, error) {
+	f.containerFilters = append(f.containerFilters, args)
+	return types.ContainersPruneReport{
+		ContainersDeleted: []string{"container-" + args.Get("label")[0]},
+		SpaceReclaimed:    10,
+	}, nil
+}
+
+func (f *fakePruner) ImagesPrune(ctx context.Context, args filters.Args) (types.ImagesPruneReport, error) {
+	f.imageFilters = append(f.imageFilters, args)
+	return types.ImagesPruneReport{
+		ImagesDeleted: []types.ImageDeleteResponseItem{
+			{Untagged: "inertia-build/myproject:old"},
+			{Deleted: "image-" + args.Get("label")[0]},
+		},
+		SpaceReclaimed: 100,
+	}, nil
+}
+
+func TestPruneFilters(t *testing.T) {
+	var pruner = &fakePruner{}
+	report, err := Prune(pruner, ManagedLabel, "com.docker.compose.project=myproject")
+	assert.Nil(t, err)
+
+	// every prune should be restricted to one of the given labels
+	assert.Len(t, pruner.containerFilters, 2)
+	assert.Len(t, pruner.imageFilters, 2)
+	for i, label := range []string{ManagedLabel, "com.docker.compose.project=myproject"} {
+		assert.Equal(t, []string{label}, pruner.containerFilters[i].Get("label"))
+		assert.Equal(t, []string{label}, pruner.imageFilters[i].Get("label"))
+		assert.True(t, pruner.imageFilters[i].ExactMatch("dangling", "true"))
+	}
+
+	assert.Equal(t, []string{
+		"container-" + ManagedLabel,
+		"container-com.docker.compose.project=myproject",
+	}, report.ContainersDeleted)
+	assert.Equal(t, []string{
+		"image-" + ManagedLabel,
+		"image-com.docker.compose.project=myproject",
+	}, report.ImagesDeleted)
+	assert.Equal(t, uint64(220), report.SpaceReclaimed)
+
+	// Inertia-managed assets should be pruned by default
+	pruner = &fakePruner{}
+	_, err = Prune(pruner)
+	assert.Nil(t, err)
+	assert.Len(t, pruner.containerFilters, 1)
+	assert.Equal(t, []string{ManagedLabel}, pruner.containerFilters[0].Get("label"))
+	assert.Equal(t, []string{ManagedLabel}, pruner.imageFilters[0].Get("label"))
+}
+
 func TestPruneAll(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
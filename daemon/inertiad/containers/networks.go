@@ -0,0 +1,121 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	docker "github.com/docker/docker/client"
+)
+
+// NetworkLabel marks networks that were created and are managed by Inertia
+const NetworkLabel = "inertia.network"
+
+// EnsureNetworks creates each of the given networks if it does not exist yet,
+// so that they can be referenced as external networks by deployed stacks
+func EnsureNetworks(cli *docker.Client, names []string, out io.Writer) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var ctx = context.Background()
+	existing, err := cli.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return err
+	}
+	var found = make(map[string]bool, len(existing))
+	for _, n := range existing {
+		found[n.Name] = true
+	}
+
+	for _, name := range names {
+		if found[name] {
+			continue
+		}
+		fmt.Fprintf(out, "Creating network %s...\n", name)
+		if _, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{
+			CheckDuplicate: true,
+			Driver:         "bridge",
+			Labels:         map[string]string{NetworkLabel: "true"},
+		}); err != nil {
+			return fmt.Errorf("failed to create network %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// ConnectNetworks attaches the given containers to each of the given
+// networks, skipping containers that are already attached
+func ConnectNetworks(cli *docker.Client, names []string, containers []types.Container) error {
+	var ctx = context.Background()
+	for _, name := range names {
+		for _, c := range containers {
+			if c.NetworkSettings != nil {
+				if _, attached := c.NetworkSettings.Networks[name]; attached {
+					continue
+				}
+			}
+			if err := cli.NetworkConnect(ctx, name, c.ID, nil); err != nil {
+				return fmt.Errorf("failed to connect %s to network %s: %s",
+					c.Names[0], name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// NetworkMembers returns the names of the containers attached to each of the
+// given networks, keyed by network name
+func NetworkMembers(cli *docker.Client, names []string) (map[string][]string, error) {
+	var (
+		ctx     = context.Background()
+		members = make(map[string][]string, len(names))
+	)
+	for _, name := range names {
+		network, err := cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var attached = make([]string, 0, len(network.Containers))
+		for _, endpoint := range network.Containers {
+			attached = append(attached, "/"+strings.TrimPrefix(endpoint.Name, "/"))
+		}
+		sort.Strings(attached)
+		members[name] = attached
+	}
+	return members, nil
+}
+
+// RemoveUnusedNetworks removes networks created by Inertia that no
+// containers are attached to, and returns the names of removed networks
+func RemoveUnusedNetworks(cli *docker.Client) ([]string, error) {
+	var ctx = context.Background()
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(
+			filters.KeyValuePair{Key: "label", Value: NetworkLabel}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed = make([]string, 0)
+	for _, n := range networks {
+		// Listed networks do not include attached containers, so inspect
+		// each one to check if it is still in use
+		network, err := cli.NetworkInspect(ctx, n.ID, types.NetworkInspectOptions{})
+		if err != nil {
+			return removed, err
+		}
+		if len(network.Containers) > 0 {
+			continue
+		}
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, n.Name)
+	}
+	return removed, nil
+}
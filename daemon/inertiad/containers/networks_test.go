@@ -0,0 +1,36 @@
+package containers
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureNetworks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cli, err := NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// networks should be created only once
+	var names = []string{"inertia-test-network"}
+	assert.Nil(t, EnsureNetworks(cli, names, ioutil.Discard))
+	assert.Nil(t, EnsureNetworks(cli, names, ioutil.Discard))
+
+	members, err := NetworkMembers(cli, names)
+	assert.Nil(t, err)
+	assert.Empty(t, members["inertia-test-network"])
+
+	// unused networks should be cleaned up
+	removed, err := RemoveUnusedNetworks(cli)
+	assert.Nil(t, err)
+	assert.Contains(t, removed, "inertia-test-network")
+	_, err = cli.NetworkInspect(context.Background(), "inertia-test-network", types.NetworkInspectOptions{})
+	assert.NotNil(t, err)
+}
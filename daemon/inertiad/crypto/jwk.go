@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// JWK is a JSON Web Key describing an RSA public key that tokens are signed
+// for, as served in a JSON Web Key Set (JWKS)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewRS256JWK describes the given public key as a JWK for validating RS256
+// tokens
+func NewRS256JWK(key *rsa.PublicKey) JWK {
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyID:     KeyID(key),
+		Modulus:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// KeyID returns the RFC 7638 thumbprint of the given public key, which is used
+// to identify the key that tokens were signed with
+func KeyID(key *rsa.PublicKey) string {
+	// Members must be in lexicographic order, without whitespace
+	bytes, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+	var sum = sha256.Sum256(bytes)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ReadRSAPrivateKey reads a PEM-encoded RSA private key from the given path,
+// for signing tokens with RS256
+func ReadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM(bytes)
+}
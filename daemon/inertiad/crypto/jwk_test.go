@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRSAPrivateKey(t *testing.T) {
+	key, err := ReadRSAPrivateKey(TestInertiaKeyPath)
+	assert.Nil(t, err)
+	assert.NotNil(t, key)
+
+	_, err = ReadRSAPrivateKey("/not/a/key")
+	assert.NotNil(t, err)
+}
+
+func TestNewRS256JWK(t *testing.T) {
+	key, err := ReadRSAPrivateKey(TestInertiaKeyPath)
+	assert.Nil(t, err)
+
+	var jwk = NewRS256JWK(&key.PublicKey)
+	assert.Equal(t, "RSA", jwk.KeyType)
+	assert.Equal(t, "RS256", jwk.Algorithm)
+	assert.Equal(t, KeyID(&key.PublicKey), jwk.KeyID)
+	n, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+	assert.Nil(t, err)
+	assert.Equal(t, key.N.Bytes(), n)
+
+	// 65537 is encoded as "AQAB"
+	assert.Equal(t, "AQAB", jwk.Exponent)
+}
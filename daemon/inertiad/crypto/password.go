@@ -1,7 +1,13 @@
 package crypto
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
 	"strings"
 
 	"golang.org/x/crypto/bcrypt"
@@ -23,13 +29,59 @@ func IsCredentialFormatError(err error) bool {
 
 // HashPassword generates a bcrypt-encrypted hash from given password
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return HashPasswordWithCost(password, bcrypt.DefaultCost)
+}
+
+// HashPasswordWithCost generates a bcrypt-encrypted hash from given password
+// using the given cost. Costs outside of the range bcrypt allows fall back to
+// bcrypt's default cost.
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", errors.New("bcrypt password hashing unsuccessful: " + err.Error())
 	}
 	return string(hash), nil
 }
 
+// PepperPassword combines the given password with the given server-side
+// secret, so that hashes of the result are useless without the secret. The
+// result is short enough to be hashed with bcrypt.
+func PepperPassword(password string, pepper []byte) string {
+	var mac = hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ReadPeppers reads the password peppers stored in the given directory as
+// 'pepper.<version>.key', for versions up to the given current version. The
+// current pepper is generated if it does not exist yet - earlier peppers that
+// do not exist are left out.
+func ReadPeppers(dir string, current int) (map[int][]byte, error) {
+	var peppers = make(map[int][]byte)
+	if current <= 0 {
+		return peppers, nil
+	}
+	for version := 1; version < current; version++ {
+		pepper, err := ioutil.ReadFile(pepperPath(dir, version))
+		if err == nil && len(pepper) == SymmetricKeyLength {
+			peppers[version] = pepper
+		}
+	}
+	pepper, err := ReadSymmetricKey(pepperPath(dir, current))
+	if err != nil {
+		return nil, err
+	}
+	peppers[current] = pepper
+	return peppers, nil
+}
+
+func pepperPath(dir string, version int) string {
+	return path.Join(dir, fmt.Sprintf("pepper.%d.key", version))
+}
+
 // CorrectPassword checks if given password maps correctly to the given hash
 func CorrectPassword(hash string, password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -72,3 +74,36 @@ func TestValidateCredentialValues(t *testing.T) {
 	err = ValidateCredentialValues("wowwow", "oasdfasdfh!!!!")
 	assert.Equal(t, errInvalidPassword, err)
 }
+
+func TestPepperPassword(t *testing.T) {
+	var peppered = PepperPassword("wowgreat", []byte("pepper"))
+	assert.Equal(t, peppered, PepperPassword("wowgreat", []byte("pepper")))
+	assert.NotEqual(t, peppered, PepperPassword("wowgreat", []byte("other_pepper")))
+	assert.NotEqual(t, peppered, PepperPassword("wowgreaT", []byte("pepper")))
+	assert.True(t, len(peppered) <= 72)
+}
+
+func TestReadPeppers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peppers")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// no peppers without a version
+	peppers, err := ReadPeppers(dir, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, peppers)
+
+	// the current pepper is generated, and kept
+	peppers, err = ReadPeppers(dir, 1)
+	assert.Nil(t, err)
+	assert.Len(t, peppers, 1)
+	assert.Len(t, peppers[1], SymmetricKeyLength)
+	var first = peppers[1]
+
+	// earlier peppers are read when rotating
+	peppers, err = ReadPeppers(dir, 3)
+	assert.Nil(t, err)
+	assert.Len(t, peppers, 2)
+	assert.Equal(t, first, peppers[1])
+	assert.NotEqual(t, first, peppers[3])
+}
@@ -4,6 +4,9 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
 )
 
 const (
@@ -18,6 +21,23 @@ func getRandomNonce(size int) []byte {
 	return nonce
 }
 
+// ReadSymmetricKey reads the symmetric key stored at the given path. If there is
+// no valid key there, a new one is generated and written to the path.
+func ReadSymmetricKey(path string) ([]byte, error) {
+	if key, err := ioutil.ReadFile(path); err == nil && len(key) == SymmetricKeyLength {
+		return key, nil
+	}
+	var key = make([]byte, SymmetricKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %s", err.Error())
+	}
+	os.Remove(path)
+	if err := ioutil.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key to '%s': %s", path, err.Error())
+	}
+	return key, nil
+}
+
 // Encrypt encrypts plaintext using given key in AES GCM mode
 func Encrypt(key, plaintext []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
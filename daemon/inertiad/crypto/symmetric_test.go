@@ -2,8 +2,12 @@ package crypto
 
 import (
 	"crypto/rand"
-	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestEncryptDecrypt(t *testing.T) {
@@ -30,3 +34,27 @@ func TestEncryptDecrypt(t *testing.T) {
 	_, err = Decrypt(keyBad, ciphertext)
 	assert.NotNil(t, err)
 }
+
+func TestReadSymmetricKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-key")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "test.key")
+
+	// key is generated if missing
+	key, err := ReadSymmetricKey(path)
+	assert.Nil(t, err)
+	assert.Len(t, key, SymmetricKeyLength)
+
+	// existing key is reused
+	again, err := ReadSymmetricKey(path)
+	assert.Nil(t, err)
+	assert.Equal(t, key, again)
+
+	// invalid key is replaced
+	assert.Nil(t, ioutil.WriteFile(path, []byte("short"), 0600))
+	replaced, err := ReadSymmetricKey(path)
+	assert.Nil(t, err)
+	assert.Len(t, replaced, SymmetricKeyLength)
+	assert.NotEqual(t, key, replaced)
+}
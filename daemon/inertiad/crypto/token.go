@@ -1,6 +1,9 @@
 package crypto
 
 import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -13,28 +16,110 @@ const (
 
 	// TokenExpiredErrorMsg says that the token is expired
 	TokenExpiredErrorMsg = "token expired"
+
+	// TokenAlgorithmErrorMsg says that the token's signing algorithm does not
+	// match the key used to validate it
+	TokenAlgorithmErrorMsg = "token signing algorithm does not match key"
 )
 
+// ErrTokenExpired is returned when validating a token past its expiry
+var ErrTokenExpired = errors.New(TokenExpiredErrorMsg)
+
 // TokenClaims represents a JWT token's claims
 type TokenClaims struct {
 	SessionID string    `json:"session_id"`
 	User      string    `json:"user"`
 	Admin     bool      `json:"admin"`
 	Expiry    time.Time `json:"expiry"`
+
+	// Version is the token version of the user the token was issued to - if
+	// it no longer matches the user's token version, the token has been revoked
+	Version int `json:"version,omitempty"`
+
+	// Role is the role of the user the token was issued to. Tokens issued
+	// before roles were introduced do not have one.
+	Role string `json:"role,omitempty"`
+
+	// ReadOnly tokens can only be used for read-only operations, and do not
+	// expire - they must be revoked instead
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// Remember is set for session tokens issued to users that asked to be
+	// remembered, which expire after a longer duration
+	Remember bool `json:"remember,omitempty"`
+
+	// Scopes restricts the token to routes that require one of the given
+	// scopes - tokens without scopes are unrestricted
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Claims are custom claims for authorization policies to use, such as
+	// the groups the token holder is in
+	Claims map[string]interface{} `json:"claims,omitempty"`
+
+	// IssuedAt is when session tokens were issued, in seconds since the epoch
+	IssuedAt int64 `json:"iat,omitempty"`
+
+	// Address is the client address that session tokens were issued to
+	Address string `json:"address,omitempty"`
 }
 
 // Valid checks if token is authentic
 func (t *TokenClaims) Valid() error {
-	if t.IsMaster() {
+	return t.ValidAt(time.Now())
+}
+
+// ValidAt checks if token is authentic at the given time
+func (t *TokenClaims) ValidAt(now time.Time) error {
+	if t.IsMaster() || t.ReadOnly {
 		return nil
 	}
 
-	if !t.Expiry.After(time.Now()) {
-		return errors.New(TokenExpiredErrorMsg)
+	if !t.Expiry.After(now) {
+		return ErrTokenExpired
 	}
 	return nil
 }
 
+// IsTokenExpired returns true if the given error indicates that a token failed
+// validation because it has expired
+func IsTokenExpired(err error) bool {
+	if vErr, ok := err.(*jwt.ValidationError); ok {
+		return vErr.Inner == ErrTokenExpired
+	}
+	return err == ErrTokenExpired
+}
+
+// IsTokenMalformed returns true if the given error indicates that a token
+// failed validation because it could not be parsed
+func IsTokenMalformed(err error) bool {
+	if vErr, ok := err.(*jwt.ValidationError); ok {
+		return vErr.Errors&jwt.ValidationErrorMalformed != 0
+	}
+	return false
+}
+
+// IsTokenSignatureInvalid returns true if the given error indicates that a
+// token failed validation because it was not signed with the expected key
+func IsTokenSignatureInvalid(err error) bool {
+	if vErr, ok := err.(*jwt.ValidationError); ok {
+		return vErr.Errors&jwt.ValidationErrorSignatureInvalid != 0
+	}
+	return false
+}
+
+// HasScope returns true if the token is unrestricted, or has the given scope
+func (t *TokenClaims) HasScope(scope string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // IsMaster returns true if this is a mster key
 func (t *TokenClaims) IsMaster() bool {
 	return (t.User == "master" && t.Expiry == time.Time{})
@@ -47,24 +132,83 @@ func (t *TokenClaims) GenerateToken(key []byte) (string, error) {
 		SignedString(key)
 }
 
-// ValidateToken ensures token is valid and returns its metadata
+// GenerateRS256Token creates a JWT token from this claim, signed with RS256
+// using the given private key, and identifies the key with the given key ID so
+// that the public key can be found in a JWKS
+func (t *TokenClaims) GenerateRS256Token(key *rsa.PrivateKey, keyID string) (string, error) {
+	var token = jwt.NewWithClaims(jwt.SigningMethodRS256, t)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	return token.SignedString(key)
+}
+
+// ValidateToken ensures token is valid and returns its metadata. The given
+// lookup decides the key to validate the token with - HMAC tokens must be
+// validated with a []byte, and RS256 tokens with an *rsa.PublicKey. Tokens
+// with any other algorithm, including "none", or with an algorithm that does
+// not match the key, are rejected.
 func ValidateToken(tokenString string, lookup jwt.Keyfunc) (*TokenClaims, error) {
+	return ValidateTokenAt(tokenString, lookup, time.Now())
+}
+
+// ValidateTokenAt ensures token is valid at the given time, like ValidateToken,
+// and returns its metadata
+func ValidateTokenAt(tokenString string, lookup jwt.Keyfunc, now time.Time) (*TokenClaims, error) {
 	// Parse takes the token string and a function for looking up the key.
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, lookup)
+	// Claims are validated below instead, at the given time.
+	var parser = &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(tokenString, &TokenClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			key, err := lookup(token)
+			if err != nil {
+				return nil, err
+			}
+			if !matchesKey(token.Method, key) {
+				return nil, errors.New(TokenAlgorithmErrorMsg)
+			}
+			return key, nil
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	// Verify signing algorithm and token
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || !token.Valid {
+	// Verify token
+	if !token.Valid {
 		return nil, errors.New(TokenInvalidErrorMsg)
 	}
 
 	// Verify the claims and token.
-	if claim, ok := token.Claims.(*TokenClaims); ok {
-		return claim, nil
+	claim, ok := token.Claims.(*TokenClaims)
+	if !ok {
+		return nil, errors.New(TokenInvalidErrorMsg)
+	}
+	if err := claim.ValidAt(now); err != nil {
+		return nil, &jwt.ValidationError{Inner: err, Errors: jwt.ValidationErrorClaimsInvalid}
+	}
+	return claim, nil
+}
+
+// matchesKey returns true if the given signing method is the one the given key
+// is meant for, so that keys cannot be used with algorithms they were not
+// meant for
+func matchesKey(method jwt.SigningMethod, key interface{}) bool {
+	switch key.(type) {
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	case *rsa.PublicKey:
+		return method == jwt.SigningMethodRS256
+	default:
+		return false
 	}
-	return nil, errors.New(TokenInvalidErrorMsg)
+}
+
+// HashToken returns a hex-encoded SHA-256 hash of the given token, which can be
+// stored in place of the token itself
+func HashToken(token string) string {
+	var sum = sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // GenerateMasterToken creates a "master" JSON Web Token (JWT) for a client to use
@@ -79,3 +223,18 @@ func GenerateMasterToken(key []byte) (string, error) {
 		}).
 		SignedString(key)
 }
+
+// GenerateScopedToken creates a master token that can only be used on routes
+// requiring one of the given scopes
+func GenerateScopedToken(key []byte, scopes ...string) (string, error) {
+	if len(scopes) == 0 {
+		return GenerateMasterToken(key)
+	}
+	return jwt.
+		NewWithClaims(jwt.SigningMethodHS256, &TokenClaims{
+			User:   "master",
+			Admin:  true,
+			Scopes: scopes,
+		}).
+		SignedString(key)
+}
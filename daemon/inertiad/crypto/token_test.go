@@ -1,9 +1,15 @@
 package crypto
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
 	"testing"
 	"time"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,6 +34,7 @@ func TestTokenClaims_Valid(t *testing.T) {
 		User      string
 		Admin     bool
 		Expiry    time.Time
+		ReadOnly  bool
 	}
 	tests := []struct {
 		name    string
@@ -35,11 +42,13 @@ func TestTokenClaims_Valid(t *testing.T) {
 		wantErr bool
 	}{
 		// master key
-		{"success", fields{"1234", "master", true, time.Time{}}, false},
+		{"success", fields{"1234", "master", true, time.Time{}, false}, false},
 		// expiry in future (+1)
-		{"success", fields{"1234", "bob", true, time.Now().AddDate(0, 1, 0)}, false},
+		{"success", fields{"1234", "bob", true, time.Now().AddDate(0, 1, 0), false}, false},
 		// expiry in past (-1)
-		{"fail", fields{"1234", "bob", true, time.Now().AddDate(0, -1, 0)}, true},
+		{"fail", fields{"1234", "bob", true, time.Now().AddDate(0, -1, 0), false}, true},
+		// read-only tokens do not expire
+		{"success", fields{"1234", "dashboard", false, time.Time{}, true}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -48,6 +57,7 @@ func TestTokenClaims_Valid(t *testing.T) {
 				User:      tt.fields.User,
 				Admin:     tt.fields.Admin,
 				Expiry:    tt.fields.Expiry,
+				ReadOnly:  tt.fields.ReadOnly,
 			}
 			if err := claims.Valid(); (err != nil) != tt.wantErr {
 				t.Errorf("TokenClaims.Valid() error = %v, wantErr %v", err, tt.wantErr)
@@ -58,7 +68,7 @@ func TestTokenClaims_Valid(t *testing.T) {
 
 func TestTokenClaims_GenerateToken(t *testing.T) {
 	expires := time.Now().AddDate(0, 1, 0)
-	claims := &TokenClaims{"1234", "robert", true, expires}
+	claims := &TokenClaims{SessionID: "1234", User: "robert", Admin: true, Expiry: expires}
 	token, err := claims.GenerateToken(TestPrivateKey)
 	assert.Nil(t, err)
 
@@ -67,3 +77,125 @@ func TestTokenClaims_GenerateToken(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, claims.User, readClaims.User)
 }
+
+func TestGenerateScopedToken(t *testing.T) {
+	// no scopes is the same as a master token
+	token, err := GenerateScopedToken(TestPrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, TestMasterToken, token)
+
+	token, err = GenerateScopedToken(TestPrivateKey, "deploy", "logs")
+	assert.Nil(t, err)
+	readClaims, err := ValidateToken(token, GetFakeAPIKey)
+	assert.Nil(t, err)
+	assert.True(t, readClaims.IsMaster())
+	assert.Equal(t, []string{"deploy", "logs"}, readClaims.Scopes)
+}
+
+func TestTokenClaims_HasScope(t *testing.T) {
+	var unscoped = &TokenClaims{}
+	assert.True(t, unscoped.HasScope("deploy"))
+
+	var scoped = &TokenClaims{Scopes: []string{"deploy"}}
+	assert.True(t, scoped.HasScope("deploy"))
+	assert.False(t, scoped.HasScope("users"))
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	expired, err := (&TokenClaims{
+		SessionID: "1234", User: "bob", Expiry: time.Now().Add(-time.Minute),
+	}).GenerateToken(TestPrivateKey)
+	assert.Nil(t, err)
+	_, err = ValidateToken(expired, GetFakeAPIKey)
+	assert.True(t, IsTokenExpired(err))
+
+	_, err = ValidateToken("not_a_token", GetFakeAPIKey)
+	assert.NotNil(t, err)
+	assert.False(t, IsTokenExpired(err))
+}
+
+func TestValidateTokenAt(t *testing.T) {
+	var now = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	token, err := (&TokenClaims{
+		SessionID: "1234", User: "bob", Expiry: now.Add(time.Hour),
+	}).GenerateToken(TestPrivateKey)
+	assert.Nil(t, err)
+
+	claims, err := ValidateTokenAt(token, GetFakeAPIKey, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", claims.User)
+
+	_, err = ValidateTokenAt(token, GetFakeAPIKey, now.Add(time.Hour))
+	assert.True(t, IsTokenExpired(err))
+}
+
+func TestIsTokenMalformedOrSignatureInvalid(t *testing.T) {
+	_, err := ValidateToken("not_a_token", GetFakeAPIKey)
+	assert.True(t, IsTokenMalformed(err))
+	assert.False(t, IsTokenSignatureInvalid(err))
+
+	token, err := (&TokenClaims{
+		SessionID: "1234", User: "bob", Expiry: time.Now().Add(time.Minute),
+	}).GenerateToken([]byte("another_key"))
+	assert.Nil(t, err)
+	_, err = ValidateToken(token, GetFakeAPIKey)
+	assert.False(t, IsTokenMalformed(err))
+	assert.True(t, IsTokenSignatureInvalid(err))
+	assert.False(t, IsTokenExpired(err))
+}
+
+func TestTokenClaims_GenerateRS256Token(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	var publicKey = func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil }
+
+	claims := &TokenClaims{SessionID: "1234", User: "robert", Expiry: time.Now().Add(time.Minute)}
+	token, err := claims.GenerateRS256Token(key, KeyID(&key.PublicKey))
+	assert.Nil(t, err)
+
+	// Try decoding token with the public key
+	readClaims, err := ValidateToken(token, publicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, claims.User, readClaims.User)
+
+	// RS256 tokens cannot be validated with HMAC keys
+	_, err = ValidateToken(token, GetFakeAPIKey)
+	assert.NotNil(t, err)
+}
+
+func TestValidateToken_tamperedAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	var publicKey = func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil }
+	var claims = &TokenClaims{SessionID: "1234", User: "robert", Admin: true,
+		Expiry: time.Now().Add(time.Minute)}
+
+	// HMAC tokens signed with the public key must not validate against it
+	publicPEM, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicPEM)
+	assert.Nil(t, err)
+	_, err = ValidateToken(forged, publicKey)
+	assert.NotNil(t, err)
+	_, err = ValidateToken(forged, func(*jwt.Token) (interface{}, error) { return publicPEM, nil })
+	assert.Nil(t, err, "sanity check: forged token is otherwise well-formed")
+
+	// Unsigned tokens are always rejected
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.Nil(t, err)
+	_, err = ValidateToken(unsigned, publicKey)
+	assert.NotNil(t, err)
+	_, err = ValidateToken(unsigned, func(*jwt.Token) (interface{}, error) {
+		return jwt.UnsafeAllowNoneSignatureType, nil
+	})
+	assert.NotNil(t, err)
+
+	// Tokens whose alg header was swapped out after signing are rejected
+	token, err := claims.GenerateRS256Token(key, "")
+	assert.Nil(t, err)
+	var parts = strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	_, err = ValidateToken(strings.Join(parts, "."), publicKey)
+	assert.NotNil(t, err)
+}
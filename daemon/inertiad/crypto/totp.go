@@ -2,7 +2,9 @@ package crypto
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"time"
 
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
@@ -35,6 +37,29 @@ func ValidatePasscode(passcode string, secret string) bool {
 	return totp.Validate(passcode, secret)
 }
 
+// ValidatePasscodeStep validates a one-time passcode against the given secret
+// key at time t, allowing for one period of clock skew in either direction. It
+// also returns the time step the passcode belongs to, so that callers can
+// reject passcodes that have already been used.
+func ValidatePasscodeStep(passcode, secret string, t time.Time) (int64, bool) {
+	var opts = totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    totpDigits,
+		Algorithm: totpAlgorithm,
+	}
+	for skew := -1; skew <= 1; skew++ {
+		var at = t.Add(time.Duration(skew*totpPeriod) * time.Second)
+		code, err := totp.GenerateCodeCustom(secret, at, opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(code), []byte(passcode)) == 1 {
+			return at.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
 // GenerateBackupCodes generates an array of backup code strings in
 // Github format.
 //
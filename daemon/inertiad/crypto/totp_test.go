@@ -59,6 +59,40 @@ func TestVerification(t *testing.T) {
 	}
 }
 
+func TestValidatePasscodeStep(t *testing.T) {
+	key, err := GenerateSecretKey("TestAccountName")
+	assert.Nil(t, err)
+	var (
+		now    = time.Unix(1538395200, 0)
+		period = time.Duration(totpPeriod) * time.Second
+		step   = now.Unix() / totpPeriod
+	)
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current period", now, step, true},
+		{"previous period", now.Add(-period), step - 1, true},
+		{"next period", now.Add(period), step + 1, true},
+		{"outside skew", now.Add(-2 * period), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := totp.GenerateCode(key.Secret(), tt.at)
+			assert.Nil(t, err)
+			gotStep, ok := ValidatePasscodeStep(code, key.Secret(), now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantStep, gotStep)
+		})
+	}
+
+	_, ok := ValidatePasscodeStep("123", key.Secret(), now)
+	assert.False(t, ok)
+}
+
 func TestBackupCodes(t *testing.T) {
 	codes := GenerateBackupCodes()
 	assert.Equal(t, len(codes), totpNoBackupCodes)
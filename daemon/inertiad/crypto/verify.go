@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -12,7 +13,8 @@ import (
 
 const (
 	// Prefixes used by GitHub before the HMAC hexdigest.
-	sha1Prefix = "sha1"
+	sha1Prefix   = "sha1"
+	sha256Prefix = "sha256"
 )
 
 // ValidateSignature validates the HMAC signature for the given payload.
@@ -55,6 +57,8 @@ func messageMAC(signature string) ([]byte, func() hash.Hash, error) {
 	switch signaturePrefix {
 	case sha1Prefix:
 		hashFunc = sha1.New
+	case sha256Prefix:
+		hashFunc = sha256.New
 	default:
 		return nil, nil, fmt.Errorf("unknown hash type prefix: %q", signaturePrefix)
 	}
@@ -3,9 +3,10 @@ package crypto
 import "testing"
 
 var (
-	testSignature = "sha1=126f2c800419c60137ce748d7672e77b65cf16d6"
-	testPayload   = []byte(`{"yo":true}`)
-	testKey       = []byte("0123456789abcdef")
+	testSignature       = "sha1=126f2c800419c60137ce748d7672e77b65cf16d6"
+	testSignatureSHA256 = "sha256=b1f8020f5b4cd42042f807dd939015c4a418bc1ff7f604dd55b0a19b5d953d9b"
+	testPayload         = []byte(`{"yo":true}`)
+	testKey             = []byte("0123456789abcdef")
 )
 
 func TestValidateSignature(t *testing.T) {
@@ -20,6 +21,8 @@ func TestValidateSignature(t *testing.T) {
 		wantErr bool
 	}{
 		{"ok", args{testSignature, testPayload, testKey}, false},
+		{"ok sha256", args{testSignatureSHA256, testPayload, testKey}, false},
+		{"unknown prefix", args{"md5=126f2c", testPayload, testKey}, true},
 		{"missing sig", args{"", testPayload, testKey}, true},
 		{"incorrect sig", args{testSignature, testPayload, []byte("ohno")}, true},
 	}
@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// composeConfigHandler returns the fully resolved docker-compose configuration
+// of the project at the requested git revision, or of the currently checked out
+// project if none is given. Values of encrypted environment variables are
+// always masked, since they may be interpolated into the configuration.
+func (s *Server) composeConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if s.deployment.GetProject() == "" {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+
+	var (
+		ref = r.URL.Query().Get(api.ComposeRef)
+		buf = new(bytes.Buffer)
+	)
+	if err := s.deployment.ComposeConfig(s.docker, ref, buf); err != nil {
+		if err == project.ErrNotComposeProject {
+			render.Render(w, r, res.Err(err.Error(), http.StatusPreconditionFailed))
+			return
+		}
+		render.Render(w, r, res.ErrInternalServer("failed to resolve compose configuration", err,
+			"ref", ref))
+		return
+	}
+
+	masker, err := s.getLogMasker()
+	if err != nil {
+		render.Render(w, r, res.ErrInternalServer("failed to retrieve secrets for masking", err))
+		return
+	}
+	render.Render(w, r, res.MsgOK("compose configuration resolved",
+		"ref", ref,
+		"config", masker.Mask(buf.String())))
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestComposeConfigHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	manager, err := project.NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	assert.Nil(t, manager.AddEnvVariable("DB_PASSWORD", "hunter2", true))
+
+	tests := []struct {
+		name      string
+		project   string
+		configErr error
+		wantCode  int
+	}{
+		{"no project", "", nil, http.StatusPreconditionFailed},
+		{"not compose", "wow", project.ErrNotComposeProject, http.StatusPreconditionFailed},
+		{"config failed", "wow", errors.New("invalid compose file"), http.StatusInternalServerError},
+		{"ok", "wow", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fakeDeployer = &mocks.FakeDeployer{}
+			fakeDeployer.GetProjectReturns(tt.project)
+			fakeDeployer.GetDataManagerReturns(manager, true)
+			fakeDeployer.ComposeConfigStub = func(cli *docker.Client, ref string, out io.Writer) error {
+				assert.Equal(t, "dev", ref)
+				out.Write([]byte("services:\n  web:\n    environment:\n      DB_PASSWORD: hunter2\n"))
+				return tt.configErr
+			}
+			var s = &Server{deployment: fakeDeployer}
+
+			req, err := http.NewRequest("GET", "/compose/config?"+api.ComposeRef+"=dev", nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.composeConfigHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantCode == http.StatusOK {
+				var config string
+				_, err := api.Unmarshal(recorder.Body, api.KV{Key: "config", Value: &config})
+				assert.Nil(t, err)
+				assert.Contains(t, config, "DB_PASSWORD: ***")
+				assert.NotContains(t, config, "hunter2")
+			}
+		})
+	}
+}
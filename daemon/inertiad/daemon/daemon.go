@@ -2,17 +2,23 @@ package daemon
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	docker "github.com/docker/docker/client"
 	"github.com/gorilla/websocket"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/auth"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/notify"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
 )
 
@@ -22,6 +28,33 @@ type Server struct {
 
 	deployment project.Deployer
 	state      cfg.Config
+	status     statusCache
+	metrics    *serverMetrics
+	notifier   *notify.Dispatcher
+
+	webhookDeploys *deployQueue
+
+	// deliveries remembers recent webhook deliveries, so that repeated
+	// deliveries are ignored
+	deliveries *deliveryCache
+	// signedDeploys remembers the signatures of recent signed deploy requests,
+	// so that captured requests cannot be replayed
+	signedDeploys *deliveryCache
+
+	// progress tracks the most recent deployment through the deploy pipeline
+	progress *deployProgress
+	// deployLogs broadcasts build and deployment output to streaming clients
+	deployLogs *log.Broadcaster
+
+	// pushMux guards allowedRefs and warmStandby, which are written by up
+	// requests while webhook and signed deploy requests read them
+	pushMux sync.RWMutex
+	// allowedRefs restricts which refs may be deployed, and is configured by
+	// the most recent up request
+	allowedRefs *git.RefFilter
+	// warmStandby makes webhook pushes pre-warm builds instead of deploying,
+	// and is configured by the most recent up request
+	warmStandby bool
 
 	docker    *docker.Client
 	websocket *websocket.Upgrader
@@ -36,19 +69,30 @@ func New(version string, state cfg.Config, deployment project.Deployer) (*Server
 	}
 
 	// Download build tools
-	go downloadDeps(cli, state.DockerComposeVersion)
+	go downloadDeps(cli, state.DockerComposeVersion, state.DockerCLIVersion)
 
-	return &Server{
+	var s = &Server{
 		version: version,
 
-		deployment: deployment,
-		state:      state,
+		deployment:    deployment,
+		state:         state,
+		status:        statusCache{interval: state.StatusCacheInterval},
+		metrics:       newServerMetrics(),
+		notifier:      notify.NewDispatcher(os.Stdout),
+		deliveries:    newDeliveryCache(state.WebhookDedupWindow, maxDedupDeliveries),
+		signedDeploys: newDeliveryCache(2*signedRequestMaxAge, maxDedupDeliveries),
+		progress:      newDeployProgress(),
+		deployLogs:    log.NewBroadcaster(),
 
 		docker: cli,
 		websocket: &websocket.Upgrader{
 			HandshakeTimeout: 5 * time.Second,
 		},
-	}, nil
+	}
+	s.webhookDeploys = newDeployQueue(s.deployPush)
+	s.deployment.OnFlap(s.observeFlap)
+	s.metrics.watchContainers(s.activeContainers)
+	return s, nil
 }
 
 // Run starts the server
@@ -88,54 +132,225 @@ func (s *Server) Run(host, port string) error {
 					return
 				}
 			case event := <-logsCh:
+				s.status.invalidate()
 				println(event)
 			}
 		}
 	}()
 
+	// Compress older deployment history in the background
+	go s.compressHistory()
+
+	// Reload configuration on SIGHUP
+	go s.watchReload()
+
 	// Set up endpoints
 	var (
 		webPrefix        = "/web/"
+		staticPrefix     = "/static/"
 		userDatabasePath = path.Join(s.state.DataDirectory, "users.db")
+		totpKeyPath      = path.Join(s.state.SecretsDirectory, "totp.key")
 	)
-	handler, err := auth.NewPermissionsHandler(
-		userDatabasePath, host, 120)
+	var audit io.Writer
+	if s.state.AuditLog != "" {
+		auditLog, err := os.OpenFile(s.state.AuditLog,
+			os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %s", err.Error())
+		}
+		defer auditLog.Close()
+		audit = auditLog
+	}
+	var cookies = auth.DefaultCookieConfig()
+	cookies.Secure = !s.state.CookieInsecure
+	cookies.Domain = s.state.CookieDomain
+	if s.state.CookiePath != "" {
+		cookies.Path = s.state.CookiePath
+	}
+	if s.state.CookieSameSite != "" {
+		if sameSite, ok := auth.ParseSameSite(s.state.CookieSameSite); ok {
+			cookies.SameSite = sameSite
+		} else {
+			fmt.Printf("invalid INERTIA_COOKIE_SAMESITE '%s' - using 'lax'\n",
+				s.state.CookieSameSite)
+		}
+	}
+	var keys auth.KeyLookup
+	if s.state.TokenSigningKey != "" {
+		signingKey, err := crypto.ReadRSAPrivateKey(s.state.TokenSigningKey)
+		if err != nil {
+			return fmt.Errorf("failed to read token signing key: %s", err.Error())
+		}
+		var lookup = auth.NewRSAKeyLookup(signingKey, nil)
+		if s.state.PreviousTokenSigningKey != "" {
+			previousKey, err := crypto.ReadRSAPrivateKey(s.state.PreviousTokenSigningKey)
+			if err != nil {
+				return fmt.Errorf("failed to read previous token signing key: %s", err.Error())
+			}
+			lookup = auth.NewRSAKeyLookup(previousKey, nil)
+			lookup.Rotate(signingKey, s.state.TokenKeyOverlap)
+		}
+		keys = lookup
+	}
+	handler, err := auth.NewPermissionsHandler(userDatabasePath, host, auth.HandlerOptions{
+		SessionTimeout: s.state.SessionTimeout,
+		HashCost:       s.state.PasswordHashCost,
+		Cookies:        &cookies,
+		Audit:          audit,
+		RateLimit: &auth.RateLimit{
+			RequestsPerSecond: s.state.RateLimit,
+			Burst:             s.state.RateLimitBurst,
+			ExemptPublic:      s.state.RateLimitExemptPublic,
+		},
+		IPFilter: &auth.IPFilter{
+			Allow:          s.state.AllowedCIDRs,
+			Deny:           s.state.DeniedCIDRs,
+			TrustedProxies: s.state.TrustedProxies,
+			ExemptPublic:   s.state.IPFilterExemptPublic,
+		},
+		Realm: s.state.AuthRealm,
+		Keys:  keys,
+	})
 	if err != nil {
 		return err
 	}
 	defer handler.Close()
+	totpKey, err := crypto.ReadSymmetricKey(totpKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read TOTP key: %s", err.Error())
+	}
+	handler.SetTotpKey(totpKey)
+	peppers, err := crypto.ReadPeppers(s.state.SecretsDirectory, s.state.PasswordPepperVersion)
+	if err != nil {
+		return fmt.Errorf("failed to read password peppers: %s", err.Error())
+	}
+	handler.SetPasswordPeppers(auth.Peppers{
+		Current: s.state.PasswordPepperVersion,
+		Keys:    peppers,
+	})
+	handler.SetLoginLockout(s.state.LoginLockoutThreshold, s.state.LoginLockoutCooldown)
+	handler.SetLoginBackoff(s.state.LoginBackoff, s.state.LoginBackoffCap)
+	handler.SetDrainTimeout(s.state.ShutdownTimeout)
+	handler.SetRequestTimeout(s.state.RequestTimeout)
+	handler.SetRememberTimeout(s.state.RememberSessionTimeout)
+	handler.SetResetTokenTimeout(s.state.ResetTokenTimeout)
+	handler.SetTokenQueryParam(s.state.AuthTokenParam)
+	if s.state.LoginToken != "" {
+		if mode, ok := auth.ParseLoginTokenMode(s.state.LoginToken); ok {
+			handler.SetLoginTokenMode(mode)
+		} else {
+			fmt.Printf("invalid INERTIA_LOGIN_TOKEN '%s' - using 'cookie'\n",
+				s.state.LoginToken)
+		}
+	}
+	var https = auth.HTTPSPolicy{
+		HSTSMaxAge:            s.state.HSTSMaxAge,
+		HSTSIncludeSubdomains: s.state.HSTSIncludeSubdomains,
+	}
+	if s.state.Plaintext != "" {
+		if mode, ok := auth.ParsePlaintextMode(s.state.Plaintext); ok {
+			https.Plaintext = mode
+		} else {
+			fmt.Printf("invalid INERTIA_PLAINTEXT '%s' - using 'allow'\n",
+				s.state.Plaintext)
+		}
+	}
+	handler.SetHTTPSPolicy(https)
+	handler.SetPasswordPolicy(auth.PasswordPolicy{
+		MinLength:        s.state.PasswordMinLength,
+		RequireDigit:     s.state.PasswordRequireDigit,
+		RequireSymbol:    s.state.PasswordRequireSymbol,
+		RequireMixedCase: s.state.PasswordRequireMixedCase,
+	})
+	handler.SetUsernamePolicy(auth.UsernamePolicy{
+		Reserved: s.state.ReservedUsernames,
+	})
 	println("Permissions manager successfully created")
 
-	// Inertia web
-	handler.AttachPublicHandler(
-		webPrefix,
-		http.StripPrefix(webPrefix, http.FileServer(http.Dir("/daemon/inertia-web"))))
+	// Inertia web, and its static assets
+	handler.AttachPublicPrefix(webPrefix,
+		http.FileServer(http.Dir("/daemon/inertia-web")),
+		http.MethodGet, http.MethodHead)
+	handler.AttachPublicPrefix(staticPrefix,
+		http.FileServer(http.Dir("/daemon/inertia-web/static")),
+		http.MethodGet, http.MethodHead)
 
 	// GitHub webhook endpoint
 	handler.AttachPublicHandlerFunc("/webhook", s.webhookHandler)
+	handler.AttachPublicHandlerFunc("/projects/{project}/webhook", s.projectWebhookHandler)
+	handler.AttachReadOnlyHandlerFunc("/webhook/queue",
+		s.deployQueueHandler, http.MethodGet)
+	handler.AttachAdminRestrictedHandlerFunc("/webhook/config",
+		s.webhookConfigHandler, http.MethodGet)
+	handler.AttachAdminRestrictedHandlerFunc("/webhook/test",
+		s.webhookTestHandler, http.MethodPost)
+
+	// Signed deploy endpoint for CI
+	handler.AttachPublicHandlerFunc("/deploy",
+		s.signedDeployHandler, http.MethodPost)
 
 	// API endpoints
-	handler.AttachUserRestrictedHandlerFunc("/status",
+	handler.AttachReadOnlyHandlerFunc("/status",
 		s.statusHandler, http.MethodGet)
-	handler.AttachUserRestrictedHandlerFunc("/logs",
+	handler.AttachReadOnlyHandlerFunc("/deploy/status",
+		s.progressHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/deploy/logs",
+		s.deployLogsHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/deployed",
+		s.deployedHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/stats",
+		s.statsHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/metrics",
+		s.metricsHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/metrics/auth",
+		handler.MetricsHandler(s.version), http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/logs",
 		s.logHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/history",
+		s.historyHandler, http.MethodGet)
+	handler.AttachReadOnlyHandlerFunc("/history/diff",
+		s.diffHandler, http.MethodGet)
+	handler.AttachUserRestrictedHandlerFunc("/compose/config",
+		s.composeConfigHandler, http.MethodGet)
+	handler.AttachAdminRestrictedHandlerFunc("/history/export",
+		s.exportHandler, http.MethodGet)
 	handler.AttachAdminRestrictedHandlerFunc("/up",
 		s.upHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/deploy/rollback",
+		s.rollbackHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/promote",
+		s.promoteHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/down",
 		s.downHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/restart",
+		s.restartHandler, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/restart/resume",
+		s.resumeHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/reset",
 		s.resetHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/env",
 		s.envHandler, http.MethodGet, http.MethodPost)
+	handler.AttachAdminRestrictedHandlerFunc("/notifications/failed",
+		s.failedNotificationsHandler, http.MethodGet)
 	handler.AttachAdminRestrictedHandlerFunc("/prune",
 		s.pruneHandler, http.MethodPost)
 	handler.AttachAdminRestrictedHandlerFunc("/token",
 		tokenHandler, http.MethodGet)
 
+	// Scoped tokens can only access endpoints that require one of their scopes
+	handler.RequireScope(api.ScopeDeploy,
+		"/up", "/deploy/rollback", "/promote", "/down", "/restart", "/reset", "/prune",
+		"/status", "/deploy/status", "/deployed", "/webhook/queue")
+	handler.RequireScope(api.ScopeLogs,
+		"/logs", "/deploy/logs", "/stats", "/metrics", "/history")
+
+	// Browsers cannot set headers on websocket upgrades for streaming logs
+	handler.AllowUpgrades("/logs")
+
 	// Root "ok" endpoint
 	handler.AttachPublicHandlerFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-	})
+	}, http.MethodGet, http.MethodHead)
 
 	// Serve daemon on port
 	println("Serving daemon on port " + port)
@@ -143,11 +358,40 @@ func (s *Server) Run(host, port string) error {
 		":"+port,
 		cert,
 		key,
-		handler)
+		s.metrics.instrument(handler))
+}
+
+// getAllowedRefs returns the filter for refs that may be deployed
+func (s *Server) getAllowedRefs() *git.RefFilter {
+	s.pushMux.RLock()
+	defer s.pushMux.RUnlock()
+	return s.allowedRefs
+}
+
+// setAllowedRefs configures the filter for refs that may be deployed
+func (s *Server) setAllowedRefs(refs *git.RefFilter) {
+	s.pushMux.Lock()
+	s.allowedRefs = refs
+	s.pushMux.Unlock()
+}
+
+// isWarmStandby returns true if pushes pre-warm builds instead of deploying
+func (s *Server) isWarmStandby() bool {
+	s.pushMux.RLock()
+	defer s.pushMux.RUnlock()
+	return s.warmStandby
+}
+
+// setWarmStandby configures whether pushes pre-warm builds instead of deploying
+func (s *Server) setWarmStandby(warmStandby bool) {
+	s.pushMux.Lock()
+	s.warmStandby = warmStandby
+	s.pushMux.Unlock()
 }
 
 // Close releases server assets
 func (s *Server) Close() {
+	s.notifier.Close()
 	s.deployment.Down(s.docker, os.Stdout)
 	s.docker.Close()
 }
@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDedupWindow is how long webhook delivery IDs are remembered if no
+	// window is configured
+	defaultDedupWindow = 10 * time.Minute
+
+	// maxDedupDeliveries is the number of webhook delivery IDs remembered at
+	// once - the oldest are forgotten first
+	maxDedupDeliveries = 1000
+)
+
+// deliveryCache remembers recently received webhook delivery IDs, so that
+// deliveries that git hosts repeat do not trigger redundant deployments
+type deliveryCache struct {
+	window time.Duration
+	size   int
+	now    func() time.Time
+
+	mux     sync.Mutex
+	seen    map[string]time.Time
+	entries []delivery
+}
+
+// delivery is a webhook delivery ID, and when it was received
+type delivery struct {
+	id string
+	at time.Time
+}
+
+// newDeliveryCache creates a cache that remembers up to size delivery IDs for
+// the given window. Invalid values fall back to defaults.
+func newDeliveryCache(window time.Duration, size int) *deliveryCache {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	if size <= 0 {
+		size = maxDedupDeliveries
+	}
+	return &deliveryCache{
+		window: window,
+		size:   size,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Seen records the given delivery ID, and returns true if it was already
+// received within the window. Empty IDs are never considered seen.
+func (c *deliveryCache) Seen(id string) bool {
+	if c == nil || id == "" {
+		return false
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var now = c.now()
+	if at, ok := c.seen[id]; ok && now.Sub(at) < c.window {
+		return true
+	}
+	c.seen[id] = now
+	c.entries = append(c.entries, delivery{id: id, at: now})
+
+	// Forget expired deliveries, and the oldest deliveries past the size
+	// limit. Entries for IDs that were received again are already stale.
+	var drop int
+	for drop < len(c.entries) {
+		var e = c.entries[drop]
+		if len(c.entries)-drop <= c.size && now.Sub(e.at) < c.window {
+			break
+		}
+		if c.seen[e.id] == e.at {
+			delete(c.seen, e.id)
+		}
+		drop++
+	}
+	c.entries = c.entries[drop:]
+	return false
+}
@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryCache(t *testing.T) {
+	var now = time.Now()
+	var cache = newDeliveryCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	// repeats within the window are seen, and empty IDs never are
+	assert.False(t, cache.Seen("1"))
+	assert.True(t, cache.Seen("1"))
+	assert.False(t, cache.Seen(""))
+	assert.False(t, cache.Seen(""))
+
+	// repeats after the window are not
+	now = now.Add(2 * time.Minute)
+	assert.False(t, cache.Seen("1"))
+	assert.True(t, cache.Seen("1"))
+
+	// the oldest deliveries are forgotten past the size limit
+	assert.False(t, cache.Seen("2"))
+	assert.False(t, cache.Seen("3"))
+	assert.Len(t, cache.seen, 2)
+	assert.False(t, cache.Seen("1"))
+	assert.True(t, cache.Seen("3"))
+
+	// a nil cache sees nothing
+	var none *deliveryCache
+	assert.False(t, none.Seen("1"))
+}
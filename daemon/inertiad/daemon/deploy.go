@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+const (
+	// signedRequestMaxAge is the maximum allowed difference between the
+	// timestamp of a signed deploy request and the daemon's clock, which
+	// protects against replayed requests
+	signedRequestMaxAge = 5 * time.Minute
+
+	// maxSignedRequestSize is the maximum size of a signed deploy request body
+	maxSignedRequestSize = 64 * 1024
+)
+
+var (
+	errStaleRequest    = errors.New("request timestamp is too old or too far in the future")
+	errReplayedRequest = errors.New("request has already been received")
+)
+
+// signedDeployHandler deploys the project in response to a request signed
+// with the webhook secret, allowing CI to trigger deployments without an API
+// token
+func (s *Server) signedDeployHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedRequestSize))
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+	defer r.Body.Close()
+	var deployReq api.SignedUpRequest
+	if err = json.Unmarshal(body, &deployReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// ensure validity
+	if s.state.WebhookSecret == "" {
+		render.Render(w, r, res.Err("no signing key is set up yet - run 'inertia [remote] up' first",
+			http.StatusPreconditionFailed))
+		return
+	}
+	if err = verifySignedDeployRequest(deployReq, s.state.WebhookSecret, time.Now()); err != nil {
+		s.notifySecurityEvent("rejected signed deploy request: " + err.Error())
+		render.Render(w, r, res.ErrUnauthorized("unable to verify request",
+			"error", err))
+		return
+	}
+
+	// Requests are only valid within signedRequestMaxAge of their timestamp,
+	// so remembering signatures for twice as long is enough to reject every
+	// replay of a request
+	if s.signedDeploys.Seen(deployReq.Signature) {
+		s.notifySecurityEvent("rejected signed deploy request: " + errReplayedRequest.Error())
+		render.Render(w, r, res.ErrUnauthorized("unable to verify request",
+			"error", errReplayedRequest))
+		return
+	}
+
+	// Ignore request if repository not set up yet
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+
+	// Check for matching project and branch
+	if deployReq.Project != s.deployment.GetProject() {
+		render.Render(w, r, res.ErrBadRequest("project does not match deployed project",
+			"project", s.deployment.GetProject()))
+		return
+	}
+	var branch = deployReq.Ref
+	if strings.HasPrefix(branch, "refs/") {
+		branch = common.GetBranchFromRef(branch)
+	}
+	if allowedRefs := s.getAllowedRefs(); !allowedRefs.Allows(deployReq.Ref) {
+		render.Render(w, r, res.ErrForbidden("ref '"+deployReq.Ref+"' is not allowed to be deployed to this remote",
+			"allowed_refs", allowedRefs.Patterns()).WithCode(api.ErrCodeRefNotAllowed))
+		return
+	}
+	if branch != s.deployment.GetBranch() {
+		render.Render(w, r, res.ErrBadRequest("branch does not match deployed branch",
+			"branch", s.deployment.GetBranch()))
+		return
+	}
+
+	var stream = log.NewStreamer(log.StreamerOptions{
+		Request:    r,
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+	})
+	defer stream.Close()
+
+	if errRes := s.runDeploy(stream, "deploy", branch, project.DeployOptions{
+		Message: deployReq.Message,
+	}); errRes != nil {
+		stream.Error(errRes)
+		return
+	}
+
+	status, _ := s.status.get(s.deployment, s.docker)
+	stream.Success(res.Msg("Project startup initiated!", http.StatusCreated,
+		"deploy_message", status.DeployMessage))
+}
+
+// runDeploy builds and starts the project, waiting for any other deployment,
+// including queued webhook deployments, to finish first. Output is written to
+// w, and the returned error response is nil if the deployment succeeded.
+func (s *Server) runDeploy(w io.Writer, source, branch string, opts project.DeployOptions) *res.ErrResponse {
+	var errRes *res.ErrResponse
+	s.webhookDeploys.exclusive(func() {
+		s.progress.start(source, branch)
+		deploy, err := s.deployment.Deploy(s.docker, s.deployOutput(w), opts)
+		s.observeBuild(err)
+		if err != nil {
+			s.observeDeploy(err)
+			errRes = res.ErrInternalServer("failed to build project", err).
+				WithCode(api.ErrCodeBuildFailed)
+			return
+		}
+
+		err = deploy()
+		s.observeDeploy(err)
+		if err != nil {
+			errRes = res.ErrInternalServer("failed to deploy project", err).
+				WithCode(api.ErrCodeStartFailed)
+			return
+		}
+		s.status.invalidate()
+	})
+	return errRes
+}
+
+// verifySignedDeployRequest checks the request's signature against the given
+// key, and rejects requests with timestamps too far from now
+func verifySignedDeployRequest(req api.SignedUpRequest, key string, now time.Time) error {
+	var age = now.Sub(time.Unix(req.Timestamp, 0))
+	if age > signedRequestMaxAge || age < -signedRequestMaxAge {
+		return errStaleRequest
+	}
+	return crypto.ValidateSignature(
+		req.Signature,
+		common.DeployRequestPayload(req.Project, req.Ref, req.Message, req.Timestamp),
+		[]byte(key))
+}
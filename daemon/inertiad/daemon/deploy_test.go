@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func Test_verifySignedDeployRequest(t *testing.T) {
+	var (
+		now    = time.Unix(1500000000, 0)
+		signed = func(ts int64) api.SignedUpRequest {
+			return api.SignedUpRequest{
+				Project:   "inertia",
+				Ref:       "refs/heads/master",
+				Timestamp: ts,
+				Signature: common.SignDeployRequest(testKey, "inertia", "refs/heads/master", "", ts),
+			}
+		}
+	)
+	tests := []struct {
+		name    string
+		req     api.SignedUpRequest
+		key     string
+		wantErr bool
+	}{
+		{"ok", signed(now.Unix()), testKey, false},
+		{"ok with small skew", signed(now.Add(time.Minute).Unix()), testKey, false},
+		{"wrong key", signed(now.Unix()), "wrong", true},
+		{"stale", signed(now.Add(-time.Hour).Unix()), testKey, true},
+		{"future", signed(now.Add(time.Hour).Unix()), testKey, true},
+		{"tampered", func() api.SignedUpRequest {
+			var r = signed(now.Unix())
+			r.Ref = "refs/heads/dev"
+			return r
+		}(), testKey, true},
+		{"no signature", api.SignedUpRequest{Timestamp: now.Unix()}, testKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignedDeployRequest(tt.req, tt.key, now)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func Test_signedDeployHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		req      api.SignedUpRequest
+		wantCode int
+	}{
+		{"no secret", "", api.SignedUpRequest{}, http.StatusPreconditionFailed},
+		{"stale", testKey, api.SignedUpRequest{
+			Project:   "inertia",
+			Ref:       "refs/heads/master",
+			Timestamp: 1234,
+			Signature: common.SignDeployRequest(testKey, "inertia", "refs/heads/master", "", 1234),
+		}, http.StatusUnauthorized},
+		{"bad signature", testKey, api.SignedUpRequest{
+			Project:   "inertia",
+			Ref:       "refs/heads/master",
+			Timestamp: time.Now().Unix(),
+			Signature: "sha256=1234",
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Server{
+				state: cfg.Config{WebhookSecret: tt.secret},
+			}
+			b, err := json.Marshal(tt.req)
+			assert.Nil(t, err)
+			req, err := http.NewRequest("POST", "/deploy", bytes.NewReader(b))
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.signedDeployHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func Test_signedDeployHandlerReplay(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetStatusReturns(api.DeploymentStatus{}, nil)
+	var s = &Server{
+		deployment:    fakeDeployer,
+		state:         cfg.Config{WebhookSecret: testKey},
+		signedDeploys: newDeliveryCache(2*signedRequestMaxAge, maxDedupDeliveries),
+	}
+	var now = time.Now().Unix()
+	var deploy = func(timestamp int64) int {
+		b, err := json.Marshal(api.SignedUpRequest{
+			Project:   "inertia",
+			Ref:       "refs/heads/master",
+			Timestamp: timestamp,
+			Signature: common.SignDeployRequest(testKey, "inertia", "refs/heads/master", "", timestamp),
+		})
+		assert.Nil(t, err)
+		req, err := http.NewRequest("POST", "/deploy", bytes.NewReader(b))
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.signedDeployHandler).ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// the first request is verified, and only rejected because nothing has
+	// been deployed yet
+	assert.Equal(t, http.StatusPreconditionFailed, deploy(now))
+
+	// replays of the same request are rejected
+	assert.Equal(t, http.StatusUnauthorized, deploy(now))
+
+	// requests signed at another time are not replays
+	assert.Equal(t, http.StatusPreconditionFailed, deploy(now-1))
+}
+
+func Test_signedDeployHandlerTooLarge(t *testing.T) {
+	var s = &Server{state: cfg.Config{WebhookSecret: testKey}}
+	var body = `{"project":"` + strings.Repeat("a", maxSignedRequestSize) + `"}`
+	req, err := http.NewRequest("POST", "/deploy", strings.NewReader(body))
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.signedDeployHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
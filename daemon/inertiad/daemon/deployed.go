@@ -0,0 +1,53 @@
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// deployedHandler returns a summary of the currently active deployment, which
+// is cheaper than a full status. Responses are tagged with an ETag so that
+// clients polling for a new deployment can make conditional requests.
+func (s *Server) deployedHandler(w http.ResponseWriter, r *http.Request) {
+	if status, _ := s.status.get(s.deployment, s.docker); len(status.Containers) == 0 {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	record, err := manager.GetDeployRecord("")
+	if err == project.ErrDeployNotFound {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	} else if err != nil {
+		render.Render(w, r, res.ErrInternalServer("failed to retrieve deployment", err))
+		return
+	}
+
+	var etag = `"` + record.ID + "-" + record.CommitHash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("deployment retrieved",
+		"deployed", api.DeployedVersion{
+			DeployID:   record.ID,
+			CommitHash: record.CommitHash,
+			Ref:        "refs/heads/" + record.Branch,
+			DeployedAt: record.Timestamp,
+		}))
+}
@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestDeployedHandler(t *testing.T) {
+	s, cleanup := newTestHistoryServer(t)
+	defer cleanup()
+	var fakeDeployer = s.deployment.(*mocks.FakeDeployer)
+
+	// no active deployment
+	req, err := http.NewRequest("GET", "/deployed", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.deployedHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+
+	// active deployment
+	fakeDeployer.GetStatusReturns(api.DeploymentStatus{
+		CommitHash: "abcde",
+		Containers: []string{"/web"},
+	}, nil)
+	s.status.invalidate()
+	recorder = httptest.NewRecorder()
+	http.HandlerFunc(s.deployedHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var etag = recorder.Header().Get("ETag")
+	assert.Equal(t, `"2-abcde"`, etag)
+
+	var deployed api.DeployedVersion
+	_, err = api.Unmarshal(recorder.Body, api.KV{Key: "deployed", Value: &deployed})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", deployed.DeployID)
+	assert.Equal(t, "abcde", deployed.CommitHash)
+
+	// conditional request
+	req.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	http.HandlerFunc(s.deployedHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotModified, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+}
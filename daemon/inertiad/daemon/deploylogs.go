@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+)
+
+// deployLogsHeartbeat is how often heartbeats are sent to deploy log streams
+// while no output is produced, so that proxies do not close idle connections
+var deployLogsHeartbeat = 15 * time.Second
+
+// deployOutput returns a writer that writes deployment output to the given
+// writer, and to clients streaming deploy logs
+func (s *Server) deployOutput(w io.Writer) io.Writer {
+	return io.MultiWriter(w, s.deployLogs)
+}
+
+// deployLogsHandler streams the output of builds and deployments as
+// server-sent events until the client disconnects. Output produced before the
+// request is not included.
+func (s *Server) deployLogsHandler(w http.ResponseWriter, r *http.Request) {
+	lines, unsubscribe := s.deployLogs.Subscribe()
+	defer unsubscribe()
+
+	var (
+		events    = log.NewSSEWriter(w)
+		heartbeat = time.NewTicker(deployLogsHeartbeat)
+	)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-lines:
+			err = events.WriteEvent(line)
+		case <-heartbeat.C:
+			err = events.Heartbeat()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestDeployLogsHandler(t *testing.T) {
+	var heartbeat = deployLogsHeartbeat
+	deployLogsHeartbeat = 5 * time.Millisecond
+	defer func() { deployLogsHeartbeat = heartbeat }()
+
+	// mock deploy keeps building until released
+	var release = make(chan struct{})
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.DeployStub = func(_ *docker.Client, out io.Writer, _ project.DeployOptions) (func() error, error) {
+		for i := 0; ; i++ {
+			select {
+			case <-release:
+				return func() error { return nil }, nil
+			case <-time.After(20 * time.Millisecond):
+				fmt.Fprintf(out, "building step %d\n", i)
+			}
+		}
+	}
+	var s = &Server{deployment: fakeDeployer, deployLogs: log.NewBroadcaster()}
+
+	var handlerDone = make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.deployLogsHandler(w, r)
+		close(handlerDone)
+	}))
+	defer testServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", testServer.URL, nil)
+	assert.Nil(t, err)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, log.EventStreamContentType, resp.Header.Get("Content-Type"))
+
+	var deployDone = make(chan struct{})
+	go func() {
+		s.deployPush(api.QueuedDeploy{Branch: "master", Source: "github"})
+		close(deployDone)
+	}()
+
+	// read events from the in-progress deployment
+	var (
+		reader     = bufio.NewReader(resp.Body)
+		events     []string
+		heartbeats int
+	)
+	for len(events) < 3 || heartbeats == 0 {
+		line, err := reader.ReadString('\n')
+		if !assert.Nil(t, err) {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, strings.TrimSpace(strings.TrimPrefix(line, "data: ")))
+		case line == ": heartbeat\n":
+			heartbeats++
+		}
+	}
+	assert.Equal(t, []string{"building step 0", "building step 1", "building step 2"}, events[:3])
+	assert.NotZero(t, heartbeats)
+
+	// handler should stop once the client disconnects
+	cancel()
+	select {
+	case <-handlerDone:
+	case <-time.After(time.Second):
+		assert.Fail(t, "handler did not stop after client disconnected")
+	}
+
+	close(release)
+	<-deployDone
+}
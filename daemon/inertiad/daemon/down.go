@@ -1,11 +1,15 @@
 package daemon
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/go-chi/render"
 
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
@@ -18,7 +22,8 @@ const (
 // downHandler tries to take the deployment offline
 func (s *Server) downHandler(w http.ResponseWriter, r *http.Request) {
 	if status, _ := s.deployment.GetStatus(s.docker); len(status.Containers) == 0 {
-		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed))
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
 		return
 	}
 
@@ -37,5 +42,22 @@ func (s *Server) downHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.status.invalidate()
+	s.cleanupNetworks(stream)
 	stream.Success(res.MsgOK("project shut down"))
 }
+
+// cleanupNetworks removes shared networks that are no longer used by any
+// containers, if enabled
+func (s *Server) cleanupNetworks(out io.Writer) {
+	if !s.state.CleanupNetworks {
+		return
+	}
+	removed, err := containers.RemoveUnusedNetworks(s.docker)
+	if err != nil {
+		fmt.Fprintln(out, "failed to clean up networks: "+err.Error())
+	}
+	if len(removed) > 0 {
+		fmt.Fprintln(out, "Removed unused networks: "+strings.Join(removed, ", "))
+	}
+}
@@ -33,4 +33,5 @@ func TestDownHandlerNoDeployment(t *testing.T) {
 	handler.ServeHTTP(recorder, req)
 	assert.Equal(t, recorder.Code, http.StatusPreconditionFailed)
 	assert.Contains(t, recorder.Body.String(), msgNoDeployment)
+	assert.Contains(t, recorder.Body.String(), api.ErrCodeNoDeployment)
 }
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"os"
 
 	"github.com/go-chi/render"
 
 	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 )
 
@@ -57,6 +60,10 @@ func envPostHandler(s *Server, w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, res.ErrInternalServer("failed to update variable", err))
 		return
 	}
+	if envReq.Restart {
+		s.redeployWithEnv(w, r, envReq.Name)
+		return
+	}
 
 	render.Render(w, r, res.Msg(
 		"environment variable updated - this will be applied the next time your container is started",
@@ -80,3 +87,37 @@ func envGetHandler(s *Server, w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, res.Msg("configured environment variables retrieved", http.StatusOK,
 		"variables", values))
 }
+
+// redeployWithEnv redeploys the current commit without updating the
+// repository, so that updated environment variables are applied
+func (s *Server) redeployWithEnv(w http.ResponseWriter, r *http.Request, name string) {
+	status, _ := s.deployment.GetStatus(s.docker)
+	if status.CommitHash == "" {
+		render.Render(w, r, res.Err("environment variable updated, but "+msgNoDeployment,
+			http.StatusPreconditionFailed, "variable", name).WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	if status.BuildContainerActive {
+		render.Render(w, r, res.Err("environment variable updated, but a deployment is already in progress",
+			http.StatusConflict, "variable", name).WithCode(api.ErrCodeDeployInProgress))
+		return
+	}
+
+	var stream = log.NewStreamer(log.StreamerOptions{
+		Request:    r,
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+	})
+	defer stream.Close()
+
+	if errRes := s.runDeploy(stream, "env", s.deployment.GetBranch(), project.DeployOptions{
+		SkipUpdate: true,
+		Message:    "environment variable " + name + " updated",
+	}); errRes != nil {
+		stream.Error(errRes)
+		return
+	}
+
+	stream.Success(res.MsgOK("environment variable updated and project redeployed",
+		"variable", name))
+}
@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestEnvHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-env")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var (
+		dbPath  = path.Join(dir, "deployment.db")
+		keyPath = path.Join(dir, "key")
+	)
+	manager, err := project.NewDataManager(dbPath, keyPath)
+	assert.Nil(t, err)
+
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetDataManagerReturns(manager, true)
+	var s = &Server{deployment: fakeDeployer}
+
+	var update = func(req api.EnvRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.envHandler).ServeHTTP(recorder,
+			httptest.NewRequest("POST", "/env", bytes.NewReader(body)))
+		return recorder
+	}
+	var list = func() []string {
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.envHandler).ServeHTTP(recorder,
+			httptest.NewRequest("GET", "/env", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var variables []string
+		_, err := api.Unmarshal(recorder.Body, api.KV{Key: "variables", Value: &variables})
+		assert.Nil(t, err)
+		return variables
+	}
+
+	// set variables
+	assert.Equal(t, http.StatusAccepted,
+		update(api.EnvRequest{Name: "SECRET", Value: "wowgreat", Encrypt: true}).Code)
+	assert.Equal(t, http.StatusAccepted,
+		update(api.EnvRequest{Name: "PLAIN", Value: "hello"}).Code)
+	assert.Equal(t, http.StatusBadRequest, update(api.EnvRequest{Value: "hello"}).Code)
+
+	// secret values should be redacted
+	var variables = list()
+	assert.ElementsMatch(t, []string{"SECRET=[ENCRYPTED]", "PLAIN=hello"}, variables)
+	for _, v := range variables {
+		assert.NotContains(t, v, "wowgreat")
+	}
+
+	// variables should persist across daemon restarts
+	assert.Nil(t, manager.Close())
+	manager, err = project.NewDataManager(dbPath, keyPath)
+	assert.Nil(t, err)
+	defer manager.Close()
+	fakeDeployer.GetDataManagerReturns(manager, true)
+	assert.ElementsMatch(t, []string{"SECRET=[ENCRYPTED]", "PLAIN=hello"}, list())
+	secrets, err := manager.GetSecrets("SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "wowgreat", secrets["SECRET"])
+
+	// unset variables
+	assert.Equal(t, http.StatusAccepted,
+		update(api.EnvRequest{Name: "SECRET", Remove: true}).Code)
+	assert.Equal(t, []string{"PLAIN=hello"}, list())
+	assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+}
+
+func TestEnvHandler_restart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-env")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	manager, err := project.NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	defer manager.Close()
+
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetDataManagerReturns(manager, true)
+	fakeDeployer.DeployReturns(func() error { return nil }, nil)
+	var s = &Server{deployment: fakeDeployer}
+
+	var update = func(req api.EnvRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.envHandler).ServeHTTP(recorder,
+			httptest.NewRequest("POST", "/env", bytes.NewReader(body)))
+		return recorder
+	}
+
+	// nothing to redeploy yet, but the variable is still set
+	var recorder = update(api.EnvRequest{Name: "PLAIN", Value: "hello", Restart: true})
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), api.ErrCodeNoDeployment)
+	assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+	values, err := manager.GetEnvVariables(false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PLAIN=hello"}, values)
+
+	// current commit should be redeployed without updating the repository
+	fakeDeployer.GetStatusReturns(api.DeploymentStatus{CommitHash: "abcde"}, nil)
+	recorder = update(api.EnvRequest{Name: "PLAIN", Value: "goodbye", Restart: true})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, fakeDeployer.DeployCallCount())
+	_, _, opts := fakeDeployer.DeployArgsForCall(0)
+	assert.True(t, opts.SkipUpdate)
+	assert.Empty(t, opts.Commit)
+}
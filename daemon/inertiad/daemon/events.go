@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/notify"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// observeBuild records the outcome of a project build
+func (s *Server) observeBuild(err error) {
+	s.metrics.observeBuild(s.deployment.GetProject(), err)
+	if err == nil {
+		s.progress.advance(api.DeployStateStarting)
+	}
+}
+
+// observeDeploy records the outcome of a deployment and notifies configured
+// channels
+func (s *Server) observeDeploy(err error) {
+	s.metrics.observeDeploy(s.deployment.GetProject(), err)
+	if s.progress != nil {
+		s.status.invalidate()
+		status, _ := s.status.get(s.deployment, s.docker)
+		s.progress.finish(status.CommitHash, err)
+	}
+	if s.notifier == nil {
+		return
+	}
+
+	var e = notify.Event{
+		Type:    notify.EventDeploy,
+		Project: s.deployment.GetProject(),
+		Success: err == nil,
+	}
+	if err != nil {
+		e.Message = "deployment of branch " + s.deployment.GetBranch() + " failed: " + err.Error()
+	} else {
+		e.Message = "deployed branch " + s.deployment.GetBranch()
+	}
+	if s.notifier.WantsStatus() {
+		s.status.invalidate()
+		if status, err := s.status.get(s.deployment, s.docker); err == nil {
+			e.Status = &status
+		}
+	}
+	s.notifier.Notify(e)
+}
+
+// observeFlap notifies configured channels of a service that is restarted so
+// often that it may be crash-looping
+func (s *Server) observeFlap(f health.Flap) {
+	s.status.invalidate()
+	if s.notifier == nil {
+		return
+	}
+
+	var message = fmt.Sprintf("container %s needed %d restarts in %s and may be crash-looping",
+		f.Service, f.Restarts, f.Window)
+	if f.Stopped {
+		message = fmt.Sprintf("container %s needed %d restarts in %s and is crash-looping - "+
+			"automatic restarts have been stopped until resumed", f.Service, f.Restarts, f.Window)
+	}
+	s.notifier.Notify(notify.Event{
+		Type:    notify.EventHealth,
+		Project: s.deployment.GetProject(),
+		Message: message,
+	})
+}
+
+// notifySecurityEvent immediately notifies configured channels of a suspicious
+// request
+func (s *Server) notifySecurityEvent(message string) {
+	if s.notifier == nil {
+		return
+	}
+	var project string
+	if s.deployment != nil {
+		project = s.deployment.GetProject()
+	}
+	s.notifier.Notify(notify.Event{
+		Type:    notify.EventSecurity,
+		Project: project,
+		Message: message,
+	})
+}
+
+// failedNotificationsHandler lists notifications that could not be delivered
+// after exhausting retries
+func (s *Server) failedNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, res.MsgOK("failed notifications retrieved",
+		"notifications", s.notifier.Failed()))
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/notify"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestObserveDeploy(t *testing.T) {
+	var received = make(chan []notify.Event, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Events []notify.Event }
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body.Events
+	}))
+	defer testServer.Close()
+
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetProjectReturns("inertia")
+	fakeDeployer.GetBranchReturns("master")
+
+	// no notifier set up
+	var s = &Server{deployment: fakeDeployer}
+	s.observeDeploy(nil)
+
+	s.notifier = notify.NewDispatcher(ioutil.Discard)
+	defer s.notifier.Close()
+	assert.Nil(t, s.notifier.SetChannels([]notify.ChannelConfig{{URL: testServer.URL}}))
+	s.observeDeploy(errors.New("oh no"))
+
+	select {
+	case events := <-received:
+		assert.Len(t, events, 1)
+		assert.Equal(t, "inertia", events[0].Project)
+		assert.False(t, events[0].Success)
+		assert.Contains(t, events[0].Message, "oh no")
+	case <-time.After(time.Second):
+		assert.Fail(t, "notification not received")
+	}
+}
+
+func TestObserveDeployStatus(t *testing.T) {
+	var received = make(chan []notify.Event, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Events []notify.Event }
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body.Events
+	}))
+	defer testServer.Close()
+
+	var fakeDeployer = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{Containers: []string{"/web"}}, nil
+		},
+	}
+	var s = &Server{deployment: fakeDeployer, notifier: notify.NewDispatcher(ioutil.Discard)}
+	defer s.notifier.Close()
+	assert.Nil(t, s.notifier.SetChannels([]notify.ChannelConfig{{
+		URL:           testServer.URL,
+		IncludeStatus: true,
+	}}))
+	s.observeDeploy(nil)
+
+	select {
+	case events := <-received:
+		assert.Len(t, events, 1)
+		assert.True(t, events[0].Success)
+		assert.Equal(t, []string{"/web"}, events[0].Status.Containers)
+	case <-time.After(time.Second):
+		assert.Fail(t, "notification not received")
+	}
+}
+
+func TestFailedNotificationsHandler(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetProjectReturns("inertia")
+	var s = &Server{deployment: fakeDeployer, notifier: notify.NewDispatcher(ioutil.Discard)}
+	defer s.notifier.Close()
+	assert.Nil(t, s.notifier.SetChannels([]notify.ChannelConfig{{
+		URL:   testServer.URL,
+		Retry: notify.RetryPolicy{Attempts: 2, Backoff: time.Millisecond},
+	}}))
+	s.notifySecurityEvent("intruder alert")
+
+	// wait for retries to be exhausted
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if len(s.notifier.Failed()) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, err := http.NewRequest("GET", "/notifications/failed", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.failedNotificationsHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "intruder alert")
+}
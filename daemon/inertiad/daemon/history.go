@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+const (
+	// historyCompressionInterval is how often older deployment history
+	// records are compressed, if enabled
+	historyCompressionInterval = time.Hour
+
+	// uncompressedHistoryRecords is the number of most recent deployment
+	// history records kept uncompressed for fast access
+	uncompressedHistoryRecords = 10
+)
+
+// historyHandler lists past deployments, or retrieves a single deployment if
+// a deploy ID or image digest is provided
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		render.Render(w, r, res.Err("no deployment history found", http.StatusPreconditionFailed))
+		return
+	}
+
+	if id := r.URL.Query().Get(api.DeployID); id != "" {
+		record, err := manager.GetDeployRecord(id)
+		if err != nil {
+			renderDeployRecordErr(w, r, id, err)
+			return
+		}
+		render.Render(w, r, res.MsgOK("deployment retrieved",
+			"deploy", record))
+		return
+	}
+
+	records, err := manager.GetDeployHistory()
+	if err != nil {
+		render.Render(w, r, res.ErrInternalServer("failed to retrieve deployment history", err))
+		return
+	}
+	render.Render(w, r, res.MsgOK("deployment history retrieved",
+		"history", records))
+}
+
+// diffHandler compares two past deployments
+func (s *Server) diffHandler(w http.ResponseWriter, r *http.Request) {
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		render.Render(w, r, res.Err("no deployment history found", http.StatusPreconditionFailed))
+		return
+	}
+
+	var (
+		fromID = r.URL.Query().Get(api.DiffFrom)
+		toID   = r.URL.Query().Get(api.DiffTo)
+	)
+	if fromID == "" || toID == "" {
+		render.Render(w, r, res.ErrBadRequest("two deployments must be provided"))
+		return
+	}
+	from, err := manager.GetDeployRecord(fromID)
+	if err != nil {
+		renderDeployRecordErr(w, r, fromID, err)
+		return
+	}
+	to, err := manager.GetDeployRecord(toID)
+	if err != nil {
+		renderDeployRecordErr(w, r, toID, err)
+		return
+	}
+
+	render.Render(w, r, res.MsgOK("deployments compared",
+		"diff", s.deployment.Diff(*from, *to)))
+}
+
+// exportHandler writes the images used by a past deployment as a tarball
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		render.Render(w, r, res.Err("no deployment history found", http.StatusPreconditionFailed))
+		return
+	}
+
+	var id = r.URL.Query().Get(api.DeployID)
+	record, err := manager.GetDeployRecord(id)
+	if err != nil {
+		renderDeployRecordErr(w, r, id, err)
+		return
+	}
+	if len(record.Images) == 0 {
+		render.Render(w, r, res.Err("deployment has no recorded images", http.StatusPreconditionFailed,
+			"id", record.ID))
+		return
+	}
+
+	// Images are exported by name, so make sure each name still refers to the
+	// image that was deployed
+	var (
+		ctx    = context.Background()
+		images = make([]string, len(record.Images))
+	)
+	for i, image := range record.Images {
+		current, _, err := s.docker.ImageInspectWithRaw(ctx, image.Name)
+		if err != nil || current.ID != image.ID {
+			render.Render(w, r, res.Err("images used by this deployment are no longer available",
+				http.StatusGone, "id", record.ID, "image", image.Name))
+			return
+		}
+		images[i] = image.Name
+	}
+	reader, err := s.docker.ImageSave(ctx, images)
+	if err != nil {
+		render.Render(w, r, res.ErrInternalServer("failed to export images", err))
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, reader); err != nil {
+		println("failed to export images: " + err.Error())
+	}
+}
+
+// promoteHandler deploys images promoted from another remote without building
+// the project
+func (s *Server) promoteHandler(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Parse promotion details, which must precede the images
+	part, err := reader.NextPart()
+	if err != nil || part.FormName() != api.PromoteRequestPart {
+		render.Render(w, r, res.ErrBadRequest("promotion details must be provided first"))
+		return
+	}
+	var promoteReq api.PromoteRequest
+	if err = json.NewDecoder(part).Decode(&promoteReq); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()))
+		return
+	}
+
+	// Ignore request if repository not set up yet
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	if promoteReq.Record.Project != s.deployment.GetProject() {
+		render.Render(w, r, res.ErrBadRequest("project does not match deployed project",
+			"project", s.deployment.GetProject()))
+		return
+	}
+
+	images, err := reader.NextPart()
+	if err != nil || images.FormName() != api.PromoteImagesPart {
+		render.Render(w, r, res.ErrBadRequest("no images provided"))
+		return
+	}
+
+	var stream = log.NewStreamer(log.StreamerOptions{
+		Request:    r,
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+	})
+	defer stream.Close()
+
+	// Promoted images are already built
+	s.progress.start("promote", s.deployment.GetBranch())
+	s.progress.advance(api.DeployStateStarting)
+	deploy, err := s.deployment.Promote(s.docker, s.deployOutput(stream), images, promoteReq)
+	if err != nil {
+		s.observeDeploy(err)
+		stream.Error(res.ErrInternalServer("failed to load promoted images", err))
+		return
+	}
+
+	err = deploy()
+	s.observeDeploy(err)
+	if err != nil {
+		stream.Error(res.ErrInternalServer("failed to deploy project", err).
+			WithCode(api.ErrCodeStartFailed))
+		return
+	}
+
+	s.status.invalidate()
+	status, _ := s.status.get(s.deployment, s.docker)
+	stream.Success(res.Msg("Promoted project startup initiated!", http.StatusCreated,
+		"deploy_message", status.DeployMessage))
+}
+
+// compressHistory periodically compresses older deployment history records,
+// if history compression is enabled
+func (s *Server) compressHistory() {
+	var ticker = time.NewTicker(historyCompressionInterval)
+	defer ticker.Stop()
+	for {
+		if !s.state.CompressHistory {
+			<-ticker.C
+			continue
+		}
+		if manager, found := s.deployment.GetDataManager(); found {
+			count, err := manager.CompressDeployHistory(uncompressedHistoryRecords)
+			if err != nil {
+				println("failed to compress deployment history: " + err.Error())
+			} else if count > 0 {
+				fmt.Printf("compressed %d deployment history records\n", count)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func renderDeployRecordErr(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch err {
+	case project.ErrDeployNotFound:
+		render.Render(w, r, res.ErrNotFound(err.Error(), "id", id))
+		return
+	case project.ErrDeployExpired:
+		render.Render(w, r, res.Err(err.Error(), http.StatusGone, "id", id))
+		return
+	}
+	render.Render(w, r, res.ErrInternalServer("failed to retrieve deployment", err))
+}
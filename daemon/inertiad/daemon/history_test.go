@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func newTestHistoryServer(t *testing.T) (*Server, func()) {
+	dir, err := ioutil.TempDir("", "inertia-history")
+	assert.Nil(t, err)
+	manager, err := project.NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	assert.Nil(t, manager.AddDeployRecord(&api.DeployRecord{CommitHash: "wow"}, 1))
+	assert.Nil(t, manager.AddDeployRecord(&api.DeployRecord{CommitHash: "abcde"}, 1))
+
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetDataManagerReturns(manager, true)
+	return &Server{deployment: fakeDeployer}, func() { os.RemoveAll(dir) }
+}
+
+func TestHistoryHandler(t *testing.T) {
+	s, cleanup := newTestHistoryServer(t)
+	defer cleanup()
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{"list", "", http.StatusOK},
+		{"by id", "2", http.StatusOK},
+		{"expired", "1", http.StatusGone},
+		{"not found", "3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/history?"+api.DeployID+"="+tt.id, nil)
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.historyHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Contains(t, recorder.Body.String(), "abcde")
+			}
+		})
+	}
+}
+
+func TestDiffHandler(t *testing.T) {
+	s, cleanup := newTestHistoryServer(t)
+	defer cleanup()
+	var fakeDeployer = s.deployment.(*mocks.FakeDeployer)
+	fakeDeployer.DiffReturns(api.DeployDiff{GitDiff: "+ wow"})
+
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		wantCode int
+	}{
+		{"ok", "2", "2", http.StatusOK},
+		{"missing deployment", "2", "", http.StatusBadRequest},
+		{"expired", "1", "2", http.StatusGone},
+		{"not found", "2", "3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET",
+				"/history/diff?"+api.DiffFrom+"="+tt.from+"&"+api.DiffTo+"="+tt.to, nil)
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.diffHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Contains(t, recorder.Body.String(), "+ wow")
+			}
+		})
+	}
+	assert.Equal(t, 1, fakeDeployer.DiffCallCount())
+}
+
+func TestExportHandlerNoImages(t *testing.T) {
+	s, cleanup := newTestHistoryServer(t)
+	defer cleanup()
+
+	req, err := http.NewRequest("GET", "/history/export?"+api.DeployID+"=2", nil)
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.exportHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+}
+
+func TestPromoteHandlerNotMultipart(t *testing.T) {
+	var s = &Server{deployment: &mocks.FakeDeployer{}}
+
+	req, err := http.NewRequest("POST", "/promote", nil)
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.promoteHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Zero(t, s.deployment.(*mocks.FakeDeployer).PromoteCallCount())
+}
@@ -92,6 +92,15 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer logs.Close()
 
+	// Mask secrets in log output if enabled
+	var masker *log.Masker
+	if s.state.MaskLogSecrets {
+		if masker, err = s.getLogMasker(); err != nil {
+			stream.Error(res.ErrInternalServer("failed to retrieve secrets for masking", err))
+			return
+		}
+	}
+
 	if shouldStream {
 		var stop = make(chan struct{})
 		socket, err := stream.GetSocketWriter()
@@ -99,13 +108,27 @@ func (s *Server) logHandler(w http.ResponseWriter, r *http.Request) {
 			stream.Error(res.ErrInternalServer("failed to write to socket", err))
 			return
 		}
-		log.FlushRoutine(socket, logs, stop)
+		log.FlushRoutine(masker.Writer(socket), logs, stop)
 		defer stream.Close()
 		defer close(stop)
 	} else {
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(logs)
 		render.Render(w, r, res.MsgOK("configured environment variables retrieved",
-			"logs", strings.Split(buf.String(), "\n")))
+			"logs", strings.Split(masker.Mask(buf.String()), "\n")))
+	}
+}
+
+// getLogMasker creates a masker for the values of the project's encrypted
+// environment variables
+func (s *Server) getLogMasker() (*log.Masker, error) {
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		return log.NewMasker(nil), nil
+	}
+	secrets, err := manager.GetSecretValues()
+	if err != nil {
+		return nil, err
 	}
+	return log.NewMasker(secrets), nil
 }
@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/metrics"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// maxProjectLabels bounds the number of distinct project labels tracked by
+// per-project metrics
+const maxProjectLabels = 50
+
+// serverMetrics tracks daemon activity
+type serverMetrics struct {
+	*metrics.Registry
+
+	requests *metrics.Counter
+	webhooks *metrics.Counter
+
+	// per-project metrics
+	deployments       *metrics.CounterVec
+	deploymentsFailed *metrics.CounterVec
+	builds            *metrics.CounterVec
+	buildsFailed      *metrics.CounterVec
+}
+
+func newServerMetrics() *serverMetrics {
+	var r = metrics.NewRegistry("inertia")
+	return &serverMetrics{
+		Registry: r,
+
+		requests: r.NewCounter("requests_total",
+			"Number of API requests received."),
+		webhooks: r.NewCounter("webhooks_total",
+			"Number of webhook events received."),
+
+		deployments: r.NewCounterVec("deployments_total",
+			"Number of deployments attempted.", "project", maxProjectLabels),
+		deploymentsFailed: r.NewCounterVec("deployments_failed_total",
+			"Number of deployments that failed to build or start.", "project", maxProjectLabels),
+		builds: r.NewCounterVec("builds_total",
+			"Number of project builds attempted.", "project", maxProjectLabels),
+		buildsFailed: r.NewCounterVec("builds_failed_total",
+			"Number of project builds that failed.", "project", maxProjectLabels),
+	}
+}
+
+// watchContainers registers a gauge of the number of active containers of
+// the deployed project, computed from the given function
+func (m *serverMetrics) watchContainers(count func() map[string]float64) {
+	m.NewGaugeVecFunc("containers_active",
+		"Number of active project containers.", "project", count)
+}
+
+// instrument wraps the given handler to count incoming requests
+func (m *serverMetrics) instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requests.Inc()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// observeDeploy records the outcome of a deployment of the given project.
+// Like other observations, it is a no-op on nil metrics.
+func (m *serverMetrics) observeDeploy(project string, err error) {
+	if m == nil {
+		return
+	}
+	m.deployments.With(project).Inc()
+	if err != nil {
+		m.deploymentsFailed.With(project).Inc()
+	}
+}
+
+// observeBuild records the outcome of a build of the given project
+func (m *serverMetrics) observeBuild(project string, err error) {
+	if m == nil {
+		return
+	}
+	m.builds.With(project).Inc()
+	if err != nil {
+		m.buildsFailed.With(project).Inc()
+	}
+}
+
+// observeWebhook records an incoming webhook event
+func (m *serverMetrics) observeWebhook() {
+	if m == nil {
+		return
+	}
+	m.webhooks.Inc()
+}
+
+// activeContainers counts the active containers of the deployed project, keyed
+// by project name
+func (s *Server) activeContainers() map[string]float64 {
+	if s.deployment == nil || s.deployment.GetProject() == "" {
+		return nil
+	}
+	status, err := s.status.get(s.deployment, s.docker)
+	if err != nil {
+		return nil
+	}
+	return map[string]float64{
+		s.deployment.GetProject(): float64(len(status.Containers)),
+	}
+}
+
+// statsHandler returns a JSON snapshot of daemon metrics
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	var uptime = s.metrics.Uptime()
+	render.Render(w, r, res.MsgOK("stats retrieved",
+		"stats", api.DaemonStats{
+			InertiaVersion: s.version,
+			Uptime:         uptime.Round(time.Second).String(),
+			UptimeSeconds:  int64(uptime.Seconds()),
+			Counters:       s.metrics.Snapshot(),
+		}))
+}
+
+// metricsHandler returns daemon metrics in the Prometheus text format
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.metrics.WritePrometheus(w, s.version); err != nil {
+		println("failed to write metrics: " + err.Error())
+	}
+}
@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestStatsHandler(t *testing.T) {
+	var s = &Server{version: "test", metrics: newServerMetrics()}
+	s.metrics.observeDeploy("wow", nil)
+	s.metrics.observeDeploy("wow", errors.New("oh no"))
+	s.metrics.observeBuild("wow", nil)
+	s.metrics.observeWebhook()
+
+	req, err := http.NewRequest("GET", "/stats", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	s.metrics.instrument(http.HandlerFunc(s.statsHandler)).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var stats api.DaemonStats
+	_, err = api.Unmarshal(recorder.Body, api.KV{Key: "stats", Value: &stats})
+	assert.Nil(t, err)
+	assert.Equal(t, "test", stats.InertiaVersion)
+	assert.Equal(t, uint64(1), stats.Counters["inertia_requests_total"])
+	assert.Equal(t, uint64(2), stats.Counters["inertia_deployments_total"])
+	assert.Equal(t, uint64(1), stats.Counters["inertia_deployments_failed_total"])
+	assert.Equal(t, uint64(1), stats.Counters["inertia_webhooks_total"])
+	assert.Equal(t, uint64(1), stats.Counters["inertia_builds_total"])
+}
+
+func TestMetricsHandler(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{Containers: []string{"web", "db"}}, nil
+		},
+	}
+	fakeDeployer.GetProjectReturns("wow")
+	var s = &Server{version: "test", metrics: newServerMetrics(), deployment: fakeDeployer}
+	s.metrics.watchContainers(s.activeContainers)
+	s.observeBuild(errors.New("oh no"))
+	s.metrics.observeDeploy("wow", nil)
+	s.metrics.observeDeploy("other", nil)
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.metricsHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var body = recorder.Body.String()
+	assert.Contains(t, body, `inertia_deployments_total{project="other"} 1`+"\n")
+	assert.Contains(t, body, `inertia_deployments_total{project="wow"} 1`+"\n")
+	assert.Contains(t, body, `inertia_builds_failed_total{project="wow"} 1`+"\n")
+	assert.Contains(t, body, `inertia_containers_active{project="wow"} 2`+"\n")
+	assert.Contains(t, body, "inertia_webhooks_total 0\n")
+	assert.Contains(t, body, `inertia_build_info{version="test"} 1`)
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// deployProgress tracks the most recent deployment as it advances through the
+// deploy pipeline, so that clients can poll for its progress. A nil
+// deployProgress ignores updates.
+type deployProgress struct {
+	now func() time.Time
+
+	mux     sync.Mutex
+	current api.DeployProgress
+}
+
+// newDeployProgress creates an idle deployProgress
+func newDeployProgress() *deployProgress {
+	return &deployProgress{
+		now:     time.Now,
+		current: api.DeployProgress{State: api.DeployStateIdle},
+	}
+}
+
+// queue records a deployment that is waiting to start. A deployment that is
+// already underway is not replaced - the queued deployment is reported once it
+// starts.
+func (p *deployProgress) queue(source, branch string) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.current.State == api.DeployStateBuilding || p.current.State == api.DeployStateStarting {
+		return
+	}
+	p.reset(api.DeployStateQueued, source, branch)
+}
+
+// start records the start of a deployment, beginning with its build
+func (p *deployProgress) start(source, branch string) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	p.reset(api.DeployStateBuilding, source, branch)
+	p.mux.Unlock()
+}
+
+// reset replaces the current deployment with a new one. Callers must hold the
+// lock.
+func (p *deployProgress) reset(state, source, branch string) {
+	var now = p.now()
+	p.current = api.DeployProgress{
+		State:     state,
+		Source:    source,
+		Branch:    branch,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// advance moves the current deployment to the given state
+func (p *deployProgress) advance(state string) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	p.current.State = state
+	p.current.UpdatedAt = p.now()
+	p.mux.Unlock()
+}
+
+// finish records the outcome of the current deployment, and the commit that
+// is now deployed
+func (p *deployProgress) finish(commit string, err error) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var now = p.now()
+	p.current.Commit = commit
+	p.current.UpdatedAt = now
+	p.current.FinishedAt = &now
+	if err != nil {
+		p.current.State = api.DeployStateFailed
+		p.current.Error = err.Error()
+	} else {
+		p.current.State = api.DeployStateRunning
+		p.current.Error = ""
+	}
+}
+
+// get returns the progress of the current deployment
+func (p *deployProgress) get() api.DeployProgress {
+	if p == nil {
+		return api.DeployProgress{State: api.DeployStateIdle}
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	var progress = p.current
+	if progress.FinishedAt != nil {
+		var finished = *progress.FinishedAt
+		progress.FinishedAt = &finished
+	}
+	return progress
+}
+
+// progressHandler returns the progress of the most recent deployment
+func (s *Server) progressHandler(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, res.MsgOK("deploy status retrieved",
+		"progress", s.progress.get()))
+}
@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestDeployProgress(t *testing.T) {
+	var now = time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var p = newDeployProgress()
+	p.now = func() time.Time { return now }
+	assert.Equal(t, api.DeployStateIdle, p.get().State)
+
+	p.queue("github", "master")
+	assert.Equal(t, api.DeployStateQueued, p.get().State)
+
+	now = now.Add(time.Minute)
+	p.start("github", "master")
+	assert.Equal(t, api.DeployStateBuilding, p.get().State)
+	assert.Equal(t, now, p.get().StartedAt)
+
+	// deployments queued while building should not replace the current one
+	p.queue("github", "master")
+	assert.Equal(t, api.DeployStateBuilding, p.get().State)
+
+	now = now.Add(time.Minute)
+	p.advance(api.DeployStateStarting)
+	assert.Equal(t, api.DeployStateStarting, p.get().State)
+	assert.Equal(t, now, p.get().UpdatedAt)
+
+	now = now.Add(time.Minute)
+	p.finish("abcde", nil)
+	var progress = p.get()
+	assert.Equal(t, api.DeployStateRunning, progress.State)
+	assert.Equal(t, "abcde", progress.Commit)
+	assert.Equal(t, now, *progress.FinishedAt)
+	assert.Equal(t, now.Add(-2*time.Minute), progress.StartedAt)
+
+	p.start("up", "master")
+	assert.Nil(t, p.get().FinishedAt)
+	p.finish("abcde", errors.New("oh no"))
+	assert.Equal(t, api.DeployStateFailed, p.get().State)
+	assert.Equal(t, "oh no", p.get().Error)
+
+	// nil progress should be idle
+	var nilProgress *deployProgress
+	nilProgress.start("up", "master")
+	assert.Equal(t, api.DeployStateIdle, nilProgress.get().State)
+}
+
+func TestProgressHandler(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetBranchReturns("master")
+	fakeDeployer.GetStatusReturns(api.DeploymentStatus{CommitHash: "abcde"}, nil)
+	var s = &Server{deployment: fakeDeployer, progress: newDeployProgress()}
+
+	var getProgress = func() api.DeployProgress {
+		req, err := http.NewRequest("GET", "/deploy/status", nil)
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(s.progressHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var progress api.DeployProgress
+		_, err = api.Unmarshal(recorder.Body, api.KV{Key: "progress", Value: &progress})
+		assert.Nil(t, err)
+		return progress
+	}
+	assert.Equal(t, api.DeployStateIdle, getProgress().State)
+
+	// drive a deployment through each state, checking along the way
+	var states []string
+	var startErr error
+	fakeDeployer.DeployStub = func(*docker.Client, io.Writer, project.DeployOptions) (func() error, error) {
+		states = append(states, getProgress().State)
+		return func() error {
+			states = append(states, getProgress().State)
+			return startErr
+		}, nil
+	}
+
+	s.progress.queue("github", "master")
+	states = append(states, getProgress().State)
+	s.deployPush(api.QueuedDeploy{Branch: "master", Source: "github"})
+	var progress = getProgress()
+	states = append(states, progress.State)
+	assert.Equal(t, []string{
+		api.DeployStateQueued,
+		api.DeployStateBuilding,
+		api.DeployStateStarting,
+		api.DeployStateRunning,
+	}, states)
+	assert.Equal(t, "abcde", progress.Commit)
+	assert.Equal(t, "github", progress.Source)
+	assert.Equal(t, "master", progress.Branch)
+	assert.NotNil(t, progress.FinishedAt)
+
+	// failed startup
+	startErr = errors.New("oh no")
+	s.deployPush(api.QueuedDeploy{Branch: "master", Source: "github"})
+	progress = getProgress()
+	assert.Equal(t, api.DeployStateFailed, progress.State)
+	assert.Equal(t, "oh no", progress.Error)
+
+	// failed build
+	fakeDeployer.DeployStub = func(*docker.Client, io.Writer, project.DeployOptions) (func() error, error) {
+		return nil, errors.New("build failed")
+	}
+	s.deployPush(api.QueuedDeploy{Branch: "master", Source: "github"})
+	progress = getProgress()
+	assert.Equal(t, api.DeployStateFailed, progress.State)
+	assert.Equal(t, "build failed", progress.Error)
+}
@@ -6,14 +6,17 @@ import (
 
 	"github.com/go-chi/render"
 
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 )
 
-// pruneHandler cleans up Docker assets
+// pruneHandler removes stopped containers and dangling images created by
+// Inertia, and reports the disk space reclaimed
 func (s *Server) pruneHandler(w http.ResponseWriter, r *http.Request) {
 	if s.deployment == nil {
-		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed))
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
 		return
 	}
 
@@ -24,10 +27,13 @@ func (s *Server) pruneHandler(w http.ResponseWriter, r *http.Request) {
 	})
 	defer stream.Close()
 
-	if err := s.deployment.Prune(s.docker, stream); err != nil {
+	report, err := s.deployment.Prune(s.docker, stream)
+	if err != nil {
 		stream.Error(res.ErrInternalServer("failed to prune Docker assets", err))
 		return
 	}
+	s.cleanupNetworks(stream)
 
-	stream.Success(res.MsgOK("docker assets have been pruned"))
+	stream.Success(res.MsgOK("docker assets have been pruned",
+		"prune", report))
 }
@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestPruneHandler(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.PruneReturns(api.PruneReport{
+		ContainersDeleted: []string{"abcde"},
+		ImagesDeleted:     []string{"sha256:12345"},
+		SpaceReclaimed:    2048,
+	}, nil)
+	var s = &Server{deployment: fakeDeployer}
+
+	req, err := http.NewRequest("POST", "/prune", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.pruneHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, fakeDeployer.PruneCallCount())
+
+	var report api.PruneReport
+	_, err = api.Unmarshal(recorder.Body, api.KV{Key: "prune", Value: &report})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2048), report.SpaceReclaimed)
+	assert.Equal(t, []string{"abcde"}, report.ContainersDeleted)
+	assert.Equal(t, []string{"sha256:12345"}, report.ImagesDeleted)
+}
+
+func TestPruneHandler_failed(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.PruneReturns(api.PruneReport{}, errors.New("docker is down"))
+	var s = &Server{deployment: fakeDeployer}
+
+	req, err := http.NewRequest("POST", "/prune", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.pruneHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "docker is down")
+}
@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// maxSkippedDeploys is the number of superseded deployments retained for
+// inspection
+const maxSkippedDeploys = 20
+
+// deployQueue runs webhook-triggered deployments one at a time. While waiting,
+// only the most recent push to each branch is kept, since an older push would
+// be replaced by the newer one as soon as it was deployed. Other deployments
+// are run with exclusive, so that they never overlap with queued ones.
+type deployQueue struct {
+	deploy func(api.QueuedDeploy)
+
+	// deploying is held while any deployment is running
+	deploying sync.Mutex
+
+	mux     sync.Mutex
+	running *api.QueuedDeploy
+	pending []api.QueuedDeploy
+	skipped []api.QueuedDeploy
+}
+
+// newDeployQueue creates a queue that runs queued deployments with the given
+// function
+func newDeployQueue(deploy func(api.QueuedDeploy)) *deployQueue {
+	return &deployQueue{deploy: deploy}
+}
+
+// push queues a deployment, superseding a pending deployment of the same
+// branch if there is one, and starts processing the queue if it is idle. The
+// superseded deployment is returned, or nil if nothing was superseded.
+// Deployments that are already running are never superseded.
+func (q *deployQueue) push(d api.QueuedDeploy) *api.QueuedDeploy {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	var superseded *api.QueuedDeploy
+	for i, p := range q.pending {
+		if p.Branch == d.Branch {
+			p.Status = api.QueuedDeploySuperseded
+			superseded = &p
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			q.skipped = append([]api.QueuedDeploy{p}, q.skipped...)
+			if len(q.skipped) > maxSkippedDeploys {
+				q.skipped = q.skipped[:maxSkippedDeploys]
+			}
+			break
+		}
+	}
+
+	d.Status = api.QueuedDeployPending
+	q.pending = append(q.pending, d)
+	if q.running == nil {
+		q.running = q.next()
+		go q.process(*q.running)
+	}
+	return superseded
+}
+
+// process runs the given deployment, followed by queued deployments until the
+// queue is empty
+func (q *deployQueue) process(d api.QueuedDeploy) {
+	for {
+		q.deploy(d)
+
+		q.mux.Lock()
+		q.running = q.next()
+		if q.running == nil {
+			q.mux.Unlock()
+			return
+		}
+		d = *q.running
+		q.mux.Unlock()
+	}
+}
+
+// exclusive runs the given deployment once no other deployment is running, and
+// holds off queued deployments until it is done. A nil deployQueue runs the
+// deployment immediately.
+func (q *deployQueue) exclusive(deploy func()) {
+	if q == nil {
+		deploy()
+		return
+	}
+	q.deploying.Lock()
+	defer q.deploying.Unlock()
+	deploy()
+}
+
+// next dequeues the next pending deployment and marks it as running, or
+// returns nil if there are none. Callers must hold the lock.
+func (q *deployQueue) next() *api.QueuedDeploy {
+	if len(q.pending) == 0 {
+		return nil
+	}
+	var d = q.pending[0]
+	q.pending = q.pending[1:]
+	d.Status = api.QueuedDeployRunning
+	return &d
+}
+
+// snapshot returns the current state of the queue
+func (q *deployQueue) snapshot() api.DeployQueue {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	var state = api.DeployQueue{
+		Pending: append([]api.QueuedDeploy{}, q.pending...),
+		Skipped: append([]api.QueuedDeploy{}, q.skipped...),
+	}
+	if q.running != nil {
+		var running = *q.running
+		state.Running = &running
+	}
+	return state
+}
+
+// deployQueueHandler returns the state of webhook-triggered deployments
+func (s *Server) deployQueueHandler(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, res.MsgOK("deploy queue retrieved",
+		"queue", s.webhookDeploys.snapshot()))
+}
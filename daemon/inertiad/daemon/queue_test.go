@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestDeployQueue(t *testing.T) {
+	var (
+		release  = make(chan struct{})
+		mux      sync.Mutex
+		deployed []string
+	)
+	var q = newDeployQueue(func(d api.QueuedDeploy) {
+		<-release
+		mux.Lock()
+		deployed = append(deployed, d.Ref)
+		mux.Unlock()
+	})
+
+	// First push should start running immediately
+	assert.Nil(t, q.push(api.QueuedDeploy{Branch: "master", Ref: "first"}))
+	assert.Equal(t, api.QueuedDeployRunning, q.snapshot().Running.Status)
+
+	// Newer pushes should supersede pending, but not running, deploys
+	assert.Nil(t, q.push(api.QueuedDeploy{Branch: "master", Ref: "second"}))
+	superseded := q.push(api.QueuedDeploy{Branch: "master", Ref: "third"})
+	assert.NotNil(t, superseded)
+	assert.Equal(t, "second", superseded.Ref)
+
+	var state = q.snapshot()
+	assert.Equal(t, "first", state.Running.Ref)
+	assert.Len(t, state.Pending, 1)
+	assert.Equal(t, "third", state.Pending[0].Ref)
+	assert.Equal(t, api.QueuedDeployPending, state.Pending[0].Status)
+	assert.Len(t, state.Skipped, 1)
+	assert.Equal(t, api.QueuedDeploySuperseded, state.Skipped[0].Status)
+
+	// Queue should drain in order, skipping superseded deploys
+	close(release)
+	for i := 0; i < 100 && q.snapshot().Running != nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, q.snapshot().Running)
+	mux.Lock()
+	assert.Equal(t, []string{"first", "third"}, deployed)
+	mux.Unlock()
+}
+
+func TestDeployQueueExclusive(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		mux      sync.Mutex
+		deployed []string
+	)
+	var record = func(ref string) {
+		mux.Lock()
+		deployed = append(deployed, ref)
+		mux.Unlock()
+	}
+
+	// queued deployments run exclusively, like Server.runDeploy
+	var q *deployQueue
+	q = newDeployQueue(func(d api.QueuedDeploy) {
+		q.exclusive(func() {
+			close(started)
+			<-release
+			record(d.Ref)
+		})
+	})
+	q.push(api.QueuedDeploy{Branch: "master", Ref: "webhook"})
+	<-started
+
+	// other deployments wait for the queued deployment to finish
+	var done = make(chan struct{})
+	go func() {
+		q.exclusive(func() { record("manual") })
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("deployment ran while a queued deployment was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	mux.Lock()
+	assert.Equal(t, []string{"webhook", "manual"}, deployed)
+	mux.Unlock()
+
+	// without a queue, deployments run immediately
+	var ran bool
+	var none *deployQueue
+	none.exclusive(func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestDeployQueueHandler(t *testing.T) {
+	var s = &Server{webhookDeploys: newDeployQueue(func(api.QueuedDeploy) {})}
+
+	req, err := http.NewRequest("GET", "/webhook/queue", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.deployQueueHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var queue api.DeployQueue
+	_, err = api.Unmarshal(recorder.Body, api.KV{Key: "queue", Value: &queue})
+	assert.Nil(t, err)
+	assert.Nil(t, queue.Running)
+	assert.Empty(t, queue.Pending)
+}
@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+// watchReload reloads the daemon configuration whenever the daemon receives
+// a SIGHUP
+func (s *Server) watchReload() {
+	var signals = make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		println("received SIGHUP - reloading configuration")
+		if err := s.reloadConfig(os.Stdout); err != nil {
+			println("failed to reload configuration: " + err.Error())
+		}
+	}
+}
+
+// reloadConfig re-reads the daemon configuration and applies settings that can
+// be safely changed at runtime. Changed settings that require a restart are
+// reported and left as they are.
+func (s *Server) reloadConfig(out io.Writer) error {
+	conf, err := cfg.Load(s.state.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	// Apply settings that are safe to change at runtime
+	if conf.StatusCacheInterval != s.state.StatusCacheInterval {
+		fmt.Fprintf(out, "reloaded status cache interval: %s -> %s\n",
+			s.state.StatusCacheInterval, conf.StatusCacheInterval)
+		s.state.StatusCacheInterval = conf.StatusCacheInterval
+		s.status.setInterval(conf.StatusCacheInterval)
+	}
+	if conf.CompressHistory != s.state.CompressHistory {
+		fmt.Fprintf(out, "reloaded history compression: %t -> %t\n",
+			s.state.CompressHistory, conf.CompressHistory)
+		s.state.CompressHistory = conf.CompressHistory
+	}
+	if conf.MaskLogSecrets != s.state.MaskLogSecrets {
+		fmt.Fprintf(out, "reloaded log secret masking: %t -> %t\n",
+			s.state.MaskLogSecrets, conf.MaskLogSecrets)
+		s.state.MaskLogSecrets = conf.MaskLogSecrets
+	}
+	if conf.CleanupNetworks != s.state.CleanupNetworks {
+		fmt.Fprintf(out, "reloaded network cleanup: %t -> %t\n",
+			s.state.CleanupNetworks, conf.CleanupNetworks)
+		s.state.CleanupNetworks = conf.CleanupNetworks
+	}
+
+	// Report settings that can only be changed with a restart
+	for _, setting := range []struct{ key, current, reloaded string }{
+		{"INERTIA_PROJECT_DIR", s.state.ProjectDirectory, conf.ProjectDirectory},
+		{"INERTIA_DATA_DIR", s.state.DataDirectory, conf.DataDirectory},
+		{"INERTIA_SECRETS_DIR", s.state.SecretsDirectory, conf.SecretsDirectory},
+		{"INERTIA_DOCKERCOMPOSE", s.state.DockerComposeVersion, conf.DockerComposeVersion},
+		{"INERTIA_DOCKERCLI", s.state.DockerCLIVersion, conf.DockerCLIVersion},
+		{"INERTIA_HISTORY_LIMIT", fmt.Sprint(s.state.HistoryLimit), fmt.Sprint(conf.HistoryLimit)},
+		{"INERTIA_SESSION_TIMEOUT", s.state.SessionTimeout.String(), conf.SessionTimeout.String()},
+		{"INERTIA_REMEMBER_SESSION_TIMEOUT", s.state.RememberSessionTimeout.String(), conf.RememberSessionTimeout.String()},
+		{"INERTIA_RESET_TOKEN_TIMEOUT", s.state.ResetTokenTimeout.String(), conf.ResetTokenTimeout.String()},
+		{"INERTIA_PASSWORD_HASH_COST", fmt.Sprint(s.state.PasswordHashCost), fmt.Sprint(conf.PasswordHashCost)},
+		{"INERTIA_PASSWORD_PEPPER_VERSION", fmt.Sprint(s.state.PasswordPepperVersion), fmt.Sprint(conf.PasswordPepperVersion)},
+		{"INERTIA_PASSWORD_MIN_LENGTH", fmt.Sprint(s.state.PasswordMinLength), fmt.Sprint(conf.PasswordMinLength)},
+		{"INERTIA_PASSWORD_REQUIRE_DIGIT", fmt.Sprint(s.state.PasswordRequireDigit), fmt.Sprint(conf.PasswordRequireDigit)},
+		{"INERTIA_PASSWORD_REQUIRE_SYMBOL", fmt.Sprint(s.state.PasswordRequireSymbol), fmt.Sprint(conf.PasswordRequireSymbol)},
+		{"INERTIA_PASSWORD_REQUIRE_MIXED_CASE", fmt.Sprint(s.state.PasswordRequireMixedCase), fmt.Sprint(conf.PasswordRequireMixedCase)},
+		{"INERTIA_RESERVED_USERNAMES", fmt.Sprint(s.state.ReservedUsernames), fmt.Sprint(conf.ReservedUsernames)},
+		{"INERTIA_LOGIN_LOCKOUT_THRESHOLD", fmt.Sprint(s.state.LoginLockoutThreshold), fmt.Sprint(conf.LoginLockoutThreshold)},
+		{"INERTIA_LOGIN_LOCKOUT_COOLDOWN", s.state.LoginLockoutCooldown.String(), conf.LoginLockoutCooldown.String()},
+		{"INERTIA_LOGIN_BACKOFF", s.state.LoginBackoff.String(), conf.LoginBackoff.String()},
+		{"INERTIA_LOGIN_BACKOFF_CAP", s.state.LoginBackoffCap.String(), conf.LoginBackoffCap.String()},
+		{"INERTIA_RATE_LIMIT", fmt.Sprint(s.state.RateLimit), fmt.Sprint(conf.RateLimit)},
+		{"INERTIA_RATE_LIMIT_BURST", fmt.Sprint(s.state.RateLimitBurst), fmt.Sprint(conf.RateLimitBurst)},
+		{"INERTIA_RATE_LIMIT_EXEMPT_PUBLIC", fmt.Sprint(s.state.RateLimitExemptPublic), fmt.Sprint(conf.RateLimitExemptPublic)},
+		{"INERTIA_ALLOWED_CIDRS", fmt.Sprint(s.state.AllowedCIDRs), fmt.Sprint(conf.AllowedCIDRs)},
+		{"INERTIA_DENIED_CIDRS", fmt.Sprint(s.state.DeniedCIDRs), fmt.Sprint(conf.DeniedCIDRs)},
+		{"INERTIA_TRUSTED_PROXIES", fmt.Sprint(s.state.TrustedProxies), fmt.Sprint(conf.TrustedProxies)},
+		{"INERTIA_IP_FILTER_EXEMPT_PUBLIC", fmt.Sprint(s.state.IPFilterExemptPublic), fmt.Sprint(conf.IPFilterExemptPublic)},
+		{"INERTIA_SHUTDOWN_TIMEOUT", s.state.ShutdownTimeout.String(), conf.ShutdownTimeout.String()},
+		{"INERTIA_REQUEST_TIMEOUT", s.state.RequestTimeout.String(), conf.RequestTimeout.String()},
+		{"INERTIA_AUDIT_LOG", s.state.AuditLog, conf.AuditLog},
+		{"INERTIA_AUTH_REALM", s.state.AuthRealm, conf.AuthRealm},
+		{"INERTIA_AUTH_TOKEN_PARAM", s.state.AuthTokenParam, conf.AuthTokenParam},
+		{"INERTIA_TOKEN_SIGNING_KEY", s.state.TokenSigningKey, conf.TokenSigningKey},
+		{"INERTIA_PREVIOUS_TOKEN_SIGNING_KEY", s.state.PreviousTokenSigningKey, conf.PreviousTokenSigningKey},
+		{"INERTIA_TOKEN_KEY_OVERLAP", s.state.TokenKeyOverlap.String(), conf.TokenKeyOverlap.String()},
+		{"INERTIA_HSTS_MAX_AGE", s.state.HSTSMaxAge.String(), conf.HSTSMaxAge.String()},
+		{"INERTIA_HSTS_INCLUDE_SUBDOMAINS", fmt.Sprint(s.state.HSTSIncludeSubdomains), fmt.Sprint(conf.HSTSIncludeSubdomains)},
+		{"INERTIA_PLAINTEXT", s.state.Plaintext, conf.Plaintext},
+		{"INERTIA_COOKIE_DOMAIN", s.state.CookieDomain, conf.CookieDomain},
+		{"INERTIA_COOKIE_PATH", s.state.CookiePath, conf.CookiePath},
+		{"INERTIA_COOKIE_SAMESITE", s.state.CookieSameSite, conf.CookieSameSite},
+		{"INERTIA_COOKIE_INSECURE", fmt.Sprint(s.state.CookieInsecure), fmt.Sprint(conf.CookieInsecure)},
+		{"INERTIA_LOGIN_TOKEN", s.state.LoginToken, conf.LoginToken},
+		{"INERTIA_WEBHOOK_DEDUP_WINDOW", s.state.WebhookDedupWindow.String(), conf.WebhookDedupWindow.String()},
+	} {
+		if setting.current != setting.reloaded {
+			fmt.Fprintf(out, "%s changed (%s -> %s), but requires a restart to take effect\n",
+				setting.key, setting.current, setting.reloaded)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+)
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-reload")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "daemon.env")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`
+INERTIA_STATUS_CACHE_INTERVAL=30s
+INERTIA_MASK_LOG_SECRETS=true
+INERTIA_PROJECT_DIR=/new/project
+`), 0600))
+
+	var s = &Server{
+		state: cfg.Config{
+			ConfigFile:          path,
+			ProjectDirectory:    "/old/project",
+			StatusCacheInterval: time.Second,
+			WebhookSecret:       "secret",
+		},
+		status: statusCache{interval: time.Second},
+	}
+	var out = &bytes.Buffer{}
+	assert.Nil(t, s.reloadConfig(out))
+
+	// Runtime settings should be applied
+	assert.Equal(t, 30*time.Second, s.state.StatusCacheInterval)
+	assert.Equal(t, 30*time.Second, s.status.interval)
+	assert.True(t, s.state.MaskLogSecrets)
+	assert.Contains(t, out.String(), "status cache interval")
+
+	// Restart-only settings should be reported but not applied
+	assert.Equal(t, "/old/project", s.state.ProjectDirectory)
+	assert.Contains(t, out.String(), "INERTIA_PROJECT_DIR changed")
+
+	// Settings not from configuration should be preserved
+	assert.Equal(t, "secret", s.state.WebhookSecret)
+
+	// Malformed config files should leave settings unchanged
+	assert.Nil(t, ioutil.WriteFile(path, []byte("INERTIA_MASK_LOG_SECRETS\n"), 0600))
+	assert.NotNil(t, s.reloadConfig(out))
+	assert.True(t, s.state.MaskLogSecrets)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 )
@@ -12,7 +13,8 @@ import (
 // resetHandler shuts down and wipes the project directory
 func (s *Server) resetHandler(w http.ResponseWriter, r *http.Request) {
 	if s.deployment == nil {
-		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed))
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
 		return
 	}
 
@@ -29,5 +31,6 @@ func (s *Server) resetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.status.invalidate()
 	stream.Success(res.MsgOK("project removed"))
 }
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+// restartHandler restarts the project's containers, optionally one batch of
+// replicas at a time
+func (s *Server) restartHandler(w http.ResponseWriter, r *http.Request) {
+	var restartReq api.RestartRequest
+	if r.ContentLength != 0 {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&restartReq); err != nil {
+			render.Render(w, r, res.ErrBadRequest(err.Error()))
+			return
+		}
+	}
+	if restartReq.BatchSize < 0 {
+		render.Render(w, r, res.ErrBadRequest("batch size must be positive"))
+		return
+	}
+
+	if status, _ := s.deployment.GetStatus(s.docker); len(status.Containers) == 0 {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+
+	var stream = log.NewStreamer(log.StreamerOptions{
+		Request:    r,
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+		HTTPStream: restartReq.Stream,
+	})
+	defer stream.Close()
+
+	restarted, err := s.deployment.Restart(s.docker, stream, health.RestartOptions{
+		Rolling:   restartReq.Rolling,
+		BatchSize: restartReq.BatchSize,
+	})
+	s.status.invalidate()
+	if err != nil {
+		stream.Error(res.ErrInternalServer("failed to restart project", err,
+			"restarted", restarted))
+		return
+	}
+
+	stream.Success(res.MsgOK("project restarted",
+		"restarted", restarted))
+}
+
+// resumeHandler resumes automatic restarts of crash-looping containers
+func (s *Server) resumeHandler(w http.ResponseWriter, r *http.Request) {
+	var resumeReq api.ResumeRequest
+	if r.ContentLength != 0 {
+		defer r.Body.Close()
+		if err := json.NewDecoder(r.Body).Decode(&resumeReq); err != nil {
+			render.Render(w, r, res.ErrBadRequest(err.Error()))
+			return
+		}
+	}
+
+	resumed, err := s.deployment.Resume(resumeReq.Services)
+	if err != nil {
+		render.Render(w, r, res.Err(err.Error(), http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	s.status.invalidate()
+	if len(resumed) == 0 {
+		render.Render(w, r, res.ErrNotFound("no crash-looping containers found",
+			"services", resumeReq.Services))
+		return
+	}
+	render.Render(w, r, res.MsgOK("restarts resumed",
+		"resumed", resumed))
+}
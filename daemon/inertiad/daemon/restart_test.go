@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestRestartHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        api.RestartRequest
+		containers []string
+		restartErr error
+		wantCode   int
+	}{
+		{"no deployment", api.RestartRequest{}, []string{}, nil, http.StatusPreconditionFailed},
+		{"invalid batch size", api.RestartRequest{Rolling: true, BatchSize: -1}, []string{"/web"}, nil, http.StatusBadRequest},
+		{"restart", api.RestartRequest{}, []string{"/web"}, nil, http.StatusOK},
+		{"rolling restart", api.RestartRequest{Rolling: true, BatchSize: 2}, []string{"/web"}, nil, http.StatusOK},
+		{"restart failed", api.RestartRequest{Rolling: true}, []string{"/web"}, errors.New("oh no"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fakeDeployer = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return api.DeploymentStatus{Containers: tt.containers}, nil
+				},
+				RestartStub: func(cli *docker.Client, out io.Writer, opts health.RestartOptions) ([]string, error) {
+					assert.Equal(t, tt.req.Rolling, opts.Rolling)
+					assert.Equal(t, tt.req.BatchSize, opts.BatchSize)
+					return tt.containers, tt.restartErr
+				},
+			}
+			var s = &Server{deployment: fakeDeployer}
+
+			b, err := json.Marshal(tt.req)
+			assert.Nil(t, err)
+			req, err := http.NewRequest("POST", "/restart", bytes.NewReader(b))
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.restartHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
+
+func TestResumeHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       api.ResumeRequest
+		resumed   []string
+		resumeErr error
+		wantCode  int
+	}{
+		{"not active", api.ResumeRequest{}, nil, errors.New("project is not active"), http.StatusPreconditionFailed},
+		{"none crash-looping", api.ResumeRequest{Services: []string{"web"}}, []string{}, nil, http.StatusNotFound},
+		{"resume", api.ResumeRequest{}, []string{"/web"}, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fakeDeployer = &mocks.FakeDeployer{
+				ResumeStub: func(services []string) ([]string, error) {
+					assert.Equal(t, tt.req.Services, services)
+					return tt.resumed, tt.resumeErr
+				},
+			}
+			var s = &Server{deployment: fakeDeployer}
+
+			b, err := json.Marshal(tt.req)
+			assert.Nil(t, err)
+			req, err := http.NewRequest("POST", "/restart/resume", bytes.NewReader(b))
+			assert.Nil(t, err)
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.resumeHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+)
+
+const msgNoRollbackTarget = "no earlier successful deployment to roll back to"
+
+// rollbackHandler rebuilds and deploys the most recent successful deployment
+// of a commit other than the one currently checked out
+func (s *Server) rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	status, _ := s.deployment.GetStatus(s.docker)
+	if status.CommitHash == "" {
+		render.Render(w, r, res.Err(msgNoDeployment, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoDeployment))
+		return
+	}
+	if status.BuildContainerActive {
+		render.Render(w, r, res.Err("a deployment is already in progress", http.StatusConflict).
+			WithCode(api.ErrCodeDeployInProgress))
+		return
+	}
+
+	// Only successful deployments are recorded, so the last known-good commit
+	// is the most recently recorded one that is not currently checked out.
+	// Rollbacks themselves are skipped, as are commits that were rolled back
+	// from since they were deployed, so that rolling back again moves further
+	// back rather than returning to a bad commit.
+	manager, found := s.deployment.GetDataManager()
+	if !found {
+		render.Render(w, r, res.Err(msgNoRollbackTarget, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoRollbackTarget))
+		return
+	}
+	records, err := manager.GetDeployHistory()
+	if err != nil {
+		render.Render(w, r, res.ErrInternalServer("failed to retrieve deployment history", err))
+		return
+	}
+	var (
+		target     *api.DeployRecord
+		rolledBack = map[string]bool{}
+	)
+	for i := range records {
+		var record = records[i]
+		if record.RolledBackFrom != "" {
+			rolledBack[record.RolledBackFrom] = true
+			continue
+		}
+		if record.CommitHash != "" && record.CommitHash != status.CommitHash &&
+			!rolledBack[record.CommitHash] {
+			target = &records[i]
+			break
+		}
+	}
+	if target == nil {
+		render.Render(w, r, res.Err(msgNoRollbackTarget, http.StatusPreconditionFailed).
+			WithCode(api.ErrCodeNoRollbackTarget))
+		return
+	}
+
+	var stream = log.NewStreamer(log.StreamerOptions{
+		Request:    r,
+		Stdout:     os.Stdout,
+		HTTPWriter: w,
+	})
+	defer stream.Close()
+	stream.Println("Rolling back from " + status.CommitHash + " to " + target.CommitHash +
+		" (deploy " + target.ID + ")")
+
+	if errRes := s.runDeploy(stream, "rollback", s.deployment.GetBranch(), project.DeployOptions{
+		Message:        "rollback to deploy " + target.ID,
+		Commit:         target.CommitHash,
+		RolledBackFrom: status.CommitHash,
+	}); errRes != nil {
+		stream.Error(errRes)
+		return
+	}
+
+	stream.Success(res.Msg("Rollback startup initiated!", http.StatusCreated,
+		"commit", target.CommitHash,
+		"rolled_back_from", status.CommitHash))
+}
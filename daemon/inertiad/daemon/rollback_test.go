@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestRollbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		history    []string
+		current    string
+		wantCode   int
+		wantCommit string
+	}{
+		{"rolls back to previous commit", []string{"first", "second"}, "second", http.StatusCreated, "first"},
+		{"rolls back after failed deploy", []string{"first", "second"}, "third", http.StatusCreated, "second"},
+		{"skips redeploys of current commit", []string{"first", "second", "second"}, "second", http.StatusCreated, "first"},
+		{"no history", nil, "first", http.StatusPreconditionFailed, ""},
+		{"no other commits", []string{"first"}, "first", http.StatusPreconditionFailed, ""},
+		{"no deployment", nil, "", http.StatusPreconditionFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-rollback")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			manager, err := project.NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+			assert.Nil(t, err)
+			for _, commit := range tt.history {
+				assert.Nil(t, manager.AddDeployRecord(&api.DeployRecord{CommitHash: commit}, 0))
+			}
+
+			var fakeDeployer = &mocks.FakeDeployer{}
+			fakeDeployer.GetDataManagerReturns(manager, true)
+			fakeDeployer.GetStatusReturns(api.DeploymentStatus{CommitHash: tt.current}, nil)
+			fakeDeployer.DeployReturns(func() error { return nil }, nil)
+			var s = &Server{deployment: fakeDeployer}
+
+			req, err := http.NewRequest("POST", "/deploy/rollback", nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.rollbackHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantCommit == "" {
+				assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+				return
+			}
+			assert.Equal(t, 1, fakeDeployer.DeployCallCount())
+			_, _, opts := fakeDeployer.DeployArgsForCall(0)
+			assert.Equal(t, tt.wantCommit, opts.Commit)
+			assert.Equal(t, tt.current, opts.RolledBackFrom)
+
+			var commit string
+			_, err = api.Unmarshal(recorder.Body, api.KV{Key: "commit", Value: &commit})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantCommit, commit)
+		})
+	}
+}
+
+func TestRollbackHandler_repeated(t *testing.T) {
+	tests := []struct {
+		name       string
+		history    []api.DeployRecord
+		current    string
+		wantCode   int
+		wantCommit string
+	}{
+		{"rolls back further", []api.DeployRecord{
+			{CommitHash: "first"}, {CommitHash: "second"}, {CommitHash: "third"},
+			{CommitHash: "second", RolledBackFrom: "third"},
+		}, "second", http.StatusCreated, "first"},
+		{"redeployed commits can be rolled back to", []api.DeployRecord{
+			{CommitHash: "first"}, {CommitHash: "second"},
+			{CommitHash: "first", RolledBackFrom: "second"},
+			{CommitHash: "second"}, {CommitHash: "third"},
+		}, "third", http.StatusCreated, "second"},
+		{"everything rolled back", []api.DeployRecord{
+			{CommitHash: "first"}, {CommitHash: "second"},
+			{CommitHash: "first", RolledBackFrom: "second"},
+		}, "first", http.StatusPreconditionFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "inertia-rollback")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			manager, err := project.NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+			assert.Nil(t, err)
+			for i := range tt.history {
+				assert.Nil(t, manager.AddDeployRecord(&tt.history[i], 0))
+			}
+
+			var fakeDeployer = &mocks.FakeDeployer{}
+			fakeDeployer.GetDataManagerReturns(manager, true)
+			fakeDeployer.GetStatusReturns(api.DeploymentStatus{CommitHash: tt.current}, nil)
+			fakeDeployer.DeployReturns(func() error { return nil }, nil)
+			var s = &Server{deployment: fakeDeployer}
+
+			req, err := http.NewRequest("POST", "/deploy/rollback", nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.rollbackHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantCommit == "" {
+				assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+				return
+			}
+			assert.Equal(t, 1, fakeDeployer.DeployCallCount())
+			_, _, opts := fakeDeployer.DeployArgsForCall(0)
+			assert.Equal(t, tt.wantCommit, opts.Commit)
+		})
+	}
+}
+
+func TestRollbackHandler_noHistory(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetStatusReturns(api.DeploymentStatus{CommitHash: "first"}, nil)
+	var s = &Server{deployment: fakeDeployer}
+
+	req, err := http.NewRequest("POST", "/deploy/rollback", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.rollbackHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+
+	b, err := api.Unmarshal(recorder.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeNoRollbackTarget, b.ErrCode)
+	assert.Equal(t, msgNoRollbackTarget, b.Message)
+	assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+}
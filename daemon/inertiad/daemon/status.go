@@ -9,10 +9,14 @@ import (
 )
 
 // statusHandler returns a formatted string about the status of the
-// deployment and lists currently active project containers
+// deployment and lists currently active project containers. Statuses are
+// served from a cache that is refreshed at most once per configured interval.
 func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
-	status, err := s.deployment.GetStatus(s.docker)
+	status, err := s.status.get(s.deployment, s.docker)
 	status.InertiaVersion = s.version
+	if project := s.deployment.GetProject(); project != "" {
+		status.WebhookPath = projectWebhookPath(project)
+	}
 	if status.CommitHash == "" {
 		status.Containers = make([]string, 0)
 		render.Render(w, r, res.MsgOK("status retrieved",
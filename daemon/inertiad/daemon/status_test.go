@@ -24,6 +24,7 @@ func TestStatusHandlerBuildInProgress(t *testing.T) {
 					BuildContainerActive: true,
 				}, nil
 			},
+			GetProjectStub: func() string { return "my-project" },
 		},
 	}
 
@@ -37,6 +38,7 @@ func TestStatusHandlerBuildInProgress(t *testing.T) {
 
 	handler.ServeHTTP(recorder, req)
 	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Contains(t, recorder.Body.String(), "/projects/my-project/webhook")
 }
 
 func TestStatusHandlerNoContainers(t *testing.T) {
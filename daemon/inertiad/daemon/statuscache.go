@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
+)
+
+// statusCache serves deployment statuses from memory so that many clients
+// polling for status do not each query Docker. The zero value is usable, and
+// refreshes on every request.
+type statusCache struct {
+	interval time.Duration
+
+	mux      sync.Mutex
+	status   api.DeploymentStatus
+	err      error
+	cachedAt time.Time
+}
+
+// get returns the cached status, retrieving a new one from the deployment if
+// the cache is older than the configured interval. Concurrent callers wait on
+// a single refresh.
+func (c *statusCache) get(d project.Deployer, cli *docker.Client) (api.DeploymentStatus, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var now = time.Now()
+	if c.cachedAt.IsZero() || now.Sub(c.cachedAt) >= c.interval {
+		c.status, c.err = d.GetStatus(cli)
+		c.cachedAt = now
+		c.status.CachedAt = now
+	}
+	return c.status, c.err
+}
+
+// setInterval updates the configured cache interval
+func (c *statusCache) setInterval(interval time.Duration) {
+	c.mux.Lock()
+	c.interval = interval
+	c.mux.Unlock()
+}
+
+// invalidate forces the next request to retrieve a new status
+func (c *statusCache) invalidate() {
+	c.mux.Lock()
+	c.cachedAt = time.Time{}
+	c.mux.Unlock()
+}
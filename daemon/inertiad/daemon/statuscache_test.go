@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestStatusCache(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{CommitHash: "abcde"}, nil
+		},
+	}
+	var cache = &statusCache{interval: time.Hour}
+
+	// Concurrent requests should only retrieve status once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := cache.get(fakeDeployer, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "abcde", status.CommitHash)
+			assert.False(t, status.CachedAt.IsZero())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, fakeDeployer.GetStatusCallCount())
+
+	// Invalidated cache should retrieve status again
+	cache.invalidate()
+	cache.get(fakeDeployer, nil)
+	assert.Equal(t, 2, fakeDeployer.GetStatusCallCount())
+}
+
+func TestStatusCacheNoInterval(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	var cache = &statusCache{}
+	cache.get(fakeDeployer, nil)
+	cache.get(fakeDeployer, nil)
+	assert.Equal(t, 2, fakeDeployer.GetStatusCallCount())
+}
@@ -2,21 +2,36 @@ package daemon
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/render"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 )
 
-// tokenHandler generates a new token
+// tokenHandler generates a new token, restricted to the comma-separated scopes
+// in the "scopes" query if any are provided
 func tokenHandler(w http.ResponseWriter, r *http.Request) {
+	var scopes []string
+	if q := r.URL.Query().Get("scopes"); q != "" {
+		scopes = strings.Split(q, ",")
+		for _, scope := range scopes {
+			if !isValidScope(scope) {
+				render.Render(w, r, res.ErrBadRequest("invalid scope",
+					"scope", scope))
+				return
+			}
+		}
+	}
+
 	keyBytes, err := crypto.GetAPIPrivateKey(nil)
 	if err != nil {
 		render.Render(w, r, res.ErrInternalServer("failed to get signing key", err))
 		return
 	}
 
-	token, err := crypto.GenerateMasterToken(keyBytes.([]byte))
+	token, err := crypto.GenerateScopedToken(keyBytes.([]byte), scopes...)
 	if err != nil {
 		render.Render(w, r, res.ErrInternalServer("failed to generate token", err))
 		return
@@ -25,3 +40,12 @@ func tokenHandler(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, res.MsgOK("token generated",
 		"token", token))
 }
+
+func isValidScope(scope string) bool {
+	switch scope {
+	case api.ScopeDeploy, api.ScopeLogs, api.ScopeUsers:
+		return true
+	default:
+		return false
+	}
+}
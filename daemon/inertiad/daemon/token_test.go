@@ -33,3 +33,27 @@ func TestTokenHandler(t *testing.T) {
 	assert.Equal(t, generatedTestToken, token)
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
+
+func TestTokenHandler_scopes(t *testing.T) {
+	var testInertiaKeyPath = path.Join(os.Getenv("GOPATH"), "/src/github.com/ubclaunchpad/inertia/test/keys/id_rsa")
+	os.Setenv("INERTIA_GH_KEY_PATH", testInertiaKeyPath)
+	defer os.Setenv("INERTIA_GH_KEY_PATH", "")
+
+	tests := []struct {
+		name     string
+		scopes   string
+		wantCode int
+	}{
+		{"valid scopes", "deploy,logs", http.StatusOK},
+		{"invalid scope", "deploy,everything", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/token?scopes="+tt.scopes, nil)
+			assert.Nil(t, err)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(tokenHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+		})
+	}
+}
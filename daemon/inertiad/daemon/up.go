@@ -8,8 +8,12 @@ import (
 
 	"github.com/go-chi/render"
 	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/log"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/notify"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/project"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
 )
@@ -28,15 +32,69 @@ func (s *Server) upHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var gitOpts = upReq.GitOptions
+	healthChecks, err := health.ParseConfigs(upReq.HealthChecks)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest("invalid health check configuration",
+			"error", err).WithCode(api.ErrCodeInvalidConfig))
+		return
+	}
+	if err = project.ValidateFeatureFlags(upReq.FeatureFlags); err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()).
+			WithCode(api.ErrCodeInvalidConfig))
+		return
+	}
+	channels, err := notify.ParseChannelConfigs(upReq.Notifications)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest("invalid notification configuration",
+			"error", err).WithCode(api.ErrCodeInvalidConfig))
+		return
+	}
+	if upReq.BuildParallelism < 0 {
+		render.Render(w, r, res.ErrBadRequest("build parallelism cannot be negative").
+			WithCode(api.ErrCodeInvalidConfig))
+		return
+	}
+	allowedRefs, err := git.NewRefFilter(upReq.AllowedRefs)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest(err.Error()).
+			WithCode(api.ErrCodeInvalidConfig))
+		return
+	}
+	if !allowedRefs.Allows(gitOpts.Branch) {
+		render.Render(w, r, res.ErrForbidden("branch '"+gitOpts.Branch+"' is not allowed to be deployed to this remote",
+			"allowed_refs", upReq.AllowedRefs).WithCode(api.ErrCodeRefNotAllowed))
+		return
+	}
+
+	// refuse to reconfigure the project while a build is underway
+	if status, _ := s.deployment.GetStatus(s.docker); status.BuildContainerActive {
+		render.Render(w, r, res.Err("a deployment is already in progress", http.StatusConflict).
+			WithCode(api.ErrCodeDeployInProgress))
+		return
+	}
 
 	// apply configuration updates
 	s.state.WebhookSecret = upReq.WebHookSecret
+	s.setAllowedRefs(allowedRefs)
+	s.setWarmStandby(upReq.WarmStandby)
+	for i := range channels {
+		channels[i].Secret = upReq.WebHookSecret
+	}
+	s.notifier.SetChannels(channels)
 	s.deployment.SetConfig(project.DeploymentConfig{
 		ProjectName:   upReq.Project,
 		BuildType:     upReq.BuildType,
 		BuildFilePath: upReq.BuildFilePath,
 		RemoteURL:     gitOpts.RemoteURL,
 		Branch:        gitOpts.Branch,
+		HealthChecks:  healthChecks,
+		HistoryLimit:  upReq.HistoryLimit,
+		Networks:      upReq.Networks,
+		BuildSecrets:  upReq.BuildSecrets,
+		BuildPlan: &build.Plan{
+			Groups:      upReq.BuildOrder,
+			Parallelism: upReq.BuildParallelism,
+		},
 	})
 
 	// Configure streamer
@@ -61,7 +119,7 @@ func (s *Server) upHandler(w http.ResponseWriter, r *http.Request) {
 				Branch:        gitOpts.Branch,
 				PemFilePath:   crypto.DaemonGithubKeyLocation,
 			},
-			stream,
+			s.deployOutput(stream),
 		); err != nil {
 			stream.Error(res.Err(err.Error(), http.StatusPreconditionFailed))
 			return
@@ -84,18 +142,16 @@ func (s *Server) upHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Deploy project
-	deploy, err := s.deployment.Deploy(s.docker, stream, project.DeployOptions{
-		SkipUpdate: skipUpdate,
-	})
-	if err != nil {
-		stream.Error(res.ErrInternalServer("failed to build project", err))
-		return
-	}
-
-	if err = deploy(); err != nil {
-		stream.Error(res.ErrInternalServer("failed to deploy project", err))
+	if errRes := s.runDeploy(stream, "up", gitOpts.Branch, project.DeployOptions{
+		SkipUpdate:   skipUpdate,
+		Message:      upReq.Message,
+		FeatureFlags: upReq.FeatureFlags,
+	}); errRes != nil {
+		stream.Error(errRes)
 		return
 	}
 
-	stream.Success(res.Msg("Project startup initiated!", http.StatusCreated))
+	status, _ := s.status.get(s.deployment, s.docker)
+	stream.Success(res.Msg("Project startup initiated!", http.StatusCreated,
+		"deploy_message", status.DeployMessage))
 }
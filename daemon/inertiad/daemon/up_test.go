@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestUpHandlerDeployInProgress(t *testing.T) {
+	var fake = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{BuildContainerActive: true}, nil
+		},
+	}
+	var s = &Server{deployment: fake}
+
+	body, err := json.Marshal(api.UpRequest{Project: "wow"})
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", "/up", bytes.NewReader(body))
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.upHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	b, err := api.Unmarshal(recorder.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeDeployInProgress, b.ErrCode)
+	assert.Equal(t, 0, fake.SetConfigCallCount())
+}
+
+func TestUpHandlerRefNotAllowed(t *testing.T) {
+	var fake = &mocks.FakeDeployer{}
+	var s = &Server{deployment: fake}
+
+	body, err := json.Marshal(api.UpRequest{
+		Project:     "wow",
+		GitOptions:  api.GitOptions{Branch: "feature"},
+		AllowedRefs: []string{"main", "refs/tags/*"},
+	})
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", "/up", bytes.NewReader(body))
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.upHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	b, err := api.Unmarshal(recorder.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeRefNotAllowed, b.ErrCode)
+	assert.Contains(t, b.Message, "feature")
+	assert.Equal(t, 0, fake.SetConfigCallCount())
+}
+
+func TestUpHandlerInvalidConfig(t *testing.T) {
+	var s = &Server{deployment: &mocks.FakeDeployer{}}
+
+	body, err := json.Marshal(api.UpRequest{Project: "wow", BuildParallelism: -1})
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", "/up", bytes.NewReader(body))
+	assert.Nil(t, err)
+
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.upHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	b, err := api.Unmarshal(recorder.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, api.ErrCodeInvalidConfig, b.ErrCode)
+}
@@ -1,11 +1,15 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/common"
@@ -15,9 +19,11 @@ import (
 )
 
 // webhookHandler receives and parses Git-based webhooks
-// Supported vendors: Github, Gitlab, Bitbucket
+// Supported vendors: Github, Gitlab, Bitbucket, and generic signed webhooks
 // Supported events: push
 func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	s.metrics.observeWebhook()
+
 	// read
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -37,7 +43,23 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	if err := webhook.Verify(host, s.state.WebhookSecret, r.Header, body); err != nil {
 		msg := "unable to verify payload: " + err.Error()
 		println(msg)
-		render.Render(w, r, res.ErrBadRequest(msg))
+		s.notifySecurityEvent("rejected " + host + " webhook: " + err.Error())
+		// Generic webhooks are authenticated only by their signature, so
+		// they are rejected as unauthorized - git hosts have always been
+		// answered with a bad request
+		if host == webhook.Generic {
+			render.Render(w, r, res.ErrUnauthorized(msg))
+		} else {
+			render.Render(w, r, res.ErrBadRequest(msg))
+		}
+		return
+	}
+
+	// ignore repeated deliveries of the same event
+	if id := webhook.DeliveryID(host, r.Header); s.deliveries.Seen(id) {
+		fmt.Printf("Ignoring duplicate %s webhook delivery %s\n", host, id)
+		render.Render(w, r, res.MsgOK("duplicate delivery ignored",
+			"delivery", id))
 		return
 	}
 
@@ -57,8 +79,22 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, res.Msg(api.MsgDaemonOK, http.StatusAccepted))
 		return
 	case webhook.PushEvent:
+		fmt.Printf("Received %s push event: %s (%s)\n",
+			payload.GetSource(), payload.GetRepoName(), payload.GetRef())
+		branch, err := s.matchPushEvent(payload)
+		if err != nil {
+			fmt.Println("Ignoring event: " + err.Error())
+			if _, ok := err.(*ignoredRefError); ok {
+				render.Render(w, r, res.MsgOK("branch ignored",
+					"branch", branch,
+					"reason", err.Error()))
+			} else {
+				render.Render(w, r, res.Msg(api.MsgDaemonOK, http.StatusAccepted))
+			}
+			return
+		}
 		render.Render(w, r, res.Msg(api.MsgDaemonOK, http.StatusAccepted))
-		processPushEvent(s, payload)
+		processPushEvent(s, payload, branch)
 	// case webhook.PullEvent:
 	//	fmt.Fprint(w, common.MsgDaemonOK)
 	// 	processPullRequestEvent(payload)
@@ -69,6 +105,25 @@ func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// projectWebhookHandler receives Git-based webhooks addressed to a specific
+// project, so that a repository's webhook only ever deploys that project.
+// Verification and branch matching use the addressed project's configuration.
+func (s *Server) projectWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var name = chi.URLParam(r, "project")
+	if current := s.deployment.GetProject(); current == "" || current != name {
+		render.Render(w, r, res.ErrNotFound("project not found",
+			"project", name))
+		return
+	}
+	s.webhookHandler(w, r)
+}
+
+// projectWebhookPath returns the path at which the given project receives
+// webhooks
+func projectWebhookPath(project string) string {
+	return "/projects/" + url.PathEscape(project) + "/webhook"
+}
+
 // specialized handler for docker webhooks
 func dockerWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	p, err := webhook.ParseDocker(r)
@@ -80,42 +135,77 @@ func dockerWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("Received dockerhub webhook event: %s:%s\n", p.GetRepoName(), p.GetTag())
 }
 
-// processPushEvent prints information about the given PushEvent.
-func processPushEvent(s *Server, p webhook.Payload) {
-	fmt.Printf("Received %s push event: %s (%s)\n",
-		p.GetSource(), p.GetRepoName(), p.GetRef())
-
-	// Ignore event if repository not set up yet, otherwise
-	// let deploy() handle the update.
-	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
-		fmt.Println(msgNoDeployment)
+// processPushEvent queues a deployment, or pre-warms a build, for the given
+// PushEvent, which must already match the deployment's branch
+func processPushEvent(s *Server, p webhook.Payload, branch string) {
+	// In warm standby, only build the pushed commit - it is deployed by the
+	// next explicit deployment
+	if s.isWarmStandby() {
+		fmt.Printf("Pre-warming event: event branch %s matches deployed branch %s\n",
+			branch, s.deployment.GetBranch())
+		go s.prewarmPush(branch)
 		return
 	}
 
-	// Check for matching remotes
-	if err := s.deployment.CompareRemotes(p.GetSSHURL()); err != nil {
-		fmt.Println(err.Error())
-		return
+	// If branches match, queue a deploy
+	fmt.Printf("Accepting event: event branch %s matches deployed branch %s\n",
+		branch, s.deployment.GetBranch())
+	s.progress.queue(p.GetSource(), branch)
+	var superseded = s.webhookDeploys.push(api.QueuedDeploy{
+		Branch:     branch,
+		Ref:        p.GetRef(),
+		Source:     p.GetSource(),
+		ReceivedAt: time.Now(),
+	})
+	if superseded != nil {
+		fmt.Printf("Skipping queued deploy of branch %s received at %s: superseded by newer push\n",
+			superseded.Branch, superseded.ReceivedAt.Format(time.RFC3339))
 	}
+}
+
+// ignoredRefError indicates a push event for a branch or ref that this remote
+// does not deploy
+type ignoredRefError struct{ reason string }
 
-	// Check for matching branch
+func (e *ignoredRefError) Error() string { return e.reason }
+
+// matchPushEvent checks that the given push event is for the current
+// deployment's repository and branch, and returns the event's branch. Events
+// for refs that are not allowed, or for other branches, are rejected with an
+// ignoredRefError.
+func (s *Server) matchPushEvent(p webhook.Payload) (string, error) {
+	if status, _ := s.deployment.GetStatus(s.docker); status.CommitHash == "" {
+		return "", errors.New(msgNoDeployment)
+	}
+	if err := s.deployment.CompareRemotes(p.GetSSHURL()); err != nil {
+		return "", err
+	}
 	var branch = common.GetBranchFromRef(p.GetRef())
+	if !s.getAllowedRefs().Allows(p.GetRef()) {
+		return branch, &ignoredRefError{fmt.Sprintf("ref %s is not allowed to be deployed", p.GetRef())}
+	}
 	if s.deployment.GetBranch() != branch {
-		fmt.Printf("Ignoring event: event branch %s does not match deployed branch %s\n",
-			branch, s.deployment.GetBranch())
-		return
+		return branch, &ignoredRefError{fmt.Sprintf("event branch %s does not match deployed branch %s",
+			branch, s.deployment.GetBranch())}
 	}
+	return branch, nil
+}
 
-	// If branches match, deploy
-	fmt.Printf("Accepting event: event branch %s matches deployed branch %s\n",
-		branch, s.deployment.GetBranch())
-	deploy, err := s.deployment.Deploy(s.docker, os.Stdout, project.DeployOptions{})
+// prewarmPush pre-warms the project's image for a push event
+func (s *Server) prewarmPush(branch string) {
+	commit, err := s.deployment.Prewarm(s.docker, s.deployOutput(os.Stdout))
 	if err != nil {
-		fmt.Println("Build failed: " + err.Error())
+		fmt.Println("Pre-warm failed: " + err.Error())
 		return
 	}
+	fmt.Printf("Pre-warmed branch %s at commit %s\n", branch, commit)
+}
 
-	if err = deploy(); err != nil {
-		fmt.Println("Deploy failed: " + err.Error())
+// deployPush deploys the project for a queued push event
+func (s *Server) deployPush(d api.QueuedDeploy) {
+	fmt.Printf("Deploying branch %s from %s push received at %s\n",
+		d.Branch, d.Source, d.ReceivedAt.Format(time.RFC3339))
+	if errRes := s.runDeploy(os.Stdout, d.Source, d.Branch, project.DeployOptions{}); errRes != nil {
+		fmt.Println(errRes.Error().Error())
 	}
 }
@@ -2,14 +2,27 @@ package daemon
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	docker "github.com/docker/docker/client"
+	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
 )
 
 const (
@@ -74,6 +87,222 @@ func Test_webhookHandler(t *testing.T) {
 	}
 }
 
+func Test_webhookHandler_generic(t *testing.T) {
+	var sign = func(body string) string {
+		mac := hmac.New(sha256.New, []byte(testKey))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	const pushBody = `{"ref": "refs/heads/master"}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		wantCode  int
+		wantErr   string
+	}{
+		{"valid signature", pushBody, sign(pushBody), http.StatusAccepted, ""},
+		{"tampered body", `{"ref": "refs/heads/evil"}`, sign(pushBody), http.StatusUnauthorized,
+			"payload signature check failed"},
+		{"missing signature", pushBody, "", http.StatusUnauthorized, "missing signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Server{
+				state:      cfg.Config{WebhookSecret: testKey},
+				deployment: &mocks.FakeDeployer{},
+			}
+			req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(tt.body))
+			req.Header.Set("content-type", "application/json")
+			req.Header.Set("X-Inertia-Event", "push")
+			if tt.signature != "" {
+				req.Header.Set("X-Inertia-Signature", tt.signature)
+			}
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.webhookHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantErr)
+		})
+	}
+}
+
+func Test_webhookHandler_duplicates(t *testing.T) {
+	const pushBody = `{"ref": "refs/heads/master"}`
+	mac := hmac.New(sha256.New, []byte(testKey))
+	mac.Write([]byte(pushBody))
+	var signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name          string
+		deliveries    []string
+		wantCodes     []int
+		wantDuplicate []bool
+	}{
+		{"same delivery twice", []string{"1234", "1234"},
+			[]int{http.StatusAccepted, http.StatusOK}, []bool{false, true}},
+		{"distinct deliveries", []string{"1234", "5678"},
+			[]int{http.StatusAccepted, http.StatusAccepted}, []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &Server{
+				state:      cfg.Config{WebhookSecret: testKey},
+				deployment: &mocks.FakeDeployer{},
+				deliveries: newDeliveryCache(time.Minute, maxDedupDeliveries),
+			}
+			for i, id := range tt.deliveries {
+				req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(pushBody))
+				req.Header.Set("content-type", "application/json")
+				req.Header.Set("X-Inertia-Event", "push")
+				req.Header.Set("X-Inertia-Signature", signature)
+				req.Header.Set("X-Inertia-Delivery", id)
+
+				recorder := httptest.NewRecorder()
+				http.HandlerFunc(s.webhookHandler).ServeHTTP(recorder, req)
+				assert.Equal(t, tt.wantCodes[i], recorder.Code)
+				assert.Equal(t, tt.wantDuplicate[i],
+					strings.Contains(recorder.Body.String(), "duplicate delivery ignored"))
+			}
+		})
+	}
+}
+
+func Test_webhookHandler_branches(t *testing.T) {
+	const (
+		githubBody = `{
+			"ref": "refs/heads/%s",
+			"repository": {
+				"name": "inertia",
+				"clone_url": "https://github.com/ubclaunchpad/inertia.git",
+				"ssh_url": "git@github.com:ubclaunchpad/inertia.git"
+			}
+		}`
+		gitlabBody = `{
+			"ref": "refs/heads/%s",
+			"repository": {
+				"name": "inertia",
+				"git_http_url": "https://gitlab.com/ubclaunchpad/inertia.git",
+				"git_ssh_url": "git@gitlab.com:ubclaunchpad/inertia.git"
+			}
+		}`
+	)
+	var github = func(branch string) *http.Request {
+		var body = fmt.Sprintf(githubBody, branch)
+		mac := hmac.New(sha1.New, []byte(testKey))
+		mac.Write([]byte(body))
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewBufferString(body))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+		return req
+	}
+	var gitlab = func(branch string) *http.Request {
+		req := httptest.NewRequest("POST", "/webhook",
+			bytes.NewBufferString(fmt.Sprintf(gitlabBody, branch)))
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", testKey)
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		allowed    []string
+		wantCode   int
+		wantDeploy bool
+		wantReason string
+	}{
+		{"github matching branch", github("main"), nil, http.StatusAccepted, true, ""},
+		{"gitlab matching branch", gitlab("main"), nil, http.StatusAccepted, true, ""},
+		{"github other branch", github("dev"), nil, http.StatusOK, false,
+			"event branch dev does not match deployed branch main"},
+		{"gitlab other branch", gitlab("dev"), nil, http.StatusOK, false,
+			"event branch dev does not match deployed branch main"},
+		{"branch not allowed", github("main"), []string{"release/*"}, http.StatusOK, false,
+			"ref refs/heads/main is not allowed to be deployed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fakeDeployer = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return api.DeploymentStatus{CommitHash: "abcde"}, nil
+				},
+			}
+			fakeDeployer.GetBranchReturns("main")
+			allowedRefs, err := git.NewRefFilter(tt.allowed)
+			assert.Nil(t, err)
+			var deployed = make(chan api.QueuedDeploy, 1)
+			var s = &Server{
+				state:       cfg.Config{WebhookSecret: testKey},
+				deployment:  fakeDeployer,
+				allowedRefs: allowedRefs,
+			}
+			s.webhookDeploys = newDeployQueue(func(d api.QueuedDeploy) { deployed <- d })
+
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.webhookHandler).ServeHTTP(recorder, tt.req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+
+			if tt.wantDeploy {
+				select {
+				case d := <-deployed:
+					assert.Equal(t, "main", d.Branch)
+					assert.Equal(t, "refs/heads/main", d.Ref)
+				case <-time.After(time.Second):
+					t.Error("expected a deploy to be queued")
+				}
+				return
+			}
+			assert.Contains(t, recorder.Body.String(), "branch ignored")
+			assert.Contains(t, recorder.Body.String(), tt.wantReason)
+			select {
+			case <-deployed:
+				t.Error("expected no deploy to be queued")
+			default:
+			}
+		})
+	}
+}
+
+func Test_projectWebhookHandler(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetProjectReturns("my-project")
+	var s = &Server{
+		state:      cfg.Config{WebhookSecret: testKey},
+		deployment: fakeDeployer,
+	}
+	var router = chi.NewRouter()
+	router.HandleFunc("/projects/{project}/webhook", s.projectWebhookHandler)
+
+	tests := []struct {
+		name     string
+		project  string
+		wantCode int
+		wantErr  string
+	}{
+		{"matching project", "my-project", http.StatusBadRequest, "missing signature"},
+		{"other project", "other-project", http.StatusNotFound, "project not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := getTestWebhookEvent(map[string]string{
+				"content-type":   "application/json",
+				"User-Agent":     "GitHub-Hookshot/539d755",
+				"X-GitHub-Event": "push",
+			})
+			req.URL.Path = projectWebhookPath(tt.project)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.wantErr)
+		})
+	}
+}
+
 func getTestWebhookEvent(headers map[string]string) *http.Request {
 	buf := bytes.NewBufferString(testBody)
 	req, err := http.NewRequest("POST", "http://127.0.0.1/webhook", buf)
@@ -86,3 +315,27 @@ func getTestWebhookEvent(headers map[string]string) *http.Request {
 	}
 	return req
 }
+
+func TestPushConfigConcurrent(t *testing.T) {
+	var s = &Server{}
+	main, err := git.NewRefFilter([]string{"main"})
+	assert.Nil(t, err)
+
+	// up requests configure refs and warm standby while webhooks are being
+	// matched against them
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			s.setAllowedRefs(main)
+			s.setWarmStandby(i%2 == 0)
+			s.setAllowedRefs(nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.True(t, s.getAllowedRefs().Allows("refs/heads/main"))
+		s.isWarmStandby()
+	}
+	wg.Wait()
+}
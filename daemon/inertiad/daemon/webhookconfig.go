@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/common"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/res"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/webhook"
+)
+
+// webhookConfigHandler describes the URLs at which the daemon receives
+// webhooks, and what each supported git host must send
+func (s *Server) webhookConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		base   = "https://" + r.Host
+		config = api.WebhookConfig{
+			URLs:      []string{base + "/webhook"},
+			Project:   s.deployment.GetProject(),
+			Branch:    s.deployment.GetBranch(),
+			SecretSet: s.state.WebhookSecret != "",
+
+			AllowedRefs: s.getAllowedRefs().Patterns(),
+			WarmStandby: s.isWarmStandby(),
+		}
+	)
+	if config.Project != "" {
+		config.URLs = append(config.URLs, base+projectWebhookPath(config.Project))
+	}
+	for _, p := range webhook.Providers {
+		config.Providers = append(config.Providers, api.WebhookProvider{
+			Name:            p.Name,
+			EventHeader:     p.EventHeader,
+			PushEvent:       p.PushEvent,
+			SignatureHeader: p.SignatureHeader,
+			Signature:       p.Signature,
+			ContentTypes:    p.ContentTypes,
+		})
+	}
+	render.Render(w, r, res.MsgOK("webhook configuration retrieved",
+		"webhook", config))
+}
+
+// webhookTestHandler runs a webhook payload, sent with the same headers a git
+// host would use, through verification and parsing, and reports whether it
+// would have triggered a deployment. No deployment is ever triggered.
+func (s *Server) webhookTestHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		render.Render(w, r, res.ErrBadRequest("unable to read payload: "+err.Error()))
+		return
+	}
+	defer r.Body.Close()
+
+	var result api.WebhookTestResult
+	result.Provider, result.Event = webhook.Type(r.Header)
+	if result.Provider == "" {
+		render.Render(w, r, res.ErrBadRequest("unable to determine webhook provider from headers"))
+		return
+	}
+
+	s.testWebhook(&result, r.Header, body)
+	render.Render(w, r, res.MsgOK("webhook test completed",
+		"result", result))
+}
+
+// testWebhook populates the given result by verifying and parsing the webhook
+func (s *Server) testWebhook(result *api.WebhookTestResult, h http.Header, body []byte) {
+	if s.state.WebhookSecret == "" {
+		result.Error = "no webhook secret is set up yet - set one in inertia.toml and run 'inertia [remote] up'"
+		return
+	}
+	if err := webhook.Verify(result.Provider, s.state.WebhookSecret, h, body); err != nil {
+		result.Error = "unable to verify payload: " + err.Error()
+		return
+	}
+	result.Verified = true
+
+	payload, err := webhook.Parse(result.Provider, result.Event, h, body)
+	if err != nil {
+		result.Error = "unable to parse payload: " + err.Error()
+		return
+	}
+	result.Parsed = true
+
+	if payload.GetEventType() != webhook.PushEvent {
+		result.Reason = "only push events trigger deployments"
+		return
+	}
+	result.Repository = payload.GetRepoName()
+	result.Ref = payload.GetRef()
+	result.SSHURL = payload.GetSSHURL()
+	result.Branch = common.GetBranchFromRef(payload.GetRef())
+	if _, err := s.matchPushEvent(payload); err != nil {
+		result.Reason = err.Error()
+		return
+	}
+	if s.isWarmStandby() {
+		result.Reason = "warm standby is enabled - the commit would be pre-warmed, and deployed by the next 'inertia [remote] up'"
+		return
+	}
+	result.WouldDeploy = true
+}
@@ -0,0 +1,165 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/cfg"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/project/mocks"
+)
+
+func TestWebhookConfigHandler(t *testing.T) {
+	var fakeDeployer = &mocks.FakeDeployer{}
+	fakeDeployer.GetProjectReturns("my-project")
+	fakeDeployer.GetBranchReturns("master")
+	allowedRefs, err := git.NewRefFilter([]string{"master", "refs/tags/*"})
+	assert.Nil(t, err)
+	var s = &Server{
+		state:       cfg.Config{WebhookSecret: testKey},
+		deployment:  fakeDeployer,
+		allowedRefs: allowedRefs,
+	}
+
+	req := httptest.NewRequest("GET", "/webhook/config", nil)
+	req.Host = "inertia.example.com:4303"
+	recorder := httptest.NewRecorder()
+	http.HandlerFunc(s.webhookConfigHandler).ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var config api.WebhookConfig
+	_, err = api.Unmarshal(recorder.Body, api.KV{Key: "webhook", Value: &config})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"https://inertia.example.com:4303/webhook",
+		"https://inertia.example.com:4303/projects/my-project/webhook",
+	}, config.URLs)
+	assert.Equal(t, "master", config.Branch)
+	assert.True(t, config.SecretSet)
+	assert.Equal(t, []string{"master", "refs/tags/*"}, config.AllowedRefs)
+	assert.Len(t, config.Providers, 4)
+}
+
+func TestWebhookTestHandler(t *testing.T) {
+	const pushBody = `{
+		"ref": "refs/heads/dev",
+		"repository": {
+			"name": "inertia",
+			"clone_url": "https://github.com/ubclaunchpad/inertia.git",
+			"ssh_url": "git@github.com:ubclaunchpad/inertia.git"
+		}
+	}`
+	var sign = func(body string) string {
+		mac := hmac.New(sha1.New, []byte(testKey))
+		mac.Write([]byte(body))
+		return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		event     string
+		body      string
+		signature string
+		allowed   []string
+		want      api.WebhookTestResult
+	}{
+		{"bad signature", "push", pushBody, testSignature, nil, api.WebhookTestResult{
+			Provider: "github", Event: "push",
+			Error: "unable to verify payload: payload signature check failed",
+		}},
+		{"ping", "ping", testBody, testSignature, nil, api.WebhookTestResult{
+			Provider: "github", Event: "ping", Verified: true, Parsed: true,
+			Reason: "only push events trigger deployments",
+		}},
+		{"branch mismatch", "push", pushBody, sign(pushBody), nil, api.WebhookTestResult{
+			Provider: "github", Event: "push", Verified: true, Parsed: true,
+			Repository: "inertia", Ref: "refs/heads/dev", Branch: "dev",
+			SSHURL: "git@github.com:ubclaunchpad/inertia.git",
+			Reason: "event branch dev does not match deployed branch master",
+		}},
+		{"ref not allowed", "push", pushBody, sign(pushBody), []string{"master"}, api.WebhookTestResult{
+			Provider: "github", Event: "push", Verified: true, Parsed: true,
+			Repository: "inertia", Ref: "refs/heads/dev", Branch: "dev",
+			SSHURL: "git@github.com:ubclaunchpad/inertia.git",
+			Reason: "ref refs/heads/dev is not allowed to be deployed",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fakeDeployer = &mocks.FakeDeployer{
+				GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+					return api.DeploymentStatus{CommitHash: "abcde"}, nil
+				},
+			}
+			fakeDeployer.GetBranchReturns("master")
+			allowedRefs, err := git.NewRefFilter(tt.allowed)
+			assert.Nil(t, err)
+			var s = &Server{
+				state:       cfg.Config{WebhookSecret: testKey},
+				deployment:  fakeDeployer,
+				allowedRefs: allowedRefs,
+			}
+
+			req := httptest.NewRequest("POST", "/webhook/test", bytes.NewBufferString(tt.body))
+			req.Header.Set("content-type", "application/json")
+			req.Header.Set("X-GitHub-Event", tt.event)
+			req.Header.Set("X-Hub-Signature", tt.signature)
+			recorder := httptest.NewRecorder()
+			http.HandlerFunc(s.webhookTestHandler).ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+
+			var result api.WebhookTestResult
+			_, err = api.Unmarshal(recorder.Body, api.KV{Key: "result", Value: &result})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, result)
+
+			// tests must never deploy
+			assert.Equal(t, 0, fakeDeployer.DeployCallCount())
+		})
+	}
+}
+
+func TestTestWebhookWarmStandby(t *testing.T) {
+	const pushBody = `{
+		"ref": "refs/heads/master",
+		"repository": {
+			"name": "inertia",
+			"clone_url": "https://github.com/ubclaunchpad/inertia.git",
+			"ssh_url": "git@github.com:ubclaunchpad/inertia.git"
+		}
+	}`
+	mac := hmac.New(sha1.New, []byte(testKey))
+	mac.Write([]byte(pushBody))
+
+	var fakeDeployer = &mocks.FakeDeployer{
+		GetStatusStub: func(*docker.Client) (api.DeploymentStatus, error) {
+			return api.DeploymentStatus{CommitHash: "abcde"}, nil
+		},
+	}
+	fakeDeployer.GetBranchReturns("master")
+	var s = &Server{
+		state:       cfg.Config{WebhookSecret: testKey},
+		deployment:  fakeDeployer,
+		warmStandby: true,
+	}
+
+	var h = http.Header{}
+	h.Set("content-type", "application/json")
+	h.Set("X-GitHub-Event", "push")
+	h.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	var result = api.WebhookTestResult{Provider: "github", Event: "push"}
+	s.testWebhook(&result, h, []byte(pushBody))
+	assert.Empty(t, result.Error)
+	assert.False(t, result.WouldDeploy)
+	assert.Contains(t, result.Reason, "warm standby")
+	assert.Equal(t, 0, fakeDeployer.PrewarmCallCount())
+}
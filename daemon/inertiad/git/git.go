@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
@@ -123,3 +126,80 @@ func UpdateRepository(repo *gogit.Repository, opts RepoOptions, out io.Writer) e
 	})
 	return SimplifyGitErr(err)
 }
+
+// Checkout checks out the given commit, which must already be present in the
+// repository, detaching HEAD from the current branch
+func Checkout(repo *gogit.Repository, commit string, out io.Writer) error {
+	tree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	var hash = plumbing.NewHash(commit)
+	if _, err = repo.CommitObject(hash); err != nil {
+		return fmt.Errorf("commit %s is not available: %s", commit, err.Error())
+	}
+	fmt.Fprintf(out, "Checking out '%s'...\n", commit)
+	return SimplifyGitErr(tree.Checkout(&gogit.CheckoutOptions{
+		Hash:  hash,
+		Force: true,
+	}))
+}
+
+// Diff returns a patch of the changes between the given commits. Both commits
+// must be present in the repository.
+func Diff(repo *gogit.Repository, from, to string) (string, error) {
+	fromCommit, err := repo.CommitObject(plumbing.NewHash(from))
+	if err != nil {
+		return "", fmt.Errorf("commit %s is not available: %s", from, err.Error())
+	}
+	toCommit, err := repo.CommitObject(plumbing.NewHash(to))
+	if err != nil {
+		return "", fmt.Errorf("commit %s is not available: %s", to, err.Error())
+	}
+	patch, err := fromCommit.Patch(toCommit)
+	if err != nil {
+		return "", err
+	}
+	return patch.String(), nil
+}
+
+// Export writes the files of the repository at the given revision, such as a
+// branch, tag, or commit hash, to the given directory without modifying the
+// repository's working tree.
+func Export(repo *gogit.Repository, rev, dir string) error {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("revision %s could not be resolved: %s", rev, err.Error())
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return fmt.Errorf("commit %s is not available: %s", hash.String(), err.Error())
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+	return files.ForEach(func(f *object.File) error {
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil || !mode.IsRegular() {
+			// skip symlinks and submodules
+			return nil
+		}
+		var dest = filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+			return err
+		}
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(file, reader)
+		return err
+	})
+}
@@ -1,11 +1,15 @@
 package git
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 const (
@@ -48,3 +52,80 @@ func TestUpdateRepositoryIntegration(t *testing.T) {
 	err = UpdateRepository(repo, RepoOptions{Branch: "dev"}, os.Stdout)
 	assert.Nil(t, err)
 }
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-git")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var (
+		repoDir   = filepath.Join(dir, "repo")
+		exportDir = filepath.Join(dir, "export")
+		signature = &object.Signature{Name: "bob", Email: "bob@inertia.io", When: time.Now()}
+	)
+
+	// Set up a repository with two commits
+	repo, err := git.PlainInit(repoDir, false)
+	assert.Nil(t, err)
+	tree, err := repo.Worktree()
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Join(repoDir, "config"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repoDir, "config", "docker-compose.yml"), []byte("v1"), 0644))
+	_, err = tree.Add("config/docker-compose.yml")
+	assert.Nil(t, err)
+	first, err := tree.Commit("first", &git.CommitOptions{Author: signature})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(repoDir, "config", "docker-compose.yml"), []byte("v2"), 0644))
+	_, err = tree.Add("config/docker-compose.yml")
+	assert.Nil(t, err)
+	_, err = tree.Commit("second", &git.CommitOptions{Author: signature})
+	assert.Nil(t, err)
+
+	// Export the first commit
+	assert.Nil(t, Export(repo, first.String(), exportDir))
+	bytes, err := ioutil.ReadFile(filepath.Join(exportDir, "config", "docker-compose.yml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(bytes))
+
+	// Working tree should be untouched
+	bytes, err = ioutil.ReadFile(filepath.Join(repoDir, "config", "docker-compose.yml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(bytes))
+
+	// Unknown revisions should fail
+	assert.NotNil(t, Export(repo, "nonexistent", exportDir))
+}
+
+func TestCheckout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-git")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var signature = &object.Signature{Name: "bob", Email: "bob@inertia.io", When: time.Now()}
+
+	// Set up a repository with two commits
+	repo, err := git.PlainInit(dir, false)
+	assert.Nil(t, err)
+	tree, err := repo.Worktree()
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("v1"), 0644))
+	_, err = tree.Add("docker-compose.yml")
+	assert.Nil(t, err)
+	first, err := tree.Commit("first", &git.CommitOptions{Author: signature})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("v2"), 0644))
+	_, err = tree.Add("docker-compose.yml")
+	assert.Nil(t, err)
+	_, err = tree.Commit("second", &git.CommitOptions{Author: signature})
+	assert.Nil(t, err)
+
+	// Check out the first commit
+	assert.Nil(t, Checkout(repo, first.String(), ioutil.Discard))
+	head, err := repo.Head()
+	assert.Nil(t, err)
+	assert.Equal(t, first, head.Hash())
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, "docker-compose.yml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(bytes))
+
+	// Unknown commits should fail
+	assert.NotNil(t, Checkout(repo, "0123456789012345678901234567890123456789", ioutil.Discard))
+}
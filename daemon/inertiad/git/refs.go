@@ -0,0 +1,83 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RefFilter restricts which git refs may be deployed. Each pattern is either a
+// glob, such as "main" or "release/*", or a regular expression wrapped in
+// slashes, such as "/^refs/tags/v[0-9]+/". Globs starting with "refs/" are
+// matched against full refs, such as "refs/tags/*", while all other globs are
+// matched against branch names. Regular expressions are always matched against
+// full refs. An empty filter allows all refs.
+type RefFilter struct {
+	patterns []string
+	regexps  map[string]*regexp.Regexp
+}
+
+// NewRefFilter validates the given patterns and creates a filter from them
+func NewRefFilter(patterns []string) (*RefFilter, error) {
+	var f = &RefFilter{
+		patterns: patterns,
+		regexps:  make(map[string]*regexp.Regexp),
+	}
+	for _, p := range patterns {
+		if isRegexpPattern(p) {
+			re, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ref pattern '%s': %s", p, err.Error())
+			}
+			f.regexps[p] = re
+		} else if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid ref pattern '%s': %s", p, err.Error())
+		}
+	}
+	return f, nil
+}
+
+// Patterns returns the patterns this filter was created with
+func (f *RefFilter) Patterns() []string {
+	if f == nil {
+		return nil
+	}
+	return f.patterns
+}
+
+// Allows reports whether the given ref may be deployed. Refs that do not
+// start with "refs/" are treated as branch names.
+func (f *RefFilter) Allows(ref string) bool {
+	if f == nil || len(f.patterns) == 0 {
+		return true
+	}
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + ref
+	}
+	var branch string
+	if strings.HasPrefix(ref, "refs/heads/") {
+		branch = strings.TrimPrefix(ref, "refs/heads/")
+	}
+
+	for _, p := range f.patterns {
+		if re, ok := f.regexps[p]; ok {
+			if re.MatchString(ref) {
+				return true
+			}
+			continue
+		}
+		var target = branch
+		if strings.HasPrefix(p, "refs/") {
+			target = ref
+		}
+		if ok, _ := path.Match(p, target); ok && target != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegexpPattern(p string) bool {
+	return len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/")
+}
@@ -0,0 +1,49 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRefFilter(t *testing.T) {
+	_, err := NewRefFilter([]string{"main", "release/*", "/^refs/tags/v/"})
+	assert.Nil(t, err)
+
+	_, err = NewRefFilter([]string{"/[/"})
+	assert.Error(t, err)
+	_, err = NewRefFilter([]string{"[main"})
+	assert.Error(t, err)
+}
+
+func TestRefFilterAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		ref      string
+		want     bool
+	}{
+		{"no patterns", nil, "anything", true},
+		{"branch name", []string{"main"}, "main", true},
+		{"full branch ref", []string{"main"}, "refs/heads/main", true},
+		{"other branch", []string{"main"}, "dev", false},
+		{"branch glob", []string{"release/*"}, "release/v1", true},
+		{"branch glob mismatch", []string{"release/*"}, "feature/wow", false},
+		{"tag glob", []string{"refs/tags/*"}, "refs/tags/v1.0.0", true},
+		{"branch pattern does not match tags", []string{"*"}, "refs/tags/v1.0.0", false},
+		{"tag glob does not match branches", []string{"refs/tags/*"}, "main", false},
+		{"regexp", []string{"/^refs/tags/v[0-9]+/"}, "refs/tags/v2", true},
+		{"regexp mismatch", []string{"/^refs/tags/v[0-9]+/"}, "refs/tags/latest", false},
+		{"regexp on branch", []string{"/^refs/heads/(main|dev)$/"}, "dev", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewRefFilter(tt.patterns)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, f.Allows(tt.ref))
+		})
+	}
+
+	var nilFilter *RefFilter
+	assert.True(t, nilFilter.Allows("main"))
+}
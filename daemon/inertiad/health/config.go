@@ -0,0 +1,150 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// DefaultInterval is the default time between probes
+	DefaultInterval = 30 * time.Second
+
+	// DefaultUnhealthyThreshold is the default number of consecutive failed
+	// probes before a container is considered unhealthy
+	DefaultUnhealthyThreshold = 3
+
+	// DefaultTimeout is the default time a health command may run for before
+	// it is considered failed
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultFlapThreshold is the default number of restarts allowed within
+	// the flap window before a service is considered to be crash-looping
+	DefaultFlapThreshold = 3
+
+	// DefaultFlapWindow is the default period over which restarts are counted
+	// towards FlapThreshold
+	DefaultFlapWindow = 10 * time.Minute
+)
+
+// Config configures health checks for a service
+type Config struct {
+	// StartupGrace is the period after a container starts during which failed
+	// probes do not count towards UnhealthyThreshold
+	StartupGrace time.Duration
+
+	// Interval is the time between probes
+	Interval time.Duration
+
+	// UnhealthyThreshold is the number of consecutive failed probes required
+	// before a container is considered unhealthy
+	UnhealthyThreshold int
+
+	// Command is an optional command run inside the container as part of each
+	// probe - a non-zero exit code indicates the container is unhealthy
+	Command []string
+
+	// Timeout is the time a health command may run for, or the time allowed
+	// for a port probe to connect
+	Timeout time.Duration
+
+	// Port is an optional container port that must accept connections for the
+	// container to be considered healthy
+	Port int
+
+	// Protocol is the protocol used to probe Port, either "tcp" or "udp"
+	Protocol string
+
+	// FlapThreshold is the number of restarts allowed within FlapWindow -
+	// a service restarted more often than this is crash-looping
+	FlapThreshold int
+
+	// FlapWindow is the period over which restarts are counted
+	FlapWindow time.Duration
+
+	// StopOnFlap stops automatic restarts of a crash-looping service until
+	// they are resumed
+	StopOnFlap bool
+}
+
+// DefaultConfig returns the configuration used for services that do not have
+// health checks configured
+func DefaultConfig() Config {
+	return Config{
+		Interval:           DefaultInterval,
+		UnhealthyThreshold: DefaultUnhealthyThreshold,
+		Timeout:            DefaultTimeout,
+		Protocol:           "tcp",
+		FlapThreshold:      DefaultFlapThreshold,
+		FlapWindow:         DefaultFlapWindow,
+	}
+}
+
+// ParseConfig creates a Config from the given API configuration, using
+// defaults for values that are not provided
+func ParseConfig(check api.HealthCheck) (Config, error) {
+	var conf = DefaultConfig()
+	var err error
+	if check.StartupGrace != "" {
+		if conf.StartupGrace, err = time.ParseDuration(check.StartupGrace); err != nil {
+			return conf, fmt.Errorf("invalid startup grace period: %s", err.Error())
+		}
+	}
+	if check.Interval != "" {
+		if conf.Interval, err = time.ParseDuration(check.Interval); err != nil {
+			return conf, fmt.Errorf("invalid probe interval: %s", err.Error())
+		}
+	}
+	if check.UnhealthyThreshold > 0 {
+		conf.UnhealthyThreshold = check.UnhealthyThreshold
+	}
+	if len(check.Command) > 0 {
+		conf.Command = check.Command
+	}
+	if check.Timeout != "" {
+		if conf.Timeout, err = time.ParseDuration(check.Timeout); err != nil {
+			return conf, fmt.Errorf("invalid command timeout: %s", err.Error())
+		}
+	}
+	if check.Port != 0 {
+		if check.Port < 0 || check.Port > 65535 {
+			return conf, fmt.Errorf("invalid port %d", check.Port)
+		}
+		conf.Port = check.Port
+	}
+	if check.Protocol != "" {
+		conf.Protocol = strings.ToLower(check.Protocol)
+		if conf.Protocol != "tcp" && conf.Protocol != "udp" {
+			return conf, fmt.Errorf("unsupported protocol '%s'", check.Protocol)
+		}
+	}
+	if check.FlapThreshold > 0 {
+		conf.FlapThreshold = check.FlapThreshold
+	}
+	if check.FlapWindow != "" {
+		if conf.FlapWindow, err = time.ParseDuration(check.FlapWindow); err != nil {
+			return conf, fmt.Errorf("invalid flap window: %s", err.Error())
+		}
+	}
+	conf.StopOnFlap = check.StopOnFlap
+	if conf.StartupGrace < 0 || conf.Interval <= 0 || conf.Timeout <= 0 ||
+		conf.FlapWindow <= 0 {
+		return conf, fmt.Errorf("durations must be positive")
+	}
+	return conf, nil
+}
+
+// ParseConfigs parses health check configuration for each service
+func ParseConfigs(checks map[string]api.HealthCheck) (map[string]Config, error) {
+	var configs = make(map[string]Config, len(checks))
+	for service, check := range checks {
+		conf, err := ParseConfig(check)
+		if err != nil {
+			return nil, fmt.Errorf("service '%s': %s", service, err.Error())
+		}
+		configs[service] = conf
+	}
+	return configs, nil
+}
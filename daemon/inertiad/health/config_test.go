@@ -0,0 +1,77 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   api.HealthCheck
+		want    Config
+		wantErr bool
+	}{
+		{"defaults", api.HealthCheck{}, DefaultConfig(), false},
+		{"all values", api.HealthCheck{
+			StartupGrace:       "1m",
+			Interval:           "10s",
+			UnhealthyThreshold: 5,
+			Command:            []string{"check-lag", "--max", "5"},
+			Timeout:            "3s",
+			Port:               5432,
+			Protocol:           "UDP",
+			FlapThreshold:      5,
+			FlapWindow:         "1h",
+			StopOnFlap:         true,
+		}, Config{
+			StartupGrace:       time.Minute,
+			Interval:           10 * time.Second,
+			UnhealthyThreshold: 5,
+			Command:            []string{"check-lag", "--max", "5"},
+			Timeout:            3 * time.Second,
+			Port:               5432,
+			Protocol:           "udp",
+			FlapThreshold:      5,
+			FlapWindow:         time.Hour,
+			StopOnFlap:         true,
+		}, false},
+		{"invalid grace", api.HealthCheck{StartupGrace: "soon"}, Config{}, true},
+		{"invalid interval", api.HealthCheck{Interval: "often"}, Config{}, true},
+		{"negative interval", api.HealthCheck{Interval: "-1s"}, Config{}, true},
+		{"invalid timeout", api.HealthCheck{Timeout: "eventually"}, Config{}, true},
+		{"zero timeout", api.HealthCheck{Timeout: "0s"}, Config{}, true},
+		{"invalid port", api.HealthCheck{Port: 70000}, Config{}, true},
+		{"invalid flap window", api.HealthCheck{FlapWindow: "a while"}, Config{}, true},
+		{"negative flap window", api.HealthCheck{FlapWindow: "-1m"}, Config{}, true},
+		{"invalid protocol", api.HealthCheck{Port: 80, Protocol: "sctp"}, Config{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(tt.check)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseConfigs(t *testing.T) {
+	configs, err := ParseConfigs(map[string]api.HealthCheck{
+		"web": {StartupGrace: "30s"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, configs["web"].StartupGrace)
+
+	_, err = ParseConfigs(map[string]api.HealthCheck{
+		"web": {StartupGrace: "wow"},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "web")
+}
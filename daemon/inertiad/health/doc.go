@@ -0,0 +1,2 @@
+// Package health provides health monitoring for deployed project containers
+package health
@@ -0,0 +1,377 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+)
+
+// State denotes the health of a monitored container
+type State string
+
+const (
+	// StateStarting indicates the container has not yet passed a probe
+	StateStarting State = "starting"
+
+	// StateHealthy indicates the container's most recent probe succeeded
+	StateHealthy State = "healthy"
+
+	// StateUnhealthy indicates the container has failed too many probes
+	StateUnhealthy State = "unhealthy"
+
+	// StateCrashLooping indicates the container has been restarted too often,
+	// and is no longer probed or restarted until restarts are resumed
+	StateCrashLooping State = "crash-looping"
+)
+
+const (
+	// composeServiceLabel is the label docker-compose uses to denote a
+	// container's service name
+	composeServiceLabel = "com.docker.compose.service"
+
+	// tickInterval is how often the monitor checks for probes that are due
+	tickInterval = time.Second
+
+	// restartWindow is how long after a restart a container is considered to
+	// have been restarted by the monitor
+	restartWindow = time.Minute
+)
+
+// service tracks the health of a single container
+type service struct {
+	name    string
+	conf    Config
+	started time.Time
+
+	state     State
+	failures  int
+	lastProbe time.Time
+
+	// portsVerified is set once the container's exposed ports have been
+	// checked for listeners
+	portsVerified bool
+}
+
+// record updates the service's health with the result of a probe made at the
+// given time, and returns true if the service has just become unhealthy.
+// Failures within the service's startup grace period are not counted.
+func (s *service) record(err error, now time.Time) bool {
+	s.lastProbe = now
+	if err == nil {
+		s.failures = 0
+		s.state = StateHealthy
+		return false
+	}
+
+	if now.Sub(s.started) < s.conf.StartupGrace {
+		s.state = StateStarting
+		return false
+	}
+
+	s.failures++
+	if s.failures < s.conf.UnhealthyThreshold || s.state == StateUnhealthy {
+		return false
+	}
+	s.state = StateUnhealthy
+	return true
+}
+
+// Flap describes a service that has been restarted more than FlapThreshold
+// times within its FlapWindow
+type Flap struct {
+	Service  string
+	Restarts int
+	Window   time.Duration
+
+	// Stopped is set if automatic restarts of the service have been stopped
+	Stopped bool
+}
+
+// due returns true if the service should be probed at the given time
+func (s *service) due(now time.Time) bool {
+	return now.Sub(s.lastProbe) >= s.conf.Interval
+}
+
+// Monitor periodically probes active project containers, tracks their health,
+// and restarts containers that become unhealthy
+type Monitor struct {
+	cli     *docker.Client
+	out     io.Writer
+	probe   Prober
+	configs map[string]Config
+	ignore  map[string]bool
+
+	services map[string]*service
+	mux      sync.RWMutex
+
+	restarts   map[string]time.Time
+	restartMux sync.Mutex
+
+	// flaps tracks recent restarts of each service by name, and is guarded
+	// by mux
+	flaps  map[string][]time.Time
+	onFlap func(Flap)
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewMonitor creates a new health monitor. Configs are keyed by service name,
+// which is either the docker-compose service or the container name, and
+// containers with names in ignore are not monitored.
+func NewMonitor(
+	cli *docker.Client,
+	configs map[string]Config,
+	out io.Writer,
+	ignore ...string,
+) *Monitor {
+	var ignored = make(map[string]bool)
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+	if configs == nil {
+		configs = make(map[string]Config)
+	}
+	return &Monitor{
+		cli:     cli,
+		out:     out,
+		probe:   Probe,
+		configs: configs,
+		ignore:  ignored,
+
+		services: make(map[string]*service),
+		restarts: make(map[string]time.Time),
+		flaps:    make(map[string][]time.Time),
+		stop:     make(chan struct{}),
+	}
+}
+
+// OnFlap sets a function that is called in the background when a service is
+// first restarted more than its FlapThreshold within its FlapWindow. It must
+// be set before Start is called.
+func (m *Monitor) OnFlap(fn func(Flap)) {
+	m.onFlap = fn
+}
+
+// Start begins monitoring in the background until Stop is called
+func (m *Monitor) Start() {
+	go func() {
+		var ticker = time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case now := <-ticker.C:
+				m.check(now)
+			}
+		}
+	}()
+}
+
+// Stop ends monitoring
+func (m *Monitor) Stop() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+// States returns the health of each monitored container, keyed by name
+func (m *Monitor) States() map[string]string {
+	var states = make(map[string]string)
+	m.mux.RLock()
+	for _, s := range m.services {
+		states[s.name] = string(s.state)
+	}
+	m.mux.RUnlock()
+	return states
+}
+
+// RecentlyRestarted returns true if the container with the given ID was
+// restarted by the monitor within the last minute. Restarts cause containers
+// to stop, so this can be used to tell them apart from unexpected stops.
+func (m *Monitor) RecentlyRestarted(id string) bool {
+	m.restartMux.Lock()
+	defer m.restartMux.Unlock()
+	restarted, found := m.restarts[id]
+	return found && time.Since(restarted) < restartWindow
+}
+
+// Resume resumes automatic restarts of the given crash-looping containers, or
+// of all crash-looping containers if none are given, and returns the names of
+// the resumed containers. Their restart history is cleared and they are
+// probed again as if they had just started, so a container that is still
+// unhealthy is restarted once it fails enough probes.
+func (m *Monitor) Resume(names ...string) []string {
+	var want = make(map[string]bool, len(names))
+	for _, name := range names {
+		want["/"+strings.TrimPrefix(name, "/")] = true
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var (
+		now     = time.Now()
+		resumed = make([]string, 0)
+	)
+	for _, s := range m.services {
+		if s.state != StateCrashLooping || (len(want) > 0 && !want[s.name]) {
+			continue
+		}
+		delete(m.flaps, s.name)
+		s.started = now
+		s.failures = 0
+		s.state = StateStarting
+		resumed = append(resumed, s.name)
+	}
+	sort.Strings(resumed)
+	return resumed
+}
+
+// check updates the set of monitored containers and probes those that are due
+func (m *Monitor) check(now time.Time) {
+	list, err := m.cli.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		fmt.Fprintln(m.out, "health monitor failed to list containers: "+err.Error())
+		return
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	// Track new containers and forget removed ones
+	var active = make(map[string]bool)
+	for _, c := range list {
+		if len(c.Names) == 0 || m.ignore[c.Names[0]] {
+			continue
+		}
+		active[c.ID] = true
+		if _, found := m.services[c.ID]; !found {
+			m.services[c.ID] = &service{
+				name:    c.Names[0],
+				conf:    m.getConfig(c),
+				started: time.Unix(c.Created, 0),
+				state:   StateStarting,
+			}
+		}
+	}
+	for id := range m.services {
+		if !active[id] {
+			delete(m.services, id)
+		}
+	}
+
+	// Probe containers that are due
+	for id, s := range m.services {
+		if s.state == StateCrashLooping || !s.due(now) {
+			continue
+		}
+		var err = m.probe(m.cli, id, s.conf)
+		if err != nil {
+			if _, ok := err.(*CommandError); ok {
+				fmt.Fprintf(m.out, "health command reported container %s as unhealthy: %s\n",
+					s.name, err.Error())
+			} else {
+				fmt.Fprintf(m.out, "health probe failed for container %s: %s\n", s.name, err.Error())
+			}
+		}
+		if s.record(err, now) {
+			m.restart(id, s, now)
+		}
+		if err == nil && !s.portsVerified {
+			m.verifyPorts(id, s)
+		}
+	}
+}
+
+// verifyPorts warns if any ports exposed by a container are not accepting
+// connections once it first passes a probe
+func (m *Monitor) verifyPorts(id string, s *service) {
+	s.portsVerified = true
+	closed, err := ClosedPorts(m.cli, id, s.conf.Timeout)
+	if err != nil {
+		fmt.Fprintf(m.out, "unable to verify ports of container %s: %s\n", s.name, err.Error())
+		return
+	}
+	for _, port := range closed {
+		fmt.Fprintf(m.out, "warning: container %s exposes port %s, but nothing is listening on it\n",
+			s.name, port)
+	}
+}
+
+// restart restarts an unhealthy container, after which its startup grace
+// period applies again
+func (m *Monitor) restart(id string, s *service, now time.Time) {
+	if m.detectFlap(s, now) {
+		return
+	}
+	fmt.Fprintf(m.out, "container %s is unhealthy after %d failed probes - restarting\n",
+		s.name, s.failures)
+	m.restartMux.Lock()
+	m.restarts[id] = time.Now()
+	m.restartMux.Unlock()
+
+	var timeout = 10 * time.Second
+	if err := m.cli.ContainerRestart(context.Background(), id, &timeout); err != nil {
+		fmt.Fprintf(m.out, "failed to restart container %s: %s\n", s.name, err.Error())
+		return
+	}
+	s.started = now
+	s.failures = 0
+	s.state = StateStarting
+}
+
+// detectFlap records a restart of the given service, and reports it if the
+// service has now been restarted more than its FlapThreshold within its
+// FlapWindow. Returns true if the service should no longer be restarted.
+func (m *Monitor) detectFlap(s *service, now time.Time) bool {
+	var recent = make([]time.Time, 0, len(m.flaps[s.name])+1)
+	for _, t := range m.flaps[s.name] {
+		if now.Sub(t) < s.conf.FlapWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	m.flaps[s.name] = recent
+	if s.conf.FlapThreshold <= 0 || len(recent) <= s.conf.FlapThreshold {
+		return false
+	}
+
+	var flap = Flap{
+		Service:  s.name,
+		Restarts: len(recent),
+		Window:   s.conf.FlapWindow,
+		Stopped:  s.conf.StopOnFlap,
+	}
+	if flap.Stopped {
+		fmt.Fprintf(m.out, "container %s needed %d restarts in %s and is crash-looping - "+
+			"it will not be restarted until restarts are resumed\n",
+			s.name, flap.Restarts, flap.Window)
+		s.state = StateCrashLooping
+	} else {
+		fmt.Fprintf(m.out, "container %s needed %d restarts in %s and may be crash-looping\n",
+			s.name, flap.Restarts, flap.Window)
+	}
+
+	// Only report the restart that first exceeds the threshold
+	if m.onFlap != nil && len(recent) == s.conf.FlapThreshold+1 {
+		go m.onFlap(flap)
+	}
+	return flap.Stopped
+}
+
+// getConfig returns the health check configuration for the given container
+func (m *Monitor) getConfig(c types.Container) Config {
+	if conf, found := m.configs[c.Labels[composeServiceLabel]]; found {
+		return conf
+	}
+	if conf, found := m.configs[strings.TrimPrefix(c.Names[0], "/")]; found {
+		return conf
+	}
+	return DefaultConfig()
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_service_record(t *testing.T) {
+	var (
+		start   = time.Unix(1500000000, 0)
+		errUhOh = errors.New("uh oh")
+		s       = &service{
+			name:    "/web",
+			started: start,
+			state:   StateStarting,
+			conf: Config{
+				StartupGrace:       time.Minute,
+				Interval:           10 * time.Second,
+				UnhealthyThreshold: 2,
+			},
+		}
+	)
+
+	// failures within grace period should not count
+	for i := 0; i < 5; i++ {
+		assert.False(t, s.record(errUhOh, start.Add(time.Duration(i)*time.Second)))
+		assert.Equal(t, StateStarting, s.state)
+		assert.Equal(t, 0, s.failures)
+	}
+
+	// success should mark healthy, even within grace period
+	assert.False(t, s.record(nil, start.Add(20*time.Second)))
+	assert.Equal(t, StateHealthy, s.state)
+
+	// failures after grace period should count towards threshold
+	var afterGrace = start.Add(2 * time.Minute)
+	assert.False(t, s.record(errUhOh, afterGrace))
+	assert.Equal(t, StateHealthy, s.state)
+	assert.Equal(t, 1, s.failures)
+	assert.True(t, s.record(errUhOh, afterGrace.Add(10*time.Second)))
+	assert.Equal(t, StateUnhealthy, s.state)
+
+	// should only report becoming unhealthy once
+	assert.False(t, s.record(errUhOh, afterGrace.Add(20*time.Second)))
+	assert.Equal(t, StateUnhealthy, s.state)
+
+	// success should reset failures
+	assert.False(t, s.record(nil, afterGrace.Add(30*time.Second)))
+	assert.Equal(t, StateHealthy, s.state)
+	assert.Equal(t, 0, s.failures)
+}
+
+func Test_service_due(t *testing.T) {
+	var (
+		now = time.Unix(1500000000, 0)
+		s   = &service{conf: Config{Interval: 10 * time.Second}}
+	)
+	assert.True(t, s.due(now))
+	s.lastProbe = now
+	assert.False(t, s.due(now.Add(5*time.Second)))
+	assert.True(t, s.due(now.Add(10*time.Second)))
+}
+
+func TestMonitor_getConfig(t *testing.T) {
+	var (
+		web   = Config{StartupGrace: time.Minute, Interval: time.Second, UnhealthyThreshold: 1}
+		named = Config{StartupGrace: time.Hour, Interval: time.Second, UnhealthyThreshold: 1}
+		m     = NewMonitor(nil, map[string]Config{"web": web, "my-app": named}, nil)
+	)
+	assert.Equal(t, web, m.getConfig(types.Container{
+		Names:  []string{"/project_web_1"},
+		Labels: map[string]string{composeServiceLabel: "web"},
+	}))
+	assert.Equal(t, named, m.getConfig(types.Container{
+		Names: []string{"/my-app"},
+	}))
+	assert.Equal(t, DefaultConfig(), m.getConfig(types.Container{
+		Names: []string{"/other"},
+	}))
+}
+
+func TestMonitor_States(t *testing.T) {
+	var m = NewMonitor(nil, nil, nil)
+	m.services["1234"] = &service{name: "/web", state: StateStarting}
+	assert.Equal(t, map[string]string{"/web": "starting"}, m.States())
+
+	// stop should be safe to call multiple times
+	m.Stop()
+	m.Stop()
+}
+
+func TestMonitor_RecentlyRestarted(t *testing.T) {
+	var m = NewMonitor(nil, nil, nil)
+	assert.False(t, m.RecentlyRestarted("1234"))
+	m.restarts["1234"] = time.Now()
+	assert.True(t, m.RecentlyRestarted("1234"))
+	m.restarts["1234"] = time.Now().Add(-2 * restartWindow)
+	assert.False(t, m.RecentlyRestarted("1234"))
+}
+
+func TestMonitor_detectFlap(t *testing.T) {
+	var (
+		now   = time.Unix(1500000000, 0)
+		flaps = make(chan Flap, 1)
+		m     = NewMonitor(nil, nil, ioutil.Discard)
+		s     = &service{
+			name:  "/web",
+			state: StateUnhealthy,
+			conf:  Config{FlapThreshold: 2, FlapWindow: time.Minute},
+		}
+	)
+	m.OnFlap(func(f Flap) { flaps <- f })
+
+	// restarts within the threshold are allowed
+	assert.False(t, m.detectFlap(s, now))
+	assert.False(t, m.detectFlap(s, now.Add(10*time.Second)))
+
+	// restarts outside the window should not count
+	assert.False(t, m.detectFlap(s, now.Add(2*time.Minute)))
+	assert.False(t, m.detectFlap(s, now.Add(2*time.Minute+10*time.Second)))
+	assert.Len(t, flaps, 0)
+
+	// exceeding the threshold should be reported, but restarts continue
+	assert.False(t, m.detectFlap(s, now.Add(2*time.Minute+20*time.Second)))
+	assert.Equal(t, Flap{Service: "/web", Restarts: 3, Window: time.Minute}, <-flaps)
+	assert.Equal(t, StateUnhealthy, s.state)
+
+	// with StopOnFlap, the service should be marked as crash-looping
+	s.conf.StopOnFlap = true
+	assert.True(t, m.detectFlap(s, now.Add(2*time.Minute+30*time.Second)))
+	assert.Equal(t, StateCrashLooping, s.state)
+}
+
+func TestMonitor_Resume(t *testing.T) {
+	var m = NewMonitor(nil, nil, nil)
+	m.services["1"] = &service{name: "/web", state: StateCrashLooping, failures: 3}
+	m.services["2"] = &service{name: "/db", state: StateCrashLooping}
+	m.services["3"] = &service{name: "/cache", state: StateHealthy}
+	m.flaps["/web"] = []time.Time{time.Now()}
+
+	assert.Equal(t, []string{"/web"}, m.Resume("web"))
+	assert.Equal(t, StateStarting, m.services["1"].state)
+	assert.Equal(t, 0, m.services["1"].failures)
+	assert.Empty(t, m.flaps["/web"])
+	assert.Equal(t, StateCrashLooping, m.services["2"].state)
+
+	assert.Equal(t, []string{"/db"}, m.Resume())
+	assert.Equal(t, []string{}, m.Resume())
+}
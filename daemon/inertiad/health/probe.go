@@ -0,0 +1,218 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	// maxCommandOutput is the maximum number of bytes of health command output
+	// that are kept for reporting
+	maxCommandOutput = 512
+
+	// daemonContainer is the name of the Inertia daemon's container, which is
+	// used to find the address of the Docker host
+	daemonContainer = "/inertia-daemon"
+)
+
+// Prober checks the health of the container with the given ID, and returns an
+// error if the container is unhealthy
+type Prober func(cli *docker.Client, id string, conf Config) error
+
+// CommandError indicates that a health command ran, but reported the
+// container as unhealthy
+type CommandError struct {
+	ExitCode int
+	Output   string
+}
+
+func (e *CommandError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("health command exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("health command exited with status %d: %s", e.ExitCode, e.Output)
+}
+
+// Probe checks the container with ContainerProbe, then checks the configured
+// port with PortProbe and runs the configured health command with
+// CommandProbe if there are any
+func Probe(cli *docker.Client, id string, conf Config) error {
+	if err := ContainerProbe(cli, id, conf); err != nil {
+		return err
+	}
+	if conf.Port > 0 {
+		if err := PortProbe(cli, id, conf); err != nil {
+			return err
+		}
+	}
+	if len(conf.Command) > 0 {
+		return CommandProbe(cli, id, conf)
+	}
+	return nil
+}
+
+// ContainerProbe checks that the container is running, and that Docker does
+// not report it as unhealthy if the image defines a HEALTHCHECK
+func ContainerProbe(cli *docker.Client, id string, conf Config) error {
+	c, err := cli.ContainerInspect(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if c.State == nil || !c.State.Running {
+		return errors.New("container is not running")
+	}
+	if c.State.Health != nil && c.State.Health.Status == types.Unhealthy {
+		return errors.New("container is reported as unhealthy by Docker")
+	}
+	return nil
+}
+
+// CommandProbe runs the configured health command inside the container. A
+// *CommandError is returned if the command exits with a non-zero status or
+// does not complete within the configured timeout - any other error means the
+// command could not be run at all.
+func CommandProbe(cli *docker.Client, id string, conf Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
+	defer cancel()
+
+	exec, err := cli.ContainerExecCreate(ctx, id, types.ExecConfig{
+		Cmd:          conf.Command,
+		Tty:          true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create health command: %s", err.Error())
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: true})
+	if err != nil {
+		return fmt.Errorf("failed to start health command: %s", err.Error())
+	}
+	defer resp.Close()
+
+	// Read output until the command exits or times out
+	resp.Conn.SetDeadline(time.Now().Add(conf.Timeout))
+	output, err := ioutil.ReadAll(io.LimitReader(resp.Reader, maxCommandOutput))
+	if err != nil {
+		return &CommandError{ExitCode: -1, Output: "timed out after " + conf.Timeout.String()}
+	}
+	io.Copy(ioutil.Discard, resp.Reader)
+
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect health command: %s", err.Error())
+	}
+	if inspect.Running {
+		return &CommandError{ExitCode: -1, Output: "timed out after " + conf.Timeout.String()}
+	}
+	if inspect.ExitCode != 0 {
+		return &CommandError{
+			ExitCode: inspect.ExitCode,
+			Output:   strings.TrimSpace(string(output)),
+		}
+	}
+	return nil
+}
+
+// PortProbe checks that the configured port of the container accepts
+// connections. Ports published on the host are reached through the host,
+// since the daemon is not usually on the same network as project containers.
+// UDP probes only fail if the port is actively refused, as UDP services are
+// not required to respond.
+func PortProbe(cli *docker.Client, id string, conf Config) error {
+	c, err := cli.ContainerInspect(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	var port = nat.Port(fmt.Sprintf("%d/%s", conf.Port, conf.Protocol))
+	address, err := portAddress(cli, c, port)
+	if err != nil {
+		return err
+	}
+	return dial(conf.Protocol, address, conf.Timeout)
+}
+
+// ClosedPorts returns the ports exposed by the container that are not
+// accepting connections
+func ClosedPorts(cli *docker.Client, id string, timeout time.Duration) ([]string, error) {
+	c, err := cli.ContainerInspect(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Config == nil {
+		return nil, nil
+	}
+	var closed = []string{}
+	for port := range c.Config.ExposedPorts {
+		address, err := portAddress(cli, c, port)
+		if err != nil {
+			return nil, err
+		}
+		if err := dial(port.Proto(), address, timeout); err != nil {
+			closed = append(closed, string(port))
+		}
+	}
+	return closed, nil
+}
+
+// portAddress returns the address at which the daemon can reach the given
+// container port
+func portAddress(cli *docker.Client, c types.ContainerJSON, port nat.Port) (string, error) {
+	if c.NetworkSettings == nil {
+		return "", errors.New("container has no network settings")
+	}
+	for _, binding := range c.NetworkSettings.Ports[port] {
+		if binding.HostPort != "" {
+			return net.JoinHostPort(hostAddress(cli), binding.HostPort), nil
+		}
+	}
+	for _, network := range c.NetworkSettings.Networks {
+		if network != nil && network.IPAddress != "" {
+			return net.JoinHostPort(network.IPAddress, port.Port()), nil
+		}
+	}
+	return "", fmt.Errorf("unable to find an address for port %s", port)
+}
+
+// hostAddress returns the address of the Docker host as seen by the daemon
+func hostAddress(cli *docker.Client) string {
+	daemon, err := cli.ContainerInspect(context.Background(), daemonContainer)
+	if err != nil || daemon.NetworkSettings == nil || daemon.NetworkSettings.Gateway == "" {
+		// daemon is likely not running in a container
+		return "127.0.0.1"
+	}
+	return daemon.NetworkSettings.Gateway
+}
+
+// dial attempts to connect to the given address
+func dial(protocol, address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout(protocol, address, timeout)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s port %s: %s", protocol, address, err.Error())
+	}
+	defer conn.Close()
+	if protocol != "udp" {
+		return nil
+	}
+
+	// A refused UDP port is reported as an error when reading after a write,
+	// while a timeout means the packet was accepted or silently dropped
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err = conn.Write([]byte{}); err == nil {
+		_, err = conn.Read(make([]byte, 1))
+	}
+	if netErr, ok := err.(net.Error); err != nil && !(ok && netErr.Timeout()) {
+		return fmt.Errorf("%s port %s refused probe: %s", protocol, address, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package health
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandError(t *testing.T) {
+	assert.Equal(t, "health command exited with status 1",
+		(&CommandError{ExitCode: 1}).Error())
+	assert.Equal(t, "health command exited with status 2: replication lag too high",
+		(&CommandError{ExitCode: 2, Output: "replication lag too high"}).Error())
+}
+
+func TestDial(t *testing.T) {
+	// TCP
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	var address = listener.Addr().String()
+	assert.Nil(t, dial("tcp", address, time.Second))
+	listener.Close()
+	assert.NotNil(t, dial("tcp", address, time.Second))
+
+	// UDP
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	assert.Nil(t, dial("udp", conn.LocalAddr().String(), 100*time.Millisecond))
+	conn.Close()
+}
@@ -0,0 +1,177 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// DefaultRestartTimeout is the default time allowed, in addition to a
+	// service's startup grace period, for a restarted container to pass a probe
+	// during a rolling restart
+	DefaultRestartTimeout = 2 * time.Minute
+
+	// restartPollInterval is how often restarted containers are probed while
+	// waiting for them to become healthy
+	restartPollInterval = time.Second
+)
+
+// RestartOptions configures a manual restart of monitored containers
+type RestartOptions struct {
+	// Rolling restarts the replicas of each service in batches, waiting for
+	// each batch to become healthy before moving on to the next
+	Rolling bool
+
+	// BatchSize is the number of replicas of a service restarted at a time
+	// during a rolling restart - defaults to 1
+	BatchSize int
+
+	// Timeout is the time allowed for a batch to become healthy, in addition
+	// to the service's startup grace period - defaults to DefaultRestartTimeout
+	Timeout time.Duration
+}
+
+// replica is a running container belonging to a service
+type replica struct {
+	id   string
+	name string
+	conf Config
+}
+
+// Restart restarts all monitored containers, reporting progress to out, and
+// returns the names of the restarted containers. If a rolling restart is
+// requested, it stops at the first batch that does not become healthy, leaving
+// remaining replicas untouched.
+func (m *Monitor) Restart(out io.Writer, opts RestartOptions) ([]string, error) {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultRestartTimeout
+	}
+
+	services, err := m.replicas()
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, errors.New("no containers to restart")
+	}
+	var names = make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var restarted = make([]string, 0)
+	if !opts.Rolling {
+		for _, name := range names {
+			for _, r := range services[name] {
+				fmt.Fprintf(out, "restarting container %s\n", r.name)
+				if err := m.restartReplica(r); err != nil {
+					return restarted, err
+				}
+				restarted = append(restarted, r.name)
+			}
+		}
+		return restarted, nil
+	}
+
+	for _, name := range names {
+		var replicas = services[name]
+		for start := 0; start < len(replicas); start += opts.BatchSize {
+			var end = start + opts.BatchSize
+			if end > len(replicas) {
+				end = len(replicas)
+			}
+			fmt.Fprintf(out, "restarting service %s: replicas %d-%d of %d\n",
+				name, start+1, end, len(replicas))
+			for _, r := range replicas[start:end] {
+				if err := m.restartReplica(r); err != nil {
+					return restarted, err
+				}
+				restarted = append(restarted, r.name)
+			}
+			for _, r := range replicas[start:end] {
+				if err := m.waitHealthy(r, opts.Timeout); err != nil {
+					return restarted, fmt.Errorf("container %s did not become healthy: %s",
+						r.name, err.Error())
+				}
+				fmt.Fprintf(out, "container %s is healthy\n", r.name)
+			}
+		}
+	}
+	return restarted, nil
+}
+
+// replicas returns the monitored containers, grouped by service name
+func (m *Monitor) replicas() (map[string][]replica, error) {
+	list, err := m.cli.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var services = make(map[string][]replica)
+	for _, c := range list {
+		if len(c.Names) == 0 || m.ignore[c.Names[0]] {
+			continue
+		}
+		var name = c.Labels[composeServiceLabel]
+		if name == "" {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		services[name] = append(services[name], replica{
+			id:   c.ID,
+			name: c.Names[0],
+			conf: m.getConfig(c),
+		})
+	}
+	for _, replicas := range services {
+		sort.Slice(replicas, func(i, j int) bool { return replicas[i].name < replicas[j].name })
+	}
+	return services, nil
+}
+
+// restartReplica restarts the given container, noting the restart so that it
+// is not mistaken for an unexpected stop, and resets its tracked health
+func (m *Monitor) restartReplica(r replica) error {
+	m.restartMux.Lock()
+	m.restarts[r.id] = time.Now()
+	m.restartMux.Unlock()
+
+	var timeout = 10 * time.Second
+	if err := m.cli.ContainerRestart(context.Background(), r.id, &timeout); err != nil {
+		return fmt.Errorf("failed to restart container %s: %s", r.name, err.Error())
+	}
+
+	m.mux.Lock()
+	if s, found := m.services[r.id]; found {
+		s.started = time.Now()
+		s.failures = 0
+		s.state = StateStarting
+	}
+	m.mux.Unlock()
+	return nil
+}
+
+// waitHealthy probes the given container until it passes a probe, or until its
+// startup grace period and the given timeout have elapsed
+func (m *Monitor) waitHealthy(r replica, timeout time.Duration) error {
+	var deadline = time.Now().Add(r.conf.StartupGrace + timeout)
+	for {
+		var err = m.probe(m.cli, r.id, r.conf)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(restartPollInterval)
+	}
+}
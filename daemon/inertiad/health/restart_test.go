@@ -0,0 +1,31 @@
+package health
+
+import (
+	"errors"
+	"testing"
+
+	docker "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor_waitHealthy(t *testing.T) {
+	var m = NewMonitor(nil, nil, nil)
+	var probes int
+	m.probe = func(cli *docker.Client, id string, conf Config) error {
+		probes++
+		if probes < 2 {
+			return errors.New("not yet")
+		}
+		return nil
+	}
+
+	// passes on second probe
+	assert.NoError(t, m.waitHealthy(replica{id: "1234", conf: DefaultConfig()}, DefaultRestartTimeout))
+	assert.Equal(t, 2, probes)
+
+	// deadline already passed
+	m.probe = func(cli *docker.Client, id string, conf Config) error {
+		return errors.New("oh no")
+	}
+	assert.EqualError(t, m.waitHealthy(replica{id: "1234", conf: DefaultConfig()}, 0), "oh no")
+}
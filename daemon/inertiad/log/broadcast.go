@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+)
+
+// subscriberBuffer is the number of lines buffered for each subscriber before
+// lines are dropped
+const subscriberBuffer = 256
+
+// Broadcaster is an io.Writer that sends each complete line written to it to
+// all current subscribers. Subscribers that fall behind miss lines rather than
+// blocking writers. A nil Broadcaster discards everything written to it.
+type Broadcaster struct {
+	mux         sync.Mutex
+	partial     []byte
+	subscribers map[chan string]struct{}
+}
+
+// NewBroadcaster creates a new Broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan string]struct{})}
+}
+
+// Write sends each complete line in p to subscribers, holding on to any
+// incomplete line until the rest of it is written
+func (b *Broadcaster) Write(p []byte) (int, error) {
+	if b == nil {
+		return len(p), nil
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.partial = append(b.partial, p...)
+	for {
+		var i = bytes.IndexByte(b.partial, '\n')
+		if i < 0 {
+			break
+		}
+		var line = string(b.partial[:i])
+		b.partial = b.partial[i+1:]
+		for sub := range b.subscribers {
+			select {
+			case sub <- line:
+			default:
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Subscribe returns a channel that receives lines written after the call, and
+// a function that must be called to unsubscribe once lines are no longer read
+func (b *Broadcaster) Subscribe() (<-chan string, func()) {
+	var sub = make(chan string, subscriberBuffer)
+	if b == nil {
+		return sub, func() {}
+	}
+	b.mux.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mux.Unlock()
+
+	var once sync.Once
+	return sub, func() {
+		once.Do(func() {
+			b.mux.Lock()
+			delete(b.subscribers, sub)
+			b.mux.Unlock()
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+	var b = NewBroadcaster()
+	fmt.Fprintln(b, "before subscribing")
+
+	lines, unsubscribe := b.Subscribe()
+	fmt.Fprint(b, "I am")
+	fmt.Fprint(b, " awesome\nand")
+	fmt.Fprintln(b, " hungry!!")
+	assert.Equal(t, "I am awesome", <-lines)
+	assert.Equal(t, "and hungry!!", <-lines)
+
+	unsubscribe()
+	unsubscribe()
+	fmt.Fprintln(b, "after unsubscribing")
+	assert.Empty(t, lines)
+
+	// nil broadcaster should discard writes
+	var nilBroadcaster *Broadcaster
+	n, err := nilBroadcaster.Write([]byte("hello\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+}
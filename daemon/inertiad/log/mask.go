@@ -0,0 +1,65 @@
+package log
+
+import (
+	"io"
+	"strings"
+)
+
+const (
+	// MaskedValue replaces secret values in masked output
+	MaskedValue = "***"
+
+	// minSecretLength is the minimum length of values that are masked, since
+	// masking very short values would garble unrelated output
+	minSecretLength = 4
+)
+
+// Masker replaces known secret values in log output
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// NewMasker creates a new masker for the given secret values. Values shorter
+// than 4 characters are not masked.
+func NewMasker(secrets []string) *Masker {
+	var pairs = make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			pairs = append(pairs, secret, MaskedValue)
+		}
+	}
+	if len(pairs) == 0 {
+		return &Masker{}
+	}
+	return &Masker{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Mask replaces all secret values in the given string
+func (m *Masker) Mask(s string) string {
+	if m == nil || m.replacer == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// Writer wraps the given writer so that secret values are masked in everything
+// written to it. Masking is applied to each write individually, so callers
+// should write complete lines.
+func (m *Masker) Writer(w io.Writer) io.Writer {
+	if m == nil || m.replacer == nil {
+		return w
+	}
+	return &maskedWriter{masker: m, w: w}
+}
+
+type maskedWriter struct {
+	masker *Masker
+	w      io.Writer
+}
+
+func (m *maskedWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(m.w, m.masker.Mask(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
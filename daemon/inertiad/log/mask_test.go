@@ -0,0 +1,25 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMasker(t *testing.T) {
+	var m = NewMasker([]string{"hunter2", "abc", ""})
+	assert.Equal(t, "password is ***, abc is not a secret",
+		m.Mask("password is hunter2, abc is not a secret"))
+
+	var buf bytes.Buffer
+	n, err := m.Writer(&buf).Write([]byte("hunter2 hunter2\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, len("hunter2 hunter2\n"), n)
+	assert.Equal(t, "*** ***\n", buf.String())
+
+	// nothing to mask
+	var empty = NewMasker(nil)
+	assert.Equal(t, "hunter2", empty.Mask("hunter2"))
+	assert.Equal(t, &buf, empty.Writer(&buf))
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EventStreamContentType is the content type of server-sent event streams
+const EventStreamContentType = "text/event-stream"
+
+// SSEWriter writes server-sent events, flushing each one if the underlying
+// writer is flushable
+type SSEWriter struct {
+	w io.Writer
+}
+
+// NewSSEWriter sets up the given response for server-sent events, and returns
+// a writer for sending events on it
+func NewSSEWriter(w http.ResponseWriter) *SSEWriter {
+	w.Header().Set("Content-Type", EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	var s = &SSEWriter{w: w}
+	s.flush()
+	return s
+}
+
+// WriteEvent sends the given data as a single event. Multi-line data is split
+// across multiple data fields, which clients join back together.
+func (s *SSEWriter) WriteEvent(data string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Heartbeat sends a comment, which clients ignore, to keep proxies from
+// closing idle connections
+func (s *SSEWriter) Heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+func (s *SSEWriter) write(frame string) error {
+	if _, err := io.WriteString(s.w, frame); err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *SSEWriter) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
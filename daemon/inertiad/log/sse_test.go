@@ -0,0 +1,24 @@
+package log
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSEWriter(t *testing.T) {
+	var rec = httptest.NewRecorder()
+	var w = NewSSEWriter(rec)
+	assert.Equal(t, EventStreamContentType, rec.Header().Get("Content-Type"))
+	assert.True(t, rec.Flushed)
+
+	assert.Nil(t, w.WriteEvent("hello"))
+	assert.Nil(t, w.WriteEvent("multiple\nlines"))
+	assert.Nil(t, w.Heartbeat())
+	assert.Equal(t,
+		"data: hello\n\n"+
+			"data: multiple\ndata: lines\n\n"+
+			": heartbeat\n\n",
+		rec.Body.String())
+}
@@ -29,7 +29,11 @@ Example:
     inertia daemon run 0.0.0.0 -p 8081`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var conf = cfg.New()
+		conf, err := cfg.Load(os.Getenv("INERTIA_CONFIG_FILE"))
+		if err != nil {
+			println(err.Error())
+			return
+		}
 
 		// Set up deployment
 		var projectDatabasePath = path.Join(conf.DataDirectory, "project.db")
@@ -43,6 +47,9 @@ Example:
 			println(err.Error())
 			return
 		}
+		deployment.SetConfig(project.DeploymentConfig{
+			HistoryLimit: conf.HistoryLimit,
+		})
 
 		// Initialize daemon
 		server, err := daemon.New(Version, *conf, deployment)
@@ -68,7 +75,8 @@ var tokenCmd = &cobra.Command{
 			panic(err)
 		}
 
-		token, err := crypto.GenerateMasterToken(keyBytes.([]byte))
+		var scopes, _ = cmd.Flags().GetStringSlice("scope")
+		token, err := crypto.GenerateScopedToken(keyBytes.([]byte), scopes...)
 		if err != nil {
 			panic(err)
 		}
@@ -94,6 +102,8 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(tokenCmd)
 	runCmd.Flags().StringP("port", "p", "4303", "Set port for daemon to run on")
+	tokenCmd.Flags().StringSlice("scope", nil,
+		"Restrict the token to the given scopes (deploy, logs, users)")
 }
 
 func main() {
@@ -0,0 +1,4 @@
+// Package metrics provides simple counters, gauges, and histograms for daemon
+// activity, which can be exported in the Prometheus text format or as a JSON
+// snapshot
+package metrics
@@ -0,0 +1,391 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a monotonically increasing value that is safe for concurrent use
+type Counter struct {
+	name  string
+	help  string
+	value uint64
+}
+
+// Name returns the name of the counter
+func (c *Counter) Name() string { return c.name }
+
+// Inc increments the counter by 1
+func (c *Counter) Inc() { atomic.AddUint64(&c.value, 1) }
+
+// Value returns the current value of the counter
+func (c *Counter) Value() uint64 { return atomic.LoadUint64(&c.value) }
+
+// OverflowLabelValue is the label value that observations are attributed to
+// once a vector has reached its limit of distinct label values
+const OverflowLabelValue = "other"
+
+// CounterVec is a set of counters partitioned by the value of a single label.
+// To keep the number of series bounded, at most limit distinct label values
+// are tracked - further values are counted under OverflowLabelValue.
+type CounterVec struct {
+	name  string
+	help  string
+	label string
+	limit int
+
+	mux      sync.RWMutex
+	counters map[string]*Counter
+}
+
+// Name returns the name of the counter vector
+func (v *CounterVec) Name() string { return v.name }
+
+// With returns the counter for the given label value
+func (v *CounterVec) With(value string) *Counter {
+	v.mux.RLock()
+	c, ok := v.counters[value]
+	v.mux.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if c, ok = v.counters[value]; ok {
+		return c
+	}
+	if v.limit > 0 && len(v.counters) >= v.limit {
+		if c, ok = v.counters[OverflowLabelValue]; ok {
+			return c
+		}
+		value = OverflowLabelValue
+	}
+	c = &Counter{name: v.name, help: v.help}
+	v.counters[value] = c
+	return c
+}
+
+// Value returns the sum of all counters in the vector
+func (v *CounterVec) Value() uint64 {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	var sum uint64
+	for _, c := range v.counters {
+		sum += c.Value()
+	}
+	return sum
+}
+
+// values returns the current value of each counter, keyed by label value
+func (v *CounterVec) values() map[string]float64 {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	var values = make(map[string]float64, len(v.counters))
+	for l, c := range v.counters {
+		values[l] = float64(c.Value())
+	}
+	return values
+}
+
+// GaugeVecFunc is a set of gauges partitioned by the value of a single label,
+// whose values are computed when metrics are collected
+type GaugeVecFunc struct {
+	name  string
+	help  string
+	label string
+	fn    func() map[string]float64
+}
+
+// Name returns the name of the gauge vector
+func (g *GaugeVecFunc) Name() string { return g.name }
+
+// DefaultBuckets are the default upper bounds of histogram buckets, suited to
+// request latencies in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in buckets by value, and tracks their sum. It
+// is safe for concurrent use.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mux    sync.Mutex
+	counts []uint64 // per bucket, with a final bucket for larger values
+	sum    float64
+}
+
+// Name returns the name of the histogram
+func (h *Histogram) Name() string { return h.name }
+
+// Observe records the given value
+func (h *Histogram) Observe(v float64) {
+	var i = sort.SearchFloat64s(h.buckets, v)
+	h.mux.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mux.Unlock()
+}
+
+// Count returns the number of values observed
+func (h *Histogram) Count() uint64 {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	var count uint64
+	for _, c := range h.counts {
+		count += c
+	}
+	return count
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mux.Lock()
+	var counts = make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	var sum = h.sum
+	h.mux.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n",
+		h.name, h.help, h.name); err != nil {
+		return err
+	}
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		var le = "+Inf"
+		if i < len(h.buckets) {
+			le = formatValue(h.buckets[i])
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, le, cumulative); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatValue(sum), h.name, cumulative)
+	return err
+}
+
+// Registry is a collection of counters, gauges, and histograms, which also
+// tracks its own uptime
+type Registry struct {
+	namespace string
+	started   time.Time
+
+	mux        sync.RWMutex
+	counters   []*Counter
+	counterVec []*CounterVec
+	gaugeVec   []*GaugeVecFunc
+	histograms []*Histogram
+}
+
+// NewRegistry instantiates a new registry. All metrics names are prefixed
+// with the given namespace.
+func NewRegistry(namespace string) *Registry {
+	return &Registry{
+		namespace: namespace,
+		started:   time.Now(),
+	}
+}
+
+// NewCounter creates and registers a new counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	var c = &Counter{name: r.namespace + "_" + name, help: help}
+	r.mux.Lock()
+	r.counters = append(r.counters, c)
+	r.mux.Unlock()
+	return c
+}
+
+// NewCounterVec creates and registers a new counter vector partitioned by the
+// given label, tracking at most limit distinct label values
+func (r *Registry) NewCounterVec(name, help, label string, limit int) *CounterVec {
+	var v = &CounterVec{
+		name:     r.namespace + "_" + name,
+		help:     help,
+		label:    label,
+		limit:    limit,
+		counters: make(map[string]*Counter),
+	}
+	r.mux.Lock()
+	r.counterVec = append(r.counterVec, v)
+	r.mux.Unlock()
+	return v
+}
+
+// NewGaugeVecFunc creates and registers a new gauge vector partitioned by the
+// given label. The given function is called to compute gauge values, keyed by
+// label value, whenever metrics are collected.
+func (r *Registry) NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) *GaugeVecFunc {
+	var g = &GaugeVecFunc{name: r.namespace + "_" + name, help: help, label: label, fn: fn}
+	r.mux.Lock()
+	r.gaugeVec = append(r.gaugeVec, g)
+	r.mux.Unlock()
+	return g
+}
+
+// NewGaugeFunc creates and registers a new gauge. The given function is called
+// to compute its value whenever metrics are collected.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeVecFunc {
+	return r.NewGaugeVecFunc(name, help, "", func() map[string]float64 {
+		return map[string]float64{"": fn()}
+	})
+}
+
+// NewHistogram creates and registers a new histogram with buckets of the given
+// upper bounds, or DefaultBuckets if none are given
+func (r *Registry) NewHistogram(name, help string, buckets ...float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	var sorted = make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	var h = &Histogram{
+		name:    r.namespace + "_" + name,
+		help:    help,
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)+1),
+	}
+	r.mux.Lock()
+	r.histograms = append(r.histograms, h)
+	r.mux.Unlock()
+	return h
+}
+
+// Registered returns true if a metric with the given name has already been
+// registered
+func (r *Registry) Registered(name string) bool {
+	name = r.namespace + "_" + name
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	for _, c := range r.counters {
+		if c.name == name {
+			return true
+		}
+	}
+	for _, v := range r.counterVec {
+		if v.name == name {
+			return true
+		}
+	}
+	for _, g := range r.gaugeVec {
+		if g.name == name {
+			return true
+		}
+	}
+	for _, h := range r.histograms {
+		if h.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Uptime returns the time elapsed since the registry was created
+func (r *Registry) Uptime() time.Duration { return time.Since(r.started) }
+
+// Snapshot returns the current value of all registered counters, keyed by
+// name. Counter vectors are reported as the sum of all their counters.
+func (r *Registry) Snapshot() map[string]uint64 {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	var snapshot = make(map[string]uint64, len(r.counters)+len(r.counterVec))
+	for _, c := range r.counters {
+		snapshot[c.name] = c.Value()
+	}
+	for _, v := range r.counterVec {
+		snapshot[v.name] = v.Value()
+	}
+	return snapshot
+}
+
+// family is a named set of series in the Prometheus text exposition format
+type family struct {
+	name   string
+	help   string
+	kind   string
+	label  string
+	values map[string]float64
+
+	// hist is set for histograms, which write their own series
+	hist *Histogram
+}
+
+func (f *family) write(w io.Writer) error {
+	if f.hist != nil {
+		return f.hist.write(w)
+	}
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+		f.name, f.help, f.name, f.kind); err != nil {
+		return err
+	}
+	if f.label == "" {
+		_, err := fmt.Fprintf(w, "%s %s\n", f.name, formatValue(f.values[""]))
+		return err
+	}
+	var labels = make([]string, 0, len(f.values))
+	for l := range f.values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		if _, err := fmt.Fprintf(w, "%s{%s=%q} %s\n",
+			f.name, f.label, l, formatValue(f.values[l])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatValue(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// WritePrometheus writes all registered metrics, as well as the registry's
+// uptime and the given version, to w in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer, version string) error {
+	r.mux.RLock()
+	var families = make([]family, 0,
+		len(r.counters)+len(r.counterVec)+len(r.gaugeVec)+len(r.histograms))
+	for _, c := range r.counters {
+		families = append(families, family{name: c.name, help: c.help, kind: "counter",
+			values: map[string]float64{"": float64(c.Value())}})
+	}
+	for _, v := range r.counterVec {
+		families = append(families, family{name: v.name, help: v.help, kind: "counter",
+			label: v.label, values: v.values()})
+	}
+	for _, h := range r.histograms {
+		families = append(families, family{name: h.name, hist: h})
+	}
+	var gauges = make([]*GaugeVecFunc, len(r.gaugeVec))
+	copy(gauges, r.gaugeVec)
+	r.mux.RUnlock()
+
+	// gauge functions are computed outside of the lock, since they may be slow
+	for _, g := range gauges {
+		families = append(families, family{name: g.name, help: g.help, kind: "gauge",
+			label: g.label, values: g.fn()})
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	for _, f := range families {
+		if err := f.write(w); err != nil {
+			return err
+		}
+	}
+
+	var uptime = r.namespace + "_uptime_seconds"
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %f\n",
+		uptime, "Time elapsed since the daemon started.", uptime, uptime,
+		r.Uptime().Seconds()); err != nil {
+		return err
+	}
+	var info = r.namespace + "_build_info"
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{version=%q} 1\n",
+		info, "Version of the daemon.", info, info, version)
+	return err
+}
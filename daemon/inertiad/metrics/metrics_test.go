@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	var r = NewRegistry("test")
+	var c = r.NewCounter("events_total", "Number of events.")
+	assert.Equal(t, "test_events_total", c.Name())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() { c.Inc(); wg.Done() }()
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(10), c.Value())
+	assert.Equal(t, map[string]uint64{"test_events_total": 10}, r.Snapshot())
+}
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	var r = NewRegistry("test")
+	r.NewCounter("b_total", "B events.").Inc()
+	r.NewCounter("a_total", "A events.")
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf, "v0.1.0"))
+	var out = buf.String()
+	assert.Contains(t, out, "# TYPE test_a_total counter\ntest_a_total 0\n")
+	assert.Contains(t, out, "# TYPE test_b_total counter\ntest_b_total 1\n")
+	assert.Contains(t, out, "# TYPE test_uptime_seconds gauge\n")
+	assert.Contains(t, out, `test_build_info{version="v0.1.0"} 1`)
+	assert.True(t, bytes.Index(buf.Bytes(), []byte("test_a_total")) <
+		bytes.Index(buf.Bytes(), []byte("test_b_total")))
+}
+
+func TestCounterVec(t *testing.T) {
+	var r = NewRegistry("test")
+	var v = r.NewCounterVec("deploys_total", "Number of deploys.", "project", 2)
+	assert.Equal(t, "test_deploys_total", v.Name())
+
+	v.With("a").Inc()
+	v.With("a").Inc()
+	v.With("b").Inc()
+	// limit reached, so further projects are counted together
+	v.With("c").Inc()
+	v.With("d").Inc()
+	assert.Equal(t, uint64(2), v.With("a").Value())
+	assert.Equal(t, uint64(2), v.With(OverflowLabelValue).Value())
+	assert.Equal(t, uint64(5), v.Value())
+	assert.Equal(t, map[string]uint64{"test_deploys_total": 5}, r.Snapshot())
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf, "v0.1.0"))
+	var out = buf.String()
+	assert.Contains(t, out, "# TYPE test_deploys_total counter\n"+
+		"test_deploys_total{project=\"a\"} 2\n"+
+		"test_deploys_total{project=\"b\"} 1\n"+
+		"test_deploys_total{project=\"other\"} 2\n")
+}
+
+func TestGaugeVecFunc(t *testing.T) {
+	var r = NewRegistry("test")
+	r.NewGaugeVecFunc("containers", "Number of containers.", "project",
+		func() map[string]float64 { return map[string]float64{"a": 3} })
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf, "v0.1.0"))
+	assert.Contains(t, buf.String(), "# TYPE test_containers gauge\ntest_containers{project=\"a\"} 3\n")
+}
+
+func TestGaugeFunc(t *testing.T) {
+	var r = NewRegistry("test")
+	r.NewGaugeFunc("sessions", "Number of sessions.", func() float64 { return 2 })
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf, "v0.1.0"))
+	assert.Contains(t, buf.String(), "# TYPE test_sessions gauge\ntest_sessions 2\n")
+}
+
+func TestHistogram(t *testing.T) {
+	var r = NewRegistry("test")
+	var h = r.NewHistogram("duration_seconds", "Duration of things.", 1, 0.5)
+	assert.Equal(t, "test_duration_seconds", h.Name())
+	h.Observe(0.25)
+	h.Observe(0.5)
+	h.Observe(0.75)
+	h.Observe(2)
+	assert.Equal(t, uint64(4), h.Count())
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf, "v0.1.0"))
+	assert.Contains(t, buf.String(), "# TYPE test_duration_seconds histogram\n"+
+		"test_duration_seconds_bucket{le=\"0.5\"} 2\n"+
+		"test_duration_seconds_bucket{le=\"1\"} 3\n"+
+		"test_duration_seconds_bucket{le=\"+Inf\"} 4\n"+
+		"test_duration_seconds_sum 3.5\n"+
+		"test_duration_seconds_count 4\n")
+}
+
+func TestRegistry_Registered(t *testing.T) {
+	var r = NewRegistry("test")
+	assert.False(t, r.Registered("events_total"))
+	r.NewCounter("events_total", "Number of events.")
+	r.NewHistogram("duration_seconds", "Time taken.")
+	assert.True(t, r.Registered("events_total"))
+	assert.True(t, r.Registered("duration_seconds"))
+	assert.False(t, r.Registered("test_events_total"))
+}
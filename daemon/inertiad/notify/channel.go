@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// ChannelSlack posts notifications to a Slack incoming webhook
+	ChannelSlack = "slack"
+
+	// ChannelWebhook posts notifications as JSON to an arbitrary URL
+	ChannelWebhook = "webhook"
+
+	// sendTimeout is the time allowed for a notification to be delivered
+	sendTimeout = 10 * time.Second
+
+	// SignatureHeader is the header containing the signature of webhook
+	// payloads, in the form "sha256=[hexdigest]"
+	SignatureHeader = "X-Inertia-Signature"
+)
+
+// ChannelConfig configures a notification channel
+type ChannelConfig struct {
+	// Type is the kind of channel, either ChannelSlack or ChannelWebhook
+	Type string
+
+	// URL is the address notifications are posted to
+	URL string
+
+	// Aggregate is the window over which deploy notifications are collected
+	// and sent as a single summary - if zero, each notification is sent
+	// immediately
+	Aggregate time.Duration
+
+	// Retry configures how failed deliveries are retried - unset values use
+	// defaults
+	Retry RetryPolicy
+
+	// IncludeStatus sends the status snapshots attached to deploy events to
+	// webhook channels
+	IncludeStatus bool
+
+	// Secret is used to sign webhook payloads with HMAC-SHA256, if set
+	Secret string
+}
+
+// Sender delivers notifications to a channel. Multiple events are sent as a
+// single summary.
+type Sender interface {
+	Send(events []Event) error
+}
+
+// NewSender creates a sender for the given channel configuration
+func NewSender(conf ChannelConfig) (Sender, error) {
+	if conf.URL == "" {
+		return nil, errors.New("notification channel URL is required")
+	}
+	switch conf.Type {
+	case ChannelSlack:
+		return &slackSender{url: conf.URL}, nil
+	case ChannelWebhook, "":
+		return &webhookSender{
+			url:           conf.URL,
+			secret:        conf.Secret,
+			includeStatus: conf.IncludeStatus,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification channel type '%s'", conf.Type)
+	}
+}
+
+// slackSender posts events as a Slack message
+type slackSender struct{ url string }
+
+func (s *slackSender) Send(events []Event) error {
+	var lines = make([]string, 0, len(events)+1)
+	if len(events) > 1 {
+		lines = append(lines, fmt.Sprintf("%d events since last summary:", len(events)))
+	}
+	for _, e := range events {
+		lines = append(lines, e.String())
+	}
+	return postJSON(s.url, map[string]string{"text": strings.Join(lines, "\n")}, "")
+}
+
+// webhookSender posts events as JSON, signed with the secret if one is set
+type webhookSender struct {
+	url           string
+	secret        string
+	includeStatus bool
+}
+
+func (s *webhookSender) Send(events []Event) error {
+	if !s.includeStatus {
+		var stripped = make([]Event, len(events))
+		for i, e := range events {
+			e.Status = nil
+			stripped[i] = e
+		}
+		events = stripped
+	}
+	return postJSON(s.url, map[string][]Event{"events": events}, s.secret)
+}
+
+// postJSON posts the given body as JSON, with a signature of the body in
+// SignatureHeader if a secret is provided
+func postJSON(url string, body interface{}, secret string) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, b))
+	}
+	var client = &http.Client{Timeout: sendTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the given webhook payload, in the form
+// "sha256=[hexdigest]"
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestSenders(t *testing.T) {
+	var received map[string]interface{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	var events = []Event{
+		{Type: EventDeploy, Time: time.Now(), Project: "inertia", Message: "deployed", Success: true},
+		{Type: EventDeploy, Time: time.Now(), Project: "inertia", Message: "oh no"},
+	}
+
+	slack, err := NewSender(ChannelConfig{Type: ChannelSlack, URL: testServer.URL})
+	assert.Nil(t, err)
+	assert.Nil(t, slack.Send(events))
+	assert.Contains(t, received["text"], "2 events since last summary")
+	assert.Contains(t, received["text"], "inertia (failed): oh no")
+
+	webhook, err := NewSender(ChannelConfig{Type: ChannelWebhook, URL: testServer.URL})
+	assert.Nil(t, err)
+	assert.Nil(t, webhook.Send(events))
+	assert.Len(t, received["events"], 2)
+}
+
+func TestWebhookSenderStatus(t *testing.T) {
+	var (
+		received  struct{ Events []Event }
+		signature string
+		body      []byte
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		assert.Nil(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	var events = []Event{{
+		Type:    EventDeploy,
+		Project: "inertia",
+		Success: true,
+		Status:  &api.DeploymentStatus{Containers: []string{"/web"}},
+	}}
+
+	// status is stripped unless requested, and payloads are unsigned without
+	// a secret
+	sender, err := NewSender(ChannelConfig{URL: testServer.URL})
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Send(events))
+	assert.Nil(t, received.Events[0].Status)
+	assert.Empty(t, signature)
+	assert.NotNil(t, events[0].Status)
+
+	sender, err = NewSender(ChannelConfig{URL: testServer.URL, IncludeStatus: true, Secret: "wow"})
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Send(events))
+	assert.Equal(t, []string{"/web"}, received.Events[0].Status.Containers)
+	assert.Equal(t, Sign("wow", body), signature)
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+}
+
+func TestSenderRejected(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer testServer.Close()
+
+	sender, err := NewSender(ChannelConfig{URL: testServer.URL})
+	assert.Nil(t, err)
+	assert.Error(t, sender.Send([]Event{{Type: EventDeploy}}))
+}
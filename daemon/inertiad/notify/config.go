@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+// ParseChannelConfigs creates channel configurations from the given API
+// configuration
+func ParseChannelConfigs(channels []api.NotificationChannel) ([]ChannelConfig, error) {
+	var confs = make([]ChannelConfig, 0, len(channels))
+	for i, c := range channels {
+		var conf = ChannelConfig{Type: c.Type, URL: c.URL, IncludeStatus: c.IncludeStatus}
+		if c.IncludeStatus && c.Type != ChannelWebhook && c.Type != "" {
+			return nil, fmt.Errorf("channel %d: status snapshots are only supported for webhook channels", i)
+		}
+		if c.Aggregate != "" {
+			aggregate, err := time.ParseDuration(c.Aggregate)
+			if err != nil {
+				return nil, fmt.Errorf("channel %d: invalid aggregation window: %s", i, err.Error())
+			}
+			if aggregate < 0 {
+				return nil, fmt.Errorf("channel %d: aggregation window must be positive", i)
+			}
+			conf.Aggregate = aggregate
+		}
+		if c.Retries < 0 {
+			return nil, fmt.Errorf("channel %d: retries must be positive", i)
+		}
+		conf.Retry.Attempts = c.Retries
+		if c.Backoff != "" {
+			backoff, err := time.ParseDuration(c.Backoff)
+			if err != nil {
+				return nil, fmt.Errorf("channel %d: invalid backoff: %s", i, err.Error())
+			}
+			if backoff < 0 {
+				return nil, fmt.Errorf("channel %d: backoff must be positive", i)
+			}
+			conf.Retry.Backoff = backoff
+		}
+		if _, err := NewSender(conf); err != nil {
+			return nil, fmt.Errorf("channel %d: %s", i, err.Error())
+		}
+		confs = append(confs, conf)
+	}
+	return confs, nil
+}
@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestParseChannelConfigs(t *testing.T) {
+	confs, err := ParseChannelConfigs([]api.NotificationChannel{
+		{Type: ChannelSlack, URL: "https://hooks.slack.com/abcde", Aggregate: "5m"},
+		{URL: "https://example.com/notify", Retries: 5, Backoff: "1s"},
+		{Type: ChannelWebhook, URL: "https://example.com/status", IncludeStatus: true},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []ChannelConfig{
+		{Type: ChannelSlack, URL: "https://hooks.slack.com/abcde", Aggregate: 5 * time.Minute},
+		{URL: "https://example.com/notify", Retry: RetryPolicy{Attempts: 5, Backoff: time.Second}},
+		{Type: ChannelWebhook, URL: "https://example.com/status", IncludeStatus: true},
+	}, confs)
+
+	_, err = ParseChannelConfigs([]api.NotificationChannel{{URL: "https://example.com", Aggregate: "soon"}})
+	assert.Error(t, err)
+	_, err = ParseChannelConfigs([]api.NotificationChannel{{URL: "https://example.com", Aggregate: "-5m"}})
+	assert.Error(t, err)
+	_, err = ParseChannelConfigs([]api.NotificationChannel{{URL: "https://example.com", Retries: -1}})
+	assert.Error(t, err)
+	_, err = ParseChannelConfigs([]api.NotificationChannel{{URL: "https://example.com", Backoff: "later"}})
+	assert.Error(t, err)
+	_, err = ParseChannelConfigs([]api.NotificationChannel{{Type: ChannelSlack}})
+	assert.Error(t, err)
+	_, err = ParseChannelConfigs([]api.NotificationChannel{
+		{Type: ChannelSlack, URL: "https://hooks.slack.com/abcde", IncludeStatus: true}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+// channel delivers events to a sender, collecting deploy events over its
+// aggregation window if one is configured. Deliveries are retried in the
+// background, and recorded as failed once retries are exhausted.
+type channel struct {
+	sender Sender
+	conf   ChannelConfig
+	retry  RetryPolicy
+	failed *deadLetters
+	out    io.Writer
+
+	mux     sync.Mutex
+	pending []Event
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newChannel(sender Sender, conf ChannelConfig, failed *deadLetters, out io.Writer) *channel {
+	var c = &channel{
+		sender: sender,
+		conf:   conf,
+		retry:  conf.Retry.withDefaults(),
+		failed: failed,
+		out:    out,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if conf.Aggregate > 0 {
+		go c.run()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// notify sends the event immediately, or queues it for the next summary if
+// the channel aggregates events. Security and health events are always sent
+// immediately.
+func (c *channel) notify(e Event) {
+	if c.conf.Aggregate == 0 || e.Type == EventSecurity || e.Type == EventHealth {
+		go c.send([]Event{e})
+		return
+	}
+	c.mux.Lock()
+	c.pending = append(c.pending, e)
+	c.mux.Unlock()
+}
+
+// run sends a summary of pending events every window until stopped, and
+// flushes remaining events before exiting
+func (c *channel) run() {
+	defer close(c.done)
+	var ticker = time.NewTicker(c.conf.Aggregate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			c.flush()
+			return
+		case <-ticker.C:
+			c.flush()
+		}
+	}
+}
+
+// flush sends all pending events as a single summary
+func (c *channel) flush() {
+	c.mux.Lock()
+	var events = c.pending
+	c.pending = nil
+	c.mux.Unlock()
+	if len(events) > 0 {
+		go c.send(events)
+	}
+}
+
+// send delivers events, retrying with exponential backoff according to the
+// channel's retry policy. Events that could not be delivered are recorded.
+func (c *channel) send(events []Event) {
+	var (
+		backoff = c.retry.Backoff
+		err     error
+	)
+	for attempt := 1; attempt <= c.retry.Attempts; attempt++ {
+		if err = c.sender.Send(events); err == nil {
+			return
+		}
+		fmt.Fprintf(c.out, "failed to send notification (attempt %d of %d): %s\n",
+			attempt, c.retry.Attempts, err.Error())
+		if attempt < c.retry.Attempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	var channelType = c.conf.Type
+	if channelType == "" {
+		channelType = ChannelWebhook
+	}
+	var messages = make([]string, len(events))
+	for i, e := range events {
+		messages[i] = e.String()
+	}
+	c.failed.add(api.FailedNotification{
+		Channel:  channelType + " " + redactURL(c.conf.URL),
+		FailedAt: time.Now(),
+		Attempts: c.retry.Attempts,
+		Error:    err.Error(),
+		Events:   messages,
+	})
+}
+
+// close stops the channel, sending any pending events in the background
+func (c *channel) close() {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	<-c.done
+}
+
+// Dispatcher delivers events to all configured channels. A nil Dispatcher
+// discards all events.
+type Dispatcher struct {
+	out    io.Writer
+	failed *deadLetters
+
+	mux      sync.Mutex
+	channels []*channel
+}
+
+// NewDispatcher creates a dispatcher with no channels. Delivery errors are
+// reported to out.
+func NewDispatcher(out io.Writer) *Dispatcher {
+	return &Dispatcher{out: out, failed: &deadLetters{}}
+}
+
+// SetChannels replaces the configured channels. Pending events on previously
+// configured channels are sent before they are removed.
+func (d *Dispatcher) SetChannels(confs []ChannelConfig) error {
+	var channels = make([]*channel, 0, len(confs))
+	for _, conf := range confs {
+		sender, err := NewSender(conf)
+		if err != nil {
+			return err
+		}
+		channels = append(channels, newChannel(sender, conf, d.failed, d.out))
+	}
+
+	d.mux.Lock()
+	var old = d.channels
+	d.channels = channels
+	d.mux.Unlock()
+	for _, c := range old {
+		c.close()
+	}
+	return nil
+}
+
+// WantsStatus returns true if any configured channel includes status
+// snapshots in deploy events
+func (d *Dispatcher) WantsStatus() bool {
+	if d == nil {
+		return false
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for _, c := range d.channels {
+		if c.conf.IncludeStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify delivers the event to all configured channels without blocking
+func (d *Dispatcher) Notify(e Event) {
+	if d == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for _, c := range d.channels {
+		c.notify(e)
+	}
+}
+
+// Failed returns the most recent notifications that could not be delivered
+// after exhausting retries, most recent first
+func (d *Dispatcher) Failed() []api.FailedNotification {
+	if d == nil {
+		return []api.FailedNotification{}
+	}
+	return d.failed.list()
+}
+
+// Close stops all channels, sending any pending events
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.SetChannels(nil)
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+type fakeSender struct {
+	mux      sync.Mutex
+	sent     [][]Event
+	failures int
+}
+
+func (f *fakeSender) Send(events []Event) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("oh no")
+	}
+	f.sent = append(f.sent, events)
+	return nil
+}
+
+func (f *fakeSender) batches() [][]Event {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.sent
+}
+
+// waitForBatches waits for the sender to receive the given number of batches
+func waitForBatches(t *testing.T, sender *fakeSender, count int) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if len(sender.batches()) == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, sender.batches(), count)
+}
+
+func TestChannel_immediate(t *testing.T) {
+	var sender = &fakeSender{}
+	var c = newChannel(sender, ChannelConfig{}, &deadLetters{}, ioutil.Discard)
+	c.notify(Event{Type: EventDeploy, Message: "hello"})
+	waitForBatches(t, sender, 1)
+	c.close()
+}
+
+func TestChannel_aggregate(t *testing.T) {
+	var sender = &fakeSender{}
+	var c = newChannel(sender, ChannelConfig{Aggregate: time.Hour}, &deadLetters{}, ioutil.Discard)
+
+	// deploy events are held for the summary
+	c.notify(Event{Type: EventDeploy, Message: "first"})
+	c.notify(Event{Type: EventDeploy, Message: "second"})
+
+	// security and health events bypass aggregation
+	c.notify(Event{Type: EventSecurity, Message: "intruder"})
+	waitForBatches(t, sender, 1)
+	assert.Equal(t, "intruder", sender.batches()[0][0].Message)
+	c.notify(Event{Type: EventHealth, Message: "crash-looping"})
+	waitForBatches(t, sender, 2)
+	assert.Equal(t, "crash-looping", sender.batches()[1][0].Message)
+
+	// closing flushes pending events as one summary
+	c.close()
+	waitForBatches(t, sender, 3)
+	assert.Len(t, sender.batches()[2], 2)
+}
+
+func TestChannel_retry(t *testing.T) {
+	var (
+		sender = &fakeSender{failures: 2}
+		failed = &deadLetters{}
+		c      = newChannel(sender, ChannelConfig{
+			Type:  ChannelWebhook,
+			URL:   "https://example.com/secret-token",
+			Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+		}, failed, ioutil.Discard)
+	)
+	defer c.close()
+
+	// delivery succeeds on the last attempt
+	c.notify(Event{Type: EventDeploy, Message: "hello"})
+	waitForBatches(t, sender, 1)
+	assert.Empty(t, failed.list())
+
+	// delivery fails after exhausting retries
+	sender.mux.Lock()
+	sender.failures = 3
+	sender.mux.Unlock()
+	c.send([]Event{{Type: EventDeploy, Message: "goodbye"}})
+	var list = failed.list()
+	assert.Len(t, list, 1)
+	assert.Equal(t, "webhook https://example.com", list[0].Channel)
+	assert.Equal(t, 3, list[0].Attempts)
+	assert.Equal(t, "oh no", list[0].Error)
+	assert.Contains(t, list[0].Events[0], "goodbye")
+}
+
+func TestDeadLetters(t *testing.T) {
+	var failed = &deadLetters{}
+	for i := 0; i < maxFailedNotifications+5; i++ {
+		failed.add(api.FailedNotification{Attempts: i})
+	}
+	var list = failed.list()
+	assert.Len(t, list, maxFailedNotifications)
+	assert.Equal(t, maxFailedNotifications+4, list[0].Attempts)
+	assert.Equal(t, 5, list[len(list)-1].Attempts)
+}
+
+func TestDispatcher(t *testing.T) {
+	// nil dispatchers discard events
+	var d *Dispatcher
+	d.Notify(Event{Type: EventDeploy})
+	assert.Empty(t, d.Failed())
+	d.Close()
+
+	d = NewDispatcher(ioutil.Discard)
+	assert.Error(t, d.SetChannels([]ChannelConfig{{Type: "carrier-pigeon", URL: "coo"}}))
+	assert.Error(t, d.SetChannels([]ChannelConfig{{Type: ChannelSlack}}))
+	assert.NoError(t, d.SetChannels([]ChannelConfig{{Type: ChannelSlack, URL: "http://localhost"}}))
+	d.Close()
+}
@@ -0,0 +1,3 @@
+// Package notify delivers notifications about daemon activity, such as
+// deployments and security events, to configured channels
+package notify
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+// EventType categorizes notification events
+type EventType string
+
+const (
+	// EventDeploy is sent when a deployment completes or fails
+	EventDeploy EventType = "deploy"
+
+	// EventSecurity is sent when the daemon rejects a suspicious request, such
+	// as a webhook or signed deploy request that fails verification. Security
+	// events are never aggregated.
+	EventSecurity EventType = "security"
+
+	// EventHealth is sent when a service is restarted so often that it may be
+	// crash-looping. Health events are never aggregated.
+	EventHealth EventType = "health"
+)
+
+// Event is a notification about daemon activity
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Project string    `json:"project"`
+	Message string    `json:"message"`
+
+	// Success is the outcome of the event, for events such as deployments
+	Success bool `json:"success"`
+
+	// Status is a snapshot of the deployment's status after a deploy event,
+	// and is only sent to channels configured to include it
+	Status *api.DeploymentStatus `json:"status,omitempty"`
+}
+
+// String formats the event as a single line
+func (e Event) String() string {
+	var outcome = "succeeded"
+	if !e.Success {
+		outcome = "failed"
+	}
+	if e.Type == EventSecurity || e.Type == EventHealth {
+		outcome = string(e.Type)
+	}
+	return fmt.Sprintf("[%s] %s (%s): %s",
+		e.Time.Format(time.RFC822), e.Project, outcome, e.Message)
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+const (
+	// DefaultRetryAttempts is the default number of times delivery of a
+	// notification is attempted before it is recorded as failed
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryBackoff is the default delay before the first retry, which
+	// doubles after each failed attempt
+	DefaultRetryBackoff = 5 * time.Second
+
+	// maxFailedNotifications is the number of failed notifications retained
+	maxFailedNotifications = 100
+)
+
+// RetryPolicy configures how delivery of a notification is retried
+type RetryPolicy struct {
+	// Attempts is the total number of delivery attempts
+	Attempts int
+
+	// Backoff is the delay before the first retry, which doubles after each
+	// failed attempt
+	Backoff time.Duration
+}
+
+// withDefaults fills in unset values with defaults
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = DefaultRetryBackoff
+	}
+	return p
+}
+
+// deadLetters records notifications that could not be delivered, retaining
+// only the most recent ones
+type deadLetters struct {
+	mux    sync.Mutex
+	failed []api.FailedNotification
+}
+
+func (d *deadLetters) add(f api.FailedNotification) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.failed = append(d.failed, f)
+	if len(d.failed) > maxFailedNotifications {
+		d.failed = d.failed[len(d.failed)-maxFailedNotifications:]
+	}
+}
+
+// list returns recorded failures, most recent first
+func (d *deadLetters) list() []api.FailedNotification {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	var failed = make([]api.FailedNotification, len(d.failed))
+	for i, f := range d.failed {
+		failed[len(d.failed)-1-i] = f
+	}
+	return failed
+}
+
+// redactURL strips everything but the scheme and host from a channel URL,
+// since URLs such as Slack webhooks embed credentials
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return "(invalid URL)"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,49 @@
+package project
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+)
+
+// ErrNotComposeProject is returned when compose-specific operations are
+// requested for projects that are not built with docker-compose
+var ErrNotComposeProject = errors.New("project is not a docker-compose project")
+
+// ComposeConfig writes the fully resolved docker-compose configuration of the
+// project at the given git revision to out, or of the currently checked out
+// project if no revision is given. The working tree is not modified.
+func (d *Deployment) ComposeConfig(cli *docker.Client, rev string, out io.Writer) error {
+	if strings.ToLower(d.buildType) != "docker-compose" {
+		return ErrNotComposeProject
+	}
+	conf, err := d.GetBuildConfiguration()
+	if err != nil {
+		return err
+	}
+	if rev == "" {
+		return d.builder.ComposeConfig(*conf, cli, out)
+	}
+	if d.repo == nil {
+		return errors.New("repository has not been initialized")
+	}
+
+	// Export the revision next to the project directory, so that it is also
+	// accessible from the host
+	dir, err := ioutil.TempDir(filepath.Dir(filepath.Clean(d.directory)), "compose-config-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := git.Export(d.repo, rev, dir); err != nil {
+		return err
+	}
+	conf.BuildDirectory = dir
+	return d.builder.ComposeConfig(*conf, cli, out)
+}
@@ -1,22 +1,38 @@
 package project
 
 import (
-	"crypto/rand"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"strconv"
+	"strings"
 
+	"github.com/ubclaunchpad/inertia/api"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 	bolt "go.etcd.io/bbolt"
 )
 
 var (
 	// database buckets
-	envVariableBucket = []byte("envVariables")
+	envVariableBucket   = []byte("envVariables")
+	deployHistoryBucket = []byte("deployHistory")
+
+	// ErrDeployNotFound is returned when a deployment could not be found in
+	// the deployment history
+	ErrDeployNotFound = errors.New("deployment not found in history")
+
+	// ErrDeployExpired is returned when a deployment has been evicted from the
+	// deployment history
+	ErrDeployExpired = errors.New("deployment is older than the retained history and is no longer available")
 )
 
+// minDigestLength is the shortest image digest prefix accepted when looking up
+// deployments by image digest
+const minDigestLength = 12
+
 // DeploymentDataManager stores persistent deployment configuration
 type DeploymentDataManager struct {
 	// db is a boltdb database, which is an embedded
@@ -30,17 +46,9 @@ type DeploymentDataManager struct {
 // NewDataManager instantiates a database associated with a deployment
 func NewDataManager(dbPath string, keyPath string) (*DeploymentDataManager, error) {
 	// retrieve AES key, generate if not present
-	var key []byte
-	var err error
-	if key, err = ioutil.ReadFile(keyPath); err != nil || len(key) != crypto.SymmetricKeyLength {
-		key = make([]byte, crypto.SymmetricKeyLength)
-		if _, err := rand.Read(key); err != nil {
-			return nil, fmt.Errorf("failed to generate key: %s", key)
-		}
-		os.Remove(keyPath)
-		if err := ioutil.WriteFile(keyPath, key, 0600); err != nil {
-			return nil, fmt.Errorf("failed to write key to '%s': %s", keyPath, err.Error())
-		}
+	key, err := crypto.ReadSymmetricKey(keyPath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Set up database
@@ -49,7 +57,10 @@ func NewDataManager(dbPath string, keyPath string) (*DeploymentDataManager, erro
 		return nil, fmt.Errorf("failed to open database at '%s': %s", dbPath, err.Error())
 	}
 	if err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(envVariableBucket)
+		if _, err := tx.CreateBucketIfNotExists(envVariableBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(deployHistoryBucket)
 		return err
 	}); err != nil {
 		return nil, fmt.Errorf("failed to instantiate database: %s", err.Error())
@@ -138,12 +149,244 @@ func (c *DeploymentDataManager) GetEnvVariables(decrypt bool) ([]string, error)
 	return envs, err
 }
 
-func (c *DeploymentDataManager) destroy() error {
+// GetSecretValues retrieves the decrypted values of all encrypted environment
+// variables
+func (c *DeploymentDataManager) GetSecretValues() ([]string, error) {
+	var secrets = []string{}
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var variables = tx.Bucket(envVariableBucket)
+		return variables.ForEach(func(name, variableBytes []byte) error {
+			var variable = &envVariable{}
+			if err := json.Unmarshal(variableBytes, variable); err != nil {
+				return err
+			}
+			if !variable.Encrypted {
+				return nil
+			}
+			decrypted, err := crypto.Decrypt(c.symmetricKey, variable.Value)
+			if err != nil {
+				// skip variables that can no longer be decrypted
+				return nil
+			}
+			secrets = append(secrets, string(decrypted))
+			return nil
+		})
+	})
+	return secrets, err
+}
+
+// GetSecrets retrieves the decrypted values of the given encrypted environment
+// variables, keyed by name. Variables that are not set or not encrypted are
+// rejected, so that only values managed as secrets are returned.
+func (c *DeploymentDataManager) GetSecrets(names ...string) (map[string]string, error) {
+	var secrets = make(map[string]string, len(names))
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var variables = tx.Bucket(envVariableBucket)
+		for _, name := range names {
+			var variableBytes = variables.Get([]byte(name))
+			if variableBytes == nil {
+				return fmt.Errorf("secret '%s' is not set", name)
+			}
+			var variable = &envVariable{}
+			if err := json.Unmarshal(variableBytes, variable); err != nil {
+				return err
+			}
+			if !variable.Encrypted {
+				return fmt.Errorf("variable '%s' is not encrypted", name)
+			}
+			decrypted, err := crypto.Decrypt(c.symmetricKey, variable.Value)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s': %s", name, err.Error())
+			}
+			secrets[name] = string(decrypted)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// AddDeployRecord adds a deployment to the deployment history, evicting the
+// oldest deployments if there are more than limit. The record's ID is assigned
+// by the history. A limit of 0 or less retains all deployments.
+func (c *DeploymentDataManager) AddDeployRecord(record *api.DeployRecord, limit int) error {
 	return c.db.Update(func(tx *bolt.Tx) error {
-		if err := tx.DeleteBucket(envVariableBucket); err != nil {
+		var history = tx.Bucket(deployHistoryBucket)
+		seq, err := history.NextSequence()
+		if err != nil {
 			return err
 		}
-		_, err := tx.CreateBucket(envVariableBucket)
-		return err
+		record.ID = strconv.FormatUint(seq, 10)
+		bytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := history.Put(sequenceKey(seq), bytes); err != nil {
+			return err
+		}
+		if limit <= 0 {
+			return nil
+		}
+
+		// Evict oldest records - keys are collected first, since deleting
+		// while iterating can skip keys
+		var keys = [][]byte{}
+		var cursor = history.Cursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			keys = append(keys, k)
+		}
+		for i := 0; i < len(keys)-limit; i++ {
+			if err := history.Delete(keys[i]); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
+
+// GetDeployHistory retrieves all recorded deployments, most recent first
+func (c *DeploymentDataManager) GetDeployHistory() ([]api.DeployRecord, error) {
+	var records = []api.DeployRecord{}
+	var err = c.db.View(func(tx *bolt.Tx) error {
+		var cursor = tx.Bucket(deployHistoryBucket).Cursor()
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			var record api.DeployRecord
+			if err := decodeRecord(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// CompressDeployHistory gzip-compresses all but the given number of most
+// recent deployment records, and returns the number of records compressed.
+// Compressed records are decompressed transparently when read.
+func (c *DeploymentDataManager) CompressDeployHistory(keepRecent int) (int, error) {
+	var compressed = 0
+	var err = c.db.Update(func(tx *bolt.Tx) error {
+		var (
+			history = tx.Bucket(deployHistoryBucket)
+			cursor  = history.Cursor()
+			pending = map[string][]byte{}
+			seen    = 0
+		)
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			if seen++; seen <= keepRecent || isCompressed(v) {
+				continue
+			}
+			var buf bytes.Buffer
+			var w = gzip.NewWriter(&buf)
+			if _, err := w.Write(v); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			pending[string(k)] = buf.Bytes()
+		}
+
+		// Values are updated after iterating, since modifying the bucket
+		// while iterating can skip keys
+		for k, v := range pending {
+			if err := history.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		compressed = len(pending)
+		return nil
+	})
+	return compressed, err
+}
+
+// GetDeployRecord retrieves a recorded deployment by its ID or by the digest of
+// one of its images. Digests may be abbreviated. If id is empty, the most
+// recent deployment is returned. ErrDeployExpired is returned for IDs of
+// deployments that have been evicted from the history.
+func (c *DeploymentDataManager) GetDeployRecord(id string) (*api.DeployRecord, error) {
+	history, err := c.GetDeployHistory()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" && len(history) > 0 {
+		return &history[0], nil
+	}
+
+	var digest = strings.TrimPrefix(id, "sha256:")
+	for i, record := range history {
+		if record.ID == id {
+			return &history[i], nil
+		}
+		if len(digest) < minDigestLength {
+			continue
+		}
+		for _, image := range record.Images {
+			if strings.HasPrefix(strings.TrimPrefix(image.ID, "sha256:"), digest) {
+				return &history[i], nil
+			}
+		}
+	}
+
+	// Check if the deployment existed at some point
+	if seq, err := strconv.ParseUint(id, 10, 64); err == nil && seq > 0 {
+		var last uint64
+		c.db.View(func(tx *bolt.Tx) error {
+			last = tx.Bucket(deployHistoryBucket).Sequence()
+			return nil
+		})
+		if seq <= last {
+			return nil, ErrDeployExpired
+		}
+	}
+	return nil, ErrDeployNotFound
+}
+
+// Close releases the database, which must not be used afterwards
+func (c *DeploymentDataManager) Close() error {
+	return c.db.Close()
+}
+
+func (c *DeploymentDataManager) destroy() error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{envVariableBucket, deployHistoryBucket} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// decodeRecord unmarshals a stored deployment record, decompressing it first
+// if required
+func decodeRecord(value []byte, record *api.DeployRecord) error {
+	if !isCompressed(value) {
+		return json.Unmarshal(value, record)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(record)
+}
+
+// isCompressed returns true if the given value starts with the gzip header,
+// which JSON values never do
+func isCompressed(value []byte) bool {
+	return len(value) > 1 && value[0] == 0x1f && value[1] == 0x8b
+}
+
+// sequenceKey encodes a sequence number as a key that sorts in order
+func sequenceKey(seq uint64) []byte {
+	var key = make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
 )
 
 func TestDataManager_EnvVariableOperations(t *testing.T) {
@@ -65,6 +66,44 @@ func TestDataManager_EnvVariableOperations(t *testing.T) {
 	}
 }
 
+func TestDataManager_GetSecretValues(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	assert.Nil(t, c.AddEnvVariable("PORT", "8080", false))
+	assert.Nil(t, c.AddEnvVariable("API_KEY", "hunter2", true))
+
+	secrets, err := c.GetSecretValues()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hunter2"}, secrets)
+}
+
+func TestDataManager_GetSecrets(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	assert.Nil(t, c.AddEnvVariable("PORT", "8080", false))
+	assert.Nil(t, c.AddEnvVariable("NPM_TOKEN", "hunter2", true))
+
+	secrets, err := c.GetSecrets("NPM_TOKEN")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"NPM_TOKEN": "hunter2"}, secrets)
+
+	// Unencrypted and missing variables should not be returned as secrets
+	_, err = c.GetSecrets("NPM_TOKEN", "PORT")
+	assert.NotNil(t, err)
+	_, err = c.GetSecrets("MISSING")
+	assert.NotNil(t, err)
+}
+
 func TestDataManager_destroy(t *testing.T) {
 	dir := "./test_config"
 	err := os.Mkdir(dir, os.ModePerm)
@@ -83,3 +122,119 @@ func TestDataManager_destroy(t *testing.T) {
 	_, err = c.GetEnvVariables(false)
 	assert.Nil(t, err)
 }
+
+func TestDataManager_DeployHistory(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Empty history
+	records, err := c.GetDeployHistory()
+	assert.Nil(t, err)
+	assert.Zero(t, len(records))
+	_, err = c.GetDeployRecord("")
+	assert.Equal(t, ErrDeployNotFound, err)
+
+	// Add records
+	first := &api.DeployRecord{CommitHash: "abcde", Images: []api.DeployImage{
+		{Name: "inertia-build/project", ID: "sha256:0123456789abcdef0123"},
+	}}
+	assert.Nil(t, c.AddDeployRecord(first, 0))
+	assert.Equal(t, "1", first.ID)
+	second := &api.DeployRecord{CommitHash: "fghij"}
+	assert.Nil(t, c.AddDeployRecord(second, 0))
+	assert.Equal(t, "2", second.ID)
+
+	// Most recent first
+	records, err = c.GetDeployHistory()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "fghij", records[0].CommitHash)
+
+	// Look up records
+	record, err := c.GetDeployRecord("")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", record.ID)
+	record, err = c.GetDeployRecord("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "abcde", record.CommitHash)
+	record, err = c.GetDeployRecord("sha256:0123456789ab")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", record.ID)
+	record, err = c.GetDeployRecord("0123456789abcdef")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", record.ID)
+	_, err = c.GetDeployRecord("0123")
+	assert.Equal(t, ErrDeployNotFound, err)
+
+	// Reset
+	assert.Nil(t, c.destroy())
+	records, err = c.GetDeployHistory()
+	assert.Nil(t, err)
+	assert.Zero(t, len(records))
+}
+
+func TestDataManager_DeployHistoryLimit(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+
+	// Add more records than limit
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, c.AddDeployRecord(&api.DeployRecord{}, 3))
+	}
+	records, err := c.GetDeployHistory()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, "5", records[0].ID)
+	assert.Equal(t, "3", records[2].ID)
+
+	// Evicted records should be reported as expired
+	_, err = c.GetDeployRecord("2")
+	assert.Equal(t, ErrDeployExpired, err)
+	_, err = c.GetDeployRecord("6")
+	assert.Equal(t, ErrDeployNotFound, err)
+}
+
+func TestDataManager_CompressDeployHistory(t *testing.T) {
+	dir := "./test_config"
+	err := os.Mkdir(dir, os.ModePerm)
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Instantiate
+	c, err := NewDataManager(path.Join(dir, "deployment.db"), path.Join(dir, "key"))
+	assert.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, c.AddDeployRecord(&api.DeployRecord{CommitHash: "abcde"}, 0))
+	}
+
+	// Only older records should be compressed, and only once
+	compressed, err := c.CompressDeployHistory(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, compressed)
+	compressed, err = c.CompressDeployHistory(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, compressed)
+
+	// Compressed and uncompressed records should both be readable
+	records, err := c.GetDeployHistory()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(records))
+	for _, r := range records {
+		assert.Equal(t, "abcde", r.CommitHash)
+	}
+	record, err := c.GetDeployRecord("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "abcde", record.CommitHash)
+}
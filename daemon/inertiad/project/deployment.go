@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -19,6 +20,7 @@ import (
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 )
@@ -26,13 +28,21 @@ import (
 // Deployer manages the deployed user project
 type Deployer interface {
 	Deploy(*docker.Client, io.Writer, DeployOptions) (func() error, error)
+	Promote(*docker.Client, io.Writer, io.Reader, api.PromoteRequest) (func() error, error)
+	Prewarm(*docker.Client, io.Writer) (string, error)
 	Initialize(cfg DeploymentConfig, out io.Writer) error
 	Down(*docker.Client, io.Writer) error
 	Destroy(*docker.Client, io.Writer) error
-	Prune(*docker.Client, io.Writer) error
+	Prune(*docker.Client, io.Writer) (api.PruneReport, error)
 	GetStatus(*docker.Client) (api.DeploymentStatus, error)
+	Restart(*docker.Client, io.Writer, health.RestartOptions) ([]string, error)
+	Resume(services []string) ([]string, error)
+	OnFlap(func(health.Flap))
+	Diff(from, to api.DeployRecord) api.DeployDiff
+	ComposeConfig(cli *docker.Client, rev string, out io.Writer) error
 
 	SetConfig(DeploymentConfig)
+	GetProject() string
 	GetBranch() string
 	CompareRemotes(string) error
 
@@ -43,7 +53,6 @@ type Deployer interface {
 
 // Deployment represents the deployed project
 type Deployment struct {
-	active    bool
 	directory string
 
 	project       string
@@ -53,10 +62,24 @@ type Deployment struct {
 
 	builder build.ContainerBuilder
 
+	healthChecks map[string]health.Config
+	onFlap       func(health.Flap)
+	historyLimit int
+	networks     []string
+	buildSecrets []string
+	buildPlan    build.Plan
+
 	repo *gogit.Repository
 	auth ssh.AuthMethod
 	mux  sync.Mutex
 
+	// active, message and monitor are set by deploy callbacks, which run
+	// after mux has been released, so they are guarded separately
+	active   bool
+	message  string
+	monitor  *health.Monitor
+	stateMux sync.RWMutex
+
 	dataManager *DeploymentDataManager
 }
 
@@ -68,6 +91,11 @@ type DeploymentConfig struct {
 	RemoteURL     string
 	Branch        string
 	PemFilePath   string
+	HealthChecks  map[string]health.Config
+	HistoryLimit  int
+	Networks      []string
+	BuildSecrets  []string
+	BuildPlan     *build.Plan
 }
 
 // NewDeployment creates a new deployment
@@ -123,7 +151,8 @@ func (d *Deployment) Initialize(cfg DeploymentConfig, out io.Writer) error {
 }
 
 // SetConfig updates the deployment's configuration. Only supports
-// ProjectName, Branch, and BuildType for now.
+// ProjectName, Branch, BuildType, BuildFilePath, HealthChecks, HistoryLimit,
+// Networks, BuildSecrets, and BuildPlan for now.
 func (d *Deployment) SetConfig(cfg DeploymentConfig) {
 	if cfg.ProjectName != "" {
 		d.project = cfg.ProjectName
@@ -137,11 +166,40 @@ func (d *Deployment) SetConfig(cfg DeploymentConfig) {
 	if cfg.BuildFilePath != "" {
 		d.buildFilePath = cfg.BuildFilePath
 	}
+	if cfg.HealthChecks != nil {
+		d.healthChecks = cfg.HealthChecks
+	}
+	if cfg.HistoryLimit > 0 {
+		d.historyLimit = cfg.HistoryLimit
+	}
+	if cfg.Networks != nil {
+		d.networks = cfg.Networks
+	}
+	if cfg.BuildSecrets != nil {
+		d.buildSecrets = cfg.BuildSecrets
+	}
+	if cfg.BuildPlan != nil {
+		d.buildPlan = *cfg.BuildPlan
+	}
 }
 
 // DeployOptions is used to configure how the deployment handles the deploy
 type DeployOptions struct {
 	SkipUpdate bool
+
+	// Message describes the deployment - if empty, the commit message is used
+	Message string
+
+	// FeatureFlags are passed to project containers for this deployment only
+	FeatureFlags map[string]string
+
+	// Commit is checked out and deployed instead of the latest commit on the
+	// deployed branch, such as when rolling back
+	Commit string
+
+	// RolledBackFrom is the commit a rollback replaces, which is recorded in
+	// the deployment history
+	RolledBackFrom string
 }
 
 // Deploy will update, build, and deploy the project
@@ -154,8 +212,17 @@ func (d *Deployment) Deploy(
 	defer d.mux.Unlock()
 	fmt.Println(out, "Preparing to deploy project")
 
-	// Update repository
-	if !opts.SkipUpdate {
+	flagEnv, err := featureFlagEnv(opts.FeatureFlags)
+	if err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Update repository, or check out the requested commit
+	if opts.Commit != "" {
+		if err := git.Checkout(d.repo, opts.Commit, out); err != nil {
+			return func() error { return nil }, err
+		}
+	} else if !opts.SkipUpdate {
 		if err := git.UpdateRepository(d.repo, git.RepoOptions{
 			Directory: d.directory,
 			Branch:    d.branch,
@@ -165,36 +232,337 @@ func (d *Deployment) Deploy(
 		}
 	}
 
+	// Reject docker-compose files that cannot be deployed before touching
+	// active containers
+	if err := d.validateComposeFile(out); err != nil {
+		fmt.Fprintln(out, err.Error())
+		return func() error { return nil }, err
+	}
+
 	// Clean up
 	d.builder.Prune(cli, out)
 
 	// Kill active project containers if there are any
-	d.active = false
-	err := d.builder.StopContainers(cli, out)
+	d.setActive(false)
+	d.stopMonitor()
+	err = d.builder.StopContainers(cli, out)
 	if err != nil {
 		return func() error { return nil }, err
 	}
 
 	// Get config
+	conf, err := d.prepareBuild(out)
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	conf.EnvValues = append(conf.EnvValues, flagEnv...)
+
+	// Create shared networks before anything references them
+	if err = containers.EnsureNetworks(cli, d.networks, out); err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Build project, unless an image has already been pre-warmed for this
+	// revision
+	var (
+		buildType = strings.ToLower(d.buildType)
+		prewarmed = d.builder.Prewarmed(buildType, *conf, cli)
+		deploy    func() error
+	)
+	if prewarmed {
+		deploy, err = d.builder.Run(buildType, *conf, cli, out)
+	} else {
+		deploy, err = d.builder.Build(buildType, *conf, cli, out)
+	}
+	if err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Deploy
+	return func() error {
+		d.setActive(true)
+		if err := deploy(); err != nil {
+			return err
+		}
+		d.connectNetworks(cli, out)
+		d.startMonitor(cli)
+
+		var record = api.DeployRecord{
+			BuildType:      buildType,
+			Message:        opts.Message,
+			FeatureFlags:   opts.FeatureFlags,
+			Prewarmed:      prewarmed,
+			RolledBackFrom: opts.RolledBackFrom,
+		}
+		if d.repo != nil {
+			if head, err := d.repo.Head(); err == nil {
+				record.Branch = strings.TrimSpace(head.Name().Short())
+				if opts.Commit != "" {
+					// HEAD is detached from the branch
+					record.Branch = d.branch
+				}
+				record.CommitHash = head.Hash().String()
+				if commit, err := d.repo.CommitObject(head.Hash()); err == nil {
+					record.CommitMessage = strings.TrimSpace(commit.Message)
+				}
+			}
+		}
+		if record.Message == "" {
+			record.Message = record.CommitMessage
+		}
+		d.recordDeploy(cli, *conf, record, out)
+		return nil
+	}, nil
+}
+
+// Prewarm updates the repository and builds the project's image for the
+// latest commit in the background, without touching active containers, so
+// that a later deployment of the same commit can skip the build. The
+// pre-warmed commit is returned.
+func (d *Deployment) Prewarm(cli *docker.Client, out io.Writer) (string, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if err := git.UpdateRepository(d.repo, git.RepoOptions{
+		Directory: d.directory,
+		Branch:    d.branch,
+		Auth:      d.auth,
+	}, out); err != nil {
+		return "", err
+	}
+
+	conf, err := d.prepareBuild(out)
+	if err != nil {
+		return "", err
+	}
+	if conf.Revision == "" {
+		return "", errors.New("unable to determine commit to pre-warm")
+	}
+	return conf.Revision, d.builder.Prewarm(strings.ToLower(d.buildType), *conf, cli, out)
+}
+
+// validateComposeFile checks that the project's docker-compose file uses a
+// supported schema, if the project is built with docker-compose
+func (d *Deployment) validateComposeFile(out io.Writer) error {
+	if strings.ToLower(d.buildType) != "docker-compose" {
+		return nil
+	}
+	var path = d.buildFilePath
+	if path == "" {
+		path = build.DefaultComposeFile
+	}
+	version, err := build.ValidateComposeFile(filepath.Join(d.directory, path))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Detected docker-compose file version %s\n", version)
+	return nil
+}
+
+// prepareBuild retrieves the build configuration for the current commit,
+// along with the secrets configured for the build
+func (d *Deployment) prepareBuild(out io.Writer) (*build.Config, error) {
+	conf, err := d.GetBuildConfiguration()
+	if err != nil {
+		fmt.Fprintln(out, err.Error())
+		fmt.Fprintln(out, "Continuing...")
+	}
+	if d.repo != nil {
+		if head, err := d.repo.Head(); err == nil {
+			conf.Revision = head.Hash().String()
+		}
+	}
+
+	// Retrieve secrets for the build - these are only passed to the build
+	if len(d.buildSecrets) > 0 {
+		if d.dataManager == nil {
+			return nil, errors.New("no data manager")
+		}
+		if conf.Secrets, err = d.dataManager.GetSecrets(d.buildSecrets...); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// Promote loads images promoted from another remote and deploys them without
+// building anything. The deployment is recorded in the deployment history
+// along with its origin.
+func (d *Deployment) Promote(
+	cli *docker.Client,
+	out io.Writer,
+	images io.Reader,
+	req api.PromoteRequest,
+) (func() error, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	fmt.Fprintf(out, "Loading images promoted from %s\n", req.SourceRemote)
+
+	// Load images before touching active containers, so that the current
+	// deployment remains up if something goes wrong
+	if err := containers.LoadImages(cli, images, out); err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Kill active project containers if there are any
+	d.setActive(false)
+	d.stopMonitor()
+	if err := d.builder.StopContainers(cli, out); err != nil {
+		return func() error { return nil }, err
+	}
+
+	// Get config, applying the promoted deployment's feature flags
 	conf, err := d.GetBuildConfiguration()
 	if err != nil {
 		fmt.Fprintln(out, err.Error())
 		fmt.Fprintln(out, "Continuing...")
 	}
+	flagEnv, err := featureFlagEnv(req.Record.FeatureFlags)
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	conf.EnvValues = append(conf.EnvValues, flagEnv...)
+
+	// Create shared networks before anything references them
+	if err = containers.EnsureNetworks(cli, d.networks, out); err != nil {
+		return func() error { return nil }, err
+	}
 
-	// Build project
-	deploy, err := d.builder.Build(strings.ToLower(d.buildType), *conf, cli, out)
+	// Set up containers from promoted images
+	var buildType = strings.ToLower(req.Record.BuildType)
+	if buildType == "" {
+		buildType = strings.ToLower(d.buildType)
+	}
+	deploy, err := d.builder.Run(buildType, *conf, cli, out)
 	if err != nil {
 		return func() error { return nil }, err
 	}
 
 	// Deploy
 	return func() error {
-		d.active = true
-		return deploy()
+		d.setActive(true)
+		if err := deploy(); err != nil {
+			return err
+		}
+		d.connectNetworks(cli, out)
+		d.startMonitor(cli)
+
+		var message = req.Message
+		if message == "" {
+			message = req.Record.Message
+		}
+		d.recordDeploy(cli, *conf, api.DeployRecord{
+			Branch:        req.Record.Branch,
+			CommitHash:    req.Record.CommitHash,
+			CommitMessage: req.Record.CommitMessage,
+			BuildType:     buildType,
+			Message:       message,
+			FeatureFlags:  req.Record.FeatureFlags,
+			PromotedFrom: &api.Provenance{
+				Remote:   req.SourceRemote,
+				DeployID: req.Record.ID,
+			},
+		}, out)
+		return nil
 	}, nil
 }
 
+// startMonitor starts health monitoring of project containers, replacing the
+// active monitor if there is one
+func (d *Deployment) startMonitor(cli *docker.Client) {
+	var monitor = health.NewMonitor(cli, d.healthChecks, os.Stdout,
+		"/inertia-daemon", "/"+d.builder.GetBuildStageName(), "/docker-compose")
+	if d.onFlap != nil {
+		monitor.OnFlap(d.onFlap)
+	}
+
+	d.stateMux.Lock()
+	var previous = d.monitor
+	d.monitor = monitor
+	d.stateMux.Unlock()
+
+	if previous != nil {
+		previous.Stop()
+	}
+	monitor.Start()
+}
+
+// recordDeploy adds the given deployment to the deployment history, along with
+// the images it uses. Failures are reported but do not fail the deployment.
+func (d *Deployment) recordDeploy(cli *docker.Client, conf build.Config,
+	record api.DeployRecord, out io.Writer) {
+	d.stateMux.Lock()
+	d.message = record.Message
+	d.stateMux.Unlock()
+	if d.dataManager == nil {
+		return
+	}
+	record.Timestamp = time.Now()
+	record.Project = d.project
+	if env, err := d.dataManager.GetEnvVariables(true); err == nil {
+		record.Environment = envDigests(env)
+	}
+
+	images, err := d.builder.GetImages(record.BuildType, conf, cli)
+	if err != nil {
+		fmt.Fprintln(out, "unable to list deployed images: "+err.Error())
+	}
+	record.Images = make([]api.DeployImage, 0, len(images))
+	for _, image := range images {
+		if len(image.RepoTags) == 0 {
+			continue
+		}
+		record.Images = append(record.Images, api.DeployImage{
+			Name: image.RepoTags[0],
+			ID:   image.ID,
+		})
+	}
+
+	if err := d.dataManager.AddDeployRecord(&record, d.historyLimit); err != nil {
+		fmt.Fprintln(out, "unable to record deployment: "+err.Error())
+	}
+}
+
+// stopMonitor stops health monitoring if it is active
+func (d *Deployment) stopMonitor() {
+	d.stateMux.Lock()
+	var monitor = d.monitor
+	d.monitor = nil
+	d.stateMux.Unlock()
+
+	if monitor != nil {
+		monitor.Stop()
+	}
+}
+
+// getMonitor returns the active health monitor, or nil if there is none
+func (d *Deployment) getMonitor() *health.Monitor {
+	d.stateMux.RLock()
+	defer d.stateMux.RUnlock()
+	return d.monitor
+}
+
+// setActive marks whether project containers are expected to be running
+func (d *Deployment) setActive(active bool) {
+	d.stateMux.Lock()
+	d.active = active
+	d.stateMux.Unlock()
+}
+
+// isActive returns true if project containers are expected to be running
+func (d *Deployment) isActive() bool {
+	d.stateMux.RLock()
+	defer d.stateMux.RUnlock()
+	return d.active
+}
+
+// recentlyRestarted returns true if the health monitor recently restarted the
+// container with the given ID
+func (d *Deployment) recentlyRestarted(id string) bool {
+	var monitor = d.getMonitor()
+	return monitor != nil && monitor.RecentlyRestarted(id)
+}
+
 // Down shuts down the deployment
 func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	d.mux.Lock()
@@ -203,7 +571,8 @@ func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	// Error if no project containers are active, but try to kill
 	// everything anyway in case the docker-compose image is still
 	// active
-	d.active = false
+	d.setActive(false)
+	d.stopMonitor()
 	_, err := containers.GetActiveContainers(cli)
 	if err != nil {
 		killErr := d.builder.StopContainers(cli, out)
@@ -222,9 +591,55 @@ func (d *Deployment) Down(cli *docker.Client, out io.Writer) error {
 	return nil
 }
 
-// Prune clears unused Docker assets
-func (d *Deployment) Prune(cli *docker.Client, out io.Writer) error {
-	return d.builder.PruneAll(cli, out)
+// Restart restarts the project's containers, optionally in a rolling fashion,
+// and returns the names of the restarted containers
+func (d *Deployment) Restart(cli *docker.Client, out io.Writer,
+	opts health.RestartOptions) ([]string, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var monitor = d.getMonitor()
+	if !d.isActive() || monitor == nil {
+		return nil, errors.New("project is not active")
+	}
+	return monitor.Restart(out, opts)
+}
+
+// Resume resumes automatic restarts of the given crash-looping containers, or
+// all crash-looping containers if none are given, and returns the names of the
+// resumed containers
+func (d *Deployment) Resume(services []string) ([]string, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var monitor = d.getMonitor()
+	if !d.isActive() || monitor == nil {
+		return nil, errors.New("project is not active")
+	}
+	return monitor.Resume(services...), nil
+}
+
+// OnFlap sets a function that is called when the health monitor detects a
+// service that is restarted too often. It applies from the next deployment.
+func (d *Deployment) OnFlap(fn func(health.Flap)) {
+	d.onFlap = fn
+}
+
+// Prune removes stopped containers and dangling images created by Inertia for
+// this project. Running containers are left untouched, so the deployment can
+// be pruned while it is live.
+func (d *Deployment) Prune(cli *docker.Client, out io.Writer) (api.PruneReport, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	fmt.Fprintln(out, "Pruning stopped containers and dangling images...")
+	report, err := containers.Prune(cli, build.ProjectLabels(d.project)...)
+	if err != nil {
+		return report, err
+	}
+	fmt.Fprintf(out, "Removed %d container(s) and %d image(s), reclaiming %d bytes\n",
+		len(report.ContainersDeleted), len(report.ImagesDeleted), report.SpaceReclaimed)
+	return report, nil
 }
 
 // Destroy shuts down the deployment and removes the repository
@@ -281,16 +696,42 @@ func (d *Deployment) GetStatus(cli *docker.Client) (api.DeploymentStatus, error)
 		}
 	}
 
+	var healthStates map[string]string
+	if monitor := d.getMonitor(); monitor != nil {
+		healthStates = monitor.States()
+	}
+
+	d.stateMux.RLock()
+	var message = d.message
+	d.stateMux.RUnlock()
+
+	var networks map[string][]string
+	if len(d.networks) > 0 {
+		if networks, err = containers.NetworkMembers(cli, d.networks); err != nil {
+			return api.DeploymentStatus{Containers: activeContainers}, err
+		}
+	}
+
+	// HEAD is detached when a specific commit is checked out, such as when
+	// rolling back, so report the deployed branch rather than HEAD's name
 	return api.DeploymentStatus{
-		Branch:               strings.TrimSpace(head.Name().Short()),
+		Branch:               d.branch,
 		CommitHash:           strings.TrimSpace(head.Hash().String()),
 		CommitMessage:        strings.TrimSpace(commit.Message),
 		BuildType:            strings.TrimSpace(d.buildType),
+		DeployMessage:        message,
 		Containers:           activeContainers,
 		BuildContainerActive: buildContainerActive,
+		Health:               healthStates,
+		Networks:             networks,
 	}, nil
 }
 
+// GetProject returns the name of the deployed project
+func (d *Deployment) GetProject() string {
+	return d.project
+}
+
 // GetBranch returns the currently deployed branch
 func (d *Deployment) GetBranch() string {
 	return d.branch
@@ -329,6 +770,7 @@ func (d *Deployment) GetBuildConfiguration() (*build.Config, error) {
 		Name:           d.project,
 		BuildFilePath:  d.buildFilePath,
 		BuildDirectory: d.directory,
+		Plan:           d.buildPlan,
 	}
 	if d.dataManager != nil {
 		env, err := d.dataManager.GetEnvVariables(true)
@@ -375,9 +817,9 @@ func (d *Deployment) Watch(client *docker.Client) (<-chan string, <-chan error)
 					logsCh <- fmt.Sprintf("container %s has stopped", status.ID[:11])
 				}
 
-				if d.active {
+				if d.isActive() && !d.recentlyRestarted(status.ID) {
 					// Shut down all containers if one stops while project is active
-					d.active = false
+					d.setActive(false)
 					logsCh <- "container stoppage was unexpected, project is active"
 					err := containers.StopActiveContainers(client, os.Stdout)
 					if err != nil {
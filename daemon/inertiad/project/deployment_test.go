@@ -1,14 +1,22 @@
 package project
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	docker "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/build"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/build/mocks"
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/health"
 	gogit "gopkg.in/src-d/go-git.v4"
 )
 
@@ -29,12 +37,16 @@ func TestSetConfig(t *testing.T) {
 		Branch:        "amazing",
 		BuildType:     "best",
 		BuildFilePath: "/robertcompose.yml",
+		HealthChecks:  map[string]health.Config{"web": health.DefaultConfig()},
+		HistoryLimit:  10,
 	})
 
 	assert.Equal(t, "wow", deployment.project)
 	assert.Equal(t, "amazing", deployment.branch)
 	assert.Equal(t, "best", deployment.buildType)
 	assert.Equal(t, "/robertcompose.yml", deployment.buildFilePath)
+	assert.Equal(t, health.DefaultConfig(), deployment.healthChecks["web"])
+	assert.Equal(t, 10, deployment.historyLimit)
 }
 
 func TestDeployMock(t *testing.T) {
@@ -62,12 +74,72 @@ func TestDeployMock(t *testing.T) {
 	assert.Nil(t, err)
 	defer cli.Close()
 
-	deploy, err := d.Deploy(cli, os.Stdout, DeployOptions{SkipUpdate: true})
+	deploy, err := d.Deploy(cli, os.Stdout, DeployOptions{
+		SkipUpdate:   true,
+		Message:      "hotfix",
+		FeatureFlags: map[string]string{"beta": "on"},
+	})
 	assert.Nil(t, err)
 
 	deploy()
 	assert.Equal(t, true, buildCalled)
 	assert.Equal(t, true, stopCalled)
+	assert.Equal(t, "hotfix", d.message)
+	_, conf, _, _ := fakeBuilder.BuildArgsForCall(0)
+	assert.Contains(t, conf.EnvValues, "FEATURE_BETA=on")
+}
+
+func TestDeployMockPrewarmed(t *testing.T) {
+	var runCalled = false
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error { return nil })
+	fakeBuilder.PrewarmedReturns(true)
+	fakeBuilder.RunReturns(func() error {
+		runCalled = true
+		return nil
+	}, nil)
+	var d = Deployment{
+		directory: "./test/",
+		buildType: "dockerfile",
+		builder:   fakeBuilder,
+	}
+
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	deploy, err := d.Deploy(cli, os.Stdout, DeployOptions{SkipUpdate: true})
+	assert.Nil(t, err)
+
+	assert.Nil(t, deploy())
+	assert.True(t, runCalled)
+	assert.Equal(t, 0, fakeBuilder.BuildCallCount())
+}
+
+func TestDeployMockInvalidComposeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-deploy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "docker-compose.yml"),
+		[]byte("version: '3'\nservices: {}\nweb:\n  image: nginx\n"), 0644))
+
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error { return nil })
+	var d = Deployment{
+		directory: dir,
+		buildType: "docker-compose",
+		builder:   fakeBuilder,
+	}
+
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// active containers should be left alone
+	var out = &bytes.Buffer{}
+	_, err = d.Deploy(cli, out, DeployOptions{SkipUpdate: true})
+	assert.IsType(t, &build.ComposeFileError{}, err)
+	assert.Contains(t, out.String(), "unsupported top-level keys: web")
+	assert.Equal(t, 0, fakeBuilder.StopContainersCallCount())
+	assert.Equal(t, 0, fakeBuilder.BuildCallCount())
 }
 
 func TestDownIntegration(t *testing.T) {
@@ -114,6 +186,7 @@ func TestGetStatusIntegration(t *testing.T) {
 	var fakeBuilder = newDefaultFakeBuilder(nil, nil)
 	var deployment = &Deployment{
 		repo:      repo,
+		branch:    "master",
 		buildType: "test",
 		builder:   fakeBuilder,
 	}
@@ -121,6 +194,60 @@ func TestGetStatusIntegration(t *testing.T) {
 	assert.Nil(t, err)
 	assert.False(t, status.BuildContainerActive)
 	assert.Equal(t, "test", status.BuildType)
+
+	// the deployed branch is reported, even if HEAD is detached
+	assert.Equal(t, "master", status.Branch)
+}
+
+func TestDeployConcurrentStatusIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	repo, err := gogit.PlainOpen("../../../")
+	assert.Nil(t, err)
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	var d = &Deployment{
+		directory: "./test/",
+		buildType: "test",
+		repo:      repo,
+		builder:   newDefaultFakeBuilder(func() error { return nil }, func() error { return nil }),
+	}
+	d.Watch(cli)
+
+	// health monitors are replaced by deploys while status is read, which
+	// should be caught by the race detector if unguarded
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				d.GetStatus(cli)
+				d.recentlyRestarted("abcde")
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		deploy, err := d.Deploy(cli, ioutil.Discard, DeployOptions{SkipUpdate: true})
+		assert.Nil(t, err)
+		assert.Nil(t, deploy())
+	}
+	close(done)
+	wg.Wait()
+
+	assert.NotNil(t, d.getMonitor())
+	d.stopMonitor()
+	assert.Nil(t, d.getMonitor())
 }
 
 func TestGetBranch(t *testing.T) {
@@ -153,3 +280,35 @@ func TestDeployment_CompareRemotes(t *testing.T) {
 		})
 	}
 }
+
+func TestPromoteMock(t *testing.T) {
+	var stopCalled = false
+	var fakeBuilder = newDefaultFakeBuilder(nil, func() error {
+		stopCalled = true
+		return nil
+	})
+	var runCalled = false
+	fakeBuilder.RunReturns(func() error {
+		runCalled = true
+		return nil
+	}, nil)
+	var d = Deployment{
+		directory: "./test/",
+		buildType: "test",
+		builder:   fakeBuilder,
+	}
+
+	cli, err := containers.NewDockerClient()
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	// Images that fail to load should leave the deployment untouched
+	_, err = d.Promote(cli, os.Stdout, strings.NewReader("not a tarball"), api.PromoteRequest{
+		SourceRemote: "staging",
+		Record:       api.DeployRecord{ID: "1", BuildType: "dockerfile"},
+	})
+	assert.NotNil(t, err)
+	assert.False(t, stopCalled)
+	assert.False(t, runCalled)
+	assert.Zero(t, fakeBuilder.BuildCallCount())
+}
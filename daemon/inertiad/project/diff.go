@@ -0,0 +1,91 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/ubclaunchpad/inertia/api"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/git"
+)
+
+// envDigestLength is the number of hex characters of each environment value's
+// digest kept in deployment records
+const envDigestLength = 12
+
+// Diff compares two recorded deployments, including a patch of the changes
+// between their commits if both are available in the repository
+func (d *Deployment) Diff(from, to api.DeployRecord) api.DeployDiff {
+	var diff = diffRecords(from, to)
+	switch {
+	case d.repo == nil:
+		diff.GitDiffError = "no repository available"
+	case from.CommitHash == "" || to.CommitHash == "":
+		diff.GitDiffError = "deployment commits were not recorded"
+	default:
+		patch, err := git.Diff(d.repo, from.CommitHash, to.CommitHash)
+		if err != nil {
+			diff.GitDiffError = err.Error()
+		} else {
+			diff.GitDiff = patch
+		}
+	}
+	return diff
+}
+
+// diffRecords compares the environment, images, and feature flags of two
+// recorded deployments
+func diffRecords(from, to api.DeployRecord) api.DeployDiff {
+	var fromImages = make(map[string]string, len(from.Images))
+	for _, image := range from.Images {
+		fromImages[image.Name] = image.ID
+	}
+	var toImages = make(map[string]string, len(to.Images))
+	for _, image := range to.Images {
+		toImages[image.Name] = image.ID
+	}
+	return api.DeployDiff{
+		From:         from,
+		To:           to,
+		Environment:  diffValues(from.Environment, to.Environment),
+		Images:       diffValues(fromImages, toImages),
+		FeatureFlags: diffValues(from.FeatureFlags, to.FeatureFlags),
+	}
+}
+
+// diffValues returns the changes between two sets of named values, sorted
+// by name
+func diffValues(from, to map[string]string) []api.Change {
+	var changes = make([]api.Change, 0)
+	for name, value := range from {
+		if toValue, found := to[name]; !found || toValue != value {
+			changes = append(changes, api.Change{Name: name, From: value, To: toValue})
+		}
+	}
+	for name, value := range to {
+		if _, found := from[name]; !found {
+			changes = append(changes, api.Change{Name: name, To: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// envDigests returns digests of the values of the given environment variables,
+// in the form 'NAME=value', keyed by name
+func envDigests(env []string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	var digests = make(map[string]string, len(env))
+	for _, e := range env {
+		var kv = strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var sum = sha256.Sum256([]byte(kv[1]))
+		digests[kv[0]] = hex.EncodeToString(sum[:])[:envDigestLength]
+	}
+	return digests
+}
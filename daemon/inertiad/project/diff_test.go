@@ -0,0 +1,49 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func Test_diffRecords(t *testing.T) {
+	var from = api.DeployRecord{
+		ID:           "1",
+		Images:       []api.DeployImage{{Name: "web", ID: "sha256:1234"}, {Name: "db", ID: "sha256:5678"}},
+		Environment:  envDigests([]string{"PORT=8080", "SECRET=hunter2"}),
+		FeatureFlags: map[string]string{"beta": "off"},
+	}
+	var to = api.DeployRecord{
+		ID:           "2",
+		Images:       []api.DeployImage{{Name: "web", ID: "sha256:abcd"}, {Name: "db", ID: "sha256:5678"}},
+		Environment:  envDigests([]string{"PORT=8080", "SECRET=hunter3", "DEBUG=true"}),
+		FeatureFlags: map[string]string{"beta": "off"},
+	}
+
+	var diff = diffRecords(from, to)
+	assert.Equal(t, "1", diff.From.ID)
+	assert.Equal(t, []api.Change{{Name: "web", From: "sha256:1234", To: "sha256:abcd"}}, diff.Images)
+	assert.Empty(t, diff.FeatureFlags)
+	assert.Len(t, diff.Environment, 2)
+	assert.Equal(t, "DEBUG", diff.Environment[0].Name)
+	assert.Empty(t, diff.Environment[0].From)
+	assert.Equal(t, "SECRET", diff.Environment[1].Name)
+	assert.NotContains(t, diff.Environment[1].To, "hunter3")
+
+	// no repository available for a git diff
+	var d = &Deployment{}
+	assert.NotEmpty(t, d.Diff(from, to).GitDiffError)
+}
+
+func Test_diffValues(t *testing.T) {
+	assert.Equal(t, []api.Change{
+		{Name: "a", From: "1"},
+		{Name: "b", From: "2", To: "3"},
+		{Name: "c", To: "4"},
+	}, diffValues(
+		map[string]string{"a": "1", "b": "2", "d": "5"},
+		map[string]string{"b": "3", "c": "4", "d": "5"},
+	))
+	assert.Empty(t, diffValues(nil, nil))
+}
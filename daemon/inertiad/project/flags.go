@@ -0,0 +1,59 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// EnvFeatureFlags is the environment variable containing all feature flags
+	// of a deployment as a JSON object
+	EnvFeatureFlags = "INERTIA_FEATURE_FLAGS"
+
+	// featureFlagPrefix prefixes the environment variable set for each
+	// individual feature flag
+	featureFlagPrefix = "FEATURE_"
+)
+
+var featureFlagName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// ValidateFeatureFlags returns an error if any of the given feature flags have
+// invalid names. Names must start with a letter, and contain only letters,
+// digits, and underscores.
+func ValidateFeatureFlags(flags map[string]string) error {
+	var seen = make(map[string]string, len(flags))
+	for name := range flags {
+		if !featureFlagName.MatchString(name) {
+			return fmt.Errorf("invalid feature flag name '%s'", name)
+		}
+		// names map to environment variables case-insensitively
+		var key = strings.ToUpper(name)
+		if other, found := seen[key]; found {
+			return fmt.Errorf("feature flags '%s' and '%s' conflict", other, name)
+		}
+		seen[key] = name
+	}
+	return nil
+}
+
+// featureFlagEnv returns the environment variables used to pass the given
+// feature flags to project containers - one variable per flag, as well as
+// EnvFeatureFlags containing all flags.
+func featureFlagEnv(flags map[string]string) ([]string, error) {
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(flags)
+	if err != nil {
+		return nil, err
+	}
+	var env = make([]string, 0, len(flags)+1)
+	for name, value := range flags {
+		env = append(env, featureFlagPrefix+strings.ToUpper(name)+"="+value)
+	}
+	sort.Strings(env)
+	return append(env, EnvFeatureFlags+"="+string(encoded)), nil
+}
@@ -0,0 +1,30 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFeatureFlags(t *testing.T) {
+	assert.Nil(t, ValidateFeatureFlags(nil))
+	assert.Nil(t, ValidateFeatureFlags(map[string]string{"new_login": "true", "Beta2": "off"}))
+	assert.Error(t, ValidateFeatureFlags(map[string]string{"2fast": "true"}))
+	assert.Error(t, ValidateFeatureFlags(map[string]string{"new-login": "true"}))
+	assert.Error(t, ValidateFeatureFlags(map[string]string{"": "true"}))
+	assert.Error(t, ValidateFeatureFlags(map[string]string{"beta": "on", "BETA": "off"}))
+}
+
+func Test_featureFlagEnv(t *testing.T) {
+	env, err := featureFlagEnv(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, env)
+
+	env, err = featureFlagEnv(map[string]string{"new_login": "true", "theme": "dark"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"FEATURE_NEW_LOGIN=true",
+		"FEATURE_THEME=dark",
+		`INERTIA_FEATURE_FLAGS={"new_login":"true","theme":"dark"}`,
+	}, env)
+}
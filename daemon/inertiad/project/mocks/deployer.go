@@ -7,6 +7,7 @@ import (
 
 	client "github.com/docker/docker/client"
 	api "github.com/ubclaunchpad/inertia/api"
+	health "github.com/ubclaunchpad/inertia/daemon/inertiad/health"
 	project "github.com/ubclaunchpad/inertia/daemon/inertiad/project"
 )
 
@@ -22,6 +23,19 @@ type FakeDeployer struct {
 	compareRemotesReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ComposeConfigStub        func(*client.Client, string, io.Writer) error
+	composeConfigMutex       sync.RWMutex
+	composeConfigArgsForCall []struct {
+		arg1 *client.Client
+		arg2 string
+		arg3 io.Writer
+	}
+	composeConfigReturns struct {
+		result1 error
+	}
+	composeConfigReturnsOnCall map[int]struct {
+		result1 error
+	}
 	DeployStub        func(*client.Client, io.Writer, project.DeployOptions) (func() error, error)
 	deployMutex       sync.RWMutex
 	deployArgsForCall []struct {
@@ -49,6 +63,18 @@ type FakeDeployer struct {
 	destroyReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DiffStub        func(api.DeployRecord, api.DeployRecord) api.DeployDiff
+	diffMutex       sync.RWMutex
+	diffArgsForCall []struct {
+		arg1 api.DeployRecord
+		arg2 api.DeployRecord
+	}
+	diffReturns struct {
+		result1 api.DeployDiff
+	}
+	diffReturnsOnCall map[int]struct {
+		result1 api.DeployDiff
+	}
 	DownStub        func(*client.Client, io.Writer) error
 	downMutex       sync.RWMutex
 	downArgsForCall []struct {
@@ -83,6 +109,16 @@ type FakeDeployer struct {
 		result1 *project.DeploymentDataManager
 		result2 bool
 	}
+	GetProjectStub        func() string
+	getProjectMutex       sync.RWMutex
+	getProjectArgsForCall []struct {
+	}
+	getProjectReturns struct {
+		result1 string
+	}
+	getProjectReturnsOnCall map[int]struct {
+		result1 string
+	}
 	GetStatusStub        func(*client.Client) (api.DeploymentStatus, error)
 	getStatusMutex       sync.RWMutex
 	getStatusArgsForCall []struct {
@@ -108,17 +144,82 @@ type FakeDeployer struct {
 	initializeReturnsOnCall map[int]struct {
 		result1 error
 	}
-	PruneStub        func(*client.Client, io.Writer) error
+	OnFlapStub        func(func(health.Flap))
+	onFlapMutex       sync.RWMutex
+	onFlapArgsForCall []struct {
+		arg1 func(health.Flap)
+	}
+	PrewarmStub        func(*client.Client, io.Writer) (string, error)
+	prewarmMutex       sync.RWMutex
+	prewarmArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+	}
+	prewarmReturns struct {
+		result1 string
+		result2 error
+	}
+	prewarmReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	PromoteStub        func(*client.Client, io.Writer, io.Reader, api.PromoteRequest) (func() error, error)
+	promoteMutex       sync.RWMutex
+	promoteArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 io.Reader
+		arg4 api.PromoteRequest
+	}
+	promoteReturns struct {
+		result1 func() error
+		result2 error
+	}
+	promoteReturnsOnCall map[int]struct {
+		result1 func() error
+		result2 error
+	}
+	PruneStub        func(*client.Client, io.Writer) (api.PruneReport, error)
 	pruneMutex       sync.RWMutex
 	pruneArgsForCall []struct {
 		arg1 *client.Client
 		arg2 io.Writer
 	}
 	pruneReturns struct {
-		result1 error
+		result1 api.PruneReport
+		result2 error
 	}
 	pruneReturnsOnCall map[int]struct {
-		result1 error
+		result1 api.PruneReport
+		result2 error
+	}
+	RestartStub        func(*client.Client, io.Writer, health.RestartOptions) ([]string, error)
+	restartMutex       sync.RWMutex
+	restartArgsForCall []struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 health.RestartOptions
+	}
+	restartReturns struct {
+		result1 []string
+		result2 error
+	}
+	restartReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	ResumeStub        func([]string) ([]string, error)
+	resumeMutex       sync.RWMutex
+	resumeArgsForCall []struct {
+		arg1 []string
+	}
+	resumeReturns struct {
+		result1 []string
+		result2 error
+	}
+	resumeReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
 	}
 	SetConfigStub        func(project.DeploymentConfig)
 	setConfigMutex       sync.RWMutex
@@ -202,6 +303,68 @@ func (fake *FakeDeployer) CompareRemotesReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDeployer) ComposeConfig(arg1 *client.Client, arg2 string, arg3 io.Writer) error {
+	fake.composeConfigMutex.Lock()
+	ret, specificReturn := fake.composeConfigReturnsOnCall[len(fake.composeConfigArgsForCall)]
+	fake.composeConfigArgsForCall = append(fake.composeConfigArgsForCall, struct {
+		arg1 *client.Client
+		arg2 string
+		arg3 io.Writer
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("ComposeConfig", []interface{}{arg1, arg2, arg3})
+	fake.composeConfigMutex.Unlock()
+	if fake.ComposeConfigStub != nil {
+		return fake.ComposeConfigStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.composeConfigReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeDeployer) ComposeConfigCallCount() int {
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
+	return len(fake.composeConfigArgsForCall)
+}
+
+func (fake *FakeDeployer) ComposeConfigCalls(stub func(*client.Client, string, io.Writer) error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = stub
+}
+
+func (fake *FakeDeployer) ComposeConfigArgsForCall(i int) (*client.Client, string, io.Writer) {
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
+	argsForCall := fake.composeConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeDeployer) ComposeConfigReturns(result1 error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = nil
+	fake.composeConfigReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDeployer) ComposeConfigReturnsOnCall(i int, result1 error) {
+	fake.composeConfigMutex.Lock()
+	defer fake.composeConfigMutex.Unlock()
+	fake.ComposeConfigStub = nil
+	if fake.composeConfigReturnsOnCall == nil {
+		fake.composeConfigReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.composeConfigReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeDeployer) Deploy(arg1 *client.Client, arg2 io.Writer, arg3 project.DeployOptions) (func() error, error) {
 	fake.deployMutex.Lock()
 	ret, specificReturn := fake.deployReturnsOnCall[len(fake.deployArgsForCall)]
@@ -328,6 +491,67 @@ func (fake *FakeDeployer) DestroyReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeDeployer) Diff(arg1 api.DeployRecord, arg2 api.DeployRecord) api.DeployDiff {
+	fake.diffMutex.Lock()
+	ret, specificReturn := fake.diffReturnsOnCall[len(fake.diffArgsForCall)]
+	fake.diffArgsForCall = append(fake.diffArgsForCall, struct {
+		arg1 api.DeployRecord
+		arg2 api.DeployRecord
+	}{arg1, arg2})
+	fake.recordInvocation("Diff", []interface{}{arg1, arg2})
+	fake.diffMutex.Unlock()
+	if fake.DiffStub != nil {
+		return fake.DiffStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.diffReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeDeployer) DiffCallCount() int {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	return len(fake.diffArgsForCall)
+}
+
+func (fake *FakeDeployer) DiffCalls(stub func(api.DeployRecord, api.DeployRecord) api.DeployDiff) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = stub
+}
+
+func (fake *FakeDeployer) DiffArgsForCall(i int) (api.DeployRecord, api.DeployRecord) {
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
+	argsForCall := fake.diffArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeDeployer) DiffReturns(result1 api.DeployDiff) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	fake.diffReturns = struct {
+		result1 api.DeployDiff
+	}{result1}
+}
+
+func (fake *FakeDeployer) DiffReturnsOnCall(i int, result1 api.DeployDiff) {
+	fake.diffMutex.Lock()
+	defer fake.diffMutex.Unlock()
+	fake.DiffStub = nil
+	if fake.diffReturnsOnCall == nil {
+		fake.diffReturnsOnCall = make(map[int]struct {
+			result1 api.DeployDiff
+		})
+	}
+	fake.diffReturnsOnCall[i] = struct {
+		result1 api.DeployDiff
+	}{result1}
+}
+
 func (fake *FakeDeployer) Down(arg1 *client.Client, arg2 io.Writer) error {
 	fake.downMutex.Lock()
 	ret, specificReturn := fake.downReturnsOnCall[len(fake.downArgsForCall)]
@@ -496,6 +720,58 @@ func (fake *FakeDeployer) GetDataManagerReturnsOnCall(i int, result1 *project.De
 	}{result1, result2}
 }
 
+func (fake *FakeDeployer) GetProject() string {
+	fake.getProjectMutex.Lock()
+	ret, specificReturn := fake.getProjectReturnsOnCall[len(fake.getProjectArgsForCall)]
+	fake.getProjectArgsForCall = append(fake.getProjectArgsForCall, struct {
+	}{})
+	fake.recordInvocation("GetProject", []interface{}{})
+	fake.getProjectMutex.Unlock()
+	if fake.GetProjectStub != nil {
+		return fake.GetProjectStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.getProjectReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeDeployer) GetProjectCallCount() int {
+	fake.getProjectMutex.RLock()
+	defer fake.getProjectMutex.RUnlock()
+	return len(fake.getProjectArgsForCall)
+}
+
+func (fake *FakeDeployer) GetProjectCalls(stub func() string) {
+	fake.getProjectMutex.Lock()
+	defer fake.getProjectMutex.Unlock()
+	fake.GetProjectStub = stub
+}
+
+func (fake *FakeDeployer) GetProjectReturns(result1 string) {
+	fake.getProjectMutex.Lock()
+	defer fake.getProjectMutex.Unlock()
+	fake.GetProjectStub = nil
+	fake.getProjectReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeDeployer) GetProjectReturnsOnCall(i int, result1 string) {
+	fake.getProjectMutex.Lock()
+	defer fake.getProjectMutex.Unlock()
+	fake.GetProjectStub = nil
+	if fake.getProjectReturnsOnCall == nil {
+		fake.getProjectReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.getProjectReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeDeployer) GetStatus(arg1 *client.Client) (api.DeploymentStatus, error) {
 	fake.getStatusMutex.Lock()
 	ret, specificReturn := fake.getStatusReturnsOnCall[len(fake.getStatusArgsForCall)]
@@ -620,7 +896,168 @@ func (fake *FakeDeployer) InitializeReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeDeployer) Prune(arg1 *client.Client, arg2 io.Writer) error {
+func (fake *FakeDeployer) OnFlap(arg1 func(health.Flap)) {
+	fake.onFlapMutex.Lock()
+	fake.onFlapArgsForCall = append(fake.onFlapArgsForCall, struct {
+		arg1 func(health.Flap)
+	}{arg1})
+	fake.recordInvocation("OnFlap", []interface{}{arg1})
+	fake.onFlapMutex.Unlock()
+	if fake.OnFlapStub != nil {
+		fake.OnFlapStub(arg1)
+	}
+}
+
+func (fake *FakeDeployer) OnFlapCallCount() int {
+	fake.onFlapMutex.RLock()
+	defer fake.onFlapMutex.RUnlock()
+	return len(fake.onFlapArgsForCall)
+}
+
+func (fake *FakeDeployer) OnFlapCalls(stub func(func(health.Flap))) {
+	fake.onFlapMutex.Lock()
+	defer fake.onFlapMutex.Unlock()
+	fake.OnFlapStub = stub
+}
+
+func (fake *FakeDeployer) OnFlapArgsForCall(i int) func(health.Flap) {
+	fake.onFlapMutex.RLock()
+	defer fake.onFlapMutex.RUnlock()
+	argsForCall := fake.onFlapArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeployer) Prewarm(arg1 *client.Client, arg2 io.Writer) (string, error) {
+	fake.prewarmMutex.Lock()
+	ret, specificReturn := fake.prewarmReturnsOnCall[len(fake.prewarmArgsForCall)]
+	fake.prewarmArgsForCall = append(fake.prewarmArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+	}{arg1, arg2})
+	fake.recordInvocation("Prewarm", []interface{}{arg1, arg2})
+	fake.prewarmMutex.Unlock()
+	if fake.PrewarmStub != nil {
+		return fake.PrewarmStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.prewarmReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PrewarmCallCount() int {
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	return len(fake.prewarmArgsForCall)
+}
+
+func (fake *FakeDeployer) PrewarmCalls(stub func(*client.Client, io.Writer) (string, error)) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = stub
+}
+
+func (fake *FakeDeployer) PrewarmArgsForCall(i int) (*client.Client, io.Writer) {
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	argsForCall := fake.prewarmArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeDeployer) PrewarmReturns(result1 string, result2 error) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = nil
+	fake.prewarmReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PrewarmReturnsOnCall(i int, result1 string, result2 error) {
+	fake.prewarmMutex.Lock()
+	defer fake.prewarmMutex.Unlock()
+	fake.PrewarmStub = nil
+	if fake.prewarmReturnsOnCall == nil {
+		fake.prewarmReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.prewarmReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Promote(arg1 *client.Client, arg2 io.Writer, arg3 io.Reader, arg4 api.PromoteRequest) (func() error, error) {
+	fake.promoteMutex.Lock()
+	ret, specificReturn := fake.promoteReturnsOnCall[len(fake.promoteArgsForCall)]
+	fake.promoteArgsForCall = append(fake.promoteArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 io.Reader
+		arg4 api.PromoteRequest
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("Promote", []interface{}{arg1, arg2, arg3, arg4})
+	fake.promoteMutex.Unlock()
+	if fake.PromoteStub != nil {
+		return fake.PromoteStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.promoteReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) PromoteCallCount() int {
+	fake.promoteMutex.RLock()
+	defer fake.promoteMutex.RUnlock()
+	return len(fake.promoteArgsForCall)
+}
+
+func (fake *FakeDeployer) PromoteCalls(stub func(*client.Client, io.Writer, io.Reader, api.PromoteRequest) (func() error, error)) {
+	fake.promoteMutex.Lock()
+	defer fake.promoteMutex.Unlock()
+	fake.PromoteStub = stub
+}
+
+func (fake *FakeDeployer) PromoteArgsForCall(i int) (*client.Client, io.Writer, io.Reader, api.PromoteRequest) {
+	fake.promoteMutex.RLock()
+	defer fake.promoteMutex.RUnlock()
+	argsForCall := fake.promoteArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeDeployer) PromoteReturns(result1 func() error, result2 error) {
+	fake.promoteMutex.Lock()
+	defer fake.promoteMutex.Unlock()
+	fake.PromoteStub = nil
+	fake.promoteReturns = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) PromoteReturnsOnCall(i int, result1 func() error, result2 error) {
+	fake.promoteMutex.Lock()
+	defer fake.promoteMutex.Unlock()
+	fake.PromoteStub = nil
+	if fake.promoteReturnsOnCall == nil {
+		fake.promoteReturnsOnCall = make(map[int]struct {
+			result1 func() error
+			result2 error
+		})
+	}
+	fake.promoteReturnsOnCall[i] = struct {
+		result1 func() error
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Prune(arg1 *client.Client, arg2 io.Writer) (api.PruneReport, error) {
 	fake.pruneMutex.Lock()
 	ret, specificReturn := fake.pruneReturnsOnCall[len(fake.pruneArgsForCall)]
 	fake.pruneArgsForCall = append(fake.pruneArgsForCall, struct {
@@ -633,10 +1070,10 @@ func (fake *FakeDeployer) Prune(arg1 *client.Client, arg2 io.Writer) error {
 		return fake.PruneStub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
 	fakeReturns := fake.pruneReturns
-	return fakeReturns.result1
+	return fakeReturns.result1, fakeReturns.result2
 }
 
 func (fake *FakeDeployer) PruneCallCount() int {
@@ -645,7 +1082,7 @@ func (fake *FakeDeployer) PruneCallCount() int {
 	return len(fake.pruneArgsForCall)
 }
 
-func (fake *FakeDeployer) PruneCalls(stub func(*client.Client, io.Writer) error) {
+func (fake *FakeDeployer) PruneCalls(stub func(*client.Client, io.Writer) (api.PruneReport, error)) {
 	fake.pruneMutex.Lock()
 	defer fake.pruneMutex.Unlock()
 	fake.PruneStub = stub
@@ -658,27 +1095,163 @@ func (fake *FakeDeployer) PruneArgsForCall(i int) (*client.Client, io.Writer) {
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeDeployer) PruneReturns(result1 error) {
+func (fake *FakeDeployer) PruneReturns(result1 api.PruneReport, result2 error) {
 	fake.pruneMutex.Lock()
 	defer fake.pruneMutex.Unlock()
 	fake.PruneStub = nil
 	fake.pruneReturns = struct {
-		result1 error
-	}{result1}
+		result1 api.PruneReport
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeDeployer) PruneReturnsOnCall(i int, result1 error) {
+func (fake *FakeDeployer) PruneReturnsOnCall(i int, result1 api.PruneReport, result2 error) {
 	fake.pruneMutex.Lock()
 	defer fake.pruneMutex.Unlock()
 	fake.PruneStub = nil
 	if fake.pruneReturnsOnCall == nil {
 		fake.pruneReturnsOnCall = make(map[int]struct {
-			result1 error
+			result1 api.PruneReport
+			result2 error
 		})
 	}
 	fake.pruneReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
+		result1 api.PruneReport
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Restart(arg1 *client.Client, arg2 io.Writer, arg3 health.RestartOptions) ([]string, error) {
+	fake.restartMutex.Lock()
+	ret, specificReturn := fake.restartReturnsOnCall[len(fake.restartArgsForCall)]
+	fake.restartArgsForCall = append(fake.restartArgsForCall, struct {
+		arg1 *client.Client
+		arg2 io.Writer
+		arg3 health.RestartOptions
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Restart", []interface{}{arg1, arg2, arg3})
+	fake.restartMutex.Unlock()
+	if fake.RestartStub != nil {
+		return fake.RestartStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.restartReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) RestartCallCount() int {
+	fake.restartMutex.RLock()
+	defer fake.restartMutex.RUnlock()
+	return len(fake.restartArgsForCall)
+}
+
+func (fake *FakeDeployer) RestartCalls(stub func(*client.Client, io.Writer, health.RestartOptions) ([]string, error)) {
+	fake.restartMutex.Lock()
+	defer fake.restartMutex.Unlock()
+	fake.RestartStub = stub
+}
+
+func (fake *FakeDeployer) RestartArgsForCall(i int) (*client.Client, io.Writer, health.RestartOptions) {
+	fake.restartMutex.RLock()
+	defer fake.restartMutex.RUnlock()
+	argsForCall := fake.restartArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeDeployer) RestartReturns(result1 []string, result2 error) {
+	fake.restartMutex.Lock()
+	defer fake.restartMutex.Unlock()
+	fake.RestartStub = nil
+	fake.restartReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) RestartReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.restartMutex.Lock()
+	defer fake.restartMutex.Unlock()
+	fake.RestartStub = nil
+	if fake.restartReturnsOnCall == nil {
+		fake.restartReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.restartReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) Resume(arg1 []string) ([]string, error) {
+	var arg1Copy []string
+	if arg1 != nil {
+		arg1Copy = make([]string, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.resumeMutex.Lock()
+	ret, specificReturn := fake.resumeReturnsOnCall[len(fake.resumeArgsForCall)]
+	fake.resumeArgsForCall = append(fake.resumeArgsForCall, struct {
+		arg1 []string
+	}{arg1Copy})
+	fake.recordInvocation("Resume", []interface{}{arg1Copy})
+	fake.resumeMutex.Unlock()
+	if fake.ResumeStub != nil {
+		return fake.ResumeStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.resumeReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeDeployer) ResumeCallCount() int {
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
+	return len(fake.resumeArgsForCall)
+}
+
+func (fake *FakeDeployer) ResumeCalls(stub func([]string) ([]string, error)) {
+	fake.resumeMutex.Lock()
+	defer fake.resumeMutex.Unlock()
+	fake.ResumeStub = stub
+}
+
+func (fake *FakeDeployer) ResumeArgsForCall(i int) []string {
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
+	argsForCall := fake.resumeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeDeployer) ResumeReturns(result1 []string, result2 error) {
+	fake.resumeMutex.Lock()
+	defer fake.resumeMutex.Unlock()
+	fake.ResumeStub = nil
+	fake.resumeReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDeployer) ResumeReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.resumeMutex.Lock()
+	defer fake.resumeMutex.Unlock()
+	fake.ResumeStub = nil
+	if fake.resumeReturnsOnCall == nil {
+		fake.resumeReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.resumeReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *FakeDeployer) SetConfig(arg1 project.DeploymentConfig) {
@@ -780,22 +1353,38 @@ func (fake *FakeDeployer) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.compareRemotesMutex.RLock()
 	defer fake.compareRemotesMutex.RUnlock()
+	fake.composeConfigMutex.RLock()
+	defer fake.composeConfigMutex.RUnlock()
 	fake.deployMutex.RLock()
 	defer fake.deployMutex.RUnlock()
 	fake.destroyMutex.RLock()
 	defer fake.destroyMutex.RUnlock()
+	fake.diffMutex.RLock()
+	defer fake.diffMutex.RUnlock()
 	fake.downMutex.RLock()
 	defer fake.downMutex.RUnlock()
 	fake.getBranchMutex.RLock()
 	defer fake.getBranchMutex.RUnlock()
 	fake.getDataManagerMutex.RLock()
 	defer fake.getDataManagerMutex.RUnlock()
+	fake.getProjectMutex.RLock()
+	defer fake.getProjectMutex.RUnlock()
 	fake.getStatusMutex.RLock()
 	defer fake.getStatusMutex.RUnlock()
 	fake.initializeMutex.RLock()
 	defer fake.initializeMutex.RUnlock()
+	fake.onFlapMutex.RLock()
+	defer fake.onFlapMutex.RUnlock()
+	fake.prewarmMutex.RLock()
+	defer fake.prewarmMutex.RUnlock()
+	fake.promoteMutex.RLock()
+	defer fake.promoteMutex.RUnlock()
 	fake.pruneMutex.RLock()
 	defer fake.pruneMutex.RUnlock()
+	fake.restartMutex.RLock()
+	defer fake.restartMutex.RUnlock()
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
 	fake.setConfigMutex.RLock()
 	defer fake.setConfigMutex.RUnlock()
 	fake.watchMutex.RLock()
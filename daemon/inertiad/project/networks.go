@@ -0,0 +1,42 @@
+package project
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	docker "github.com/docker/docker/client"
+	"github.com/ubclaunchpad/inertia/daemon/inertiad/containers"
+)
+
+// connectNetworks attaches active project containers to the deployment's
+// shared networks. Containers that declare the networks themselves, such as
+// docker-compose services using external networks, are already attached.
+// Failures are reported but do not fail the deployment, since the project is
+// already running.
+func (d *Deployment) connectNetworks(cli *docker.Client, out io.Writer) {
+	if len(d.networks) == 0 {
+		return
+	}
+	active, err := containers.GetActiveContainers(cli)
+	if err != nil {
+		if err != containers.ErrNoContainers {
+			fmt.Fprintln(out, "failed to attach shared networks: "+err.Error())
+		}
+		return
+	}
+
+	var ignore = map[string]bool{
+		"/inertia-daemon":                   true,
+		"/" + d.builder.GetBuildStageName(): true,
+	}
+	var project = make([]types.Container, 0, len(active))
+	for _, c := range active {
+		if !ignore[c.Names[0]] {
+			project = append(project, c)
+		}
+	}
+	if err := containers.ConnectNetworks(cli, d.networks, project); err != nil {
+		fmt.Fprintln(out, "failed to attach shared networks: "+err.Error())
+	}
+}
@@ -2,6 +2,8 @@ package res
 
 import (
 	"net/http"
+
+	"github.com/ubclaunchpad/inertia/api"
 )
 
 // ErrResponse is the template for a typical HTTP response for errors
@@ -9,35 +11,71 @@ type ErrResponse struct {
 	*BaseResponse
 }
 
+func newErrResponse(message string, code int, kvs []interface{}) *ErrResponse {
+	var b = newBaseResponse(message, code, kvs)
+	b.ErrCode = defaultErrCode(code)
+	return &ErrResponse{b}
+}
+
 // Err is a basic error response constructor
 func Err(message string, code int, kvs ...interface{}) *ErrResponse {
-	return &ErrResponse{newBaseResponse(message, code, kvs)}
+	return newErrResponse(message, code, kvs)
 }
 
 // ErrInternalServer is a shortcut for internal server errors. It should be
 // accompanied by an actual error.
 func ErrInternalServer(message string, err error, kvs ...interface{}) *ErrResponse {
-	var b = newBaseResponse(message, http.StatusInternalServerError, kvs)
-	b.Err = err.Error()
-	return &ErrResponse{b}
+	var e = newErrResponse(message, http.StatusInternalServerError, kvs)
+	e.Err = err.Error()
+	return e
 }
 
 // ErrBadRequest is a shortcut for bad requests
 func ErrBadRequest(message string, kvs ...interface{}) *ErrResponse {
-	return &ErrResponse{newBaseResponse(message, http.StatusBadRequest, kvs)}
+	return newErrResponse(message, http.StatusBadRequest, kvs)
 }
 
 // ErrUnauthorized is a shortcut for unauthorized requests
 func ErrUnauthorized(message string, kvs ...interface{}) *ErrResponse {
-	return &ErrResponse{newBaseResponse(message, http.StatusUnauthorized, kvs)}
+	return newErrResponse(message, http.StatusUnauthorized, kvs)
 }
 
 // ErrForbidden is a shortcut for forbidden requests
 func ErrForbidden(message string, kvs ...interface{}) *ErrResponse {
-	return &ErrResponse{newBaseResponse(message, http.StatusForbidden, kvs)}
+	return newErrResponse(message, http.StatusForbidden, kvs)
 }
 
 // ErrNotFound is a shortcut for forbidden requests
 func ErrNotFound(message string, kvs ...interface{}) *ErrResponse {
-	return &ErrResponse{newBaseResponse(message, http.StatusNotFound, kvs)}
+	return newErrResponse(message, http.StatusNotFound, kvs)
+}
+
+// WithCode overrides the error code that would otherwise be derived from the
+// response's HTTP status code - see the api.ErrCode* constants
+func (e *ErrResponse) WithCode(code string) *ErrResponse {
+	e.ErrCode = code
+	return e
+}
+
+// defaultErrCode provides a generic error code for the given HTTP status
+func defaultErrCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return api.ErrCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return api.ErrCodeInvalidToken
+	case http.StatusForbidden:
+		return api.ErrCodeForbidden
+	case http.StatusNotFound:
+		return api.ErrCodeNotFound
+	case http.StatusConflict:
+		return api.ErrCodeConflict
+	case http.StatusPreconditionFailed:
+		return api.ErrCodePreconditionFailed
+	default:
+		if status >= 500 {
+			return api.ErrCodeInternal
+		}
+		return ""
+	}
 }
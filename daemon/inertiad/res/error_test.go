@@ -0,0 +1,35 @@
+package res
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ubclaunchpad/inertia/api"
+)
+
+func TestErrCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *ErrResponse
+		want string
+	}{
+		{"bad request", ErrBadRequest("oops"), api.ErrCodeInvalidRequest},
+		{"unauthorized", ErrUnauthorized("oops"), api.ErrCodeInvalidToken},
+		{"forbidden", ErrForbidden("oops"), api.ErrCodeForbidden},
+		{"not found", ErrNotFound("oops"), api.ErrCodeNotFound},
+		{"conflict", Err("oops", http.StatusConflict), api.ErrCodeConflict},
+		{"precondition", Err("oops", http.StatusPreconditionFailed), api.ErrCodePreconditionFailed},
+		{"internal", ErrInternalServer("oops", errors.New("bad")), api.ErrCodeInternal},
+		{"unavailable", Err("oops", http.StatusServiceUnavailable), api.ErrCodeInternal},
+		{"unknown", Err("oops", http.StatusTeapot), ""},
+		{"override", ErrInternalServer("oops", errors.New("bad")).WithCode(api.ErrCodeBuildFailed), api.ErrCodeBuildFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.resp.ErrCode)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Headers and event header values for generic webhooks, which can be sent by
+// any system, such as a custom CI, that signs payloads with the webhook secret
+var (
+	GenericEventHeader = "X-Inertia-Event"
+	GenericPingHeader  = "ping"
+	GenericPushHeader  = "push"
+)
+
+func parseGenericEvent(rawJSON map[string]interface{}, event string) (Payload, error) {
+	switch event {
+	case GenericPingHeader:
+		return genericPushEvent{eventType: PingEvent}, nil
+	case GenericPushHeader:
+		return parseGenericPushEvent(rawJSON)
+	default:
+		return nil, fmt.Errorf("unsupported generic event %s", event)
+	}
+}
+
+// get payload bytes from request body
+func getGenericPayloadBytes(contentType string, body []byte) ([]byte, error) {
+	switch contentType {
+	case "application/json":
+		return body, nil
+	default:
+		return nil, errors.New("generic Webhook Content-Type must be application/json")
+	}
+}
+
+// Implements Payload interface
+// See generic_test.go for an example request body
+type genericPushEvent struct {
+	eventType EventType
+	ref       string
+	name      string
+	gitURL    string
+	sshURL    string
+}
+
+// parseGenericPushEvent extracts push details - only the ref is required. If
+// no repository SSH URL is given, the push is not checked against the
+// deployed repository.
+func parseGenericPushEvent(rawJSON map[string]interface{}) (genericPushEvent, error) {
+	ref, _ := rawJSON["ref"].(string)
+	if ref == "" {
+		return genericPushEvent{}, errors.New("generic push event must include a ref")
+	}
+	var event = genericPushEvent{eventType: PushEvent, ref: ref}
+	if repo, ok := rawJSON["repository"].(map[string]interface{}); ok {
+		event.name, _ = repo["name"].(string)
+		event.gitURL, _ = repo["git_url"].(string)
+		event.sshURL, _ = repo["ssh_url"].(string)
+	}
+	return event, nil
+}
+
+// GetSource returns the source of the webhook
+func (g genericPushEvent) GetSource() string {
+	return Generic
+}
+
+// GetEventType returns the event type of the webhook
+func (g genericPushEvent) GetEventType() EventType {
+	return g.eventType
+}
+
+// GetRepoName returns the repo name
+func (g genericPushEvent) GetRepoName() string {
+	return g.name
+}
+
+// GetRef returns the full ref
+func (g genericPushEvent) GetRef() string {
+	return g.ref
+}
+
+// GetGitURL returns the git clone URL
+func (g genericPushEvent) GetGitURL() string {
+	return g.gitURL
+}
+
+// GetSSHURL returns the ssh URL
+func (g genericPushEvent) GetSSHURL() string {
+	return g.sshURL
+}
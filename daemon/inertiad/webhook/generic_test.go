@@ -0,0 +1,13 @@
+package webhook
+
+// Generic Push Event, as a custom CI might send it
+var genericPushRawJSON = []byte(`
+{
+	"ref": "refs/heads/master",
+	"repository": {
+		"name": "inertia-deploy-test",
+		"git_url": "https://github.com/ubclaunchpad/inertia-deploy-test.git",
+		"ssh_url": "git@github.com:ubclaunchpad/inertia-deploy-test.git"
+	}
+}
+`)
@@ -20,6 +20,7 @@ const (
 	GitHub    = "github"
 	GitLab    = "gitlab"
 	BitBucket = "bitbucket"
+	Generic   = "generic"
 )
 
 // Payload represents a generic webhook payload
@@ -57,6 +58,8 @@ func Parse(host, eventHeader string, h http.Header, body []byte) (Payload, error
 		return parseGitlabEvent(rawJSON, eventHeader)
 	case BitBucket:
 		return parseBitbucketEvent(rawJSON, eventHeader)
+	case Generic:
+		return parseGenericEvent(rawJSON, eventHeader)
 	default:
 		return nil, errors.New("Unsupported webhook received")
 	}
@@ -102,6 +105,14 @@ func Type(h http.Header) (host string, eventHeader string) {
 	if strings.Contains(userAgent, "Bitbucket") {
 		host = BitBucket
 		eventHeader = h.Get("x-event-key")
+		return
+	}
+
+	// Try generic
+	genericEventHeader := h.Get(GenericEventHeader)
+	if len(genericEventHeader) > 0 {
+		host = Generic
+		eventHeader = genericEventHeader
 	}
 	return
 }
@@ -115,6 +126,8 @@ func getPayloadBytes(host, contentType string, body []byte) ([]byte, error) {
 		return getGitlabPayloadBytes(contentType, body)
 	case BitBucket:
 		return getBitbucketPayloadBytes(contentType, body)
+	case Generic:
+		return getGenericPayloadBytes(contentType, body)
 	default:
 		return nil, errors.New("Unsupported webhook received")
 	}
@@ -31,6 +31,8 @@ func TestTypeAndParse(t *testing.T) {
 		{GitHub, "application/json", githubPushRawJSON, "x-github-event", GithubPingHeader, PingEvent},
 		{GitLab, "application/json", gitlabPushRawJSON, "x-gitlab-event", GitlabPushHeader, PushEvent},
 		{BitBucket, "application/json", bitbucketPushRawJSON, "x-event-key", BitbucketPushHeader, PushEvent},
+		{Generic, "application/json", genericPushRawJSON, "x-inertia-event", GenericPushHeader, PushEvent},
+		{Generic, "application/json", genericPushRawJSON, "x-inertia-event", GenericPingHeader, PingEvent},
 	}
 	for _, tc := range testCases {
 		req := getMockRequest("/webhook", tc.contentType, tc.reqBody)
@@ -0,0 +1,68 @@
+package webhook
+
+import "net/http"
+
+// Provider describes how a supported git host delivers and signs webhooks
+type Provider struct {
+	Name            string
+	EventHeader     string
+	PushEvent       string
+	SignatureHeader string
+	Signature       string
+	ContentTypes    []string
+
+	// DeliveryHeader identifies each delivery - it is the same if the host
+	// delivers an event more than once
+	DeliveryHeader string
+}
+
+// Providers lists the git hosts that webhooks are accepted from
+var Providers = []Provider{
+	{
+		Name:            GitHub,
+		EventHeader:     "X-GitHub-Event",
+		PushEvent:       GithubPushHeader,
+		SignatureHeader: xHubSignatureHeader,
+		Signature:       "HMAC hex digest of the payload using the webhook secret, as 'sha1=<digest>'",
+		ContentTypes:    []string{"application/json", "application/x-www-form-urlencoded"},
+		DeliveryHeader:  "X-GitHub-Delivery",
+	},
+	{
+		Name:            GitLab,
+		EventHeader:     "X-Gitlab-Event",
+		PushEvent:       GitlabPushHeader,
+		SignatureHeader: gitlabTokenHeader,
+		Signature:       "the webhook secret, as is",
+		ContentTypes:    []string{"application/json"},
+		DeliveryHeader:  "X-Gitlab-Event-UUID",
+	},
+	{
+		Name:            BitBucket,
+		EventHeader:     "X-Event-Key",
+		PushEvent:       BitbucketPushHeader,
+		SignatureHeader: xHubSignatureHeader,
+		Signature:       "optional - if provided, verified the same way as GitHub",
+		ContentTypes:    []string{"application/json"},
+		DeliveryHeader:  "X-Request-UUID",
+	},
+	{
+		Name:            Generic,
+		EventHeader:     GenericEventHeader,
+		PushEvent:       GenericPushHeader,
+		SignatureHeader: genericSignatureHeader,
+		Signature:       "HMAC-SHA256 hex digest of the payload using the webhook secret, as 'sha256=<digest>'",
+		ContentTypes:    []string{"application/json"},
+		DeliveryHeader:  "X-Inertia-Delivery",
+	},
+}
+
+// DeliveryID returns the ID of the given webhook delivery from the given
+// host, or an empty string if the host or delivery did not provide one
+func DeliveryID(host string, h http.Header) string {
+	for _, p := range Providers {
+		if p.Name == host {
+			return h.Get(p.DeliveryHeader)
+		}
+	}
+	return ""
+}
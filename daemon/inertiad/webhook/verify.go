@@ -3,14 +3,16 @@ package webhook
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/ubclaunchpad/inertia/daemon/inertiad/crypto"
 )
 
 const (
 	// Signatures
-	xHubSignatureHeader = "X-Hub-Signature"
-	gitlabTokenHeader   = "X-Gitlab-Token"
+	xHubSignatureHeader    = "X-Hub-Signature"
+	gitlabTokenHeader      = "X-Gitlab-Token"
+	genericSignatureHeader = "X-Inertia-Signature"
 )
 
 // Verify ensures the payload's integrity and returns and error if anything
@@ -37,6 +39,17 @@ func Verify(host, key string, h http.Header, body []byte) (err error) {
 			return errors.New("invalid webhook token")
 		}
 		return nil
+	case Generic:
+		// Generic webhooks must be signed with HMAC-SHA256, and are never
+		// accepted without a secret
+		if key == "" {
+			return errors.New("no webhook secret is set up")
+		}
+		var signature = h.Get(genericSignatureHeader)
+		if signature != "" && !strings.HasPrefix(signature, "sha256=") {
+			return errors.New("signature must be an HMAC-SHA256 digest, as 'sha256=<digest>'")
+		}
+		return crypto.ValidateSignature(signature, body, []byte(key))
 	default:
 		return errors.New("unsupported type")
 	}
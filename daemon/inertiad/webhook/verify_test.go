@@ -13,6 +13,8 @@ const (
 	testBody      = `{"yo":true}`
 	testSignature = "sha1=126f2c800419c60137ce748d7672e77b65cf16d6"
 	testKey       = "0123456789abcdef"
+
+	testSHA256Signature = "sha256=b1f8020f5b4cd42042f807dd939015c4a418bc1ff7f604dd55b0a19b5d953d9b"
 )
 
 func TestVerify(t *testing.T) {
@@ -49,6 +51,11 @@ func TestVerify(t *testing.T) {
 			args{GitLab, testBody, gitlabTokenHeader, testKey, testKey},
 			false,
 		},
+		{
+			"generic",
+			args{Generic, testBody, genericSignatureHeader, testSHA256Signature, testKey},
+			false,
+		},
 
 		// not ok cases
 		{
@@ -66,6 +73,21 @@ func TestVerify(t *testing.T) {
 			args{GitLab, testBody, gitlabTokenHeader, "", testKey},
 			true,
 		},
+		{
+			"generic without signature",
+			args{Generic, testBody, genericSignatureHeader, "", testKey},
+			true,
+		},
+		{
+			"generic with SHA-1 signature",
+			args{Generic, testBody, genericSignatureHeader, testSignature, testKey},
+			true,
+		},
+		{
+			"generic without secret",
+			args{Generic, testBody, genericSignatureHeader, testSHA256Signature, ""},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
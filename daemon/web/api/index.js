@@ -103,13 +103,19 @@ export default class InertiaAPI {
   }
 
   /**
-   * Makes a POST request to the given API endpoint with the given params.
+   * Makes a POST request to the given API endpoint with the given params. The
+   * CSRF token issued at login is included, since the daemon requires it for
+   * requests authenticated by the session cookie.
    * @param {String} endpoint
    * @param {Object} params
    */
   static async post(endpoint, params) {
     const newParams = {
       ...params,
+      headers: {
+        ...(params && params.headers),
+        'X-CSRF-Token': cookies.get('ubclaunchpad-inertia-csrf') || '',
+      },
       method: 'POST',
       credentials: 'include',
     };
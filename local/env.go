@@ -2,15 +2,101 @@ package local
 
 import (
 	"errors"
+	"net"
 	"os"
 	"runtime"
+	"strings"
+
+	"github.com/ubclaunchpad/inertia/cfg"
 )
 
 const (
 	// EnvSSHPassphrase is the key used to fetch PEM key passphrases
 	EnvSSHPassphrase = "PEM_PASSPHRASE"
+
+	// EnvRemote is the key used to fetch the address of a daemon, in the
+	// form 'host:port'
+	EnvRemote = "INERTIA_REMOTE"
+	// EnvToken is the key used to fetch a daemon API token
+	EnvToken = "INERTIA_TOKEN"
+	// EnvProject is the key used to fetch the project name
+	EnvProject = "INERTIA_PROJECT"
+	// EnvBuildType is the key used to fetch the project build type
+	EnvBuildType = "INERTIA_BUILD_TYPE"
+	// EnvBuildFilePath is the key used to fetch the project build file path
+	EnvBuildFilePath = "INERTIA_BUILD_FILE_PATH"
+	// EnvBranch is the key used to fetch the branch to deploy
+	EnvBranch = "INERTIA_BRANCH"
+
+	// EnvDefaultRemote is the key used to override the user's default remote
+	EnvDefaultRemote = "INERTIA_DEFAULT_REMOTE"
+	// EnvDefaultProject is the key used to override the user's default project
+	// configuration path
+	EnvDefaultProject = "INERTIA_DEFAULT_PROJECT"
+
+	// EnvRemoteName is the name of the remote configured from the environment
+	EnvRemoteName = "ci"
+
+	defaultDaemonPort = "4303"
 )
 
+// GetProjectConfigFromEnv builds a project configuration with a single remote,
+// named EnvRemoteName, entirely from the environment. This allows the CLI to
+// be used without a configuration file, for example in CI.
+func GetProjectConfigFromEnv(version string) (*cfg.Config, error) {
+	var address = os.Getenv(EnvRemote)
+	if address == "" {
+		return nil, errors.New(EnvRemote + " environment variable is blank")
+	}
+
+	var buildType = os.Getenv(EnvBuildType)
+	if buildType == "" {
+		buildType = "docker-compose"
+	}
+	var config = cfg.NewConfig(version, os.Getenv(EnvProject), buildType,
+		os.Getenv(EnvBuildFilePath))
+
+	var branch = os.Getenv(EnvBranch)
+	if branch == "" {
+		// fall back to the current branch if available
+		branch, _ = GetRepoCurrentBranch()
+	}
+	var remote = &cfg.RemoteVPS{
+		Name:   EnvRemoteName,
+		Branch: branch,
+	}
+	ApplyEnvOverrides(remote)
+	config.AddRemote(remote)
+
+	return config, nil
+}
+
+// ApplyEnvOverrides overrides the given remote's daemon address and API token
+// with values set in the environment, if any.
+func ApplyEnvOverrides(remote *cfg.RemoteVPS) {
+	if remote.Daemon == nil {
+		remote.Daemon = &cfg.DaemonConfig{}
+	}
+	if address := os.Getenv(EnvRemote); address != "" {
+		remote.IP, remote.Daemon.Port = parseDaemonAddress(address)
+	}
+	if token := os.Getenv(EnvToken); token != "" {
+		remote.Daemon.Token = token
+	}
+}
+
+// parseDaemonAddress splits a daemon address into its host and port, using
+// the default daemon port if none is provided
+func parseDaemonAddress(address string) (host, port string) {
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimSuffix(address, "/")
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, defaultDaemonPort
+	}
+	return host, port
+}
+
 // GetHomePath returns the homepath based on the operating system used
 func GetHomePath() (string, error) {
 	if runtime.GOOS == "windows" {
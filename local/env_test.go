@@ -1,9 +1,11 @@
 package local
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/ubclaunchpad/inertia/cfg"
 )
 
 func TestGetHomePath(t *testing.T) {
@@ -12,3 +14,51 @@ func TestGetHomePath(t *testing.T) {
 	assert.NotEqual(t, env, "")
 	assert.DirExists(t, env)
 }
+
+func TestGetProjectConfigFromEnv(t *testing.T) {
+	_, err := GetProjectConfigFromEnv("test")
+	assert.Error(t, err)
+
+	os.Setenv(EnvRemote, "https://10.0.0.1:8080")
+	os.Setenv(EnvToken, "abcdefg")
+	os.Setenv(EnvProject, "inertia")
+	os.Setenv(EnvBranch, "dev")
+	defer func() {
+		for _, key := range []string{EnvRemote, EnvToken, EnvProject, EnvBranch} {
+			os.Unsetenv(key)
+		}
+	}()
+
+	config, err := GetProjectConfigFromEnv("test")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", config.Version)
+	assert.Equal(t, "inertia", config.Project)
+	assert.Equal(t, "docker-compose", config.BuildType)
+
+	remote, found := config.GetRemote(EnvRemoteName)
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.1", remote.IP)
+	assert.Equal(t, "8080", remote.Daemon.Port)
+	assert.Equal(t, "abcdefg", remote.Daemon.Token)
+	assert.Equal(t, "dev", remote.Branch)
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	var remote = &cfg.RemoteVPS{
+		IP:     "127.0.0.1",
+		Daemon: &cfg.DaemonConfig{Port: "4303", Token: "abcdefg"},
+	}
+
+	// nothing set
+	ApplyEnvOverrides(remote)
+	assert.Equal(t, "127.0.0.1:4303", remote.GetIPAndPort())
+	assert.Equal(t, "abcdefg", remote.Daemon.Token)
+
+	os.Setenv(EnvRemote, "10.0.0.1")
+	os.Setenv(EnvToken, "hijklmn")
+	defer os.Unsetenv(EnvRemote)
+	defer os.Unsetenv(EnvToken)
+	ApplyEnvOverrides(remote)
+	assert.Equal(t, "10.0.0.1:4303", remote.GetIPAndPort())
+	assert.Equal(t, "hijklmn", remote.Daemon.Token)
+}
@@ -0,0 +1,64 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ubclaunchpad/inertia/cfg"
+)
+
+// GetUserConfigPath returns the location of the user's CLI configuration
+func GetUserConfigPath() (string, error) {
+	home, err := GetHomePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".inertia", "config.toml"), nil
+}
+
+// GetUserConfig reads the user's CLI configuration from the given path. A
+// missing file is not an error, and results in an empty configuration.
+func GetUserConfig(path string) (*cfg.UserConfig, error) {
+	var user cfg.UserConfig
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := toml.Unmarshal(raw, &user); err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// ApplyUserEnvOverrides overrides the given user configuration with defaults
+// set in the environment, if any.
+func ApplyUserEnvOverrides(user *cfg.UserConfig) {
+	if remote := os.Getenv(EnvDefaultRemote); remote != "" {
+		user.DefaultRemote = remote
+	}
+	if project := os.Getenv(EnvDefaultProject); project != "" {
+		user.DefaultProject = project
+	}
+}
+
+// ResolveProjectConfigPath returns the project configuration path to use.
+// An explicitly provided path is always used as is - otherwise, the given
+// default project is used if the current directory has no configuration.
+func ResolveProjectConfigPath(path string, explicit bool, defaultProject string) string {
+	if explicit || defaultProject == "" {
+		return path
+	}
+	if full, err := filepath.Abs(path); err == nil {
+		if _, err := os.Stat(full); err == nil {
+			return path
+		}
+	}
+	if info, err := os.Stat(defaultProject); err == nil && info.IsDir() {
+		return filepath.Join(defaultProject, "inertia.toml")
+	}
+	return defaultProject
+}
@@ -0,0 +1,62 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-user")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "config.toml")
+
+	// missing file
+	user, err := GetUserConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "", user.DefaultRemote)
+
+	// from file
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`default-remote = "staging"
+default-project = "/projects/wow"`), 0644))
+	user, err = GetUserConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", user.DefaultRemote)
+	assert.Equal(t, "/projects/wow", user.DefaultProject)
+
+	// environment overrides file
+	os.Setenv(EnvDefaultRemote, "production")
+	defer os.Unsetenv(EnvDefaultRemote)
+	user, err = GetUserConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", user.DefaultRemote)
+	ApplyUserEnvOverrides(user)
+	assert.Equal(t, "production", user.DefaultRemote)
+	assert.Equal(t, "/projects/wow", user.DefaultProject)
+
+	// malformed file
+	assert.Nil(t, ioutil.WriteFile(path, []byte("default-remote = "), 0644))
+	_, err = GetUserConfig(path)
+	assert.Error(t, err)
+}
+
+func TestResolveProjectConfigPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inertia-project")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// no default
+	assert.Equal(t, "inertia.toml", ResolveProjectConfigPath("inertia.toml", false, ""))
+	// explicit path always wins
+	assert.Equal(t, "inertia.toml", ResolveProjectConfigPath("inertia.toml", true, dir))
+	// default project directory is used when no local configuration exists
+	assert.Equal(t, filepath.Join(dir, "inertia.toml"),
+		ResolveProjectConfigPath("does-not-exist.toml", false, dir))
+	// default project file
+	assert.Equal(t, "/projects/wow.toml",
+		ResolveProjectConfigPath("does-not-exist.toml", false, "/projects/wow.toml"))
+}
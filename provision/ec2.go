@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -134,32 +136,166 @@ type EC2CreateInstanceOptions struct {
 	ImageID      string
 	InstanceType string
 	Region       string
+
+	// Timeouts limits how long each provisioning step may take
+	Timeouts Timeouts
 }
 
-// CreateInstance creates an EC2 instance with given properties
+// CreateInstance creates an EC2 instance with given properties. Each step is
+// subject to the configured timeouts, and if any step fails, resources that
+// were already created for the instance are removed.
 func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.RemoteVPS, error) {
 	// Set requested region
 	p.WithRegion(opts.Region)
 
-	// Generate authentication
-	var keyName = fmt.Sprintf("%s_%s_inertia_key_%d", opts.Name, p.user, time.Now().UnixNano())
-	fmt.Printf("Generating key pair %s...\n", keyName)
-	keyResp, err := p.client.CreateKeyPair(&ec2.CreateKeyPairInput{
+	var (
+		timeouts = opts.Timeouts.withDefaults()
+		steps    = newSteps(p.out)
+		keyName  = fmt.Sprintf("%s_%s_inertia_key_%d", opts.Name, p.user, time.Now().UnixNano())
+		keyPath  = filepath.Join(os.Getenv("HOME"), ".ssh", keyName)
+		groupID  string
+		instance *ec2.Instance
+	)
+
+	// Authentication and network configuration are independent of each other
+	if err := steps.runConcurrently(step{
+		name:    "create key pair " + keyName,
+		timeout: timeouts.Request,
+		run: func(ctx context.Context) error {
+			return p.createKeyPair(ctx, steps, keyName, keyPath, timeouts.Request)
+		},
+	}, step{
+		name:    "create security group",
+		timeout: timeouts.Request,
+		run: func(ctx context.Context) (err error) {
+			groupID, err = p.createSecurityGroup(ctx, steps, opts, timeouts.Request)
+			return err
+		},
+	}); err != nil {
+		return nil, p.abort(steps, err)
+	}
+
+	// Start up instance
+	if err := steps.run(step{
+		name:    "start instance",
+		timeout: timeouts.Startup,
+		run: func(ctx context.Context) (err error) {
+			instance, err = p.startInstance(ctx, steps, opts, keyName, groupID, timeouts.Request)
+			return err
+		},
+	}); err != nil {
+		return nil, p.abort(steps, err)
+	}
+
+	// Set tags
+	if err := steps.run(step{
+		name:    "tag instance",
+		timeout: timeouts.Request,
+		run: func(ctx context.Context) error {
+			_, err := p.client.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+				Resources: []*string{instance.InstanceId},
+				Tags: []*ec2.Tag{
+					{
+						Key:   aws.String("Name"),
+						Value: aws.String(opts.Name),
+					},
+					{
+						Key:   aws.String("Purpose"),
+						Value: aws.String("Inertia Continuous Deployment"),
+					},
+				},
+			})
+			return err
+		},
+	}); err != nil {
+		fmt.Fprintln(p.out, "Failed to set tags - continuing")
+	}
+
+	// Poll for SSH port to open
+	if err := steps.run(step{
+		name:    "wait for SSH",
+		timeout: timeouts.SSH,
+		run: func(ctx context.Context) error {
+			return p.waitForSSH(ctx, *instance.PublicDnsName+":22")
+		},
+	}); err != nil {
+		return nil, p.abort(steps, err)
+	}
+
+	// Generate webhook secret
+	webhookSecret, err := common.GenerateRandomString()
+	if err != nil {
+		fmt.Fprintln(p.out, err.Error())
+		fmt.Fprintln(p.out, "Using default secret 'inertia'")
+		webhookSecret = "interia"
+	} else {
+		fmt.Fprintf(p.out, "Generated webhook secret: '%s'\n", webhookSecret)
+	}
+
+	// Return remote configuration
+	return &cfg.RemoteVPS{
+		Name:    opts.Name,
+		IP:      *instance.PublicDnsName,
+		User:    p.user,
+		PEM:     keyPath,
+		SSHPort: "22",
+		Daemon: &cfg.DaemonConfig{
+			Port:          strconv.FormatInt(opts.DaemonPort, 10),
+			WebHookSecret: webhookSecret,
+		},
+	}, nil
+}
+
+// abort cleans up resources created by completed steps, and returns the
+// error that caused provisioning to fail along with any resources that could
+// not be cleaned up
+func (p *EC2Provisioner) abort(steps *steps, err error) error {
+	fmt.Fprintln(p.out, "Provisioning failed - cleaning up created resources...")
+	if failed := steps.cleanup(); len(failed) > 0 {
+		return fmt.Errorf("%s (unable to clean up: %s - these may need to be removed manually)",
+			err.Error(), strings.Join(failed, ", "))
+	}
+	return err
+}
+
+// createKeyPair generates a key pair and saves the private key to keyPath
+func (p *EC2Provisioner) createKeyPair(ctx context.Context, steps *steps,
+	keyName, keyPath string, timeout time.Duration) error {
+	keyResp, err := p.client.CreateKeyPairWithContext(ctx, &ec2.CreateKeyPairInput{
 		KeyName: aws.String(keyName),
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	steps.onCleanup(step{
+		name:    "delete key pair " + keyName,
+		timeout: timeout,
+		run: func(ctx context.Context) error {
+			_, err := p.client.DeleteKeyPairWithContext(ctx, &ec2.DeleteKeyPairInput{
+				KeyName: aws.String(keyName),
+			})
+			return err
+		},
+	})
 
 	// Save key
-	keyPath := filepath.Join(os.Getenv("HOME"), ".ssh", *keyResp.KeyName)
-	fmt.Printf("Saving key to %s...\n", keyPath)
+	fmt.Fprintf(p.out, "Saving key to %s...\n", keyPath)
 	if err = local.SaveKey(*keyResp.KeyMaterial, keyPath); err != nil {
-		return nil, err
+		return err
 	}
+	steps.onCleanup(step{
+		name:    "remove key " + keyPath,
+		timeout: timeout,
+		run:     func(context.Context) error { return os.Remove(keyPath) },
+	})
+	return nil
+}
 
-	// Create security group for network configuration
-	group, err := p.client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+// createSecurityGroup creates a security group exposing the given ports for
+// the instance, and returns its ID
+func (p *EC2Provisioner) createSecurityGroup(ctx context.Context, steps *steps,
+	opts EC2CreateInstanceOptions, timeout time.Duration) (string, error) {
+	group, err := p.client.CreateSecurityGroupWithContext(ctx, &ec2.CreateSecurityGroupInput{
 		GroupName: aws.String(
 			fmt.Sprintf("%s-%s-%d", opts.ProjectName, opts.Name, time.Now().UnixNano()),
 		),
@@ -168,24 +304,36 @@ func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.Rem
 		),
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	steps.onCleanup(step{
+		name:    "delete security group " + *group.GroupId,
+		timeout: timeout,
+		run: func(ctx context.Context) error {
+			_, err := p.client.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{
+				GroupId: group.GroupId,
+			})
+			return err
+		},
+	})
 
 	// Set rules for ports
-	if err = p.exposePorts(*group.GroupId, opts.DaemonPort, opts.Ports); err != nil {
-		return nil, err
-	}
+	return *group.GroupId, p.exposePorts(ctx, *group.GroupId, opts.DaemonPort, opts.Ports)
+}
 
-	// Start up instance
-	runResp, err := p.client.RunInstances(&ec2.RunInstancesInput{
+// startInstance runs an instance and waits for it to start running
+func (p *EC2Provisioner) startInstance(ctx context.Context, steps *steps,
+	opts EC2CreateInstanceOptions, keyName, groupID string,
+	timeout time.Duration) (*ec2.Instance, error) {
+	runResp, err := p.client.RunInstancesWithContext(ctx, &ec2.RunInstancesInput{
 		ImageId:      aws.String(opts.ImageID),
 		InstanceType: aws.String(opts.InstanceType),
 		MinCount:     aws.Int64(1),
 		MaxCount:     aws.Int64(1),
 
 		// Security options
-		KeyName:          keyResp.KeyName,
-		SecurityGroupIds: []*string{group.GroupId},
+		KeyName:          aws.String(keyName),
+		SecurityGroupIds: []*string{aws.String(groupID)},
 	})
 	if err != nil {
 		return nil, err
@@ -195,17 +343,35 @@ func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.Rem
 	if runResp.Instances == nil || len(runResp.Instances) == 0 {
 		return nil, errors.New("Unable to start instances: " + runResp.String())
 	}
+	var instanceID = runResp.Instances[0].InstanceId
+	steps.onCleanup(step{
+		name: "terminate instance " + *instanceID,
+		// The security group can only be deleted once the instance is gone,
+		// so wait for termination to complete
+		timeout: timeout + DefaultStartupTimeout,
+		run: func(ctx context.Context) error {
+			var input = &ec2.TerminateInstancesInput{InstanceIds: []*string{instanceID}}
+			if _, err := p.client.TerminateInstancesWithContext(ctx, input); err != nil {
+				return err
+			}
+			return p.client.WaitUntilInstanceTerminatedWithContext(ctx,
+				&ec2.DescribeInstancesInput{InstanceIds: []*string{instanceID}})
+		},
+	})
 
 	// Loop until intance is running
 	fmt.Fprintln(p.out, "Checking status of requested instance...")
-	var instance ec2.Instance
 	for {
 		// Wait briefly between checks
-		time.Sleep(3 * time.Second)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(3 * time.Second):
+		}
 
 		// Request instance status
-		result, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
-			InstanceIds: []*string{runResp.Instances[0].InstanceId},
+		result, err := p.client.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: []*string{instanceID},
 		})
 		if err != nil {
 			return nil, err
@@ -223,15 +389,20 @@ func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.Rem
 		// Get status
 		s := result.Reservations[0].Instances[0].State
 		if s == nil {
-			fmt.Println(p.out, "Status unknown.")
+			fmt.Fprintln(p.out, "Status unknown.")
 			continue
 		}
 
 		// Code 16 means instance has started, and we can continue!
 		if s.Code != nil && *s.Code == codeEC2InstanceStarted {
 			fmt.Fprintln(p.out, "Instance is running!")
-			instance = *result.Reservations[0].Instances[0]
-			break
+			var instance = result.Reservations[0].Instances[0]
+
+			// Check instance validity
+			if instance.PublicDnsName == nil {
+				return nil, errors.New("Unable to find public IP address for instance: " + instance.String())
+			}
+			return instance, nil
 		}
 
 		// Otherwise, keep polling
@@ -240,65 +411,25 @@ func (p *EC2Provisioner) CreateInstance(opts EC2CreateInstanceOptions) (*cfg.Rem
 		} else {
 			fmt.Fprintln(p.out, "Instance status: "+s.String())
 		}
-		continue
-	}
-
-	// Check instance validity
-	if instance.PublicDnsName == nil {
-		return nil, errors.New("Unable to find public IP address for instance: " + instance.String())
-	}
-
-	// Set tags
-	if _, err = p.client.CreateTags(&ec2.CreateTagsInput{
-		Resources: []*string{instance.InstanceId},
-		Tags: []*ec2.Tag{
-			{
-				Key:   aws.String("Name"),
-				Value: aws.String(opts.Name),
-			},
-			{
-				Key:   aws.String("Purpose"),
-				Value: aws.String("Inertia Continuous Deployment"),
-			},
-		},
-	}); err != nil {
-		fmt.Fprintln(p.out, "Failed to set tags: "+err.Error())
 	}
+}
 
-	// Poll for SSH port to open
-	fmt.Fprintln(p.out, "Waiting for ports to open...")
+// waitForSSH polls the given address until it accepts connections
+func (p *EC2Provisioner) waitForSSH(ctx context.Context, address string) error {
+	var dialer net.Dialer
 	for {
-		time.Sleep(3 * time.Second)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(3 * time.Second):
+		}
 		fmt.Fprintln(p.out, "Checking ports...")
-		if conn, err := net.Dial("tcp", *instance.PublicDnsName+":22"); err == nil {
+		if conn, err := dialer.DialContext(ctx, "tcp", address); err == nil {
 			fmt.Fprintln(p.out, "Connection established!")
 			conn.Close()
-			break
+			return nil
 		}
 	}
-
-	// Generate webhook secret
-	webhookSecret, err := common.GenerateRandomString()
-	if err != nil {
-		fmt.Fprintln(p.out, err.Error())
-		fmt.Fprintln(p.out, "Using default secret 'inertia'")
-		webhookSecret = "interia"
-	} else {
-		fmt.Fprintf(p.out, "Generated webhook secret: '%s'\n", webhookSecret)
-	}
-
-	// Return remote configuration
-	return &cfg.RemoteVPS{
-		Name:    opts.Name,
-		IP:      *instance.PublicDnsName,
-		User:    p.user,
-		PEM:     keyPath,
-		SSHPort: "22",
-		Daemon: &cfg.DaemonConfig{
-			Port:          strconv.FormatInt(opts.DaemonPort, 10),
-			WebHookSecret: webhookSecret,
-		},
-	}, nil
 }
 
 // WithRegion assigns a region to the client
@@ -309,7 +440,8 @@ func (p *EC2Provisioner) WithRegion(region string) {
 
 // exposePorts updates the security rules of given security group to expose
 // given ports
-func (p *EC2Provisioner) exposePorts(securityGroupID string, daemonPort int64, ports []int64) error {
+func (p *EC2Provisioner) exposePorts(ctx context.Context, securityGroupID string,
+	daemonPort int64, ports []int64) error {
 	// Create Inertia rules
 	portRules := []*ec2.IpPermission{{
 		FromPort:   aws.Int64(int64(22)),
@@ -337,7 +469,7 @@ func (p *EC2Provisioner) exposePorts(securityGroupID string, daemonPort int64, p
 	}
 
 	// Set rules
-	_, err := p.client.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+	_, err := p.client.AuthorizeSecurityGroupIngressWithContext(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId:       aws.String(securityGroupID),
 		IpPermissions: portRules,
 	})
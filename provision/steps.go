@@ -0,0 +1,143 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestTimeout is the default time allowed for each provider API
+	// request made while provisioning
+	DefaultRequestTimeout = time.Minute
+
+	// DefaultStartupTimeout is the default time allowed for a new host to
+	// start running
+	DefaultStartupTimeout = 5 * time.Minute
+
+	// DefaultSSHTimeout is the default time allowed for a new host to start
+	// accepting SSH connections
+	DefaultSSHTimeout = 5 * time.Minute
+)
+
+// Timeouts configures how long each provisioning step may take before it is
+// considered to have failed. Unset values use defaults.
+type Timeouts struct {
+	// Request is the time allowed for each provider API request
+	Request time.Duration
+
+	// Startup is the time allowed for a new host to start running
+	Startup time.Duration
+
+	// SSH is the time allowed for a new host to start accepting SSH
+	// connections
+	SSH time.Duration
+}
+
+// withDefaults returns a copy of the timeouts with unset values replaced by
+// defaults
+func (t Timeouts) withDefaults() Timeouts {
+	if t.Request <= 0 {
+		t.Request = DefaultRequestTimeout
+	}
+	if t.Startup <= 0 {
+		t.Startup = DefaultStartupTimeout
+	}
+	if t.SSH <= 0 {
+		t.SSH = DefaultSSHTimeout
+	}
+	return t
+}
+
+// step is a single provisioning step
+type step struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// steps runs provisioning steps with timeouts, reporting progress as they go,
+// and keeps track of how to undo the steps that have completed
+type steps struct {
+	out io.Writer
+
+	mux      sync.Mutex
+	cleanups []step
+}
+
+// newSteps creates a step runner that reports progress to out
+func newSteps(out io.Writer) *steps {
+	return &steps{out: out}
+}
+
+// run runs the given step, failing it if it does not complete within its
+// timeout
+func (s *steps) run(st step) error {
+	var start = time.Now()
+	fmt.Fprintf(s.out, "[%s] started\n", st.name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), st.timeout)
+	defer cancel()
+	var err = st.run(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", st.timeout)
+	}
+
+	var elapsed = time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(s.out, "[%s] failed after %s: %s\n", st.name, elapsed, err.Error())
+		return fmt.Errorf("%s: %s", st.name, err.Error())
+	}
+	fmt.Fprintf(s.out, "[%s] done in %s\n", st.name, elapsed)
+	return nil
+}
+
+// runConcurrently runs independent steps at the same time, and returns the
+// first error encountered once all of them have completed
+func (s *steps) runConcurrently(sts ...step) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(sts))
+	)
+	for i, st := range sts {
+		wg.Add(1)
+		go func(i int, st step) {
+			defer wg.Done()
+			errs[i] = s.run(st)
+		}(i, st)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onCleanup registers a step that undoes a completed step. Cleanup steps are
+// run in reverse order of registration.
+func (s *steps) onCleanup(st step) {
+	s.mux.Lock()
+	s.cleanups = append(s.cleanups, st)
+	s.mux.Unlock()
+}
+
+// cleanup runs all registered cleanup steps, continuing past failures so that
+// as much as possible is cleaned up. Returns the names of steps that failed.
+func (s *steps) cleanup() []string {
+	s.mux.Lock()
+	var cleanups = s.cleanups
+	s.cleanups = nil
+	s.mux.Unlock()
+
+	var failed []string
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if err := s.run(cleanups[i]); err != nil {
+			failed = append(failed, cleanups[i].name)
+		}
+	}
+	return failed
+}
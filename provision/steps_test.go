@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeouts_withDefaults(t *testing.T) {
+	assert.Equal(t, Timeouts{
+		Request: DefaultRequestTimeout,
+		Startup: DefaultStartupTimeout,
+		SSH:     DefaultSSHTimeout,
+	}, Timeouts{}.withDefaults())
+	assert.Equal(t, time.Second,
+		Timeouts{Request: time.Second}.withDefaults().Request)
+}
+
+func TestSteps_run(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		run     func(context.Context) error
+		wantErr string
+	}{
+		{"ok", time.Second, func(context.Context) error { return nil }, ""},
+		{"failed", time.Second, func(context.Context) error {
+			return errors.New("oh no")
+		}, "failed: oh no"},
+		{"timed out", 10 * time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, "timed out: timed out after 10ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out = &bytes.Buffer{}
+			err := newSteps(out).run(step{name: tt.name, timeout: tt.timeout, run: tt.run})
+			if tt.wantErr == "" {
+				assert.Nil(t, err)
+				assert.Contains(t, out.String(), "["+tt.name+"] done in")
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Contains(t, out.String(), "["+tt.name+"] failed after")
+			}
+		})
+	}
+}
+
+func TestSteps_runConcurrently(t *testing.T) {
+	var (
+		s       = newSteps(&bytes.Buffer{})
+		started = make(chan struct{})
+		wait    = func(ctx context.Context) error {
+			// each step can only complete once the other has started
+			select {
+			case started <- struct{}{}:
+			case <-started:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		}
+	)
+	assert.Nil(t, s.runConcurrently(
+		step{name: "a", timeout: time.Second, run: wait},
+		step{name: "b", timeout: time.Second, run: wait},
+	))
+
+	err := s.runConcurrently(
+		step{name: "a", timeout: time.Second, run: func(context.Context) error { return nil }},
+		step{name: "b", timeout: time.Second, run: func(context.Context) error {
+			return errors.New("oh no")
+		}},
+	)
+	assert.EqualError(t, err, "b: oh no")
+}
+
+func TestSteps_cleanup(t *testing.T) {
+	var (
+		s     = newSteps(&bytes.Buffer{})
+		order []string
+		undo  = func(name string, err error) step {
+			return step{name: name, timeout: time.Second, run: func(context.Context) error {
+				order = append(order, name)
+				return err
+			}}
+		}
+	)
+	s.onCleanup(undo("delete key", nil))
+	s.onCleanup(undo("delete group", errors.New("in use")))
+	s.onCleanup(undo("terminate instance", nil))
+
+	assert.Equal(t, []string{"delete group"}, s.cleanup())
+	assert.Equal(t, []string{"terminate instance", "delete group", "delete key"}, order)
+
+	// cleanup steps only run once
+	assert.Empty(t, s.cleanup())
+	assert.Len(t, order, 3)
+}